@@ -12,9 +12,16 @@ require (
 	github.com/spf13/cobra v1.1.1
 	github.com/spf13/pflag v1.0.5
 	golang.org/x/sync v0.0.0-20201207232520-09787c993a3a
-	golang.org/x/sys v0.0.0-20201221093633-bc327ba9c2f0 // indirect
+	golang.org/x/sys v0.0.0-20201221093633-bc327ba9c2f0
 	google.golang.org/api v0.36.0
+	k8s.io/api v0.20.2
+	k8s.io/apimachinery v0.20.2
+	k8s.io/client-go v9.0.0+incompatible
 	k8s.io/klog v1.0.0
 	k8s.io/release v0.7.1-0.20210204090829-09fb5e3883b8
 	sigs.k8s.io/boskos v0.0.0-20200710214748-f5935686c7fc
 )
+
+replace vbom.ml/util => github.com/fvbommel/util v0.0.0-20180919145318-efcd4e0f9787
+
+replace k8s.io/client-go => k8s.io/client-go v0.20.2
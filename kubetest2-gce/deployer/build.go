@@ -112,5 +112,6 @@ func (d *deployer) verifyBuildFlags() error {
 		return err
 	}
 	d.BuildOptions.CommonBuildOptions.RepoRoot = d.RepoRoot
+	d.BuildOptions.CommonBuildOptions.RunDir = d.commonOptions.RunDir()
 	return d.BuildOptions.Validate()
 }
@@ -0,0 +1,152 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package deployer
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"k8s.io/klog"
+
+	"sigs.k8s.io/kubetest2/pkg/exec"
+)
+
+// the network plugins supported by --cni
+const (
+	cniKubenet = "kubenet"
+	cniCalico  = "calico"
+	cniCilium  = "cilium"
+)
+
+// cniNodesReadyTimeout is how long the nodes have to become ready once
+// the CNI of --cni-manifest is installed
+const cniNodesReadyTimeout = "10m"
+
+func (d *deployer) verifyCNIFlags() error {
+	switch d.CNI {
+	case "", cniKubenet, cniCalico:
+		if d.CNIManifest != "" {
+			return fmt.Errorf("--cni-manifest is only supported with --cni=%s", cniCilium)
+		}
+	case cniCilium:
+		if d.CNIManifest == "" {
+			return fmt.Errorf("--cni=%s requires --cni-manifest, kube-up does not install it", cniCilium)
+		}
+	default:
+		return fmt.Errorf("unsupported --cni %q, must be one of %s, %s or %s", d.CNI, cniKubenet, cniCalico, cniCilium)
+	}
+	if d.NodeStartupScript != "" {
+		if _, err := os.Stat(d.NodeStartupScript); err != nil {
+			return fmt.Errorf("failed to find the --node-startup-script: %s", err)
+		}
+	}
+	return nil
+}
+
+// instanceEnv returns the kube-up environment configuring the network plugin
+// and the instance templates of the cluster
+func (d *deployer) instanceEnv() []string {
+	var env []string
+	switch d.CNI {
+	case cniKubenet:
+		env = append(env, "NETWORK_PROVIDER=kubenet")
+	case cniCalico:
+		env = append(env, "NETWORK_PROVIDER=kubenet", "NETWORK_POLICY_PROVIDER=calico")
+	case cniCilium:
+		// the nodes are not ready until the CNI is installed after kube-up,
+		// so the cluster validation of kube-up must not wait for them
+		env = append(env, "NETWORK_PROVIDER=cni", fmt.Sprintf("ALLOWED_NOTREADY_NODES=%d", d.NumNodes))
+	}
+
+	for name, value := range map[string]string{
+		"MASTER_SIZE":               d.MasterSize,
+		"NODE_SIZE":                 d.NodeSize,
+		"NODE_DISK_SIZE":            d.NodeDiskSize,
+		"KUBE_GCE_NODE_IMAGE":       d.NodeImage,
+		"KUBE_GCE_NODE_PROJECT":     d.NodeImageProject,
+		"KUBE_NODE_OS_DISTRIBUTION": d.NodeOSDistribution,
+	} {
+		if value != "" {
+			env = append(env, fmt.Sprintf("%s=%s", name, value))
+		}
+	}
+
+	// the extra metadata is added to the node instance template, where the
+	// startup-script is run by the guest environment of the image at boot
+	if d.NodeStartupScript != "" {
+		env = append(env, fmt.Sprintf("KUBE_NODE_EXTRA_METADATA=startup-script=%s", d.NodeStartupScript))
+	}
+	return env
+}
+
+// installCNI installs the network plugin of --cni-manifest, kube-up only
+// installs the built-in ones, and waits for the nodes to become ready
+func (d *deployer) installCNI() error {
+	if d.CNIManifest == "" {
+		return nil
+	}
+	klog.V(1).Infof("Installing the %s CNI from %s", d.CNI, d.CNIManifest)
+	cmd := exec.Command(d.kubectlPath, "apply", "--filename="+d.CNIManifest)
+	cmd.SetEnv(d.buildEnv()...)
+	exec.InheritOutput(cmd)
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("failed to install the %s CNI: %s", d.CNI, err)
+	}
+
+	cmd = exec.Command(d.kubectlPath, "wait", "nodes", "--all", "--for=condition=Ready", "--timeout="+cniNodesReadyTimeout)
+	cmd.SetEnv(d.buildEnv()...)
+	exec.InheritOutput(cmd)
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("nodes did not become ready after installing the %s CNI: %s", d.CNI, err)
+	}
+	return nil
+}
+
+// tagInstances adds the --instance-tags to the instances of the cluster.
+// kube-up does not support extra tags in its instance templates, so nodes
+// recreated by their instance group later on do not have them.
+func (d *deployer) tagInstances() error {
+	if len(d.InstanceTags) == 0 {
+		return nil
+	}
+	cmd := exec.Command("gcloud", "compute", "instances", "list",
+		"--project="+d.GCPProject,
+		"--filter=name ~ ^"+d.instancePrefix+"-",
+		"--format=value(name,zone.basename())")
+	lines, err := exec.OutputLines(cmd)
+	if err != nil {
+		return fmt.Errorf("failed to list the instances of the cluster: %s", err)
+	}
+	tags := strings.Join(d.InstanceTags, ",")
+	for _, line := range lines {
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			continue
+		}
+		klog.V(2).Infof("Adding tags %s to instance %s", tags, fields[0])
+		cmd := exec.Command("gcloud", "compute", "instances", "add-tags", fields[0],
+			"--project="+d.GCPProject,
+			"--zone="+fields[1],
+			"--tags="+tags)
+		exec.InheritOutput(cmd)
+		if err := cmd.Run(); err != nil {
+			return fmt.Errorf("failed to tag instance %s: %s", fields[0], err)
+		}
+	}
+	return nil
+}
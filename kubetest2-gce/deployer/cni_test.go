@@ -0,0 +1,102 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package deployer
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestVerifyCNIFlags(t *testing.T) {
+	testCases := []struct {
+		name        string
+		cni         string
+		manifest    string
+		expectError bool
+	}{
+		{name: "default"},
+		{name: "kubenet", cni: "kubenet"},
+		{name: "calico", cni: "calico"},
+		{name: "cilium", cni: "cilium", manifest: "https://example.com/cilium.yaml"},
+		{name: "cilium without manifest", cni: "cilium", expectError: true},
+		{name: "manifest without cilium", cni: "calico", manifest: "calico.yaml", expectError: true},
+		{name: "unsupported", cni: "flannel", expectError: true},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			d := &deployer{CNI: tc.cni, CNIManifest: tc.manifest}
+			err := d.verifyCNIFlags()
+			if (err != nil) != tc.expectError {
+				t.Errorf("expected error: %v, but got: %v", tc.expectError, err)
+			}
+		})
+	}
+}
+
+func TestInstanceEnv(t *testing.T) {
+	testCases := []struct {
+		name     string
+		d        *deployer
+		expected []string
+	}{
+		{
+			name: "defaults",
+			d:    &deployer{},
+		},
+		{
+			name:     "calico",
+			d:        &deployer{CNI: "calico"},
+			expected: []string{"NETWORK_POLICY_PROVIDER=calico", "NETWORK_PROVIDER=kubenet"},
+		},
+		{
+			name:     "cilium",
+			d:        &deployer{CNI: "cilium", NumNodes: 3},
+			expected: []string{"ALLOWED_NOTREADY_NODES=3", "NETWORK_PROVIDER=cni"},
+		},
+		{
+			name: "instance template",
+			d: &deployer{
+				NodeSize:          "e2-standard-4",
+				NodeImage:         "ubuntu-2004-focal-v20210511",
+				NodeImageProject:  "ubuntu-os-cloud",
+				NodeStartupScript: "/tmp/startup.sh",
+			},
+			expected: []string{
+				"KUBE_GCE_NODE_IMAGE=ubuntu-2004-focal-v20210511",
+				"KUBE_GCE_NODE_PROJECT=ubuntu-os-cloud",
+				"KUBE_NODE_EXTRA_METADATA=startup-script=/tmp/startup.sh",
+				"NODE_SIZE=e2-standard-4",
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			actual := tc.d.instanceEnv()
+			sort.Strings(actual)
+			if !reflect.DeepEqual(actual, tc.expected) {
+				t.Errorf("expected %v, but got %v", tc.expected, actual)
+			}
+		})
+	}
+}
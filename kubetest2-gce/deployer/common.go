@@ -25,7 +25,8 @@ import (
 
 	"k8s.io/klog"
 
-	"sigs.k8s.io/kubetest2/pkg/boskos"
+	"sigs.k8s.io/kubetest2/pkg/firewall"
+	"sigs.k8s.io/kubetest2/pkg/projects"
 )
 
 const (
@@ -40,6 +41,8 @@ func (d *deployer) init() error {
 
 // initialize should only be called by init(), behind a sync.Once
 func (d *deployer) initialize() error {
+	d.firewalls = firewall.NewManager(d.commonOptions.RunDir(), d.SkipFirewallRules)
+
 	if d.commonOptions.ShouldBuild() {
 		if err := d.verifyBuildFlags(); err != nil {
 			return fmt.Errorf("init failed to check build flags: %s", err)
@@ -51,33 +54,26 @@ func (d *deployer) initialize() error {
 			return fmt.Errorf("init failed to verify flags for up: %s", err)
 		}
 
-		if d.GCPProject == "" {
-			klog.V(1).Info("No GCP project provided, acquiring from Boskos")
-
-			boskosClient, err := boskos.NewClient(d.BoskosLocation)
-			if err != nil {
-				return fmt.Errorf("failed to make boskos client: %s", err)
-			}
-			d.boskos = boskosClient
-
-			resource, err := boskos.Acquire(
-				d.boskos,
-				gceProjectResourceType,
-				time.Duration(d.BoskosAcquireTimeoutSeconds)*time.Second,
-				time.Duration(d.BoskosHeartbeatIntervalSeconds)*time.Second,
-				d.boskosHeartbeatClose,
-			)
-
-			if err != nil {
-				return fmt.Errorf("init failed to get project from boskos: %s", err)
-			}
-			d.GCPProject = resource.Name
-			klog.V(1).Infof("Got project %s from boskos", d.GCPProject)
+		if err := d.acquireProject(); err != nil {
+			return fmt.Errorf("init failed to acquire the project: %s", err)
 		}
-
 	}
 
 	if d.commonOptions.ShouldDown() {
+		// e.g. the final stage of a pipeline tearing down the cluster of the
+		// earlier ones, in the project of their lease
+		if d.projectLease == nil && d.BoskosLeaseFile != "" {
+			if _, err := os.Stat(d.BoskosLeaseFile); err == nil {
+				// not a new project, which would not have the cluster
+				lease, err := projects.ResumeLeaseFile(d.projectConfig())
+				if err == nil {
+					err = d.useLease(lease)
+				}
+				if err != nil {
+					return fmt.Errorf("init failed to reuse the project of the boskos lease file: %s", err)
+				}
+			}
+		}
 		if err := d.verifyDownFlags(); err != nil {
 			return fmt.Errorf("init failed to verify flags for down: %s", err)
 		}
@@ -86,6 +82,39 @@ func (d *deployer) initialize() error {
 	return nil
 }
 
+// acquireProject acquires the project of the cluster from the --project-source
+func (d *deployer) acquireProject() error {
+	lease, err := projects.AcquireProject(d.projectConfig(), d.GCPProject)
+	if err != nil {
+		return err
+	}
+	return d.useLease(lease)
+}
+
+// projectConfig returns the config of the project of the cluster
+func (d *deployer) projectConfig() *projects.Config {
+	return &projects.Config{
+		Source:                  d.ProjectSource,
+		BoskosLocation:          d.BoskosLocation,
+		BoskosRequests:          []projects.BoskosRequest{{ResourceType: gceProjectResourceType, Count: 1}},
+		BoskosAcquireTimeout:    time.Duration(d.BoskosAcquireTimeoutSeconds) * time.Second,
+		BoskosHeartbeatInterval: time.Duration(d.BoskosHeartbeatIntervalSeconds) * time.Second,
+		LeaseFile:               d.BoskosLeaseFile,
+		LeaseFinal:              d.BoskosLeaseFinal,
+	}
+}
+
+// useLease uses the project of the lease for the cluster
+func (d *deployer) useLease(lease *projects.Lease) error {
+	project, err := lease.Project()
+	if err != nil {
+		return err
+	}
+	d.projectLease = lease
+	d.GCPProject = project
+	return nil
+}
+
 func (d *deployer) buildEnv() []string {
 	// The base env currently does not inherit the current os env (except for PATH)
 	// because (for now) it doesn't have to. In future, this may have to change when
@@ -150,8 +179,12 @@ func (d *deployer) buildEnv() []string {
 		env = append(env, "ENABLE_CACHE_MUTATION_DETECTOR=true")
 	}
 
-	if d.RuntimeConfig != "" {
-		env = append(env, fmt.Sprintf("KUBE_RUNTIME_CONFIG=%s", d.RuntimeConfig))
+	if d.featureGates != "" {
+		env = append(env, fmt.Sprintf("KUBE_FEATURE_GATES=%s", d.featureGates))
+	}
+
+	if d.runtimeConfig != "" {
+		env = append(env, fmt.Sprintf("KUBE_RUNTIME_CONFIG=%s", d.runtimeConfig))
 	}
 
 	if d.EnablePodSecurityPolicy {
@@ -184,6 +217,8 @@ func (d *deployer) buildEnv() []string {
 		env = append(env, fmt.Sprintf("KUBE_GCE_NODE_SERVICE_ACCOUNT=%s", d.NodeServiceAccount))
 	}
 
+	env = append(env, d.instanceEnv()...)
+
 	return env
 }
 
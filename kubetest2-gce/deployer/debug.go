@@ -0,0 +1,38 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package deployer
+
+import (
+	"fmt"
+
+	"sigs.k8s.io/kubetest2/pkg/types"
+)
+
+// assert that deployer implements types.DeployerWithDebugHints
+var _ types.DeployerWithDebugHints = &deployer{}
+
+// DebugHints returns the gcloud commands to find and ssh into the instances of the cluster
+func (d *deployer) DebugHints() []string {
+	zone := ""
+	if d.GCPZone != "" {
+		zone = " --zone=" + d.GCPZone
+	}
+	return []string{
+		fmt.Sprintf("gcloud compute instances list --project=%s --filter='name~^%s-'", d.GCPProject, d.instancePrefix),
+		fmt.Sprintf("gcloud compute ssh --project=%s%s %s-master", d.GCPProject, zone, d.instancePrefix),
+	}
+}
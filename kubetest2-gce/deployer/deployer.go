@@ -29,10 +29,11 @@ import (
 
 	"k8s.io/klog"
 
-	"sigs.k8s.io/boskos/client"
-
 	"sigs.k8s.io/kubetest2/kubetest2-gce/deployer/options"
 	"sigs.k8s.io/kubetest2/pkg/build"
+	"sigs.k8s.io/kubetest2/pkg/featuregates"
+	"sigs.k8s.io/kubetest2/pkg/firewall"
+	"sigs.k8s.io/kubetest2/pkg/projects"
 	"sigs.k8s.io/kubetest2/pkg/types"
 )
 
@@ -53,37 +54,54 @@ type deployer struct {
 	kubectlPath    string
 	logsDir        string
 
-	// boskos struct field will be non-nil when the deployer is
-	// using boskos to acquire a GCP project
-	boskos *client.Client
-
-	// this channel serves as a signal channel for the hearbeat goroutine
-	// so that it can be explicitly closed
-	boskosHeartbeatClose chan struct{}
+	// projectLease holds the project acquired from the --project-source
+	projectLease *projects.Lease
 
 	// instancePrefix is set for a mandatory env and for firewall rule creation
 	// see buildEnv() and nodeTag()
 	instancePrefix string
 	// network is set for firewall rule creation, see buildEnv() and firewall.go
 	network string
+	// firewalls records the firewall rules explicitly created by the deployer,
+	// set by init() as it depends on --skip-firewall-rules
+	firewalls *firewall.Manager
+	// featureGates and runtimeConfig are the formatted --kube-feature-gates
+	// and --runtime-config of kubetest2, see SetFeatureGates
+	featureGates  string
+	runtimeConfig string
 
 	BoskosAcquireTimeoutSeconds    int    `desc:"How long (in seconds) to hang on a request to Boskos to acquire a resource before erroring."`
 	BoskosHeartbeatIntervalSeconds int    `desc:"How often (in seconds) to send a heartbeat to Boskos to hold the acquired resource. 0 means no heartbeat."`
 	RepoRoot                       string `desc:"The path to the root of the local kubernetes/cloud-provider-gcp repo. Necessary to call certain scripts. Defaults to the current directory. If operating in legacy mode, this should be set to the local kubernetes/kubernetes repo."`
 	GCPProject                     string `desc:"GCP Project to create VMs in. If unset, the deployer will attempt to get a project from boskos."`
+	ProjectSource                  string `desc:"Where to get the GCP project from: static for --gcp-project, boskos to lease it from boskos, or env for the project of $KUBETEST2_PROJECTS. Defaults to static if --gcp-project is set and boskos otherwise."`
 	GCPZone                        string `desc:"GCP Zone to create VMs in. If unset, kube-up.sh and kube-down.sh defaults apply."`
 	EnableComputeAPI               bool   `desc:"If set, the deployer will enable the compute API for the project during the Up phase. This is necessary if the project has not been used before. WARNING: The currently configured GCP account must have permission to enable this API on the configured project."`
 	OverwriteLogsDir               bool   `desc:"If set, will overwrite an existing logs directory if one is encountered during dumping of logs. Useful when runnning tests locally."`
-	BoskosLocation                 string `desc:"If set, manually specifies the location of the boskos server. If unset and boskos is needed, defaults to http://boskos.test-pods.svc.cluster.local."`
+	BoskosLeaseFile                string `desc:"File persisting the project leased from boskos, for the sequential invocations of a job in the same pod e.g. the stages of a pipeline to reuse it instead of leasing a new one. The project is kept leased until the --boskos-lease-final invocation."`
+	BoskosLeaseFinal               bool   `desc:"Only used with --boskos-lease-file. Whether this is the final invocation sharing the lease file, which releases the project to boskos at down and removes the file."`
+	BoskosLocation                 string `desc:"If set, manually specifies the location of the boskos server. If unset and boskos is needed, defaults to http://boskos.test-pods.svc.cluster.local. Can reference a secret holding it e.g. env:NAME, file:PATH or gcp-secret:projects/PROJECT/secrets/SECRET."`
 	LegacyMode                     bool   `desc:"Set if the provided repo root is the kubernetes/kubernetes repo and not kubernetes/cloud-provider-gcp."`
 	NumNodes                       int    `desc:"The number of nodes in the cluster."`
+	SkipQuotaCheck                 bool   `desc:"If set, the deployer will not verify that the project has enough compute quota for the cluster before running kube-up."`
+	SkipFirewallRules              bool   `desc:"If set, the deployer will not create or delete the nodeports firewall rule, e.g. for VPC-SC restricted projects where firewall rules are managed externally. The firewall rules of kube-up.sh are not affected."`
 
 	EnableCacheMutationDetector bool   `desc:"Sets the environment variable ENABLE_CACHE_MUTATION_DETECTOR=true during deployment. This should cause a panic if anything mutates a shared informer cache."`
-	RuntimeConfig               string `desc:"Sets the KUBE_RUNTIME_CONFIG environment variable during deployment."`
 	EnablePodSecurityPolicy     bool   `desc:"Sets the environment variable ENABLE_POD_SECURITY_POLICY=true during deployment."`
 	CreateCustomNetwork         bool   `desc:"Sets the environment variable CREATE_CUSTOM_NETWORK=true during deployment."`
 	NodeScopes                  string `desc:"Sets the NODE_SCOPES environment variable during deployment."`
 	NodeServiceAccount          string `desc:"Sets the KUBE_GCE_NODE_SERVICE_ACCOUNT environment variable during deployment."`
+
+	CNI                string   `desc:"The network plugin of the cluster, one of kubenet, calico (kubenet with the calico network policy provider) or cilium (installed from --cni-manifest after kube-up). Defaults to the kube-up default."`
+	CNIManifest        string   `desc:"The path or URL of the manifest installing the network plugin of --cni after kube-up, required for --cni=cilium."`
+	InstanceTags       []string `desc:"Extra network tags added to the instances of the cluster after kube-up, e.g. for firewall rules needed by the network plugin."`
+	NodeStartupScript  string   `desc:"The path of a script added as the startup-script metadata of the node instance template, run at boot in addition to the kube-up node configuration."`
+	MasterSize         string   `desc:"Sets the MASTER_SIZE environment variable (the machine type of the control plane) during deployment."`
+	NodeSize           string   `desc:"Sets the NODE_SIZE environment variable (the machine type of the nodes) during deployment."`
+	NodeDiskSize       string   `desc:"Sets the NODE_DISK_SIZE environment variable during deployment, e.g. 100GB."`
+	NodeImage          string   `desc:"Sets the KUBE_GCE_NODE_IMAGE environment variable during deployment."`
+	NodeImageProject   string   `desc:"Sets the KUBE_GCE_NODE_PROJECT environment variable (the project of --node-image) during deployment."`
+	NodeOSDistribution string   `desc:"Sets the KUBE_NODE_OS_DISTRIBUTION environment variable during deployment, e.g. gci or ubuntu."`
 }
 
 // pseudoUniqueSubstring returns a substring of a UUID
@@ -118,9 +136,8 @@ func New(opts types.Options) (types.Deployer, *pflag.FlagSet) {
 				Strategy: "make",
 			},
 		},
-		kubeconfigPath:       filepath.Join(opts.RunDir(), "kubetest2-kubeconfig"),
-		logsDir:              filepath.Join(opts.RunDir(), "cluster-logs"),
-		boskosHeartbeatClose: make(chan struct{}),
+		kubeconfigPath: filepath.Join(opts.RunDir(), "kubetest2-kubeconfig"),
+		logsDir:        filepath.Join(opts.RunDir(), "cluster-logs"),
 		// names need to start with an alphabet
 		instancePrefix:                 "kt2-" + pseudoUniqueSubstring(opts.RunID()),
 		network:                        "kt2-" + pseudoUniqueSubstring(opts.RunID()),
@@ -158,6 +175,10 @@ func (d *deployer) Version() string {
 	return GitTag
 }
 
+func (d *deployer) Description() string {
+	return "creates clusters on GCE VMs with the kubernetes cluster scripts"
+}
+
 func (d *deployer) Kubeconfig() (string, error) {
 	_, err := os.Stat(d.kubeconfigPath)
 	if os.IsNotExist(err) {
@@ -169,3 +190,14 @@ func (d *deployer) Kubeconfig() (string, error) {
 
 	return d.kubeconfigPath, nil
 }
+
+// assert that deployer implements types.DeployerWithFeatureGates
+var _ types.DeployerWithFeatureGates = &deployer{}
+
+// SetFeatureGates sets KUBE_FEATURE_GATES and KUBE_RUNTIME_CONFIG during
+// deployment, which the cluster scripts pass to all the components
+func (d *deployer) SetFeatureGates(featureGates map[string]bool, runtimeConfig map[string]string) error {
+	d.featureGates = featuregates.FormatFeatureGates(featureGates)
+	d.runtimeConfig = featuregates.FormatRuntimeConfig(runtimeConfig)
+	return nil
+}
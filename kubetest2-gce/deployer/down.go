@@ -21,7 +21,6 @@ import (
 	"path/filepath"
 
 	"k8s.io/klog"
-	"sigs.k8s.io/kubetest2/pkg/boskos"
 	"sigs.k8s.io/kubetest2/pkg/exec"
 )
 
@@ -52,17 +51,11 @@ func (d *deployer) Down() error {
 
 	klog.V(2).Info("about to delete nodeport firewall rule")
 	// best-effort try to delete the explicitly created firewall rules
-	// ideally these should already be deleted by kube-down
-	d.deleteFirewallRuleNodePort()
+	d.deleteFirewallRules()
 
-	if d.boskos != nil {
+	if d.projectLease.Leased() {
 		klog.V(2).Info("releasing boskos project")
-		err := boskos.Release(
-			d.boskos,
-			[]string{d.GCPProject},
-			d.boskosHeartbeatClose,
-		)
-		if err != nil {
+		if err := d.projectLease.Release(); err != nil {
 			return fmt.Errorf("down failed to release boskos project: %s", err)
 		}
 	}
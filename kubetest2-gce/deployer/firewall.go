@@ -20,7 +20,8 @@ import (
 	"fmt"
 
 	"k8s.io/klog"
-	"sigs.k8s.io/kubetest2/pkg/exec"
+
+	"sigs.k8s.io/kubetest2/pkg/firewall"
 )
 
 // kube-up.sh builds NODE_TAG based on KUBE_GCE_INSTANCE_PREFIX which the deployer
@@ -34,31 +35,26 @@ func (d *deployer) nodePortRuleName() string {
 	return fmt.Sprintf("%s-nodeports", d.nodeTag())
 }
 
+// createFirewallRuleNodePort creates the nodeports rule unless it exists, e.g.
+// from an earlier --up of the run
 func (d *deployer) createFirewallRuleNodePort() error {
-	cmd := exec.Command(
-		"gcloud", "compute", "firewall-rules", "create",
-		"--project", d.GCPProject,
-		"--target-tags", d.nodeTag(),
-		"--allow", "tcp:30000-32767,udp:30000-32767",
-		"--network", d.network,
-		d.nodePortRuleName(),
-	)
-	exec.InheritOutput(cmd)
-	if err := cmd.Run(); err != nil {
+	if err := d.firewalls.Ensure(firewall.Rule{
+		Name:       d.nodePortRuleName(),
+		Project:    d.GCPProject,
+		Network:    d.network,
+		Allow:      "tcp:30000-32767,udp:30000-32767",
+		TargetTags: []string{d.nodeTag()},
+	}); err != nil {
 		return fmt.Errorf("failed to create nodeports firewall rule: %s", err)
 	}
 
 	return nil
 }
 
-func (d *deployer) deleteFirewallRuleNodePort() {
-	cmd := exec.Command(
-		"gcloud", "compute", "firewall-rules", "delete",
-		"--project", d.GCPProject,
-		d.nodePortRuleName(),
-	)
-	exec.InheritOutput(cmd)
-	if err := cmd.Run(); err != nil {
-		klog.Warning("failed to delete nodeports firewall rules: might be deleted already?")
+// deleteFirewallRules best-effort deletes the firewall rules created for the run
+// ideally these should already be deleted by kube-down
+func (d *deployer) deleteFirewallRules() {
+	if err := d.firewalls.Cleanup(); err != nil {
+		klog.Warningf("failed to delete firewall rules: %s", err)
 	}
 }
@@ -0,0 +1,100 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package deployer
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"k8s.io/klog"
+
+	"sigs.k8s.io/kubetest2/pkg/quota"
+)
+
+// the kube-up.sh default for NODE_SIZE
+const defaultNodeSize = "n1-standard-2"
+
+// checkQuota verifies that the project has enough regional compute quota
+// for the master and nodes that kube-up.sh is going to create
+func (d *deployer) checkQuota() error {
+	if d.SkipQuotaCheck {
+		return nil
+	}
+	if d.GCPZone == "" {
+		klog.V(2).Info("no zone set, skipping quota check")
+		return nil
+	}
+	region, err := regionFromZone(d.GCPZone)
+	if err != nil {
+		return err
+	}
+
+	required := quota.Requirements{}
+	nodeSize := d.NodeSize
+	if nodeSize == "" {
+		nodeSize = os.Getenv("NODE_SIZE")
+	}
+	if nodeSize == "" {
+		nodeSize = defaultNodeSize
+	}
+	if err := required.AddNodes(nodeSize, d.NumNodes, true); err != nil {
+		klog.Warningf("Skipping quota check: %v", err)
+		return nil
+	}
+	master := d.MasterSize
+	if master == "" {
+		master = masterSize(d.NumNodes)
+	}
+	if err := required.AddNodes(master, 1, true); err != nil {
+		klog.Warningf("Skipping quota check: %v", err)
+		return nil
+	}
+
+	klog.V(1).Infof("Checking quota in project %s region %s for %v", d.GCPProject, region, required)
+	return quota.Check(d.GCPProject, region, required)
+}
+
+// masterSize mirrors get-master-size in cluster/gce/config-common.sh
+func masterSize(numNodes int) string {
+	if size := os.Getenv("MASTER_SIZE"); size != "" {
+		return size
+	}
+	cores := 1
+	switch {
+	case numNodes > 500:
+		cores = 32
+	case numNodes > 250:
+		cores = 16
+	case numNodes > 100:
+		cores = 8
+	case numNodes > 10:
+		cores = 4
+	case numNodes > 5:
+		cores = 2
+	}
+	return fmt.Sprintf("n1-standard-%d", cores)
+}
+
+// regionFromZone returns the region of a zone e.g. us-central1 for us-central1-b
+func regionFromZone(zone string) (string, error) {
+	i := strings.LastIndex(zone, "-")
+	if i <= 0 {
+		return "", fmt.Errorf("invalid zone %q", zone)
+	}
+	return zone[:i], nil
+}
@@ -83,6 +83,10 @@ func (d *deployer) Up() error {
 		}
 	}
 
+	if err := d.checkQuota(); err != nil {
+		return fmt.Errorf("quota preflight check failed: %s", err)
+	}
+
 	defer func() {
 		if err := d.DumpClusterLogs(); err != nil {
 			klog.Warningf("Dumping cluster logs at the end of Up() failed: %s", err)
@@ -103,6 +107,14 @@ func (d *deployer) Up() error {
 		return fmt.Errorf("error encountered during %s: %s", script, err)
 	}
 
+	if err := d.installCNI(); err != nil {
+		return err
+	}
+
+	if err := d.tagInstances(); err != nil {
+		return err
+	}
+
 	if isUp, err := d.IsUp(); err != nil {
 		klog.Warningf("failed to check if cluster is up: %s", err)
 	} else if isUp {
@@ -152,6 +164,10 @@ func (d *deployer) verifyUpFlags() error {
 		return err
 	}
 
+	if err := d.verifyCNIFlags(); err != nil {
+		return err
+	}
+
 	// verifyUpFlags does not check for a gcp project because it is
 	// assumed that one will be acquired from boskos if it is not set
 
@@ -0,0 +1,109 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package deployer
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"syscall"
+	"time"
+
+	"k8s.io/klog"
+
+	"sigs.k8s.io/kubetest2/pkg/metadata"
+)
+
+const (
+	// GKE deletes the alpha clusters 30 days after their creation
+	alphaClusterLifetimeHours = 30 * 24
+	// defaultAlphaClusterMaxHours leaves a day to tear the clusters down
+	// and collect the artifacts before GKE deletes them
+	defaultAlphaClusterMaxHours = 29 * 24
+)
+
+// optionsWithTestDuration is implemented by the kubetest2 options of soak runs, see --test-duration
+type optionsWithTestDuration interface {
+	TestDuration() time.Duration
+}
+
+// verifyAlphaFlags validates the flags of the alpha clusters and disables the
+// auto-upgrade and auto-repair of their node pools, as GKE requires
+func (d *Deployer) verifyAlphaFlags() error {
+	if !d.EnableKubernetesAlpha {
+		return nil
+	}
+	if d.Autopilot {
+		return fmt.Errorf("--enable-kubernetes-alpha is not supported with --autopilot")
+	}
+	if d.ReleaseChannel != "" {
+		return fmt.Errorf("--enable-kubernetes-alpha cannot be used with --release-channel, alpha clusters are not enrolled in a release channel")
+	}
+	if d.AlphaClusterMaxHours <= 0 || d.AlphaClusterMaxHours >= alphaClusterLifetimeHours {
+		return fmt.Errorf("--alpha-cluster-max-hours must be between 1 and %d, below the lifetime of the alpha clusters, got %d",
+			alphaClusterLifetimeHours-1, d.AlphaClusterMaxHours)
+	}
+	maxDuration := time.Duration(d.AlphaClusterMaxHours) * time.Hour
+	if oWithTestDuration, ok := d.Kubetest2CommonOptions.(optionsWithTestDuration); ok && oWithTestDuration.TestDuration() > maxDuration {
+		return fmt.Errorf("--test-duration %s exceeds the --alpha-cluster-max-hours of %d of the alpha clusters",
+			oWithTestDuration.TestDuration(), d.AlphaClusterMaxHours)
+	}
+	if !d.DisableAutoUpgrade || !d.DisableAutoRepair {
+		klog.V(1).Info("Disabling the auto-upgrade and auto-repair of the node pools of the alpha clusters")
+		d.DisableAutoUpgrade = true
+		d.DisableAutoRepair = true
+	}
+	return nil
+}
+
+// alphaArgs returns the flags creating alpha clusters
+func (d *Deployer) alphaArgs() []string {
+	if !d.EnableKubernetesAlpha {
+		return nil
+	}
+	return []string{"--enable-kubernetes-alpha"}
+}
+
+// startAlphaClusterWatchdog records the alpha status of the clusters in the
+// metadata, and caps the run at --alpha-cluster-max-hours from their creation
+// by terminating it, which tears the clusters down and fails the run like an
+// interrupt would, before GKE deletes them with the artifacts they hold
+func (d *Deployer) startAlphaClusterWatchdog(created time.Time) {
+	if !d.EnableKubernetesAlpha {
+		return
+	}
+	if err := metadata.Default().SetAll(map[string]string{
+		"gke-alpha-clusters":          strconv.FormatBool(true),
+		"gke-alpha-clusters-deadline": created.Add(time.Duration(d.AlphaClusterMaxHours) * time.Hour).UTC().Format(time.RFC3339),
+		"gke-alpha-clusters-expiry":   created.Add(alphaClusterLifetimeHours * time.Hour).UTC().Format(time.RFC3339),
+	}); err != nil {
+		klog.Warningf("Failed to record the alpha clusters in the metadata: %v", err)
+	}
+	d.alphaWatchdog = time.AfterFunc(time.Until(created.Add(time.Duration(d.AlphaClusterMaxHours)*time.Hour)), func() {
+		klog.Errorf("Terminating the run, which reached the --alpha-cluster-max-hours of %d of the alpha clusters", d.AlphaClusterMaxHours)
+		if err := syscall.Kill(os.Getpid(), syscall.SIGTERM); err != nil {
+			klog.Errorf("Failed to terminate the run: %v", err)
+		}
+	})
+}
+
+// stopAlphaClusterWatchdog stops the watchdog once the clusters are torn down
+func (d *Deployer) stopAlphaClusterWatchdog() {
+	if d.alphaWatchdog != nil {
+		d.alphaWatchdog.Stop()
+	}
+}
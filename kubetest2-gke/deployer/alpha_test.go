@@ -0,0 +1,73 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package deployer
+
+import (
+	"testing"
+
+	"sigs.k8s.io/kubetest2/kubetest2-gke/deployer/options"
+)
+
+func TestVerifyAlphaFlags(t *testing.T) {
+	testCases := []struct {
+		name           string
+		clusterOptions options.ClusterOptions
+		expectError    bool
+	}{
+		{
+			name: "not alpha",
+		},
+		{
+			name:           "alpha",
+			clusterOptions: options.ClusterOptions{EnableKubernetesAlpha: true, AlphaClusterMaxHours: defaultAlphaClusterMaxHours},
+		},
+		{
+			name:           "alpha with release channel",
+			clusterOptions: options.ClusterOptions{EnableKubernetesAlpha: true, AlphaClusterMaxHours: defaultAlphaClusterMaxHours, ReleaseChannel: "rapid"},
+			expectError:    true,
+		},
+		{
+			name:           "alpha autopilot",
+			clusterOptions: options.ClusterOptions{EnableKubernetesAlpha: true, AlphaClusterMaxHours: defaultAlphaClusterMaxHours, Autopilot: true},
+			expectError:    true,
+		},
+		{
+			name:           "max hours beyond the alpha lifetime",
+			clusterOptions: options.ClusterOptions{EnableKubernetesAlpha: true, AlphaClusterMaxHours: 720},
+			expectError:    true,
+		},
+		{
+			name:           "no max hours",
+			clusterOptions: options.ClusterOptions{EnableKubernetesAlpha: true},
+			expectError:    true,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			d := &Deployer{ClusterOptions: &tc.clusterOptions}
+			err := d.verifyAlphaFlags()
+			if (err != nil) != tc.expectError {
+				t.Fatalf("expected error: %v, but got: %v", tc.expectError, err)
+			}
+			if err == nil && tc.clusterOptions.EnableKubernetesAlpha && (!d.DisableAutoUpgrade || !d.DisableAutoRepair) {
+				t.Errorf("expected the auto-upgrade and auto-repair to be disabled for alpha clusters")
+			}
+		})
+	}
+}
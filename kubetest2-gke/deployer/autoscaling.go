@@ -0,0 +1,160 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package deployer
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"k8s.io/klog"
+
+	"sigs.k8s.io/kubetest2/pkg/exec"
+)
+
+// nodePoolAutoscaling is the --enable-autoscaling config of a node pool
+type nodePoolAutoscaling struct {
+	pool     string
+	minNodes int
+	maxNodes int
+}
+
+// parseAutoscaling parses the --enable-autoscaling KEY=VALUE pairs by node pool.
+// The comma separated pairs of the flag values are regrouped at each pool key,
+// the pairs before the first one configure the default node pool.
+func parseAutoscaling(values []string) (map[string]nodePoolAutoscaling, error) {
+	var configs []nodePoolAutoscaling
+	current := -1
+	seen := map[string]bool{}
+	for _, value := range values {
+		for _, pair := range strings.Split(value, ",") {
+			parts := strings.SplitN(pair, "=", 2)
+			if len(parts) != 2 {
+				return nil, fmt.Errorf("invalid --enable-autoscaling pair %q, must be KEY=VALUE", pair)
+			}
+			key, val := parts[0], parts[1]
+			if key == "pool" || current == -1 {
+				configs = append(configs, nodePoolAutoscaling{pool: defaultNodePoolName, minNodes: -1, maxNodes: -1})
+				current = len(configs) - 1
+			}
+			c := &configs[current]
+			switch key {
+			case "pool":
+				c.pool = val
+			case "min", "max":
+				n, err := strconv.Atoi(val)
+				if err != nil {
+					return nil, fmt.Errorf("invalid --enable-autoscaling %s %q: %v", key, val, err)
+				}
+				if key == "min" {
+					c.minNodes = n
+				} else {
+					c.maxNodes = n
+				}
+			default:
+				return nil, fmt.Errorf("unknown --enable-autoscaling key %q, must be one of pool, min or max", key)
+			}
+		}
+	}
+
+	byPool := map[string]nodePoolAutoscaling{}
+	for _, c := range configs {
+		switch c.pool {
+		case defaultNodePoolName, windowsNodePoolName, acceleratorNodePoolName, sandboxNodePoolName:
+		default:
+			return nil, fmt.Errorf("unknown --enable-autoscaling pool %q, must be one of %s, %s, %s or %s",
+				c.pool, defaultNodePoolName, windowsNodePoolName, acceleratorNodePoolName, sandboxNodePoolName)
+		}
+		if seen[c.pool] {
+			return nil, fmt.Errorf("--enable-autoscaling is set more than once for node pool %s", c.pool)
+		}
+		seen[c.pool] = true
+		if c.minNodes < 0 || c.maxNodes < 1 || c.maxNodes < c.minNodes {
+			return nil, fmt.Errorf("--enable-autoscaling for node pool %s requires 0 <= min <= max and max >= 1", c.pool)
+		}
+		byPool[c.pool] = c
+	}
+	return byPool, nil
+}
+
+func (d *Deployer) verifyAutoscalingFlags() error {
+	if len(d.Autoscaling) == 0 {
+		return nil
+	}
+	if d.Autopilot {
+		return fmt.Errorf("--enable-autoscaling is not supported with --autopilot, which scales the nodes itself")
+	}
+	autoscaling, err := parseAutoscaling(d.Autoscaling)
+	if err != nil {
+		return err
+	}
+	if _, ok := autoscaling[windowsNodePoolName]; ok && !d.WindowsEnabled {
+		return fmt.Errorf("--enable-autoscaling for node pool %s requires --enable-windows", windowsNodePoolName)
+	}
+	if _, ok := autoscaling[acceleratorNodePoolName]; ok && d.Accelerator == "" {
+		return fmt.Errorf("--enable-autoscaling for node pool %s requires --accelerator", acceleratorNodePoolName)
+	}
+	if _, ok := autoscaling[sandboxNodePoolName]; ok && !d.SandboxEnabled {
+		return fmt.Errorf("--enable-autoscaling for node pool %s requires --enable-gke-sandbox", sandboxNodePoolName)
+	}
+	d.autoscaling = autoscaling
+	return nil
+}
+
+// autoscalingArgs returns the gcloud flags enabling the autoscaling of the node pool.
+// The minimum and maximum are per zone for regional clusters.
+func (d *Deployer) autoscalingArgs(pool string) []string {
+	c, ok := d.autoscaling[pool]
+	if !ok {
+		return nil
+	}
+	return []string{
+		"--enable-autoscaling",
+		"--min-nodes=" + strconv.Itoa(c.minNodes),
+		"--max-nodes=" + strconv.Itoa(c.maxNodes),
+	}
+}
+
+// dumpAutoscalerStatus writes the cluster autoscaler status and events of the
+// clusters to the run dir, for the cluster autoscaler e2e tests expecting
+// the node counts to change
+func (d *Deployer) dumpAutoscalerStatus() {
+	if len(d.autoscaling) == 0 {
+		return
+	}
+	for _, project := range d.Projects {
+		for _, cluster := range d.projectClustersLayout[project] {
+			kubeconfig := "--kubeconfig=" + d.clusterKubeconfig(project, cluster)
+			for name, args := range map[string][]string{
+				"status": {kubeconfig, "get", "configmap", "cluster-autoscaler-status", "--namespace=kube-system", "--output=yaml"},
+				"events": {kubeconfig, "get", "events", "--all-namespaces", "--field-selector=source=cluster-autoscaler", "--sort-by=.lastTimestamp"},
+				"nodes":  {kubeconfig, "get", "nodes", "--label-columns=cloud.google.com/gke-nodepool", "--output=wide"},
+			} {
+				path := filepath.Join(d.Kubetest2CommonOptions.RunDir(), fmt.Sprintf("autoscaler-%s-%s-%s.txt", project, cluster.name, name))
+				lines, err := exec.CombinedOutputLines(exec.Command("kubectl", args...))
+				if err != nil {
+					klog.Warningf("Failed to get the cluster autoscaler %s of cluster %s: %v", name, cluster.name, err)
+				}
+				if err := ioutil.WriteFile(path, []byte(strings.Join(lines, "\n")+"\n"), 0644); err != nil {
+					klog.Warningf("Failed to write the cluster autoscaler %s: %v", name, err)
+				}
+			}
+		}
+	}
+}
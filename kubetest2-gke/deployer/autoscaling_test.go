@@ -0,0 +1,75 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package deployer
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseAutoscaling(t *testing.T) {
+	testCases := []struct {
+		name        string
+		values      []string
+		expected    map[string]nodePoolAutoscaling
+		expectError bool
+	}{
+		{
+			name:   "default pool",
+			values: []string{"min=1", "max=10"},
+			expected: map[string]nodePoolAutoscaling{
+				"default-pool": {pool: "default-pool", minNodes: 1, maxNodes: 10},
+			},
+		},
+		{
+			name:   "several pools",
+			values: []string{"min=1,max=10", "pool=accelerator-pool,min=0,max=2"},
+			expected: map[string]nodePoolAutoscaling{
+				"default-pool":     {pool: "default-pool", minNodes: 1, maxNodes: 10},
+				"accelerator-pool": {pool: "accelerator-pool", minNodes: 0, maxNodes: 2},
+			},
+		},
+		{
+			name:   "split by the flag parsing",
+			values: []string{"pool=windows-pool", "min=0", "max=3", "pool=default-pool", "min=3", "max=5"},
+			expected: map[string]nodePoolAutoscaling{
+				"windows-pool": {pool: "windows-pool", minNodes: 0, maxNodes: 3},
+				"default-pool": {pool: "default-pool", minNodes: 3, maxNodes: 5},
+			},
+		},
+		{name: "missing max", values: []string{"min=1"}, expectError: true},
+		{name: "min above max", values: []string{"min=5,max=2"}, expectError: true},
+		{name: "unknown pool", values: []string{"pool=gpu-pool,min=0,max=1"}, expectError: true},
+		{name: "unknown key", values: []string{"min=0,max=1,location-policy=ANY"}, expectError: true},
+		{name: "duplicate pool", values: []string{"min=0,max=1,pool=default-pool,min=1,max=2"}, expectError: true},
+		{name: "not a number", values: []string{"min=one,max=2"}, expectError: true},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			actual, err := parseAutoscaling(tc.values)
+			if (err != nil) != tc.expectError {
+				t.Fatalf("expected error: %v, but got: %v", tc.expectError, err)
+			}
+			if !tc.expectError && !reflect.DeepEqual(actual, tc.expected) {
+				t.Errorf("expected %+v, but got %+v", tc.expected, actual)
+			}
+		})
+	}
+}
@@ -20,11 +20,13 @@ import (
 	"fmt"
 	"os"
 	"regexp"
+	"runtime"
 	"strings"
 
 	"k8s.io/klog"
 
 	"sigs.k8s.io/kubetest2/pkg/build"
+	"sigs.k8s.io/kubetest2/pkg/trace"
 )
 
 var (
@@ -47,6 +49,15 @@ var (
 )
 
 func (d *Deployer) Build() error {
+	// the built version is deployed by up, possibly in a resumed run
+	defer d.saveState()
+	if err := d.build(); err != nil {
+		return err
+	}
+	return trace.Default().Wrap("Mirror", d.copyToMirror)
+}
+
+func (d *Deployer) build() error {
 	imageTag := defaultImageTag
 	if d.BuildOptions.CommonBuildOptions.ImageLocation != "" {
 		imageTag = d.BuildOptions.CommonBuildOptions.ImageLocation
@@ -64,6 +75,17 @@ func (d *Deployer) Build() error {
 		return err
 	}
 	klog.V(2).Infof("got build version: %s", version)
+	// ko builds the images of the components under test, not kubernetes itself,
+	// so there is no cluster version to stage or to deploy
+	if d.BuildOptions.CommonBuildOptions.Strategy == string(build.KoStrategy) {
+		return nil
+	}
+	// a cached build is deployed as the version it was previously staged as
+	if staged, ok := d.BuildOptions.CommonBuildOptions.StagedVersion(); ok {
+		d.ClusterVersion = staged
+		build.StoreCommonBinaries(d.RepoRoot, d.Kubetest2CommonOptions.RunDir())
+		return nil
+	}
 	version = strings.TrimPrefix(version, "v")
 	if version, err = normalizeVersion(version); err != nil {
 		return err
@@ -82,7 +104,7 @@ func (d *Deployer) Build() error {
 
 	// stage build if requested
 	if d.BuildOptions.CommonBuildOptions.StageLocation != "" {
-		if err := d.BuildOptions.Stage(version); err != nil {
+		if err := trace.Default().Wrap("Stage", func() error { return d.BuildOptions.Stage(version) }); err != nil {
 			return fmt.Errorf("error staging build: %v", err)
 		}
 	}
@@ -96,13 +118,37 @@ func (d *Deployer) VerifyBuildFlags() error {
 		return fmt.Errorf("required repo-root when building from source")
 	}
 	d.BuildOptions.CommonBuildOptions.RepoRoot = d.RepoRoot
-	if d.Kubetest2CommonOptions.ShouldBuild() && d.Kubetest2CommonOptions.ShouldUp() && d.BuildOptions.CommonBuildOptions.StageLocation == "" {
+	d.BuildOptions.CommonBuildOptions.RunDir = d.Kubetest2CommonOptions.RunDir()
+	isKo := d.BuildOptions.CommonBuildOptions.Strategy == string(build.KoStrategy)
+	if d.Kubetest2CommonOptions.ShouldBuild() && d.Kubetest2CommonOptions.ShouldUp() && d.BuildOptions.CommonBuildOptions.StageLocation == "" && !isKo {
 		return fmt.Errorf("creating a gke cluster from built sources requires staging them to a specific GCS bucket, use --stage=gs://<bucket>")
 	}
+	// build the binaries and images for the node architectures of all the
+	// clusters along with the host architecture, which the test binaries are run on
+	if d.Kubetest2CommonOptions.ShouldUp() && !d.Autopilot {
+		archs := d.BuildOptions.CommonBuildOptions.BuildArchs
+		buildArchs := []string{runtime.GOARCH}
+		for _, machineType := range d.machineTypes() {
+			nodeArch := machineArch(machineType)
+			if len(archs) > 0 && !build.HasArch(archs, nodeArch) {
+				return fmt.Errorf("--build-arch=%s does not include the %s architecture of machine type %s", strings.Join(archs, ","), nodeArch, machineType)
+			}
+			if !build.HasArch(buildArchs, nodeArch) {
+				klog.V(1).Infof("building for the %s nodes of machine type %s", nodeArch, machineType)
+				buildArchs = append(buildArchs, nodeArch)
+			}
+		}
+		if len(archs) == 0 && len(buildArchs) > 1 {
+			d.BuildOptions.CommonBuildOptions.BuildArchs = buildArchs
+		}
+	}
 	// force extra GCP files to be staged
 	d.BuildOptions.CommonBuildOptions.StageExtraGCPFiles = true
 	// add kubetest2 runid as the version suffix
 	d.BuildOptions.CommonBuildOptions.VersionSuffix = d.Kubetest2CommonOptions.RunID()
+	if err := d.verifyMirrorFlags(); err != nil {
+		return err
+	}
 	return d.BuildOptions.Validate()
 }
 
@@ -140,3 +186,12 @@ func normalizeVersion(version string) (string, error) {
 	}
 	return finalVersion, nil
 }
+
+// machineArch returns the CPU architecture of a GCE machine type, the Tau T2A
+// machine types are the only arm64 ones
+func machineArch(machineType string) string {
+	if strings.HasPrefix(machineType, "t2a-") {
+		return "arm64"
+	}
+	return "amd64"
+}
@@ -16,7 +16,13 @@ limitations under the License.
 
 package deployer
 
-import "testing"
+import (
+	"runtime"
+	"testing"
+
+	"sigs.k8s.io/kubetest2/kubetest2-gke/deployer/options"
+	"sigs.k8s.io/kubetest2/pkg/build"
+)
 
 func TestNormalizeVersion(t *testing.T) {
 	testCases := []struct {
@@ -140,3 +146,73 @@ func TestNormalizeVersion(t *testing.T) {
 		})
 	}
 }
+
+func TestMachineArch(t *testing.T) {
+	testCases := []struct {
+		machineType  string
+		expectedArch string
+	}{
+		{machineType: "", expectedArch: "amd64"},
+		{machineType: "e2-standard-4", expectedArch: "amd64"},
+		{machineType: "t2d-standard-4", expectedArch: "amd64"},
+		{machineType: "t2a-standard-4", expectedArch: "arm64"},
+	}
+
+	for _, tc := range testCases {
+		if arch := machineArch(tc.machineType); arch != tc.expectedArch {
+			t.Errorf("expected arch %s for machine type %q, but got %s", tc.expectedArch, tc.machineType, arch)
+		}
+	}
+}
+
+func TestVerifyBuildFlagsArchs(t *testing.T) {
+	testCases := []struct {
+		name         string
+		buildArchs   []string
+		expectedArch string
+		expectError  bool
+	}{
+		{
+			name:         "arm64 cluster spec",
+			expectedArch: "arm64",
+		},
+		{
+			name:        "build archs without the arch of a cluster spec",
+			buildArchs:  []string{"amd64"},
+			expectError: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			d := &Deployer{
+				Kubetest2CommonOptions: &fakeOptions{runDir: "run"},
+				BuildOptions: &options.BuildOptions{
+					CommonBuildOptions: &build.Options{
+						Builder:    &build.NoopBuilder{},
+						Stager:     &build.NoopStager{},
+						Strategy:   "make",
+						BuildArchs: tc.buildArchs,
+					},
+				},
+				CommonOptions: &options.CommonOptions{RepoRoot: "."},
+				ClusterOptions: &options.ClusterOptions{
+					MachineType:  "e2-standard-4",
+					ClusterSpecs: []string{"name=c1", "name=c2,machine-type=t2a-standard-4"},
+				},
+			}
+			if err := d.applyClusterSpecs(); err != nil {
+				t.Fatal(err)
+			}
+			err := d.VerifyBuildFlags()
+			if (err != nil) != tc.expectError {
+				t.Fatalf("expected error: %v, but got: %v", tc.expectError, err)
+			}
+			archs := d.BuildOptions.CommonBuildOptions.BuildArchs
+			if tc.expectedArch != "" && tc.expectedArch != runtime.GOARCH && !build.HasArch(archs, tc.expectedArch) {
+				t.Errorf("expected the build archs %v to include %s", archs, tc.expectedArch)
+			}
+		})
+	}
+}
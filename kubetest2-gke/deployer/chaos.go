@@ -0,0 +1,142 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package deployer
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+	"strings"
+	"time"
+
+	"k8s.io/klog"
+
+	"sigs.k8s.io/kubetest2/pkg/chaos"
+	"sigs.k8s.io/kubetest2/pkg/exec"
+)
+
+var (
+	_ chaos.NodeRebooter = &Deployer{}
+	_ chaos.ZoneFailer   = &Deployer{}
+)
+
+// nodeInstanceGroup returns the project and the instance group of the node,
+// as GKE names the nodes after their instance group: gke-<cluster>-<pool>-<uniq>-<suffix>
+func nodeInstanceGroup(node string, instanceGroups map[string]map[string][]*ig) (string, *ig, bool) {
+	for project, clusters := range instanceGroups {
+		for _, igs := range clusters {
+			for _, group := range igs {
+				if strings.HasPrefix(node, strings.TrimSuffix(group.name, "grp")) {
+					return project, group, true
+				}
+			}
+		}
+	}
+	return "", nil, false
+}
+
+// RebootNode resets the VM of the node
+func (d *Deployer) RebootNode(node string) error {
+	if err := d.Init(); err != nil {
+		return err
+	}
+	if err := d.GetInstanceGroups(); err != nil {
+		return err
+	}
+	project, group, ok := nodeInstanceGroup(node, d.instanceGroups)
+	if !ok {
+		return fmt.Errorf("no instance group found for node %s", node)
+	}
+	return runWithOutput(exec.Command("gcloud", "compute", "instances", "reset", node,
+		"--project="+project,
+		"--zone="+group.zone))
+}
+
+// FailZone resizes the instance groups of the clusters in a random zone to 0,
+// restore resizes them back
+func (d *Deployer) FailZone() (func() error, error) {
+	if err := d.Init(); err != nil {
+		return nil, err
+	}
+	if err := d.GetInstanceGroups(); err != nil {
+		return nil, err
+	}
+
+	groupsByZone := map[string]map[*ig]string{}
+	for project, clusters := range d.instanceGroups {
+		for _, igs := range clusters {
+			for _, group := range igs {
+				if groupsByZone[group.zone] == nil {
+					groupsByZone[group.zone] = map[*ig]string{}
+				}
+				groupsByZone[group.zone][group] = project
+			}
+		}
+	}
+	zones := make([]string, 0, len(groupsByZone))
+	for zone := range groupsByZone {
+		zones = append(zones, zone)
+	}
+	if len(zones) == 0 {
+		return nil, fmt.Errorf("no instance groups found to fail a zone")
+	}
+	sort.Strings(zones)
+	zone := zones[rand.New(rand.NewSource(time.Now().UnixNano())).Intn(len(zones))]
+
+	sizes := map[*ig]string{}
+	for group, project := range groupsByZone[zone] {
+		size, err := exec.Output(exec.Command("gcloud", "compute", "instance-groups", "managed", "describe", group.name,
+			"--project="+project,
+			"--zone="+zone,
+			"--format=value(targetSize)"))
+		if err != nil {
+			return nil, fmt.Errorf("failed to get the size of instance group %s: %s", group.name, execError(err))
+		}
+		sizes[group] = strings.TrimSpace(string(size))
+	}
+
+	resize := func(group *ig, size string) error {
+		return runWithOutput(exec.Command("gcloud", "compute", "instance-groups", "managed", "resize", group.name,
+			"--project="+groupsByZone[zone][group],
+			"--zone="+zone,
+			"--size="+size))
+	}
+	restore := func() error {
+		var errs []string
+		for group, size := range sizes {
+			if err := resize(group, size); err != nil {
+				errs = append(errs, fmt.Sprintf("instance group %s: %v", group.name, err))
+			}
+		}
+		if len(errs) > 0 {
+			return fmt.Errorf("failed to restore zone %s: %s", zone, strings.Join(errs, "; "))
+		}
+		return nil
+	}
+
+	klog.Infof("Chaos: failing zone %s", zone)
+	for group := range sizes {
+		if err := resize(group, "0"); err != nil {
+			// bring back the instance groups that were already resized
+			if restoreErr := restore(); restoreErr != nil {
+				klog.Errorf("%v", restoreErr)
+			}
+			return nil, err
+		}
+	}
+	return restore, nil
+}
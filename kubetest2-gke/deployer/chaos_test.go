@@ -0,0 +1,64 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package deployer
+
+import (
+	"testing"
+)
+
+func TestNodeInstanceGroup(t *testing.T) {
+	instanceGroups := map[string]map[string][]*ig{
+		"project1": {
+			"cluster1": {
+				{zone: "us-central1-a", name: "gke-cluster1-default-pool-90fcb815-grp"},
+				{zone: "us-central1-b", name: "gke-cluster1-default-pool-1234abcd-grp"},
+			},
+		},
+		"project2": {
+			"cluster2": {
+				{zone: "us-east1-b", name: "gke-cluster2-default-pool-5678abcd-grp"},
+			},
+		},
+	}
+	testCases := []struct {
+		node    string
+		project string
+		zone    string
+		found   bool
+	}{
+		{node: "gke-cluster1-default-pool-1234abcd-x1z2", project: "project1", zone: "us-central1-b", found: true},
+		{node: "gke-cluster2-default-pool-5678abcd-q9w8", project: "project2", zone: "us-east1-b", found: true},
+		{node: "gke-cluster3-default-pool-00000000-a1b2"},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.node, func(t *testing.T) {
+			t.Parallel()
+			project, group, found := nodeInstanceGroup(tc.node, instanceGroups)
+			if found != tc.found {
+				t.Fatalf("expected found: %v, but got: %v", tc.found, found)
+			}
+			if !found {
+				return
+			}
+			if project != tc.project || group.zone != tc.zone {
+				t.Errorf("expected %s/%s, but got %s/%s", tc.project, tc.zone, project, group.zone)
+			}
+		})
+	}
+}
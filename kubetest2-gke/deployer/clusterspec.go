@@ -0,0 +1,252 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package deployer
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// clusterSpec is the configuration of a single cluster of a multi-cluster run
+// set with --cluster-spec, overriding the flags shared by all the clusters
+type clusterSpec struct {
+	name        string
+	project     string
+	version     string
+	region      string
+	zone        string
+	machineType string
+	numNodes    int
+}
+
+// parseClusterSpecs parses the --cluster-spec values, which are lists of
+// comma separated KEY=VALUE pairs starting with the name of the cluster.
+// As the flag is itself a comma separated list, the pairs of a spec may
+// have been split into separate values, so a spec ends where the next starts.
+func parseClusterSpecs(values []string) ([]clusterSpec, error) {
+	var specs []clusterSpec
+	names := map[string]bool{}
+	for _, value := range values {
+		for _, pair := range strings.Split(value, ",") {
+			pair = strings.TrimSpace(pair)
+			if pair == "" {
+				continue
+			}
+			parts := strings.SplitN(pair, "=", 2)
+			if len(parts) != 2 || parts[1] == "" {
+				return nil, fmt.Errorf("invalid --cluster-spec %q, expected KEY=VALUE", pair)
+			}
+			key, val := parts[0], parts[1]
+			if key == "name" {
+				if names[val] {
+					return nil, fmt.Errorf("duplicate --cluster-spec for cluster %q", val)
+				}
+				names[val] = true
+				specs = append(specs, clusterSpec{name: val})
+				continue
+			}
+			if len(specs) == 0 {
+				return nil, fmt.Errorf("invalid --cluster-spec %q, each spec must start with name=", pair)
+			}
+			spec := &specs[len(specs)-1]
+			switch key {
+			case "project":
+				if _, err := strconv.Atoi(val); err != nil {
+					return nil, fmt.Errorf("invalid --cluster-spec project %q for cluster %q, expected the index of the project", val, spec.name)
+				}
+				spec.project = val
+			case "version":
+				if err := validateVersion(val); err != nil {
+					return nil, fmt.Errorf("invalid --cluster-spec version for cluster %q: %v", spec.name, err)
+				}
+				spec.version = val
+			case "region":
+				spec.region = val
+			case "zone":
+				spec.zone = val
+			case "machine-type":
+				spec.machineType = val
+			case "num-nodes":
+				n, err := strconv.Atoi(val)
+				if err != nil || n <= 0 {
+					return nil, fmt.Errorf("invalid --cluster-spec num-nodes %q for cluster %q, must be larger than 0", val, spec.name)
+				}
+				spec.numNodes = n
+			default:
+				return nil, fmt.Errorf("unknown --cluster-spec key %q, must be one of name, project, version, region, zone, machine-type or num-nodes", key)
+			}
+			if spec.region != "" && spec.zone != "" {
+				return nil, fmt.Errorf("the --cluster-spec of cluster %q cannot set both region and zone", spec.name)
+			}
+		}
+	}
+	return specs, nil
+}
+
+// applyClusterSpecs sets the clusters of the run from the --cluster-spec flags, if any
+func (d *Deployer) applyClusterSpecs() error {
+	specs, err := parseClusterSpecs(d.ClusterSpecs)
+	if err != nil {
+		return err
+	}
+	if len(specs) == 0 {
+		return nil
+	}
+	clusters := make([]string, len(specs))
+	d.clusterSpecs = make(map[string]clusterSpec, len(specs))
+	for i, spec := range specs {
+		clusters[i] = spec.name
+		if spec.project != "" {
+			clusters[i] += ":" + spec.project
+		}
+		d.clusterSpecs[spec.name] = spec
+	}
+	// the clusters may already be set from the state of a resumed run
+	if len(d.Clusters) != 0 && strings.Join(d.Clusters, ",") != strings.Join(clusters, ",") {
+		return fmt.Errorf("--cluster-spec cannot be used with --cluster-name")
+	}
+	d.Clusters = clusters
+	return nil
+}
+
+// clusterLocationFlag returns the zone/region flag of the cluster for gcloud commands
+func (d *Deployer) clusterLocationFlag(name string, retryCount int) string {
+	spec := d.clusterSpecs[name]
+	switch {
+	case spec.zone != "":
+		return "--zone=" + spec.zone
+	case spec.region != "":
+		return "--region=" + spec.region
+	default:
+		return locationFlag(d.Regions, d.Zones, retryCount)
+	}
+}
+
+// clusterLocation returns the zone or region of the cluster, empty if the
+// retry count is past the shared locations
+func (d *Deployer) clusterLocation(name string, retryCount int) string {
+	spec := d.clusterSpecs[name]
+	switch {
+	case spec.zone != "":
+		return spec.zone
+	case spec.region != "":
+		return spec.region
+	case len(d.Zones) > retryCount:
+		return d.Zones[retryCount]
+	case len(d.Regions) > retryCount:
+		return d.Regions[retryCount]
+	default:
+		return ""
+	}
+}
+
+// clusterRegion returns the region of the cluster, i.e. of its zone for zonal clusters
+func (d *Deployer) clusterRegion(name string, retryCount int) string {
+	return locationRegion(d.clusterLocation(name, retryCount))
+}
+
+// locationRegion returns the region of a zone like us-central1-a, or the
+// location itself for a region like us-central1
+func locationRegion(location string) string {
+	if strings.Count(location, "-") >= 2 {
+		return location[0:strings.LastIndex(location, "-")]
+	}
+	return location
+}
+
+// subnetRegion returns the region of the subnet of the clusters of the project,
+// which is the region of the clusters as they must be in the region of their subnet
+func (d *Deployer) subnetRegion(project string, retryCount int) string {
+	if clusters := d.projectClustersLayout[project]; len(clusters) > 0 {
+		return d.clusterRegion(clusters[0].name, retryCount)
+	}
+	return regionFromLocation(d.Regions, d.Zones, retryCount)
+}
+
+// clusterRegions returns the regions of the clusters of the project, in order
+func (d *Deployer) clusterRegions(project string, retryCount int) []string {
+	var regions []string
+	seen := map[string]bool{}
+	for _, cluster := range d.projectClustersLayout[project] {
+		if region := d.clusterRegion(cluster.name, retryCount); !seen[region] {
+			seen[region] = true
+			regions = append(regions, region)
+		}
+	}
+	return regions
+}
+
+// verifyClusterRegions fails if the clusters of a service project of the
+// multi-project profile, which share the subnet of the project, are in
+// different regions for any of the retried locations
+func (d *Deployer) verifyClusterRegions() error {
+	if len(d.Projects) < 2 {
+		return nil
+	}
+	for retryCount := 0; retryCount < d.totalTryCount; retryCount++ {
+		for _, project := range d.Projects[1:] {
+			if regions := d.clusterRegions(project, retryCount); len(regions) > 1 {
+				return fmt.Errorf("the clusters of project %s share a subnet, but are in regions %s: set the same region in their --cluster-spec",
+					project, strings.Join(regions, ", "))
+			}
+		}
+	}
+	return nil
+}
+
+// clusterVersion returns the version of the cluster
+func (d *Deployer) clusterVersion(name string) string {
+	if spec := d.clusterSpecs[name]; spec.version != "" {
+		return spec.version
+	}
+	return d.ClusterVersion
+}
+
+// clusterMachineType returns the machine type of the default node pool of the cluster
+func (d *Deployer) clusterMachineType(name string) string {
+	if spec := d.clusterSpecs[name]; spec.machineType != "" {
+		return spec.machineType
+	}
+	return d.MachineType
+}
+
+// machineTypes returns the machine types of the default node pools of all
+// the clusters, or of the flags if the clusters are not known yet
+func (d *Deployer) machineTypes() []string {
+	if len(d.Clusters) == 0 {
+		return []string{d.MachineType}
+	}
+	var machineTypes []string
+	seen := map[string]bool{}
+	for _, c := range d.Clusters {
+		machineType := d.clusterMachineType(strings.Split(c, ":")[0])
+		if !seen[machineType] {
+			seen[machineType] = true
+			machineTypes = append(machineTypes, machineType)
+		}
+	}
+	return machineTypes
+}
+
+// clusterNumNodes returns the number of nodes of the default node pool of the cluster
+func (d *Deployer) clusterNumNodes(name string) int {
+	if spec := d.clusterSpecs[name]; spec.numNodes != 0 {
+		return spec.numNodes
+	}
+	return d.NumNodes
+}
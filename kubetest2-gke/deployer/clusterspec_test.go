@@ -0,0 +1,181 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package deployer
+
+import (
+	"reflect"
+	"testing"
+
+	"sigs.k8s.io/kubetest2/kubetest2-gke/deployer/options"
+)
+
+func TestParseClusterSpecs(t *testing.T) {
+	testCases := []struct {
+		name        string
+		values      []string
+		expected    []clusterSpec
+		expectError bool
+	}{
+		{
+			name: "none",
+		},
+		{
+			name:   "one value per spec",
+			values: []string{"name=c1,version=1.29,region=us-central1", "name=c2,zone=europe-west1-b,machine-type=n2-standard-4,num-nodes=2"},
+			expected: []clusterSpec{
+				{name: "c1", version: "1.29", region: "us-central1"},
+				{name: "c2", zone: "europe-west1-b", machineType: "n2-standard-4", numNodes: 2},
+			},
+		},
+		{
+			name:   "pairs split by the flag parsing",
+			values: []string{"name=c1", "version=1.29", "name=c2", "project=1"},
+			expected: []clusterSpec{
+				{name: "c1", version: "1.29"},
+				{name: "c2", project: "1"},
+			},
+		},
+		{
+			name:        "missing name",
+			values:      []string{"version=1.29,name=c1"},
+			expectError: true,
+		},
+		{
+			name:        "duplicate name",
+			values:      []string{"name=c1", "name=c1"},
+			expectError: true,
+		},
+		{
+			name:        "unknown key",
+			values:      []string{"name=c1,nodes=3"},
+			expectError: true,
+		},
+		{
+			name:        "region and zone",
+			values:      []string{"name=c1,region=us-central1,zone=us-central1-c"},
+			expectError: true,
+		},
+		{
+			name:        "invalid num nodes",
+			values:      []string{"name=c1,num-nodes=0"},
+			expectError: true,
+		},
+		{
+			name:        "invalid version",
+			values:      []string{"name=c1,version=next"},
+			expectError: true,
+		},
+	}
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			actual, err := parseClusterSpecs(tc.values)
+			if tc.expectError {
+				if err == nil {
+					t.Errorf("expected an error but got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Errorf("did not expect an error, but got: %v", err)
+			}
+			if !reflect.DeepEqual(actual, tc.expected) {
+				t.Errorf("expected %+v, but got %+v", tc.expected, actual)
+			}
+		})
+	}
+}
+
+func TestApplyClusterSpecs(t *testing.T) {
+	d := &Deployer{
+		ClusterOptions: &options.ClusterOptions{
+			ClusterSpecs:   []string{"name=c1,region=us-central1,version=1.29", "name=c2,project=1,num-nodes=5"},
+			Zones:          []string{"us-west1-a", "us-west1-b"},
+			ClusterVersion: "1.30",
+			MachineType:    "e2-standard-4",
+			NumNodes:       3,
+		},
+	}
+	if err := d.applyClusterSpecs(); err != nil {
+		t.Fatalf("did not expect an error, but got: %v", err)
+	}
+	if expected := []string{"c1", "c2:1"}; !reflect.DeepEqual(d.Clusters, expected) {
+		t.Errorf("expected clusters %v, but got %v", expected, d.Clusters)
+	}
+	if loc := d.clusterLocationFlag("c1", 1); loc != "--region=us-central1" {
+		t.Errorf("expected the location of the spec, but got %s", loc)
+	}
+	if loc := d.clusterLocationFlag("c2", 1); loc != "--zone=us-west1-b" {
+		t.Errorf("expected the shared location, but got %s", loc)
+	}
+	if v := d.clusterVersion("c1"); v != "1.29" {
+		t.Errorf("expected the version of the spec, but got %s", v)
+	}
+	if v := d.clusterVersion("c2"); v != "1.30" {
+		t.Errorf("expected the shared version, but got %s", v)
+	}
+	if n := d.clusterNumNodes("c2"); n != 5 {
+		t.Errorf("expected the num nodes of the spec, but got %d", n)
+	}
+	if m := d.clusterMachineType("c2"); m != "e2-standard-4" {
+		t.Errorf("expected the shared machine type, but got %s", m)
+	}
+
+	d.Clusters = []string{"other"}
+	if err := d.applyClusterSpecs(); err == nil {
+		t.Errorf("expected an error using --cluster-spec with --cluster-name")
+	}
+}
+
+func TestClusterRegions(t *testing.T) {
+	d := &Deployer{
+		ClusterOptions: &options.ClusterOptions{
+			ClusterSpecs: []string{"name=c1,project=0,zone=us-east1-b", "name=c2,project=1,region=europe-west1", "name=c3,project=1"},
+			Zones:        []string{"us-west1-a", "europe-west1-c"},
+		},
+		ProjectOptions: &options.ProjectOptions{},
+		totalTryCount:  2,
+	}
+	if err := d.applyClusterSpecs(); err != nil {
+		t.Fatalf("did not expect an error, but got: %v", err)
+	}
+	d.Projects = []string{"host", "service"}
+	if err := d.layoutClusters(); err != nil {
+		t.Fatalf("did not expect an error, but got: %v", err)
+	}
+	if loc := d.clusterLocation("c1", 1); loc != "us-east1-b" {
+		t.Errorf("expected the zone of the spec, but got %s", loc)
+	}
+	if loc := d.clusterLocation("c3", 1); loc != "europe-west1-c" {
+		t.Errorf("expected the shared zone of the retry, but got %s", loc)
+	}
+	if region := d.clusterRegion("c1", 0); region != "us-east1" {
+		t.Errorf("expected the region of the zone, but got %s", region)
+	}
+	if region := d.subnetRegion("service", 1); region != "europe-west1" {
+		t.Errorf("expected the region of the clusters of the project, but got %s", region)
+	}
+	// c3 is in us-west1 on the first try
+	if err := d.verifyClusterRegions(); err == nil {
+		t.Errorf("expected an error for the clusters of a project in different regions")
+	}
+	d.Zones = []string{"europe-west1-b"}
+	d.totalTryCount = 1
+	if err := d.verifyClusterRegions(); err != nil {
+		t.Errorf("did not expect an error, but got: %v", err)
+	}
+}
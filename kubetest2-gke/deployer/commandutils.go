@@ -29,6 +29,7 @@ import (
 	"k8s.io/klog"
 
 	"sigs.k8s.io/kubetest2/pkg/exec"
+	"sigs.k8s.io/kubetest2/pkg/secrets"
 )
 
 func (d *Deployer) PrepareGcpIfNeeded(projectID string) error {
@@ -85,10 +86,15 @@ func (d *Deployer) PrepareGcpIfNeeded(projectID string) error {
 }
 
 // Activate service account if set or do nothing.
-func activateServiceAccount(path string) error {
-	if path == "" {
+// The key may be a reference to a secret e.g. gcp-secret:projects/P/secrets/S.
+func activateServiceAccount(key string) error {
+	if key == "" {
 		return nil
 	}
+	path, err := secrets.ResolveFile(key)
+	if err != nil {
+		return fmt.Errorf("failed to resolve the service account key: %w", err)
+	}
 	return runWithOutput(exec.RawCommand("gcloud auth activate-service-account --key-file=" + path))
 }
 
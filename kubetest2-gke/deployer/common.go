@@ -18,6 +18,7 @@ package deployer
 
 import (
 	"fmt"
+	"os"
 	"regexp"
 	"strconv"
 	"strings"
@@ -26,7 +27,9 @@ import (
 	"github.com/pkg/math"
 	"k8s.io/klog"
 
-	"sigs.k8s.io/kubetest2/pkg/boskos"
+	"sigs.k8s.io/kubetest2/pkg/exec"
+	"sigs.k8s.io/kubetest2/pkg/firewall"
+	"sigs.k8s.io/kubetest2/pkg/projects"
 )
 
 const (
@@ -36,6 +39,26 @@ const (
 	defaultBoskosHeartbeatIntervalSeconds = 300
 )
 
+// projectConfig returns the config of the projects of the run from the flags
+func (d *Deployer) projectConfig() *projects.Config {
+	c := &projects.Config{
+		Source:                  d.ProjectSource,
+		Projects:                d.Projects,
+		BoskosLocation:          d.BoskosLocation,
+		BoskosAcquireTimeout:    time.Duration(d.BoskosAcquireTimeoutSeconds) * time.Second,
+		BoskosHeartbeatInterval: time.Duration(d.BoskosHeartbeatIntervalSeconds) * time.Second,
+		LeaseFile:               d.BoskosLeaseFile,
+		LeaseFinal:              d.BoskosLeaseFinal,
+	}
+	// a length mismatch is rejected by VerifyUpFlags
+	for i, count := range d.BoskosProjectsRequested {
+		if i < len(d.BoskosResourceType) {
+			c.BoskosRequests = append(c.BoskosRequests, projects.BoskosRequest{ResourceType: d.BoskosResourceType[i], Count: count})
+		}
+	}
+	return c
+}
+
 func (d *Deployer) Init() error {
 	var err error
 	d.doInit.Do(func() { err = d.Initialize() })
@@ -44,13 +67,50 @@ func (d *Deployer) Init() error {
 
 // Initialize should only be called by init(), behind a sync.Once
 func (d *Deployer) Initialize() error {
+	d.firewalls = firewall.NewManager(d.Kubetest2CommonOptions.RunDir(), d.SkipFirewallRules)
+
+	if d.SimulateError != "" {
+		fault, err := parseSimulatedError(d.SimulateError)
+		if err != nil {
+			return err
+		}
+		klog.Warningf("Simulating a %s error during %s with --simulate-error", fault.kind, fault.phase)
+		exec.SetFaultInjector(fault)
+	}
+
 	if d.ClusterVersion == "" && d.LegacyClusterVersion != "" {
 		klog.Warningf("--version is deprecated please use --cluster-version")
 		d.ClusterVersion = d.LegacyClusterVersion
 	}
+	if err := d.applyClusterSpecs(); err != nil {
+		return err
+	}
 	if d.Kubetest2CommonOptions.ShouldUp() {
 		d.totalTryCount = math.Max(len(d.Regions), len(d.Zones))
 
+		// the projects of a resumed run are restored with the state
+		projectConfig := d.projectConfig()
+		if d.projectLease == nil {
+			// all but the boskos projects are known before verifying the flags
+			source, knownProjects, err := projectConfig.Resolve()
+			if err != nil {
+				return fmt.Errorf("init failed to resolve the projects: %w", err)
+			}
+			if d.MaxProjectRetries > 0 && source != projects.Boskos {
+				return fmt.Errorf("--max-project-retries requires the projects to be leased from boskos")
+			}
+			if d.BoskosLeaseFile != "" {
+				if source != projects.Boskos {
+					return fmt.Errorf("--boskos-lease-file requires the projects to be leased from boskos")
+				}
+				// the rotated projects would be released while still in the lease file
+				if d.MaxProjectRetries > 0 {
+					return fmt.Errorf("--boskos-lease-file cannot be used with --max-project-retries")
+				}
+			}
+			d.Projects = knownProjects
+		}
+
 		if err := d.VerifyUpFlags(); err != nil {
 			return fmt.Errorf("init failed to verify flags for up: %w", err)
 		}
@@ -65,42 +125,59 @@ func (d *Deployer) Initialize() error {
 			}
 		}
 
-		if len(d.Projects) == 0 {
-			klog.V(1).Infof("No GCP projects provided, acquiring from Boskos %d project/s", d.BoskosProjectsRequested)
-
-			boskosClient, err := boskos.NewClient(d.BoskosLocation)
-			if err != nil {
-				return fmt.Errorf("failed to make boskos client: %w", err)
+		if d.projectLease == nil {
+			if len(d.Projects) == 0 {
+				klog.V(1).Infof("No GCP projects provided, acquiring from Boskos %d project/s", d.BoskosProjectsRequested)
 			}
-			d.boskos = boskosClient
-			d.boskosHeartbeatClose = make(chan struct{})
-
-			for i := 0; i < len(d.BoskosProjectsRequested); i++ {
-				for j := 0; j < d.BoskosProjectsRequested[i]; j++ {
-					resource, err := boskos.Acquire(
-						d.boskos,
-						d.BoskosResourceType[i],
-						time.Duration(d.BoskosAcquireTimeoutSeconds)*time.Second,
-						time.Duration(d.BoskosHeartbeatIntervalSeconds)*time.Second,
-						d.boskosHeartbeatClose,
-					)
-
-					if err != nil {
-						return fmt.Errorf("init failed to get project from boskos: %w", err)
-					}
-					d.Projects = append(d.Projects, resource.Name)
-					klog.V(1).Infof("Got project %s from boskos", resource.Name)
-				}
+			lease, err := projects.Acquire(projectConfig)
+			if err != nil {
+				return fmt.Errorf("init failed to acquire the projects: %w", err)
 			}
+			d.projectLease = lease
+			d.Projects = lease.Projects()
+			// persist the leased projects right away, for a resumed run to reuse them
+			d.saveState()
 		}
 	}
 
 	if d.Kubetest2CommonOptions.ShouldDown() {
+		// e.g. the final stage of a pipeline tearing down the clusters of the
+		// earlier ones, in the projects of their lease
+		if d.projectLease == nil && d.BoskosLeaseFile != "" {
+			if _, err := os.Stat(d.BoskosLeaseFile); err == nil {
+				// not new projects, which would not have the clusters
+				lease, err := projects.ResumeLeaseFile(d.projectConfig())
+				if err != nil {
+					return fmt.Errorf("init failed to reuse the projects of the boskos lease file: %w", err)
+				}
+				d.projectLease = lease
+				d.Projects = lease.Projects()
+			}
+		}
 		if err := d.VerifyDownFlags(); err != nil {
 			return fmt.Errorf("init failed to verify flags for down: %w", err)
 		}
 	}
 
+	if err := d.layoutClusters(); err != nil {
+		return err
+	}
+	if d.Kubetest2CommonOptions.ShouldUp() {
+		if err := d.verifyClusterRegions(); err != nil {
+			return err
+		}
+	}
+
+	// Prepare the GCP environment for the following operations.
+	if err := d.PrepareGcpIfNeeded(d.Projects[0]); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// layoutClusters maps the clusters to the projects they are created in
+func (d *Deployer) layoutClusters() error {
 	// Multi-cluster name adjustment
 	numProjects := len(d.Projects)
 	d.projectClustersLayout = make(map[string][]cluster, numProjects)
@@ -116,13 +193,44 @@ func (d *Deployer) Initialize() error {
 		}
 		d.projectClustersLayout[d.Projects[0]] = clusters
 	}
+	return nil
+}
 
-	// Prepare the GCP environment for the following operations.
-	if err := d.PrepareGcpIfNeeded(d.Projects[0]); err != nil {
-		return err
+// retryProjectConfig returns the config of the projects leased by rotateProjects
+func (d *Deployer) retryProjectConfig() *projects.Config {
+	c := d.projectConfig()
+	c.Source = string(projects.Boskos)
+	c.Projects = nil
+	for i := range c.BoskosRequests {
+		if i < len(d.BoskosRetryResourceType) {
+			c.BoskosRequests[i].ResourceType = d.BoskosRetryResourceType[i]
+		}
 	}
+	return c
+}
 
-	return nil
+// rotateProjects releases the boskos projects of the run and leases new
+// ones in their place, to retry the creation of the clusters there
+func (d *Deployer) rotateProjects() error {
+	// the firewall rules of the run are deleted by the run itself, see Down
+	if err := d.firewalls.Cleanup(); err != nil {
+		klog.Errorf("Error cleaning-up firewall rules: %v", err)
+	}
+	if err := d.projectLease.Release(); err != nil {
+		klog.Warningf("Failed to release the projects %v: %v", d.Projects, err)
+	}
+	lease, err := projects.Acquire(d.retryProjectConfig())
+	if err != nil {
+		return err
+	}
+	klog.V(0).Infof("Replaced the projects %v with %v", d.Projects, lease.Projects())
+	d.projectLease = lease
+	d.Projects = lease.Projects()
+	d.saveState()
+	if err := d.layoutClusters(); err != nil {
+		return err
+	}
+	return d.PrepareGcpIfNeeded(d.Projects[0])
 }
 
 // buildProjectClustersLayout builds the projects and real cluster names mapping based on the provided --cluster-name flag.
@@ -0,0 +1,105 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package deployer
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"k8s.io/klog"
+
+	"sigs.k8s.io/kubetest2/pkg/exec"
+)
+
+// controlPlaneComponents are the components whose logs are enabled with
+// --control-plane-logs, by their component_name in Cloud Logging
+var controlPlaneComponents = []string{"apiserver", "scheduler", "controller-manager"}
+
+// controlPlaneLoggingArgs returns the flags enabling the control plane logs
+// of a cluster along with the default system and workload logs
+func (d *Deployer) controlPlaneLoggingArgs() []string {
+	if !d.ControlPlaneLogs {
+		return nil
+	}
+	return []string{"--logging=SYSTEM,WORKLOAD,API_SERVER,SCHEDULER,CONTROLLER_MANAGER"}
+}
+
+// controlPlaneLogsFilter returns the Cloud Logging filter of the logs of the
+// control plane component of the cluster from start to end
+func controlPlaneLogsFilter(cluster, location, component string, start, end time.Time) string {
+	return strings.Join([]string{
+		`resource.type="k8s_control_plane_component"`,
+		fmt.Sprintf(`resource.labels.cluster_name="%s"`, cluster),
+		fmt.Sprintf(`resource.labels.location="%s"`, location),
+		fmt.Sprintf(`resource.labels.component_name="%s"`, component),
+		fmt.Sprintf(`timestamp>="%s"`, start.UTC().Format(time.RFC3339)),
+		fmt.Sprintf(`timestamp<="%s"`, end.UTC().Format(time.RFC3339)),
+	}, " AND ")
+}
+
+// DumpControlPlaneLogs pulls the control plane logs of the clusters from
+// Cloud Logging, from the creation of each cluster until now, to
+// logs/<cluster>/control-plane/<component>.json in the run dir
+func (d *Deployer) DumpControlPlaneLogs() error {
+	end := time.Now()
+	return d.forEachCluster(func(project string, cluster cluster, locationArg string) error {
+		return d.dumpClusterControlPlaneLogs(project, cluster.name, locationArg, end)
+	})
+}
+
+func (d *Deployer) dumpClusterControlPlaneLogs(project, cluster, locationArg string, end time.Time) error {
+	lines, err := exec.OutputLines(exec.Command("gcloud", containerArgs("clusters", "describe", cluster,
+		"--project="+project,
+		locationArg,
+		"--format=value(createTime)")...))
+	if err != nil || len(lines) == 0 {
+		return fmt.Errorf("error getting the creation time of cluster %s: %s", cluster, execError(err))
+	}
+	start, err := time.Parse(time.RFC3339, strings.TrimSpace(lines[0]))
+	if err != nil {
+		return fmt.Errorf("error parsing the creation time of cluster %s: %v", cluster, err)
+	}
+	// the location label is the zone or region of the --zone or --region flag
+	location := locationArg[strings.Index(locationArg, "=")+1:]
+
+	dir := filepath.Join(d.localLogsDir, cluster, "control-plane")
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		return err
+	}
+	for _, component := range controlPlaneComponents {
+		klog.V(1).Infof("Dumping the %s logs of cluster %s", component, cluster)
+		f, err := os.Create(filepath.Join(dir, component+".json"))
+		if err != nil {
+			return err
+		}
+		cmd := exec.Command("gcloud", "logging", "read", controlPlaneLogsFilter(cluster, location, component, start, end),
+			"--project="+project,
+			"--order=asc",
+			"--format=json")
+		cmd.SetStdout(f)
+		cmd.SetStderr(os.Stderr)
+		err = cmd.Run()
+		f.Close()
+		if err != nil {
+			return fmt.Errorf("error reading the %s logs of cluster %s: %v", component, cluster, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,51 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package deployer
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"sigs.k8s.io/kubetest2/kubetest2-gke/deployer/options"
+)
+
+func TestControlPlaneLoggingArgs(t *testing.T) {
+	d := &Deployer{ClusterOptions: &options.ClusterOptions{}}
+	if args := d.controlPlaneLoggingArgs(); len(args) != 0 {
+		t.Errorf("expected no args without --control-plane-logs, got %v", args)
+	}
+	d.ControlPlaneLogs = true
+	expected := []string{"--logging=SYSTEM,WORKLOAD,API_SERVER,SCHEDULER,CONTROLLER_MANAGER"}
+	if args := d.controlPlaneLoggingArgs(); !reflect.DeepEqual(args, expected) {
+		t.Errorf("expected %v, got %v", expected, args)
+	}
+}
+
+func TestControlPlaneLogsFilter(t *testing.T) {
+	start := time.Date(2021, 3, 4, 10, 0, 0, 0, time.FixedZone("PST", -8*60*60))
+	end := start.Add(time.Hour)
+	expected := `resource.type="k8s_control_plane_component" AND ` +
+		`resource.labels.cluster_name="cluster-1" AND ` +
+		`resource.labels.location="us-central1-c" AND ` +
+		`resource.labels.component_name="apiserver" AND ` +
+		`timestamp>="2021-03-04T18:00:00Z" AND ` +
+		`timestamp<="2021-03-04T19:00:00Z"`
+	if filter := controlPlaneLogsFilter("cluster-1", "us-central1-c", "apiserver", start, end); filter != expected {
+		t.Errorf("expected filter\n%s\ngot\n%s", expected, filter)
+	}
+}
@@ -0,0 +1,118 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package deployer
+
+import (
+	"k8s.io/klog"
+
+	"sigs.k8s.io/kubetest2/pkg/cost"
+)
+
+// startCostEstimate records the inventory of the resources about to be created,
+// if cost estimation is enabled. Failures are not fatal to the run.
+func (d *Deployer) startCostEstimate() {
+	if !d.EstimateCost {
+		return
+	}
+	resources, err := d.costInventory()
+	if err != nil {
+		klog.Warningf("Skipping cost estimation: %v", err)
+		return
+	}
+	if err := cost.Start(d.Kubetest2CommonOptions.RunDir(), resources); err != nil {
+		klog.Warningf("Failed to record the resource inventory: %v", err)
+	}
+}
+
+// finishCostEstimate completes the cost estimate recorded in Up() with the duration of the run
+func (d *Deployer) finishCostEstimate() {
+	if !d.EstimateCost {
+		return
+	}
+	report, err := cost.Finish(d.Kubetest2CommonOptions.RunDir())
+	if err != nil {
+		klog.Warningf("Failed to estimate the cost of the run: %v", err)
+		return
+	}
+	if report == nil {
+		return
+	}
+	klog.V(0).Infof("Estimated cost of the run: %.4f %s (%.2f hours at %.4f %s/hour)",
+		report.EstimatedCost, report.Currency, report.Hours, report.HourlyCost, report.Currency)
+}
+
+// costInventory returns the billable resources of the clusters, priced in the
+// regions of their locations
+func (d *Deployer) costInventory() ([]cost.Resource, error) {
+	var resources []cost.Resource
+	for _, project := range d.Projects {
+		for _, cluster := range d.projectClustersLayout[project] {
+			location := d.clusterLocation(cluster.name, d.retryCount)
+			region := locationRegion(location)
+			nodesMultiplier := 1
+			if location != "" && location == region {
+				// the node pools of regional clusters have the nodes in each zone
+				nodesMultiplier = defaultZonesPerRegion
+			}
+			resources = append(resources, cost.GKECluster(cluster.name, project, region))
+			// GKE Autopilot bills the pods instead of the nodes, which cannot be known in advance.
+			if d.Autopilot {
+				continue
+			}
+			machineType := d.clusterMachineType(cluster.name)
+			if machineType == "" {
+				machineType = defaultMachineType
+			}
+			nodes, err := cost.Instances("node", cluster.name, project, region, machineType, d.clusterNumNodes(cluster.name)*nodesMultiplier)
+			if err != nil {
+				return nil, err
+			}
+			resources = append(resources, nodes)
+			if d.WindowsEnabled {
+				windowsMachineType := d.WindowsMachineType
+				if windowsMachineType == "" {
+					windowsMachineType = defaultMachineType
+				}
+				windowsNodes, err := cost.Instances("windows-node", cluster.name, project, region, windowsMachineType, d.WindowsNumNodes*nodesMultiplier)
+				if err != nil {
+					return nil, err
+				}
+				resources = append(resources, windowsNodes)
+			}
+			// the price of the attached accelerators is not included
+			if d.Accelerator != "" {
+				acceleratorNodes, err := cost.Instances("accelerator-node", cluster.name, project, region, d.AcceleratorMachineType, d.AcceleratorNumNodes*nodesMultiplier)
+				if err != nil {
+					return nil, err
+				}
+				resources = append(resources, acceleratorNodes)
+			}
+			if d.SandboxEnabled {
+				sandboxMachineType := d.SandboxMachineType
+				if sandboxMachineType == "" {
+					sandboxMachineType = defaultMachineType
+				}
+				sandboxNodes, err := cost.Instances("sandbox-node", cluster.name, project, region, sandboxMachineType, d.SandboxNumNodes*nodesMultiplier)
+				if err != nil {
+					return nil, err
+				}
+				resources = append(resources, sandboxNodes)
+			}
+		}
+	}
+	return resources, nil
+}
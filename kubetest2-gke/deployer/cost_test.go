@@ -0,0 +1,59 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package deployer
+
+import (
+	"testing"
+
+	"sigs.k8s.io/kubetest2/kubetest2-gke/deployer/options"
+)
+
+func TestCostInventory(t *testing.T) {
+	d := &Deployer{
+		ClusterOptions: &options.ClusterOptions{
+			ClusterSpecs: []string{"name=c1,zone=us-central1-a", "name=c2,region=europe-west2,machine-type=n1-standard-2,num-nodes=2"},
+			MachineType:  "e2-medium",
+			NumNodes:     1,
+		},
+		ProjectOptions: &options.ProjectOptions{},
+	}
+	if err := d.applyClusterSpecs(); err != nil {
+		t.Fatalf("did not expect an error, but got: %v", err)
+	}
+	d.Projects = []string{"p"}
+	if err := d.layoutClusters(); err != nil {
+		t.Fatalf("did not expect an error, but got: %v", err)
+	}
+	resources, err := d.costInventory()
+	if err != nil {
+		t.Fatalf("did not expect an error, but got: %v", err)
+	}
+	nodes := map[string]int{}
+	regions := map[string]string{}
+	for _, r := range resources {
+		if r.Kind == "node" {
+			nodes[r.Name] = r.Count
+			regions[r.Name] = r.Region
+		}
+	}
+	if regions["c1"] != "us-central1" || regions["c2"] != "europe-west2" {
+		t.Errorf("expected the nodes priced in the regions of the clusters, but got %v", regions)
+	}
+	if nodes["c1"] != 1 || nodes["c2"] != 2*defaultZonesPerRegion {
+		t.Errorf("expected the nodes of the zonal and the regional cluster, but got %v", nodes)
+	}
+}
@@ -0,0 +1,43 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package deployer
+
+import (
+	"fmt"
+
+	"sigs.k8s.io/kubetest2/pkg/types"
+)
+
+// assert that Deployer implements types.DeployerWithDebugHints
+var _ types.DeployerWithDebugHints = &Deployer{}
+
+// DebugHints returns the gcloud commands to inspect the clusters and find their nodes
+func (d *Deployer) DebugHints() []string {
+	var hints []string
+	for _, project := range d.Projects {
+		for _, cluster := range d.projectClustersLayout[project] {
+			loc := d.clusterLocationFlag(cluster.name, d.retryCount)
+			hints = append(hints,
+				fmt.Sprintf("gcloud container clusters describe %s --project=%s %s", cluster.name, project, loc),
+				fmt.Sprintf("gcloud compute instances list --project=%s --filter='name~^gke-%s-'", project, cluster.name))
+		}
+	}
+	if len(hints) > 0 {
+		hints = append(hints, "gcloud compute ssh --project=PROJECT --zone=ZONE NODE, to ssh into one of the nodes")
+	}
+	return hints
+}
@@ -24,14 +24,16 @@ import (
 	"regexp"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/octago/sflags/gen/gpflag"
 	"github.com/spf13/pflag"
 	"k8s.io/klog"
-	"sigs.k8s.io/boskos/client"
 
 	"sigs.k8s.io/kubetest2/kubetest2-gke/deployer/options"
 	"sigs.k8s.io/kubetest2/pkg/build"
+	"sigs.k8s.io/kubetest2/pkg/firewall"
+	"sigs.k8s.io/kubetest2/pkg/projects"
 	"sigs.k8s.io/kubetest2/pkg/types"
 )
 
@@ -75,6 +77,16 @@ var (
 	defaultWindowsNodePool = gkeNodePool{
 		Nodes: 1,
 	}
+
+	// most GPU types, including the T4, can only be attached to N1 machines
+	defaultAcceleratorNodePool = gkeNodePool{
+		Nodes:       1,
+		MachineType: "n1-standard-4",
+	}
+
+	defaultSandboxNodePool = gkeNodePool{
+		Nodes: 1,
+	}
 )
 
 type gkeNodePool struct {
@@ -111,9 +123,24 @@ type Deployer struct {
 	projectClustersLayout map[string][]cluster
 	// project -> cluster -> instance groups
 	instanceGroups map[string]map[string][]*ig
+	// instanceGroupsMu guards the population of instanceGroups, which the
+	// chaos injector does concurrently with the test
+	instanceGroupsMu sync.Mutex
+
+	// firewalls records the firewall rules created for the run
+	firewalls *firewall.Manager
 
 	kubecfgPath  string
+	kubecfgDir   string
 	testPrepared bool
+	// stateRestored is set when resuming a run whose state was persisted
+	stateRestored bool
+
+	// labels applied to the resources created by the run
+	resourceLabels map[string]string
+
+	// node pool -> node system config file passed to gcloud
+	systemConfigFiles map[string]string
 
 	localLogsDir string
 	gcsLogsDir   string
@@ -125,16 +152,20 @@ type Deployer struct {
 	subnetworkRangesInternal             [][]string
 	privateClusterMasterIPRangesInternal [][]string
 
+	// clusterSpecs are the --cluster-spec of the clusters by name
+	clusterSpecs map[string]clusterSpec
+	// autoscaling is the --enable-autoscaling config by node pool name
+	autoscaling map[string]nodePoolAutoscaling
+	// unstableAPIs are the beta APIs of the --runtime-config of kubetest2, see SetFeatureGates
+	unstableAPIs []string
+	// alphaWatchdog terminates the run at the --alpha-cluster-max-hours
+	alphaWatchdog *time.Timer
+
 	// the total number of Boskos projects to request
 	totalBoskosProjectsRequested int
 
-	// boskos struct field will be non-nil when the deployer is
-	// using boskos to acquire a GCP project
-	boskos *client.Client
-
-	// this channel serves as a signal channel for the hearbeat goroutine
-	// so that it can be explicitly closed
-	boskosHeartbeatClose chan struct{}
+	// projectLease holds the projects acquired from the --project-source
+	projectLease *projects.Lease
 }
 
 // assert that New implements types.NewDeployer
@@ -151,6 +182,10 @@ func (d *Deployer) Version() string {
 	return GitTag
 }
 
+func (d *Deployer) Description() string {
+	return "creates GKE clusters with gcloud"
+}
+
 // New implements deployer.New for gke
 func New(opts types.Options) (types.Deployer, *pflag.FlagSet) {
 	d := NewDeployer(opts)
@@ -176,7 +211,8 @@ func NewDeployer(opts types.Options) *Deployer {
 			},
 		},
 		CommonOptions: &options.CommonOptions{
-			GCPSSHKeyIgnored: true,
+			GCPSSHKeyIgnored:          true,
+			ResourceLabelsExpiryHours: defaultResourceLabelsExpiryHours,
 		},
 		ProjectOptions: &options.ProjectOptions{
 			BoskosLocation:                 defaultBoskosLocation,
@@ -197,8 +233,19 @@ func NewDeployer(opts types.Options) *Deployer {
 			ClusterVersion:    "",
 			FirewallRuleAllow: defaultFirewallRuleAllow,
 
-			WindowsNumNodes:    defaultWindowsNodePool.Nodes,
-			WindowsMachineType: defaultWindowsNodePool.MachineType,
+			WindowsNumNodes:                defaultWindowsNodePool.Nodes,
+			WindowsMachineType:             defaultWindowsNodePool.MachineType,
+			WindowsNoScheduleTaint:         true,
+			WindowsNodeReadyTimeoutMinutes: defaultWindowsNodeReadyTimeoutMinutes,
+
+			AcceleratorNumNodes:    defaultAcceleratorNodePool.Nodes,
+			AcceleratorMachineType: defaultAcceleratorNodePool.MachineType,
+
+			SandboxNumNodes:    defaultSandboxNodePool.Nodes,
+			SandboxMachineType: defaultSandboxNodePool.MachineType,
+
+			MaintenanceExclusionScope: "no_upgrades",
+			AlphaClusterMaxHours:      defaultAlphaClusterMaxHours,
 
 			RetryableErrorPatterns: []string{gceStockoutErrorPattern},
 		},
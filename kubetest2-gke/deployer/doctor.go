@@ -0,0 +1,369 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package deployer
+
+import (
+	"bytes"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"sigs.k8s.io/kubetest2/pkg/doctor"
+	"sigs.k8s.io/kubetest2/pkg/exec"
+	"sigs.k8s.io/kubetest2/pkg/projects"
+	"sigs.k8s.io/kubetest2/pkg/secrets"
+	"sigs.k8s.io/kubetest2/pkg/types"
+)
+
+var _ types.DeployerWithDoctor = &Deployer{}
+
+// minGcloudVersion is the oldest gcloud release supported by the deployer,
+// older ones lack some of the flags it passes
+const minGcloudVersion = "400.0.0"
+
+// testIAMPermissionsURL is the Resource Manager API returning which of the
+// permissions the caller has in a project
+const testIAMPermissionsURL = "https://cloudresourcemanager.googleapis.com/v1/projects/%s:testIamPermissions"
+
+// DoctorChecks returns the preflight checks of `kubetest2 doctor` for the
+// flags: the tools installed, the credentials, and the APIs enabled and the
+// permissions granted in the projects, unless they are leased from boskos
+func (d *Deployer) DoctorChecks() []types.Check {
+	checks := []types.Check{
+		doctor.BinaryCheck("gcloud", "install the Google Cloud SDK, see https://cloud.google.com/sdk/docs/install", true),
+		{
+			Name: "gcloud is recent enough",
+			Run:  checkGcloudVersion,
+			Fix:  "gcloud components update",
+		},
+	}
+	creds := &doctorCredentials{}
+	if d.GCPServiceAccount != "" {
+		// the key is not activated as the run does, which would switch the
+		// active account of the user, the checks below use it directly
+		checks = append(checks, types.Check{
+			Name: "the --gcp-service-account key is valid",
+			Run: func() (string, error) {
+				keyFile, account, err := serviceAccountKeyAccount(d.GCPServiceAccount)
+				if err != nil {
+					return "", err
+				}
+				creds.keyFile, creds.account = keyFile, account
+				return account, nil
+			},
+			Fix:   "pass the path to a service account key file, or a reference to a secret holding it e.g. env:NAME or gcp-secret:projects/PROJECT/secrets/SECRET",
+			Fatal: true,
+		})
+	}
+	checks = append(checks,
+		types.Check{
+			Name: "gcloud is authenticated",
+			Run:  creds.checkAuth,
+			Fix:  "gcloud auth login, or gcloud auth activate-service-account --key-file=KEY_FILE, or pass the key with --gcp-service-account",
+			// the API checks only fail the same way without credentials
+			Fatal: true,
+		},
+		doctor.BinaryCheck("kubectl", "gcloud components install kubectl", false),
+		doctor.BinaryCheck("gke-gcloud-auth-plugin", "gcloud components install gke-gcloud-auth-plugin", false),
+	)
+	if !d.GCPSSHKeyIgnored {
+		checks = append(checks, types.Check{
+			Name: "the GCP SSH key exists",
+			Run:  checkSSHKey,
+			Fix:  `ssh-keygen -t rsa -f ~/.ssh/google_compute_engine -C "$USER" -N "", or pass --ignore-gcp-ssh-key`,
+		})
+	}
+
+	source, knownProjects, err := d.projectConfig().Resolve()
+	checks = append(checks, types.Check{
+		Name: "the projects are resolved",
+		Run: func() (string, error) {
+			if err != nil {
+				return "", err
+			}
+			if source == projects.Boskos {
+				return "leased from boskos at up, not checking their APIs and permissions", nil
+			}
+			return strings.Join(knownProjects, ", "), nil
+		},
+		Fix: "pass --project, or --project-source=boskos to lease the projects",
+	})
+	if err != nil || source == projects.Boskos {
+		return checks
+	}
+	for _, project := range knownProjects {
+		project := project
+		checks = append(checks,
+			types.Check{
+				Name: fmt.Sprintf("the APIs are enabled in %s", project),
+				Run:  func() (string, error) { return "", d.checkAPIs(creds, project) },
+				Fix:  fmt.Sprintf("gcloud services enable %s --project=%s", strings.Join(d.requiredAPIs(), " "), project),
+			},
+			types.Check{
+				Name: fmt.Sprintf("the permissions are granted in %s", project),
+				Run:  func() (string, error) { return "", d.checkPermissions(creds, project) },
+				Fix: fmt.Sprintf("ask an owner of %s to grant the account e.g. roles/container.admin, roles/compute.securityAdmin and roles/iam.serviceAccountUser with "+
+					"gcloud projects add-iam-policy-binding %s --member=user:ACCOUNT --role=ROLE", project, project),
+			},
+		)
+	}
+	return checks
+}
+
+// checkGcloudVersion returns the version of the Google Cloud SDK, failing if it is older than minGcloudVersion
+func checkGcloudVersion() (string, error) {
+	out, err := exec.Output(exec.Command("gcloud", "version", "--format=json"))
+	if err != nil {
+		return "", fmt.Errorf("failed to get the gcloud version: %s", execError(err))
+	}
+	components := map[string]string{}
+	if err := json.Unmarshal(out, &components); err != nil {
+		return "", fmt.Errorf("failed to parse the gcloud version: %v", err)
+	}
+	version := components["Google Cloud SDK"]
+	atLeast, err := versionAtLeast(version, minGcloudVersion)
+	if err != nil {
+		return "", err
+	}
+	if !atLeast {
+		return "", fmt.Errorf("gcloud %s is older than %s", version, minGcloudVersion)
+	}
+	return version, nil
+}
+
+// versionAtLeast returns whether the MAJOR.MINOR.PATCH version is at least the minimum one
+func versionAtLeast(version, minimum string) (bool, error) {
+	parse := func(v string) ([]int, error) {
+		parts := strings.Split(v, ".")
+		if len(parts) != 3 {
+			return nil, fmt.Errorf("unexpected gcloud version %q", v)
+		}
+		numbers := make([]int, len(parts))
+		for i, part := range parts {
+			n, err := strconv.Atoi(part)
+			if err != nil {
+				return nil, fmt.Errorf("unexpected gcloud version %q", v)
+			}
+			numbers[i] = n
+		}
+		return numbers, nil
+	}
+	v, err := parse(version)
+	if err != nil {
+		return false, err
+	}
+	m, err := parse(minimum)
+	if err != nil {
+		return false, err
+	}
+	for i := range v {
+		if v[i] != m[i] {
+			return v[i] > m[i], nil
+		}
+	}
+	return true, nil
+}
+
+// doctorCredentials are the credentials of the gcloud commands of the checks:
+// the --gcp-service-account key if set, passed per command rather than
+// activated, or otherwise the active account of gcloud
+type doctorCredentials struct {
+	keyFile string
+	account string
+}
+
+// gcloud returns the gcloud command authenticated with the credentials
+func (c *doctorCredentials) gcloud(args ...string) exec.Cmd {
+	cmd := exec.Command("gcloud", args...)
+	if c.keyFile != "" {
+		cmd.SetEnv(append(os.Environ(), "CLOUDSDK_AUTH_CREDENTIAL_FILE_OVERRIDE="+c.keyFile)...)
+	}
+	return cmd
+}
+
+// serviceAccountKeyAccount returns the file and the account of the service
+// account key, failing if it does not parse
+func serviceAccountKeyAccount(key string) (string, string, error) {
+	path, err := secrets.ResolveFile(key)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to resolve the service account key: %w", err)
+	}
+	contents, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to read the service account key: %v", err)
+	}
+	parsed := struct {
+		Type        string `json:"type"`
+		ClientEmail string `json:"client_email"`
+		PrivateKey  string `json:"private_key"`
+	}{}
+	if err := json.Unmarshal(contents, &parsed); err != nil {
+		return "", "", fmt.Errorf("failed to parse the service account key: %v", err)
+	}
+	if parsed.Type != "service_account" || parsed.ClientEmail == "" {
+		return "", "", fmt.Errorf("not a service account key, of type %q", parsed.Type)
+	}
+	block, _ := pem.Decode([]byte(parsed.PrivateKey))
+	if block == nil {
+		return "", "", fmt.Errorf("the service account key of %s has no private key", parsed.ClientEmail)
+	}
+	if _, err := x509.ParsePKCS8PrivateKey(block.Bytes); err != nil {
+		return "", "", fmt.Errorf("failed to parse the private key of %s: %v", parsed.ClientEmail, err)
+	}
+	return path, parsed.ClientEmail, nil
+}
+
+// checkAuth returns the account of the credentials, the active account of
+// gcloud without a key
+func (c *doctorCredentials) checkAuth() (string, error) {
+	if c.keyFile != "" {
+		if _, err := exec.Output(c.gcloud("auth", "print-access-token")); err != nil {
+			return "", fmt.Errorf("failed to authenticate as %s: %s", c.account, execError(err))
+		}
+		return c.account, nil
+	}
+	lines, err := exec.OutputLines(exec.Command("gcloud", "auth", "list", "--filter=status:ACTIVE", "--format=value(account)"))
+	if err != nil {
+		return "", fmt.Errorf("failed to list the gcloud accounts: %s", execError(err))
+	}
+	if len(lines) == 0 || lines[0] == "" {
+		return "", fmt.Errorf("no active gcloud account")
+	}
+	return lines[0], nil
+}
+
+// checkSSHKey checks the SSH key the deployer requires to exist, see PrepareGcpIfNeeded
+func checkSSHKey() (string, error) {
+	key := filepath.Join(home(".ssh"), "google_compute_engine")
+	for _, path := range []string{key, key + ".pub"} {
+		if _, err := os.Stat(path); err != nil {
+			return "", err
+		}
+	}
+	return key, nil
+}
+
+// requiredAPIs returns the APIs the flags use
+func (d *Deployer) requiredAPIs() []string {
+	apis := []string{"compute.googleapis.com", "container.googleapis.com"}
+	if d.ControlPlaneLogs {
+		apis = append(apis, "logging.googleapis.com")
+	}
+	if d.NotificationTopic != "" {
+		apis = append(apis, "pubsub.googleapis.com")
+	}
+	return apis
+}
+
+// checkAPIs checks that the required APIs are enabled in the project
+func (d *Deployer) checkAPIs(creds *doctorCredentials, project string) error {
+	enabled, err := exec.OutputLines(creds.gcloud("services", "list", "--enabled",
+		"--project="+project,
+		"--format=value(config.name)"))
+	if err != nil {
+		return fmt.Errorf("failed to list the enabled APIs: %s", execError(err))
+	}
+	if missing := missing(d.requiredAPIs(), enabled); len(missing) > 0 {
+		return fmt.Errorf("%s not enabled", strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+// requiredPermissions returns the permissions the flags use
+func (d *Deployer) requiredPermissions() []string {
+	permissions := []string{
+		"compute.networks.get",
+		"container.clusters.create",
+		"container.clusters.delete",
+		"container.clusters.get",
+		"container.clusters.getCredentials",
+		"container.operations.get",
+		"iam.serviceAccounts.actAs",
+	}
+	if d.Network != "default" {
+		permissions = append(permissions, "compute.networks.create", "compute.networks.delete")
+	}
+	if !d.SkipFirewallRules {
+		permissions = append(permissions, "compute.firewalls.create", "compute.firewalls.delete")
+	}
+	sort.Strings(permissions)
+	return permissions
+}
+
+// checkPermissions checks that the account of the credentials has the
+// required permissions in the project, whether they are granted by
+// predefined, custom or group roles
+func (d *Deployer) checkPermissions(creds *doctorCredentials, project string) error {
+	token, err := exec.Output(creds.gcloud("auth", "print-access-token"))
+	if err != nil {
+		return fmt.Errorf("failed to get an access token: %s", execError(err))
+	}
+	required := d.requiredPermissions()
+	body, err := json.Marshal(map[string][]string{"permissions": required})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, fmt.Sprintf(testIAMPermissionsURL, project), bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+strings.TrimSpace(string(token)))
+	req.Header.Set("Content-Type", "application/json")
+	client := &http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to test the permissions: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to test the permissions: %s", resp.Status)
+	}
+	granted := struct {
+		Permissions []string `json:"permissions"`
+	}{}
+	if err := json.NewDecoder(resp.Body).Decode(&granted); err != nil {
+		return fmt.Errorf("failed to parse the granted permissions: %v", err)
+	}
+	if missing := missing(required, granted.Permissions); len(missing) > 0 {
+		return fmt.Errorf("%s not granted", strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+// missing returns the required values that are not in the actual ones
+func missing(required, actual []string) []string {
+	have := map[string]bool{}
+	for _, value := range actual {
+		have[value] = true
+	}
+	var missing []string
+	for _, value := range required {
+		if !have[value] {
+			missing = append(missing, value)
+		}
+	}
+	return missing
+}
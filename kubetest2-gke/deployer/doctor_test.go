@@ -0,0 +1,131 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package deployer
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"sigs.k8s.io/kubetest2/kubetest2-gke/deployer/options"
+)
+
+func TestVersionAtLeast(t *testing.T) {
+	testCases := []struct {
+		version     string
+		expected    bool
+		expectError bool
+	}{
+		{version: "400.0.0", expected: true},
+		{version: "456.0.1", expected: true},
+		{version: "399.12.3", expected: false},
+		{version: "2023.09.15", expected: true},
+		{version: "", expectError: true},
+		{version: "400.0", expectError: true},
+	}
+	for _, tc := range testCases {
+		actual, err := versionAtLeast(tc.version, minGcloudVersion)
+		if (err != nil) != tc.expectError {
+			t.Errorf("%q: expected error: %v, but got: %v", tc.version, tc.expectError, err)
+		}
+		if actual != tc.expected {
+			t.Errorf("%q: expected %v, got %v", tc.version, tc.expected, actual)
+		}
+	}
+}
+
+func TestMissing(t *testing.T) {
+	actual := missing([]string{"compute.googleapis.com", "container.googleapis.com", "logging.googleapis.com"}, []string{"container.googleapis.com"})
+	expected := []string{"compute.googleapis.com", "logging.googleapis.com"}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("expected %v, got %v", expected, actual)
+	}
+	if actual := missing([]string{"compute.googleapis.com"}, []string{"compute.googleapis.com"}); actual != nil {
+		t.Errorf("expected nothing missing, got %v", actual)
+	}
+}
+
+func TestRequiredPermissions(t *testing.T) {
+	d := &Deployer{ClusterOptions: &options.ClusterOptions{}, NetworkOptions: &options.NetworkOptions{Network: "default", SkipFirewallRules: true}}
+	for _, permission := range d.requiredPermissions() {
+		if permission == "compute.networks.create" || permission == "compute.firewalls.create" {
+			t.Errorf("did not expect %s for the default network without firewall rules", permission)
+		}
+	}
+	d.Network, d.SkipFirewallRules = "e2e", false
+	required := map[string]bool{}
+	for _, permission := range d.requiredPermissions() {
+		required[permission] = true
+	}
+	if !required["compute.networks.create"] || !required["compute.firewalls.create"] || !required["container.clusters.create"] {
+		t.Errorf("expected the network, firewall and cluster permissions, got %v", d.requiredPermissions())
+	}
+}
+
+func TestServiceAccountKeyAccount(t *testing.T) {
+	dir, err := ioutil.TempDir("", "doctor")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(rsaKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	privateKey := string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}))
+	writeKey := func(name string, key map[string]string) string {
+		contents, err := json.Marshal(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		path := filepath.Join(dir, name)
+		if err := ioutil.WriteFile(path, contents, 0600); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	valid := writeKey("valid.json", map[string]string{"type": "service_account", "client_email": "sa@p.iam.gserviceaccount.com", "private_key": privateKey})
+	path, account, err := serviceAccountKeyAccount(valid)
+	if err != nil {
+		t.Fatalf("did not expect an error, but got: %v", err)
+	}
+	if path != valid || account != "sa@p.iam.gserviceaccount.com" {
+		t.Errorf("unexpected key file %q and account %q", path, account)
+	}
+
+	for _, key := range []string{
+		writeKey("user.json", map[string]string{"type": "authorized_user", "client_email": "u@example.com", "private_key": privateKey}),
+		writeKey("truncated.json", map[string]string{"type": "service_account", "client_email": "sa@p.iam.gserviceaccount.com", "private_key": privateKey[:64]}),
+		filepath.Join(dir, "missing.json"),
+	} {
+		if _, _, err := serviceAccountKeyAccount(key); err == nil {
+			t.Errorf("expected an error for the key %s", key)
+		}
+	}
+}
@@ -22,7 +22,6 @@ import (
 
 	"k8s.io/klog"
 
-	"sigs.k8s.io/kubetest2/pkg/boskos"
 	"sigs.k8s.io/kubetest2/pkg/exec"
 )
 
@@ -35,21 +34,67 @@ func (d *Deployer) Down() error {
 	if len(d.Projects) == 0 {
 		return nil
 	}
+	defer d.finishCostEstimate()
+	d.stopAlphaClusterWatchdog()
+	// pulled before the clusters are deleted, as they are looked up by their creation time
+	if d.ControlPlaneLogs {
+		if err := d.DumpControlPlaneLogs(); err != nil {
+			klog.Warningf("Failed to dump the control plane logs: %v", err)
+		}
+	}
+	// memberships outlive the clusters, and may be in a project that is not
+	// cleaned up by the boskos janitor
+	d.UnregisterFleetMemberships()
 
 	// If the GCP projects are acquired from Boskos, release the projects and
-	// rely on boskos-janitor to do clean-ups for them.
-	if d.totalBoskosProjectsRequested > 0 {
-		return boskos.Release(d.boskos, d.Projects, d.boskosHeartbeatClose)
+	// rely on boskos-janitor to clean up the clusters and the networks.
+	// The janitor does not clean up the firewall rules soon enough though, so
+	// the ones created for the run are deleted here, and checked for leaks
+	// while the projects are still leased, as leaked rules quickly exhaust
+	// the project quota.
+	// The projects kept for the next invocation sharing the lease file are
+	// not cleaned up by the janitor until then, so they are cleaned up below.
+	if d.projectLease.Leased() && !d.projectLease.Kept() {
+		if err := d.firewalls.Cleanup(); err != nil {
+			klog.Errorf("Error cleaning-up firewall rules: %v", err)
+		}
+		leakErr := d.checkLeakedResources("firewall-rule")
+		if err := d.projectLease.Release(); err != nil {
+			return err
+		}
+		return leakErr
 	}
 
 	d.DeleteClusters(d.retryCount)
+	// check for leaks once everything else is deleted, even if it failed,
+	// before the kept projects are handed over
+	if err := d.deleteNetworkResources(); err != nil {
+		if leakErr := d.CheckLeakedResources(); leakErr != nil {
+			klog.Errorf("%v", leakErr)
+		}
+		return err
+	}
+	if err := d.CheckLeakedResources(); err != nil {
+		return err
+	}
 
+	// hands the kept projects over to the next invocation
+	return d.projectLease.Release()
+}
+
+// deleteNetworkResources deletes the firewall rules, subnets and network of the run
+func (d *Deployer) deleteNetworkResources() error {
 	numDeletedFWRules, errCleanFirewalls := d.CleanupNetworkFirewalls(d.Projects[0], d.Network)
 	if errCleanFirewalls != nil {
 		klog.Errorf("Error cleaning-up firewall rules: %v", errCleanFirewalls)
 	} else {
 		klog.V(1).Infof("Deleted %d network firewall rules", numDeletedFWRules)
 	}
+	// Verify that all the firewall rules created for the run are gone,
+	// including the ones in the default network.
+	if err := d.firewalls.Cleanup(); err != nil {
+		return err
+	}
 
 	if err := d.TeardownNetwork(); err != nil {
 		return err
@@ -57,11 +102,7 @@ func (d *Deployer) Down() error {
 	if err := d.DeleteSubnets(d.retryCount); err != nil {
 		return err
 	}
-	if err := d.DeleteNetwork(); err != nil {
-		return err
-	}
-
-	return nil
+	return d.DeleteNetwork()
 }
 
 func (d *Deployer) DeleteClusters(retryCount int) {
@@ -71,7 +112,7 @@ func (d *Deployer) DeleteClusters(retryCount int) {
 		project := d.Projects[i]
 		for j := range d.projectClustersLayout[project] {
 			cluster := d.projectClustersLayout[project][j]
-			loc := locationFlag(d.Regions, d.Zones, retryCount)
+			loc := d.clusterLocationFlag(cluster.name, retryCount)
 
 			wg.Add(1)
 			go func() {
@@ -64,14 +64,20 @@ export KUBE_NODE_OS_DISTRIBUTION='%[3]s'
 			return fmt.Errorf("%q or %q contain single quotes - nice try", d.localLogsDir, d.gcsLogsDir)
 		}
 
-		// Generate a slice of filters to be OR'd together below
-		var filters []string
+		// Generate the slices of filters to be OR'd together below, per zone
+		// of the instance groups as log-dump.sh dumps the nodes of a zone,
+		// which differ between the clusters of different --cluster-spec locations
+		filters := map[string][]string{}
+		var zones []string
 		for _, cluster := range d.projectClustersLayout[project] {
 			if err := d.GetInstanceGroups(); err != nil {
 				return err
 			}
 			for _, ig := range d.instanceGroups[project][cluster.name] {
-				filters = append(filters, fmt.Sprintf("(metadata.created-by:*%s)", ig.path))
+				if _, ok := filters[ig.zone]; !ok {
+					zones = append(zones, ig.zone)
+				}
+				filters[ig.zone] = append(filters[ig.zone], fmt.Sprintf("(metadata.created-by:*%s)", ig.path))
 			}
 		}
 
@@ -80,15 +86,17 @@ export KUBE_NODE_OS_DISTRIBUTION='%[3]s'
 		if d.gcsLogsDir != "" {
 			dumpCmd += " " + d.gcsLogsDir
 		}
-		cmd := exec.Command("bash", "-c", fmt.Sprintf(gkeLogDumpTemplate,
-			project,
-			d.Zones[d.retryCount],
-			os.Getenv("NODE_OS_DISTRIBUTION"),
-			strings.Join(filters, " OR "),
-			dumpCmd))
-		cmd.SetDir(d.RepoRoot)
-		if err := runWithOutput(cmd); err != nil {
-			return err
+		for _, zone := range zones {
+			cmd := exec.Command("bash", "-c", fmt.Sprintf(gkeLogDumpTemplate,
+				project,
+				zone,
+				os.Getenv("NODE_OS_DISTRIBUTION"),
+				strings.Join(filters[zone], " OR "),
+				dumpCmd))
+			cmd.SetDir(d.RepoRoot)
+			if err := runWithOutput(cmd); err != nil {
+				return err
+			}
 		}
 	}
 
@@ -0,0 +1,91 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package deployer
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"sigs.k8s.io/kubetest2/pkg/types"
+)
+
+var _ types.DeployerWithFeatureGates = &Deployer{}
+
+// SetFeatureGates translates the runtime config to the beta APIs enabled with
+// --enable-kubernetes-unstable-apis, which is all GKE supports: the feature
+// gates of the components and the other APIs cannot be set
+func (d *Deployer) SetFeatureGates(featureGates map[string]bool, runtimeConfig map[string]string) error {
+	if err := d.verifyAlphaFeatureGates(featureGates); err != nil {
+		return err
+	}
+	apis, err := unstableAPIs(runtimeConfig, d.EnableKubernetesAlpha)
+	if err != nil {
+		return err
+	}
+	d.unstableAPIs = apis
+	return nil
+}
+
+// verifyAlphaFeatureGates only accepts enabling feature gates on alpha
+// clusters, whose components have all the feature gates enabled already
+func (d *Deployer) verifyAlphaFeatureGates(featureGates map[string]bool) error {
+	if len(featureGates) == 0 {
+		return nil
+	}
+	if !d.EnableKubernetesAlpha {
+		return fmt.Errorf("GKE does not support setting the feature gates of the components, but --enable-kubernetes-alpha enables all of them")
+	}
+	for name, enabled := range featureGates {
+		if !enabled {
+			return fmt.Errorf("GKE does not support disabling the feature gate %s of the alpha clusters", name)
+		}
+	}
+	return nil
+}
+
+// unstableAPIs returns the beta APIs enabled by the runtime config, which
+// GKE only supports enabling by group/version/resource. The alpha APIs are
+// all enabled on alpha clusters, so they are skipped there.
+func unstableAPIs(runtimeConfig map[string]string, alpha bool) ([]string, error) {
+	var apis []string
+	for key, value := range runtimeConfig {
+		parts := strings.Split(key, "/")
+		// group/version or group/version/resource
+		if alpha && len(parts) >= 2 && strings.Contains(parts[1], "alpha") {
+			if value != "true" {
+				return nil, fmt.Errorf("GKE does not support disabling the alpha API %s of the alpha clusters", key)
+			}
+			continue
+		}
+		if len(parts) != 3 || parts[0] == "" || !strings.Contains(parts[1], "beta") || parts[2] == "" || value != "true" {
+			return nil, fmt.Errorf("GKE only supports enabling beta APIs by group/version/resource e.g. "+
+				"storage.k8s.io/v1beta1/csistoragecapacities=true with --runtime-config, got %s=%s", key, value)
+		}
+		apis = append(apis, key)
+	}
+	sort.Strings(apis)
+	return apis, nil
+}
+
+// unstableAPIsArgs returns the flags enabling the beta APIs of the --runtime-config
+func (d *Deployer) unstableAPIsArgs() []string {
+	if len(d.unstableAPIs) == 0 {
+		return nil
+	}
+	return []string{"--enable-kubernetes-unstable-apis=" + strings.Join(d.unstableAPIs, ",")}
+}
@@ -0,0 +1,122 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package deployer
+
+import (
+	"reflect"
+	"testing"
+
+	"sigs.k8s.io/kubetest2/kubetest2-gke/deployer/options"
+)
+
+func TestUnstableAPIs(t *testing.T) {
+	testCases := []struct {
+		name          string
+		runtimeConfig map[string]string
+		alpha         bool
+		expected      []string
+		expectError   bool
+	}{
+		{
+			name: "none",
+		},
+		{
+			name: "beta resources",
+			runtimeConfig: map[string]string{
+				"storage.k8s.io/v1beta1/csistoragecapacities":                      "true",
+				"admissionregistration.k8s.io/v1beta1/validatingadmissionpolicies": "true",
+			},
+			expected: []string{
+				"admissionregistration.k8s.io/v1beta1/validatingadmissionpolicies",
+				"storage.k8s.io/v1beta1/csistoragecapacities",
+			},
+		},
+		{
+			name:          "whole group version",
+			runtimeConfig: map[string]string{"storage.k8s.io/v1beta1": "true"},
+			expectError:   true,
+		},
+		{
+			name:          "all the APIs",
+			runtimeConfig: map[string]string{"api/all": "true"},
+			expectError:   true,
+		},
+		{
+			name:          "alpha resource",
+			runtimeConfig: map[string]string{"resource.k8s.io/v1alpha2/resourceclaims": "true"},
+			expectError:   true,
+		},
+		{
+			name: "alpha resource of alpha clusters",
+			runtimeConfig: map[string]string{
+				"resource.k8s.io/v1alpha2/resourceclaims":     "true",
+				"storage.k8s.io/v1beta1/csistoragecapacities": "true",
+			},
+			alpha:    true,
+			expected: []string{"storage.k8s.io/v1beta1/csistoragecapacities"},
+		},
+		{
+			name:          "alpha group version of alpha clusters",
+			runtimeConfig: map[string]string{"resource.k8s.io/v1alpha2": "true"},
+			alpha:         true,
+		},
+		{
+			name:          "disabled alpha resource of alpha clusters",
+			runtimeConfig: map[string]string{"resource.k8s.io/v1alpha2/resourceclaims": "false"},
+			alpha:         true,
+			expectError:   true,
+		},
+		{
+			name:          "disabled",
+			runtimeConfig: map[string]string{"storage.k8s.io/v1beta1/csistoragecapacities": "false"},
+			expectError:   true,
+		},
+	}
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			apis, err := unstableAPIs(tc.runtimeConfig, tc.alpha)
+			if (err != nil) != tc.expectError {
+				t.Fatalf("expected error: %v, but got: %v", tc.expectError, err)
+			}
+			if !reflect.DeepEqual(apis, tc.expected) {
+				t.Errorf("expected %v, got %v", tc.expected, apis)
+			}
+		})
+	}
+}
+
+func TestSetFeatureGates(t *testing.T) {
+	d := &Deployer{ClusterOptions: &options.ClusterOptions{}}
+	if err := d.SetFeatureGates(map[string]bool{"InPlacePodVerticalScaling": true}, nil); err == nil {
+		t.Errorf("expected an error setting feature gates")
+	}
+	d.EnableKubernetesAlpha = true
+	if err := d.SetFeatureGates(map[string]bool{"InPlacePodVerticalScaling": false}, nil); err == nil {
+		t.Errorf("expected an error disabling feature gates of alpha clusters")
+	}
+	if err := d.SetFeatureGates(map[string]bool{"InPlacePodVerticalScaling": true}, nil); err != nil {
+		t.Errorf("did not expect an error enabling feature gates of alpha clusters, but got: %v", err)
+	}
+	if err := d.SetFeatureGates(nil, map[string]string{"storage.k8s.io/v1beta1/csistoragecapacities": "true"}); err != nil {
+		t.Fatalf("did not expect an error, but got: %v", err)
+	}
+	expected := []string{"--enable-kubernetes-unstable-apis=storage.k8s.io/v1beta1/csistoragecapacities"}
+	if args := d.unstableAPIsArgs(); !reflect.DeepEqual(args, expected) {
+		t.Errorf("expected %v, got %v", expected, args)
+	}
+}
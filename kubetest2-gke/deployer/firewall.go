@@ -25,6 +25,7 @@ import (
 	"k8s.io/klog"
 
 	"sigs.k8s.io/kubetest2/pkg/exec"
+	"sigs.k8s.io/kubetest2/pkg/firewall"
 )
 
 func (d *Deployer) EnsureFirewallRules() error {
@@ -32,6 +33,10 @@ func (d *Deployer) EnsureFirewallRules() error {
 	if d.Network == "default" {
 		return nil
 	}
+	if d.firewalls.Skipped() {
+		klog.V(1).Info("--skip-firewall-rules is set, not creating firewall rules for e2e testing")
+		return nil
+	}
 
 	if len(d.Projects) == 1 {
 		return d.ensureFirewallRulesForSingleProject()
@@ -46,21 +51,19 @@ func (d *Deployer) ensureFirewallRulesForSingleProject() error {
 	for _, cluster := range d.projectClustersLayout[project] {
 		clusterName := cluster.name
 		klog.V(1).Infof("Ensuring firewall rules for cluster %s in %s", clusterName, project)
-		firewall := clusterFirewallName(project, clusterName, d.instanceGroups)
-		if runWithNoOutput(exec.Command("gcloud", "compute", "firewall-rules", "describe", firewall,
-			"--project="+project,
-			"--format=value(name)")) == nil {
+		rule := firewall.Rule{
+			Name:        clusterFirewallName(project, clusterName, d.instanceGroups),
+			Project:     project,
+			Network:     d.Network,
+			Allow:       d.FirewallRuleAllow,
+			Description: d.firewallRuleDescription(),
+		}
+		if firewall.Exists(rule.Project, rule.Name) {
 			// Assume that if this unique firewall exists, it's good to go.
 			continue
 		}
-		klog.V(1).Infof("Couldn't describe firewall '%s', assuming it doesn't exist and creating it", firewall)
+		klog.V(1).Infof("Couldn't describe firewall '%s', assuming it doesn't exist and creating it", rule.Name)
 
-		firewallRulesCreateCmd := []string{
-			"gcloud", "compute", "firewall-rules", "create", firewall,
-			"--project=" + project,
-			"--network=" + d.Network,
-			"--allow=" + d.FirewallRuleAllow,
-		}
 		if !d.Autopilot {
 			tagOut, err := exec.Output(exec.Command("gcloud", "compute", "instances", "list",
 				"--project="+project,
@@ -74,11 +77,11 @@ func (d *Deployer) ensureFirewallRulesForSingleProject() error {
 			if tag == "" {
 				return fmt.Errorf("instances list returned no instances (or instance has no tags)")
 			}
-			firewallRulesCreateCmd = append(firewallRulesCreateCmd, "--target-tags="+tag)
+			rule.TargetTags = []string{tag}
 		}
 
-		if err := runWithOutput(exec.Command(firewallRulesCreateCmd[0], firewallRulesCreateCmd[1:]...)); err != nil {
-			return fmt.Errorf("error creating firewall rule: %v", err)
+		if err := d.firewalls.Create(rule); err != nil {
+			return err
 		}
 	}
 	return nil
@@ -107,15 +110,17 @@ func (d *Deployer) ensureFirewallRulesForMultiProjects() error {
 		if err != nil {
 			return fmt.Errorf("error looking up project number for id %q: %w", curtProject, err)
 		}
-		firewall := fmt.Sprintf("rule-%s-%s", hostProjectNumber, curtProjectNumber)
-		// sourceRanges need to be separated with ",", while the provided subnetworkRanges are separated with space.
-		sourceRanges := strings.ReplaceAll(d.SubnetworkRanges[i-1], " ", ",")
-		if err := runWithOutput(exec.Command("gcloud", "compute", "firewall-rules", "create", firewall,
-			"--project="+hostProject,
-			"--network="+d.Network,
-			"--allow="+d.FirewallRuleAllow,
-			"--direction=INGRESS",
-			"--source-ranges="+sourceRanges)); err != nil {
+		name := fmt.Sprintf("rule-%s-%s", hostProjectNumber, curtProjectNumber)
+		if err := d.firewalls.Create(firewall.Rule{
+			Name:      name,
+			Project:   hostProject,
+			Network:   d.Network,
+			Allow:     d.FirewallRuleAllow,
+			Direction: "INGRESS",
+			// the provided subnetworkRanges are separated with space
+			SourceRanges: strings.Split(d.SubnetworkRanges[i-1], " "),
+			Description:  d.firewallRuleDescription(),
+		}); err != nil {
 			return fmt.Errorf("error creating firewall rule for project %q: %v", curtProject, err)
 		}
 	}
@@ -128,6 +133,10 @@ func (d *Deployer) CleanupNetworkFirewalls(hostProject, network string) (int, er
 	if network == "default" {
 		return 0, nil
 	}
+	// Do not touch firewall rules that kubetest2 is not managing.
+	if d.firewalls.Skipped() {
+		return 0, nil
+	}
 
 	klog.V(1).Infof("Cleaning up network firewall rules for network %s in %s", network, hostProject)
 	fws, err := exec.Output(exec.Command("gcloud", "compute", "firewall-rules", "list",
@@ -156,6 +165,8 @@ func (d *Deployer) CleanupNetworkFirewalls(hostProject, network string) (int, er
 }
 
 func (d *Deployer) GetInstanceGroups() error {
+	d.instanceGroupsMu.Lock()
+	defer d.instanceGroupsMu.Unlock()
 	// If instanceGroups has already been populated, return directly.
 	if d.instanceGroups != nil {
 		return nil
@@ -164,13 +175,12 @@ func (d *Deployer) GetInstanceGroups() error {
 	// Initialize project instance groups structure
 	d.instanceGroups = map[string]map[string][]*ig{}
 
-	location := locationFlag(d.Regions, d.Zones, d.retryCount)
-
 	for _, project := range d.Projects {
 		d.instanceGroups[project] = map[string][]*ig{}
 
 		for _, cluster := range d.projectClustersLayout[project] {
 			clusterName := cluster.name
+			location := d.clusterLocationFlag(clusterName, d.retryCount)
 
 			igs, err := exec.Output(exec.Command("gcloud", containerArgs("clusters", "describe", clusterName,
 				"--format=value(instanceGroupUrls)",
@@ -0,0 +1,178 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package deployer
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"golang.org/x/sync/errgroup"
+	"k8s.io/klog"
+
+	"sigs.k8s.io/kubetest2/pkg/exec"
+)
+
+const (
+	// fleet memberships are global resources
+	membershipLocation = "global"
+	// membershipReadyTimeout is how long to wait for the memberships to become ready
+	membershipReadyTimeout = 10 * time.Minute
+)
+
+// verifyFleetFlags validates the fleet flags
+func (d *Deployer) verifyFleetFlags() error {
+	if !d.FleetEnabled {
+		if d.MultiClusterServicesEnabled || d.MultiClusterIngressEnabled || d.FleetProject != "" {
+			return fmt.Errorf("--fleet-project, --enable-multi-cluster-services and --enable-multi-cluster-ingress require --enable-fleet")
+		}
+		return nil
+	}
+	// Autopilot clusters always have workload identity enabled
+	if (d.MultiClusterServicesEnabled || d.MultiClusterIngressEnabled) && !d.WorkloadIdentityEnabled && !d.Autopilot {
+		return fmt.Errorf("--enable-multi-cluster-services and --enable-multi-cluster-ingress require --enable-workload-identity")
+	}
+	return nil
+}
+
+// fleetProject returns the fleet host project
+func (d *Deployer) fleetProject() string {
+	if d.FleetProject != "" {
+		return d.FleetProject
+	}
+	return d.Projects[0]
+}
+
+// gkeURI returns the resource URI of the cluster used to register it,
+// which unlike --gke-cluster also works for clusters in other projects than the fleet
+func gkeURI(project, location, cluster string) string {
+	return fmt.Sprintf("https://container.googleapis.com/v1/projects/%s/locations/%s/clusters/%s", project, location, cluster)
+}
+
+// membershipName returns the name of the fleet membership of the cluster
+func membershipName(cluster string) string {
+	return cluster
+}
+
+func registerMembershipArgs(fleetProject, project, location, cluster string) []string {
+	return []string{
+		"container", "fleet", "memberships", "register", membershipName(cluster),
+		"--project=" + fleetProject,
+		"--gke-uri=" + gkeURI(project, location, cluster),
+		"--enable-workload-identity",
+		"--quiet",
+	}
+}
+
+// SetupFleet registers the clusters to the fleet, enables the multi-cluster
+// features and waits for the memberships to be ready
+func (d *Deployer) SetupFleet() error {
+	if !d.FleetEnabled {
+		return nil
+	}
+	fleetProject := d.fleetProject()
+	apis := []string{"gkehub.googleapis.com"}
+	if d.MultiClusterServicesEnabled {
+		apis = append(apis, "multiclusterservicediscovery.googleapis.com", "trafficdirector.googleapis.com", "cloudresourcemanager.googleapis.com")
+	}
+	if d.MultiClusterIngressEnabled {
+		apis = append(apis, "multiclusteringress.googleapis.com")
+	}
+	if err := runWithOutput(exec.Command("gcloud", append([]string{"services", "enable", "--project=" + fleetProject}, apis...)...)); err != nil {
+		return fmt.Errorf("error enabling the fleet APIs in project %s: %w", fleetProject, err)
+	}
+
+	eg := new(errgroup.Group)
+	for _, project := range d.Projects {
+		project := project
+		for _, cluster := range d.projectClustersLayout[project] {
+			cluster := cluster
+			eg.Go(func() error {
+				location := d.clusterLocation(cluster.name, d.retryCount)
+				if err := runWithOutput(exec.Command("gcloud", registerMembershipArgs(fleetProject, project, location, cluster.name)...)); err != nil {
+					return fmt.Errorf("error registering cluster %s to the fleet: %w", cluster.name, err)
+				}
+				return nil
+			})
+		}
+	}
+	if err := eg.Wait(); err != nil {
+		return err
+	}
+
+	if d.MultiClusterServicesEnabled {
+		if err := runWithOutput(exec.Command("gcloud", "container", "fleet", "multi-cluster-services", "enable",
+			"--project="+fleetProject)); err != nil {
+			return fmt.Errorf("error enabling multi-cluster services: %w", err)
+		}
+	}
+	if d.MultiClusterIngressEnabled {
+		configCluster := d.projectClustersLayout[d.Projects[0]][0].name
+		if err := runWithOutput(exec.Command("gcloud", "container", "fleet", "ingress", "enable",
+			"--project="+fleetProject,
+			fmt.Sprintf("--config-membership=projects/%s/locations/%s/memberships/%s", fleetProject, membershipLocation, membershipName(configCluster)),
+			"--quiet")); err != nil {
+			return fmt.Errorf("error enabling multi-cluster ingress: %w", err)
+		}
+	}
+	return d.waitForMemberships(fleetProject)
+}
+
+// waitForMemberships waits for the memberships of all the clusters to be ready
+func (d *Deployer) waitForMemberships(fleetProject string) error {
+	deadline := time.Now().Add(membershipReadyTimeout)
+	for _, project := range d.Projects {
+		for _, cluster := range d.projectClustersLayout[project] {
+			name := membershipName(cluster.name)
+			for {
+				state, err := exec.Output(exec.Command("gcloud", "container", "fleet", "memberships", "describe", name,
+					"--project="+fleetProject,
+					"--format=value(state.code)"))
+				if err != nil {
+					return fmt.Errorf("error describing membership %s: %s", name, execError(err))
+				}
+				if code := strings.TrimSpace(string(state)); code == "READY" {
+					klog.V(1).Infof("Membership %s is ready", name)
+					break
+				} else if time.Now().After(deadline) {
+					return fmt.Errorf("membership %s is not ready after %s, state: %q", name, membershipReadyTimeout, code)
+				}
+				time.Sleep(15 * time.Second)
+			}
+		}
+	}
+	return nil
+}
+
+// UnregisterFleetMemberships best-effort unregisters the clusters from the fleet
+func (d *Deployer) UnregisterFleetMemberships() {
+	if !d.FleetEnabled {
+		return
+	}
+	fleetProject := d.fleetProject()
+	for _, project := range d.Projects {
+		for _, cluster := range d.projectClustersLayout[project] {
+			location := d.clusterLocation(cluster.name, d.retryCount)
+			if err := runWithOutput(exec.Command("gcloud", "container", "fleet", "memberships", "unregister", membershipName(cluster.name),
+				"--project="+fleetProject,
+				"--gke-uri="+gkeURI(project, location, cluster.name),
+				"--quiet")); err != nil {
+				klog.Warningf("Error unregistering cluster %s from the fleet: %v", cluster.name, err)
+			}
+		}
+	}
+}
@@ -0,0 +1,78 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package deployer
+
+import (
+	"reflect"
+	"testing"
+
+	"sigs.k8s.io/kubetest2/kubetest2-gke/deployer/options"
+)
+
+func TestRegisterMembershipArgs(t *testing.T) {
+	expected := []string{
+		"container", "fleet", "memberships", "register", "cluster1",
+		"--project=fleet-project",
+		"--gke-uri=https://container.googleapis.com/v1/projects/project1/locations/us-central1-c/clusters/cluster1",
+		"--enable-workload-identity",
+		"--quiet",
+	}
+	actual := registerMembershipArgs("fleet-project", "project1", "us-central1-c", "cluster1")
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("expected args %v, but got %v", expected, actual)
+	}
+}
+
+func TestVerifyFleetFlags(t *testing.T) {
+	testCases := []struct {
+		name           string
+		clusterOptions options.ClusterOptions
+		expectError    bool
+	}{
+		{
+			name: "fleet disabled",
+		},
+		{
+			name:           "multi-cluster services without fleet",
+			clusterOptions: options.ClusterOptions{MultiClusterServicesEnabled: true, WorkloadIdentityEnabled: true},
+			expectError:    true,
+		},
+		{
+			name:           "multi-cluster services without workload identity",
+			clusterOptions: options.ClusterOptions{FleetEnabled: true, MultiClusterServicesEnabled: true},
+			expectError:    true,
+		},
+		{
+			name:           "multi-cluster services with workload identity",
+			clusterOptions: options.ClusterOptions{FleetEnabled: true, MultiClusterServicesEnabled: true, WorkloadIdentityEnabled: true},
+		},
+		{
+			name:           "multi-cluster ingress on autopilot",
+			clusterOptions: options.ClusterOptions{FleetEnabled: true, MultiClusterIngressEnabled: true, Autopilot: true},
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			d := &Deployer{ClusterOptions: &tc.clusterOptions}
+			if err := d.verifyFleetFlags(); (err != nil) != tc.expectError {
+				t.Errorf("expected error: %v, but got: %v", tc.expectError, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,203 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package deployer
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"k8s.io/klog"
+
+	"sigs.k8s.io/kubetest2/pkg/exec"
+)
+
+const (
+	acceleratorNodePoolName = "accelerator-pool"
+
+	// https://cloud.google.com/kubernetes-engine/docs/how-to/gpus#installing_drivers
+	// pinned rather than from master, so that the runs do not change with the upstream manifests
+	gpuDriverInstallerRef    = "v1.0.15"
+	cosGPUDriverInstaller    = "https://raw.githubusercontent.com/GoogleCloudPlatform/container-engine-accelerators/" + gpuDriverInstallerRef + "/nvidia-driver-installer/cos/daemonset-preloaded.yaml"
+	ubuntuGPUDriverInstaller = "https://raw.githubusercontent.com/GoogleCloudPlatform/container-engine-accelerators/" + gpuDriverInstallerRef + "/nvidia-driver-installer/ubuntu/daemonset-preloaded.yaml"
+
+	gpuResourceName = "nvidia.com/gpu"
+	gpuWaitTimeout  = 15 * time.Minute
+	gpuPollInterval = 15 * time.Second
+)
+
+// parseAccelerator parses an accelerator spec of the form type=TYPE[,count=COUNT]
+func parseAccelerator(spec string) (acceleratorType string, count int, err error) {
+	count = 1
+	for _, field := range strings.Split(spec, ",") {
+		kv := strings.SplitN(field, "=", 2)
+		if len(kv) != 2 {
+			return "", 0, fmt.Errorf("invalid accelerator %q, expected type=TYPE,count=COUNT", spec)
+		}
+		switch kv[0] {
+		case "type":
+			acceleratorType = kv[1]
+		case "count":
+			if count, err = strconv.Atoi(kv[1]); err != nil || count < 1 {
+				return "", 0, fmt.Errorf("invalid accelerator count %q", kv[1])
+			}
+		}
+	}
+	if acceleratorType == "" {
+		return "", 0, fmt.Errorf("invalid accelerator %q, the type is required", spec)
+	}
+	return acceleratorType, count, nil
+}
+
+// gpuQuotaMetric returns the regional quota metric of a GPU type
+// e.g. NVIDIA_T4_GPUS for nvidia-tesla-t4
+func gpuQuotaMetric(acceleratorType string) string {
+	name := strings.TrimPrefix(strings.TrimPrefix(acceleratorType, "nvidia-tesla-"), "nvidia-")
+	return "NVIDIA_" + strings.ToUpper(strings.ReplaceAll(name, "-", "_")) + "_GPUS"
+}
+
+func (d *Deployer) verifyAcceleratorFlags() error {
+	if d.Accelerator == "" {
+		return nil
+	}
+	if d.Autopilot {
+		return fmt.Errorf("--accelerator is not supported with --autopilot")
+	}
+	if _, _, err := parseAccelerator(d.Accelerator); err != nil {
+		return err
+	}
+	if d.AcceleratorNumNodes <= 0 {
+		return fmt.Errorf("--accelerator-num-nodes must be larger than 0")
+	}
+	return nil
+}
+
+func (d *Deployer) createAcceleratorNodePoolCommand(project string, cluster cluster, locationArg, nodePoolName string) []string {
+	fs := make([]string, 0)
+	fs = append(fs, "container", "node-pools", "create", nodePoolName)
+	fs = append(fs, "--quiet")
+	fs = append(fs, "--cluster="+cluster.name)
+	fs = append(fs, "--project="+project)
+	fs = append(fs, locationArg)
+	fs = append(fs, "--accelerator="+d.Accelerator)
+	if d.AcceleratorMachineType != "" {
+		fs = append(fs, "--machine-type="+d.AcceleratorMachineType)
+	}
+	if d.ImageType != "" {
+		fs = append(fs, "--image-type="+d.ImageType)
+	}
+	fs = append(fs, "--num-nodes="+strconv.Itoa(d.AcceleratorNumNodes))
+	fs = append(fs, d.systemConfigArgs(nodePoolName)...)
+	fs = append(fs, d.autoscalingArgs(nodePoolName)...)
+	fs = append(fs, d.nodeManagementArgs()...)
+	fs = append(fs, d.resourceLabelsArgs()...)
+
+	return fs
+}
+
+// InstallGPUDrivers applies the NVIDIA driver installer to the clusters with an
+// accelerator node pool, and waits for the GPUs to be allocatable
+func (d *Deployer) InstallGPUDrivers() error {
+	if d.Accelerator == "" {
+		return nil
+	}
+	_, count, err := parseAccelerator(d.Accelerator)
+	if err != nil {
+		return err
+	}
+
+	installer := d.GPUDriverInstaller
+	if installer == "" {
+		installer = cosGPUDriverInstaller
+		if strings.HasPrefix(strings.ToUpper(d.ImageType), "UBUNTU") {
+			installer = ubuntuGPUDriverInstaller
+		}
+	}
+
+	for _, project := range d.Projects {
+		for _, cluster := range d.projectClustersLayout[project] {
+			kubeconfig := d.clusterKubeconfig(project, cluster)
+			klog.V(1).Infof("Installing the NVIDIA drivers in cluster %s from %s", cluster.name, installer)
+			if err := runWithOutput(exec.Command("kubectl", "--kubeconfig="+kubeconfig, "apply", "-f", installer)); err != nil {
+				return fmt.Errorf("error installing the GPU drivers in cluster %s: %v", cluster.name, err)
+			}
+			if err := waitForGPUs(kubeconfig, count*d.acceleratorNumNodes(cluster.name)); err != nil {
+				return fmt.Errorf("error waiting for the GPUs of cluster %s: %v", cluster.name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// acceleratorNumNodes returns the number of nodes of the accelerator node pool
+// of the cluster which can be expected to be up, i.e. in each zone of regional
+// clusters, and no more than the minimum of the autoscaled pools
+func (d *Deployer) acceleratorNumNodes(name string) int {
+	numNodes := d.AcceleratorNumNodes
+	if c, ok := d.autoscaling[acceleratorNodePoolName]; ok && c.minNodes < numNodes {
+		numNodes = c.minNodes
+	}
+	location := d.clusterLocation(name, d.retryCount)
+	if location != "" && location == locationRegion(location) {
+		numNodes *= defaultZonesPerRegion
+	}
+	return numNodes
+}
+
+// waitForGPUs waits for the nodes of the cluster to report at least expected allocatable GPUs
+func waitForGPUs(kubeconfig string, expected int) error {
+	deadline := time.Now().Add(gpuWaitTimeout)
+	for {
+		allocatable, err := allocatableGPUs(kubeconfig)
+		if err != nil {
+			klog.Warningf("Failed to get the allocatable GPUs: %v", err)
+		} else if allocatable >= expected {
+			klog.V(1).Infof("%d GPUs are allocatable", allocatable)
+			return nil
+		} else {
+			klog.V(1).Infof("%d of %d GPUs are allocatable, waiting", allocatable, expected)
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("timed out after %v waiting for %d allocatable GPUs", gpuWaitTimeout, expected)
+		}
+		time.Sleep(gpuPollInterval)
+	}
+}
+
+func allocatableGPUs(kubeconfig string) (int, error) {
+	jsonPath := fmt.Sprintf(`{.items[*].status.allocatable.%s}`, strings.ReplaceAll(gpuResourceName, ".", `\.`))
+	out, err := exec.Output(exec.Command("kubectl", "--kubeconfig="+kubeconfig,
+		"get", "nodes", "-o", "jsonpath="+jsonPath))
+	if err != nil {
+		return 0, err
+	}
+	return sumCounts(string(out))
+}
+
+// sumCounts sums a whitespace separated list of counts
+func sumCounts(s string) (int, error) {
+	total := 0
+	for _, field := range strings.Fields(s) {
+		n, err := strconv.Atoi(field)
+		if err != nil {
+			return 0, fmt.Errorf("invalid count %q: %v", field, err)
+		}
+		total += n
+	}
+	return total, nil
+}
@@ -0,0 +1,104 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package deployer
+
+import (
+	"testing"
+
+	"sigs.k8s.io/kubetest2/kubetest2-gke/deployer/options"
+)
+
+func TestParseAccelerator(t *testing.T) {
+	testCases := []struct {
+		spec          string
+		expectedType  string
+		expectedCount int
+		expectError   bool
+	}{
+		{spec: "type=nvidia-tesla-t4,count=2", expectedType: "nvidia-tesla-t4", expectedCount: 2},
+		{spec: "type=nvidia-tesla-a100", expectedType: "nvidia-tesla-a100", expectedCount: 1},
+		{spec: "count=1", expectError: true},
+		{spec: "type=nvidia-tesla-t4,count=zero", expectError: true},
+		{spec: "nvidia-tesla-t4", expectError: true},
+	}
+
+	for _, tc := range testCases {
+		acceleratorType, count, err := parseAccelerator(tc.spec)
+		if tc.expectError {
+			if err == nil {
+				t.Errorf("expected an error for %q", tc.spec)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("did not expect an error for %q, but got: %v", tc.spec, err)
+		}
+		if acceleratorType != tc.expectedType || count != tc.expectedCount {
+			t.Errorf("expected %s x%d for %q, but got %s x%d", tc.expectedType, tc.expectedCount, tc.spec, acceleratorType, count)
+		}
+	}
+}
+
+func TestGPUQuotaMetric(t *testing.T) {
+	testCases := map[string]string{
+		"nvidia-tesla-t4":       "NVIDIA_T4_GPUS",
+		"nvidia-tesla-a100":     "NVIDIA_A100_GPUS",
+		"nvidia-l4":             "NVIDIA_L4_GPUS",
+		"nvidia-tesla-p100-vws": "NVIDIA_P100_VWS_GPUS",
+	}
+	for acceleratorType, expected := range testCases {
+		if metric := gpuQuotaMetric(acceleratorType); metric != expected {
+			t.Errorf("expected metric %s for %s, but got %s", expected, acceleratorType, metric)
+		}
+	}
+}
+
+func TestSumCounts(t *testing.T) {
+	total, err := sumCounts("1 2  4\n")
+	if err != nil {
+		t.Fatalf("did not expect an error, but got: %v", err)
+	}
+	if total != 7 {
+		t.Errorf("expected 7, but got %d", total)
+	}
+	if _, err := sumCounts("1 x"); err == nil {
+		t.Errorf("expected an error for an invalid count")
+	}
+}
+
+func TestAcceleratorNumNodes(t *testing.T) {
+	d := &Deployer{ClusterOptions: &options.ClusterOptions{
+		AcceleratorNumNodes: 2,
+		ClusterSpecs:        []string{"name=c1,zone=us-central1-a", "name=c2,region=us-central1"},
+	}}
+	if err := d.applyClusterSpecs(); err != nil {
+		t.Fatal(err)
+	}
+	if n := d.acceleratorNumNodes("c1"); n != 2 {
+		t.Errorf("expected 2 nodes for the zonal cluster, but got %d", n)
+	}
+	if n := d.acceleratorNumNodes("c2"); n != 2*defaultZonesPerRegion {
+		t.Errorf("expected %d nodes for the regional cluster, but got %d", 2*defaultZonesPerRegion, n)
+	}
+
+	d.autoscaling = map[string]nodePoolAutoscaling{
+		acceleratorNodePoolName: {pool: acceleratorNodePoolName, minNodes: 1, maxNodes: 3},
+	}
+	if n := d.acceleratorNumNodes("c2"); n != defaultZonesPerRegion {
+		t.Errorf("expected %d nodes for the autoscaled regional cluster, but got %d", defaultZonesPerRegion, n)
+	}
+}
@@ -0,0 +1,156 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package deployer
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	"k8s.io/klog"
+
+	"sigs.k8s.io/kubetest2/pkg/exec"
+)
+
+// the values of --kubeconfig-auth
+const (
+	kubeconfigAuthToken              = "token"
+	kubeconfigAuthExec               = "exec"
+	kubeconfigAuthApplicationDefault = "application-default"
+)
+
+const (
+	gkeAuthPlugin            = "gke-gcloud-auth-plugin"
+	gkeAuthPluginInstallHint = "Install gke-gcloud-auth-plugin for use with kubectl by following https://cloud.google.com/blog/products/containers-kubernetes/kubectl-auth-changes-in-gke"
+)
+
+// kubeconfigAuth returns the validated --kubeconfig-auth, defaulting to exec
+// if the auth plugin is installed, or to "" to use get-credentials otherwise
+func (d *Deployer) kubeconfigAuth(lookPath func(string) (string, error)) (string, error) {
+	switch d.KubeconfigAuth {
+	case "":
+		if _, err := lookPath(gkeAuthPlugin); err != nil {
+			klog.Warningf("%s not found in PATH, falling back to gcloud container clusters get-credentials, "+
+				"the kubeconfigs may stop working if their access token expires during the run", gkeAuthPlugin)
+			return "", nil
+		}
+		return kubeconfigAuthExec, nil
+	case kubeconfigAuthToken:
+		return d.KubeconfigAuth, nil
+	case kubeconfigAuthExec, kubeconfigAuthApplicationDefault:
+		if _, err := lookPath(gkeAuthPlugin); err != nil {
+			return "", fmt.Errorf("--kubeconfig-auth=%s requires %s in PATH, install it with "+
+				"`gcloud components install %s` or use --kubeconfig-auth=%s", d.KubeconfigAuth, gkeAuthPlugin, gkeAuthPlugin, kubeconfigAuthToken)
+		}
+		return d.KubeconfigAuth, nil
+	default:
+		return "", fmt.Errorf("--kubeconfig-auth must be one of %s, %s or %s, found %q",
+			kubeconfigAuthToken, kubeconfigAuthExec, kubeconfigAuthApplicationDefault, d.KubeconfigAuth)
+	}
+}
+
+// clusterEndpoint is the part of the description of a cluster needed to connect to it
+type clusterEndpoint struct {
+	Endpoint   string `json:"endpoint"`
+	MasterAuth struct {
+		ClusterCACertificate string `json:"clusterCaCertificate"`
+	} `json:"masterAuth"`
+}
+
+// writeClusterKubeconfig writes a kubeconfig for the cluster authenticating with auth to path
+func writeClusterKubeconfig(path, project, loc, cluster, auth string) error {
+	out, err := exec.Output(exec.Command("gcloud",
+		containerArgs("clusters", "describe", cluster, "--project="+project, loc, "--format=json")...),
+	)
+	if err != nil {
+		return fmt.Errorf("error describing cluster %s: %s", cluster, execError(err))
+	}
+	endpoint := &clusterEndpoint{}
+	if err := json.Unmarshal(out, endpoint); err != nil {
+		return fmt.Errorf("error parsing the description of cluster %s: %v", cluster, err)
+	}
+
+	user := map[string]interface{}{}
+	if auth == kubeconfigAuthToken {
+		token, err := exec.Output(exec.Command("gcloud", "auth", "print-access-token"))
+		if err != nil {
+			return fmt.Errorf("error getting an access token: %s", execError(err))
+		}
+		user["token"] = strings.TrimSpace(string(token))
+	} else {
+		user["exec"] = authPluginConfig(auth)
+	}
+
+	// the same context name as gcloud container clusters get-credentials
+	location := loc[strings.Index(loc, "=")+1:]
+	name := fmt.Sprintf("gke_%s_%s_%s", project, location, cluster)
+	data, err := json.MarshalIndent(kubeconfig(name, endpoint, user), "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, data, 0600)
+}
+
+// authPluginConfig returns the exec credential config of the kubeconfig user
+func authPluginConfig(auth string) map[string]interface{} {
+	config := map[string]interface{}{
+		"apiVersion":         "client.authentication.k8s.io/v1beta1",
+		"command":            gkeAuthPlugin,
+		"installHint":        gkeAuthPluginInstallHint,
+		"provideClusterInfo": true,
+		"interactiveMode":    "Never",
+	}
+	if auth == kubeconfigAuthApplicationDefault {
+		config["args"] = []string{"--use_application_default_credentials"}
+	}
+	return config
+}
+
+// kubeconfig returns a kubeconfig with a single context, as JSON is valid YAML
+// it is marshalled as is
+func kubeconfig(name string, endpoint *clusterEndpoint, user map[string]interface{}) map[string]interface{} {
+	return map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "Config",
+		"clusters": []interface{}{
+			map[string]interface{}{
+				"name": name,
+				"cluster": map[string]interface{}{
+					"server":                     "https://" + endpoint.Endpoint,
+					"certificate-authority-data": endpoint.MasterAuth.ClusterCACertificate,
+				},
+			},
+		},
+		"users": []interface{}{
+			map[string]interface{}{
+				"name": name,
+				"user": user,
+			},
+		},
+		"contexts": []interface{}{
+			map[string]interface{}{
+				"name": name,
+				"context": map[string]interface{}{
+					"cluster": name,
+					"user":    name,
+				},
+			},
+		},
+		"current-context": name,
+	}
+}
@@ -0,0 +1,74 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package deployer
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"sigs.k8s.io/kubetest2/kubetest2-gke/deployer/options"
+)
+
+func TestKubeconfigAuth(t *testing.T) {
+	installed := func(string) (string, error) { return "/usr/bin/" + gkeAuthPlugin, nil }
+	missing := func(string) (string, error) { return "", errors.New("not found") }
+	testCases := []struct {
+		name        string
+		auth        string
+		lookPath    func(string) (string, error)
+		expected    string
+		expectError bool
+	}{
+		{name: "default with plugin", lookPath: installed, expected: kubeconfigAuthExec},
+		{name: "default without plugin", lookPath: missing, expected: ""},
+		{name: "token without plugin", auth: kubeconfigAuthToken, lookPath: missing, expected: kubeconfigAuthToken},
+		{name: "exec with plugin", auth: kubeconfigAuthExec, lookPath: installed, expected: kubeconfigAuthExec},
+		{name: "exec without plugin", auth: kubeconfigAuthExec, lookPath: missing, expectError: true},
+		{name: "application default without plugin", auth: kubeconfigAuthApplicationDefault, lookPath: missing, expectError: true},
+		{name: "invalid", auth: "password", lookPath: installed, expectError: true},
+	}
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			d := &Deployer{CommonOptions: &options.CommonOptions{KubeconfigAuth: tc.auth}}
+			actual, err := d.kubeconfigAuth(tc.lookPath)
+			if tc.expectError {
+				if err == nil {
+					t.Errorf("expected an error but got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Errorf("did not expect an error, but got: %v", err)
+			}
+			if actual != tc.expected {
+				t.Errorf("expected %q, but got %q", tc.expected, actual)
+			}
+		})
+	}
+}
+
+func TestAuthPluginConfig(t *testing.T) {
+	if _, ok := authPluginConfig(kubeconfigAuthExec)["args"]; ok {
+		t.Errorf("expected no args for the exec auth")
+	}
+	args := authPluginConfig(kubeconfigAuthApplicationDefault)["args"]
+	if expected := []string{"--use_application_default_credentials"}; !reflect.DeepEqual(args, expected) {
+		t.Errorf("expected args %v for the application default auth, but got %v", expected, args)
+	}
+}
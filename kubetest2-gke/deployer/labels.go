@@ -0,0 +1,64 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package deployer
+
+import (
+	"fmt"
+	"time"
+
+	"sigs.k8s.io/kubetest2/pkg/labels"
+)
+
+// defaultResourceLabelsExpiryHours is the default lifetime of the resources
+// created by the run in the kubetest2-expiry label
+const defaultResourceLabelsExpiryHours = 24
+
+// verifyResourceLabelsFlags validates --resource-labels and computes the labels
+// of the resources created by the run
+func (d *Deployer) verifyResourceLabelsFlags() error {
+	if d.ResourceLabelsExpiryHours < 0 {
+		return fmt.Errorf("--resource-labels-expiry-hours must not be negative")
+	}
+	overrides, err := labels.Parse(d.ResourceLabels)
+	if err != nil {
+		return fmt.Errorf("invalid --resource-labels: %w", err)
+	}
+	// the run id label is how the leaked resources of the run are found on down
+	if _, ok := overrides[labels.RunID]; ok {
+		return fmt.Errorf("the %s label cannot be set with --resource-labels", labels.RunID)
+	}
+	ttl := time.Duration(d.ResourceLabelsExpiryHours) * time.Hour
+	d.resourceLabels = labels.Merge(labels.ForRun(d.Kubetest2CommonOptions.RunID(), ttl, time.Now()), overrides)
+	return nil
+}
+
+// resourceLabelsArgs returns the gcloud flags labeling a cluster or node pool with the resource labels
+func (d *Deployer) resourceLabelsArgs() []string {
+	if len(d.resourceLabels) == 0 {
+		return nil
+	}
+	return []string{"--labels=" + labels.Format(d.resourceLabels)}
+}
+
+// firewallRuleDescription returns the description of the firewall rules created
+// by the run, which carries the resource labels as firewall rules do not support labels
+func (d *Deployer) firewallRuleDescription() string {
+	if len(d.resourceLabels) == 0 {
+		return ""
+	}
+	return "created by kubetest2, labels: " + labels.Format(d.resourceLabels)
+}
@@ -0,0 +1,75 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package deployer
+
+import (
+	"reflect"
+	"testing"
+
+	"sigs.k8s.io/kubetest2/kubetest2-gke/deployer/options"
+)
+
+func TestResourceLabelsArgs(t *testing.T) {
+	d := &Deployer{}
+	if args := d.resourceLabelsArgs(); args != nil {
+		t.Errorf("expected no args without labels, but got %v", args)
+	}
+	if description := d.firewallRuleDescription(); description != "" {
+		t.Errorf("expected no description without labels, but got %q", description)
+	}
+
+	d.resourceLabels = map[string]string{"kubetest2-run-id": "1234", "team": "sig-testing"}
+	expected := []string{"--labels=kubetest2-run-id=1234,team=sig-testing"}
+	if args := d.resourceLabelsArgs(); !reflect.DeepEqual(args, expected) {
+		t.Errorf("expected args %v, but got %v", expected, args)
+	}
+	expectedDescription := "created by kubetest2, labels: kubetest2-run-id=1234,team=sig-testing"
+	if description := d.firewallRuleDescription(); description != expectedDescription {
+		t.Errorf("expected description %q, but got %q", expectedDescription, description)
+	}
+}
+
+func TestVerifyResourceLabelsFlagsErrors(t *testing.T) {
+	testCases := []struct {
+		name          string
+		commonOptions options.CommonOptions
+	}{
+		{
+			name:          "invalid label",
+			commonOptions: options.CommonOptions{ResourceLabels: []string{"Team=sig-testing"}},
+		},
+		{
+			name:          "run id override",
+			commonOptions: options.CommonOptions{ResourceLabels: []string{"kubetest2-run-id=1234"}},
+		},
+		{
+			name:          "negative expiry",
+			commonOptions: options.CommonOptions{ResourceLabelsExpiryHours: -1},
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			d := &Deployer{CommonOptions: &tc.commonOptions}
+			if err := d.verifyResourceLabelsFlags(); err == nil {
+				t.Errorf("expected an error but got none")
+			}
+		})
+	}
+}
@@ -0,0 +1,84 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package deployer
+
+import (
+	"fmt"
+
+	"k8s.io/klog"
+
+	"sigs.k8s.io/kubetest2/pkg/labels"
+	"sigs.k8s.io/kubetest2/pkg/leak"
+)
+
+// leakFilters returns the filters selecting the resources created by the run
+// in each project, of the kinds or of every kind if none
+func (d *Deployer) leakFilters(kinds ...string) []leak.Filter {
+	var filters []leak.Filter
+	for i, project := range d.Projects {
+		f := leak.Filter{
+			Project: project,
+			Labels:  map[string]string{labels.RunID: labels.SanitizeValue(d.Kubetest2CommonOptions.RunID())},
+			Kinds:   kinds,
+		}
+		for _, cluster := range d.projectClustersLayout[project] {
+			// the instance groups, routes and firewall rules of GKE clusters are prefixed by
+			// gke-<cluster name>, or gk3-<cluster name> for Autopilot clusters
+			f.Names = append(f.Names, cluster.name)
+			f.NamePrefixes = append(f.NamePrefixes, "gke-"+cluster.name+"-", "gk3-"+cluster.name+"-")
+		}
+		// the network is deleted on down unless it is the default network, it is in the host project
+		if i == 0 && d.Network != "default" {
+			f.Networks = []string{d.Network}
+		}
+		filters = append(filters, f)
+	}
+	return filters
+}
+
+// CheckLeakedResources looks for the resources created by the run that are
+// still in the projects after down, reporting them in the run dir and force
+// deleting them with --cleanup-leaked-resources
+func (d *Deployer) CheckLeakedResources() error {
+	return d.checkLeakedResources()
+}
+
+// checkLeakedResources looks for the leaked resources of the kinds, or of
+// every kind if none
+func (d *Deployer) checkLeakedResources(kinds ...string) error {
+	leaked, err := leak.Detect(d.leakFilters(kinds...))
+	if err != nil {
+		klog.Warningf("%v", err)
+	}
+	if len(leaked) == 0 {
+		return nil
+	}
+	for _, r := range leaked {
+		klog.Warningf("Leaked %s", r)
+	}
+	if err := leak.Report(d.Kubetest2CommonOptions.RunDir(), leaked); err != nil {
+		klog.Warningf("Failed to report the leaked resources: %v", err)
+	}
+	if !d.CleanupLeakedResources {
+		klog.Warningf("Found %d leaked resources, see %s in the run dir, set --cleanup-leaked-resources to delete them", len(leaked), "leaked-resources.json")
+		return nil
+	}
+	if remaining := leak.Delete(leaked); len(remaining) > 0 {
+		return fmt.Errorf("failed to delete %d leaked resources: %v", len(remaining), remaining)
+	}
+	return nil
+}
@@ -0,0 +1,126 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package deployer
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"k8s.io/klog"
+
+	"sigs.k8s.io/kubetest2/pkg/exec"
+)
+
+const (
+	// the name of the maintenance exclusion added to the clusters
+	maintenanceExclusionName = "kubetest2"
+	// GKE limits the exclusions of all upgrades to 30 days
+	maxNoUpgradesExclusionHours = 30 * 24
+)
+
+// the --maintenance-exclusion-scope values, as accepted by gcloud
+var maintenanceExclusionScopes = map[string]bool{
+	"no_upgrades":               true,
+	"no_minor_upgrades":         true,
+	"no_minor_or_node_upgrades": true,
+}
+
+// verifyMaintenanceFlags validates the flags protecting the clusters from
+// upgrades and repairs during the run
+func (d *Deployer) verifyMaintenanceFlags() error {
+	if d.MaintenanceExclusionHours < 0 {
+		return fmt.Errorf("--maintenance-exclusion-hours must not be negative, got %d", d.MaintenanceExclusionHours)
+	}
+	if d.MaintenanceExclusionHours > 0 {
+		if !maintenanceExclusionScopes[d.MaintenanceExclusionScope] {
+			return fmt.Errorf("--maintenance-exclusion-scope must be one of no_upgrades, no_minor_upgrades or no_minor_or_node_upgrades, got %q", d.MaintenanceExclusionScope)
+		}
+		if d.MaintenanceExclusionScope == "no_upgrades" && d.MaintenanceExclusionHours > maxNoUpgradesExclusionHours {
+			return fmt.Errorf("--maintenance-exclusion-hours must be at most %d with the no_upgrades scope, got %d", maxNoUpgradesExclusionHours, d.MaintenanceExclusionHours)
+		}
+	}
+	if d.DisableAutoUpgrade || d.DisableAutoRepair {
+		if d.Autopilot {
+			return fmt.Errorf("--disable-auto-upgrade and --disable-auto-repair are not supported with --autopilot, use --maintenance-exclusion-hours instead")
+		}
+	}
+	// the node pools of the clusters enrolled in a release channel are always auto-upgraded
+	if d.DisableAutoUpgrade && d.ReleaseChannel != "" {
+		return fmt.Errorf("--disable-auto-upgrade cannot be used with --release-channel, use --maintenance-exclusion-hours instead")
+	}
+	return nil
+}
+
+// nodeManagementArgs returns the flags disabling the auto-upgrade and
+// auto-repair of a node pool
+func (d *Deployer) nodeManagementArgs() []string {
+	var args []string
+	if d.DisableAutoUpgrade {
+		args = append(args, "--no-enable-autoupgrade")
+	}
+	if d.DisableAutoRepair {
+		args = append(args, "--no-enable-autorepair")
+	}
+	return args
+}
+
+// notificationArgs returns the flags sending the notifications of the
+// clusters of the project, e.g. of the upgrades, to the --notification-topic
+func (d *Deployer) notificationArgs(project string) []string {
+	if d.NotificationTopic == "" {
+		return nil
+	}
+	return []string{"--notification-config=pubsub=ENABLED,pubsub-topic=" + notificationTopic(d.NotificationTopic, project)}
+}
+
+// notificationTopic returns the full name of the Pub/Sub topic, a topic name
+// without a project is in the project of the cluster
+func notificationTopic(topic, project string) string {
+	if strings.HasPrefix(topic, "projects/") {
+		return topic
+	}
+	return fmt.Sprintf("projects/%s/topics/%s", project, topic)
+}
+
+// maintenanceExclusionWindow returns the start and end of an exclusion of
+// the given hours from now, in the RFC 3339 format accepted by gcloud
+func maintenanceExclusionWindow(now time.Time, hours int) (start, end string) {
+	now = now.UTC().Truncate(time.Second)
+	return now.Format(time.RFC3339), now.Add(time.Duration(hours) * time.Hour).Format(time.RFC3339)
+}
+
+// addMaintenanceExclusion prevents GKE from upgrading the cluster for the
+// --maintenance-exclusion-hours following its creation
+func (d *Deployer) addMaintenanceExclusion(project string, cluster cluster, locationArg string) error {
+	if d.MaintenanceExclusionHours == 0 {
+		return nil
+	}
+	start, end := maintenanceExclusionWindow(time.Now(), d.MaintenanceExclusionHours)
+	klog.V(1).Infof("Excluding %s upgrades of cluster %s from %s to %s", d.MaintenanceExclusionScope, cluster.name, start, end)
+	if err := runWithOutput(exec.Command("gcloud", containerArgs("clusters", "update", cluster.name,
+		"--project="+project,
+		locationArg,
+		"--add-maintenance-exclusion-name="+maintenanceExclusionName,
+		"--add-maintenance-exclusion-start="+start,
+		"--add-maintenance-exclusion-end="+end,
+		"--add-maintenance-exclusion-scope="+d.MaintenanceExclusionScope,
+	)...)); err != nil {
+		return fmt.Errorf("error adding a maintenance exclusion to cluster %s: %w", cluster.name, err)
+	}
+	return nil
+}
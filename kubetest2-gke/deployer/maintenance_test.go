@@ -0,0 +1,107 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package deployer
+
+import (
+	"testing"
+	"time"
+
+	"sigs.k8s.io/kubetest2/kubetest2-gke/deployer/options"
+)
+
+func TestVerifyMaintenanceFlags(t *testing.T) {
+	testCases := []struct {
+		name           string
+		clusterOptions options.ClusterOptions
+		expectError    bool
+	}{
+		{
+			name: "nothing set",
+		},
+		{
+			name:           "valid exclusion",
+			clusterOptions: options.ClusterOptions{MaintenanceExclusionHours: 48, MaintenanceExclusionScope: "no_upgrades"},
+		},
+		{
+			name:           "negative exclusion",
+			clusterOptions: options.ClusterOptions{MaintenanceExclusionHours: -1, MaintenanceExclusionScope: "no_upgrades"},
+			expectError:    true,
+		},
+		{
+			name:           "invalid scope",
+			clusterOptions: options.ClusterOptions{MaintenanceExclusionHours: 48, MaintenanceExclusionScope: "no_repairs"},
+			expectError:    true,
+		},
+		{
+			name:           "no upgrades exclusion too long",
+			clusterOptions: options.ClusterOptions{MaintenanceExclusionHours: 721, MaintenanceExclusionScope: "no_upgrades"},
+			expectError:    true,
+		},
+		{
+			name:           "long minor upgrades exclusion",
+			clusterOptions: options.ClusterOptions{MaintenanceExclusionHours: 2000, MaintenanceExclusionScope: "no_minor_upgrades"},
+		},
+		{
+			name:           "auto-upgrade and auto-repair disabled",
+			clusterOptions: options.ClusterOptions{DisableAutoUpgrade: true, DisableAutoRepair: true},
+		},
+		{
+			name:           "auto-upgrade disabled with release channel",
+			clusterOptions: options.ClusterOptions{DisableAutoUpgrade: true, ReleaseChannel: "stable"},
+			expectError:    true,
+		},
+		{
+			name:           "auto-repair disabled with release channel",
+			clusterOptions: options.ClusterOptions{DisableAutoRepair: true, ReleaseChannel: "stable"},
+		},
+		{
+			name:           "autopilot",
+			clusterOptions: options.ClusterOptions{DisableAutoRepair: true, Autopilot: true},
+			expectError:    true,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			d := &Deployer{ClusterOptions: &tc.clusterOptions}
+			if err := d.verifyMaintenanceFlags(); (err != nil) != tc.expectError {
+				t.Errorf("expected error: %v, but got: %v", tc.expectError, err)
+			}
+		})
+	}
+}
+
+func TestNotificationTopic(t *testing.T) {
+	if actual := notificationTopic("gke-upgrades", "p1"); actual != "projects/p1/topics/gke-upgrades" {
+		t.Errorf("unexpected topic %q", actual)
+	}
+	if actual := notificationTopic("projects/central/topics/gke-upgrades", "p1"); actual != "projects/central/topics/gke-upgrades" {
+		t.Errorf("unexpected topic %q", actual)
+	}
+}
+
+func TestMaintenanceExclusionWindow(t *testing.T) {
+	now := time.Date(2021, 3, 4, 22, 30, 15, 500, time.FixedZone("PST", -8*3600))
+	start, end := maintenanceExclusionWindow(now, 12)
+	if start != "2021-03-05T06:30:15Z" {
+		t.Errorf("unexpected start %q", start)
+	}
+	if end != "2021-03-05T18:30:15Z" {
+		t.Errorf("unexpected end %q", end)
+	}
+}
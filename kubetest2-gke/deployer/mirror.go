@@ -0,0 +1,97 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package deployer
+
+import (
+	"fmt"
+	"regexp"
+
+	"k8s.io/klog"
+
+	"sigs.k8s.io/kubetest2/pkg/exec"
+	"sigs.k8s.io/kubetest2/pkg/mirror"
+)
+
+// LOCATION-docker.pkg.dev/PROJECT/REPOSITORY
+var mirrorRepositoryRegexp = regexp.MustCompile(`^([a-z0-9-]+)-docker\.pkg\.dev/([a-z0-9:.-]+)/([a-z0-9-]+)$`)
+
+// parseMirrorRepository returns the location, project and name of the Artifact Registry repository
+func parseMirrorRepository(repository string) (location, project, name string, err error) {
+	match := mirrorRepositoryRegexp.FindStringSubmatch(repository)
+	if match == nil {
+		return "", "", "", fmt.Errorf("invalid --mirror-repository %q, must be an Artifact Registry docker repository as LOCATION-docker.pkg.dev/PROJECT/REPOSITORY", repository)
+	}
+	return match[1], match[2], match[3], nil
+}
+
+func (d *Deployer) verifyMirrorFlags() error {
+	if d.BuildOptions.MirrorRepository == "" {
+		if d.BuildOptions.MirrorImages != "" {
+			return fmt.Errorf("--mirror-images requires --mirror-repository")
+		}
+		return nil
+	}
+	_, _, _, err := parseMirrorRepository(d.BuildOptions.MirrorRepository)
+	return err
+}
+
+// copyToMirror copies the --mirror-images to the --mirror-repository during the build,
+// creating the repository if needed, so that the nodes and the tests pull
+// the images from Artifact Registry instead of the public registries
+func (d *Deployer) copyToMirror() error {
+	if d.BuildOptions.MirrorRepository == "" || d.BuildOptions.MirrorImages == "" {
+		return nil
+	}
+	location, project, name, err := parseMirrorRepository(d.BuildOptions.MirrorRepository)
+	if err != nil {
+		return err
+	}
+	images, err := mirror.ReadImages(d.BuildOptions.MirrorImages)
+	if err != nil {
+		return err
+	}
+
+	if err := runWithNoOutput(exec.Command("gcloud", "artifacts", "repositories", "describe", name,
+		"--project="+project,
+		"--location="+location)); err != nil {
+		klog.V(1).Infof("Creating the mirror repository %s", d.BuildOptions.MirrorRepository)
+		if err := runWithOutput(exec.Command("gcloud", "artifacts", "repositories", "create", name,
+			"--project="+project,
+			"--location="+location,
+			"--repository-format=docker",
+			"--description=kubetest2 image mirror")); err != nil {
+			return fmt.Errorf("failed to create the mirror repository: %w", err)
+		}
+	}
+	// crane pushes with the docker credential helpers
+	if err := runWithOutput(exec.Command("gcloud", "auth", "configure-docker", location+"-docker.pkg.dev", "--quiet")); err != nil {
+		return fmt.Errorf("failed to configure the docker credentials for the mirror: %w", err)
+	}
+	return d.mirror().Copy(images)
+}
+
+func (d *Deployer) mirror() *mirror.Mirror {
+	return &mirror.Mirror{Registry: d.BuildOptions.MirrorRepository}
+}
+
+// writeMirrorRepoList points the e2e tests of the run at the --mirror-repository
+func (d *Deployer) writeMirrorRepoList() error {
+	if d.BuildOptions.MirrorRepository == "" {
+		return nil
+	}
+	return d.mirror().WriteRepoList(d.Kubetest2CommonOptions.RunDir())
+}
@@ -0,0 +1,51 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package deployer
+
+import (
+	"testing"
+)
+
+func TestParseMirrorRepository(t *testing.T) {
+	testCases := []struct {
+		repository  string
+		location    string
+		project     string
+		name        string
+		expectError bool
+	}{
+		{repository: "us-docker.pkg.dev/my-project/mirror", location: "us", project: "my-project", name: "mirror"},
+		{repository: "europe-west1-docker.pkg.dev/example.com:project/e2e-images", location: "europe-west1", project: "example.com:project", name: "e2e-images"},
+		{repository: "gcr.io/my-project", expectError: true},
+		{repository: "us-docker.pkg.dev/my-project", expectError: true},
+		{repository: "us-docker.pkg.dev/my-project/mirror/nested", expectError: true},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.repository, func(t *testing.T) {
+			t.Parallel()
+			location, project, name, err := parseMirrorRepository(tc.repository)
+			if (err != nil) != tc.expectError {
+				t.Fatalf("expected error: %v, but got: %v", tc.expectError, err)
+			}
+			if location != tc.location || project != tc.project || name != tc.name {
+				t.Errorf("expected %s %s %s, but got %s %s %s", tc.location, tc.project, tc.name, location, project, name)
+			}
+		})
+	}
+}
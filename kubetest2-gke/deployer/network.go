@@ -80,6 +80,10 @@ func (d *Deployer) VerifyNetworkFlags() error {
 		return err
 	}
 
+	if err := d.verifyStackTypeFlags(); err != nil {
+		return err
+	}
+
 	return nil
 }
 
@@ -157,8 +161,9 @@ func (d *Deployer) CreateNetwork() error {
 	// For multiple projects profile, the subnet-mode must be custom and should only be created in the host project.
 	//   (Here we consider the first project to be the host project and the rest be service projects)
 	//   Reference: https://cloud.google.com/kubernetes-engine/docs/how-to/cluster-shared-vpc#creating_a_network_and_two_subnets
+	// The subnets of auto mode networks do not support IPv6, so dual-stack clusters also require a custom mode network.
 	subnetMode := "auto"
-	if len(d.Projects) > 1 {
+	if len(d.Projects) > 1 || d.dualStack() {
 		subnetMode = "custom"
 	}
 	if runWithNoOutput(exec.Command("gcloud", "compute", "networks", "describe", d.Network,
@@ -180,6 +185,14 @@ func (d *Deployer) CreateSubnets() error {
 	// Create subnetworks for the service projects to work with shared VPC if it's a multi-project profile.
 	// Reference: https://cloud.google.com/kubernetes-engine/docs/how-to/cluster-shared-vpc#creating_a_network_and_two_subnets
 	if len(d.Projects) == 1 {
+		if d.dualStack() {
+			// a subnet per region of the clusters
+			for _, region := range d.clusterRegions(d.Projects[0], d.retryCount) {
+				if err := d.createDualStackSubnet(region); err != nil {
+					return err
+				}
+			}
+		}
 		return nil
 	}
 	hostProject := d.Projects[0]
@@ -192,12 +205,13 @@ func (d *Deployer) CreateSubnets() error {
 			"gcloud", "compute", "networks", "subnets", "create",
 			subnetName,
 			"--project=" + hostProject,
-			"--region=" + regionFromLocation(d.Regions, d.Zones, d.retryCount),
+			"--region=" + d.subnetRegion(serviceProject, d.retryCount),
 			"--network=" + d.Network,
 			"--range=" + parts[0],
 			"--secondary-range",
 			fmt.Sprintf("%s-services=%s,%s-pods=%s", subnetName, parts[1], subnetName, parts[2]),
 		}
+		createSubnetCommand = append(createSubnetCommand, d.stackTypeSubnetArgs()...)
 		// Enabling `Private Google Access` on the subnet is needed for private
 		// cluster nodes to reach storage.googleapis.com.
 		if d.PrivateClusterAccessLevel != "" {
@@ -213,6 +227,14 @@ func (d *Deployer) CreateSubnets() error {
 func (d *Deployer) DeleteSubnets(retryCount int) error {
 	// Delete the subnetworks if it's a multi-project profile.
 	// Reference: https://cloud.google.com/kubernetes-engine/docs/how-to/cluster-shared-vpc#deleting_the_shared_network
+	if len(d.Projects) == 1 && d.dualStack() {
+		for _, region := range d.clusterRegions(d.Projects[0], retryCount) {
+			if err := d.deleteDualStackSubnet(region); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
 	if len(d.Projects) >= 1 {
 		hostProject := d.Projects[0]
 		for i := 1; i < len(d.Projects); i++ {
@@ -221,7 +243,7 @@ func (d *Deployer) DeleteSubnets(retryCount int) error {
 			if err := runWithOutput(exec.Command("gcloud", "compute", "networks", "subnets", "delete",
 				subnetName,
 				"--project="+hostProject,
-				"--region="+regionFromLocation(d.Regions, d.Zones, retryCount),
+				"--region="+d.subnetRegion(serviceProject, retryCount),
 				"--quiet",
 			)); err != nil {
 				return err
@@ -280,7 +302,11 @@ func subNetworkArgs(autopilot bool, projects []string, region, network string, p
 }
 
 func (d *Deployer) SetupNetwork() error {
-	if err := enableSharedVPCAndGrantRoles(d.Projects, regionFromLocation(d.Regions, d.Zones, d.retryCount), d.Network); err != nil {
+	subnetRegions := map[string]string{}
+	for _, project := range d.Projects {
+		subnetRegions[project] = d.subnetRegion(project, d.retryCount)
+	}
+	if err := enableSharedVPCAndGrantRoles(d.Projects, subnetRegions, d.Network); err != nil {
 		return err
 	}
 	if err := grantHostServiceAgentUserRole(d.Projects); err != nil {
@@ -291,7 +317,8 @@ func (d *Deployer) SetupNetwork() error {
 
 // This function implements https://cloud.google.com/kubernetes-engine/docs/how-to/cluster-shared-vpc#enabling_and_granting_roles
 // to enable shared VPC and grant required roles for the multi-project multi-cluster profile.
-func enableSharedVPCAndGrantRoles(projects []string, region, network string) error {
+// The subnet of each service project is in its region of subnetRegions.
+func enableSharedVPCAndGrantRoles(projects []string, subnetRegions map[string]string, network string) error {
 	// Nothing needs to be done for single project.
 	if len(projects) == 1 {
 		return nil
@@ -327,6 +354,7 @@ func enableSharedVPCAndGrantRoles(projects []string, region, network string) err
 	for i := 1; i < len(projects); i++ {
 		serviceProject := projects[i]
 		subnetName := network + "-" + serviceProject
+		region := subnetRegions[serviceProject]
 		// Get the subnet etag.
 		subnetETag, err := exec.Output(exec.Command("gcloud", "compute", "networks", "subnets",
 			"get-iam-policy", subnetName, "--project="+networkHostProject, "--region="+region, "--format=value(etag)"))
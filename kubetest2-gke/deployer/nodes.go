@@ -0,0 +1,163 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package deployer
+
+import (
+	"fmt"
+	"strings"
+
+	"k8s.io/klog"
+
+	"sigs.k8s.io/kubetest2/pkg/exec"
+)
+
+// verifyNodeFlags validates the node flags against the machine types of all the clusters
+func (d *Deployer) verifyNodeFlags() error {
+	if err := d.verifyAcceleratorFlags(); err != nil {
+		return err
+	}
+	if err := d.verifyWindowsFlags(); err != nil {
+		return err
+	}
+	if err := d.verifySandboxFlags(); err != nil {
+		return err
+	}
+	if err := d.verifySystemConfigFlags(); err != nil {
+		return err
+	}
+	if err := d.verifyAutoscalingFlags(); err != nil {
+		return err
+	}
+	// before the maintenance flags, as it disables the auto-upgrade and auto-repair
+	if err := d.verifyAlphaFlags(); err != nil {
+		return err
+	}
+	if err := d.verifyMaintenanceFlags(); err != nil {
+		return err
+	}
+	if d.Autopilot {
+		if d.ConfidentialNodesEnabled {
+			return fmt.Errorf("--enable-confidential-nodes is not supported with --autopilot")
+		}
+		return nil
+	}
+	for _, machineType := range d.machineTypes() {
+		if d.ConfidentialNodesEnabled && !supportsConfidentialNodes(machineType) {
+			return fmt.Errorf("--enable-confidential-nodes requires an N2D or C2D machine type, got %q", machineType)
+		}
+		if arch := machineArch(machineType); arch != "amd64" {
+			imageType, err := imageTypeForArch(d.ImageType, arch)
+			if err != nil {
+				return fmt.Errorf("invalid --image-type for machine type %s: %w", machineType, err)
+			}
+			if imageType != d.ImageType {
+				klog.V(0).Infof("Using image type %s for the %s nodes of machine type %s", imageType, arch, machineType)
+			}
+		}
+	}
+	return nil
+}
+
+// clusterImageType returns the image type of the default node pool of the
+// cluster, adjusted to the architecture of its machine type
+func (d *Deployer) clusterImageType(name string) string {
+	imageType, err := imageTypeForArch(d.ImageType, machineArch(d.clusterMachineType(name)))
+	if err != nil {
+		// rejected by verifyNodeFlags
+		return d.ImageType
+	}
+	return imageType
+}
+
+// supportsConfidentialNodes returns true if the machine type is of a family
+// supporting Confidential VMs, the AMD EPYC based N2D and C2D
+func supportsConfidentialNodes(machineType string) bool {
+	return strings.HasPrefix(machineType, "n2d-") || strings.HasPrefix(machineType, "c2d-")
+}
+
+// imageTypeForArch returns the node image type to use on the architecture,
+// as arm64 nodes only support the containerd image types
+func imageTypeForArch(imageType, arch string) (string, error) {
+	if arch != "arm64" {
+		return imageType, nil
+	}
+	switch strings.ToUpper(imageType) {
+	case "", "COS", "COS_CONTAINERD":
+		return "COS_CONTAINERD", nil
+	case "UBUNTU", "UBUNTU_CONTAINERD":
+		return "UBUNTU_CONTAINERD", nil
+	default:
+		return "", fmt.Errorf("image type %s is not supported on %s nodes", imageType, arch)
+	}
+}
+
+func (d *Deployer) nodeSecurityArgs() []string {
+	var args []string
+	if d.ConfidentialNodesEnabled {
+		args = append(args, "--enable-confidential-nodes")
+	}
+	if d.ShieldedNodesEnabled {
+		args = append(args, "--enable-shielded-nodes")
+	}
+	return args
+}
+
+// VerifyMachineTypeAvailability verifies that the machine type of each cluster is
+// offered in its location before creating the clusters, as newer machine families
+// (e.g. T2A for arm64 or N2D for confidential nodes) are only available in some regions.
+func (d *Deployer) VerifyMachineTypeAvailability() error {
+	if d.Autopilot {
+		return nil
+	}
+	verified := map[string]bool{}
+	for _, project := range d.Projects {
+		for _, cluster := range d.projectClustersLayout[project] {
+			machineType := d.clusterMachineType(cluster.name)
+			location := d.clusterLocation(cluster.name, d.retryCount)
+			if machineType == "" || location == "" || verified[machineType+" "+location] {
+				continue
+			}
+			verified[machineType+" "+location] = true
+			if err := verifyMachineTypeAvailable(project, machineType, location); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// verifyMachineTypeAvailable verifies that the machine type is offered in the zone or region
+func verifyMachineTypeAvailable(project, machineType, location string) error {
+	filter := fmt.Sprintf("name=%s AND zone=%s", machineType, location)
+	if locationRegion(location) == location {
+		filter = fmt.Sprintf("name=%s AND zone~^%s-", machineType, location)
+	}
+	zones, err := exec.OutputLines(exec.Command("gcloud", "compute", "machine-types", "list",
+		"--project="+project,
+		"--filter="+filter,
+		"--format=value(zone)"))
+	if err != nil {
+		// do not block the run on a failure to list, cluster creation will fail anyway if unavailable
+		klog.Warningf("Failed to verify the availability of machine type %s in %s: %v", machineType, location, err)
+		return nil
+	}
+	if len(zones) == 0 {
+		return fmt.Errorf("machine type %s is not available in %s", machineType, location)
+	}
+	klog.V(1).Infof("Machine type %s is available in zones %v", machineType, zones)
+	return nil
+}
@@ -0,0 +1,116 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package deployer
+
+import (
+	"testing"
+
+	"sigs.k8s.io/kubetest2/kubetest2-gke/deployer/options"
+)
+
+func TestImageTypeForArch(t *testing.T) {
+	testCases := []struct {
+		imageType         string
+		arch              string
+		expectedImageType string
+		expectError       bool
+	}{
+		{imageType: "", arch: "amd64", expectedImageType: ""},
+		{imageType: "cos", arch: "amd64", expectedImageType: "cos"},
+		{imageType: "", arch: "arm64", expectedImageType: "COS_CONTAINERD"},
+		{imageType: "cos", arch: "arm64", expectedImageType: "COS_CONTAINERD"},
+		{imageType: "ubuntu", arch: "arm64", expectedImageType: "UBUNTU_CONTAINERD"},
+		{imageType: "windows_ltsc", arch: "arm64", expectError: true},
+	}
+
+	for _, tc := range testCases {
+		imageType, err := imageTypeForArch(tc.imageType, tc.arch)
+		if tc.expectError {
+			if err == nil {
+				t.Errorf("expected an error for image type %q on %s", tc.imageType, tc.arch)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("did not expect an error, but got: %v", err)
+		}
+		if imageType != tc.expectedImageType {
+			t.Errorf("expected image type %q for %q on %s, but got %q", tc.expectedImageType, tc.imageType, tc.arch, imageType)
+		}
+	}
+}
+
+func TestVerifyNodeFlags(t *testing.T) {
+	testCases := []struct {
+		name               string
+		clusterOptions     options.ClusterOptions
+		expectedImageTypes map[string]string
+		expectError        bool
+	}{
+		{
+			name:           "confidential nodes on n2d",
+			clusterOptions: options.ClusterOptions{MachineType: "n2d-standard-4", ConfidentialNodesEnabled: true},
+		},
+		{
+			name:           "confidential nodes on e2",
+			clusterOptions: options.ClusterOptions{MachineType: "e2-standard-4", ConfidentialNodesEnabled: true},
+			expectError:    true,
+		},
+		{
+			name:               "arm nodes",
+			clusterOptions:     options.ClusterOptions{MachineType: "t2a-standard-4"},
+			expectedImageTypes: map[string]string{"": "COS_CONTAINERD"},
+		},
+		{
+			name: "arm nodes of a cluster spec",
+			clusterOptions: options.ClusterOptions{
+				MachineType:  "e2-standard-4",
+				ImageType:    "ubuntu",
+				ClusterSpecs: []string{"name=c1", "name=c2,machine-type=t2a-standard-4"},
+			},
+			expectedImageTypes: map[string]string{"c1": "ubuntu", "c2": "UBUNTU_CONTAINERD"},
+		},
+		{
+			name: "confidential nodes with an e2 cluster spec",
+			clusterOptions: options.ClusterOptions{
+				MachineType:              "n2d-standard-4",
+				ConfidentialNodesEnabled: true,
+				ClusterSpecs:             []string{"name=c1", "name=c2,machine-type=e2-standard-4"},
+			},
+			expectError: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			d := &Deployer{ClusterOptions: &tc.clusterOptions}
+			if err := d.applyClusterSpecs(); err != nil {
+				t.Fatal(err)
+			}
+			err := d.verifyNodeFlags()
+			if (err != nil) != tc.expectError {
+				t.Errorf("expected error: %v, but got: %v", tc.expectError, err)
+			}
+			for name, expected := range tc.expectedImageTypes {
+				if imageType := d.clusterImageType(name); imageType != expected {
+					t.Errorf("expected image type %q for cluster %q, but got %q", expected, name, imageType)
+				}
+			}
+		})
+	}
+}
@@ -17,6 +17,7 @@ limitations under the License.
 package options
 
 import (
+	"fmt"
 	"os"
 
 	"k8s.io/klog"
@@ -29,6 +30,8 @@ type BuildOptions struct {
 	CommonBuildOptions      *build.Options
 	UpdateLatestGreenMarker bool   `flag:"~update-latest-green-marker" desc:"When set to true, will update the latest-green-x.y.txt marker on GCS."`
 	BuildScript             string `flag:"~build-script" desc:"Only used with the gke_make build. Absolute path to the gke_make build script."`
+	MirrorRepository        string `flag:"~mirror-repository" desc:"Artifact Registry docker repository as LOCATION-docker.pkg.dev/PROJECT/REPOSITORY the --mirror-images are copied to during build, created if needed. The e2e tests pull their images from it."`
+	MirrorImages            string `flag:"~mirror-images" desc:"Only used with --mirror-repository. File listing the images to copy to the mirror during build, one per line."`
 }
 
 var _ build.Builder = &BuildOptions{}
@@ -38,6 +41,9 @@ func (bo *BuildOptions) Validate() error {
 	if bo.CommonBuildOptions.Strategy == string(gkeBuild.GKEMakeStrategy) {
 		if bo.BuildScript != "" {
 			if _, err := os.Stat(bo.BuildScript); err == nil {
+				if bo.CommonBuildOptions.Attest {
+					return fmt.Errorf("--attest is not supported with --strategy=gke_make")
+				}
 				gkeMake := &gkeBuild.GKEMake{
 					RepoRoot:      bo.CommonBuildOptions.RepoRoot,
 					BuildScript:   bo.BuildScript,
@@ -31,6 +31,7 @@ type ClusterOptions struct {
 
 	NumClusters             int      `flag:"~num-clusters" desc:"Number of clusters to create, will auto-generate names as (kt2-<run-id>-<index>)."`
 	Clusters                []string `flag:"~cluster-name" desc:"Cluster names separated by comma. Must be set. For multi-project profile, it should be in the format of clusterA:0,clusterB:1,clusterC:2, where the index means the index of the project."`
+	ClusterSpecs            []string `flag:"~cluster-spec" desc:"Per cluster configuration of a multi-cluster run as comma separated KEY=VALUE pairs starting with the cluster name e.g. name=c1,version=1.29,region=us-central1, can be repeated for each cluster instead of --cluster-name. The keys are name, project (the index of the project for multi-project profile), version, region, zone, machine-type and num-nodes, defaulting to the shared flags. The subnet of a project of the multi-project profile is created in the region of its clusters, which must be the same."`
 	MachineType             string   `flag:"~machine-type" desc:"For use with gcloud commands to specify the machine type for the cluster."`
 	NumNodes                int      `flag:"~num-nodes" desc:"For use with gcloud commands to specify the number of nodes for the cluster."`
 	ImageType               string   `flag:"~image-type" desc:"The image type to use for the cluster."`
@@ -40,12 +41,54 @@ type ClusterOptions struct {
 	WorkloadIdentityEnabled bool     `flag:"~enable-workload-identity" desc:"Whether enable workload identity for the cluster or not. See the details in https://cloud.google.com/kubernetes-engine/docs/how-to/workload-identity."`
 	FirewallRuleAllow       string   `desc:"A list of protocols and ports whose traffic will be allowed for the firewall rules created for the cluster."`
 
+	ConfidentialNodesEnabled bool `flag:"~enable-confidential-nodes" desc:"Whether to enable Confidential GKE Nodes, requires an N2D or C2D --machine-type. See the details in https://cloud.google.com/kubernetes-engine/docs/how-to/confidential-gke-nodes."`
+	ShieldedNodesEnabled     bool `flag:"~enable-shielded-nodes" desc:"Whether to enable Shielded GKE Nodes. See the details in https://cloud.google.com/kubernetes-engine/docs/how-to/shielded-gke-nodes."`
+
+	NodeSystemConfigFile     string   `flag:"~node-system-config-file" desc:"Path to a node system config file with the kubelet and sysctl settings of the linux node pools, validated before creating the clusters. See the details in https://cloud.google.com/kubernetes-engine/docs/how-to/node-system-config."`
+	KubeletConfig            []string `flag:"~kubelet-config" desc:"Comma separated list of KEY=VALUE kubelet config overrides for the default node pool e.g. cpuManagerPolicy=static, applied on top of --node-system-config-file."`
+	AcceleratorKubeletConfig []string `flag:"~accelerator-kubelet-config" desc:"Comma separated list of KEY=VALUE kubelet config overrides for the accelerator node pool, applied on top of --node-system-config-file."`
+
+	MaintenanceExclusionHours int    `flag:"~maintenance-exclusion-hours" desc:"If set, adds a maintenance exclusion of this many hours from the creation of the clusters, so that GKE does not upgrade them during the tests of a long run. At most 720 hours (30 days) with the no_upgrades scope."`
+	MaintenanceExclusionScope string `flag:"~maintenance-exclusion-scope" desc:"The upgrades excluded by --maintenance-exclusion-hours, one of no_upgrades, no_minor_upgrades or no_minor_or_node_upgrades."`
+	DisableAutoUpgrade        bool   `flag:"~disable-auto-upgrade" desc:"Whether to disable the auto-upgrade of the node pools for the lifetime of the clusters, which are then not enrolled in a release channel. Cannot be used with --release-channel."`
+	DisableAutoRepair         bool   `flag:"~disable-auto-repair" desc:"Whether to disable the auto-repair of the node pools for the lifetime of the clusters."`
+	EnableKubernetesAlpha     bool   `flag:"~enable-kubernetes-alpha" desc:"Whether to create alpha clusters, with all the Kubernetes alpha APIs and features enabled, and the auto-upgrade and auto-repair of the node pools disabled as GKE requires. GKE deletes alpha clusters after 30 days, the run is capped at --alpha-cluster-max-hours."`
+	AlphaClusterMaxHours      int    `flag:"~alpha-cluster-max-hours" desc:"Only used with --enable-kubernetes-alpha. The maximum duration of the run from the creation of the alpha clusters, after which it is terminated and the clusters torn down, below the 30 days after which GKE deletes them."`
+	ControlPlaneLogs          bool   `flag:"~control-plane-logs" desc:"Whether to enable the Cloud Logging of the control plane components (apiserver, scheduler and controller-manager) of the clusters, and pull their logs of the run to logs/<cluster>/control-plane in the artifacts at down."`
+	NotificationTopic         string `flag:"~notification-topic" desc:"Pub/Sub topic to send the notifications of the clusters e.g. of the upcoming and started upgrades to, as projects/PROJECT/topics/TOPIC or a topic name in the project of the cluster."`
+
+	Autoscaling []string `flag:"~enable-autoscaling" desc:"Enable the cluster autoscaler for a node pool with comma separated KEY=VALUE pairs e.g. min=1,max=10, can be repeated for each node pool as pool=windows-pool,min=0,max=3. The keys are pool (default-pool, windows-pool, accelerator-pool or sandbox-pool, defaults to default-pool), min and max, the number of nodes per zone. The cluster autoscaler status is written to the run dir after the tests."`
+
 	WindowsEnabled     bool   `flag:"~enable-windows" desc:"Whether enable Windows node pool in the cluster or not."`
 	WindowsNumNodes    int    `flag:"~windows-num-nodes" desc:"For use with gcloud commands to specify the number of nodes for Windows node pools in the cluster."`
 	WindowsMachineType string `flag:"~windows-machine-type" desc:"For use with gcloud commands to specify the machine type for Windows node in the cluster."`
 	WindowsImageType   string `flag:"~windows-image-type" desc:"The Windows image type to use for the cluster."`
+	WindowsOSVersion   string `flag:"~windows-os-version" desc:"The Windows Server LTSC version of the Windows nodes, one of ltsc2019 or ltsc2022, requires a WINDOWS_LTSC --windows-image-type."`
+
+	WindowsNodeLabels              []string `flag:"~windows-node-labels" desc:"Comma separated list of KEY=VALUE labels to apply to the Windows nodes."`
+	WindowsNodeTaints              []string `flag:"~windows-node-taints" desc:"Comma separated list of KEY=VALUE:EFFECT taints to apply to the Windows nodes."`
+	WindowsNoScheduleTaint         bool     `flag:"~windows-no-schedule-taint" desc:"Whether to apply the standard node.kubernetes.io/os=windows:NoSchedule taint to the Windows nodes, so that only the pods tolerating it are scheduled on them."`
+	WindowsNodeReadyTimeoutMinutes int      `flag:"~windows-node-ready-timeout-minutes" desc:"How long to wait for the Windows nodes to be Ready after the clusters are created, as Windows nodes take long to bootstrap."`
+
+	Accelerator            string `flag:"~accelerator" desc:"Accelerators to attach to the nodes of an additional accelerator node pool, in the gcloud format e.g. type=nvidia-tesla-t4,count=1. The NVIDIA drivers are installed and the GPUs are waited for before the tests."`
+	AcceleratorMachineType string `flag:"~accelerator-machine-type" desc:"The machine type for the nodes of the accelerator node pool, must support the accelerator type."`
+	AcceleratorNumNodes    int    `flag:"~accelerator-num-nodes" desc:"The number of nodes of the accelerator node pool."`
+	GPUDriverInstaller     string `flag:"~gpu-driver-installer" desc:"URL or path of the NVIDIA driver installer DaemonSet manifest to apply, defaults to the one matching the image type."`
+
+	SandboxEnabled     bool     `flag:"~enable-gke-sandbox" desc:"Whether to add a GKE Sandbox node pool running the pods of the gvisor RuntimeClass in gVisor, with its nodes labeled and tainted with sandbox.gke.io/runtime=gvisor by GKE. The RuntimeClass name is recorded as runtime-class in the metadata of the run. Autopilot clusters need no node pool."`
+	SandboxNumNodes    int      `flag:"~sandbox-num-nodes" desc:"The number of nodes of the GKE Sandbox node pool."`
+	SandboxMachineType string   `flag:"~sandbox-machine-type" desc:"The machine type for the nodes of the GKE Sandbox node pool."`
+	SandboxNodeLabels  []string `flag:"~sandbox-node-labels" desc:"Comma separated list of additional KEY=VALUE labels to apply to the GKE Sandbox nodes."`
+
+	FleetEnabled                bool   `flag:"~enable-fleet" desc:"Whether to register the clusters to a fleet and wait for the memberships to be ready. See the details in https://cloud.google.com/anthos/fleet-management/docs."`
+	FleetProject                string `flag:"~fleet-project" desc:"The fleet host project to register the clusters to, defaults to the first project."`
+	MultiClusterServicesEnabled bool   `flag:"~enable-multi-cluster-services" desc:"Whether to enable multi-cluster services for the fleet, requires --enable-fleet and --enable-workload-identity."`
+	MultiClusterIngressEnabled  bool   `flag:"~enable-multi-cluster-ingress" desc:"Whether to enable multi-cluster ingress for the fleet with the first cluster as the config cluster, requires --enable-fleet and --enable-workload-identity."`
 
 	RetryableErrorPatterns []string `flag:"~retryable-error-patterns" desc:"Comma separated list of regex match patterns for retryable errors during cluster creation."`
+
+	SkipQuotaCheck bool `flag:"~skip-quota-check" desc:"If set, skips the preflight check of the compute quotas (CPUs, in-use external IPs, instances) in the target projects before creating the clusters."`
+	EstimateCost   bool `flag:"~estimate-cost" desc:"If set, records the inventory of the created resources and an estimate of their spend, based on on-demand list prices and the duration of the run, in the run artifacts."`
 }
 
 func (uo *ClusterOptions) Validate() error {
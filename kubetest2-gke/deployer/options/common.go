@@ -18,6 +18,15 @@ package options
 
 type CommonOptions struct {
 	RepoRoot          string `desc:"Path to root of the kubernetes repo. Used with --build and for dumping cluster logs."`
-	GCPServiceAccount string `flag:"~gcp-service-account" desc:"Service account to activate before using gcloud."`
+	GCPServiceAccount string `flag:"~gcp-service-account" desc:"Service account key file to activate before using gcloud, or a reference to a secret holding the key, e.g. env:NAME or gcp-secret:projects/PROJECT/secrets/SECRET[/versions/VERSION]."`
 	GCPSSHKeyIgnored  bool   `flag:"~ignore-gcp-ssh-key" desc:"Whether the GCP SSH key should be ignored or not for bringing up the cluster."`
+
+	ResourceLabels            []string `flag:"~resource-labels" desc:"KEY=VALUE labels to add to the clusters, node pools and firewall rules created by the run, in addition to (or overriding) the kubetest2-run-id, kubetest2-prow-job, kubetest2-owner and kubetest2-expiry labels identifying the run."`
+	ResourceLabelsExpiryHours int      `flag:"~resource-labels-expiry-hours" desc:"Number of hours after which the resources created by the run are labeled as expired with the kubetest2-expiry label (as a unix time), 0 to not set the label."`
+
+	KubeconfigAuth string `flag:"~kubeconfig-auth" desc:"How the generated kubeconfigs authenticate to the clusters, one of token (an access token embedded in the kubeconfig, which expires after an hour), exec (the gke-gcloud-auth-plugin using the gcloud credentials) or application-default (the gke-gcloud-auth-plugin using the application default credentials). Defaults to exec if the gke-gcloud-auth-plugin is installed, otherwise the kubeconfigs are generated by gcloud container clusters get-credentials."`
+
+	CleanupLeakedResources bool `flag:"~cleanup-leaked-resources" desc:"If set, force delete the resources created by the run that are still found in the projects after down, instead of only reporting them in leaked-resources.json."`
+
+	SimulateError string `flag:"~simulate-error" desc:"Developer flag to exercise the error handling of the deployer, as <error>:<phase>: fails the first gcloud container clusters command of the phase (up, upgrade or down) with a simulated stockout, quota or timeout error, e.g. stockout:up to retry in the next zone."`
 }
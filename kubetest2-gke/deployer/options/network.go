@@ -22,4 +22,8 @@ type NetworkOptions struct {
 	PrivateClusterAccessLevel    string   `flag:"~private-cluster-access-level" desc:"Private cluster access level, if not empty, must be one of 'no', 'limited' or 'unrestricted'. See the details in https://cloud.google.com/kubernetes-engine/docs/how-to/private-clusters."`
 	PrivateClusterMasterIPRanges []string `flag:"~private-cluster-master-ip-range" desc:"Private cluster master IP ranges. It should be IPv4 CIDR(s), and its length must be the same as the number of clusters if private cluster is requested."`
 	SubnetworkRanges             []string `flag:"~subnetwork-ranges" desc:"Subnetwork ranges as required for shared VPC setup as described in https://cloud.google.com/kubernetes-engine/docs/how-to/cluster-shared-vpc#creating_a_network_and_two_subnets. For multi-project profile, it is required and should be in the format of 10.0.4.0/22 10.0.32.0/20 10.4.0.0/14,172.16.4.0/22 172.16.16.0/20 172.16.4.0/22, where the subnetworks configuration for different project are separated by comma, and the ranges of each subnetwork configuration is separated by space."`
+
+	StackType string `flag:"~stack-type" desc:"IP stack type of the clusters, one of ipv4 or dual. Dual-stack clusters require a --network other than default, as an IPv4/IPv6 subnet is created in it for the clusters, with the IPv4 range 10.0.0.0/20 for single project profile or the --subnetwork-ranges for multi-project profile."`
+
+	SkipFirewallRules bool `flag:"~skip-firewall-rules" desc:"If set, the deployer will not create or delete any firewall rules, e.g. for VPC-SC restricted projects where firewall rules are managed externally."`
 }
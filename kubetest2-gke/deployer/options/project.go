@@ -17,11 +17,18 @@ limitations under the License.
 package options
 
 type ProjectOptions struct {
-	Projects []string `flag:"~project" desc:"Comma separated list of GCP Project(s) to use for creating the cluster."`
+	Projects      []string `flag:"~project" desc:"Comma separated list of GCP Project(s) to use for creating the cluster."`
+	ProjectSource string   `flag:"~project-source" desc:"Where to get the GCP projects from: static for the --project ones, boskos to lease them from Boskos, or env for the comma separated projects of $KUBETEST2_PROJECTS. Defaults to static if --project is set and boskos otherwise."`
 
-	BoskosLocation                 string   `flag:"~boskos-location" desc:"If set, manually specifies the location of the Boskos server."`
+	BoskosLocation                 string   `flag:"~boskos-location" desc:"If set, manually specifies the location of the Boskos server, or a reference to a secret holding it e.g. env:NAME, file:PATH or gcp-secret:projects/PROJECT/secrets/SECRET."`
 	BoskosAcquireTimeoutSeconds    int      `flag:"~boskos-acquire-timeout-seconds" desc:"How long (in seconds) to hang on a request to Boskos to acquire a resource before erroring."`
 	BoskosHeartbeatIntervalSeconds int      `flag:"~boskos-heartbeat-interval-seconds" desc:"How often (in seconds) to send a heartbeat to Boskos to hold the acquired resource. 0 means no heartbeat."`
 	BoskosResourceType             []string `flag:"~boskos-resource-type" desc:"If set, manually specifies the resource type(s) of GCP projects to acquire from Boskos."`
 	BoskosProjectsRequested        []int    `flag:"~projects-requested" desc:"Number of projects to request from Boskos. It is only respected if projects is empty, and must be larger than zero."`
+
+	MaxProjectRetries       int      `flag:"~max-project-retries" desc:"Number of times to release the boskos projects and lease new ones to retry creating the clusters in, when all the --zone or --region are stocked out. 0 to fail the run instead."`
+	BoskosRetryResourceType []string `flag:"~boskos-retry-resource-type" desc:"Only used with --max-project-retries. Resource type(s) of the projects leased from Boskos on retries e.g. of a pool in other regions, defaults to --boskos-resource-type."`
+
+	BoskosLeaseFile  string `flag:"~boskos-lease-file" desc:"File persisting the projects leased from Boskos, for the sequential invocations of a job in the same pod e.g. the stages of a pipeline to reuse them instead of leasing new ones. The projects are kept leased, and the clusters deleted at down, until the --boskos-lease-final invocation."`
+	BoskosLeaseFinal bool   `flag:"~boskos-lease-final" desc:"Only used with --boskos-lease-file. Whether this is the final invocation sharing the lease file, which releases the projects to Boskos at down and removes the file."`
 }
@@ -29,7 +29,9 @@ var (
 // PostTest will check if there's any error in the test. If there's no
 // error in the test, and the --update-latest-green-marker is set to true,
 // this method will stage the build marker to the GCS bucket.
+// With --enable-autoscaling, the cluster autoscaler status is written to the run dir first.
 func (d *Deployer) PostTest(testErr error) error {
+	d.dumpAutoscalerStatus()
 	if testErr != nil || !d.BuildOptions.UpdateLatestGreenMarker {
 		return nil
 	}
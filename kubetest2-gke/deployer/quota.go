@@ -0,0 +1,121 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package deployer
+
+import (
+	"k8s.io/klog"
+
+	"sigs.k8s.io/kubetest2/pkg/quota"
+)
+
+const (
+	// the machine type used by gcloud when --machine-type is not specified
+	defaultMachineType = "e2-medium"
+	// regional clusters create --num-nodes in each of the (by default 3) zones of the region
+	defaultZonesPerRegion = 3
+)
+
+// CheckQuota verifies that the projects have enough regional compute quota for
+// the requested clusters in the regions of their locations of the current try,
+// to fail fast instead of erroring out in the middle of cluster creation.
+func (d *Deployer) CheckQuota() error {
+	if d.SkipQuotaCheck {
+		return nil
+	}
+	// GKE Autopilot manages the nodes, so the requirements cannot be known in advance.
+	if d.Autopilot {
+		return nil
+	}
+
+	for _, project := range d.Projects {
+		regionRequirements, regions, err := d.quotaRequirements(d.projectClustersLayout[project], d.retryCount)
+		if err != nil {
+			klog.Warningf("Skipping quota check: %v", err)
+			return nil
+		}
+		for _, region := range regions {
+			required := regionRequirements[region]
+			klog.V(1).Infof("Checking quota in project %s region %s for %v", project, region, required)
+			if err := quota.Check(project, region, required); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// quotaRequirements returns the compute quota required by the clusters in
+// each of the regions of their locations for the try, and the regions in order
+func (d *Deployer) quotaRequirements(clusters []cluster, retryCount int) (map[string]quota.Requirements, []string, error) {
+	// private cluster nodes do not get external IPs
+	externalIP := d.PrivateClusterAccessLevel == ""
+
+	requirements := map[string]quota.Requirements{}
+	var regions []string
+	for _, cluster := range clusters {
+		location := d.clusterLocation(cluster.name, retryCount)
+		region := locationRegion(location)
+		nodesMultiplier := 1
+		if location != "" && location == region {
+			nodesMultiplier = defaultZonesPerRegion
+		}
+		required, ok := requirements[region]
+		if !ok {
+			required = quota.Requirements{}
+			requirements[region] = required
+			regions = append(regions, region)
+		}
+
+		machineType := d.clusterMachineType(cluster.name)
+		if machineType == "" {
+			machineType = defaultMachineType
+		}
+		if err := required.AddNodes(machineType, d.clusterNumNodes(cluster.name)*nodesMultiplier, externalIP); err != nil {
+			return nil, nil, err
+		}
+		if d.WindowsEnabled {
+			windowsMachineType := d.WindowsMachineType
+			if windowsMachineType == "" {
+				windowsMachineType = defaultMachineType
+			}
+			if err := required.AddNodes(windowsMachineType, d.WindowsNumNodes*nodesMultiplier, externalIP); err != nil {
+				return nil, nil, err
+			}
+		}
+		if d.Accelerator != "" {
+			acceleratorType, count, err := parseAccelerator(d.Accelerator)
+			if err != nil {
+				return nil, nil, err
+			}
+			nodes := d.AcceleratorNumNodes * nodesMultiplier
+			if err := required.AddNodes(d.AcceleratorMachineType, nodes, externalIP); err != nil {
+				return nil, nil, err
+			}
+			required.Add(gpuQuotaMetric(acceleratorType), float64(nodes*count))
+		}
+		if d.SandboxEnabled && !d.Autopilot {
+			sandboxMachineType := d.SandboxMachineType
+			if sandboxMachineType == "" {
+				sandboxMachineType = defaultMachineType
+			}
+			if err := required.AddNodes(sandboxMachineType, d.SandboxNumNodes*nodesMultiplier, externalIP); err != nil {
+				return nil, nil, err
+			}
+		}
+	}
+	return requirements, regions, nil
+}
@@ -0,0 +1,56 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package deployer
+
+import (
+	"reflect"
+	"testing"
+
+	"sigs.k8s.io/kubetest2/kubetest2-gke/deployer/options"
+	"sigs.k8s.io/kubetest2/pkg/quota"
+)
+
+func TestQuotaRequirements(t *testing.T) {
+	d := &Deployer{
+		ClusterOptions: &options.ClusterOptions{
+			ClusterSpecs: []string{"name=c1,zone=us-central1-a", "name=c2,zone=us-central1-b", "name=c3,region=europe-west2,num-nodes=2"},
+			NumNodes:     1,
+		},
+		ProjectOptions: &options.ProjectOptions{},
+		NetworkOptions: &options.NetworkOptions{},
+	}
+	if err := d.applyClusterSpecs(); err != nil {
+		t.Fatalf("did not expect an error, but got: %v", err)
+	}
+	d.Projects = []string{"p"}
+	if err := d.layoutClusters(); err != nil {
+		t.Fatalf("did not expect an error, but got: %v", err)
+	}
+	requirements, regions, err := d.quotaRequirements(d.projectClustersLayout["p"], 0)
+	if err != nil {
+		t.Fatalf("did not expect an error, but got: %v", err)
+	}
+	if expected := []string{"us-central1", "europe-west2"}; !reflect.DeepEqual(regions, expected) {
+		t.Errorf("expected the quota checked in %v, but got %v", expected, regions)
+	}
+	if instances := requirements["us-central1"][quota.Instances]; instances != 2 {
+		t.Errorf("expected 2 instances of the zonal clusters in us-central1, but got %v", instances)
+	}
+	if instances := requirements["europe-west2"][quota.Instances]; instances != 2*defaultZonesPerRegion {
+		t.Errorf("expected %d instances of the regional cluster in europe-west2, but got %v", 2*defaultZonesPerRegion, instances)
+	}
+}
@@ -0,0 +1,80 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package deployer
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const (
+	sandboxNodePoolName = "sandbox-pool"
+
+	// sandboxRuntimeClass is the RuntimeClass of the pods run in gVisor,
+	// installed by GKE along with the sandbox node pool
+	sandboxRuntimeClass = "gvisor"
+	// GKE Sandbox requires Container-Optimized OS with containerd
+	sandboxImageType = "COS_CONTAINERD"
+)
+
+func (d *Deployer) verifySandboxFlags() error {
+	if !d.SandboxEnabled {
+		return nil
+	}
+	// autopilot runs the pods of the gvisor RuntimeClass in GKE Sandbox without a node pool
+	if d.Autopilot {
+		return nil
+	}
+	if d.SandboxNumNodes <= 0 {
+		return fmt.Errorf("--sandbox-num-nodes must be larger than 0")
+	}
+	if machineArch(d.SandboxMachineType) != "amd64" {
+		return fmt.Errorf("GKE Sandbox does not support the machine type %s of --sandbox-machine-type", d.SandboxMachineType)
+	}
+	for _, label := range d.SandboxNodeLabels {
+		if kv := strings.SplitN(label, "=", 2); len(kv) != 2 || kv[0] == "" {
+			return fmt.Errorf("invalid GKE Sandbox node label %q, expected KEY=VALUE", label)
+		}
+	}
+	return nil
+}
+
+func (d *Deployer) createSandboxNodePoolCommand(project string, cluster cluster, locationArg, nodePoolName string) []string {
+	fs := make([]string, 0)
+	fs = append(fs, "container", "node-pools", "create", nodePoolName)
+	fs = append(fs, "--quiet")
+	fs = append(fs, "--cluster="+cluster.name)
+	fs = append(fs, "--project="+project)
+	fs = append(fs, locationArg)
+	// GKE labels and taints the nodes with sandbox.gke.io/runtime=gvisor
+	// itself, which the RuntimeClass selects and tolerates
+	fs = append(fs, "--sandbox=type="+sandboxRuntimeClass)
+	fs = append(fs, "--image-type="+sandboxImageType)
+	if d.SandboxMachineType != "" {
+		fs = append(fs, "--machine-type="+d.SandboxMachineType)
+	}
+	fs = append(fs, "--num-nodes="+strconv.Itoa(d.SandboxNumNodes))
+	if len(d.SandboxNodeLabels) > 0 {
+		fs = append(fs, "--node-labels="+strings.Join(d.SandboxNodeLabels, ","))
+	}
+	fs = append(fs, d.autoscalingArgs(nodePoolName)...)
+	fs = append(fs, d.nodeManagementArgs()...)
+	fs = append(fs, d.resourceLabelsArgs()...)
+
+	return fs
+}
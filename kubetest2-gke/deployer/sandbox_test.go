@@ -0,0 +1,97 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package deployer
+
+import (
+	"reflect"
+	"testing"
+
+	"sigs.k8s.io/kubetest2/kubetest2-gke/deployer/options"
+)
+
+func TestVerifySandboxFlags(t *testing.T) {
+	testCases := []struct {
+		name           string
+		clusterOptions options.ClusterOptions
+		expectError    bool
+	}{
+		{
+			name:           "sandbox disabled",
+			clusterOptions: options.ClusterOptions{SandboxNumNodes: 0},
+		},
+		{
+			name:           "valid flags",
+			clusterOptions: options.ClusterOptions{SandboxEnabled: true, SandboxNumNodes: 2, SandboxMachineType: "e2-standard-4", SandboxNodeLabels: []string{"team=sig-node"}},
+		},
+		{
+			name:           "no nodes",
+			clusterOptions: options.ClusterOptions{SandboxEnabled: true},
+			expectError:    true,
+		},
+		{
+			name:           "arm machine type",
+			clusterOptions: options.ClusterOptions{SandboxEnabled: true, SandboxNumNodes: 1, SandboxMachineType: "t2a-standard-4"},
+			expectError:    true,
+		},
+		{
+			name:           "invalid label",
+			clusterOptions: options.ClusterOptions{SandboxEnabled: true, SandboxNumNodes: 1, SandboxNodeLabels: []string{"team"}},
+			expectError:    true,
+		},
+		{
+			name:           "autopilot needs no node pool",
+			clusterOptions: options.ClusterOptions{SandboxEnabled: true, Autopilot: true},
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			d := &Deployer{ClusterOptions: &tc.clusterOptions}
+			if err := d.verifySandboxFlags(); (err != nil) != tc.expectError {
+				t.Errorf("expected error: %v, but got: %v", tc.expectError, err)
+			}
+		})
+	}
+}
+
+func TestCreateSandboxNodePoolCommand(t *testing.T) {
+	d := &Deployer{
+		ClusterOptions: &options.ClusterOptions{
+			SandboxEnabled:     true,
+			SandboxNumNodes:    2,
+			SandboxMachineType: "e2-standard-4",
+			SandboxNodeLabels:  []string{"team=sig-node", "tier=e2e"},
+		},
+	}
+	expected := []string{
+		"container", "node-pools", "create", "sandbox-pool",
+		"--quiet",
+		"--cluster=cluster-1",
+		"--project=project-1",
+		"--zone=us-central1-c",
+		"--sandbox=type=gvisor",
+		"--image-type=COS_CONTAINERD",
+		"--machine-type=e2-standard-4",
+		"--num-nodes=2",
+		"--node-labels=team=sig-node,tier=e2e",
+	}
+	args := d.createSandboxNodePoolCommand("project-1", cluster{name: "cluster-1"}, "--zone=us-central1-c", sandboxNodePoolName)
+	if !reflect.DeepEqual(args, expected) {
+		t.Errorf("expected %v, got %v", expected, args)
+	}
+}
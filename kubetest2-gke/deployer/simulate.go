@@ -0,0 +1,99 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package deployer
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// simulatedErrorOutputs are the gcloud outputs of the errors of
+// --simulate-error, formatted with the clusters command e.g. create
+var simulatedErrorOutputs = map[string]string{
+	// matches gceStockoutErrorPattern, so the creation is retried in the next zone
+	"stockout": "ERROR: (gcloud.container.clusters.%s) ResponseError: code=503, message=The zone 'projects/simulated/zones/simulated' does not have enough resources available to fulfill the request. Try a different zone, or try again later.",
+	"quota":    "ERROR: (gcloud.container.clusters.%s) ResponseError: code=403, message=Insufficient regional quota to satisfy request: resource \"CPUS\": request requires '12.0' and is short '4.0'. project has a quota of '8.0' with '8.0' available.",
+	"timeout":  "ERROR: (gcloud.container.clusters.%s) Operation [<Operation\n name: 'operation-simulated'>] is still running: timed out waiting for the operation to complete",
+}
+
+// simulatedErrorCommands are the gcloud container clusters commands failed
+// by --simulate-error for each phase
+var simulatedErrorCommands = map[string][]string{
+	"up":      {"create", "create-auto"},
+	"upgrade": {"upgrade"},
+	"down":    {"delete"},
+}
+
+// simulatedError fails the first gcloud container clusters command of the
+// phase with the gcloud output of the error, for self-testing the error
+// handling of the deployer e.g. the retry in the next zone on stockouts
+type simulatedError struct {
+	kind  string
+	phase string
+
+	mu       sync.Mutex
+	injected bool
+}
+
+// parseSimulatedError parses a --simulate-error of <error>:<phase>
+func parseSimulatedError(spec string) (*simulatedError, error) {
+	parts := strings.SplitN(spec, ":", 2)
+	if len(parts) != 2 {
+		return nil, fmt.Errorf("invalid --simulate-error %q, must be <error>:<phase> e.g. stockout:up", spec)
+	}
+	if _, ok := simulatedErrorOutputs[parts[0]]; !ok {
+		return nil, fmt.Errorf("invalid --simulate-error %q, the error must be one of stockout, quota or timeout", spec)
+	}
+	if _, ok := simulatedErrorCommands[parts[1]]; !ok {
+		return nil, fmt.Errorf("invalid --simulate-error %q, the phase must be one of up, upgrade or down", spec)
+	}
+	return &simulatedError{kind: parts[0], phase: parts[1]}, nil
+}
+
+// Inject implements exec.FaultInjector
+func (s *simulatedError) Inject(args []string) (string, error) {
+	if len(args) == 0 || filepath.Base(args[0]) != "gcloud" {
+		return "", nil
+	}
+	command := clustersCommand(args[1:])
+	matched := false
+	for _, c := range simulatedErrorCommands[s.phase] {
+		matched = matched || c == command
+	}
+	if !matched {
+		return "", nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.injected {
+		return "", nil
+	}
+	s.injected = true
+	return fmt.Sprintf(simulatedErrorOutputs[s.kind], command) + "\n", fmt.Errorf("exit status 1 (simulated %s error with --simulate-error)", s.kind)
+}
+
+// clustersCommand returns the command of gcloud container clusters args e.g. create
+func clustersCommand(args []string) string {
+	for i := 0; i+2 < len(args); i++ {
+		if args[i] == "container" && args[i+1] == "clusters" {
+			return args[i+2]
+		}
+	}
+	return ""
+}
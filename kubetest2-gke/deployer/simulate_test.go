@@ -0,0 +1,281 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package deployer
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+
+	"sigs.k8s.io/boskos/common"
+
+	"sigs.k8s.io/kubetest2/kubetest2-gke/deployer/options"
+	"sigs.k8s.io/kubetest2/pkg/exec"
+	"sigs.k8s.io/kubetest2/pkg/firewall"
+	"sigs.k8s.io/kubetest2/pkg/projects"
+)
+
+func TestParseSimulatedError(t *testing.T) {
+	testCases := []struct {
+		spec        string
+		expectError bool
+	}{
+		{spec: "stockout:up"},
+		{spec: "quota:up"},
+		{spec: "timeout:down"},
+		{spec: "stockout:upgrade"},
+		{spec: "stockout", expectError: true},
+		{spec: "outage:up", expectError: true},
+		{spec: "quota:test", expectError: true},
+	}
+	for _, tc := range testCases {
+		_, err := parseSimulatedError(tc.spec)
+		if tc.expectError && err == nil {
+			t.Errorf("expected an error for %q", tc.spec)
+		}
+		if !tc.expectError && err != nil {
+			t.Errorf("unexpected error for %q: %v", tc.spec, err)
+		}
+	}
+}
+
+func TestSimulatedErrorInject(t *testing.T) {
+	s, err := parseSimulatedError("stockout:up")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, args := range [][]string{
+		{"gcloud", "container", "clusters", "list"},
+		{"gcloud", "container", "clusters", "delete", "c1"},
+		{"kubectl", "container", "clusters", "create"},
+	} {
+		if _, err := s.Inject(args); err != nil {
+			t.Errorf("expected no failure of %v, but got %v", args, err)
+		}
+	}
+
+	create := []string{"gcloud", "beta", "container", "clusters", "create", "--quiet", "c1"}
+	output, err := s.Inject(create)
+	if err == nil {
+		t.Fatalf("expected the cluster creation to fail")
+	}
+	if !strings.Contains(output, "gcloud.container.clusters.create") {
+		t.Errorf("expected the output of the create command, but got %q", output)
+	}
+	// the stockout is retried in the next zone
+	d := &Deployer{retryableErrorPatternsCompiled: []*regexp.Regexp{regexp.MustCompile(gceStockoutErrorPattern)}}
+	if !d.isRetryableError(fmt.Errorf("error creating cluster: %v, output: %q", err, output)) {
+		t.Errorf("expected the simulated stockout to be retryable")
+	}
+	// only the first command fails
+	if _, err := s.Inject(create); err != nil {
+		t.Errorf("expected the retry to run, but got %v", err)
+	}
+}
+
+// stockoutInjector fails the cluster creations in the stocked out
+// <project>/<location>, recording the ones tried
+type stockoutInjector struct {
+	stockedOut map[string]bool
+
+	mu    sync.Mutex
+	tried []string
+}
+
+func (s *stockoutInjector) Inject(args []string) (string, error) {
+	if clustersCommand(args[1:]) != "create" {
+		return "", nil
+	}
+	var project, location string
+	for _, arg := range args {
+		if strings.HasPrefix(arg, "--project=") {
+			project = strings.TrimPrefix(arg, "--project=")
+		}
+		if strings.HasPrefix(arg, "--zone=") {
+			location = strings.TrimPrefix(arg, "--zone=")
+		}
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tried = append(s.tried, project+"/"+location)
+	if !s.stockedOut[project+"/"+location] {
+		return "", nil
+	}
+	return fmt.Sprintf(simulatedErrorOutputs["stockout"], "create") + "\n", fmt.Errorf("exit status 1")
+}
+
+func (s *stockoutInjector) triedLocations() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string{}, s.tried...)
+}
+
+// fakeGcloud puts a gcloud succeeding without output first on the PATH, for
+// the commands that are not failed by the fault injector
+func fakeGcloud(t *testing.T) func() {
+	dir, err := ioutil.TempDir("", "gcloud")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "gcloud"), []byte("#!/bin/sh\nexit 0\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	path := os.Getenv("PATH")
+	os.Setenv("PATH", dir+string(os.PathListSeparator)+path)
+	return func() {
+		os.Setenv("PATH", path)
+		os.RemoveAll(dir)
+	}
+}
+
+type fakeOptions struct {
+	runDir string
+}
+
+func (o *fakeOptions) HelpRequested() bool       { return false }
+func (o *fakeOptions) ShouldBuild() bool         { return false }
+func (o *fakeOptions) ShouldUp() bool            { return true }
+func (o *fakeOptions) ShouldDown() bool          { return true }
+func (o *fakeOptions) ShouldTest() bool          { return false }
+func (o *fakeOptions) SkipTestJUnitReport() bool { return false }
+func (o *fakeOptions) RunID() string             { return "run" }
+func (o *fakeOptions) RunDir() string            { return o.runDir }
+
+// newStockoutDeployer returns a deployer creating cluster c1 in the zones
+func newStockoutDeployer(runDir string, zones ...string) *Deployer {
+	return &Deployer{
+		Kubetest2CommonOptions: &fakeOptions{runDir: runDir},
+		CommonOptions:          &options.CommonOptions{GCPSSHKeyIgnored: true},
+		ProjectOptions:         &options.ProjectOptions{},
+		NetworkOptions:         &options.NetworkOptions{Network: "default"},
+		ClusterOptions: &options.ClusterOptions{
+			Environment:    "prod",
+			Clusters:       []string{"c1"},
+			Zones:          zones,
+			SkipQuotaCheck: true,
+		},
+		retryableErrorPatternsCompiled: []*regexp.Regexp{regexp.MustCompile(gceStockoutErrorPattern)},
+		totalTryCount:                  len(zones),
+	}
+}
+
+func TestStockoutRotatesZones(t *testing.T) {
+	defer fakeGcloud(t)()
+	injector := &stockoutInjector{stockedOut: map[string]bool{
+		"project1/us-central1-a": true,
+		"project1/us-central1-b": true,
+	}}
+	exec.SetFaultInjector(injector)
+	defer exec.SetFaultInjector(nil)
+
+	d := newStockoutDeployer("", "us-central1-a", "us-central1-b", "us-central1-c")
+	d.Projects = []string{"project1"}
+	if err := d.layoutClusters(); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.createClustersInLocations(); err != nil {
+		t.Fatalf("expected the clusters to be created in the last zone, but got: %v", err)
+	}
+	expected := []string{"project1/us-central1-a", "project1/us-central1-b", "project1/us-central1-c"}
+	if tried := injector.triedLocations(); !reflect.DeepEqual(tried, expected) {
+		t.Errorf("expected the creation to be tried in %v, but got %v", expected, tried)
+	}
+	if d.retryCount != 2 {
+		t.Errorf("expected the clusters to be in the zone of retry 2, but got retry %d", d.retryCount)
+	}
+}
+
+// fakeBoskos leases the projects in order, recording the released ones
+type fakeBoskos struct {
+	mu       sync.Mutex
+	leased   int
+	released []string
+}
+
+func (b *fakeBoskos) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch r.URL.Path {
+	case "/acquire":
+		b.leased++
+		resource := common.Resource{Name: fmt.Sprintf("project%d", b.leased), Type: r.URL.Query().Get("type"), State: "busy"}
+		if err := json.NewEncoder(w).Encode(&resource); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	case "/release":
+		b.released = append(b.released, r.URL.Query().Get("name"))
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func TestStockoutReleasesProjects(t *testing.T) {
+	defer fakeGcloud(t)()
+	injector := &stockoutInjector{stockedOut: map[string]bool{
+		"project1/us-central1-a": true,
+		"project1/us-central1-b": true,
+	}}
+	exec.SetFaultInjector(injector)
+	defer exec.SetFaultInjector(nil)
+	boskos := &fakeBoskos{}
+	server := httptest.NewServer(boskos)
+	defer server.Close()
+	runDir, err := ioutil.TempDir("", "run")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(runDir)
+
+	d := newStockoutDeployer(runDir, "us-central1-a", "us-central1-b")
+	d.firewalls = firewall.NewManager(runDir, true)
+	d.BoskosLocation = server.URL
+	d.BoskosAcquireTimeoutSeconds = 10
+	d.BoskosResourceType = []string{"gke-project"}
+	d.BoskosProjectsRequested = []int{1}
+	d.MaxProjectRetries = 1
+	lease, err := projects.Acquire(d.projectConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	d.projectLease = lease
+	d.Projects = lease.Projects()
+	if err := d.layoutClusters(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := d.CreateClusters(); err != nil {
+		t.Fatalf("expected the clusters to be created in new projects, but got: %v", err)
+	}
+	expected := []string{"project1/us-central1-a", "project1/us-central1-b", "project2/us-central1-a"}
+	if tried := injector.triedLocations(); !reflect.DeepEqual(tried, expected) {
+		t.Errorf("expected the creation to be tried in %v, but got %v", expected, tried)
+	}
+	boskos.mu.Lock()
+	defer boskos.mu.Unlock()
+	if expected := []string{"project1"}; !reflect.DeepEqual(boskos.released, expected) {
+		t.Errorf("expected the stocked out projects %v to be released, but got %v", expected, boskos.released)
+	}
+}
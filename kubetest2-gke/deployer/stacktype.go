@@ -0,0 +1,131 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package deployer
+
+import (
+	"fmt"
+
+	"sigs.k8s.io/kubetest2/pkg/exec"
+)
+
+// the values of --stack-type
+const (
+	stackTypeIPv4 = "ipv4"
+	stackTypeIPv6 = "ipv6"
+	stackTypeDual = "dual"
+)
+
+// the primary IPv4 range of the dual-stack subnet of the single project profile,
+// the secondary ranges for the pods and services are created by GKE
+const defaultDualStackSubnetRange = "10.0.0.0/20"
+
+func (d *Deployer) verifyStackTypeFlags() error {
+	switch d.StackType {
+	case "", stackTypeIPv4:
+		return nil
+	case stackTypeIPv6:
+		return fmt.Errorf("--stack-type=%s is not supported by GKE, use --stack-type=%s for IPv4/IPv6 dual-stack clusters", stackTypeIPv6, stackTypeDual)
+	case stackTypeDual:
+		// the subnets of auto mode networks only have IPv4 ranges
+		if d.Network == "default" {
+			return fmt.Errorf("--stack-type=%s requires a --network other than default, to create the dual-stack subnet in", stackTypeDual)
+		}
+		return nil
+	default:
+		return fmt.Errorf("--stack-type must be one of %s or %s, found %q", stackTypeIPv4, stackTypeDual, d.StackType)
+	}
+}
+
+func (d *Deployer) dualStack() bool {
+	return d.StackType == stackTypeDual
+}
+
+// ipv6AccessType returns the IPv6 access type of the dual-stack subnet,
+// private clusters only get internal IPv6 addresses like they have no external IPv4 addresses
+func (d *Deployer) ipv6AccessType() string {
+	if d.PrivateClusterAccessLevel != "" {
+		return "INTERNAL"
+	}
+	return "EXTERNAL"
+}
+
+// stackTypeSubnetArgs returns the args of gcloud compute networks subnets create for the stack type
+func (d *Deployer) stackTypeSubnetArgs() []string {
+	if !d.dualStack() {
+		return nil
+	}
+	return []string{
+		"--stack-type=IPV4_IPV6",
+		"--ipv6-access-type=" + d.ipv6AccessType(),
+	}
+}
+
+// stackTypeClusterArgs returns the args of the cluster creation command for the stack type,
+// dual-stack clusters must be VPC-native and use GKE Dataplane V2
+func (d *Deployer) stackTypeClusterArgs(region string) []string {
+	if !d.dualStack() {
+		return nil
+	}
+	args := []string{
+		"--stack-type=ipv4-ipv6",
+		"--enable-dataplane-v2",
+	}
+	// the clusters of the multi-project profile use the subnets of the shared VPC instead
+	if len(d.Projects) == 1 {
+		args = append(args, "--subnetwork="+dualStackSubnetName(d.Network, region))
+		// GKE in Autopilot mode clusters are always VPC-native and do not support the flag
+		if !d.Autopilot {
+			args = append(args, "--enable-ip-alias")
+		}
+	}
+	return args
+}
+
+// dualStackSubnetName returns the name of the dual-stack subnet of the single project profile
+func dualStackSubnetName(network, region string) string {
+	return network + "-" + region
+}
+
+// createDualStackSubnet creates the dual-stack subnet for the clusters of the host project
+func (d *Deployer) createDualStackSubnet(region string) error {
+	args := []string{
+		"compute", "networks", "subnets", "create",
+		dualStackSubnetName(d.Network, region),
+		"--project=" + d.Projects[0],
+		"--region=" + region,
+		"--network=" + d.Network,
+		"--range=" + defaultDualStackSubnetRange,
+	}
+	args = append(args, d.stackTypeSubnetArgs()...)
+	if d.PrivateClusterAccessLevel != "" {
+		args = append(args, "--enable-private-ip-google-access")
+	}
+	if err := runWithOutput(exec.Command("gcloud", args...)); err != nil {
+		return fmt.Errorf("failed to create the dual-stack subnet in region %s, IPv6 subnets may not be supported in the region: %w", region, err)
+	}
+	return nil
+}
+
+// deleteDualStackSubnet deletes the dual-stack subnet of the host project
+func (d *Deployer) deleteDualStackSubnet(region string) error {
+	return runWithOutput(exec.Command("gcloud", "compute", "networks", "subnets", "delete",
+		dualStackSubnetName(d.Network, region),
+		"--project="+d.Projects[0],
+		"--region="+region,
+		"--quiet",
+	))
+}
@@ -0,0 +1,97 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package deployer
+
+import (
+	"reflect"
+	"testing"
+
+	"sigs.k8s.io/kubetest2/kubetest2-gke/deployer/options"
+)
+
+func TestVerifyStackTypeFlags(t *testing.T) {
+	testCases := []struct {
+		name        string
+		stackType   string
+		network     string
+		expectError bool
+	}{
+		{name: "unset", network: "default"},
+		{name: "ipv4", stackType: stackTypeIPv4, network: "default"},
+		{name: "dual", stackType: stackTypeDual, network: "kt2-dual"},
+		{name: "dual in the default network", stackType: stackTypeDual, network: "default", expectError: true},
+		{name: "ipv6 only", stackType: stackTypeIPv6, network: "kt2-ipv6", expectError: true},
+		{name: "invalid", stackType: "ipv5", network: "default", expectError: true},
+	}
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			d := &Deployer{NetworkOptions: &options.NetworkOptions{StackType: tc.stackType, Network: tc.network}}
+			if err := d.verifyStackTypeFlags(); (err != nil) != tc.expectError {
+				t.Errorf("expected error: %v, but got: %v", tc.expectError, err)
+			}
+		})
+	}
+}
+
+func TestStackTypeClusterArgs(t *testing.T) {
+	testCases := []struct {
+		name      string
+		stackType string
+		projects  []string
+		autopilot bool
+		expected  []string
+	}{
+		{
+			name:     "ipv4",
+			projects: []string{"project"},
+		},
+		{
+			name:      "dual single project",
+			stackType: stackTypeDual,
+			projects:  []string{"project"},
+			expected:  []string{"--stack-type=ipv4-ipv6", "--enable-dataplane-v2", "--subnetwork=kt2-dual-us-central1", "--enable-ip-alias"},
+		},
+		{
+			name:      "dual autopilot",
+			stackType: stackTypeDual,
+			projects:  []string{"project"},
+			autopilot: true,
+			expected:  []string{"--stack-type=ipv4-ipv6", "--enable-dataplane-v2", "--subnetwork=kt2-dual-us-central1"},
+		},
+		{
+			name:      "dual multi-project",
+			stackType: stackTypeDual,
+			projects:  []string{"host", "service"},
+			expected:  []string{"--stack-type=ipv4-ipv6", "--enable-dataplane-v2"},
+		},
+	}
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			d := &Deployer{
+				CommonOptions:  &options.CommonOptions{},
+				ClusterOptions: &options.ClusterOptions{Autopilot: tc.autopilot},
+				NetworkOptions: &options.NetworkOptions{StackType: tc.stackType, Network: "kt2-dual"},
+				ProjectOptions: &options.ProjectOptions{Projects: tc.projects},
+			}
+			if actual := d.stackTypeClusterArgs("us-central1"); !reflect.DeepEqual(actual, tc.expected) {
+				t.Errorf("expected %v, but got %v", tc.expected, actual)
+			}
+		})
+	}
+}
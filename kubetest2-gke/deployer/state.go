@@ -0,0 +1,121 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package deployer
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"k8s.io/klog"
+
+	"sigs.k8s.io/kubetest2/pkg/projects"
+)
+
+// stateFile is the name of the file in the run dir persisting what the
+// previous invocations of the run created, for resuming it with --resume
+const stateFile = "gke-state.json"
+
+// state is the part of the deployer state that is not derived from the flags
+type state struct {
+	ClusterVersion string   `json:"clusterVersion,omitempty"`
+	Projects       []string `json:"projects,omitempty"`
+	// ProjectSource is the source the projects were acquired from
+	ProjectSource string `json:"projectSource,omitempty"`
+	// BoskosProjects is the number of the projects that were acquired from boskos
+	BoskosProjects int      `json:"boskosProjects,omitempty"`
+	Clusters       []string `json:"clusters,omitempty"`
+	RetryCount     int      `json:"retryCount,omitempty"`
+}
+
+func saveState(path string, s *state) error {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, data, 0644)
+}
+
+func loadState(path string) (*state, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	s := &state{}
+	if err := json.Unmarshal(data, s); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %v", path, err)
+	}
+	return s, nil
+}
+
+func (d *Deployer) statePath() string {
+	return filepath.Join(d.Kubetest2CommonOptions.RunDir(), stateFile)
+}
+
+// saveState persists the state of the deployer in the run dir
+func (d *Deployer) saveState() {
+	s := &state{
+		ClusterVersion: d.ClusterVersion,
+		Projects:       d.Projects,
+		ProjectSource:  string(d.projectLease.Source()),
+		BoskosProjects: d.totalBoskosProjectsRequested,
+		Clusters:       d.Clusters,
+		RetryCount:     d.retryCount,
+	}
+	if err := saveState(d.statePath(), s); err != nil {
+		klog.Warningf("Failed to save the deployer state: %v", err)
+	}
+}
+
+// RestoreState restores the state persisted by the previous invocations of
+// the resumed run, reacquiring the projects leased from boskos
+func (d *Deployer) RestoreState() error {
+	s, err := loadState(d.statePath())
+	if os.IsNotExist(err) {
+		klog.Warningf("No deployer state found at %s, nothing to restore", d.statePath())
+		return nil
+	} else if err != nil {
+		return err
+	}
+	if d.ClusterVersion == "" {
+		d.ClusterVersion = s.ClusterVersion
+	}
+	d.Clusters = s.Clusters
+	d.retryCount = s.RetryCount
+	if len(d.Projects) == 0 && len(s.Projects) != 0 {
+		source := projects.Source(s.ProjectSource)
+		// the state of the runs from before --project-source
+		if source == "" {
+			source = projects.Static
+		}
+		if s.BoskosProjects > 0 {
+			source = projects.Boskos
+			d.totalBoskosProjectsRequested = s.BoskosProjects
+		}
+		lease, err := projects.Resume(d.projectConfig(), source, s.Projects)
+		if err != nil {
+			return err
+		}
+		d.projectLease = lease
+		d.Projects = lease.Projects()
+	}
+	d.stateRestored = true
+	klog.V(1).Infof("Restored the deployer state from %s", d.statePath())
+	return nil
+}
@@ -0,0 +1,56 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package deployer
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestStateRoundTrip(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gke-state")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, stateFile)
+
+	if _, err := loadState(path); !os.IsNotExist(err) {
+		t.Errorf("expected a not exist error loading a missing state, but got: %v", err)
+	}
+
+	expected := &state{
+		ClusterVersion: "1.21.0-gke.1000+run-id",
+		Projects:       []string{"project-a", "project-b"},
+		BoskosProjects: 2,
+		Clusters:       []string{"kt2-run-id-1"},
+		RetryCount:     1,
+	}
+	if err := saveState(path, expected); err != nil {
+		t.Fatalf("failed to save the state: %v", err)
+	}
+	actual, err := loadState(path)
+	if err != nil {
+		t.Fatalf("failed to load the state: %v", err)
+	}
+	if !reflect.DeepEqual(expected, actual) {
+		t.Errorf("expected state %+v, but got %+v", expected, actual)
+	}
+}
@@ -0,0 +1,342 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package deployer
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// defaultNodePoolName is the name GKE gives to the node pool created with the cluster
+const defaultNodePoolName = "default-pool"
+
+// systemConfig is a node system config mapping, the values are either
+// nested systemConfigs or the scalars as written in the file
+type systemConfig map[string]interface{}
+
+// parseSystemConfig parses a node system config file, which is YAML made of
+// nested mappings of scalars only, so a full YAML parser is not required
+func parseSystemConfig(data string) (systemConfig, error) {
+	type level struct {
+		indent      int
+		childIndent int
+		config      systemConfig
+	}
+	root := systemConfig{}
+	stack := []*level{{indent: -1, childIndent: -1, config: root}}
+	for n, raw := range strings.Split(data, "\n") {
+		line := stripComment(strings.TrimRight(raw, " \r"))
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || trimmed == "---" {
+			continue
+		}
+		if strings.HasPrefix(line, "\t") {
+			return nil, fmt.Errorf("line %d: tabs are not allowed for indentation", n+1)
+		}
+		if strings.HasPrefix(trimmed, "- ") || trimmed == "-" {
+			return nil, fmt.Errorf("line %d: lists are not supported in the node system config", n+1)
+		}
+		indent := len(line) - len(strings.TrimLeft(line, " "))
+		for stack[len(stack)-1].indent >= indent {
+			stack = stack[:len(stack)-1]
+		}
+		parent := stack[len(stack)-1]
+		if parent.childIndent == -1 {
+			parent.childIndent = indent
+		} else if parent.childIndent != indent {
+			return nil, fmt.Errorf("line %d: unexpected indentation", n+1)
+		}
+
+		kv := strings.SplitN(trimmed, ":", 2)
+		if len(kv) != 2 {
+			return nil, fmt.Errorf("line %d: expected key: value, got %q", n+1, trimmed)
+		}
+		key, value := unquote(strings.TrimSpace(kv[0])), strings.TrimSpace(kv[1])
+		if _, exists := parent.config[key]; exists {
+			return nil, fmt.Errorf("line %d: duplicate key %q", n+1, key)
+		}
+		if value == "" {
+			child := systemConfig{}
+			parent.config[key] = child
+			stack = append(stack, &level{indent: indent, childIndent: -1, config: child})
+		} else {
+			parent.config[key] = value
+		}
+	}
+	return root, nil
+}
+
+// stripComment removes a trailing comment outside of quotes
+func stripComment(line string) string {
+	var quote rune
+	for i, c := range line {
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"':
+			quote = c
+		case c == '#' && (i == 0 || line[i-1] == ' '):
+			return strings.TrimRight(line[:i], " ")
+		}
+	}
+	return line
+}
+
+func unquote(s string) string {
+	if len(s) >= 2 && (s[0] == '\'' || s[0] == '"') && s[len(s)-1] == s[0] {
+		return s[1 : len(s)-1]
+	}
+	return s
+}
+
+// render renders the config as YAML with sorted keys
+func (c systemConfig) render() string {
+	var b strings.Builder
+	c.renderTo(&b, 0)
+	return b.String()
+}
+
+func (c systemConfig) renderTo(b *strings.Builder, indent int) {
+	keys := make([]string, 0, len(c))
+	for key := range c {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		b.WriteString(strings.Repeat(" ", indent) + key + ":")
+		if child, ok := c[key].(systemConfig); ok {
+			b.WriteString("\n")
+			child.renderTo(b, indent+2)
+		} else {
+			b.WriteString(" " + c[key].(string) + "\n")
+		}
+	}
+}
+
+// kubeletConfigValidators are the kubelet settings supported in the node
+// system config, see https://cloud.google.com/kubernetes-engine/docs/how-to/node-system-config#kubelet-options
+var kubeletConfigValidators = map[string]func(string) error{
+	"cpuManagerPolicy": func(v string) error {
+		if v != "none" && v != "static" {
+			return fmt.Errorf("expected none or static")
+		}
+		return nil
+	},
+	"cpuCFSQuota": func(v string) error {
+		_, err := strconv.ParseBool(v)
+		return err
+	},
+	"cpuCFSQuotaPeriod": func(v string) error {
+		_, err := time.ParseDuration(v)
+		return err
+	},
+	"podPidsLimit": func(v string) error {
+		limit, err := strconv.Atoi(v)
+		if err != nil {
+			return err
+		}
+		if limit < 1024 || limit > 4194304 {
+			return fmt.Errorf("expected a value between 1024 and 4194304")
+		}
+		return nil
+	},
+}
+
+// sysctlPrefixes are the prefixes of the sysctls supported in the node system config
+var sysctlPrefixes = []string{"net.core.", "net.ipv4.", "net.netfilter.", "kernel.shm", "kernel.msg", "vm."}
+
+var linuxConfigValidators = map[string]func(interface{}) error{
+	"sysctl": func(v interface{}) error {
+		sysctls, ok := v.(systemConfig)
+		if !ok {
+			return fmt.Errorf("expected a mapping of sysctls")
+		}
+		for name, value := range sysctls {
+			if _, ok := value.(string); !ok {
+				return fmt.Errorf("expected a value for sysctl %s", name)
+			}
+			supported := false
+			for _, prefix := range sysctlPrefixes {
+				supported = supported || strings.HasPrefix(name, prefix)
+			}
+			if !supported {
+				return fmt.Errorf("sysctl %s is not supported", name)
+			}
+		}
+		return nil
+	},
+	"cgroupMode": func(v interface{}) error {
+		switch v {
+		case "CGROUP_MODE_UNSPECIFIED", "CGROUP_MODE_V1", "CGROUP_MODE_V2":
+			return nil
+		}
+		return fmt.Errorf("expected one of CGROUP_MODE_UNSPECIFIED, CGROUP_MODE_V1, CGROUP_MODE_V2")
+	},
+	"hugepageConfig": func(v interface{}) error {
+		hugepages, ok := v.(systemConfig)
+		if !ok {
+			return fmt.Errorf("expected a mapping of hugepage sizes")
+		}
+		for size, count := range hugepages {
+			if size != "hugepage_size2m" && size != "hugepage_size1g" {
+				return fmt.Errorf("unknown hugepage size %s", size)
+			}
+			if s, ok := count.(string); !ok {
+				return fmt.Errorf("expected a count for %s", size)
+			} else if _, err := strconv.Atoi(unquote(s)); err != nil {
+				return fmt.Errorf("invalid count for %s: %v", size, err)
+			}
+		}
+		return nil
+	},
+}
+
+// validate validates the config against the settings supported by GKE
+func (c systemConfig) validate() error {
+	for section, value := range c {
+		settings, ok := value.(systemConfig)
+		if !ok {
+			return fmt.Errorf("%s: expected a mapping", section)
+		}
+		switch section {
+		case "kubeletConfig":
+			for key, v := range settings {
+				validator, ok := kubeletConfigValidators[key]
+				if !ok {
+					return fmt.Errorf("kubeletConfig: unknown setting %s", key)
+				}
+				s, ok := v.(string)
+				if !ok {
+					return fmt.Errorf("kubeletConfig.%s: expected a value", key)
+				}
+				if err := validator(unquote(s)); err != nil {
+					return fmt.Errorf("kubeletConfig.%s: invalid value %s: %v", key, s, err)
+				}
+			}
+		case "linuxConfig":
+			for key, v := range settings {
+				validator, ok := linuxConfigValidators[key]
+				if !ok {
+					return fmt.Errorf("linuxConfig: unknown setting %s", key)
+				}
+				if s, ok := v.(string); ok {
+					v = unquote(s)
+				}
+				if err := validator(v); err != nil {
+					return fmt.Errorf("linuxConfig.%s: %v", key, err)
+				}
+			}
+		default:
+			return fmt.Errorf("unknown section %s, expected kubeletConfig or linuxConfig", section)
+		}
+	}
+	return nil
+}
+
+// withKubeletOverrides returns a copy of the config with the KEY=VALUE kubelet overrides applied
+func (c systemConfig) withKubeletOverrides(overrides []string) (systemConfig, error) {
+	result := systemConfig{}
+	for section, value := range c {
+		result[section] = value
+	}
+	kubelet := systemConfig{}
+	if existing, ok := c["kubeletConfig"].(systemConfig); ok {
+		for key, value := range existing {
+			kubelet[key] = value
+		}
+	}
+	for _, override := range overrides {
+		kv := strings.SplitN(override, "=", 2)
+		if len(kv) != 2 || kv[0] == "" || kv[1] == "" {
+			return nil, fmt.Errorf("invalid kubelet config override %q, expected KEY=VALUE", override)
+		}
+		kubelet[kv[0]] = kv[1]
+	}
+	result["kubeletConfig"] = kubelet
+	return result, nil
+}
+
+// verifySystemConfigFlags validates the node system config and the kubelet
+// overrides, and prepares the config files of the node pools
+func (d *Deployer) verifySystemConfigFlags() error {
+	if d.NodeSystemConfigFile == "" && len(d.KubeletConfig) == 0 && len(d.AcceleratorKubeletConfig) == 0 {
+		return nil
+	}
+	if d.Autopilot {
+		return fmt.Errorf("--node-system-config-file, --kubelet-config and --accelerator-kubelet-config are not supported with --autopilot")
+	}
+	config := systemConfig{}
+	if d.NodeSystemConfigFile != "" {
+		data, err := ioutil.ReadFile(d.NodeSystemConfigFile)
+		if err != nil {
+			return fmt.Errorf("failed to read --node-system-config-file: %w", err)
+		}
+		if config, err = parseSystemConfig(string(data)); err != nil {
+			return fmt.Errorf("invalid --node-system-config-file %s: %w", d.NodeSystemConfigFile, err)
+		}
+		if err := config.validate(); err != nil {
+			return fmt.Errorf("invalid --node-system-config-file %s: %w", d.NodeSystemConfigFile, err)
+		}
+	}
+
+	d.systemConfigFiles = map[string]string{}
+	pools := map[string][]string{
+		defaultNodePoolName: d.KubeletConfig,
+	}
+	if d.Accelerator != "" {
+		pools[acceleratorNodePoolName] = d.AcceleratorKubeletConfig
+	} else if len(d.AcceleratorKubeletConfig) > 0 {
+		return fmt.Errorf("--accelerator-kubelet-config requires --accelerator")
+	}
+	for pool, overrides := range pools {
+		if len(overrides) == 0 {
+			// pass the file as is when there is nothing to override
+			if d.NodeSystemConfigFile != "" {
+				d.systemConfigFiles[pool] = d.NodeSystemConfigFile
+			}
+			continue
+		}
+		poolConfig, err := config.withKubeletOverrides(overrides)
+		if err != nil {
+			return err
+		}
+		if err := poolConfig.validate(); err != nil {
+			return fmt.Errorf("invalid kubelet config overrides for node pool %s: %w", pool, err)
+		}
+		path := filepath.Join(d.Kubetest2CommonOptions.RunDir(), "system-config-"+pool+".yaml")
+		if err := ioutil.WriteFile(path, []byte(poolConfig.render()), 0644); err != nil {
+			return fmt.Errorf("failed to write the node system config of node pool %s: %w", pool, err)
+		}
+		d.systemConfigFiles[pool] = path
+	}
+	return nil
+}
+
+// systemConfigArgs returns the gcloud flags applying the node system config of the node pool
+func (d *Deployer) systemConfigArgs(pool string) []string {
+	if path, ok := d.systemConfigFiles[pool]; ok {
+		return []string{"--system-config-from-file=" + path}
+	}
+	return nil
+}
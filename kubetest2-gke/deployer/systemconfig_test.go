@@ -0,0 +1,175 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package deployer
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseSystemConfig(t *testing.T) {
+	data := `# node system config
+kubeletConfig:
+  cpuManagerPolicy: static   # for the cpu manager tests
+  podPidsLimit: 4096
+linuxConfig:
+  sysctl:
+    net.core.somaxconn: '2048'
+    net.ipv4.tcp_rmem: "4096 87380 6291456"
+  hugepageConfig:
+    hugepage_size2m: 1024
+`
+	expected := systemConfig{
+		"kubeletConfig": systemConfig{
+			"cpuManagerPolicy": "static",
+			"podPidsLimit":     "4096",
+		},
+		"linuxConfig": systemConfig{
+			"sysctl": systemConfig{
+				"net.core.somaxconn": "'2048'",
+				"net.ipv4.tcp_rmem":  `"4096 87380 6291456"`,
+			},
+			"hugepageConfig": systemConfig{
+				"hugepage_size2m": "1024",
+			},
+		},
+	}
+	config, err := parseSystemConfig(data)
+	if err != nil {
+		t.Fatalf("did not expect an error, but got: %v", err)
+	}
+	if !reflect.DeepEqual(config, expected) {
+		t.Errorf("expected config %v, but got %v", expected, config)
+	}
+	if err := config.validate(); err != nil {
+		t.Errorf("expected the config to be valid, but got: %v", err)
+	}
+
+	rendered := `kubeletConfig:
+  cpuManagerPolicy: static
+  podPidsLimit: 4096
+linuxConfig:
+  hugepageConfig:
+    hugepage_size2m: 1024
+  sysctl:
+    net.core.somaxconn: '2048'
+    net.ipv4.tcp_rmem: "4096 87380 6291456"
+`
+	if actual := config.render(); actual != rendered {
+		t.Errorf("expected rendered config:\n%s\nbut got:\n%s", rendered, actual)
+	}
+}
+
+func TestParseSystemConfigErrors(t *testing.T) {
+	testCases := []struct {
+		name string
+		data string
+	}{
+		{name: "list", data: "linuxConfig:\n  sysctl:\n    - net.core.somaxconn\n"},
+		{name: "tabs", data: "kubeletConfig:\n\tcpuManagerPolicy: static\n"},
+		{name: "inconsistent indentation", data: "kubeletConfig:\n  cpuManagerPolicy: static\n   podPidsLimit: 4096\n"},
+		{name: "duplicate key", data: "kubeletConfig:\n  podPidsLimit: 4096\n  podPidsLimit: 8192\n"},
+		{name: "not a mapping", data: "kubeletConfig\n"},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			if _, err := parseSystemConfig(tc.data); err == nil {
+				t.Errorf("expected an error but got none")
+			}
+		})
+	}
+}
+
+func TestValidateSystemConfig(t *testing.T) {
+	testCases := []struct {
+		name        string
+		config      systemConfig
+		expectError bool
+	}{
+		{
+			name:   "valid kubelet config",
+			config: systemConfig{"kubeletConfig": systemConfig{"cpuCFSQuota": "false", "cpuCFSQuotaPeriod": "'100ms'"}},
+		},
+		{
+			name:        "unknown section",
+			config:      systemConfig{"windowsConfig": systemConfig{}},
+			expectError: true,
+		},
+		{
+			name:        "unknown kubelet setting",
+			config:      systemConfig{"kubeletConfig": systemConfig{"evictionHard": "memory.available<100Mi"}},
+			expectError: true,
+		},
+		{
+			name:        "invalid cpu manager policy",
+			config:      systemConfig{"kubeletConfig": systemConfig{"cpuManagerPolicy": "dynamic"}},
+			expectError: true,
+		},
+		{
+			name:        "pod pids limit out of range",
+			config:      systemConfig{"kubeletConfig": systemConfig{"podPidsLimit": "100"}},
+			expectError: true,
+		},
+		{
+			name:        "unsupported sysctl",
+			config:      systemConfig{"linuxConfig": systemConfig{"sysctl": systemConfig{"kernel.panic": "1"}}},
+			expectError: true,
+		},
+		{
+			name:   "cgroup mode",
+			config: systemConfig{"linuxConfig": systemConfig{"cgroupMode": "'CGROUP_MODE_V2'"}},
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			if err := tc.config.validate(); (err != nil) != tc.expectError {
+				t.Errorf("expected error: %v, but got: %v", tc.expectError, err)
+			}
+		})
+	}
+}
+
+func TestWithKubeletOverrides(t *testing.T) {
+	config := systemConfig{
+		"kubeletConfig": systemConfig{"cpuManagerPolicy": "none", "podPidsLimit": "4096"},
+		"linuxConfig":   systemConfig{"cgroupMode": "CGROUP_MODE_V2"},
+	}
+	actual, err := config.withKubeletOverrides([]string{"cpuManagerPolicy=static"})
+	if err != nil {
+		t.Fatalf("did not expect an error, but got: %v", err)
+	}
+	expected := systemConfig{
+		"kubeletConfig": systemConfig{"cpuManagerPolicy": "static", "podPidsLimit": "4096"},
+		"linuxConfig":   systemConfig{"cgroupMode": "CGROUP_MODE_V2"},
+	}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("expected config %v, but got %v", expected, actual)
+	}
+	// the original config is left untouched
+	if config["kubeletConfig"].(systemConfig)["cpuManagerPolicy"] != "none" {
+		t.Errorf("expected the original config to be unchanged")
+	}
+	if _, err := config.withKubeletOverrides([]string{"cpuManagerPolicy"}); err == nil {
+		t.Errorf("expected an error for an override without a value")
+	}
+}
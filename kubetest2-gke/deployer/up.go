@@ -21,9 +21,11 @@ import (
 	"io/ioutil"
 	"log"
 	"os"
+	osexec "os/exec"
 	"path/filepath"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/pkg/math"
 	"golang.org/x/sync/errgroup"
@@ -31,6 +33,9 @@ import (
 
 	"sigs.k8s.io/kubetest2/pkg/exec"
 	"sigs.k8s.io/kubetest2/pkg/metadata"
+	"sigs.k8s.io/kubetest2/pkg/metrics"
+	"sigs.k8s.io/kubetest2/pkg/secrets"
+	"sigs.k8s.io/kubetest2/pkg/trace"
 )
 
 // Deployer implementation methods below
@@ -38,6 +43,17 @@ func (d *Deployer) Up() error {
 	if err := d.Init(); err != nil {
 		return err
 	}
+	defer d.saveState()
+
+	if err := d.writeMirrorRepoList(); err != nil {
+		return err
+	}
+	if err := d.CheckQuota(); err != nil {
+		return fmt.Errorf("quota preflight check failed: %w", err)
+	}
+	if err := d.VerifyMachineTypeAvailability(); err != nil {
+		return err
+	}
 
 	defer func() {
 		if d.RepoRoot == "" {
@@ -49,26 +65,72 @@ func (d *Deployer) Up() error {
 		}
 	}()
 
-	if err := d.CreateNetwork(); err != nil {
+	if err := d.verifyClusterNamesAvailable(); err != nil {
 		return err
 	}
-	if err := d.CreateClusters(); err != nil {
+	if err := trace.Default().Wrap("CreateNetwork", d.CreateNetwork); err != nil {
+		return err
+	}
+	d.startCostEstimate()
+	created := time.Now()
+	if err := trace.Default().Wrap("CreateClusters", d.CreateClusters); err != nil {
 		return fmt.Errorf("error creating the clusters: %w", err)
 	}
+	d.startAlphaClusterWatchdog(created)
+	clusterMetadata := map[string]string{
+		metadata.ClusterNamesKey:   strings.Join(d.Clusters, ","),
+		metadata.ClusterVersionKey: d.ClusterVersion,
+	}
+	if d.SandboxEnabled {
+		clusterMetadata[metadata.RuntimeClassKey] = sandboxRuntimeClass
+	}
+	if err := metadata.Default().SetAll(clusterMetadata); err != nil {
+		klog.Warningf("Failed to record the clusters in the metadata: %v", err)
+	}
 
-	if err := d.TestSetup(); err != nil {
+	if err := trace.Default().Wrap("SetupFleet", d.SetupFleet); err != nil {
+		return fmt.Errorf("error setting up the fleet: %w", err)
+	}
+
+	if err := trace.Default().Wrap("TestSetup", d.TestSetup); err != nil {
 		return fmt.Errorf("error running setup for the tests: %w", err)
 	}
 
 	return nil
 }
 
+// CreateClusters creates the clusters, retrying in the next zone or region
+// on retryable errors, and in new boskos projects once they are all stocked
+// out with --max-project-retries
 func (d *Deployer) CreateClusters() error {
-	klog.V(2).Infof("Environment: %v", os.Environ())
+	klog.V(2).Infof("Environment: %v", secrets.Redact(strings.Join(os.Environ(), " ")))
+
+	for projectRetry := 0; ; projectRetry++ {
+		err := d.createClustersInLocations()
+		if err == nil || !d.isRetryableError(err) || !d.projectLease.Leased() || projectRetry >= d.MaxProjectRetries {
+			return err
+		}
+		klog.Warningf("Failed to create the clusters in all the locations of projects %v, retrying in new projects (%d/%d): %v",
+			d.Projects, projectRetry+1, d.MaxProjectRetries, err)
+		metrics.Default().AddRetries("Up", 1)
+		if err := d.rotateProjects(); err != nil {
+			return fmt.Errorf("failed to replace the projects: %w", err)
+		}
+		if err := trace.Default().Wrap("CreateNetwork", d.CreateNetwork); err != nil {
+			return err
+		}
+	}
+}
 
+// createClustersInLocations tries to create the clusters in each of the
+// --zone or --region in turn, until they are not failing with a retryable error
+func (d *Deployer) createClustersInLocations() error {
 	totalTryCount := math.Max(len(d.Regions), len(d.Zones))
 	for retryCount := 0; retryCount < totalTryCount; retryCount++ {
 		d.retryCount = retryCount
+		if retryCount > 0 {
+			metrics.Default().AddRetries("Up", 1)
+		}
 		shouldRetry, err := d.tryCreateClusters(retryCount)
 		if !shouldRetry {
 			return err
@@ -80,6 +142,16 @@ func (d *Deployer) CreateClusters() error {
 
 func (d *Deployer) tryCreateClusters(retryCount int) (shouldRetry bool, err error) {
 	shouldRetry = false
+	if retryCount > 0 {
+		// the checks of Up only covered the locations of the first try
+		if err = d.CheckQuota(); err != nil {
+			err = fmt.Errorf("quota preflight check failed: %w", err)
+			return
+		}
+		if err = d.VerifyMachineTypeAvailability(); err != nil {
+			return
+		}
+	}
 	if err = d.CreateSubnets(); err != nil {
 		return
 	}
@@ -88,13 +160,14 @@ func (d *Deployer) tryCreateClusters(retryCount int) (shouldRetry bool, err erro
 	}
 
 	eg := new(errgroup.Group)
-	locationArg := locationFlag(d.Regions, d.Zones, retryCount)
 	for i := range d.Projects {
 		project := d.Projects[i]
 		clusters := d.projectClustersLayout[project]
-		subNetworkArgs := subNetworkArgs(d.Autopilot, d.Projects, regionFromLocation(d.Regions, d.Zones, retryCount), d.Network, i)
+		projectSubNetworkArgs := subNetworkArgs(d.Autopilot, d.Projects, d.subnetRegion(project, retryCount), d.Network, i)
 		for j := range clusters {
 			cluster := clusters[j]
+			locationArg := d.clusterLocationFlag(cluster.name, retryCount)
+			subNetworkArgs := append(append([]string{}, projectSubNetworkArgs...), d.stackTypeClusterArgs(d.clusterRegion(cluster.name, retryCount))...)
 			eg.Go(
 				func() error {
 					return d.CreateCluster(project, cluster, subNetworkArgs, locationArg)
@@ -148,21 +221,36 @@ func (d *Deployer) CreateCluster(project string, cluster cluster, subNetworkArgs
 	// A few args are not supported in GKE Autopilot cluster creation, so they should be left unset.
 	// https://cloud.google.com/sdk/gcloud/reference/container/clusters/create-auto
 	if !d.Autopilot {
-		if d.MachineType != "" {
-			args = append(args, "--machine-type="+d.MachineType)
+		if machineType := d.clusterMachineType(cluster.name); machineType != "" {
+			args = append(args, "--machine-type="+machineType)
 		}
-		args = append(args, "--num-nodes="+strconv.Itoa(d.NumNodes))
-		if d.ImageType != "" {
-			args = append(args, "--image-type="+d.ImageType)
+		args = append(args, "--num-nodes="+strconv.Itoa(d.clusterNumNodes(cluster.name)))
+		if imageType := d.clusterImageType(cluster.name); imageType != "" {
+			args = append(args, "--image-type="+imageType)
 		}
 		if d.WorkloadIdentityEnabled {
 			args = append(args, fmt.Sprintf("--workload-pool=%s.svc.id.goog", project))
 		}
-	}
-
-	if d.ReleaseChannel != "" {
+		args = append(args, d.nodeSecurityArgs()...)
+		args = append(args, d.systemConfigArgs(defaultNodePoolName)...)
+		args = append(args, d.autoscalingArgs(defaultNodePoolName)...)
+		args = append(args, d.nodeManagementArgs()...)
+	}
+	// the labels are propagated to the node VMs and disks, for the leak check on down
+	args = append(args, d.resourceLabelsArgs()...)
+
+	args = append(args, d.notificationArgs(project)...)
+	args = append(args, d.controlPlaneLoggingArgs()...)
+	args = append(args, d.unstableAPIsArgs()...)
+	args = append(args, d.alphaArgs()...)
+
+	version := d.clusterVersion(cluster.name)
+	if d.DisableAutoUpgrade {
+		// auto-upgrade cannot be disabled for the node pools of clusters on a release channel
+		args = append(args, "--release-channel=None", "--cluster-version="+version)
+	} else if d.ReleaseChannel != "" {
 		args = append(args, "--release-channel="+d.ReleaseChannel)
-		if d.ClusterVersion == "latest" {
+		if version == "latest" {
 			// If latest is specified, get the latest version from server config for this channel.
 			actualVersion, err := resolveLatestVersionInChannel(locationArg, d.ReleaseChannel)
 			if err != nil {
@@ -171,13 +259,13 @@ func (d *Deployer) CreateCluster(project string, cluster cluster, subNetworkArgs
 			klog.V(0).Infof("Using the latest version %q in %q channel", actualVersion, d.ReleaseChannel)
 			args = append(args, "--cluster-version="+actualVersion)
 		} else {
-			args = append(args, "--cluster-version="+d.ClusterVersion)
+			args = append(args, "--cluster-version="+version)
 		}
 	} else {
-		args = append(args, "--cluster-version="+d.ClusterVersion)
-		releaseChannel, err := resolveReleaseChannelForClusterVersion(d.ClusterVersion, locationArg)
+		args = append(args, "--cluster-version="+version)
+		releaseChannel, err := resolveReleaseChannelForClusterVersion(version, locationArg)
 		if err != nil {
-			klog.Warningf("error resolving the release channel for %q: %v, will proceed with no channel", d.ClusterVersion, err)
+			klog.Warningf("error resolving the release channel for %q: %v, will proceed with no channel", version, err)
 		} else {
 			args = append(args, "--release-channel="+releaseChannel)
 		}
@@ -190,15 +278,34 @@ func (d *Deployer) CreateCluster(project string, cluster cluster, subNetworkArgs
 		//parse output for match with regex error
 		return fmt.Errorf("error creating cluster: %v, output: %q", err, output)
 	}
+	if err := d.addMaintenanceExclusion(project, cluster, locationArg); err != nil {
+		return err
+	}
 
 	if d.WindowsEnabled {
-		args := d.createWindowsNodePoolCommand(project, cluster, locationArg, "windows-pool", d.WindowsImageType)
+		args := d.createWindowsNodePoolCommand(project, cluster, locationArg, windowsNodePoolName, d.WindowsImageType)
 		output, err := runWithOutputAndReturn(exec.Command("gcloud", args...))
 		if err != nil {
 			return fmt.Errorf("error creating windows node-pool: %v, output: %q", err, output)
 		}
 	}
 
+	if d.Accelerator != "" {
+		args := d.createAcceleratorNodePoolCommand(project, cluster, locationArg, acceleratorNodePoolName)
+		output, err := runWithOutputAndReturn(exec.Command("gcloud", args...))
+		if err != nil {
+			return fmt.Errorf("error creating accelerator node-pool: %v, output: %q", err, output)
+		}
+	}
+
+	if d.SandboxEnabled && !d.Autopilot {
+		args := d.createSandboxNodePoolCommand(project, cluster, locationArg, sandboxNodePoolName)
+		output, err := runWithOutputAndReturn(exec.Command("gcloud", args...))
+		if err != nil {
+			return fmt.Errorf("error creating sandbox node-pool: %v, output: %q", err, output)
+		}
+	}
+
 	return nil
 }
 
@@ -237,6 +344,18 @@ func (d *Deployer) createWindowsNodePoolCommand(project string, cluster cluster,
 		fs = append(fs, "--machine-type="+d.WindowsMachineType)
 	}
 	fs = append(fs, "--num-nodes="+strconv.Itoa(d.WindowsNumNodes))
+	if d.WindowsOSVersion != "" {
+		fs = append(fs, "--windows-os-version="+d.WindowsOSVersion)
+	}
+	if len(d.WindowsNodeLabels) > 0 {
+		fs = append(fs, "--node-labels="+strings.Join(d.WindowsNodeLabels, ","))
+	}
+	if taints := d.windowsNodeTaints(); len(taints) > 0 {
+		fs = append(fs, "--node-taints="+strings.Join(taints, ","))
+	}
+	fs = append(fs, d.autoscalingArgs(nodePoolName)...)
+	fs = append(fs, d.nodeManagementArgs()...)
+	fs = append(fs, d.resourceLabelsArgs()...)
 
 	return fs
 }
@@ -246,15 +365,15 @@ func (d *Deployer) IsUp() (up bool, err error) {
 		return false, err
 	}
 
+	// use the kubeconfigs of the run rather than the user's, which concurrent runs would clobber
+	if _, err := d.Kubeconfig(); err != nil {
+		return false, err
+	}
 	for _, project := range d.Projects {
 		for _, cluster := range d.projectClustersLayout[project] {
-			if err := getClusterCredentials(project, locationFlag(d.Regions, d.Zones, d.retryCount), cluster.name); err != nil {
-				return false, err
-			}
-
 			// naively assume that if the api server reports nodes, the cluster is up
 			lines, err := exec.CombinedOutputLines(
-				exec.RawCommand("kubectl get nodes -o=name"),
+				exec.Command("kubectl", "--kubeconfig="+d.clusterKubeconfig(project, cluster), "get", "nodes", "-o=name"),
 			)
 			if err != nil {
 				return false, metadata.NewJUnitError(err, strings.Join(lines, "\n"))
@@ -283,6 +402,12 @@ func (d *Deployer) TestSetup() error {
 	if err := d.EnsureFirewallRules(); err != nil {
 		return err
 	}
+	if err := d.WaitForWindowsNodes(); err != nil {
+		return err
+	}
+	if err := d.InstallGPUDrivers(); err != nil {
+		return err
+	}
 	d.testPrepared = true
 	return nil
 }
@@ -295,19 +420,32 @@ func (d *Deployer) Kubeconfig() (string, error) {
 		return d.kubecfgPath, nil
 	}
 
+	auth, err := d.kubeconfigAuth(osexec.LookPath)
+	if err != nil {
+		return "", err
+	}
+	// not in the run dir, which is uploaded with the artifacts,
+	// as the kubeconfigs may embed access tokens
 	tmpdir, err := ioutil.TempDir("", "kubetest2-gke")
 	if err != nil {
 		return "", err
 	}
+	d.kubecfgDir = tmpdir
 
 	kubecfgFiles := make([]string, 0)
 	for _, project := range d.Projects {
 		for _, cluster := range d.projectClustersLayout[project] {
-			filename := filepath.Join(tmpdir, fmt.Sprintf("kubecfg-%s-%s", project, cluster.name))
+			filename := d.clusterKubeconfig(project, cluster)
 			if err := os.Setenv("KUBECONFIG", filename); err != nil {
 				return "", err
 			}
-			if err := getClusterCredentials(project, locationFlag(d.Regions, d.Zones, d.retryCount), cluster.name); err != nil {
+			loc := d.clusterLocationFlag(cluster.name, d.retryCount)
+			if auth == "" {
+				err = getClusterCredentials(project, loc, cluster.name)
+			} else {
+				err = writeClusterKubeconfig(filename, project, loc, cluster.name, auth)
+			}
+			if err != nil {
 				return "", err
 			}
 			kubecfgFiles = append(kubecfgFiles, filename)
@@ -318,6 +456,12 @@ func (d *Deployer) Kubeconfig() (string, error) {
 	return d.kubecfgPath, nil
 }
 
+// clusterKubeconfig returns the path to the kubeconfig of a single cluster,
+// only valid once Kubeconfig() has been called
+func (d *Deployer) clusterKubeconfig(project string, cluster cluster) string {
+	return filepath.Join(d.kubecfgDir, fmt.Sprintf("kubecfg-%s-%s", project, cluster.name))
+}
+
 // verifyCommonFlags validates flags for up phase.
 func (d *Deployer) VerifyUpFlags() error {
 	if len(d.Projects) == 0 {
@@ -346,6 +490,9 @@ func (d *Deployer) VerifyUpFlags() error {
 	if err := d.VerifyNetworkFlags(); err != nil {
 		return err
 	}
+	if err := d.verifyMirrorFlags(); err != nil {
+		return err
+	}
 	if err := d.VerifyLocationFlags(); err != nil {
 		return err
 	}
@@ -358,9 +505,55 @@ func (d *Deployer) VerifyUpFlags() error {
 	if err := validateReleaseChannel(d.ReleaseChannel); err != nil {
 		return err
 	}
+	if err := d.verifyResourceLabelsFlags(); err != nil {
+		return err
+	}
+	if err := d.verifyNodeFlags(); err != nil {
+		return err
+	}
+	if err := d.verifyFleetFlags(); err != nil {
+		return err
+	}
 	return nil
 }
 
+// verifyClusterNamesAvailable fails if a cluster to create already exists in
+// its project, e.g. because a concurrent run uses the same --cluster-name.
+// The clusters of a resumed run are expected to exist.
+func (d *Deployer) verifyClusterNamesAvailable() error {
+	if d.stateRestored {
+		return nil
+	}
+	for _, project := range d.Projects {
+		lines, err := exec.OutputLines(exec.Command("gcloud",
+			containerArgs("clusters", "list", "--project="+project, "--format=value(name)")...),
+		)
+		if err != nil {
+			return fmt.Errorf("error listing the clusters of project %s: %s", project, execError(err))
+		}
+		if taken := clusterNameCollisions(d.projectClustersLayout[project], lines); len(taken) > 0 {
+			return fmt.Errorf("clusters %s already exist in project %s, they may belong to another run: "+
+				"use a different --cluster-name or delete them first", strings.Join(taken, ", "), project)
+		}
+	}
+	return nil
+}
+
+// clusterNameCollisions returns the names of the clusters that are among the existing cluster names
+func clusterNameCollisions(clusters []cluster, existing []string) []string {
+	exists := map[string]bool{}
+	for _, name := range existing {
+		exists[strings.TrimSpace(name)] = true
+	}
+	var taken []string
+	for _, c := range clusters {
+		if exists[c.name] {
+			taken = append(taken, c.name)
+		}
+	}
+	return taken
+}
+
 func generateClusterNames(numClusters int, uid string) []string {
 	clusters := make([]string, numClusters)
 	for i := 1; i <= numClusters; i++ {
@@ -19,6 +19,9 @@ package deployer
 import (
 	"reflect"
 	"testing"
+
+	"sigs.k8s.io/kubetest2/kubetest2-gke/deployer/options"
+	"sigs.k8s.io/kubetest2/pkg/projects"
 )
 
 func TestClusterVersion(t *testing.T) {
@@ -116,3 +119,60 @@ func TestGenerateClusterNames(t *testing.T) {
 		})
 	}
 }
+
+func TestClusterNameCollisions(t *testing.T) {
+	clusters := []cluster{{index: 0, name: "kt2-foo-1"}, {index: 1, name: "kt2-foo-2"}}
+	testCases := []struct {
+		name     string
+		existing []string
+		expected []string
+	}{
+		{
+			name: "no existing clusters",
+		},
+		{
+			name:     "other clusters",
+			existing: []string{"kt2-bar-1", "kt2-foo-10"},
+		},
+		{
+			name:     "collision",
+			existing: []string{"kt2-bar-1", "kt2-foo-2"},
+			expected: []string{"kt2-foo-2"},
+		},
+		{
+			name:     "all collide",
+			existing: []string{"kt2-foo-2", "kt2-foo-1"},
+			expected: []string{"kt2-foo-1", "kt2-foo-2"},
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			if got := clusterNameCollisions(clusters, tc.existing); !reflect.DeepEqual(got, tc.expected) {
+				t.Errorf("expected %v, but got %v", tc.expected, got)
+			}
+		})
+	}
+}
+
+func TestRetryProjectConfig(t *testing.T) {
+	d := &Deployer{ProjectOptions: &options.ProjectOptions{
+		Projects:                []string{"p1", "p2"},
+		BoskosResourceType:      []string{"gke-project", "gke-internal-project"},
+		BoskosProjectsRequested: []int{1, 1},
+		BoskosRetryResourceType: []string{"gke-project-eu"},
+	}}
+
+	c := d.retryProjectConfig()
+	if c.Source != "boskos" || len(c.Projects) != 0 {
+		t.Errorf("expected to lease new projects from boskos, but got source %q and projects %v", c.Source, c.Projects)
+	}
+	expected := []projects.BoskosRequest{
+		{ResourceType: "gke-project-eu", Count: 1},
+		{ResourceType: "gke-internal-project", Count: 1},
+	}
+	if !reflect.DeepEqual(c.BoskosRequests, expected) {
+		t.Errorf("expected requests %v, but got %v", expected, c.BoskosRequests)
+	}
+}
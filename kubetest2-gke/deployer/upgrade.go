@@ -0,0 +1,109 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package deployer
+
+import (
+	"fmt"
+
+	"golang.org/x/sync/errgroup"
+	"k8s.io/klog"
+
+	"sigs.k8s.io/kubetest2/pkg/exec"
+	"sigs.k8s.io/kubetest2/pkg/types"
+)
+
+var _ types.DeployerWithUpgrade = &Deployer{}
+
+// upgradeArgs returns the gcloud args upgrading the control plane of the
+// cluster, or the node pool if not empty, to the version
+func upgradeArgs(project, locationArg, cluster, nodePool, version string) []string {
+	args := containerArgs("clusters", "upgrade", cluster,
+		"--project="+project,
+		locationArg,
+		"--cluster-version="+version,
+		"--quiet")
+	if nodePool == "" {
+		return append(args, "--master")
+	}
+	return append(args, "--node-pool="+nodePool)
+}
+
+// UpgradeControlPlane upgrades the control planes of all the clusters to the
+// version, gcloud returns once the upgrade operations are done
+func (d *Deployer) UpgradeControlPlane(version string) error {
+	if err := validateVersion(version); err != nil {
+		return err
+	}
+	return d.forEachCluster(func(project string, cluster cluster, locationArg string) error {
+		klog.V(1).Infof("Upgrading the control plane of cluster %s to %s", cluster.name, version)
+		if err := runWithOutput(exec.Command("gcloud", upgradeArgs(project, locationArg, cluster.name, "", version)...)); err != nil {
+			return fmt.Errorf("error upgrading the control plane of cluster %s: %w", cluster.name, err)
+		}
+		return nil
+	})
+}
+
+// UpgradeNodes upgrades all the node pools of all the clusters to the
+// version, one node pool of a cluster at a time as GKE does not allow
+// concurrent operations on a cluster
+func (d *Deployer) UpgradeNodes(version string) error {
+	if err := validateVersion(version); err != nil {
+		return err
+	}
+	if d.Autopilot {
+		return fmt.Errorf("the nodes of autopilot clusters are upgraded by GKE with the control plane")
+	}
+	return d.forEachCluster(func(project string, cluster cluster, locationArg string) error {
+		pools, err := exec.OutputLines(exec.Command("gcloud", containerArgs("node-pools", "list",
+			"--cluster="+cluster.name,
+			"--project="+project,
+			locationArg,
+			"--format=value(name)")...))
+		if err != nil {
+			return fmt.Errorf("error listing the node pools of cluster %s: %s", cluster.name, execError(err))
+		}
+		for _, pool := range pools {
+			if pool == "" {
+				continue
+			}
+			klog.V(1).Infof("Upgrading node pool %s of cluster %s to %s", pool, cluster.name, version)
+			if err := runWithOutput(exec.Command("gcloud", upgradeArgs(project, locationArg, cluster.name, pool, version)...)); err != nil {
+				return fmt.Errorf("error upgrading node pool %s of cluster %s: %w", pool, cluster.name, err)
+			}
+		}
+		return nil
+	})
+}
+
+// forEachCluster runs fn for all the clusters in parallel, returning the first error
+func (d *Deployer) forEachCluster(fn func(project string, cluster cluster, locationArg string) error) error {
+	if err := d.Init(); err != nil {
+		return err
+	}
+	eg := new(errgroup.Group)
+	for _, project := range d.Projects {
+		project := project
+		for _, cluster := range d.projectClustersLayout[project] {
+			cluster := cluster
+			locationArg := d.clusterLocationFlag(cluster.name, d.retryCount)
+			eg.Go(func() error {
+				return fn(project, cluster, locationArg)
+			})
+		}
+	}
+	return eg.Wait()
+}
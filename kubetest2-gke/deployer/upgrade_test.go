@@ -0,0 +1,33 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package deployer
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestUpgradeArgs(t *testing.T) {
+	expected := []string{"container", "clusters", "upgrade", "c1", "--project=p", "--zone=us-west1-b", "--cluster-version=1.21.0", "--quiet", "--master"}
+	if actual := upgradeArgs("p", "--zone=us-west1-b", "c1", "", "1.21.0"); !reflect.DeepEqual(actual, expected) {
+		t.Errorf("expected %v, but got %v", expected, actual)
+	}
+	expected = []string{"container", "clusters", "upgrade", "c1", "--project=p", "--region=us-central1", "--cluster-version=1.21.0", "--quiet", "--node-pool=default-pool"}
+	if actual := upgradeArgs("p", "--region=us-central1", "c1", "default-pool", "1.21.0"); !reflect.DeepEqual(actual, expected) {
+		t.Errorf("expected %v, but got %v", expected, actual)
+	}
+}
@@ -0,0 +1,183 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package deployer
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"k8s.io/klog"
+
+	"sigs.k8s.io/kubetest2/pkg/exec"
+)
+
+const (
+	windowsNodePoolName = "windows-pool"
+
+	// windowsNoScheduleTaint keeps the pods that do not tolerate it, i.e. the
+	// linux pods, off the Windows nodes
+	windowsNoScheduleTaint    = "node.kubernetes.io/os=windows:NoSchedule"
+	windowsNoScheduleTaintKey = "node.kubernetes.io/os"
+
+	// Windows nodes commonly take 15+ minutes to become Ready
+	defaultWindowsNodeReadyTimeoutMinutes = 30
+	windowsNodePollInterval               = 30 * time.Second
+)
+
+// windowsImageTypes are the image types of the Windows Server servicing channels,
+// the Long-Term Servicing Channel and the Semi-Annual Channel
+var windowsImageTypes = map[string]bool{
+	"WINDOWS_LTSC":            true,
+	"WINDOWS_LTSC_CONTAINERD": true,
+	"WINDOWS_SAC":             true,
+	"WINDOWS_SAC_CONTAINERD":  true,
+}
+
+var windowsOSVersions = map[string]bool{
+	"ltsc2019": true,
+	"ltsc2022": true,
+}
+
+var taintEffects = map[string]bool{
+	"NoSchedule":       true,
+	"PreferNoSchedule": true,
+	"NoExecute":        true,
+}
+
+func (d *Deployer) verifyWindowsFlags() error {
+	if !d.WindowsEnabled {
+		return nil
+	}
+	if d.Autopilot {
+		return fmt.Errorf("--enable-windows is not supported with --autopilot")
+	}
+	imageType := strings.ToUpper(d.WindowsImageType)
+	if d.WindowsImageType != "" && !windowsImageTypes[imageType] {
+		return fmt.Errorf("invalid --windows-image-type %q, expected one of WINDOWS_LTSC, WINDOWS_LTSC_CONTAINERD, WINDOWS_SAC, WINDOWS_SAC_CONTAINERD", d.WindowsImageType)
+	}
+	if d.WindowsOSVersion != "" {
+		if !windowsOSVersions[d.WindowsOSVersion] {
+			return fmt.Errorf("invalid --windows-os-version %q, expected one of ltsc2019 or ltsc2022", d.WindowsOSVersion)
+		}
+		if !strings.HasPrefix(imageType, "WINDOWS_LTSC") {
+			return fmt.Errorf("--windows-os-version requires a WINDOWS_LTSC --windows-image-type, got %q", d.WindowsImageType)
+		}
+	}
+	for _, label := range d.WindowsNodeLabels {
+		if kv := strings.SplitN(label, "=", 2); len(kv) != 2 || kv[0] == "" {
+			return fmt.Errorf("invalid Windows node label %q, expected KEY=VALUE", label)
+		}
+	}
+	for _, taint := range d.WindowsNodeTaints {
+		if err := validateTaint(taint); err != nil {
+			return err
+		}
+	}
+	if d.WindowsNodeReadyTimeoutMinutes <= 0 {
+		return fmt.Errorf("--windows-node-ready-timeout-minutes must be larger than 0")
+	}
+	return nil
+}
+
+// validateTaint validates a taint of the form KEY[=VALUE]:EFFECT
+func validateTaint(taint string) error {
+	i := strings.LastIndex(taint, ":")
+	if i <= 0 || !taintEffects[taint[i+1:]] {
+		return fmt.Errorf("invalid Windows node taint %q, expected KEY=VALUE:EFFECT with EFFECT one of NoSchedule, PreferNoSchedule, NoExecute", taint)
+	}
+	return nil
+}
+
+// windowsNodeTaints returns the taints of the Windows nodes,
+// adding the standard Windows taint unless disabled or overridden
+func (d *Deployer) windowsNodeTaints() []string {
+	taints := append([]string{}, d.WindowsNodeTaints...)
+	if !d.WindowsNoScheduleTaint {
+		return taints
+	}
+	for _, taint := range taints {
+		if strings.HasPrefix(taint, windowsNoScheduleTaintKey+"=") || strings.HasPrefix(taint, windowsNoScheduleTaintKey+":") {
+			return taints
+		}
+	}
+	return append(taints, windowsNoScheduleTaint)
+}
+
+// WaitForWindowsNodes waits for all the Windows nodes of the clusters to be Ready,
+// so that the tests do not start before the Windows nodes are bootstrapped
+func (d *Deployer) WaitForWindowsNodes() error {
+	if !d.WindowsEnabled {
+		return nil
+	}
+	nodesMultiplier := 1
+	if len(d.Regions) != 0 {
+		nodesMultiplier = defaultZonesPerRegion
+	}
+	expected := d.WindowsNumNodes * nodesMultiplier
+	timeout := time.Duration(d.WindowsNodeReadyTimeoutMinutes) * time.Minute
+
+	for _, project := range d.Projects {
+		for _, cluster := range d.projectClustersLayout[project] {
+			kubeconfig := d.clusterKubeconfig(project, cluster)
+			if err := waitForReadyWindowsNodes(kubeconfig, expected, timeout); err != nil {
+				return fmt.Errorf("error waiting for the Windows nodes of cluster %s: %v", cluster.name, err)
+			}
+		}
+	}
+	return nil
+}
+
+func waitForReadyWindowsNodes(kubeconfig string, expected int, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	for {
+		ready, err := readyWindowsNodes(kubeconfig)
+		if err != nil {
+			klog.Warningf("Failed to get the Windows nodes: %v", err)
+		} else if ready >= expected {
+			klog.V(1).Infof("%d Windows nodes are Ready", ready)
+			return nil
+		} else {
+			klog.V(1).Infof("%d of %d Windows nodes are Ready, waiting", ready, expected)
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("timed out after %v waiting for %d Ready Windows nodes", timeout, expected)
+		}
+		time.Sleep(windowsNodePollInterval)
+	}
+}
+
+func readyWindowsNodes(kubeconfig string) (int, error) {
+	out, err := exec.Output(exec.Command("kubectl", "--kubeconfig="+kubeconfig,
+		"get", "nodes", "--selector=kubernetes.io/os=windows",
+		"-o", `jsonpath={range .items[*]}{.status.conditions[?(@.type=="Ready")].status}{" "}{end}`))
+	if err != nil {
+		return 0, err
+	}
+	return countReady(string(out)), nil
+}
+
+// countReady counts the True statuses in a whitespace separated list of Ready condition statuses
+func countReady(s string) int {
+	ready := 0
+	for _, status := range strings.Fields(s) {
+		if status == "True" {
+			ready++
+		}
+	}
+	return ready
+}
@@ -0,0 +1,130 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package deployer
+
+import (
+	"reflect"
+	"testing"
+
+	"sigs.k8s.io/kubetest2/kubetest2-gke/deployer/options"
+)
+
+func TestVerifyWindowsFlags(t *testing.T) {
+	testCases := []struct {
+		name           string
+		clusterOptions options.ClusterOptions
+		expectError    bool
+	}{
+		{
+			name:           "windows disabled",
+			clusterOptions: options.ClusterOptions{WindowsImageType: "bogus"},
+		},
+		{
+			name: "valid flags",
+			clusterOptions: options.ClusterOptions{
+				WindowsEnabled:                 true,
+				WindowsImageType:               "windows_ltsc_containerd",
+				WindowsOSVersion:               "ltsc2022",
+				WindowsNodeLabels:              []string{"team=sig-windows"},
+				WindowsNodeTaints:              []string{"dedicated=windows:NoExecute"},
+				WindowsNodeReadyTimeoutMinutes: 30,
+			},
+		},
+		{
+			name:           "invalid image type",
+			clusterOptions: options.ClusterOptions{WindowsEnabled: true, WindowsImageType: "COS", WindowsNodeReadyTimeoutMinutes: 30},
+			expectError:    true,
+		},
+		{
+			name:           "os version on the semi-annual channel",
+			clusterOptions: options.ClusterOptions{WindowsEnabled: true, WindowsImageType: "WINDOWS_SAC", WindowsOSVersion: "ltsc2019", WindowsNodeReadyTimeoutMinutes: 30},
+			expectError:    true,
+		},
+		{
+			name:           "invalid label",
+			clusterOptions: options.ClusterOptions{WindowsEnabled: true, WindowsNodeLabels: []string{"team"}, WindowsNodeReadyTimeoutMinutes: 30},
+			expectError:    true,
+		},
+		{
+			name:           "invalid taint effect",
+			clusterOptions: options.ClusterOptions{WindowsEnabled: true, WindowsNodeTaints: []string{"dedicated=windows:Never"}, WindowsNodeReadyTimeoutMinutes: 30},
+			expectError:    true,
+		},
+		{
+			name:           "autopilot",
+			clusterOptions: options.ClusterOptions{WindowsEnabled: true, Autopilot: true, WindowsNodeReadyTimeoutMinutes: 30},
+			expectError:    true,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			d := &Deployer{ClusterOptions: &tc.clusterOptions}
+			if err := d.verifyWindowsFlags(); (err != nil) != tc.expectError {
+				t.Errorf("expected error: %v, but got: %v", tc.expectError, err)
+			}
+		})
+	}
+}
+
+func TestWindowsNodeTaints(t *testing.T) {
+	testCases := []struct {
+		name       string
+		taints     []string
+		noSchedule bool
+		expected   []string
+	}{
+		{
+			name:       "default taint",
+			noSchedule: true,
+			expected:   []string{windowsNoScheduleTaint},
+		},
+		{
+			name:       "default taint with custom taints",
+			taints:     []string{"dedicated=windows:NoExecute"},
+			noSchedule: true,
+			expected:   []string{"dedicated=windows:NoExecute", windowsNoScheduleTaint},
+		},
+		{
+			name:       "overridden default taint",
+			taints:     []string{"node.kubernetes.io/os=windows:NoExecute"},
+			noSchedule: true,
+			expected:   []string{"node.kubernetes.io/os=windows:NoExecute"},
+		},
+		{
+			name:     "disabled default taint",
+			expected: []string{},
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			d := &Deployer{ClusterOptions: &options.ClusterOptions{WindowsNodeTaints: tc.taints, WindowsNoScheduleTaint: tc.noSchedule}}
+			if actual := d.windowsNodeTaints(); !reflect.DeepEqual(actual, tc.expected) {
+				t.Errorf("expected taints %v, but got %v", tc.expected, actual)
+			}
+		})
+	}
+}
+
+func TestCountReady(t *testing.T) {
+	if ready := countReady("True False True Unknown "); ready != 2 {
+		t.Errorf("expected 2 ready nodes, but got %d", ready)
+	}
+}
@@ -0,0 +1,114 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package deployer implements the kubetest2 k3d deployer
+package deployer
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+
+	"github.com/octago/sflags/gen/gpflag"
+	"github.com/spf13/pflag"
+	"k8s.io/klog"
+
+	"sigs.k8s.io/kubetest2/pkg/types"
+)
+
+// Name is the name of the deployer
+const Name = "k3d"
+
+var GitTag string
+
+// New implements deployer.New for k3d
+func New(opts types.Options) (types.Deployer, *pflag.FlagSet) {
+	// create a deployer object and set fields that are not flag controlled
+	d := &deployer{
+		commonOptions: opts,
+		logsDir:       filepath.Join(opts.RunDir(), "logs"),
+		// not the run dir, which is uploaded with the artifacts while the
+		// kubeconfig holds the client key of the cluster admin
+		kubeconfigPath: filepath.Join(os.TempDir(), "kubetest2-k3d-"+opts.RunID(), "kubeconfig"),
+		ClusterName:    "kubetest2",
+		Servers:        1,
+	}
+	// register flags and return
+	return d, bindFlags(d)
+}
+
+// assert that New implements types.NewDeployer
+var _ types.NewDeployer = New
+
+type deployer struct {
+	// generic parts
+	commonOptions types.Options
+	// k3d specific details
+	ClusterName     string `flag:"cluster-name" desc:"the k3d cluster name"`
+	Servers         int    `desc:"--servers for k3d cluster create, the number of server (control plane) nodes"`
+	Agents          int    `desc:"--agents for k3d cluster create, the number of agent (worker) nodes"`
+	K3sVersion      string `flag:"k3s-version" desc:"the k3s version to use e.g. v1.20.4-k3s1, sets the rancher/k3s image tag. Ignored if --image is set"`
+	Image           string `desc:"--image for k3d cluster create, the k3s node image to use"`
+	RegistriesPath  string `flag:"registries-config" desc:"--registry-config for k3d cluster create, path to a k3s registries.yaml"`
+	ConfigPath      string `flag:"config" desc:"--config for k3d cluster create"`
+	KubeconfigPath  string `flag:"kubeconfig" desc:"the path to write the cluster kubeconfig to. Defaults to a kubeconfig in a temp directory of the run"`
+	CreateExtraArgs string `desc:"extra space separated flags for k3d cluster create"`
+
+	kubeconfigPath string
+	logsDir        string
+}
+
+func (d *deployer) Kubeconfig() (string, error) {
+	if d.KubeconfigPath != "" {
+		return d.KubeconfigPath, nil
+	}
+	if err := os.MkdirAll(filepath.Dir(d.kubeconfigPath), 0700); err != nil {
+		return "", err
+	}
+	return d.kubeconfigPath, nil
+}
+
+func (d *deployer) Version() string {
+	return GitTag
+}
+
+func (d *deployer) Build() error {
+	// k3d runs released k3s images, there is nothing to build
+	if d.commonOptions.ShouldBuild() {
+		klog.Warningf("--build is not supported by the k3d deployer, ignoring")
+	}
+	return nil
+}
+
+// helper used to create & bind a flagset to the deployer
+func bindFlags(d *deployer) *pflag.FlagSet {
+	flags, err := gpflag.Parse(d)
+	if err != nil {
+		klog.Fatalf("unable to generate flags from deployer")
+		return nil
+	}
+
+	klog.InitFlags(nil)
+	flags.AddGoFlagSet(flag.CommandLine)
+
+	return flags
+}
+
+// assert that deployer implements types.DeployerWithKubeconfig
+var _ types.DeployerWithKubeconfig = &deployer{}
+
+// well-known k3d related constants
+const k3sImageRepository = "rancher/k3s"
@@ -0,0 +1,67 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package deployer
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"k8s.io/klog"
+
+	"sigs.k8s.io/kubetest2/pkg/exec"
+)
+
+// DumpClusterLogs writes the logs of every k3d node container, which include
+// the k3s server and agent logs, to the logs directory
+func (d *deployer) DumpClusterLogs() error {
+	klog.V(0).Infof("DumpClusterLogs(): exporting k3d cluster logs...\n")
+
+	nodes, err := exec.OutputLines(exec.Command("docker", "ps", "-a",
+		"--filter", "label=k3d.cluster="+d.ClusterName,
+		"--format", "{{.Names}}"))
+	if err != nil {
+		return fmt.Errorf("failed to list the k3d nodes: %v", err)
+	}
+	if err := os.MkdirAll(d.logsDir, os.ModePerm); err != nil {
+		return err
+	}
+
+	var errs []error
+	for _, node := range nodes {
+		if err := dumpNodeLogs(node, filepath.Join(d.logsDir, node+".log")); err != nil {
+			klog.Warningf("failed to dump the logs of node %s: %v", node, err)
+			errs = append(errs, err)
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("failed to dump the logs of %d node(s)", len(errs))
+	}
+	return nil
+}
+
+func dumpNodeLogs(node, path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	cmd := exec.Command("docker", "logs", node)
+	cmd.SetStdout(f)
+	cmd.SetStderr(f)
+	return cmd.Run()
+}
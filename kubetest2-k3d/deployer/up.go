@@ -0,0 +1,98 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package deployer
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"k8s.io/klog"
+
+	"sigs.k8s.io/kubetest2/pkg/exec"
+	"sigs.k8s.io/kubetest2/pkg/metadata"
+	"sigs.k8s.io/kubetest2/pkg/process"
+)
+
+func (d *deployer) IsUp() (up bool, err error) {
+	kubeconfig, err := d.Kubeconfig()
+	if err != nil {
+		return false, err
+	}
+	// naively assume that if the api server reports nodes, the cluster is up
+	lines, err := exec.CombinedOutputLines(
+		exec.Command("kubectl", "--kubeconfig", kubeconfig, "get", "nodes", "-o=name"),
+	)
+	if err != nil {
+		return false, metadata.NewJUnitError(err, strings.Join(lines, "\n"))
+	}
+	return len(lines) > 0, nil
+}
+
+func (d *deployer) Up() error {
+	if d.Servers < 1 {
+		return fmt.Errorf("--servers must be at least 1")
+	}
+	if d.Agents < 0 {
+		return fmt.Errorf("--agents must not be negative")
+	}
+
+	klog.V(0).Infof("Up(): creating k3d cluster...\n")
+	// we want to see the output so use process.ExecJUnit
+	if err := process.ExecJUnit("k3d", d.createArgs(), os.Environ()); err != nil {
+		return err
+	}
+
+	kubeconfig, err := d.Kubeconfig()
+	if err != nil {
+		return err
+	}
+	klog.V(0).Infof("Up(): writing kubeconfig to %s\n", kubeconfig)
+	return process.ExecJUnit("k3d", []string{
+		"kubeconfig", "write", d.ClusterName,
+		"--output", kubeconfig,
+	}, os.Environ())
+}
+
+func (d *deployer) createArgs() []string {
+	args := []string{
+		"cluster", "create", d.ClusterName,
+		"--servers", strconv.Itoa(d.Servers),
+		"--agents", strconv.Itoa(d.Agents),
+		// the kubeconfig is written explicitly to its own temp directory instead
+		"--kubeconfig-update-default=false",
+		"--kubeconfig-switch-context=false",
+		"--wait",
+	}
+
+	if d.Image != "" {
+		args = append(args, "--image", d.Image)
+	} else if d.K3sVersion != "" {
+		args = append(args, "--image", k3sImageRepository+":"+d.K3sVersion)
+	}
+	if d.RegistriesPath != "" {
+		args = append(args, "--registry-config", d.RegistriesPath)
+	}
+	if d.ConfigPath != "" {
+		args = append(args, "--config", d.ConfigPath)
+	}
+	if d.CreateExtraArgs != "" {
+		args = append(args, strings.Fields(d.CreateExtraArgs)...)
+	}
+	return args
+}
@@ -16,15 +16,30 @@ limitations under the License.
 package deployer
 
 import (
+	"fmt"
 	"os"
+	"regexp"
+	"strings"
 
 	"k8s.io/klog"
 
 	"sigs.k8s.io/kubetest2/pkg/build"
+	"sigs.k8s.io/kubetest2/pkg/exec"
 	"sigs.k8s.io/kubetest2/pkg/process"
 )
 
 func (d *deployer) Build() error {
+	image := d.builtNodeImage()
+	cachedImage := d.cachedNodeImage()
+	if cachedImage != "" && imageExists(cachedImage) {
+		klog.V(0).Infof("Build(): reusing the node image %s built from the same sources\n", cachedImage)
+		if err := tagImage(cachedImage, image); err != nil {
+			return err
+		}
+		build.StoreCommonBinaries(d.KubeRoot, d.commonOptions.RunDir())
+		return d.copyToMirror()
+	}
+
 	args := []string{
 		"build", "node-image",
 	}
@@ -34,19 +49,105 @@ func (d *deployer) Build() error {
 	if d.KubeRoot != "" {
 		args = append(args, "--kube-root", d.KubeRoot)
 	}
-	// set the explicitly specified image name if set
-	if d.NodeImage != "" {
-		args = append(args, "--image", d.NodeImage)
-	} else if d.commonOptions.ShouldBuild() {
-		// otherwise if we just built an image, use that
-		args = append(args, "--image", kindDefaultBuiltImageName)
-	}
+	args = append(args, "--image", image)
 
-	klog.V(0).Infof("Build(): building kind node image...\n")
+	klog.V(0).Infof("Build(): building kind node image %s...\n", image)
 	// we want to see the output so use process.ExecJUnit
 	if err := process.ExecJUnit("kind", args, os.Environ()); err != nil {
 		return err
 	}
+	if cachedImage != "" {
+		if err := tagImage(image, cachedImage); err != nil {
+			klog.Warningf("failed to cache the node image: %v", err)
+		}
+	}
 	build.StoreCommonBinaries(d.KubeRoot, d.commonOptions.RunDir())
+	return d.copyToMirror()
+}
+
+// invalidTagChars matches the characters that are not allowed in image tags
+var invalidTagChars = regexp.MustCompile(`[^A-Za-z0-9_.-]`)
+
+// builtNodeImage returns the node image built by Build and used by Up: the
+// --image-name if set, otherwise kindest/node tagged with the run id so that
+// concurrent runs do not use each other's images
+func (d *deployer) builtNodeImage() string {
+	if d.NodeImage != "" {
+		return d.NodeImage
+	}
+	return kindBuiltImageRepository + ":" + invalidTagChars.ReplaceAllString(d.commonOptions.RunID(), "-")
+}
+
+// cachedNodeImage returns the image caching the node image built by this kind
+// from the sources of --kube-root, empty if --build-cache is not set or the
+// sources have no tree hash
+func (d *deployer) cachedNodeImage() string {
+	if !d.BuildCache {
+		return ""
+	}
+	if d.KubeRoot == "" {
+		klog.Warningf("Not using the build cache: it requires --kube-root")
+		return ""
+	}
+	tree, err := build.SourceTreeHash(d.KubeRoot)
+	if err != nil {
+		klog.Warningf("Not using the build cache: %v", err)
+		return ""
+	}
+	version, err := kindVersion()
+	if err != nil {
+		klog.Warningf("Not using the build cache: %v", err)
+		return ""
+	}
+	return cachedNodeImageName(tree, version, d.BuildType)
+}
+
+// cachedNodeImageName returns the image caching the node image built from the
+// sources of the tree hash by the kind version with the build type, as the
+// node images of a kind version do not necessarily work with other versions
+func cachedNodeImageName(tree, kindVersion, buildType string) string {
+	if len(tree) > 16 {
+		tree = tree[:16]
+	}
+	tag := kindCachedImageTagPrefix + tree + "-kind" + invalidTagChars.ReplaceAllString(kindVersion, "-")
+	if buildType != "" {
+		tag += "-" + invalidTagChars.ReplaceAllString(buildType, "-")
+	}
+	return kindBuiltImageRepository + ":" + tag
+}
+
+// kindVersion returns the version of the kind binary, e.g. v0.11.1
+func kindVersion() (string, error) {
+	lines, err := exec.OutputLines(exec.Command("kind", "version"))
+	if err != nil {
+		return "", fmt.Errorf("failed to get the kind version: %v", err)
+	}
+	// e.g. kind v0.11.1 go1.16.4 linux/amd64
+	if len(lines) == 0 || len(strings.Fields(lines[0])) < 2 {
+		return "", fmt.Errorf("failed to parse the kind version from %q", lines)
+	}
+	return strings.Fields(lines[0])[1], nil
+}
+
+// removeImage removes the image tag from the local docker images, and the
+// image with it unless it has other tags
+func removeImage(image string) error {
+	if err := exec.Command("docker", "rmi", image).Run(); err != nil {
+		return fmt.Errorf("failed to remove the node image %s: %v", image, err)
+	}
+	return nil
+}
+
+// imageExists returns true if the image is in the local docker images
+func imageExists(image string) bool {
+	cmd := exec.Command("docker", "image", "inspect", image)
+	exec.NoOutput(cmd)
+	return cmd.Run() == nil
+}
+
+func tagImage(source, target string) error {
+	if err := exec.Command("docker", "tag", source, target).Run(); err != nil {
+		return fmt.Errorf("failed to tag the node image %s as %s: %v", source, target, err)
+	}
 	return nil
 }
@@ -0,0 +1,35 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package deployer
+
+import (
+	"fmt"
+
+	"sigs.k8s.io/kubetest2/pkg/types"
+)
+
+// assert that deployer implements types.DeployerWithDebugHints
+var _ types.DeployerWithDebugHints = &deployer{}
+
+// DebugHints returns the commands to get into the node containers of the cluster
+func (d *deployer) DebugHints() []string {
+	name := d.clusterName()
+	return []string{
+		fmt.Sprintf("kind get nodes --name=%s", name),
+		fmt.Sprintf("docker exec -it %s-control-plane bash", name),
+	}
+}
@@ -40,6 +40,10 @@ func New(opts types.Options) (types.Deployer, *pflag.FlagSet) {
 	d := &deployer{
 		commonOptions: opts,
 		logsDir:       filepath.Join(opts.RunDir(), "logs"),
+		// not the user's kubeconfig, which concurrent runs would clobber, nor
+		// the run dir, which is uploaded with the artifacts while the
+		// kubeconfig holds the client key of the cluster admin
+		kubeconfigPath: filepath.Join(os.TempDir(), "kubetest2-kind-"+opts.RunID(), "kubeconfig"),
 	}
 	// register flags and return
 	return d, bindFlags(d)
@@ -52,31 +56,52 @@ type deployer struct {
 	// generic parts
 	commonOptions types.Options
 	// kind specific details
-	NodeImage      string `flag:"image-name" desc:"the image name to use for build and up"`
+	NodeImage      string `flag:"image-name" desc:"the image name to use for build and up, defaults to kindest/node tagged with the run id when building"`
 	ClusterName    string `flag:"cluster-name" desc:"the kind cluster --name"`
 	BuildType      string `desc:"--type for kind build node-image"`
 	ConfigPath     string `flag:"config" desc:"--config for kind create cluster"`
-	KubeconfigPath string `flag:"kubeconfig" desc:"--kubeconfig flag for kind create cluster"`
+	KubeconfigPath string `flag:"kubeconfig" desc:"--kubeconfig flag for kind create cluster. Defaults to a kubeconfig in a temp directory of the run"`
 	KubeRoot       string `desc:"--kube-root for kind build node-image"`
-
-	logsDir string
+	BuildCache     bool   `flag:"build-cache" desc:"reuse the node image previously built from the same --kube-root sources (by git tree hash), kind version and --build-type instead of rebuilding it"`
+	StackType      string `flag:"stack-type" desc:"IP family of the cluster, one of ipv4, ipv6 or dual, set as networking.ipFamily in the generated kind config, cannot be used with --config"`
+	MirrorRegistry string `flag:"mirror-registry" desc:"registry the --mirror-images are copied to during build, and the nodes and the e2e tests pull the images from, e.g. localhost:5001, cannot be used with --config"`
+	MirrorEndpoint string `flag:"mirror-endpoint" desc:"endpoint of the --mirror-registry as seen from the nodes, e.g. http://kind-registry:5000, defaults to http://<mirror-registry>"`
+	MirrorImages   string `flag:"mirror-images" desc:"file listing the images to copy to the --mirror-registry during build, one per line"`
+
+	kubeconfigPath string
+	logsDir        string
+	// featureGates and runtimeConfig are set by SetFeatureGates
+	featureGates  map[string]bool
+	runtimeConfig map[string]string
 }
 
 func (d *deployer) Kubeconfig() (string, error) {
 	if d.KubeconfigPath != "" {
 		return d.KubeconfigPath, nil
 	}
-	home, err := os.UserHomeDir()
-	if err != nil {
+	if err := os.MkdirAll(filepath.Dir(d.kubeconfigPath), 0700); err != nil {
 		return "", err
 	}
-	return filepath.Join(home, ".kube", "config"), nil
+	return d.kubeconfigPath, nil
+}
+
+// clusterName returns the --name of the kind cluster
+func (d *deployer) clusterName() string {
+	if d.ClusterName == "" {
+		// the kind default
+		return "kind"
+	}
+	return d.ClusterName
 }
 
 func (d *deployer) Version() string {
 	return GitTag
 }
 
+func (d *deployer) Description() string {
+	return "creates local clusters in docker containers with kind"
+}
+
 // helper used to create & bind a flagset to the deployer
 func bindFlags(d *deployer) *pflag.FlagSet {
 	flags, err := gpflag.Parse(d)
@@ -95,4 +120,9 @@ func bindFlags(d *deployer) *pflag.FlagSet {
 var _ types.DeployerWithKubeconfig = &deployer{}
 
 // well-known kind related constants
-const kindDefaultBuiltImageName = "kindest/node:latest"
+const (
+	// kindBuiltImageRepository is the repository of the node images built by Build
+	kindBuiltImageRepository = "kindest/node"
+	// kindCachedImageTagPrefix prefixes the tags of the node images cached by source tree hash
+	kindCachedImageTagPrefix = "kubetest2-src-"
+)
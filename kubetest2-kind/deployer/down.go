@@ -17,6 +17,7 @@ package deployer
 
 import (
 	"os"
+	"path/filepath"
 
 	"k8s.io/klog"
 
@@ -24,12 +25,30 @@ import (
 )
 
 func (d *deployer) Down() error {
+	kubeconfig, err := d.Kubeconfig()
+	if err != nil {
+		return err
+	}
 	args := []string{
 		"delete", "cluster",
 		"--name", d.ClusterName,
+		"--kubeconfig", kubeconfig,
 	}
 
 	klog.V(0).Infof("Down(): deleting kind cluster...%s\n", d.ClusterName)
 	// we want to see the output so use process.ExecJUnit
-	return process.ExecJUnit("kind", args, os.Environ())
+	if err := process.ExecJUnit("kind", args, os.Environ()); err != nil {
+		return err
+	}
+	// the images tagged with the run id would otherwise pile up, the cached
+	// image of the build is kept under its own tag
+	if d.NodeImage == "" && d.commonOptions.ShouldBuild() && imageExists(d.builtNodeImage()) {
+		if err := removeImage(d.builtNodeImage()); err != nil {
+			klog.Warningf("%v", err)
+		}
+	}
+	if d.KubeconfigPath == "" {
+		return os.RemoveAll(filepath.Dir(d.kubeconfigPath))
+	}
+	return nil
 }
@@ -0,0 +1,66 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package deployer
+
+import (
+	"fmt"
+	"sort"
+
+	"sigs.k8s.io/kubetest2/pkg/types"
+)
+
+var _ types.DeployerWithFeatureGates = &deployer{}
+
+// SetFeatureGates sets the feature gates and the runtime config of the
+// generated kind config, which kind passes to all the components
+func (d *deployer) SetFeatureGates(featureGates map[string]bool, runtimeConfig map[string]string) error {
+	if d.ConfigPath != "" {
+		return fmt.Errorf("--kube-feature-gates and --runtime-config cannot be used with --config, " +
+			"set featureGates and runtimeConfig in the config instead")
+	}
+	d.featureGates = featureGates
+	d.runtimeConfig = runtimeConfig
+	return nil
+}
+
+// featureGatesConfig returns the featureGates and runtimeConfig of the kind config
+func (d *deployer) featureGatesConfig() string {
+	config := ""
+	if len(d.featureGates) > 0 {
+		names := make([]string, 0, len(d.featureGates))
+		for name := range d.featureGates {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		config += "featureGates:\n"
+		for _, name := range names {
+			config += fmt.Sprintf("  %q: %t\n", name, d.featureGates[name])
+		}
+	}
+	if len(d.runtimeConfig) > 0 {
+		keys := make([]string, 0, len(d.runtimeConfig))
+		for key := range d.runtimeConfig {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		config += "runtimeConfig:\n"
+		for _, key := range keys {
+			config += fmt.Sprintf("  %q: %q\n", key, d.runtimeConfig[key])
+		}
+	}
+	return config
+}
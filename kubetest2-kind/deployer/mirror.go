@@ -0,0 +1,82 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package deployer
+
+import (
+	"fmt"
+	"strings"
+
+	"sigs.k8s.io/kubetest2/pkg/mirror"
+)
+
+// the registries always mirrored, as they host the images of the kind node image,
+// and of the e2e tests
+var defaultMirroredHosts = []string{"docker.io", "registry.k8s.io"}
+
+func (d *deployer) mirror() *mirror.Mirror {
+	return &mirror.Mirror{
+		Registry: d.MirrorRegistry,
+		Insecure: strings.HasPrefix(d.mirrorEndpoint(), "http://"),
+	}
+}
+
+// mirrorEndpoint returns the endpoint of the mirror as seen from the kind nodes
+func (d *deployer) mirrorEndpoint() string {
+	if d.MirrorEndpoint != "" {
+		return d.MirrorEndpoint
+	}
+	return "http://" + d.MirrorRegistry
+}
+
+func (d *deployer) mirrorImages() ([]string, error) {
+	if d.MirrorImages == "" {
+		return nil, nil
+	}
+	return mirror.ReadImages(d.MirrorImages)
+}
+
+// copyToMirror copies the --mirror-images to the --mirror-registry during the build,
+// so that up and the tests do not pull from the public registries
+func (d *deployer) copyToMirror() error {
+	if d.MirrorRegistry == "" {
+		return nil
+	}
+	images, err := d.mirrorImages()
+	if err != nil {
+		return err
+	}
+	return d.mirror().Copy(images)
+}
+
+// mirrorConfigPatch returns the containerd config patch of the nodes pulling from the mirror
+func (d *deployer) mirrorConfigPatch() (string, error) {
+	images, err := d.mirrorImages()
+	if err != nil {
+		return "", err
+	}
+	hosts := mirror.Hosts(append(images, defaultMirroredHosts...))
+	if err := d.mirror().WriteRepoList(d.commonOptions.RunDir()); err != nil {
+		return "", err
+	}
+	return mirror.ContainerdConfigPatch(hosts, d.mirrorEndpoint()), nil
+}
+
+// indent indents every line of s by prefix, to embed it as a yaml block scalar
+func indent(s, prefix string) string {
+	lines := strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+	return fmt.Sprintf("%s%s\n", prefix, strings.Join(lines, "\n"+prefix))
+}
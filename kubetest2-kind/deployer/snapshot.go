@@ -0,0 +1,71 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package deployer
+
+import (
+	"fmt"
+
+	"sigs.k8s.io/kubetest2/pkg/exec"
+	"sigs.k8s.io/kubetest2/pkg/snapshot"
+	"sigs.k8s.io/kubetest2/pkg/types"
+)
+
+// assert that deployer implements types.DeployerWithSnapshot
+var _ types.DeployerWithSnapshot = &deployer{}
+
+func (d *deployer) Snapshot(name string) error {
+	s, err := d.snapshotter()
+	if err != nil {
+		return err
+	}
+	return s.Save(name)
+}
+
+func (d *deployer) Restore(name string) error {
+	s, err := d.snapshotter()
+	if err != nil {
+		return err
+	}
+	return s.Restore(name)
+}
+
+// snapshotter runs the snapshot scripts in the control plane node container,
+// restoring the etcd of a cluster with several control plane nodes is not supported
+func (d *deployer) snapshotter() (*snapshot.Snapshotter, error) {
+	name := d.clusterName()
+	nodes, err := exec.OutputLines(exec.Command("docker", "ps", "--quiet",
+		"--filter=label=io.x-k8s.kind.cluster="+name,
+		"--filter=label=io.x-k8s.kind.role=control-plane"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list the control plane nodes: %v", err)
+	}
+	if len(nodes) != 1 {
+		return nil, fmt.Errorf("etcd snapshots require a single control plane node, found %d", len(nodes))
+	}
+	kubeconfig, err := d.Kubeconfig()
+	if err != nil {
+		return nil, err
+	}
+	return &snapshot.Snapshotter{
+		Run: func(script string) error {
+			cmd := exec.Command("docker", "exec", nodes[0], "sh", "-c", script)
+			exec.InheritOutput(cmd)
+			return cmd.Run()
+		},
+		Kubeconfig: kubeconfig,
+	}, nil
+}
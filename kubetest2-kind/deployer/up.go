@@ -16,7 +16,10 @@ limitations under the License.
 package deployer
 
 import (
+	"fmt"
+	"io/ioutil"
 	"os"
+	"path/filepath"
 	"strings"
 
 	"k8s.io/klog"
@@ -27,9 +30,13 @@ import (
 )
 
 func (d *deployer) IsUp() (up bool, err error) {
+	kubeconfig, err := d.Kubeconfig()
+	if err != nil {
+		return false, err
+	}
 	// naively assume that if the api server reports nodes, the cluster is up
 	lines, err := exec.CombinedOutputLines(
-		exec.Command("kubectl", "get", "nodes", "-o=name"),
+		exec.Command("kubectl", "--kubeconfig="+kubeconfig, "get", "nodes", "-o=name"),
 	)
 	if err != nil {
 		return false, metadata.NewJUnitError(err, strings.Join(lines, "\n"))
@@ -38,6 +45,9 @@ func (d *deployer) IsUp() (up bool, err error) {
 }
 
 func (d *deployer) Up() error {
+	if err := d.verifyClusterNameAvailable(); err != nil {
+		return err
+	}
 	args := []string{
 		"create", "cluster",
 		"--name", d.ClusterName,
@@ -49,17 +59,79 @@ func (d *deployer) Up() error {
 	} else if d.commonOptions.ShouldBuild() {
 		// otherwise if we just built an image, use that
 		// NOTE: this is safe in the face of upstream changes, because
-		// we use the same logic / constant for Build()
-		args = append(args, "--image", kindDefaultBuiltImageName)
+		// we use the same logic for Build()
+		args = append(args, "--image", d.builtNodeImage())
 	}
-	if d.ConfigPath != "" {
-		args = append(args, "--config", d.ConfigPath)
+	configPath, err := d.clusterConfig()
+	if err != nil {
+		return err
 	}
-	if d.KubeconfigPath != "" {
-		args = append(args, "--kubeconfig", d.KubeconfigPath)
+	if configPath != "" {
+		args = append(args, "--config", configPath)
 	}
+	kubeconfig, err := d.Kubeconfig()
+	if err != nil {
+		return err
+	}
+	args = append(args, "--kubeconfig", kubeconfig)
 
 	klog.V(0).Infof("Up(): creating kind cluster...\n")
 	// we want to see the output so use process.ExecJUnit
 	return process.ExecJUnit("kind", args, os.Environ())
 }
+
+// verifyClusterNameAvailable fails if a kind cluster with the same name
+// already exists, e.g. because a concurrent run uses the same --cluster-name
+func (d *deployer) verifyClusterNameAvailable() error {
+	clusters, err := exec.OutputLines(exec.Command("kind", "get", "clusters"))
+	if err != nil {
+		return fmt.Errorf("failed to list the kind clusters: %v", err)
+	}
+	for _, cluster := range clusters {
+		if strings.TrimSpace(cluster) == d.clusterName() {
+			return fmt.Errorf("kind cluster %q already exists, it may belong to another run: "+
+				"use a different --cluster-name or delete it with kind delete cluster --name=%s", d.clusterName(), d.clusterName())
+		}
+	}
+	return nil
+}
+
+const clusterConfigHeader = `kind: Cluster
+apiVersion: kind.x-k8s.io/v1alpha4
+`
+
+// clusterConfig returns the path to the --config for kind create cluster,
+// generating one in the run dir for --stack-type, --mirror-registry and the
+// feature gates
+func (d *deployer) clusterConfig() (string, error) {
+	if d.StackType == "" && d.MirrorRegistry == "" && len(d.featureGates) == 0 && len(d.runtimeConfig) == 0 {
+		return d.ConfigPath, nil
+	}
+	if d.ConfigPath != "" {
+		return "", fmt.Errorf("--stack-type and --mirror-registry cannot be used with --config, " +
+			"set networking.ipFamily or containerdConfigPatches in the config instead")
+	}
+	config := clusterConfigHeader
+	if d.StackType != "" {
+		// the values of --stack-type are the same as the kind ip families
+		switch d.StackType {
+		case "ipv4", "ipv6", "dual":
+		default:
+			return "", fmt.Errorf("--stack-type must be one of ipv4, ipv6 or dual, found %q", d.StackType)
+		}
+		config += fmt.Sprintf("networking:\n  ipFamily: %s\n", d.StackType)
+	}
+	if d.MirrorRegistry != "" {
+		patch, err := d.mirrorConfigPatch()
+		if err != nil {
+			return "", err
+		}
+		config += "containerdConfigPatches:\n- |-\n" + indent(patch, "  ")
+	}
+	config += d.featureGatesConfig()
+	path := filepath.Join(d.commonOptions.RunDir(), "kind-config.yaml")
+	if err := ioutil.WriteFile(path, []byte(config), 0644); err != nil {
+		return "", fmt.Errorf("failed to write the kind config: %v", err)
+	}
+	return path, nil
+}
@@ -0,0 +1,121 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package deployer implements the kubetest2 minikube deployer
+package deployer
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+
+	"github.com/octago/sflags/gen/gpflag"
+	"github.com/spf13/pflag"
+	"k8s.io/klog"
+
+	"sigs.k8s.io/kubetest2/pkg/types"
+)
+
+// Name is the name of the deployer
+const Name = "minikube"
+
+var GitTag string
+
+// New implements deployer.New for minikube
+func New(opts types.Options) (types.Deployer, *pflag.FlagSet) {
+	// create a deployer object and set fields that are not flag controlled
+	d := &deployer{
+		commonOptions: opts,
+		logsDir:       filepath.Join(opts.RunDir(), "logs"),
+		// not the run dir, which is uploaded with the artifacts while the
+		// kubeconfig holds the client key of the cluster admin
+		kubeconfigPath: filepath.Join(os.TempDir(), "kubetest2-minikube-"+opts.RunID(), "kubeconfig"),
+		Profile:        "kubetest2",
+		Driver:         "docker",
+		Nodes:          1,
+	}
+	// register flags and return
+	return d, bindFlags(d)
+}
+
+// assert that New implements types.NewDeployer
+var _ types.NewDeployer = New
+
+type deployer struct {
+	// generic parts
+	commonOptions types.Options
+	// minikube specific details
+	Profile           string   `desc:"--profile for minikube, the name of the cluster"`
+	Driver            string   `desc:"--driver for minikube start, one of docker, kvm2, hyperkit"`
+	KubernetesVersion string   `flag:"kubernetes-version" desc:"--kubernetes-version for minikube start"`
+	Nodes             int      `desc:"--nodes for minikube start"`
+	CNI               string   `flag:"cni" desc:"--cni for minikube start e.g. auto, bridge, calico, cilium, flannel, kindnet or a path to a CNI manifest"`
+	FeatureGates      string   `desc:"--feature-gates for minikube start, a comma separated list of key=value pairs"`
+	Addons            []string `flag:"addons" desc:"comma separated list of minikube addons to enable after the cluster is started"`
+	KubeconfigPath    string   `flag:"kubeconfig" desc:"the path to write the cluster kubeconfig to. Defaults to a kubeconfig in a temp directory of the run"`
+	StartExtraArgs    string   `desc:"extra space separated flags for minikube start"`
+
+	kubeconfigPath string
+	logsDir        string
+	// runtimeConfig is the formatted --runtime-config of kubetest2, see SetFeatureGates
+	runtimeConfig string
+}
+
+func (d *deployer) Kubeconfig() (string, error) {
+	if d.KubeconfigPath != "" {
+		return d.KubeconfigPath, nil
+	}
+	if err := os.MkdirAll(filepath.Dir(d.kubeconfigPath), 0700); err != nil {
+		return "", err
+	}
+	return d.kubeconfigPath, nil
+}
+
+func (d *deployer) Version() string {
+	return GitTag
+}
+
+func (d *deployer) Build() error {
+	// minikube runs released kubernetes binaries, there is nothing to build
+	if d.commonOptions.ShouldBuild() {
+		klog.Warningf("--build is not supported by the minikube deployer, ignoring")
+	}
+	return nil
+}
+
+// helper used to create & bind a flagset to the deployer
+func bindFlags(d *deployer) *pflag.FlagSet {
+	flags, err := gpflag.Parse(d)
+	if err != nil {
+		klog.Fatalf("unable to generate flags from deployer")
+		return nil
+	}
+
+	klog.InitFlags(nil)
+	flags.AddGoFlagSet(flag.CommandLine)
+
+	return flags
+}
+
+// assert that deployer implements types.DeployerWithKubeconfig
+var _ types.DeployerWithKubeconfig = &deployer{}
+
+// supportedDrivers are the minikube drivers the deployer is verified with
+var supportedDrivers = map[string]bool{
+	"docker":   true,
+	"kvm2":     true,
+	"hyperkit": true,
+}
@@ -0,0 +1,52 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package deployer
+
+import (
+	"os"
+	"path/filepath"
+
+	"k8s.io/klog"
+
+	"sigs.k8s.io/kubetest2/pkg/process"
+)
+
+func (d *deployer) Down() error {
+	// collect the logs while the cluster still exists
+	if err := d.DumpClusterLogs(); err != nil {
+		klog.Warningf("Dumping cluster logs at the start of Down() failed: %s", err)
+	}
+
+	env, err := d.env()
+	if err != nil {
+		return err
+	}
+	args := []string{
+		"delete",
+		"--profile", d.Profile,
+	}
+
+	klog.V(0).Infof("Down(): deleting minikube cluster...%s\n", d.Profile)
+	// we want to see the output so use process.ExecJUnit
+	if err := process.ExecJUnit("minikube", args, env); err != nil {
+		return err
+	}
+	if d.KubeconfigPath == "" {
+		return os.RemoveAll(filepath.Dir(d.kubeconfigPath))
+	}
+	return nil
+}
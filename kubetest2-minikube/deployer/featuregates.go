@@ -0,0 +1,39 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package deployer
+
+import (
+	"fmt"
+
+	"sigs.k8s.io/kubetest2/pkg/featuregates"
+	"sigs.k8s.io/kubetest2/pkg/types"
+)
+
+var _ types.DeployerWithFeatureGates = &deployer{}
+
+// SetFeatureGates passes the feature gates to minikube start with
+// --feature-gates, and the runtime config to the API server with --extra-config
+func (d *deployer) SetFeatureGates(featureGates map[string]bool, runtimeConfig map[string]string) error {
+	if len(featureGates) > 0 {
+		if d.FeatureGates != "" {
+			return fmt.Errorf("--kube-feature-gates cannot be used with the --feature-gates of the deployer")
+		}
+		d.FeatureGates = featuregates.FormatFeatureGates(featureGates)
+	}
+	d.runtimeConfig = featuregates.FormatRuntimeConfig(runtimeConfig)
+	return nil
+}
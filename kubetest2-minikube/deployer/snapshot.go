@@ -0,0 +1,67 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package deployer
+
+import (
+	"encoding/base64"
+	"fmt"
+
+	"sigs.k8s.io/kubetest2/pkg/exec"
+	"sigs.k8s.io/kubetest2/pkg/snapshot"
+	"sigs.k8s.io/kubetest2/pkg/types"
+)
+
+// assert that deployer implements types.DeployerWithSnapshot
+var _ types.DeployerWithSnapshot = &deployer{}
+
+func (d *deployer) Snapshot(name string) error {
+	s, err := d.snapshotter()
+	if err != nil {
+		return err
+	}
+	return s.Save(name)
+}
+
+func (d *deployer) Restore(name string) error {
+	s, err := d.snapshotter()
+	if err != nil {
+		return err
+	}
+	return s.Restore(name)
+}
+
+// snapshotter runs the snapshot scripts on the control plane node with minikube ssh,
+// which is the first node of the profile
+func (d *deployer) snapshotter() (*snapshot.Snapshotter, error) {
+	kubeconfig, err := d.Kubeconfig()
+	if err != nil {
+		return nil, err
+	}
+	return &snapshot.Snapshotter{
+		Run: func(script string) error {
+			// minikube ssh runs its args as a remote shell command line,
+			// the script is encoded so that it does not need to be quoted
+			encoded := base64.StdEncoding.EncodeToString([]byte(script))
+			cmd := exec.Command("minikube", "ssh",
+				"--profile", d.Profile,
+				"--", fmt.Sprintf("echo %s | base64 -d | sudo sh", encoded))
+			exec.InheritOutput(cmd)
+			return cmd.Run()
+		},
+		Kubeconfig: kubeconfig,
+	}, nil
+}
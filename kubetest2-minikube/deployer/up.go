@@ -0,0 +1,112 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package deployer
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"k8s.io/klog"
+
+	"sigs.k8s.io/kubetest2/pkg/exec"
+	"sigs.k8s.io/kubetest2/pkg/metadata"
+	"sigs.k8s.io/kubetest2/pkg/process"
+)
+
+func (d *deployer) IsUp() (up bool, err error) {
+	kubeconfig, err := d.Kubeconfig()
+	if err != nil {
+		return false, err
+	}
+	// naively assume that if the api server reports nodes, the cluster is up
+	lines, err := exec.CombinedOutputLines(
+		exec.Command("kubectl", "--kubeconfig", kubeconfig, "get", "nodes", "-o=name"),
+	)
+	if err != nil {
+		return false, metadata.NewJUnitError(err, strings.Join(lines, "\n"))
+	}
+	return len(lines) > 0, nil
+}
+
+func (d *deployer) Up() error {
+	if !supportedDrivers[d.Driver] {
+		return fmt.Errorf("unsupported --driver %q", d.Driver)
+	}
+	if d.Nodes < 1 {
+		return fmt.Errorf("--nodes must be at least 1")
+	}
+
+	env, err := d.env()
+	if err != nil {
+		return err
+	}
+
+	klog.V(0).Infof("Up(): starting minikube cluster...\n")
+	// we want to see the output so use process.ExecJUnit
+	if err := process.ExecJUnit("minikube", d.startArgs(), env); err != nil {
+		return err
+	}
+
+	for _, addon := range d.Addons {
+		klog.V(0).Infof("Up(): enabling minikube addon %s...\n", addon)
+		if err := process.ExecJUnit("minikube", []string{
+			"addons", "enable", addon,
+			"--profile", d.Profile,
+		}, env); err != nil {
+			return fmt.Errorf("failed to enable addon %s: %v", addon, err)
+		}
+	}
+	return nil
+}
+
+func (d *deployer) startArgs() []string {
+	args := []string{
+		"start",
+		"--profile", d.Profile,
+		"--driver", d.Driver,
+		"--nodes", strconv.Itoa(d.Nodes),
+		"--wait", "all",
+	}
+	if d.KubernetesVersion != "" {
+		args = append(args, "--kubernetes-version", d.KubernetesVersion)
+	}
+	if d.CNI != "" {
+		args = append(args, "--cni", d.CNI)
+	}
+	if d.FeatureGates != "" {
+		args = append(args, "--feature-gates", d.FeatureGates)
+	}
+	if d.runtimeConfig != "" {
+		args = append(args, "--extra-config", "apiserver.runtime-config="+d.runtimeConfig)
+	}
+	if d.StartExtraArgs != "" {
+		args = append(args, strings.Fields(d.StartExtraArgs)...)
+	}
+	return args
+}
+
+// env returns the environment for minikube, pointing KUBECONFIG at the
+// deployer kubeconfig so that the default kubeconfig is left untouched
+func (d *deployer) env() ([]string, error) {
+	kubeconfig, err := d.Kubeconfig()
+	if err != nil {
+		return nil, err
+	}
+	return append(os.Environ(), "KUBECONFIG="+kubeconfig), nil
+}
@@ -94,6 +94,10 @@ func (d *deployer) Version() string {
 	return GitTag
 }
 
+func (d *deployer) Description() string {
+	return "uses an existing cluster, the up and down phases do nothing"
+}
+
 // helper used to create & bind a flagset to the deployer
 func bindFlags(d *deployer) *pflag.FlagSet {
 	flags, err := gpflag.Parse(d)
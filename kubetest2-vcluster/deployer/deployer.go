@@ -0,0 +1,192 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package deployer implements the kubetest2 vcluster deployer
+package deployer
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/octago/sflags/gen/gpflag"
+	"github.com/spf13/pflag"
+	"k8s.io/klog"
+
+	"sigs.k8s.io/kubetest2/pkg/types"
+)
+
+// Name is the name of the deployer
+const Name = "vcluster"
+
+var GitTag string
+
+// New implements deployer.New for vcluster
+func New(opts types.Options) (types.Deployer, *pflag.FlagSet) {
+	// create a deployer object and set fields that are not flag controlled
+	d := &deployer{
+		commonOptions: opts,
+		logsDir:       filepath.Join(opts.RunDir(), "logs"),
+		// not the run dir, which is uploaded with the artifacts while the
+		// kubeconfigs hold the client keys of the cluster admins
+		kubeconfigDir: filepath.Join(os.TempDir(), "kubetest2-vcluster-"+opts.RunID(), "kubeconfigs"),
+		ClusterPrefix: "kt2-" + runIDPrefix(opts.RunID()),
+		NumClusters:   1,
+		Expose:        true,
+	}
+	// register flags and return
+	return d, bindFlags(d)
+}
+
+// assert that New implements types.NewDeployer
+var _ types.NewDeployer = New
+
+type deployer struct {
+	// generic parts
+	commonOptions types.Options
+	// vcluster specific details
+	HostKubeconfig    string `flag:"host-kubeconfig" desc:"Path to the kubeconfig of the existing host cluster to create the virtual clusters in. Must be set."`
+	ClusterPrefix     string `desc:"Prefix of the virtual cluster names, the clusters are named <prefix>-<index> and each is created in a namespace of the same name. Defaults to kt2-<run-id>"`
+	NumClusters       int    `desc:"Number of virtual clusters to create in the host cluster."`
+	Distro            string `desc:"--distro for vcluster create, e.g. k3s or k8s"`
+	KubernetesVersion string `flag:"kubernetes-version" desc:"--kubernetes-version for vcluster create"`
+	ValuesPath        string `flag:"values" desc:"-f for vcluster create, a helm values file for the vcluster chart"`
+	Expose            bool   `desc:"--expose for vcluster create, exposes the virtual clusters through a LoadBalancer service. If false, the virtual clusters are reached through port forwards which only live as long as this kubetest2 invocation."`
+
+	kubeconfigDir  string
+	kubeconfigPath string
+	logsDir        string
+
+	// cancelProxies stops the port forwards to the virtual clusters, if any
+	cancelProxies context.CancelFunc
+	// localPorts are the local ports of the port forwards to the virtual
+	// clusters, free ports picked by the OS, see allocatePorts
+	localPorts []int
+}
+
+// cluster is a virtual cluster, living in the namespace of the same name
+type cluster struct {
+	name      string
+	localPort int
+}
+
+func (d *deployer) clusters() []cluster {
+	clusters := make([]cluster, d.NumClusters)
+	for i := range clusters {
+		clusters[i] = cluster{name: fmt.Sprintf("%s-%d", d.ClusterPrefix, i)}
+		if i < len(d.localPorts) {
+			clusters[i].localPort = d.localPorts[i]
+		}
+	}
+	return clusters
+}
+
+// allocatePorts picks the local ports of the port forwards, free ones rather
+// than fixed so that concurrent runs on the same host do not share them
+func (d *deployer) allocatePorts() error {
+	d.localPorts = make([]int, d.NumClusters)
+	for i := range d.localPorts {
+		port, err := freePort()
+		if err != nil {
+			return fmt.Errorf("failed to pick a local port for the port forwards: %v", err)
+		}
+		d.localPorts[i] = port
+	}
+	return nil
+}
+
+// freePort returns a local port that is free, as picked by the OS
+func freePort() (int, error) {
+	l, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		return 0, err
+	}
+	defer l.Close()
+	return l.Addr().(*net.TCPAddr).Port, nil
+}
+
+// Kubeconfig returns the kubeconfigs of all the virtual clusters, joined in a KUBECONFIG list
+func (d *deployer) Kubeconfig() (string, error) {
+	if d.kubeconfigPath != "" {
+		return d.kubeconfigPath, nil
+	}
+	files := make([]string, 0, d.NumClusters)
+	for _, c := range d.clusters() {
+		files = append(files, d.clusterKubeconfig(c))
+	}
+	d.kubeconfigPath = strings.Join(files, string(os.PathListSeparator))
+	return d.kubeconfigPath, nil
+}
+
+func (d *deployer) clusterKubeconfig(c cluster) string {
+	return filepath.Join(d.kubeconfigDir, c.name)
+}
+
+func (d *deployer) Version() string {
+	return GitTag
+}
+
+func (d *deployer) Description() string {
+	return "creates virtual clusters inside a host cluster with vcluster"
+}
+
+func (d *deployer) Build() error {
+	// virtual clusters run released images, there is nothing to build
+	if d.commonOptions.ShouldBuild() {
+		klog.Warningf("--build is not supported by the vcluster deployer, ignoring")
+	}
+	return nil
+}
+
+func (d *deployer) verifyFlags() error {
+	if d.HostKubeconfig == "" {
+		return fmt.Errorf("--host-kubeconfig must be set for the vcluster deployer")
+	}
+	if d.NumClusters < 1 {
+		return fmt.Errorf("--num-clusters must be at least 1")
+	}
+	return nil
+}
+
+// runIDPrefix returns a prefix of the run ID short enough for resource names
+func runIDPrefix(runID string) string {
+	const maxLength = 8
+	if len(runID) <= maxLength {
+		return runID
+	}
+	return runID[:maxLength]
+}
+
+// helper used to create & bind a flagset to the deployer
+func bindFlags(d *deployer) *pflag.FlagSet {
+	flags, err := gpflag.Parse(d)
+	if err != nil {
+		klog.Fatalf("unable to generate flags from deployer")
+		return nil
+	}
+
+	klog.InitFlags(nil)
+	flags.AddGoFlagSet(flag.CommandLine)
+
+	return flags
+}
+
+// assert that deployer implements types.DeployerWithKubeconfig
+var _ types.DeployerWithKubeconfig = &deployer{}
@@ -0,0 +1,62 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package deployer
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"golang.org/x/sync/errgroup"
+	"k8s.io/klog"
+
+	"sigs.k8s.io/kubetest2/pkg/exec"
+)
+
+func (d *deployer) Down() error {
+	if err := d.verifyFlags(); err != nil {
+		return err
+	}
+	if d.cancelProxies != nil {
+		d.cancelProxies()
+	}
+
+	// collect the logs while the virtual clusters still exist
+	if err := d.DumpClusterLogs(); err != nil {
+		klog.Warningf("Dumping cluster logs at the start of Down() failed: %s", err)
+	}
+
+	klog.V(0).Infof("Down(): deleting %d virtual cluster(s)...\n", d.NumClusters)
+	eg := new(errgroup.Group)
+	for _, c := range d.clusters() {
+		c := c
+		eg.Go(func() error {
+			cmd := d.vcluster("delete", c.name,
+				"--namespace", c.name,
+				"--delete-namespace")
+			exec.InheritOutput(cmd)
+			if err := cmd.Run(); err != nil {
+				return fmt.Errorf("failed to delete virtual cluster %s: %v", c.name, err)
+			}
+			return nil
+		})
+	}
+	if err := eg.Wait(); err != nil {
+		return err
+	}
+	return os.RemoveAll(filepath.Dir(d.kubeconfigDir))
+}
@@ -0,0 +1,56 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package deployer
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"k8s.io/klog"
+
+	"sigs.k8s.io/kubetest2/pkg/exec"
+)
+
+// DumpClusterLogs writes the logs of the virtual cluster control planes,
+// which run as pods in the host cluster, to the logs directory
+func (d *deployer) DumpClusterLogs() error {
+	klog.V(0).Infof("DumpClusterLogs(): exporting virtual cluster logs...\n")
+
+	var failed []string
+	for _, c := range d.clusters() {
+		dir := filepath.Join(d.logsDir, c.name)
+		if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+			return err
+		}
+		// dump the namespace of the virtual cluster in the host cluster,
+		// including the logs of the vcluster and of the synced pods
+		cmd := exec.Command("kubectl", "--kubeconfig", d.HostKubeconfig,
+			"cluster-info", "dump",
+			"--namespaces", c.name,
+			"--output-directory", dir)
+		exec.NoOutput(cmd)
+		if err := cmd.Run(); err != nil {
+			klog.Warningf("failed to dump the logs of virtual cluster %s: %v", c.name, err)
+			failed = append(failed, c.name)
+		}
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("failed to dump the logs of virtual clusters %v", failed)
+	}
+	return nil
+}
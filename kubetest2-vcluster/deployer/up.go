@@ -0,0 +1,186 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package deployer
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/sync/errgroup"
+	"k8s.io/klog"
+
+	"sigs.k8s.io/kubetest2/pkg/exec"
+	"sigs.k8s.io/kubetest2/pkg/metadata"
+)
+
+func (d *deployer) IsUp() (up bool, err error) {
+	for _, c := range d.clusters() {
+		// naively assume that if the api server reports namespaces, the virtual cluster is up
+		// virtual clusters may legitimately have no nodes synced yet
+		lines, err := exec.CombinedOutputLines(
+			exec.Command("kubectl", "--kubeconfig", d.clusterKubeconfig(c), "get", "namespaces", "-o=name"),
+		)
+		if err != nil {
+			return false, metadata.NewJUnitError(err, strings.Join(lines, "\n"))
+		}
+		if len(lines) == 0 {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+func (d *deployer) Up() error {
+	if err := d.verifyFlags(); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(d.kubeconfigDir, 0700); err != nil {
+		return err
+	}
+	if !d.Expose {
+		if err := d.allocatePorts(); err != nil {
+			return err
+		}
+	}
+
+	klog.V(0).Infof("Up(): creating %d virtual cluster(s)...\n", d.NumClusters)
+	eg := new(errgroup.Group)
+	for _, c := range d.clusters() {
+		c := c
+		eg.Go(func() error {
+			return d.createCluster(c)
+		})
+	}
+	if err := eg.Wait(); err != nil {
+		return err
+	}
+
+	if !d.Expose {
+		return d.startProxies()
+	}
+	return nil
+}
+
+func (d *deployer) createCluster(c cluster) error {
+	args := []string{
+		"create", c.name,
+		"--namespace", c.name,
+		"--connect=false",
+	}
+	if d.Distro != "" {
+		args = append(args, "--distro", d.Distro)
+	}
+	if d.KubernetesVersion != "" {
+		args = append(args, "--kubernetes-version", d.KubernetesVersion)
+	}
+	if d.ValuesPath != "" {
+		args = append(args, "-f", d.ValuesPath)
+	}
+	if d.Expose {
+		args = append(args, "--expose")
+	}
+	cmd := d.vcluster(args...)
+	exec.InheritOutput(cmd)
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("failed to create virtual cluster %s: %v", c.name, err)
+	}
+
+	// --print writes the kubeconfig of the virtual cluster without connecting to it
+	connectArgs := []string{
+		"connect", c.name,
+		"--namespace", c.name,
+		"--print",
+	}
+	if !d.Expose {
+		connectArgs = append(connectArgs, "--server", "https://localhost:"+strconv.Itoa(c.localPort))
+	}
+	kubeconfig, err := exec.Output(d.vcluster(connectArgs...))
+	if err != nil {
+		return fmt.Errorf("failed to get the kubeconfig of virtual cluster %s: %v", c.name, err)
+	}
+	return ioutil.WriteFile(d.clusterKubeconfig(c), kubeconfig, 0600)
+}
+
+// startProxies starts port forwards to the unexposed virtual clusters,
+// running in the background until Down() or the end of the invocation
+func (d *deployer) startProxies() error {
+	ctx, cancel := context.WithCancel(context.Background())
+	d.cancelProxies = cancel
+	for _, c := range d.clusters() {
+		cmd := exec.CommandContext(ctx, "vcluster", "connect", c.name,
+			"--namespace", c.name,
+			"--update-current=false",
+			"--kube-config", os.DevNull,
+			"--local-port", strconv.Itoa(c.localPort))
+		cmd.SetEnv(d.env()...)
+		name := c.name
+		exited := make(chan struct{})
+		go func() {
+			defer close(exited)
+			if err := cmd.Run(); err != nil && ctx.Err() == nil {
+				klog.Errorf("port forward to virtual cluster %s exited: %v", name, err)
+			}
+		}()
+		// so that the testers do not race the port forward
+		if err := waitForPort(c.localPort, exited, proxyReadyTimeout); err != nil {
+			return fmt.Errorf("port forward to virtual cluster %s: %v", name, err)
+		}
+	}
+	return nil
+}
+
+// proxyReadyTimeout is how long to wait for a port forward to listen
+const proxyReadyTimeout = time.Minute
+
+// waitForPort waits until the local port accepts connections, failing if
+// the port forward exits or the timeout elapses before
+func waitForPort(port int, exited <-chan struct{}, timeout time.Duration) error {
+	address := net.JoinHostPort("localhost", strconv.Itoa(port))
+	deadline := time.Now().Add(timeout)
+	for {
+		conn, err := net.DialTimeout("tcp", address, time.Second)
+		if err == nil {
+			return conn.Close()
+		}
+		select {
+		case <-exited:
+			return fmt.Errorf("exited before listening on %s", address)
+		default:
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("not listening on %s after %s: %v", address, timeout, err)
+		}
+		time.Sleep(500 * time.Millisecond)
+	}
+}
+
+// vcluster returns a vcluster command against the host cluster
+func (d *deployer) vcluster(args ...string) exec.Cmd {
+	cmd := exec.Command("vcluster", args...)
+	cmd.SetEnv(d.env()...)
+	return cmd
+}
+
+func (d *deployer) env() []string {
+	return append(os.Environ(), "KUBECONFIG="+d.HostKubeconfig)
+}
@@ -17,9 +17,12 @@ limitations under the License.
 package main
 
 import (
+	"sigs.k8s.io/kubetest2/pkg/app"
 	"sigs.k8s.io/kubetest2/pkg/app/shim"
 )
 
 func main() {
+	// deployer plugins are run in process, see pkg/plugin
+	shim.RunPlugin = app.RunPlugin
 	shim.Main()
 }
@@ -21,13 +21,25 @@ import (
 	"os"
 	"os/signal"
 	"path/filepath"
+	"strconv"
+	"strings"
 	"syscall"
+	"time"
 
 	"github.com/pkg/errors"
 	"k8s.io/klog"
 
+	"sigs.k8s.io/kubetest2/pkg/chaos"
+	"sigs.k8s.io/kubetest2/pkg/diagnostics"
+	"sigs.k8s.io/kubetest2/pkg/events"
 	"sigs.k8s.io/kubetest2/pkg/exec"
 	"sigs.k8s.io/kubetest2/pkg/metadata"
+	"sigs.k8s.io/kubetest2/pkg/metrics"
+	"sigs.k8s.io/kubetest2/pkg/ratelimit"
+	"sigs.k8s.io/kubetest2/pkg/secrets"
+	"sigs.k8s.io/kubetest2/pkg/smoke"
+	"sigs.k8s.io/kubetest2/pkg/testers"
+	"sigs.k8s.io/kubetest2/pkg/trace"
 	"sigs.k8s.io/kubetest2/pkg/types"
 )
 
@@ -45,9 +57,12 @@ func Main(deployerName string, newDeployer types.NewDeployer) {
 	}
 }
 
+// errInterrupted is the result of the runs interrupted by a signal
+var errInterrupted = errors.New("interrupted")
+
 // RealMain contains nearly all of the application logic / control flow
 // beyond the command line boilerplate
-func RealMain(opts types.Options, d types.Deployer, tester types.Tester) (result error) {
+func RealMain(opts types.Options, d types.Deployer, allTesters ...types.Tester) (result error) {
 	/*
 		Now for the core kubetest2 logic:
 		 - build
@@ -64,11 +79,49 @@ func RealMain(opts types.Options, d types.Deployer, tester types.Tester) (result
 	if err := os.MkdirAll(opts.RunDir(), os.ModePerm); err != nil {
 		return err
 	}
+	stopEvents, err := startEvents(opts)
+	if err != nil {
+		return err
+	}
+	defer stopEvents()
+	// the result event is the last one, once the cluster is torn down
+	defer func() { events.Default().RunFinished(result) }()
+	// hold the run dir for the whole invocation, released last after the cluster is torn down
+	releaseLock, err := acquireRunLock(opts.RunDir())
+	if err != nil {
+		return err
+	}
+	defer releaseLock()
+	// remove the secrets resolved to files by the deployer
+	defer secrets.Cleanup()
 
 	if err := writeVersionToMetadataJSON(opts, d); err != nil {
 		return err
 	}
 
+	// record the commands executed by the run, appending to the transcripts
+	// of the earlier invocations for the same run e.g. with a separate --down
+	commandsLog, err := os.OpenFile(filepath.Join(opts.RunDir(), "commands.log"), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return errors.Wrap(err, "could not create the commands transcript")
+	}
+	defer commandsLog.Close()
+	exec.SetTranscript(exec.NewTranscript(commandsLog))
+	defer exec.SetTranscript(nil)
+	if oWithRateLimit, ok := opts.(optionsWithRateLimit); ok {
+		ratelimit.SetDefault(ratelimit.NewLimiter(oWithRateLimit.APIQPS(), oWithRateLimit.APIBurst()))
+		defer ratelimit.SetDefault(nil)
+	}
+
+	// the metrics are flushed last, after the cluster is torn down
+	metricsRegistry := newMetricsRegistry(opts)
+	defer flushMetrics(opts, metricsRegistry)
+	// for `kubetest2 history`, once the cluster is torn down
+	started := time.Now()
+	defer func() { recordHistory(opts, allTesters, metricsRegistry, started, result) }()
+	tracer := newTracer(opts)
+	defer func() { flushTrace(opts, tracer, result) }()
+
 	// setup junit writer
 	junitRunner, err := os.Create(
 		filepath.Join(opts.RunDir(), "junit_runner.xml"),
@@ -78,6 +131,14 @@ func RealMain(opts types.Options, d types.Deployer, tester types.Tester) (result
 	}
 	writer := metadata.NewWriter("kubetest2", junitRunner)
 
+	// the phases that succeeded are skipped when resuming a previous run,
+	// loaded before the interrupt handler which records the Down
+	oWithResume, ok := opts.(optionsWithResume)
+	state, err := loadRunState(opts.RunDir(), ok && oWithResume.Resume())
+	if err != nil {
+		return err
+	}
+
 	done := make(chan bool)
 	defer func() { done <- true }()
 	go func() {
@@ -90,10 +151,17 @@ func RealMain(opts types.Options, d types.Deployer, tester types.Tester) (result
 			case <-c:
 				if opts.ShouldUp() || opts.ShouldTest() {
 					klog.Info("Captured ^C, gracefully attempting to cleanup resources..")
-					if err := writer.WrapStep("Down", d.Down); err != nil {
-						result = err
+					result = errInterrupted
+					if err := wrapStep(writer, "Down", state.recorded("Down", d.Down)); err != nil {
+						result = errors.Wrap(err, errInterrupted.Error())
 					}
-					os.Exit(0)
+					flushMetrics(opts, metricsRegistry)
+					recordHistory(opts, allTesters, metricsRegistry, started, result)
+					flushTrace(opts, tracer, result)
+					events.Default().RunFinished(result)
+					// the run did not complete, e.g. terminated by a
+					// deployer capping its duration
+					os.Exit(1)
 				}
 			case <-done:
 				return
@@ -119,9 +187,53 @@ func RealMain(opts types.Options, d types.Deployer, tester types.Tester) (result
 
 	klog.Infof("ID for this run: %q", opts.RunID())
 
+	pause, err := pauseDuration(opts)
+	if err != nil {
+		return err
+	}
+	// hold the failed cluster open for debugging with --on-failure=pause,
+	// once, before anything is collected from it or torn down
+	paused := false
+	holdOpen := func(failure error) {
+		if pause > 0 && !paused {
+			paused = true
+			pauseOnFailure(opts, d, failure, pause)
+		}
+	}
+	skew, err := newSkewSequence(opts, d)
+	if err != nil {
+		return err
+	}
+	if err := checkEtcdSnapshot(opts, d); err != nil {
+		return err
+	}
+	userHooks, err := newHooks(opts, d)
+	if err != nil {
+		return err
+	}
+	gate, err := newReadinessGate(opts)
+	if err != nil {
+		return err
+	}
+	streamer, err := newArtifactsStreamer(opts)
+	if err != nil {
+		return err
+	}
+	if opts.ShouldUp() {
+		if err := configureFeatureGates(opts, d); err != nil {
+			return err
+		}
+	}
+
+	if state.resuming {
+		if err := state.resume(opts.RunID(), d); err != nil {
+			return err
+		}
+	}
+
 	// build if specified
-	if opts.ShouldBuild() {
-		if err := writer.WrapStep("Build", d.Build); err != nil {
+	if opts.ShouldBuild() && !state.skip("Build") {
+		if err := wrapStep(writer, "Build", state.recorded("Build", d.Build)); err != nil {
 			// we do not continue to up / test etc. if build fails
 			return err
 		}
@@ -131,52 +243,81 @@ func RealMain(opts types.Options, d types.Deployer, tester types.Tester) (result
 	// down should be called both when Up and Test fails to ensure resources are being cleaned up.
 	defer func() {
 		if opts.ShouldDown() {
+			if result != nil {
+				holdOpen(result)
+			}
+			// a failed hook does not keep the cluster from being torn down
+			if err := userHooks.run(preDownHook, writer); err != nil && result == nil {
+				result = err
+			}
 			// TODO(bentheelder): instead of keeping the first error, consider
 			// a multi-error type
-			if err := writer.WrapStep("Down", d.Down); err != nil && result == nil {
+			if err := wrapStep(writer, "Down", state.recorded("Down", d.Down)); err != nil && result == nil {
 				result = err
 			}
 		}
 	}()
 
-	// up a cluster
-	if opts.ShouldUp() {
-		// TODO(bentheelder): this should write out to JUnit
-		if err := writer.WrapStep("Up", d.Up); err != nil {
-			// we do not continue to test if build fails
-			return err
+	// up a cluster, recorded as succeeded only once it is verified and
+	// ready, so that resuming does not skip the checks it failed
+	if opts.ShouldUp() && !state.skip("Up") {
+		upErr := func() error {
+			if err := userHooks.run(preUpHook, writer); err != nil {
+				return err
+			}
+			// TODO(bentheelder): this should write out to JUnit
+			if err := wrapStep(writer, "Up", d.Up); err != nil {
+				// we do not continue to test if build fails
+				return err
+			}
+			if oWithVerify, ok := opts.(optionsWithVerifyClusterUp); ok && oWithVerify.VerifyClusterUp() {
+				if err := wrapStep(writer, "VerifyClusterUp", func() error { return verifyClusterUp(opts, d) }); err != nil {
+					// testing a broken cluster only produces confusing failures
+					return err
+				}
+			}
+			// e.g. installing CRDs and operators the tests need
+			if err := userHooks.run(postUpHook, writer); err != nil {
+				return err
+			}
+			if gate != nil {
+				return wrapStep(writer, "WaitFor", func() error { return waitForReadiness(gate, d) })
+			}
+			return nil
+		}()
+		state.record("Up", upErr)
+		if upErr != nil {
+			return upErr
 		}
 	}
 
 	// and finally test, if a test was specified
-	if opts.ShouldTest() {
-		test := exec.Command(tester.TesterPath, tester.TesterArgs...)
-		exec.InheritOutput(test)
-
-		envsForTester := os.Environ()
-		// We expose both ARIFACTS and KUBETEST2_RUN_DIR so we can more granular about caching vs output in future.
-		// also add run_dir to $PATH for locally built binaries
-		updatedPath := opts.RunDir() + string(filepath.ListSeparator) + os.Getenv("PATH")
-		envsForTester = append(envsForTester, fmt.Sprintf("%s=%s", "PATH", updatedPath))
-		envsForTester = append(envsForTester, fmt.Sprintf("%s=%s", "ARTIFACTS", opts.RunDir()))
-		envsForTester = append(envsForTester, fmt.Sprintf("%s=%s", "KUBETEST2_RUN_DIR", opts.RunDir()))
-		envsForTester = append(envsForTester, fmt.Sprintf("%s=%s", "KUBETEST2_RUN_ID", opts.RunID()))
-		// If the deployer provides a kubeconfig pass it to the tester
-		// else assumes that it is handled offline by default methods like
-		// ~/.kube/config
-		if dWithKubeconfig, ok := d.(types.DeployerWithKubeconfig); ok {
-			if kconfig, err := dWithKubeconfig.Kubeconfig(); err == nil {
-				envsForTester = append(envsForTester, fmt.Sprintf("%s=%s", "KUBECONFIG", kconfig))
-			}
-
+	if opts.ShouldTest() && !state.skip("Test") {
+		// e.g. so that a soak run killed midway still leaves its logs
+		stopStream := startArtifactsStream(streamer)
+		defer stopStream()
+		if err := userHooks.run(preTestHook, writer); err != nil {
+			state.record("Test", err)
+			return err
 		}
-		test.SetEnv(envsForTester...)
-
 		var testErr error
-		if !opts.SkipTestJUnitReport() {
-			testErr = writer.WrapStep("Test", test.Run)
+		if skew != nil {
+			testErr = skew.run(opts, allTesters, writer)
 		} else {
-			testErr = test.Run()
+			testErr = runTesters(opts, d, allTesters, writer)
+		}
+		state.record("Test", testErr)
+		if testErr != nil {
+			holdOpen(testErr)
+			// before down, while the cluster is still there
+			collectDiagnostics(opts, d)
+		}
+		testResult := "passed"
+		if testErr != nil {
+			testResult = "failed"
+		}
+		if err := userHooks.run(postTestHook, writer, "KUBETEST2_TEST_RESULT="+testResult); err != nil && testErr == nil {
+			testErr = err
 		}
 
 		if dWithPostTester, ok := d.(types.DeployerWithPostTester); ok {
@@ -192,38 +333,533 @@ func RealMain(opts types.Options, d types.Deployer, tester types.Tester) (result
 	return nil
 }
 
-func writeVersionToMetadataJSON(opts types.Options, d types.Deployer) error {
-	// setup the json metadata writer
-	metadataJSON, err := os.Create(
-		filepath.Join(opts.RunDir(), "metadata.json"),
-	)
+// optionsWithVerifyClusterUp is implemented by options configuring the smoke check after up
+type optionsWithVerifyClusterUp interface {
+	VerifyClusterUp() bool
+}
+
+// verifyClusterUp runs the smoke check against the cluster of the deployer
+func verifyClusterUp(opts types.Options, d types.Deployer) error {
+	kubeconfig, err := deployerKubeconfig(d)
 	if err != nil {
 		return err
 	}
+	checker := &smoke.Checker{
+		Kubeconfig:   kubeconfig,
+		ArtifactsDir: opts.RunDir(),
+	}
+	return checker.Run()
+}
+
+// optionsWithDiagnostics is implemented by options configuring the diagnostics collected on test failures
+type optionsWithDiagnostics interface {
+	CollectDiagnostics() bool
+}
+
+// collectDiagnostics writes the state of the workloads of the cluster to
+// diagnostics/ in the run dir, failing to do so is logged but does not fail the run
+func collectDiagnostics(opts types.Options, d types.Deployer) {
+	if oWithDiagnostics, ok := opts.(optionsWithDiagnostics); !ok || !oWithDiagnostics.CollectDiagnostics() {
+		return
+	}
+	kubeconfig, err := deployerKubeconfig(d)
+	if err != nil {
+		events.Warningf("Not collecting the diagnostics of the cluster: %v", err)
+		return
+	}
+	collector := &diagnostics.Collector{
+		Kubeconfig: kubeconfig,
+		Dir:        filepath.Join(opts.RunDir(), "diagnostics"),
+	}
+	if err := trace.Default().Wrap("CollectDiagnostics", collector.Collect); err != nil {
+		events.Warningf("Failed to collect the diagnostics of the cluster: %v", err)
+	}
+}
+
+// deployerKubeconfig returns the kubeconfig provided by the deployer,
+// or an empty string to use the default kubeconfig
+func deployerKubeconfig(d types.Deployer) (string, error) {
+	dWithKubeconfig, ok := d.(types.DeployerWithKubeconfig)
+	if !ok {
+		return "", nil
+	}
+	kubeconfig, err := dWithKubeconfig.Kubeconfig()
+	if err != nil {
+		return "", fmt.Errorf("failed to get the kubeconfig of the deployer: %v", err)
+	}
+	return kubeconfig, nil
+}
+
+// optionsWithTestRepeat is implemented by options configuring soak runs of the tester
+type optionsWithTestRepeat interface {
+	TestRepeat() int
+	TestDuration() time.Duration
+}
+
+// optionsWithFailFast is implemented by options configuring the runs of multiple testers
+type optionsWithFailFast interface {
+	FailFast() bool
+}
 
-	meta, err2 := metadata.NewCustomJSON(nil)
-	if err2 != nil {
-		return err2
+// runTesters runs the testers in order against the same cluster, aggregating their results.
+// A single tester runs as the Test step with the run dir as its artifacts.
+func runTesters(opts types.Options, d types.Deployer, allTesters []types.Tester, writer *metadata.Writer) error {
+	return runTestersAs(opts, d, allTesters, writer, "Test", "")
+}
+
+// runTestersAs runs the testers as the named step with the artifacts under
+// relDir of the run dir, the testers of several --test get their own step and
+// artifacts, e.g. Test (ginkgo) with testers/ginkgo
+func runTestersAs(opts types.Options, d types.Deployer, allTesters []types.Tester, writer *metadata.Writer, name, relDir string) (result error) {
+	snap, err := newSnapshotter(opts, d, writer)
+	if err != nil {
+		return err
+	}
+	injector, err := newChaosInjector(opts, d)
+	if err != nil {
+		return err
 	}
-	if err := meta.Add("kubetest-version", os.Getenv("KUBETEST2_VERSION")); err != nil {
+	if injector != nil {
+		injector.Start()
+		defer func() {
+			if err := injector.Stop(); err != nil && result == nil {
+				result = err
+			}
+		}()
+	}
+
+	if len(allTesters) == 1 {
+		testerResult, err := runTester(opts, d, allTesters[0], writer, snap, name, filepath.Join(opts.RunDir(), relDir))
+		if testerResult != nil && relDir != "" {
+			relativizeArtifacts(testerResult, relDir)
+			recordTesterResult(testerResult)
+		}
 		return err
 	}
 
-	if dWithVersion, ok := d.(types.DeployerWithVersion); ok {
-		if err := meta.Add("deployer-version", dWithVersion.Version()); err != nil {
+	failFast := false
+	if oWithFailFast, ok := opts.(optionsWithFailFast); ok {
+		failFast = oWithFailFast.FailFast()
+	}
+	var results []*testers.Result
+	var failed []string
+	for i, id := range testerIDs(allTesters) {
+		// each tester gets its own artifacts so that e.g. their junit files do not collide
+		testerDir := filepath.Join(relDir, "testers", id)
+		artifactsDir := filepath.Join(opts.RunDir(), testerDir)
+		if err := os.MkdirAll(artifactsDir, os.ModePerm); err != nil {
 			return err
 		}
+		events.Progressf("Running tester %d of %d (%s), artifacts in %q", i+1, len(allTesters), id, artifactsDir)
+		testerResult, err := runTester(opts, d, allTesters[i], writer, snap, fmt.Sprintf("%s (%s)", name, id), artifactsDir)
+		if testerResult != nil {
+			relativizeArtifacts(testerResult, testerDir)
+			results = append(results, testerResult)
+		}
+		if err != nil {
+			klog.Errorf("Tester %s failed: %v", id, err)
+			failed = append(failed, id)
+			if failFast {
+				klog.Infof("Not running the remaining testers with --fail-fast")
+				break
+			}
+		}
+	}
+	// the result of the run is the sum of the results of the testers
+	if merged := testers.Merge(results...); merged != nil {
+		klog.Infof("Result of the testers: %s", merged.Summary())
+		recordTesterResult(merged)
 	}
+	if err := metadata.Default().SetStrings("testers-failed", failed); err != nil {
+		klog.Warningf("Failed to record the failed testers in the metadata: %v", err)
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("%d of %d testers failed: %s", len(failed), len(allTesters), strings.Join(failed, ", "))
+	}
+	return nil
+}
 
-	if err := meta.Write(metadataJSON); err != nil {
-		return err
+// relativizeArtifacts makes the artifacts reported by a tester relative to
+// the run dir rather than to relDir, its artifacts dir, leaving URLs alone
+func relativizeArtifacts(result *testers.Result, relDir string) {
+	for i, artifact := range result.Artifacts {
+		if !strings.Contains(artifact, "://") {
+			result.Artifacts[i] = filepath.Join(relDir, artifact)
+		}
 	}
+}
 
-	if err := metadataJSON.Sync(); err != nil {
-		return err
+// testerIDs returns the names of the testers, suffixed with their occurrence
+// for the testers selected more than once e.g. exec, exec-2
+func testerIDs(allTesters []types.Tester) []string {
+	ids := make([]string, len(allTesters))
+	seen := map[string]int{}
+	for i, tester := range allTesters {
+		seen[tester.Name]++
+		ids[i] = tester.Name
+		if n := seen[tester.Name]; n > 1 {
+			ids[i] = fmt.Sprintf("%s-%d", tester.Name, n)
+		}
+	}
+	return ids
+}
+
+// runTester runs the tester as the named step, repeatedly against the same cluster for
+// soak runs with --test-repeat and/or --test-duration, aggregating the iterations.
+// The result is the one reported by the tester, not reported for soak runs.
+func runTester(opts types.Options, d types.Deployer, tester types.Tester, writer *metadata.Writer, snap *snapshotter, name, artifactsDir string) (*testers.Result, error) {
+	repeat, duration := 0, time.Duration(0)
+	if oWithTestRepeat, ok := opts.(optionsWithTestRepeat); ok {
+		repeat, duration = oWithTestRepeat.TestRepeat(), oWithTestRepeat.TestDuration()
+	}
+	if repeat < 0 || duration < 0 {
+		return nil, fmt.Errorf("--test-repeat and --test-duration must not be negative")
+	}
+
+	start := time.Now()
+	if repeat <= 1 && duration == 0 {
+		result, err := runTesterIteration(opts, d, tester, writer, snap, name, artifactsDir)
+		metrics.Default().ObservePhase(name, time.Since(start), err)
+		return result, err
+	}
+
+	// with only a duration, iterate until it elapses. An iteration is not
+	// interrupted when the duration elapses, but no new one is started.
+	deadline := start.Add(duration)
+	var failed []string
+	iterations := 0
+	for (repeat == 0 || iterations < repeat) && (duration == 0 || time.Now().Before(deadline)) {
+		iterations++
+		iterationName := fmt.Sprintf("%s (iteration %d)", name, iterations)
+		// each iteration gets its own artifacts so that e.g. the junit of the
+		// tester is not overwritten by the next iteration
+		iterationDir := filepath.Join(artifactsDir, "iterations", strconv.Itoa(iterations))
+		if err := os.MkdirAll(iterationDir, os.ModePerm); err != nil {
+			return nil, err
+		}
+		events.Progressf("Starting test iteration %d, artifacts in %q", iterations, iterationDir)
+		if _, err := runTesterIteration(opts, d, tester, writer, snap, iterationName, iterationDir); err != nil {
+			klog.Errorf("Test iteration %d failed: %v", iterations, err)
+			failed = append(failed, strconv.Itoa(iterations))
+		}
+	}
+	klog.Infof("Ran %d test iterations in %s, %d failed", iterations, time.Since(start).Round(time.Second), len(failed))
+	if err := metadata.Default().SetAll(map[string]string{
+		"test-iterations":        strconv.Itoa(iterations),
+		"test-failed-iterations": strings.Join(failed, ","),
+	}); err != nil {
+		klog.Warningf("Failed to record the test iterations in the metadata: %v", err)
+	}
+
+	var err error
+	if len(failed) > 0 {
+		err = fmt.Errorf("%d of %d test iterations failed: %s", len(failed), iterations, strings.Join(failed, ", "))
+	}
+	metrics.Default().ObservePhase(name, time.Since(start), err)
+	return nil, err
+}
+
+// optionsWithChaos is implemented by options configuring faults to inject while the tests run
+type optionsWithChaos interface {
+	Chaos() []string
+}
+
+// newChaosInjector returns the injector of the faults configured with --chaos, if any
+func newChaosInjector(opts types.Options, d types.Deployer) (*chaos.Injector, error) {
+	oWithChaos, ok := opts.(optionsWithChaos)
+	if !ok || len(oWithChaos.Chaos()) == 0 {
+		return nil, nil
+	}
+	faults, err := chaos.ParseFaults(oWithChaos.Chaos())
+	if err != nil {
+		return nil, err
+	}
+	kubeconfig, err := deployerKubeconfig(d)
+	if err != nil {
+		return nil, err
 	}
-	if err := metadataJSON.Close(); err != nil {
+	return chaos.NewInjector(faults, kubeconfig, d)
+}
+
+// optionsWithTestRetries is implemented by options configuring the retries of the tester
+type optionsWithTestRetries interface {
+	TestRetries() int
+}
+
+// runTesterIteration runs the tester as the named step, with artifactsDir as
+// its $ARTIFACTS, retrying it with --test-retries if its result allows it
+func runTesterIteration(opts types.Options, d types.Deployer, tester types.Tester, writer *metadata.Writer, snap *snapshotter, name, artifactsDir string) (*testers.Result, error) {
+	retries := 0
+	if oWithTestRetries, ok := opts.(optionsWithTestRetries); ok {
+		retries = oWithTestRetries.TestRetries()
+	}
+	for attempt := 0; ; attempt++ {
+		stepName, dir := name, artifactsDir
+		if attempt > 0 {
+			stepName = fmt.Sprintf("%s (retry %d)", name, attempt)
+			dir = filepath.Join(artifactsDir, "retries", strconv.Itoa(attempt))
+			if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+				return nil, err
+			}
+		}
+		// every run of the tester starts from the same etcd state with --etcd-snapshot
+		if err := snap.beforeTest(); err != nil {
+			return nil, err
+		}
+		result, err := runTesterOnce(opts, d, tester, writer, stepName, dir)
+		if err == nil || attempt >= retries {
+			return result, err
+		}
+		if result == nil || !result.Retryable {
+			klog.Infof("Not retrying the tester, as its result does not report the failure as retryable")
+			return result, err
+		}
+		events.Warningf("Retrying the tester after a retryable failure: %v", err)
+	}
+}
+
+// runTesterOnce runs the tester once as the named step, returning the result it reported, if any
+func runTesterOnce(opts types.Options, d types.Deployer, tester types.Tester, writer *metadata.Writer, name, artifactsDir string) (*testers.Result, error) {
+	test := exec.Command(tester.TesterPath, tester.TesterArgs...)
+	exec.InheritRawOutput(test)
+
+	envsForTester := runEnv(opts, artifactsDir)
+	resultPath := filepath.Join(artifactsDir, "tester-result.json")
+	// a result left by an earlier invocation of the run must not be mistaken for this one
+	if err := os.Remove(resultPath); err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	envsForTester = append(envsForTester, fmt.Sprintf("%s=%s", testers.ResultFileEnv, resultPath))
+	envsForTester = append(envsForTester, kubeconfigEnv(d)...)
+	test.SetEnv(envsForTester...)
+
+	var result *testers.Result
+	run := func() error {
+		err := test.Run()
+		result = readTesterResult(resultPath)
+		// surface the failed tests in the junit of the run rather than only the exit code
+		if err != nil && result != nil {
+			return metadata.NewJUnitError(err, result.Summary())
+		}
 		return err
 	}
+	err := trace.Default().Wrap(name, func() error {
+		if opts.SkipTestJUnitReport() {
+			return run()
+		}
+		return writer.WrapStep(name, run)
+	})
+	return result, err
+}
+
+// runEnv returns the environment of the commands run against the cluster of
+// the run, i.e. the testers and the hooks, with artifactsDir as their $ARTIFACTS
+func runEnv(opts types.Options, artifactsDir string) []string {
+	env := os.Environ()
+	// We expose both ARIFACTS and KUBETEST2_RUN_DIR so we can more granular about caching vs output in future.
+	// also add run_dir to $PATH for locally built binaries
+	updatedPath := opts.RunDir() + string(filepath.ListSeparator) + os.Getenv("PATH")
+	env = append(env, fmt.Sprintf("%s=%s", "PATH", updatedPath))
+	env = append(env, fmt.Sprintf("%s=%s", "ARTIFACTS", artifactsDir))
+	env = append(env, fmt.Sprintf("%s=%s", "KUBETEST2_RUN_DIR", opts.RunDir()))
+	env = append(env, fmt.Sprintf("%s=%s", "KUBETEST2_RUN_ID", opts.RunID()))
+	// propagate the trace so that instrumented commands can add their spans to it
+	if traceparent := trace.Default().Traceparent(); traceparent != "" {
+		env = append(env, fmt.Sprintf("%s=%s", "TRACEPARENT", traceparent))
+	}
+	return env
+}
+
+// kubeconfigEnv returns the KUBECONFIG of the deployer, if it provides one,
+// else assumes that it is handled offline by default methods like ~/.kube/config
+func kubeconfigEnv(d types.Deployer) []string {
+	if dWithKubeconfig, ok := d.(types.DeployerWithKubeconfig); ok {
+		if kconfig, err := dWithKubeconfig.Kubeconfig(); err == nil {
+			return []string{fmt.Sprintf("%s=%s", "KUBECONFIG", kconfig)}
+		}
+	}
 	return nil
 }
+
+// readTesterResult reads the result reported by the tester and merges it into the metadata of the run
+func readTesterResult(path string) *testers.Result {
+	result, err := testers.ReadResult(path)
+	if err != nil {
+		events.Warningf("Ignoring the tester result: %v", err)
+		return nil
+	}
+	if result == nil {
+		return nil
+	}
+	klog.Infof("Tester result: %s", result.Summary())
+	recordTesterResult(result)
+	return result
+}
+
+// recordTesterResult records the result in the metadata of the run
+func recordTesterResult(result *testers.Result) {
+	failures := make([]string, len(result.Failures))
+	for i, f := range result.Failures {
+		failures[i] = f.Name
+	}
+	store := metadata.Default()
+	if err := store.SetAll(map[string]string{
+		metadata.TestsPassedKey:  strconv.Itoa(result.Passed),
+		metadata.TestsFailedKey:  strconv.Itoa(result.Failed),
+		metadata.TestsSkippedKey: strconv.Itoa(result.Skipped),
+	}); err != nil {
+		klog.Warningf("Failed to record the tester result in the metadata: %v", err)
+	}
+	if err := store.SetStrings(metadata.TestFailuresKey, failures); err != nil {
+		klog.Warningf("Failed to record the test failures in the metadata: %v", err)
+	}
+	if err := store.SetStrings(metadata.TestArtifactsKey, result.Artifacts); err != nil {
+		klog.Warningf("Failed to record the test artifacts in the metadata: %v", err)
+	}
+}
+
+// wrapStep runs the step as a JUnit test case and a span of the trace, and
+// records its duration and result in the metrics
+func wrapStep(writer *metadata.Writer, name string, doStep func() error) error {
+	start := time.Now()
+	events.Default().PhaseStarted(name)
+	err := trace.Default().Wrap(name, func() error {
+		return writer.WrapStep(name, doStep)
+	})
+	metrics.Default().ObservePhase(name, time.Since(start), err)
+	events.Default().PhaseEnded(name, time.Since(start), err)
+	return err
+}
+
+// optionsWithMetrics is implemented by options configuring the output of the metrics
+type optionsWithMetrics interface {
+	PushgatewayURL() string
+	MetricsJob() string
+	WriteMetrics() bool
+	DeployerName() string
+}
+
+// newMetricsRegistry creates the metrics registry of the run and makes it the
+// default registry that the deployer records e.g. retries to
+func newMetricsRegistry(opts types.Options) *metrics.Registry {
+	labels := map[string]string{}
+	if oWithMetrics, ok := opts.(optionsWithMetrics); ok && oWithMetrics.DeployerName() != "" {
+		labels["deployer"] = oWithMetrics.DeployerName()
+	}
+	r := metrics.NewRegistry(labels)
+	metrics.SetDefault(r)
+	return r
+}
+
+// flushMetrics writes out and pushes the metrics as configured, failing to
+// do so is logged but does not fail the run
+func flushMetrics(opts types.Options, r *metrics.Registry) {
+	oWithMetrics, ok := opts.(optionsWithMetrics)
+	if !ok {
+		return
+	}
+	if oWithMetrics.WriteMetrics() {
+		path := filepath.Join(opts.RunDir(), "metrics.txt")
+		if err := r.WriteFile(path); err != nil {
+			klog.Warningf("Failed to write the metrics to %s: %v", path, err)
+		}
+	}
+	if gateway := oWithMetrics.PushgatewayURL(); gateway != "" {
+		grouping := map[string]string{}
+		if deployer := oWithMetrics.DeployerName(); deployer != "" {
+			grouping["deployer"] = deployer
+		}
+		if err := r.Push(gateway, oWithMetrics.MetricsJob(), grouping); err != nil {
+			klog.Warningf("Failed to push the metrics: %v", err)
+		}
+	}
+}
+
+// optionsWithTrace is implemented by options configuring the export of the trace
+type optionsWithTrace interface {
+	TraceOTLPEndpoint() string
+	WriteTrace() bool
+}
+
+// newTracer creates the tracer of the run if the trace is exported,
+// and makes it the default tracer that commands record their spans to
+func newTracer(opts types.Options) *trace.Tracer {
+	oWithTrace, ok := opts.(optionsWithTrace)
+	if !ok || (oWithTrace.TraceOTLPEndpoint() == "" && !oWithTrace.WriteTrace()) {
+		return nil
+	}
+	resource := map[string]string{
+		"service.name":      "kubetest2",
+		"kubetest2.run_id":  opts.RunID(),
+		"kubetest2.version": os.Getenv("KUBETEST2_VERSION"),
+	}
+	if oWithMetrics, ok := opts.(optionsWithMetrics); ok {
+		resource["kubetest2.deployer"] = oWithMetrics.DeployerName()
+	}
+	t := trace.NewTracer("kubetest2", resource)
+	trace.SetDefault(t)
+	return t
+}
+
+// flushTrace ends the trace with the result of the run and exports it as
+// configured, failing to do so is logged but does not fail the run
+func flushTrace(opts types.Options, t *trace.Tracer, result error) {
+	if t == nil {
+		return
+	}
+	t.Finish(result)
+	oWithTrace := opts.(optionsWithTrace)
+	if oWithTrace.WriteTrace() {
+		path := filepath.Join(opts.RunDir(), "trace.json")
+		if err := t.WriteFile(path); err != nil {
+			klog.Warningf("Failed to write the trace to %s: %v", path, err)
+		}
+	}
+	if endpoint := oWithTrace.TraceOTLPEndpoint(); endpoint != "" {
+		if err := t.Export(endpoint); err != nil {
+			klog.Warningf("Failed to export the trace: %v", err)
+		}
+	}
+}
+
+// optionsWithRateLimit is implemented by options configuring the rate limit of the GCP API calls
+type optionsWithRateLimit interface {
+	APIQPS() float64
+	APIBurst() int
+}
+
+// optionsWithMetadata is implemented by options supplying user metadata
+type optionsWithMetadata interface {
+	Metadata() []string
+}
+
+// writeVersionToMetadataJSON starts the metadata.json of the run with the
+// versions and the user supplied metadata, and makes it the default metadata
+// store that the deployer writes to
+func writeVersionToMetadataJSON(opts types.Options, d types.Deployer) error {
+	values := map[string]string{
+		metadata.KubetestVersionKey: os.Getenv("KUBETEST2_VERSION"),
+	}
+	if dWithVersion, ok := d.(types.DeployerWithVersion); ok {
+		values[metadata.DeployerVersionKey] = dWithVersion.Version()
+	}
+	if oWithMetadata, ok := opts.(optionsWithMetadata); ok {
+		userValues, err := metadata.ParseKeyValues(oWithMetadata.Metadata())
+		if err != nil {
+			return err
+		}
+		for key, value := range userValues {
+			if _, exists := values[key]; exists {
+				return fmt.Errorf("key %s already exists in the metadata", key)
+			}
+			values[key] = value
+		}
+	}
+
+	meta := metadata.NewStore(filepath.Join(opts.RunDir(), "metadata.json"))
+	if err := meta.Reset(); err != nil {
+		return err
+	}
+	metadata.SetDefault(meta)
+	return meta.SetAll(values)
+}
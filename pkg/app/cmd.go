@@ -22,6 +22,7 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/pkg/errors"
@@ -31,6 +32,7 @@ import (
 	"sigs.k8s.io/kubetest2/pkg/app/shim"
 	"sigs.k8s.io/kubetest2/pkg/artifacts"
 	"sigs.k8s.io/kubetest2/pkg/exec"
+	"sigs.k8s.io/kubetest2/pkg/readiness"
 	"sigs.k8s.io/kubetest2/pkg/types"
 )
 
@@ -62,7 +64,7 @@ func runE(
 	deployerName string, newDeployer types.NewDeployer,
 ) error {
 	// setup the options struct & flags, etc.
-	opts := &options{}
+	opts := &options{deployerName: deployerName}
 	kubetest2Flags := pflag.NewFlagSet(deployerName, pflag.ContinueOnError)
 	opts.bindFlags(kubetest2Flags)
 	artifacts.MustBindFlags(kubetest2Flags)
@@ -84,12 +86,13 @@ func runE(
 	// We will later show this + usage if there is one
 	parseError := kubetest2Flags.Parse(deployerArgs)
 
-	// now that we've parsed flags we can look up the tester
-	tester := types.Tester{}
-	if opts.test != "" {
-		testerPath, err := shim.FindTester(opts.test)
+	// now that we've parsed flags we can look up the testers
+	var allTesters []types.Tester
+	for i, testerArgs := range splitTesterArgs(testerArgs, len(opts.tests)) {
+		name := opts.tests[i]
+		testerPath, err := shim.FindTester(name)
 		if err != nil {
-			return fmt.Errorf("unable to find tester %v: %v", opts.test, err)
+			return fmt.Errorf("unable to find tester %v: %v", name, err)
 		}
 
 		// Get tester usage by running it with --help
@@ -99,16 +102,17 @@ func runE(
 		testerUsageCmd := exec.Command(testerPath, helpArgs...)
 		var stderr bytes.Buffer
 		testerUsageCmd.SetStderr(&stderr)
-		testerUsage, err := exec.Output(testerUsageCmd)
+		testerHelp, err := exec.Output(testerUsageCmd)
 		if err != nil {
 			return fmt.Errorf(stderr.String())
 		}
 
-		usage.testerUsage = string(testerUsage)
-		usage.testerName = opts.test
-
-		tester.TesterPath = testerPath
-		tester.TesterArgs = testerArgs
+		usage.testers = append(usage.testers, testerUsage{name: name, usage: string(testerHelp)})
+		allTesters = append(allTesters, types.Tester{
+			Name:       name,
+			TesterPath: testerPath,
+			TesterArgs: testerArgs,
+		})
 	}
 
 	// instantiate the deployer
@@ -136,6 +140,16 @@ func runE(
 		parseError = err
 	}
 
+	opts.setFlags = map[string]string{}
+	allFlags.Visit(func(f *pflag.Flag) {
+		opts.setFlags[f.Name] = f.Value.String()
+	})
+
+	// describe the deployer for `kubetest2 list-deployers`
+	if opts.describeJSON {
+		return describeDeployer(cmd, deployerName, deployer, deployerFlags)
+	}
+
 	// print usage and return if no args are provided, or help is explicitly requested
 	if len(args) == 0 || opts.HelpRequested() {
 		cmd.Print(usage.String())
@@ -162,8 +176,26 @@ func runE(
 		return parseError
 	}
 
+	// check the environment of the deployer for `kubetest2 doctor`, the
+	// testers of --test were found above
+	if opts.doctor {
+		return runDoctor(cmd, deployerName, deployer)
+	}
+
 	// run RealMain, which contains all of the logic beyond the CLI boilerplate
-	return RealMain(opts, deployer, tester)
+	return RealMain(opts, deployer, allTesters...)
+}
+
+// describeDeployer prints the types.Description of the deployer as JSON
+func describeDeployer(cmd *cobra.Command, deployerName string, d types.Deployer, deployerFlags *pflag.FlagSet) error {
+	var version, description string
+	if dWithVersion, ok := d.(types.DeployerWithVersion); ok {
+		version = dWithVersion.Version()
+	}
+	if dWithDescription, ok := d.(types.DeployerWithDescription); ok {
+		description = dWithDescription.Description()
+	}
+	return types.NewDescription(deployerName, version, description, deployerFlags).Write(cmd.OutOrStdout())
 }
 
 // splitArgs splits args into deployerArgs and testerArgs at the first bare `--`
@@ -182,15 +214,67 @@ func splitArgs(args []string) ([]string, []string) {
 	return args, testArgs
 }
 
+// splitTesterArgs splits the testerArgs of n testers at the next n-1 bare `--`,
+// the last tester gets the remaining args including any further `--`
+func splitTesterArgs(testerArgs []string, n int) [][]string {
+	split := make([][]string, n)
+	for i := 0; i < n-1; i++ {
+		split[i], testerArgs = splitArgs(testerArgs)
+	}
+	if n > 0 {
+		split[n-1] = testerArgs
+	}
+	return split
+}
+
 // options holds flag values and implements deployer.Options
 type options struct {
 	help                bool
 	build               bool
 	up                  bool
 	down                bool
-	test                string
+	tests               []string
+	failFast            bool
+	onFailure           string
+	etcdSnapshot        bool
 	skipTestJUnitReport bool
+	testRepeat          int
+	testRetries         int
+	diagnostics         bool
+	testDuration        time.Duration
+	chaos               []string
+	skewSequence        string
+	preUpHooks          []string
+	postUpHooks         []string
+	preTestHooks        []string
+	postTestHooks       []string
+	preDownHooks        []string
+	hookFailure         string
+	verifyClusterUp     bool
+	waitFor             []string
+	featureGates        []string
+	runtimeConfig       []string
+	waitForTimeout      time.Duration
+	streamTo            string
+	streamInterval      time.Duration
+	streamPatterns      []string
 	runid               string
+	resume              string
+	apiQPS              float64
+	apiBurst            int
+	metadata            []string
+	pushgateway         string
+	metricsJob          string
+	writeMetrics        bool
+	otlpEndpoint        string
+	writeTrace          bool
+	output              string
+	eventsFile          string
+	deployerName        string
+	describeJSON        bool
+	doctor              bool
+	// setFlags are the flags set explicitly, recorded to the history
+	setFlags map[string]string
 }
 
 // bindFlags registers all first class kubetest2 flags
@@ -199,10 +283,57 @@ func (o *options) bindFlags(flags *pflag.FlagSet) {
 	flags.BoolVar(&o.build, "build", false, "build kubernetes")
 	flags.BoolVar(&o.up, "up", false, "provision the test cluster")
 	flags.BoolVar(&o.down, "down", false, "tear down the test cluster")
-	flags.StringVar(&o.test, "test", "", "test type to run, if unset no tests will run")
+	flags.StringArrayVar(&o.tests, "test", nil, "test type to run, if unset no tests will run. Can be repeated to run several testers in order "+
+		"against the same cluster, with the args of each tester separated by a bare -- e.g. --test=ginkgo --test=exec -- --focus-regex=Conformance -- ./smoke.sh")
+	flags.StringVar(&o.onFailure, "on-failure", "down", "what to do when up or the tests fail, down to tear down the cluster right away, "+
+		"or pause[:duration] to print how to connect to the cluster and hold it open for debugging until enter is pressed or the duration (30m by default) elapses")
+	flags.BoolVar(&o.etcdSnapshot, "etcd-snapshot", false, "snapshot etcd before the first test run and restore the snapshot before every later one "+
+		"(iterations, retries and testers), so that destructive tests run against a pristine control plane. Requires a deployer supporting it e.g. kind or minikube")
+	flags.BoolVar(&o.failFast, "fail-fast", false, "with several --test, do not run the remaining testers once one fails")
 	flags.BoolVar(&o.skipTestJUnitReport, "skip-test-junit-report", false, "skip reporting the test step as a JUnit test case, "+
 		"should be set to true when solely relying on the tester binary to generate it's own junit.")
 
+	flags.BoolVar(&o.verifyClusterUp, "verify-cluster-up", false, "after up, check that the nodes are ready, the kube-system pods are healthy and "+
+		"that a service is reachable by its DNS name, failing fast with diagnostics if not")
+	flags.IntVar(&o.testRepeat, "test-repeat", 0, "run the tester this many times against the same cluster, e.g. for soak runs, "+
+		"the artifacts of each iteration are put under iterations/<N> in the run dir")
+	flags.IntVar(&o.testRetries, "test-retries", 0, "retry the tester up to this many times when it fails, if the result it reports marks the failure as retryable "+
+		"e.g. a failed test setup, the artifacts of each retry are put under retries/<N>")
+	flags.BoolVar(&o.diagnostics, "collect-diagnostics", true, "when the tester fails, collect the state of the workloads of the cluster with kubectl "+
+		"(all the resources, the events, the nodes and the descriptions and logs of the unhealthy pods) to diagnostics/ in the run dir before down")
+	flags.DurationVar(&o.testDuration, "test-duration", 0, "keep re-running the tester against the same cluster until this duration elapses e.g. 4h, "+
+		"if --test-repeat is also set the tester runs at most that many times")
+
+	flags.StringSliceVar(&o.chaos, "chaos", nil, "faults to inject while the tests run as action:interval e.g. node-reboot:10m, "+
+		"actions are node-drain, node-reboot, pod-kill and zone-failure, node-reboot and zone-failure require support by the deployer")
+
+	flags.StringVar(&o.skewSequence, "skew-sequence", "", "path to a JSON file with the sequence of steps of a version skew test, run instead of running the testers once: "+
+		`e.g. [{"action": "test"}, {"action": "upgrade-control-plane", "version": "1.21.0"}, {"action": "test"}, {"action": "upgrade-nodes", "version": "1.21.0"}, {"action": "test"}], `+
+		"the artifacts of each test step are put under skew/<N>. Requires a deployer supporting upgrades e.g. gke")
+
+	flags.StringSliceVar(&o.featureGates, "kube-feature-gates", nil, "NAME=true|false feature gates of the Kubernetes components of the cluster brought up, "+
+		"e.g. InPlacePodVerticalScaling=true, translated by the deployer to its configuration, which fails the run before up if it cannot honor them")
+	flags.StringSliceVar(&o.runtimeConfig, "runtime-config", nil, "KEY=VALUE runtime config of the API server of the cluster brought up, e.g. api/all=true or "+
+		"resource.k8s.io/v1alpha2=true, translated by the deployer to its configuration, which fails the run before up if it cannot honor them")
+	flags.StringArrayVar(&o.waitFor, "wait-for", nil, "after up and the --post-up-hook, wait for an object to be ready before the testers start, as KIND/NAME[,-n NAMESPACE] "+
+		"e.g. deployment/coredns,-n kube-system or crd/foo.example.com, where the kind is one of deployment, daemonset, statefulset, endpoints or crd, can be repeated")
+	flags.DurationVar(&o.waitForTimeout, "wait-for-timeout", readiness.DefaultTimeout, "time to wait for all the --wait-for objects to be ready before failing the run")
+
+	flags.StringVar(&o.streamTo, "artifacts-stream-to", "", "gs://BUCKET/PATH or s3://BUCKET/PATH to upload the logs and junit files of the run dir to "+
+		"periodically during the test phase, under the run ID as in --artifacts, so that a run killed midway e.g. by the infrastructure still leaves usable artifacts. "+
+		"Only the changes since the last upload are uploaded, requires gsutil or the aws cli")
+	flags.DurationVar(&o.streamInterval, "artifacts-stream-interval", artifacts.DefaultStreamInterval, "how often to upload the changes of the artifacts with --artifacts-stream-to")
+	flags.StringSliceVar(&o.streamPatterns, "artifacts-stream-patterns", artifacts.DefaultStreamPatterns, "names of the files of the run dir to upload with --artifacts-stream-to")
+
+	hookUsage := "shell command to run %s, with the environment of the testers (e.g. $KUBECONFIG, $ARTIFACTS and $KUBETEST2_RUN_DIR)%s, can be repeated"
+	flags.StringArrayVar(&o.preUpHooks, "pre-up-hook", nil, fmt.Sprintf(hookUsage, "before up", " but $KUBECONFIG"))
+	flags.StringArrayVar(&o.postUpHooks, "post-up-hook", nil, fmt.Sprintf(hookUsage, "after up, e.g. to install CRDs or operators", ""))
+	flags.StringArrayVar(&o.preTestHooks, "pre-test-hook", nil, fmt.Sprintf(hookUsage, "before the testers", ""))
+	flags.StringArrayVar(&o.postTestHooks, "post-test-hook", nil, fmt.Sprintf(hookUsage, "after the testers", " and $KUBETEST2_TEST_RESULT (passed or failed)"))
+	flags.StringArrayVar(&o.preDownHooks, "pre-down-hook", nil, fmt.Sprintf(hookUsage, "before down", ""))
+	flags.StringVar(&o.hookFailure, "hook-failure", hookFailureFatal, "what to do when a hook fails, fatal to fail the run, or warn to only log the failure. "+
+		"A failed --pre-down-hook never keeps the cluster from being torn down")
+
 	var defaultRunID string
 	// reuse uid for CI use cases
 	if uid, exists := os.LookupEnv("PROW_JOB_ID"); exists && uid != "" {
@@ -211,6 +342,28 @@ func (o *options) bindFlags(flags *pflag.FlagSet) {
 		defaultRunID = uuid.New().String()
 	}
 	flags.StringVar(&o.runid, "run-id", defaultRunID, "unique identifier for a kubetest2 run")
+	flags.StringVar(&o.resume, "resume", "", "ID of a previous failed run to resume, only re-running the phases that did not succeed, "+
+		"the run dir of that run must still exist and the other flags should match the ones it was started with")
+	flags.StringArrayVar(&o.metadata, "metadata", nil, "KEY=VALUE to add to the metadata.json of the run e.g. for testgrid, can be repeated")
+	flags.StringVar(&o.pushgateway, "metrics-pushgateway", "", "URL of a Prometheus pushgateway to push the metrics of the run phases to, e.g. http://pushgateway:9091")
+	defaultMetricsJob := "kubetest2"
+	if job := os.Getenv("JOB_NAME"); job != "" {
+		defaultMetricsJob = job
+	}
+	flags.StringVar(&o.metricsJob, "metrics-job", defaultMetricsJob, "job name to push the metrics under, defaults to $JOB_NAME if set")
+	flags.BoolVar(&o.writeMetrics, "write-metrics", false, "write the metrics of the run phases as an OpenMetrics file to metrics.txt in the run dir")
+	flags.StringVar(&o.otlpEndpoint, "trace-otlp-endpoint", os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT"), "OTLP/HTTP endpoint to export the trace of the run to, e.g. http://otel-collector:4318, defaults to $OTEL_EXPORTER_OTLP_ENDPOINT")
+	flags.BoolVar(&o.writeTrace, "write-trace", false, "write the trace of the run in the OTLP JSON encoding to trace.json in the run dir")
+	flags.StringVar(&o.output, "output", outputText, "what to write to stdout, text for the output of the commands run, or events for line delimited JSON events "+
+		"(phase-start, phase-end, command, progress, warning and result) to render the progress of the run, with the output of the commands on stderr instead")
+	flags.StringVar(&o.eventsFile, "events-file", "", "file to also write the line delimited JSON events of the run to, regardless of --output")
+	flags.Float64Var(&o.apiQPS, "api-qps", 10, "maximum average number of GCP API calls e.g. gcloud invocations per second shared by the whole run, 0 disables the limit, "+
+		"the throttled calls are retried with backoff regardless")
+	flags.IntVar(&o.apiBurst, "api-burst", 20, "maximum number of GCP API calls allowed in a burst above --api-qps")
+	flags.BoolVar(&o.describeJSON, types.DescribeFlag, false, "print the name, version, description and flags of the deployer as JSON")
+	_ = flags.MarkHidden(types.DescribeFlag)
+	flags.BoolVar(&o.doctor, types.DoctorFlag, false, "run the preflight checks of the deployer for the flags instead of a run")
+	_ = flags.MarkHidden(types.DoctorFlag)
 }
 
 // assert that options implements deployer options
@@ -233,28 +386,203 @@ func (o *options) ShouldDown() bool {
 }
 
 func (o *options) ShouldTest() bool {
-	return o.test != ""
+	return len(o.tests) > 0
 }
 
 func (o *options) SkipTestJUnitReport() bool {
 	return o.skipTestJUnitReport
 }
 
+// VerifyClusterUp returns true if the cluster should be smoke checked after up
+func (o *options) VerifyClusterUp() bool {
+	return o.verifyClusterUp
+}
+
+// TestRepeat returns the number of times to run the tester
+func (o *options) TestRepeat() int {
+	return o.testRepeat
+}
+
+// TestRetries returns the number of times to retry the tester after a retryable failure
+func (o *options) TestRetries() int {
+	return o.testRetries
+}
+
+// CollectDiagnostics returns true if the diagnostics of the cluster are collected on test failures
+func (o *options) CollectDiagnostics() bool {
+	return o.diagnostics
+}
+
+// FailFast returns true if the remaining testers are not run once one fails
+func (o *options) FailFast() bool {
+	return o.failFast
+}
+
+// EtcdSnapshot returns true if etcd is restored to its state before the first test run before every later one
+func (o *options) EtcdSnapshot() bool {
+	return o.etcdSnapshot
+}
+
+// OnFailure returns what to do when a phase fails
+func (o *options) OnFailure() string {
+	return o.onFailure
+}
+
+// TestDuration returns the duration to keep re-running the tester for
+func (o *options) TestDuration() time.Duration {
+	return o.testDuration
+}
+
+// Chaos returns the faults to inject while the tests run
+func (o *options) Chaos() []string {
+	return o.chaos
+}
+
+// Hooks returns the commands to run around the phase e.g. post-up
+func (o *options) Hooks(phase string) []string {
+	switch phase {
+	case preUpHook:
+		return o.preUpHooks
+	case postUpHook:
+		return o.postUpHooks
+	case preTestHook:
+		return o.preTestHooks
+	case postTestHook:
+		return o.postTestHooks
+	case preDownHook:
+		return o.preDownHooks
+	default:
+		return nil
+	}
+}
+
+// HookFailure returns what to do when a hook fails
+func (o *options) HookFailure() string {
+	return o.hookFailure
+}
+
+// FeatureGates returns the feature gates of the components of the cluster
+func (o *options) FeatureGates() []string {
+	return o.featureGates
+}
+
+// RuntimeConfig returns the runtime config of the API server of the cluster
+func (o *options) RuntimeConfig() []string {
+	return o.runtimeConfig
+}
+
+// WaitFor returns the objects to wait for after up
+func (o *options) WaitFor() []string {
+	return o.waitFor
+}
+
+// WaitForTimeout returns the time to wait for the --wait-for objects
+func (o *options) WaitForTimeout() time.Duration {
+	return o.waitForTimeout
+}
+
+// ArtifactsStreamTo returns where to stream the artifacts to during the test phase
+func (o *options) ArtifactsStreamTo() string {
+	return o.streamTo
+}
+
+// ArtifactsStreamInterval returns how often to upload the changes of the artifacts
+func (o *options) ArtifactsStreamInterval() time.Duration {
+	return o.streamInterval
+}
+
+// ArtifactsStreamPatterns returns the names of the files to stream
+func (o *options) ArtifactsStreamPatterns() []string {
+	return o.streamPatterns
+}
+
+// SkewSequence returns the path to the steps of the version skew test
+func (o *options) SkewSequence() string {
+	return o.skewSequence
+}
+
+// Output returns what to write to stdout, text or events
+func (o *options) Output() string {
+	return o.output
+}
+
+// EventsFile returns the file to write the events of the run to, if any
+func (o *options) EventsFile() string {
+	return o.eventsFile
+}
+
 func (o *options) RunID() string {
+	if o.resume != "" {
+		return o.resume
+	}
 	return o.runid
 }
 
+// APIQPS returns the rate limit of the GCP API calls of the run
+func (o *options) APIQPS() float64 {
+	return o.apiQPS
+}
+
+// APIBurst returns the burst allowed above the rate limit of the GCP API calls
+func (o *options) APIBurst() int {
+	return o.apiBurst
+}
+
+// Resume returns true if a previous run is being resumed
+func (o *options) Resume() bool {
+	return o.resume != ""
+}
+
 func (o *options) RunDir() string {
 	return filepath.Join(artifacts.BaseDir(), o.RunID())
 }
 
+// Metadata returns the user supplied KEY=VALUE metadata
+func (o *options) Metadata() []string {
+	return o.metadata
+}
+
+// PushgatewayURL returns the URL of the pushgateway to push the metrics to
+func (o *options) PushgatewayURL() string {
+	return o.pushgateway
+}
+
+// MetricsJob returns the job name to push the metrics under
+func (o *options) MetricsJob() string {
+	return o.metricsJob
+}
+
+// WriteMetrics returns true if the metrics should be written to the run dir
+func (o *options) WriteMetrics() bool {
+	return o.writeMetrics
+}
+
+// TraceOTLPEndpoint returns the OTLP/HTTP endpoint to export the trace to
+func (o *options) TraceOTLPEndpoint() string {
+	return o.otlpEndpoint
+}
+
+// WriteTrace returns true if the trace should be written to the run dir
+func (o *options) WriteTrace() bool {
+	return o.writeTrace
+}
+
+// DeployerName returns the name of the deployer of the run
+func (o *options) DeployerName() string {
+	return o.deployerName
+}
+
+// SetFlags returns the kubetest2 and deployer flags set explicitly, with their values
+func (o *options) SetFlags() map[string]string {
+	return o.setFlags
+}
+
 // metadata used for CLI usage string
 type usage struct {
 	kubetest2Flags *pflag.FlagSet
 	deployerFlags  *pflag.FlagSet
 	deployerName   string
-	testerName     string
-	testerUsage    string
+	testers        []testerUsage
 	// purely computed fields, see Default()
 	deployerUsage string
 }
@@ -264,11 +592,19 @@ func (u *usage) setDefaults() {
 	if u.deployerFlags != nil {
 		u.deployerUsage = u.deployerFlags.FlagUsages()
 	}
-	if u.testerUsage == "" {
-		u.testerUsage = fmt.Sprintf("  NONE - %s has no usage", u.testerName)
+	for i := range u.testers {
+		if u.testers[i].usage == "" {
+			u.testers[i].usage = fmt.Sprintf("  NONE - %s has no usage", u.testers[i].name)
+		}
 	}
 }
 
+// testerUsage is the usage of a selected tester
+type testerUsage struct {
+	name  string
+	usage string
+}
+
 // helper to compute usage text
 func (u *usage) String() string {
 	// fixup any default values
@@ -278,7 +614,7 @@ func (u *usage) String() string {
 	s := fmt.Sprintf(
 		strings.TrimPrefix(`
 Usage:
-  kubetest2 %s [Flags] [DeployerFlags] -- [TesterArgs]
+  kubetest2 %s [Flags] [DeployerFlags] -- [TesterArgs] [-- [TesterArgs]...]
 
 Flags:
 %s
@@ -291,15 +627,15 @@ DeployerFlags(%s):
 		u.deployerUsage,
 	)
 
-	// add tester info if we selected a tester and have it
-	if u.testerName != "" {
+	// add tester info for the selected testers
+	for _, t := range u.testers {
 		s += fmt.Sprintf(
 			strings.TrimPrefix(`
 TesterArgs(%s):
 %s
 `, "\n"),
-			u.testerName,
-			u.testerUsage,
+			t.name,
+			t.usage,
 		)
 	}
 
@@ -0,0 +1,40 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package app
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"sigs.k8s.io/kubetest2/pkg/doctor"
+	"sigs.k8s.io/kubetest2/pkg/types"
+)
+
+// runDoctor runs the preflight checks of the deployer for its flags instead
+// of a run, see `kubetest2 doctor`
+func runDoctor(cmd *cobra.Command, deployerName string, d types.Deployer) error {
+	dWithDoctor, ok := d.(types.DeployerWithDoctor)
+	if !ok {
+		cmd.Printf("The %s deployer has no preflight checks\n", deployerName)
+		return nil
+	}
+	if err := doctor.Run(cmd.OutOrStdout(), dWithDoctor.DoctorChecks()); err != nil {
+		return fmt.Errorf("the %s deployer is not ready: %v", deployerName, err)
+	}
+	return nil
+}
@@ -0,0 +1,85 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package app
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"sigs.k8s.io/kubetest2/pkg/events"
+	"sigs.k8s.io/kubetest2/pkg/types"
+)
+
+// The --output modes
+const (
+	// outputText leaves stdout to the output of the commands run
+	outputText = "text"
+	// outputEvents writes the events of the run to stdout
+	outputEvents = "events"
+)
+
+// optionsWithEvents is implemented by options configuring the events of the run
+type optionsWithEvents interface {
+	Output() string
+	EventsFile() string
+}
+
+// startEvents starts emitting the events of the run as configured, the
+// returned function stops it
+func startEvents(opts types.Options) (func(), error) {
+	oWithEvents, ok := opts.(optionsWithEvents)
+	if !ok {
+		return func() {}, nil
+	}
+	var ws []io.Writer
+	restoreStdout := func() {}
+	switch oWithEvents.Output() {
+	case "", outputText:
+	case outputEvents:
+		// stdout is left to the events, the output of the commands inheriting
+		// it e.g. the testers goes to stderr instead
+		stdout := os.Stdout
+		os.Stdout = os.Stderr
+		restoreStdout = func() { os.Stdout = stdout }
+		ws = append(ws, stdout)
+	default:
+		return nil, fmt.Errorf("invalid --output %q, must be text or events", oWithEvents.Output())
+	}
+	var eventsFile *os.File
+	if path := oWithEvents.EventsFile(); path != "" {
+		// append to the events of the earlier invocations for the same run
+		f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+		if err != nil {
+			restoreStdout()
+			return nil, fmt.Errorf("could not create the events file: %v", err)
+		}
+		eventsFile = f
+		ws = append(ws, f)
+	}
+	if len(ws) == 0 {
+		return func() {}, nil
+	}
+	events.SetDefault(events.NewEmitter(opts.RunID(), ws...))
+	return func() {
+		events.SetDefault(nil)
+		restoreStdout()
+		if eventsFile != nil {
+			eventsFile.Close()
+		}
+	}, nil
+}
@@ -0,0 +1,69 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package app
+
+import (
+	"fmt"
+
+	"k8s.io/klog"
+
+	"sigs.k8s.io/kubetest2/pkg/featuregates"
+	"sigs.k8s.io/kubetest2/pkg/metadata"
+	"sigs.k8s.io/kubetest2/pkg/types"
+)
+
+// optionsWithFeatureGates is implemented by options configuring the feature
+// gates and the runtime config of the cluster
+type optionsWithFeatureGates interface {
+	FeatureGates() []string
+	RuntimeConfig() []string
+}
+
+// configureFeatureGates passes the --kube-feature-gates and --runtime-config
+// to the deployer, failing if it does not support them or cannot honor them,
+// and records them in the metadata of the run
+func configureFeatureGates(opts types.Options, d types.Deployer) error {
+	oWithFeatureGates, ok := opts.(optionsWithFeatureGates)
+	if !ok {
+		return nil
+	}
+	gates, err := featuregates.ParseFeatureGates(oWithFeatureGates.FeatureGates())
+	if err != nil {
+		return fmt.Errorf("invalid --kube-feature-gates: %v", err)
+	}
+	runtimeConfig, err := featuregates.ParseRuntimeConfig(oWithFeatureGates.RuntimeConfig())
+	if err != nil {
+		return fmt.Errorf("invalid --runtime-config: %v", err)
+	}
+	if len(gates) == 0 && len(runtimeConfig) == 0 {
+		return nil
+	}
+	dWithFeatureGates, ok := d.(types.DeployerWithFeatureGates)
+	if !ok {
+		return fmt.Errorf("--kube-feature-gates and --runtime-config are not supported by the deployer")
+	}
+	if err := dWithFeatureGates.SetFeatureGates(gates, runtimeConfig); err != nil {
+		return fmt.Errorf("the deployer cannot honor the --kube-feature-gates or --runtime-config: %v", err)
+	}
+	if err := metadata.Default().SetAll(map[string]string{
+		metadata.FeatureGatesKey:  featuregates.FormatFeatureGates(gates),
+		metadata.RuntimeConfigKey: featuregates.FormatRuntimeConfig(runtimeConfig),
+	}); err != nil {
+		klog.Warningf("Failed to record the feature gates in the metadata: %v", err)
+	}
+	return nil
+}
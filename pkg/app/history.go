@@ -0,0 +1,88 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package app
+
+import (
+	"path/filepath"
+	"time"
+
+	"k8s.io/klog"
+
+	"sigs.k8s.io/kubetest2/pkg/exec"
+	"sigs.k8s.io/kubetest2/pkg/history"
+	"sigs.k8s.io/kubetest2/pkg/metrics"
+	"sigs.k8s.io/kubetest2/pkg/secrets"
+	"sigs.k8s.io/kubetest2/pkg/types"
+)
+
+// optionsWithHistory is implemented by options recording the runs to the
+// index of `kubetest2 history`
+type optionsWithHistory interface {
+	DeployerName() string
+	SetFlags() map[string]string
+}
+
+// recordHistory appends the summary of the invocation to the runs index of
+// the artifacts dir, with the phases observed by the metrics registry.
+// Failing to do so is logged but does not fail the run.
+func recordHistory(opts types.Options, allTesters []types.Tester, r *metrics.Registry, started time.Time, result error) {
+	oWithHistory, ok := opts.(optionsWithHistory)
+	if !ok {
+		return
+	}
+	run := &history.Run{
+		ID:       opts.RunID(),
+		Deployer: oWithHistory.DeployerName(),
+		Flags:    map[string]string{},
+		RunDir:   opts.RunDir(),
+		Started:  started,
+		Seconds:  time.Since(started).Seconds(),
+		Passed:   result == nil,
+	}
+	// the flags and args may hold e.g. tokens
+	for name, value := range oWithHistory.SetFlags() {
+		run.Flags[name] = redactFlag(name, value)
+	}
+	for _, tester := range allTesters {
+		t := history.Tester{Name: tester.Name}
+		for _, arg := range tester.TesterArgs {
+			t.Args = append(t.Args, exec.Redact(arg))
+		}
+		run.Testers = append(run.Testers, t)
+	}
+	if result != nil {
+		run.Error = exec.Redact(result.Error())
+	}
+	for _, p := range r.Phases() {
+		run.Phases = append(run.Phases, history.Phase{Name: p.Name, Seconds: p.Duration.Seconds(), Passed: p.Success})
+	}
+	if err := history.Record(filepath.Dir(opts.RunDir()), run); err != nil {
+		klog.Warningf("Failed to record the run to the history: %v", err)
+	}
+}
+
+// redactFlag returns the value of the flag with the secrets redacted, all of
+// it for the flags whose name looks like a secret's, e.g. --auth-token,
+// rather than only the value's well-known forms of secrets
+func redactFlag(name, value string) string {
+	flag := "--" + name + "="
+	redacted := exec.Redact(value)
+	if exec.Redact(flag+value) != flag+redacted {
+		return secrets.Redacted
+	}
+	return redacted
+}
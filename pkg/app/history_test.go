@@ -0,0 +1,42 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package app
+
+import (
+	"testing"
+
+	"sigs.k8s.io/kubetest2/pkg/secrets"
+)
+
+func TestRedactFlag(t *testing.T) {
+	testCases := []struct {
+		name     string
+		value    string
+		expected string
+	}{
+		{name: "cluster-name", value: "e2e", expected: "e2e"},
+		{name: "auth-token", value: "hunter2", expected: secrets.Redacted},
+		{name: "registry-password", value: "two words", expected: secrets.Redacted},
+		{name: "test_args", value: "--focus=x --api-key=hunter2", expected: "--focus=x --api-key=" + secrets.Redacted},
+	}
+
+	for _, tc := range testCases {
+		if actual := redactFlag(tc.name, tc.value); actual != tc.expected {
+			t.Errorf("redactFlag(%q, %q) = %q, expected %q", tc.name, tc.value, actual, tc.expected)
+		}
+	}
+}
@@ -0,0 +1,122 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package app
+
+import (
+	"fmt"
+	"strings"
+
+	"sigs.k8s.io/kubetest2/pkg/events"
+	"sigs.k8s.io/kubetest2/pkg/exec"
+	"sigs.k8s.io/kubetest2/pkg/metadata"
+	"sigs.k8s.io/kubetest2/pkg/types"
+)
+
+// The phases of the hooks, named after their flags e.g. --pre-up-hook
+const (
+	preUpHook    = "pre-up"
+	postUpHook   = "post-up"
+	preTestHook  = "pre-test"
+	postTestHook = "post-test"
+	preDownHook  = "pre-down"
+)
+
+// The --hook-failure values
+const (
+	// hookFailureFatal fails the run when a hook fails
+	hookFailureFatal = "fatal"
+	// hookFailureWarn only logs the failed hooks
+	hookFailureWarn = "warn"
+)
+
+// optionsWithHooks is implemented by options configuring the user hooks run around the phases
+type optionsWithHooks interface {
+	Hooks(phase string) []string
+	HookFailure() string
+}
+
+// hooks runs the user commands of e.g. --post-up-hook, with the environment
+// of the testers
+type hooks struct {
+	opts  types.Options
+	d     types.Deployer
+	fatal bool
+}
+
+// newHooks returns the hooks of the run, nil if the options do not support them
+func newHooks(opts types.Options, d types.Deployer) (*hooks, error) {
+	oWithHooks, ok := opts.(optionsWithHooks)
+	if !ok {
+		return nil, nil
+	}
+	switch oWithHooks.HookFailure() {
+	case "", hookFailureFatal:
+		return &hooks{opts: opts, d: d, fatal: true}, nil
+	case hookFailureWarn:
+		return &hooks{opts: opts, d: d}, nil
+	default:
+		return nil, fmt.Errorf("invalid --hook-failure %q, must be fatal or warn", oWithHooks.HookFailure())
+	}
+}
+
+// hookStepName returns the name of the junit step of the hooks of the phase e.g. PostUpHook
+func hookStepName(phase string) string {
+	var b strings.Builder
+	for _, part := range strings.Split(phase, "-") {
+		b.WriteString(strings.Title(part))
+	}
+	b.WriteString("Hook")
+	return b.String()
+}
+
+// run runs the hooks of the phase in order as a step of the junit of the run,
+// stopping at the first failure. With --hook-failure=warn the failure is only
+// logged. extraEnv is added to the environment of the hooks.
+func (h *hooks) run(phase string, writer *metadata.Writer, extraEnv ...string) error {
+	if h == nil {
+		return nil
+	}
+	commands := h.opts.(optionsWithHooks).Hooks(phase)
+	if len(commands) == 0 {
+		return nil
+	}
+	env := runEnv(h.opts, h.opts.RunDir())
+	// the cluster does not exist yet before up
+	if phase != preUpHook {
+		env = append(env, kubeconfigEnv(h.d)...)
+	}
+	env = append(env, "KUBETEST2_HOOK="+phase)
+	env = append(env, extraEnv...)
+
+	return wrapStep(writer, hookStepName(phase), func() error {
+		for i, command := range commands {
+			events.Progressf("Running %s hook %d of %d: %s", phase, i+1, len(commands), command)
+			cmd := exec.Command("sh", "-c", command)
+			cmd.SetEnv(env...)
+			exec.InheritOutput(cmd)
+			if err := cmd.Run(); err != nil {
+				err = fmt.Errorf("%s hook %q failed: %v", phase, command, err)
+				if !h.fatal {
+					events.Warningf("Ignoring the failure with --hook-failure=warn: %v", err)
+					return nil
+				}
+				return err
+			}
+		}
+		return nil
+	})
+}
@@ -0,0 +1,81 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package app
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"sigs.k8s.io/kubetest2/pkg/metadata"
+)
+
+func TestHookStepName(t *testing.T) {
+	for phase, expected := range map[string]string{
+		preUpHook:    "PreUpHook",
+		postTestHook: "PostTestHook",
+		preDownHook:  "PreDownHook",
+	} {
+		if actual := hookStepName(phase); actual != expected {
+			t.Errorf("expected %s for %s, but got %s", expected, phase, actual)
+		}
+	}
+}
+
+func TestHooks(t *testing.T) {
+	dir, err := ioutil.TempDir("", "hooks")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	out := filepath.Join(dir, "out")
+	writer := metadata.NewWriter("kubetest2", ioutil.Discard)
+
+	opts := &options{
+		runid:         "hooks",
+		postTestHooks: []string{`echo "$KUBETEST2_HOOK $KUBETEST2_TEST_RESULT" > ` + out, "false", "echo not run >> " + out},
+	}
+	if _, err := newHooks(&options{hookFailure: "ignore"}, nil); err == nil {
+		t.Errorf("expected an error for an invalid --hook-failure but got none")
+	}
+
+	opts.hookFailure = hookFailureFatal
+	h, err := newHooks(opts, nil)
+	if err != nil {
+		t.Fatalf("did not expect an error, but got: %v", err)
+	}
+	if err := h.run(preUpHook, writer); err != nil {
+		t.Errorf("did not expect an error for a phase without hooks, but got: %v", err)
+	}
+	if err := h.run(postTestHook, writer, "KUBETEST2_TEST_RESULT=passed"); err == nil {
+		t.Errorf("expected the failed hook to fail the run")
+	}
+	// the hooks after the failed one are not run
+	if data, err := ioutil.ReadFile(out); err != nil || string(data) != "post-test passed\n" {
+		t.Errorf("unexpected output of the hooks %q: %v", data, err)
+	}
+
+	opts.hookFailure = hookFailureWarn
+	h, err = newHooks(opts, nil)
+	if err != nil {
+		t.Fatalf("did not expect an error, but got: %v", err)
+	}
+	if err := h.run(postTestHook, writer, "KUBETEST2_TEST_RESULT=failed"); err != nil {
+		t.Errorf("did not expect an error with --hook-failure=warn, but got: %v", err)
+	}
+}
@@ -0,0 +1,94 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package app
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"github.com/pkg/errors"
+	"k8s.io/klog"
+)
+
+// runLockFile is the file in the run dir holding the pid of the kubetest2
+// invocation currently running it
+const runLockFile = ".lock"
+
+// acquireRunLock locks the run dir for this invocation, failing if another
+// live kubetest2 process holds it e.g. a concurrent run with the same --run-id.
+// The lock of an invocation that died without releasing it is taken over.
+func acquireRunLock(runDir string) (release func(), err error) {
+	path := filepath.Join(runDir, runLockFile)
+	for attempt := 0; attempt < 2; attempt++ {
+		f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+		if err == nil {
+			_, err = fmt.Fprintf(f, "%d\n", os.Getpid())
+			if closeErr := f.Close(); err == nil {
+				err = closeErr
+			}
+			if err != nil {
+				_ = os.Remove(path)
+				return nil, errors.Wrap(err, "could not write the run lock")
+			}
+			return func() {
+				if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+					klog.Warningf("Failed to release the run lock %s: %v", path, err)
+				}
+			}, nil
+		}
+		if !os.IsExist(err) {
+			return nil, errors.Wrap(err, "could not create the run lock")
+		}
+		if pid, err := readRunLock(path); err == nil && processAlive(pid) {
+			return nil, fmt.Errorf("the run dir %s is in use by another kubetest2 invocation (pid %d), "+
+				"concurrent runs must use different --run-id values", runDir, pid)
+		}
+		klog.Warningf("Taking over the stale run lock %s", path)
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return nil, errors.Wrap(err, "could not remove the stale run lock")
+		}
+	}
+	return nil, fmt.Errorf("could not acquire the run lock %s", path)
+}
+
+// readRunLock returns the pid recorded in the lock file
+func readRunLock(path string) (int, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.Atoi(strings.TrimSpace(string(data)))
+}
+
+// processAlive returns true if a process with the pid exists,
+// including the processes of other users that cannot be signalled
+func processAlive(pid int) bool {
+	if pid <= 0 {
+		return false
+	}
+	p, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	err = p.Signal(syscall.Signal(0))
+	return err == nil || os.IsPermission(err)
+}
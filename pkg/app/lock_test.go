@@ -0,0 +1,58 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package app
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestAcquireRunLock(t *testing.T) {
+	dir, err := ioutil.TempDir("", "runlock")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	release, err := acquireRunLock(dir)
+	if err != nil {
+		t.Fatalf("did not expect an error, but got: %v", err)
+	}
+	// this process is alive, so the lock is held
+	if _, err := acquireRunLock(dir); err == nil {
+		t.Errorf("expected an error acquiring a held lock but got none")
+	}
+	release()
+	if _, err := os.Stat(filepath.Join(dir, runLockFile)); !os.IsNotExist(err) {
+		t.Errorf("expected the lock file to be removed, but got: %v", err)
+	}
+
+	// locks of dead processes or with garbage are taken over
+	for _, content := range []string{"999999999\n", "garbage"} {
+		if err := ioutil.WriteFile(filepath.Join(dir, runLockFile), []byte(content), 0644); err != nil {
+			t.Fatalf("failed to write the lock: %v", err)
+		}
+		release, err := acquireRunLock(dir)
+		if err != nil {
+			t.Errorf("expected the stale lock %q to be taken over, but got: %v", content, err)
+			continue
+		}
+		release()
+	}
+}
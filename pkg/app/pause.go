@@ -0,0 +1,111 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package app
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"k8s.io/klog"
+
+	"sigs.k8s.io/kubetest2/pkg/types"
+)
+
+// defaultPauseDuration is how long --on-failure=pause holds the cluster open
+const defaultPauseDuration = 30 * time.Minute
+
+// optionsWithOnFailure is implemented by options configuring what happens when a phase fails
+type optionsWithOnFailure interface {
+	OnFailure() string
+}
+
+// pauseDuration returns how long to hold the cluster open when a phase fails,
+// 0 to tear it down right away
+func pauseDuration(opts types.Options) (time.Duration, error) {
+	oWithOnFailure, ok := opts.(optionsWithOnFailure)
+	if !ok {
+		return 0, nil
+	}
+	return parseOnFailure(oWithOnFailure.OnFailure())
+}
+
+// parseOnFailure parses the --on-failure value, down or pause[:duration]
+func parseOnFailure(value string) (time.Duration, error) {
+	switch {
+	case value == "" || value == "down":
+		return 0, nil
+	case value == "pause":
+		return defaultPauseDuration, nil
+	case strings.HasPrefix(value, "pause:"):
+		duration, err := time.ParseDuration(strings.TrimPrefix(value, "pause:"))
+		if err != nil || duration <= 0 {
+			return 0, fmt.Errorf("invalid --on-failure %q, the pause must be a positive duration e.g. pause:1h", value)
+		}
+		return duration, nil
+	default:
+		return 0, fmt.Errorf("invalid --on-failure %q, must be down or pause[:duration]", value)
+	}
+}
+
+// pauseOnFailure prints how to connect to the cluster of the failed run and
+// holds it open until enter is pressed or the duration elapses. The deployer
+// keeps its boskos heartbeat going in the meantime, so the leases are not lost.
+func pauseOnFailure(opts types.Options, d types.Deployer, failure error, duration time.Duration) {
+	var b strings.Builder
+	fmt.Fprintf(&b, "\nThe run failed: %v\n", failure)
+	fmt.Fprintf(&b, "Holding the cluster open for debugging for %s before tearing it down.\n", duration)
+	fmt.Fprintf(&b, "  run dir: %s\n", opts.RunDir())
+	if kubeconfig, err := deployerKubeconfig(d); err != nil {
+		klog.Warningf("Failed to get the kubeconfig of the cluster: %v", err)
+	} else if kubeconfig != "" {
+		fmt.Fprintf(&b, "  export KUBECONFIG=%s\n", kubeconfig)
+	}
+	if dWithDebugHints, ok := d.(types.DeployerWithDebugHints); ok {
+		for _, hint := range dWithDebugHints.DebugHints() {
+			fmt.Fprintf(&b, "  %s\n", hint)
+		}
+	}
+	b.WriteString("Press enter to tear down the cluster now.\n")
+	fmt.Fprint(os.Stderr, b.String())
+
+	if waitForEnter(os.Stdin, duration) {
+		klog.Info("Resuming the run")
+	} else {
+		klog.Infof("Resuming the run after pausing for %s", duration)
+	}
+}
+
+// waitForEnter returns true if a line is read from r before the timeout.
+// Without a terminal, e.g. in CI, only the timeout ends the wait.
+func waitForEnter(r io.Reader, timeout time.Duration) bool {
+	entered := make(chan struct{})
+	go func() {
+		if _, err := bufio.NewReader(r).ReadString('\n'); err == nil {
+			close(entered)
+		}
+	}()
+	select {
+	case <-entered:
+		return true
+	case <-time.After(timeout):
+		return false
+	}
+}
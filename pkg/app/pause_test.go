@@ -0,0 +1,63 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package app
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseOnFailure(t *testing.T) {
+	testCases := []struct {
+		value       string
+		expected    time.Duration
+		expectError bool
+	}{
+		{value: "", expected: 0},
+		{value: "down", expected: 0},
+		{value: "pause", expected: defaultPauseDuration},
+		{value: "pause:2h", expected: 2 * time.Hour},
+		{value: "pause:0s", expectError: true},
+		{value: "pause:forever", expectError: true},
+		{value: "wait", expectError: true},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.value, func(t *testing.T) {
+			t.Parallel()
+			actual, err := parseOnFailure(tc.value)
+			if (err != nil) != tc.expectError {
+				t.Fatalf("expected error: %v, but got: %v", tc.expectError, err)
+			}
+			if actual != tc.expected {
+				t.Errorf("expected %s, but got %s", tc.expected, actual)
+			}
+		})
+	}
+}
+
+func TestWaitForEnter(t *testing.T) {
+	if !waitForEnter(strings.NewReader("\n"), time.Minute) {
+		t.Errorf("expected the wait to end when enter is pressed")
+	}
+	// e.g. stdin is /dev/null
+	if waitForEnter(strings.NewReader(""), 10*time.Millisecond) {
+		t.Errorf("expected the wait to time out without a terminal")
+	}
+}
@@ -0,0 +1,51 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package app
+
+import (
+	"fmt"
+	"os"
+
+	"sigs.k8s.io/kubetest2/pkg/plugin"
+	"sigs.k8s.io/kubetest2/pkg/types"
+)
+
+// RunPlugin runs kubetest2 with the out of process deployer plugin at path and
+// the deployer args, it is the equivalent of Main for deployer plugins
+func RunPlugin(deployerName, path string, args []string) error {
+	client, err := plugin.Start(path)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return err
+	}
+	defer func() {
+		if err := client.Close(); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: plugin %s did not exit cleanly: %v\n", path, err)
+		}
+	}()
+
+	cmd := NewCommand(deployerName, client.NewDeployer)
+	cmd.SetArgs(args)
+	if err := cmd.Execute(); err != nil {
+		// only print the error if it's not an IncorrectUsage, see Main
+		if _, isUsage := err.(types.IncorrectUsage); !isUsage {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		}
+		return err
+	}
+	return nil
+}
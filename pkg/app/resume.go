@@ -0,0 +1,133 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package app
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"k8s.io/klog"
+
+	"sigs.k8s.io/kubetest2/pkg/events"
+	"sigs.k8s.io/kubetest2/pkg/types"
+)
+
+// runStateFile is the name of the file in the run dir recording the outcome
+// of the phases of the run, for resuming it with --resume
+const runStateFile = "run-state.json"
+
+const (
+	phaseSucceeded = "succeeded"
+	phaseFailed    = "failed"
+)
+
+// optionsWithResume is implemented by options configuring the resumption of a previous run
+type optionsWithResume interface {
+	Resume() bool
+}
+
+// runState records the outcome of the phases of a run across invocations
+type runState struct {
+	path     string
+	resuming bool
+	// mu guards Phases, recorded by the interrupt handler too
+	mu sync.Mutex
+	// Phases maps the phase name to phaseSucceeded or phaseFailed
+	Phases map[string]string `json:"phases"`
+}
+
+// loadRunState loads the state of the run from the run dir, if any
+func loadRunState(runDir string, resuming bool) (*runState, error) {
+	s := &runState{
+		path:     filepath.Join(runDir, runStateFile),
+		resuming: resuming,
+		Phases:   map[string]string{},
+	}
+	data, err := ioutil.ReadFile(s.path)
+	if os.IsNotExist(err) {
+		return s, nil
+	} else if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, s); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %v", s.path, err)
+	}
+	return s, nil
+}
+
+// resume checks that the run can be resumed and restores the state of the deployer
+func (s *runState) resume(runID string, d types.Deployer) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.Phases) == 0 {
+		return fmt.Errorf("cannot resume run %s: no state found at %s", runID, s.path)
+	}
+	if s.Phases["Down"] == phaseSucceeded {
+		return fmt.Errorf("cannot resume run %s: the cluster was already torn down", runID)
+	}
+	klog.Infof("Resuming run %s, phases of the previous invocations: %v", runID, s.Phases)
+	dWithState, ok := d.(types.DeployerWithState)
+	if !ok {
+		events.Warningf("The deployer does not persist its state, resuming relies on the flags matching the previous invocation")
+		return nil
+	}
+	if err := dWithState.RestoreState(); err != nil {
+		return fmt.Errorf("failed to restore the deployer state of run %s: %v", runID, err)
+	}
+	return nil
+}
+
+// skip returns true if the phase succeeded in a previous invocation of the resumed run
+func (s *runState) skip(phase string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.resuming && s.Phases[phase] == phaseSucceeded {
+		events.Progressf("Skipping %s, which succeeded in a previous invocation of the run", phase)
+		return true
+	}
+	return false
+}
+
+// record records the outcome of the phase
+func (s *runState) record(phase string, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.Phases[phase] = phaseSucceeded
+	if err != nil {
+		s.Phases[phase] = phaseFailed
+	}
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err == nil {
+		err = ioutil.WriteFile(s.path, data, 0644)
+	}
+	if err != nil {
+		klog.Warningf("Failed to record the state of the run: %v", err)
+	}
+}
+
+// recorded returns f, recording its outcome as the phase
+func (s *runState) recorded(phase string, f func() error) func() error {
+	return func() error {
+		err := f()
+		s.record(phase, err)
+		return err
+	}
+}
@@ -44,6 +44,7 @@ func FindDeployers() map[string]string {
 	nameToPath := make(map[string]string)
 	prefix := fmt.Sprintf("%s-", BinaryName)
 	testerPrefix := fmt.Sprintf("%s-tester-", BinaryName)
+	pluginPrefix := fmt.Sprintf("%s-plugin-", BinaryName)
 	// search every directory in PATH for kubetest2-* binaries
 	searchPaths := filepath.SplitList(os.Getenv("PATH"))
 	for _, dir := range searchPaths {
@@ -72,8 +73,8 @@ func FindDeployers() map[string]string {
 			if !strings.HasPrefix(fileName, prefix) {
 				continue
 			}
-			// ignore if it is a tester
-			if strings.HasPrefix(fileName, testerPrefix) {
+			// ignore if it is a tester or a deployer plugin
+			if strings.HasPrefix(fileName, testerPrefix) || strings.HasPrefix(fileName, pluginPrefix) {
 				continue
 			}
 			// convert the file name to a deployer name
@@ -0,0 +1,82 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package shim
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"sigs.k8s.io/kubetest2/pkg/doctor"
+	"sigs.k8s.io/kubetest2/pkg/process"
+	"sigs.k8s.io/kubetest2/pkg/types"
+)
+
+const doctorCommand = "doctor"
+
+// parseDoctorArgs returns the --deployer of the doctor command and the
+// remaining args, which are the flags of the deployer to check for
+func parseDoctorArgs(args []string) (string, []string, error) {
+	var deployerName string
+	var deployerArgs []string
+	for i := 0; i < len(args); i++ {
+		switch arg := args[i]; {
+		case strings.HasPrefix(arg, "--deployer="):
+			deployerName = strings.TrimPrefix(arg, "--deployer=")
+		case arg == "--deployer":
+			if i+1 == len(args) {
+				return "", nil, fmt.Errorf("--deployer requires a deployer name")
+			}
+			i++
+			deployerName = args[i]
+		default:
+			deployerArgs = append(deployerArgs, arg)
+		}
+	}
+	if deployerName == "" {
+		return "", nil, fmt.Errorf("%s doctor requires --deployer e.g. %s doctor --deployer=gke --project=PROJECT", BinaryName, BinaryName)
+	}
+	return deployerName, deployerArgs, nil
+}
+
+// runDoctor implements `kubetest2 doctor --deployer=NAME [flags]`, which
+// checks that the deployer is installed, then runs its preflight checks for
+// the flags of the run to check, printing how to fix the failed ones
+func runDoctor(cmd *cobra.Command, args []string) error {
+	deployerName, deployerArgs, err := parseDoctorArgs(args)
+	if err != nil {
+		cmd.Printf("Error: %v\n", err)
+		return err
+	}
+	var path string
+	if err := doctor.Run(cmd.OutOrStdout(), []types.Check{{
+		Name: fmt.Sprintf("the %s deployer is on PATH", deployerName),
+		Run: func() (string, error) {
+			path, err = FindDeployer(deployerName)
+			return path, err
+		},
+		Fix: fmt.Sprintf("install %s-%s to a directory of PATH, e.g. with go install sigs.k8s.io/kubetest2/%s-%s@latest for the deployers of kubetest2",
+			BinaryName, deployerName, BinaryName, deployerName),
+		Fatal: true,
+	}}); err != nil {
+		return err
+	}
+	env := append(os.Environ(), fmt.Sprintf("KUBETEST2_VERSION=kubetest2 version %s", GitTag))
+	return process.Exec(path, append([]string{"--" + types.DoctorFlag}, deployerArgs...), env)
+}
@@ -0,0 +1,66 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package shim
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseDoctorArgs(t *testing.T) {
+	testCases := []struct {
+		name             string
+		args             []string
+		expectedDeployer string
+		expectedArgs     []string
+		expectError      bool
+	}{
+		{
+			name:             "deployer only",
+			args:             []string{"--deployer=gke"},
+			expectedDeployer: "gke",
+		},
+		{
+			name:             "deployer flags",
+			args:             []string{"--project=p1", "--deployer", "gke", "--zone=us-central1-c"},
+			expectedDeployer: "gke",
+			expectedArgs:     []string{"--project=p1", "--zone=us-central1-c"},
+		},
+		{
+			name:        "no deployer",
+			args:        []string{"--project=p1"},
+			expectError: true,
+		},
+		{
+			name:        "missing deployer name",
+			args:        []string{"--deployer"},
+			expectError: true,
+		},
+	}
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			deployer, args, err := parseDoctorArgs(tc.args)
+			if (err != nil) != tc.expectError {
+				t.Fatalf("expected error: %v, but got: %v", tc.expectError, err)
+			}
+			if deployer != tc.expectedDeployer || !reflect.DeepEqual(args, tc.expectedArgs) {
+				t.Errorf("expected %q %v, got %q %v", tc.expectedDeployer, tc.expectedArgs, deployer, args)
+			}
+		})
+	}
+}
@@ -0,0 +1,104 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package shim
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/spf13/pflag"
+
+	"sigs.k8s.io/kubetest2/pkg/artifacts"
+	"sigs.k8s.io/kubetest2/pkg/history"
+)
+
+const (
+	historyCommand = "history"
+	showCommand    = "show"
+)
+
+// runHistory implements `kubetest2 history [--deployer=NAME] [--limit=N]`
+// and `kubetest2 show RUN-ID [OTHER-RUN-ID]` for the runs of the artifacts dir
+func runHistory(w io.Writer, command string, args []string) error {
+	flags := pflag.NewFlagSet(command, pflag.ContinueOnError)
+	var deployer *string
+	var limit *int
+	if command == historyCommand {
+		deployer = flags.String("deployer", "", "only list the runs of this deployer")
+		limit = flags.Int("limit", 20, "maximum number of runs to list, 0 for all of them")
+	}
+	if err := artifacts.BindFlags(flags); err != nil {
+		return err
+	}
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if command == showCommand {
+		return showRun(w, artifacts.BaseDir(), flags.Args())
+	}
+	return listHistory(w, artifacts.BaseDir(), *deployer, *limit)
+}
+
+// listHistory prints a table of the past runs of the dir, newest first
+func listHistory(w io.Writer, dir, deployer string, limit int) error {
+	runs, err := history.Load(dir)
+	if err != nil {
+		return err
+	}
+	if deployer != "" {
+		var filtered []history.Run
+		for _, r := range runs {
+			if r.Deployer == deployer {
+				filtered = append(filtered, r)
+			}
+		}
+		runs = filtered
+	}
+	if limit > 0 && len(runs) > limit {
+		runs = runs[len(runs)-limit:]
+	}
+	if len(runs) == 0 {
+		fmt.Fprintf(w, "No runs recorded in %s\n", dir)
+		return nil
+	}
+	return history.WriteList(w, runs)
+}
+
+// showRun prints the details of a past run of the dir, or with another run,
+// the flags that changed from the first run to the other one
+func showRun(w io.Writer, dir string, ids []string) error {
+	if len(ids) < 1 || len(ids) > 2 {
+		return fmt.Errorf("usage: %s %s RUN-ID [OTHER-RUN-ID], the run IDs can be shortened to a unique prefix", BinaryName, showCommand)
+	}
+	runs, err := history.Load(dir)
+	if err != nil {
+		return err
+	}
+	run, err := history.Find(runs, ids[0])
+	if err != nil {
+		return err
+	}
+	if len(ids) == 1 {
+		return history.WriteRun(w, run)
+	}
+	other, err := history.Find(runs, ids[1])
+	if err != nil {
+		return err
+	}
+	history.WriteFlagChanges(w, run.ID, other.ID, history.DiffFlags(run.Flags, other.Flags))
+	return nil
+}
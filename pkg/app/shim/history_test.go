@@ -0,0 +1,75 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package shim
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"sigs.k8s.io/kubetest2/pkg/history"
+)
+
+func TestHistoryCommands(t *testing.T) {
+	dir, err := ioutil.TempDir("", "history")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	for _, r := range []*history.Run{
+		{ID: "1111-aaaa", Deployer: "kind", Flags: map[string]string{"up": "true", "kube-feature-gates": "[A=true]"}, Started: time.Now(), Passed: true},
+		{ID: "2222-bbbb", Deployer: "kind", Flags: map[string]string{"up": "true"}, Started: time.Now(), Error: "Test failed"},
+		{ID: "3333-cccc", Deployer: "gke", Started: time.Now(), Passed: true},
+	} {
+		if err := history.Record(dir, r); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var out bytes.Buffer
+	if err := listHistory(&out, dir, "kind", 0); err != nil {
+		t.Fatalf("did not expect an error, but got: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 3 || !strings.HasPrefix(lines[1], "2222-bbbb") || !strings.HasPrefix(lines[2], "1111-aaaa") {
+		t.Errorf("expected the kind runs newest first, got:\n%s", out.String())
+	}
+
+	out.Reset()
+	if err := showRun(&out, dir, []string{"1111", "2222"}); err != nil {
+		t.Fatalf("did not expect an error, but got: %v", err)
+	}
+	expected := "--- 1111-aaaa\n+++ 2222-bbbb\n- --kube-feature-gates=[A=true]\n"
+	if out.String() != expected {
+		t.Errorf("expected output:\n%s\nbut got:\n%s", expected, out.String())
+	}
+
+	out.Reset()
+	if err := showRun(&out, dir, []string{"2222"}); err != nil {
+		t.Fatalf("did not expect an error, but got: %v", err)
+	}
+	if !strings.Contains(out.String(), "Error:     Test failed") {
+		t.Errorf("expected the error of the run, got:\n%s", out.String())
+	}
+
+	if err := showRun(&out, dir, nil); err == nil {
+		t.Errorf("expected an error without a run ID")
+	}
+}
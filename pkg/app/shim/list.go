@@ -0,0 +1,153 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package shim
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os/exec"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/pflag"
+
+	deployerplugin "sigs.k8s.io/kubetest2/pkg/plugin"
+	"sigs.k8s.io/kubetest2/pkg/types"
+)
+
+const (
+	listDeployersCommand = "list-deployers"
+	listTestersCommand   = "list-testers"
+)
+
+// describeTimeout bounds how long a plugin may take to describe itself
+const describeTimeout = 10 * time.Second
+
+// plugin is a deployer or tester binary found in PATH
+type plugin struct {
+	name        string
+	path        string
+	description *types.Description
+	err         error
+}
+
+// listPlugins implements list-deployers and list-testers, printing a table
+// of the binaries found in PATH with their version and description
+func listPlugins(w io.Writer, command string, args []string) error {
+	flags := pflag.NewFlagSet(command, pflag.ContinueOnError)
+	showFlags := flags.Bool("flags", false, "also list the flags supported by each plugin")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	var found map[string]string
+	if command == listDeployersCommand {
+		found = FindDeployers()
+	} else {
+		found = FindTesters()
+	}
+	plugins := make([]plugin, 0, len(found))
+	for name, path := range found {
+		description, err := describePlugin(path)
+		plugins = append(plugins, plugin{name: name, path: path, description: description, err: err})
+	}
+	if command == listDeployersCommand {
+		for name, path := range FindPlugins() {
+			if _, found := found[name]; found {
+				continue
+			}
+			description, err := describeDeployerPlugin(path)
+			plugins = append(plugins, plugin{name: name, path: path, description: description, err: err})
+		}
+	}
+	sort.Slice(plugins, func(i, j int) bool { return plugins[i].name < plugins[j].name })
+	return writePlugins(w, plugins, *showFlags)
+}
+
+// describePlugin runs the plugin with --describe-json
+func describePlugin(path string) (*types.Description, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), describeTimeout)
+	defer cancel()
+	out, err := exec.CommandContext(ctx, path, "--"+types.DescribeFlag).Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to run with --%s: %v", types.DescribeFlag, err)
+	}
+	description := &types.Description{}
+	if err := json.Unmarshal(out, description); err != nil {
+		return nil, fmt.Errorf("does not support --%s", types.DescribeFlag)
+	}
+	return description, nil
+}
+
+// describeDeployerPlugin returns the description of an out of process deployer plugin from the handshake
+func describeDeployerPlugin(path string) (*types.Description, error) {
+	client, err := deployerplugin.Start(path)
+	if err != nil {
+		return nil, err
+	}
+	handshake, err := client.Handshake()
+	_ = client.Close()
+	if err != nil {
+		return nil, err
+	}
+	return &handshake.Description, nil
+}
+
+func writePlugins(w io.Writer, plugins []plugin, showFlags bool) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "NAME\tVERSION\tDESCRIPTION\tPATH")
+	for _, p := range plugins {
+		version, description := "unknown", ""
+		if p.err != nil {
+			description = fmt.Sprintf("(%v)", p.err)
+		} else {
+			if p.description.Version != "" {
+				version = p.description.Version
+			}
+			description = p.description.Description
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", p.name, version, description, p.path)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	if !showFlags {
+		return nil
+	}
+	for _, p := range plugins {
+		if p.err != nil || len(p.description.Flags) == 0 {
+			continue
+		}
+		fmt.Fprintf(w, "\n%s flags:\n", p.name)
+		tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+		for _, f := range p.description.Flags {
+			usage := strings.SplitN(f.Usage, "\n", 2)[0]
+			if f.Default != "" {
+				usage = fmt.Sprintf("%s (default %s)", usage, f.Default)
+			}
+			fmt.Fprintf(tw, "  --%s\t%s\n", f.Name, usage)
+		}
+		if err := tw.Flush(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,63 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package shim
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"sigs.k8s.io/kubetest2/pkg/types"
+)
+
+func TestWritePlugins(t *testing.T) {
+	plugins := []plugin{
+		{
+			name: "gke",
+			path: "/bin/kubetest2-gke",
+			description: &types.Description{
+				Name:        "gke",
+				Version:     "v0.1.0",
+				Description: "creates GKE clusters with gcloud",
+				Flags: []types.FlagDescription{
+					{Name: "cluster-name", Usage: "Cluster names separated by comma."},
+					{Name: "num-nodes", Usage: "For use with gcloud commands.", Default: "3"},
+				},
+			},
+		},
+		{
+			name: "old",
+			path: "/bin/kubetest2-old",
+			err:  fmt.Errorf("does not support --describe-json"),
+		},
+	}
+	expected := `NAME  VERSION  DESCRIPTION                         PATH
+gke   v0.1.0   creates GKE clusters with gcloud    /bin/kubetest2-gke
+old   unknown  (does not support --describe-json)  /bin/kubetest2-old
+
+gke flags:
+  --cluster-name  Cluster names separated by comma.
+  --num-nodes     For use with gcloud commands. (default 3)
+`
+	var buf bytes.Buffer
+	if err := writePlugins(&buf, plugins, true); err != nil {
+		t.Fatalf("did not expect an error, but got: %v", err)
+	}
+	if buf.String() != expected {
+		t.Errorf("expected output:\n%s\nbut got:\n%s", expected, buf.String())
+	}
+}
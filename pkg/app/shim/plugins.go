@@ -0,0 +1,85 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package shim
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// RunPlugin runs kubetest2 with the out of process deployer plugin at path,
+// it is set to app.RunPlugin by the kubetest2 binary as pkg/app depends on this package
+var RunPlugin func(deployerName, path string, args []string) error
+
+// FindPlugin locates the binary implementing the named deployer plugin,
+// see sigs.k8s.io/kubetest2/pkg/plugin
+func FindPlugin(name string) (path string, err error) {
+	binary := fmt.Sprintf("%s-plugin-%s", BinaryName, name)
+	path, err = exec.LookPath(binary)
+	if err != nil {
+		return "", errors.Errorf("%#v not found in PATH, could not locate %#v deployer plugin", binary, name)
+	}
+	return path, err
+}
+
+// FindPlugins looks for all deployer plugins in PATH, returning a map of the
+// deployer name to the first matching binary found in path
+func FindPlugins() map[string]string {
+	nameToPath := make(map[string]string)
+	prefix := fmt.Sprintf("%s-plugin-", BinaryName)
+
+	// search every directory in PATH for kubetest2-plugin-* binaries
+	searchPaths := filepath.SplitList(os.Getenv("PATH"))
+	for _, dir := range searchPaths {
+		// mimic LookPath() for nicer results
+		if dir == "" {
+			// Unix shell semantics: path element "" means "."
+			dir = "."
+		}
+
+		// list all files in the directory, ignoring bad directories in PATH
+		files, err := ioutil.ReadDir(dir)
+		if os.IsNotExist(err) {
+			continue
+		}
+
+		for _, f := range files {
+			fileName := f.Name()
+			if f.IsDir() || !strings.HasPrefix(fileName, prefix) {
+				continue
+			}
+			name := strings.TrimPrefix(fileName, prefix)
+			// only keep the first result
+			if _, foundAlready := nameToPath[name]; foundAlready {
+				continue
+			}
+			// use FindPlugin / LookPath to ensure consistency
+			path, err := FindPlugin(name)
+			if err != nil {
+				continue
+			}
+			nameToPath[name] = path
+		}
+	}
+	return nameToPath
+}
@@ -93,10 +93,31 @@ func runE(cmd *cobra.Command, args []string) error {
 		}
 	}
 
+	// list the installed deployers or testers
+	if args[0] == listDeployersCommand || args[0] == listTestersCommand {
+		return listPlugins(cmd.OutOrStdout(), args[0], args[1:])
+	}
+	// browse the past runs
+	if args[0] == historyCommand || args[0] == showCommand {
+		if err := runHistory(cmd.OutOrStdout(), args[0], args[1:]); err != nil {
+			cmd.Printf("Error: %v\n", err)
+			return err
+		}
+		return nil
+	}
+	// check the environment of a deployer
+	if args[0] == doctorCommand {
+		return runDoctor(cmd, args[1:])
+	}
+
 	// otherwise find and execute the deployer with the remaining arguments
 	deployerName := args[0]
 	deployer, err := FindDeployer(deployerName)
 	if err != nil {
+		// fall back to an out of process deployer plugin
+		if path, pluginErr := FindPlugin(deployerName); pluginErr == nil && RunPlugin != nil {
+			return RunPlugin(deployerName, path, args[1:])
+		}
 		cmd.Printf("Error: could not find kubetest2 deployer %#v\n", deployerName)
 		cmd.Println()
 		usage(cmd)
@@ -119,6 +140,11 @@ func help(cmd *cobra.Command, args []string) {
 // the usage subset of help info, attempts to identify and list known deployers
 func usage(cmd *cobra.Command) {
 	deployers := FindDeployers()
+	for plugin, path := range FindPlugins() {
+		if _, found := deployers[plugin]; !found {
+			deployers[plugin] = path
+		}
+	}
 	cmd.Println("Usage:")
 	cmd.Printf("  %s [deployer] [flags]\n", BinaryName)
 	cmd.Println()
@@ -133,5 +159,11 @@ func usage(cmd *cobra.Command) {
 		cmd.Printf("  %s\n", tester)
 	}
 	cmd.Println()
+	cmd.Printf("Run %s %s or %s %s [--flags] for the versions, descriptions and flags of the deployers and testers\n",
+		BinaryName, listDeployersCommand, BinaryName, listTestersCommand)
+	cmd.Printf("Run %s %s to list the past runs, and %s %s [run-id] [other-run-id] to inspect one or diff the flags of two\n",
+		BinaryName, historyCommand, BinaryName, showCommand)
+	cmd.Printf("Run %s %s --deployer=[deployer] [--flags] to check that the deployer is set up for a run with the flags\n", BinaryName, doctorCommand)
+	cmd.Println()
 	cmd.Println("For more help, run kubetest2 [deployer] --help")
 }
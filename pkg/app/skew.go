@@ -0,0 +1,196 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package app
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"k8s.io/klog"
+
+	"sigs.k8s.io/kubetest2/pkg/events"
+	"sigs.k8s.io/kubetest2/pkg/metadata"
+	"sigs.k8s.io/kubetest2/pkg/types"
+)
+
+// skewAction is a kind of step of a version skew test
+type skewAction string
+
+// The supported actions
+const (
+	// skewTest runs the testers against the cluster
+	skewTest skewAction = "test"
+	// skewUpgradeControlPlane upgrades, or downgrades, the control plane to the version
+	skewUpgradeControlPlane skewAction = "upgrade-control-plane"
+	// skewUpgradeNodes upgrades, or downgrades, the nodes to the version
+	skewUpgradeNodes skewAction = "upgrade-nodes"
+)
+
+// skewStep is a step of the --skew-sequence file
+type skewStep struct {
+	Action  skewAction `json:"action"`
+	Version string     `json:"version,omitempty"`
+	// Name is the name of the step in the junit of the run, defaults to the action and the version
+	Name string `json:"name,omitempty"`
+}
+
+// title returns the name of the step in the junit of the run
+func (s skewStep) title() string {
+	if s.Name != "" {
+		return s.Name
+	}
+	switch s.Action {
+	case skewUpgradeControlPlane:
+		return fmt.Sprintf("UpgradeControlPlane (%s)", s.Version)
+	case skewUpgradeNodes:
+		return fmt.Sprintf("UpgradeNodes (%s)", s.Version)
+	default:
+		return "Test"
+	}
+}
+
+// optionsWithSkewSequence is implemented by options configuring a version skew test
+type optionsWithSkewSequence interface {
+	SkewSequence() string
+}
+
+// parseSkewSequence parses and validates the steps of a --skew-sequence file
+func parseSkewSequence(data []byte) ([]skewStep, error) {
+	var steps []skewStep
+	if err := json.Unmarshal(data, &steps); err != nil {
+		return nil, fmt.Errorf("failed to parse the skew sequence: %v", err)
+	}
+	tests := 0
+	for i, step := range steps {
+		switch step.Action {
+		case skewTest:
+			if step.Version != "" {
+				return nil, fmt.Errorf("skew step %d: the test action does not take a version", i+1)
+			}
+			tests++
+		case skewUpgradeControlPlane, skewUpgradeNodes:
+			if step.Version == "" {
+				return nil, fmt.Errorf("skew step %d: the %s action requires a version", i+1, step.Action)
+			}
+		default:
+			return nil, fmt.Errorf("skew step %d: unknown action %q, must be one of test, upgrade-control-plane or upgrade-nodes", i+1, step.Action)
+		}
+	}
+	if tests == 0 {
+		return nil, fmt.Errorf("the skew sequence has no test step")
+	}
+	return steps, nil
+}
+
+// skewSequence is a version skew test, run in place of the testers
+type skewSequence struct {
+	steps []skewStep
+	d     types.Deployer
+}
+
+// newSkewSequence returns the version skew test of --skew-sequence, nil if
+// unset. It is validated before the cluster is brought up so that a bad
+// sequence fails the run early.
+func newSkewSequence(opts types.Options, d types.Deployer) (*skewSequence, error) {
+	oWithSkewSequence, ok := opts.(optionsWithSkewSequence)
+	if !ok || oWithSkewSequence.SkewSequence() == "" {
+		return nil, nil
+	}
+	if !opts.ShouldTest() {
+		return nil, fmt.Errorf("--skew-sequence requires --test")
+	}
+	// restoring the etcd state of the old version would undo the upgrades
+	if oWithEtcdSnapshot, ok := opts.(optionsWithEtcdSnapshot); ok && oWithEtcdSnapshot.EtcdSnapshot() {
+		return nil, fmt.Errorf("--skew-sequence cannot be used with --etcd-snapshot")
+	}
+	data, err := ioutil.ReadFile(oWithSkewSequence.SkewSequence())
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the skew sequence: %v", err)
+	}
+	steps, err := parseSkewSequence(data)
+	if err != nil {
+		return nil, err
+	}
+	for _, step := range steps {
+		if step.Action == skewTest {
+			continue
+		}
+		if _, ok := d.(types.DeployerWithUpgrade); !ok {
+			return nil, fmt.Errorf("--skew-sequence with upgrade steps is not supported by the deployer")
+		}
+		break
+	}
+	return &skewSequence{steps: steps, d: d}, nil
+}
+
+// run runs the steps in order as Skew <N>: <step> steps of the junit of the
+// run. A failed test step does not stop the sequence unless --fail-fast is
+// set, but a failed upgrade does, as the later steps would test a cluster
+// in an unknown state.
+func (s *skewSequence) run(opts types.Options, allTesters []types.Tester, writer *metadata.Writer) error {
+	failFast := false
+	if oWithFailFast, ok := opts.(optionsWithFailFast); ok {
+		failFast = oWithFailFast.FailFast()
+	}
+	var failed []string
+	defer func() {
+		if err := metadata.Default().SetStrings("skew-steps-failed", failed); err != nil {
+			klog.Warningf("Failed to record the failed skew steps in the metadata: %v", err)
+		}
+	}()
+	for i, step := range s.steps {
+		name := fmt.Sprintf("Skew %d: %s", i+1, step.title())
+		events.Progressf("Running skew step %d of %d: %s", i+1, len(s.steps), step.title())
+		if step.Action == skewTest {
+			// each test step gets its own artifacts so that e.g. the junit of
+			// the tester is not overwritten by the next one
+			relDir := filepath.Join("skew", strconv.Itoa(i+1))
+			if err := os.MkdirAll(filepath.Join(opts.RunDir(), relDir), os.ModePerm); err != nil {
+				return err
+			}
+			if err := runTestersAs(opts, s.d, allTesters, writer, name, relDir); err != nil {
+				klog.Errorf("Skew step %d failed: %v", i+1, err)
+				failed = append(failed, strconv.Itoa(i+1))
+				if failFast {
+					klog.Infof("Not running the remaining skew steps with --fail-fast")
+					break
+				}
+			}
+			continue
+		}
+
+		dWithUpgrade := s.d.(types.DeployerWithUpgrade)
+		upgrade := dWithUpgrade.UpgradeNodes
+		if step.Action == skewUpgradeControlPlane {
+			upgrade = dWithUpgrade.UpgradeControlPlane
+		}
+		version := step.Version
+		if err := wrapStep(writer, name, func() error { return upgrade(version) }); err != nil {
+			failed = append(failed, strconv.Itoa(i+1))
+			return fmt.Errorf("skew step %d (%s) failed, not running the remaining steps: %v", i+1, step.title(), err)
+		}
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("%d of %d skew steps failed: %s", len(failed), len(s.steps), strings.Join(failed, ", "))
+	}
+	return nil
+}
@@ -0,0 +1,89 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package app
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseSkewSequence(t *testing.T) {
+	testCases := []struct {
+		name        string
+		sequence    string
+		expected    []skewStep
+		expectError bool
+	}{
+		{
+			name:     "upgrade",
+			sequence: `[{"action": "test"}, {"action": "upgrade-control-plane", "version": "1.21.0"}, {"action": "test", "name": "Test (1.21 control plane)"}]`,
+			expected: []skewStep{
+				{Action: skewTest},
+				{Action: skewUpgradeControlPlane, Version: "1.21.0"},
+				{Action: skewTest, Name: "Test (1.21 control plane)"},
+			},
+		},
+		{
+			name:        "no test",
+			sequence:    `[{"action": "upgrade-nodes", "version": "1.21.0"}]`,
+			expectError: true,
+		},
+		{
+			name:        "upgrade without version",
+			sequence:    `[{"action": "test"}, {"action": "upgrade-nodes"}]`,
+			expectError: true,
+		},
+		{
+			name:        "test with version",
+			sequence:    `[{"action": "test", "version": "1.21.0"}]`,
+			expectError: true,
+		},
+		{
+			name:        "unknown action",
+			sequence:    `[{"action": "test"}, {"action": "downgrade", "version": "1.20.0"}]`,
+			expectError: true,
+		},
+		{
+			name:        "invalid",
+			sequence:    `{"action": "test"}`,
+			expectError: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			actual, err := parseSkewSequence([]byte(tc.sequence))
+			if (err != nil) != tc.expectError {
+				t.Fatalf("expected error: %v, but got: %v", tc.expectError, err)
+			}
+			if !reflect.DeepEqual(actual, tc.expected) {
+				t.Errorf("expected %v, but got %v", tc.expected, actual)
+			}
+		})
+	}
+}
+
+func TestSkewStepTitle(t *testing.T) {
+	if title := (skewStep{Action: skewUpgradeNodes, Version: "1.21.0"}).title(); title != "UpgradeNodes (1.21.0)" {
+		t.Errorf("unexpected title %q", title)
+	}
+	if title := (skewStep{Action: skewTest, Name: "Test (skewed nodes)"}).title(); title != "Test (skewed nodes)" {
+		t.Errorf("unexpected title %q", title)
+	}
+}
@@ -0,0 +1,83 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package app
+
+import (
+	"fmt"
+
+	"sigs.k8s.io/kubetest2/pkg/metadata"
+	"sigs.k8s.io/kubetest2/pkg/types"
+)
+
+// etcdSnapshotName is the name of the snapshot of the cluster before the first test run
+const etcdSnapshotName = "pristine"
+
+// optionsWithEtcdSnapshot is implemented by options configuring the etcd snapshots between test runs
+type optionsWithEtcdSnapshot interface {
+	EtcdSnapshot() bool
+}
+
+// snapshotter snapshots etcd before the first test run and restores the
+// snapshot before every later one, including the iterations and the retries
+type snapshotter struct {
+	d      types.DeployerWithSnapshot
+	writer *metadata.Writer
+	runs   int
+}
+
+// newSnapshotter returns the snapshotter of --etcd-snapshot, nil if disabled
+func newSnapshotter(opts types.Options, d types.Deployer, writer *metadata.Writer) (*snapshotter, error) {
+	if oWithEtcdSnapshot, ok := opts.(optionsWithEtcdSnapshot); !ok || !oWithEtcdSnapshot.EtcdSnapshot() {
+		return nil, nil
+	}
+	dWithSnapshot, err := snapshotDeployer(d)
+	if err != nil {
+		return nil, err
+	}
+	return &snapshotter{d: dWithSnapshot, writer: writer}, nil
+}
+
+// checkEtcdSnapshot fails if --etcd-snapshot is set for a deployer not
+// supporting it, before a cluster is brought up for nothing
+func checkEtcdSnapshot(opts types.Options, d types.Deployer) error {
+	if oWithEtcdSnapshot, ok := opts.(optionsWithEtcdSnapshot); !ok || !oWithEtcdSnapshot.EtcdSnapshot() {
+		return nil
+	}
+	_, err := snapshotDeployer(d)
+	return err
+}
+
+func snapshotDeployer(d types.Deployer) (types.DeployerWithSnapshot, error) {
+	dWithSnapshot, ok := d.(types.DeployerWithSnapshot)
+	if !ok {
+		// e.g. gce, whose etcd is not a static pod of a single control plane node
+		return nil, fmt.Errorf("--etcd-snapshot is not supported by the deployer, only by e.g. kind and minikube")
+	}
+	return dWithSnapshot, nil
+}
+
+// beforeTest is called before every run of a tester
+func (s *snapshotter) beforeTest() error {
+	if s == nil {
+		return nil
+	}
+	s.runs++
+	if s.runs == 1 {
+		return wrapStep(s.writer, "EtcdSnapshot", func() error { return s.d.Snapshot(etcdSnapshotName) })
+	}
+	return wrapStep(s.writer, "EtcdRestore", func() error { return s.d.Restore(etcdSnapshotName) })
+}
@@ -0,0 +1,72 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package app
+
+import (
+	"time"
+
+	"sigs.k8s.io/kubetest2/pkg/artifacts"
+	"sigs.k8s.io/kubetest2/pkg/events"
+	"sigs.k8s.io/kubetest2/pkg/types"
+)
+
+// optionsWithArtifactsStream is implemented by options configuring the
+// streaming of the artifacts during the test phase
+type optionsWithArtifactsStream interface {
+	ArtifactsStreamTo() string
+	ArtifactsStreamInterval() time.Duration
+	ArtifactsStreamPatterns() []string
+}
+
+// newArtifactsStreamer returns the streamer of the run dir to
+// --artifacts-stream-to, nil if unset. The destination is validated before
+// the cluster is brought up so that a typo fails the run early.
+func newArtifactsStreamer(opts types.Options) (*artifacts.Streamer, error) {
+	oWithStream, ok := opts.(optionsWithArtifactsStream)
+	if !ok || oWithStream.ArtifactsStreamTo() == "" {
+		return nil, nil
+	}
+	if err := artifacts.ValidateDestination(oWithStream.ArtifactsStreamTo()); err != nil {
+		return nil, err
+	}
+	return &artifacts.Streamer{
+		Dir: opts.RunDir(),
+		// the layout of the --artifacts dir, which holds the run dirs
+		Destination: oWithStream.ArtifactsStreamTo() + "/" + opts.RunID(),
+		Interval:    oWithStream.ArtifactsStreamInterval(),
+		Patterns:    oWithStream.ArtifactsStreamPatterns(),
+	}, nil
+}
+
+// startArtifactsStream streams the artifacts until the returned func is
+// called, which uploads the remaining changes. Failing to stream the
+// artifacts is logged but does not fail the run.
+func startArtifactsStream(streamer *artifacts.Streamer) func() {
+	if streamer == nil {
+		return func() {}
+	}
+	if err := streamer.Start(); err != nil {
+		events.Warningf("Not streaming the artifacts: %v", err)
+		return func() {}
+	}
+	events.Progressf("Streaming the artifacts to %s every %s", streamer.Destination, streamer.Interval)
+	return func() {
+		if err := streamer.Stop(); err != nil {
+			events.Warningf("Failed to stream the artifacts: %v", err)
+		}
+	}
+}
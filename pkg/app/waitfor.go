@@ -0,0 +1,59 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package app
+
+import (
+	"time"
+
+	"sigs.k8s.io/kubetest2/pkg/readiness"
+	"sigs.k8s.io/kubetest2/pkg/types"
+)
+
+// optionsWithWaitFor is implemented by options configuring the readiness gate after up
+type optionsWithWaitFor interface {
+	WaitFor() []string
+	WaitForTimeout() time.Duration
+}
+
+// newReadinessGate returns the readiness gate of --wait-for, nil if unset.
+// The probes are parsed before the cluster is brought up so that a typo
+// fails the run early.
+func newReadinessGate(opts types.Options) (*readiness.Gate, error) {
+	oWithWaitFor, ok := opts.(optionsWithWaitFor)
+	if !ok || len(oWithWaitFor.WaitFor()) == 0 {
+		return nil, nil
+	}
+	gate := &readiness.Gate{Timeout: oWithWaitFor.WaitForTimeout()}
+	for _, value := range oWithWaitFor.WaitFor() {
+		probe, err := readiness.ParseProbe(value)
+		if err != nil {
+			return nil, err
+		}
+		gate.Probes = append(gate.Probes, probe)
+	}
+	return gate, nil
+}
+
+// waitForReadiness waits for the probes of the gate in the cluster of the deployer
+func waitForReadiness(gate *readiness.Gate, d types.Deployer) error {
+	kubeconfig, err := deployerKubeconfig(d)
+	if err != nil {
+		return err
+	}
+	gate.Kubeconfig = kubeconfig
+	return gate.Wait()
+}
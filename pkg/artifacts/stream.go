@@ -0,0 +1,312 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package artifacts
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"k8s.io/klog"
+
+	"sigs.k8s.io/kubetest2/pkg/exec"
+)
+
+// DefaultStreamInterval is how often the artifacts are streamed by default
+const DefaultStreamInterval = 5 * time.Minute
+
+// DefaultStreamPatterns are the names of the files streamed by default, the
+// logs and junit files that tell what a run killed midway got to
+var DefaultStreamPatterns = []string{"*.log", "*.txt", "junit*.xml"}
+
+const (
+	// tailSize is the size of the end of the last upload of a file, compared
+	// to tell a file that was appended to from one that was rewritten
+	tailSize = 1024
+	// maxAppends bounds the appends to an object, GCS composite objects
+	// are limited to 1024 components
+	maxAppends = 512
+	// appendSuffix is the suffix of the temporary objects of the appends
+	appendSuffix = ".kubetest2-append"
+)
+
+// Streamer uploads the files of a directory to a GCS or S3 location
+// periodically while they are being written, so that a run killed by the
+// infrastructure midway still leaves usable artifacts.
+//
+// Only the files that changed since their last upload are uploaded. The new
+// bytes of files that were only appended to, e.g. logs, are appended to their
+// GCS objects with gsutil compose instead of uploading them whole again. The
+// whole files are uploaded with gsutil, which resumes interrupted uploads of
+// large files, or the aws cli, which uploads large files in retried parts.
+// A failed upload is retried at the next sync.
+type Streamer struct {
+	// Dir is the local directory to stream
+	Dir string
+	// Destination is the gs://BUCKET/PATH or s3://BUCKET/PATH to stream to
+	Destination string
+	// Interval is how often the changes are uploaded
+	Interval time.Duration
+	// Patterns are the names of the files to stream, DefaultStreamPatterns if empty
+	Patterns []string
+
+	// run runs a gsutil or aws command, with stdin if not nil
+	run func(stdin io.Reader, name string, args ...string) error
+
+	mu       sync.Mutex
+	uploaded map[string]*upload
+	stop     chan struct{}
+	done     chan struct{}
+}
+
+// upload is the state of a file as of its last upload
+type upload struct {
+	size    int64
+	modTime time.Time
+	// tail is the end of the uploaded content, nil if it is not known as
+	// the file changed while being uploaded
+	tail    []byte
+	appends int
+}
+
+// ValidateDestination checks that the destination is a gs:// or s3:// location
+func ValidateDestination(destination string) error {
+	for _, scheme := range []string{"gs://", "s3://"} {
+		if strings.HasPrefix(destination, scheme) && strings.TrimPrefix(destination, scheme) != "" {
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid artifacts stream destination %q, must be gs://BUCKET[/PATH] or s3://BUCKET[/PATH]", destination)
+}
+
+// Start uploads the changes every interval until Stop is called
+func (s *Streamer) Start() error {
+	if err := ValidateDestination(s.Destination); err != nil {
+		return err
+	}
+	if s.Interval <= 0 {
+		return fmt.Errorf("the artifacts stream interval must be positive, got %s", s.Interval)
+	}
+	s.stop = make(chan struct{})
+	s.done = make(chan struct{})
+	go func() {
+		defer close(s.done)
+		ticker := time.NewTicker(s.Interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if err := s.Sync(); err != nil {
+					klog.Warningf("Failed to stream the artifacts: %v", err)
+				}
+			case <-s.stop:
+				return
+			}
+		}
+	}()
+	return nil
+}
+
+// Stop stops the periodic uploads and uploads the remaining changes
+func (s *Streamer) Stop() error {
+	if s.stop != nil {
+		close(s.stop)
+		<-s.done
+		s.stop = nil
+	}
+	return s.Sync()
+}
+
+// Sync uploads the files that changed since their last upload, it carries
+// on past the files that fail to upload and returns the first error
+func (s *Streamer) Sync() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.uploaded == nil {
+		s.uploaded = map[string]*upload{}
+	}
+	var firstErr error
+	failed, total := 0, 0
+	err := filepath.Walk(s.Dir, func(file string, info os.FileInfo, err error) error {
+		if err != nil {
+			// e.g. removed since it was listed
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if info.IsDir() || !info.Mode().IsRegular() || !s.matches(info.Name()) {
+			return nil
+		}
+		rel, err := filepath.Rel(s.Dir, file)
+		if err != nil {
+			return err
+		}
+		previous := s.uploaded[rel]
+		if previous != nil && previous.size == info.Size() && previous.modTime.Equal(info.ModTime()) {
+			return nil
+		}
+		total++
+		current, err := s.uploadFile(file, s.remotePath(rel), info, previous)
+		if err != nil {
+			failed++
+			if firstErr == nil {
+				firstErr = fmt.Errorf("failed to upload %s: %v", rel, err)
+			}
+			return nil
+		}
+		s.uploaded[rel] = current
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to list the artifacts: %v", err)
+	}
+	if firstErr != nil {
+		return fmt.Errorf("%d of %d changed files not streamed, %v", failed, total, firstErr)
+	}
+	if total > 0 {
+		klog.V(2).Infof("Streamed %d changed files to %s", total, s.Destination)
+	}
+	return nil
+}
+
+// matches returns whether the file name matches the patterns to stream
+func (s *Streamer) matches(name string) bool {
+	patterns := s.Patterns
+	if len(patterns) == 0 {
+		patterns = DefaultStreamPatterns
+	}
+	for _, pattern := range patterns {
+		if matched, _ := filepath.Match(pattern, name); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// remotePath returns the location of the file relative to the dir
+func (s *Streamer) remotePath(rel string) string {
+	return strings.TrimSuffix(s.Destination, "/") + "/" + path.Clean(filepath.ToSlash(rel))
+}
+
+func (s *Streamer) isGCS() bool {
+	return strings.HasPrefix(s.Destination, "gs://")
+}
+
+// uploadFile uploads the changes of the file since the previous upload, nil if
+// it was never uploaded, and returns the state of the file it uploaded
+func (s *Streamer) uploadFile(file, remote string, info os.FileInfo, previous *upload) (*upload, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	size := info.Size()
+	current := &upload{size: size, modTime: info.ModTime()}
+
+	if offset, ok := appendOffset(f, previous, size); ok && s.isGCS() {
+		if err := s.append(io.NewSectionReader(f, offset, size-offset), remote); err != nil {
+			return nil, err
+		}
+		current.appends = previous.appends + 1
+	} else if err := s.uploadWhole(file, remote); err != nil {
+		return nil, err
+	}
+
+	// the end of the file is only the end of the upload if it did not
+	// change in the meantime, else the next upload is a whole one
+	if after, err := f.Stat(); err == nil && after.Size() == size && after.ModTime().Equal(info.ModTime()) {
+		current.tail, err = readTail(f, size)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return current, nil
+}
+
+// appendOffset returns the offset of the bytes appended to the file since
+// the previous upload, false if the file was not only appended to
+func appendOffset(f io.ReaderAt, previous *upload, size int64) (int64, bool) {
+	if previous == nil || previous.tail == nil || previous.appends >= maxAppends || size <= previous.size {
+		return 0, false
+	}
+	tail := make([]byte, len(previous.tail))
+	if _, err := f.ReadAt(tail, previous.size-int64(len(tail))); err != nil {
+		return 0, false
+	}
+	if !bytes.Equal(tail, previous.tail) {
+		return 0, false
+	}
+	return previous.size, true
+}
+
+// readTail returns the end of the first size bytes of the file
+func readTail(f io.ReaderAt, size int64) ([]byte, error) {
+	n := int64(tailSize)
+	if size < n {
+		n = size
+	}
+	tail := make([]byte, n)
+	if _, err := f.ReadAt(tail, size-n); err != nil {
+		return nil, err
+	}
+	return tail, nil
+}
+
+// uploadWhole uploads the whole file
+func (s *Streamer) uploadWhole(file, remote string) error {
+	if s.isGCS() {
+		return s.runCommand(nil, "gsutil", "-q", "cp", file, remote)
+	}
+	return s.runCommand(nil, "aws", "s3", "cp", "--only-show-errors", file, remote)
+}
+
+// append appends the bytes to the GCS object, composing it with a temporary
+// object holding them
+func (s *Streamer) append(r io.Reader, remote string) error {
+	part := remote + appendSuffix
+	if err := s.runCommand(r, "gsutil", "-q", "cp", "-", part); err != nil {
+		return err
+	}
+	if err := s.runCommand(nil, "gsutil", "-q", "compose", remote, part, remote); err != nil {
+		return err
+	}
+	return s.runCommand(nil, "gsutil", "-q", "rm", part)
+}
+
+func (s *Streamer) runCommand(stdin io.Reader, name string, args ...string) error {
+	if s.run != nil {
+		return s.run(stdin, name, args...)
+	}
+	cmd := exec.Command(name, args...)
+	if stdin != nil {
+		cmd.SetStdin(stdin)
+	}
+	var stderr bytes.Buffer
+	exec.SetOutput(cmd, ioutil.Discard, &stderr)
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("%s %s failed: %v: %s", name, strings.Join(args, " "), err, strings.TrimSpace(stderr.String()))
+	}
+	return nil
+}
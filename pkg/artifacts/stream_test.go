@@ -0,0 +1,150 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package artifacts
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestValidateDestination(t *testing.T) {
+	for _, destination := range []string{"gs://bucket", "gs://bucket/logs/run", "s3://bucket/logs"} {
+		if err := ValidateDestination(destination); err != nil {
+			t.Errorf("did not expect an error for %q, but got: %v", destination, err)
+		}
+	}
+	for _, destination := range []string{"", "gs://", "/tmp/logs", "https://bucket"} {
+		if err := ValidateDestination(destination); err == nil {
+			t.Errorf("expected an error for %q", destination)
+		}
+	}
+}
+
+func TestMatches(t *testing.T) {
+	s := &Streamer{}
+	for name, expected := range map[string]bool{
+		"build-log.txt":     true,
+		"commands.log":      true,
+		"junit_runner.xml":  true,
+		"metadata.json":     false,
+		"kubernetes.tar.gz": false,
+	} {
+		if actual := s.matches(name); actual != expected {
+			t.Errorf("expected %q to match: %v, got %v", name, expected, actual)
+		}
+	}
+	s.Patterns = []string{"*.json"}
+	if !s.matches("metadata.json") || s.matches("commands.log") {
+		t.Errorf("expected only the files matching --artifacts-stream-patterns to match")
+	}
+}
+
+// fakeCommands records the commands of a streamer and what they read from stdin
+type fakeCommands struct {
+	commands []string
+	stdin    []string
+}
+
+func (f *fakeCommands) run(stdin io.Reader, name string, args ...string) error {
+	f.commands = append(f.commands, name+" "+strings.Join(args, " "))
+	if stdin != nil {
+		data, err := ioutil.ReadAll(stdin)
+		if err != nil {
+			return err
+		}
+		f.stdin = append(f.stdin, string(data))
+	}
+	return nil
+}
+
+func (f *fakeCommands) reset() {
+	f.commands, f.stdin = nil, nil
+}
+
+func TestSync(t *testing.T) {
+	dir, err := ioutil.TempDir("", "stream")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	logFile := filepath.Join(dir, "tester", "build-log.txt")
+	if err := os.MkdirAll(filepath.Dir(logFile), os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(logFile, []byte("first\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "kubernetes.tar.gz"), []byte("binary"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	fake := &fakeCommands{}
+	s := &Streamer{Dir: dir, Destination: "gs://bucket/run/", run: fake.run}
+	remote := "gs://bucket/run/tester/build-log.txt"
+
+	sync := func(expected []string, expectedStdin []string) {
+		t.Helper()
+		fake.reset()
+		if err := s.Sync(); err != nil {
+			t.Fatalf("did not expect an error, but got: %v", err)
+		}
+		if !reflect.DeepEqual(fake.commands, expected) {
+			t.Errorf("expected commands %v, got %v", expected, fake.commands)
+		}
+		if !reflect.DeepEqual(fake.stdin, expectedStdin) {
+			t.Errorf("expected stdin %q, got %q", expectedStdin, fake.stdin)
+		}
+	}
+
+	// new files are uploaded whole
+	sync([]string{"gsutil -q cp " + logFile + " " + remote}, nil)
+	// unchanged files are not uploaded again
+	sync(nil, nil)
+
+	// only the appended bytes of a file are uploaded
+	f, err := os.OpenFile(logFile, os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.WriteString("second\n"); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+	sync([]string{
+		"gsutil -q cp - " + remote + appendSuffix,
+		"gsutil -q compose " + remote + " " + remote + appendSuffix + " " + remote,
+		"gsutil -q rm " + remote + appendSuffix,
+	}, []string{"second\n"})
+
+	// a rewritten file is uploaded whole again
+	if err := ioutil.WriteFile(logFile, []byte("rewritten and longer\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	sync([]string{"gsutil -q cp " + logFile + " " + remote}, nil)
+
+	// S3 has no appends
+	s = &Streamer{Dir: dir, Destination: "s3://bucket/run", run: fake.run}
+	sync([]string{"aws s3 cp --only-show-errors " + logFile + " s3://bucket/run/tester/build-log.txt"}, nil)
+	if err := ioutil.WriteFile(logFile, []byte("rewritten and longer\nappended\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	sync([]string{"aws s3 cp --only-show-errors " + logFile + " s3://bucket/run/tester/build-log.txt"}, nil)
+}
@@ -25,13 +25,20 @@ import (
 	"k8s.io/klog"
 	"sigs.k8s.io/boskos/client"
 	"sigs.k8s.io/boskos/common"
+
+	"sigs.k8s.io/kubetest2/pkg/secrets"
 )
 
 // const (for the run) owner string for consistency between up and down
 var boskosOwner = os.Getenv("JOB_NAME") + "-kubetest2"
 
 // NewClient creates a boskos client for kubetest2 deployers.
+// The location may reference a secret holding the URL of the server, e.g. env:BOSKOS_URL.
 func NewClient(boskosLocation string) (*client.Client, error) {
+	boskosLocation, err := secrets.Resolve(boskosLocation)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve the boskos location: %v", err)
+	}
 	boskos, err := client.NewClient(
 		boskosOwner,
 		boskosLocation,
@@ -70,6 +77,29 @@ func Acquire(boskosClient *client.Client, resourceType string, timeout, heartbea
 	return boskosResource, nil
 }
 
+// Resume reacquires the busy resources leased by a previous invocation of the run,
+// which is possible as the owner of the lease is the same for that job, and starts
+// a heartbeat goroutine to keep them reserved.
+func Resume(boskosClient *client.Client, resourceNames []string, heartbeatInterval time.Duration, heartbeatClose chan struct{}) error {
+	resources, err := boskosClient.AcquireByState("busy", "busy", resourceNames)
+	if err != nil {
+		return fmt.Errorf("failed to reacquire %v from boskos: %s", resourceNames, err)
+	}
+
+	if heartbeatInterval != 0 {
+		for i := range resources {
+			startBoskosHeartbeat(
+				boskosClient,
+				&resources[i],
+				heartbeatInterval,
+				heartbeatClose,
+			)
+		}
+	}
+
+	return nil
+}
+
 // startBoskosHeartbeat starts a goroutine that sends periodic updates to boskos
 // about the provided resource until the channel is closed. This prevents
 // reaper from taking the resource from the deployer while it is still in use.
@@ -0,0 +1,434 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package build
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"k8s.io/klog"
+
+	"sigs.k8s.io/kubetest2/pkg/exec"
+	"sigs.k8s.io/kubetest2/pkg/metadata"
+)
+
+const (
+	// attestationsDir is the directory of the run dir holding the SBOMs,
+	// signatures and provenance of the attested artifacts
+	attestationsDir = "attestations"
+	// attestationsFile lists the attested artifacts, in the attestations dir
+	attestationsFile = "attestations.json"
+
+	inTotoStatementType = "https://in-toto.io/Statement/v0.1"
+	slsaProvenanceType  = "https://slsa.dev/provenance/v0.2"
+	provenanceBuilderID = "https://sigs.k8s.io/kubetest2"
+	provenanceBuildType = "https://sigs.k8s.io/kubetest2/build@v1"
+)
+
+// Attester wraps a Builder and a Stager to attest the built artifacts, so
+// that the tested artifacts can be traced back to their sources.
+//
+// Once built, and once staged for the images pushed by the stager, it
+// generates an SPDX SBOM of every artifact with syft, signs the release tars
+// and the images with cosign, attaching the SBOMs and a SLSA provenance to
+// the images, and records the digests in RunDir/attestations and the metadata.
+type Attester struct {
+	Builder
+	Stager
+	RepoRoot string
+	RunDir   string
+	// Key is the cosign key reference to sign with e.g. cosign.key or
+	// gcpkms://..., keyless signing is used if empty
+	Key string
+	// Strategy is the build strategy, recorded in the provenance
+	Strategy string
+
+	started  time.Time
+	finished time.Time
+	version  string
+	attested []Attestation
+}
+
+var _ Builder = &Attester{}
+var _ Stager = &Attester{}
+
+// Attestation is an attested artifact, the paths are relative to the run dir
+type Attestation struct {
+	// Name is the file name of a release tar, or the reference of an image
+	Name string `json:"name"`
+	// Digest is the sha256 digest of the release tar or the image manifest
+	Digest      string `json:"digest"`
+	SBOM        string `json:"sbom,omitempty"`
+	Signature   string `json:"signature,omitempty"`
+	Certificate string `json:"certificate,omitempty"`
+	Provenance  string `json:"provenance,omitempty"`
+}
+
+// Build builds, then attests the release tars and the images pushed by the
+// builder e.g. with ko. The release tars are attested once built rather than
+// once staged as they are not always staged.
+func (a *Attester) Build() (string, error) {
+	a.started = time.Now()
+	version, err := a.Builder.Build()
+	if err != nil {
+		return "", err
+	}
+	a.finished = time.Now()
+	a.version = version
+
+	tars, err := filepath.Glob(filepath.Join(a.RepoRoot, "_output", "release-tars", "*.tar.gz"))
+	if err != nil {
+		return "", err
+	}
+	if err := a.attest("build", tars, builtImages(a.Builder)); err != nil {
+		return "", fmt.Errorf("failed to attest the build: %v", err)
+	}
+	return version, nil
+}
+
+// Stage stages, then attests the images pushed by the stager
+func (a *Attester) Stage(version string) error {
+	if err := a.Stager.Stage(version); err != nil {
+		return err
+	}
+	if err := a.attest("stage", nil, stagedImages(a.Stager)); err != nil {
+		return fmt.Errorf("failed to attest the staged images: %v", err)
+	}
+	return nil
+}
+
+// builtImages returns the references of the images pushed by the builder
+func builtImages(b Builder) []string {
+	if c, ok := b.(*Cache); ok {
+		b = c.Builder
+	}
+	if i, ok := b.(interface{ Images() []string }); ok {
+		return i.Images()
+	}
+	return nil
+}
+
+// stagedImages returns the references of the images pushed by the stager
+func stagedImages(s Stager) []string {
+	if c, ok := s.(*Cache); ok {
+		s = c.Stager
+	}
+	if i, ok := s.(interface{ StagedImages() []string }); ok {
+		return i.StagedImages()
+	}
+	return nil
+}
+
+// attest attests the release tars and the images of the phase, recording
+// them with the artifacts attested so far
+func (a *Attester) attest(phase string, tars, images []string) error {
+	if len(tars) == 0 && len(images) == 0 {
+		return nil
+	}
+	dir := filepath.Join(a.RunDir, attestationsDir)
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		return err
+	}
+
+	var attestations []Attestation
+	for _, tar := range tars {
+		digest, err := fileDigest(tar)
+		if err != nil {
+			return err
+		}
+		attestations = append(attestations, Attestation{Name: filepath.Base(tar), Digest: digest})
+	}
+	for _, image := range images {
+		name, digest := splitDigest(image)
+		if digest == "" {
+			lines, err := exec.OutputLines(exec.Command("crane", "digest", image))
+			if err != nil || len(lines) == 0 {
+				return fmt.Errorf("failed to get the digest of image %s: %v", image, err)
+			}
+			digest = lines[0]
+		}
+		attestations = append(attestations, Attestation{Name: name + "@" + digest, Digest: digest})
+	}
+
+	// a single provenance has all the artifacts of the phase as subjects
+	predicatePath := filepath.Join(dir, phase+"-provenance.json")
+	if err := a.writeProvenance(predicatePath, attestations); err != nil {
+		return err
+	}
+	statementPath := filepath.Join(dir, phase+"-provenance.intoto.json")
+	if err := a.signBlob(statementPath, statementPath+".sig"); err != nil {
+		return err
+	}
+
+	for i := range attestations {
+		at := &attestations[i]
+		base := artifactFileName(at.Name)
+		sbom := filepath.Join(dir, base+".spdx.json")
+		if i < len(tars) {
+			if err := generateSBOM("file:"+tars[i], sbom); err != nil {
+				return err
+			}
+			if err := a.signBlob(tars[i], filepath.Join(dir, base+".sig")); err != nil {
+				return err
+			}
+			at.Signature = filepath.Join(attestationsDir, base+".sig")
+			if a.Key == "" {
+				at.Certificate = filepath.Join(attestationsDir, base+".pem")
+			}
+			at.Provenance = filepath.Join(attestationsDir, filepath.Base(statementPath))
+		} else {
+			if err := generateSBOM("registry:"+at.Name, sbom); err != nil {
+				return err
+			}
+			if err := a.cosign("sign", at.Name); err != nil {
+				return err
+			}
+			if err := a.cosign("attest", "--type=spdxjson", "--predicate="+sbom, at.Name); err != nil {
+				return err
+			}
+			if err := a.cosign("attest", "--type=slsaprovenance", "--predicate="+predicatePath, at.Name); err != nil {
+				return err
+			}
+			at.Provenance = filepath.Join(attestationsDir, filepath.Base(predicatePath))
+		}
+		at.SBOM = filepath.Join(attestationsDir, base+".spdx.json")
+		klog.V(0).Infof("Attested %s (%s)", at.Name, at.Digest)
+	}
+	a.attested = append(a.attested, attestations...)
+	return a.record(dir)
+}
+
+// record writes the attested artifacts to the attestations file and their
+// digests to the metadata
+func (a *Attester) record(dir string) error {
+	data, err := json.MarshalIndent(a.attested, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, attestationsFile), data, 0644); err != nil {
+		return fmt.Errorf("failed to write the attestations: %v", err)
+	}
+	var artifacts []string
+	for _, at := range a.attested {
+		if strings.Contains(at.Name, "@") {
+			artifacts = append(artifacts, at.Name)
+		} else {
+			artifacts = append(artifacts, at.Name+"@"+at.Digest)
+		}
+	}
+	if err := metadata.Default().SetAll(map[string]string{
+		metadata.AttestedArtifactsKey: strings.Join(artifacts, ","),
+		metadata.AttestationsKey:      filepath.Join(attestationsDir, attestationsFile),
+	}); err != nil {
+		klog.Warningf("Failed to record the attestations in the metadata: %v", err)
+	}
+	return nil
+}
+
+// fileDigest returns the sha256 digest of the file as sha256:<hex>
+func fileDigest(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", fmt.Errorf("failed to compute the digest of %s: %v", path, err)
+	}
+	return "sha256:" + hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// splitDigest splits the image reference into its name and its digest,
+// empty if the reference is not by digest
+func splitDigest(ref string) (string, string) {
+	if i := strings.LastIndex(ref, "@"); i >= 0 {
+		return ref[:i], ref[i+1:]
+	}
+	return ref, ""
+}
+
+// artifactFileName returns the base name of the attestation files of the
+// artifact e.g. gcr.io_p_kube-apiserver for gcr.io/p/kube-apiserver:v1.21.0@sha256:...
+func artifactFileName(name string) string {
+	name, _ = splitDigest(name)
+	// drop the tag, but not a registry port
+	if i := strings.LastIndex(name, ":"); i > strings.LastIndex(name, "/") {
+		name = name[:i]
+	}
+	return strings.NewReplacer("/", "_", ":", "_").Replace(name)
+}
+
+type provenanceSubject struct {
+	Name   string            `json:"name"`
+	Digest map[string]string `json:"digest"`
+}
+
+type provenanceMaterial struct {
+	URI    string            `json:"uri,omitempty"`
+	Digest map[string]string `json:"digest,omitempty"`
+}
+
+// provenancePredicate is a SLSA v0.2 provenance predicate
+type provenancePredicate struct {
+	Builder struct {
+		ID string `json:"id"`
+	} `json:"builder"`
+	BuildType  string `json:"buildType"`
+	Invocation struct {
+		ConfigSource provenanceMaterial `json:"configSource"`
+		Parameters   map[string]string  `json:"parameters"`
+	} `json:"invocation"`
+	Metadata struct {
+		BuildStartedOn  string `json:"buildStartedOn,omitempty"`
+		BuildFinishedOn string `json:"buildFinishedOn,omitempty"`
+	} `json:"metadata"`
+	Materials []provenanceMaterial `json:"materials"`
+}
+
+// provenanceStatement is an in-toto statement of a provenance predicate
+type provenanceStatement struct {
+	Type          string              `json:"_type"`
+	PredicateType string              `json:"predicateType"`
+	Subject       []provenanceSubject `json:"subject"`
+	Predicate     provenancePredicate `json:"predicate"`
+}
+
+// newProvenance returns the provenance of artifacts built from the source at
+// the commit of the repository
+func newProvenance(attestations []Attestation, repository, commit, strategy, version string, started, finished time.Time) *provenanceStatement {
+	s := &provenanceStatement{
+		Type:          inTotoStatementType,
+		PredicateType: slsaProvenanceType,
+	}
+	for _, at := range attestations {
+		name, _ := splitDigest(at.Name)
+		algorithm, value := splitAlgorithm(at.Digest)
+		s.Subject = append(s.Subject, provenanceSubject{Name: name, Digest: map[string]string{algorithm: value}})
+	}
+	p := &s.Predicate
+	p.Builder.ID = provenanceBuilderID
+	p.BuildType = provenanceBuildType
+	source := provenanceMaterial{URI: repository}
+	if commit != "" {
+		source.Digest = map[string]string{"sha1": commit}
+	}
+	p.Invocation.ConfigSource = source
+	p.Invocation.Parameters = map[string]string{"strategy": strategy, "version": version}
+	if !started.IsZero() {
+		p.Metadata.BuildStartedOn = started.UTC().Format(time.RFC3339)
+	}
+	if !finished.IsZero() {
+		p.Metadata.BuildFinishedOn = finished.UTC().Format(time.RFC3339)
+	}
+	p.Materials = []provenanceMaterial{source}
+	return s
+}
+
+// splitAlgorithm splits a digest of <algorithm>:<hex>
+func splitAlgorithm(digest string) (string, string) {
+	if i := strings.Index(digest, ":"); i >= 0 {
+		return digest[:i], digest[i+1:]
+	}
+	return "sha256", digest
+}
+
+// writeProvenance writes the provenance predicate attached to the images to
+// path, and the in-toto statement of all the artifacts next to it
+func (a *Attester) writeProvenance(path string, attestations []Attestation) error {
+	repository, commit := gitSource(a.RepoRoot)
+	statement := newProvenance(attestations, repository, commit, a.Strategy, a.version, a.started, a.finished)
+	predicate, err := json.MarshalIndent(statement.Predicate, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(path, predicate, 0644); err != nil {
+		return fmt.Errorf("failed to write the provenance: %v", err)
+	}
+	data, err := json.MarshalIndent(statement, "", "  ")
+	if err != nil {
+		return err
+	}
+	statementPath := strings.TrimSuffix(path, ".json") + ".intoto.json"
+	if err := ioutil.WriteFile(statementPath, data, 0644); err != nil {
+		return fmt.Errorf("failed to write the provenance: %v", err)
+	}
+	return nil
+}
+
+// gitSource returns the origin and the commit of the repository, best effort
+func gitSource(root string) (string, string) {
+	var values [2]string
+	for i, args := range [][]string{
+		{"config", "--get", "remote.origin.url"},
+		{"rev-parse", "HEAD"},
+	} {
+		cmd := exec.Command("git", args...)
+		cmd.SetDir(root)
+		if lines, err := exec.OutputLines(cmd); err == nil && len(lines) > 0 {
+			values[i] = lines[0]
+		}
+	}
+	return values[0], values[1]
+}
+
+// generateSBOM writes the SPDX SBOM of the syft source e.g. registry:<ref> to path
+func generateSBOM(source, path string) error {
+	cmd := exec.Command("syft", source, "--output=spdx-json="+path, "--quiet")
+	cmd.SetStderr(os.Stderr)
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("failed to generate the SBOM of %s: %v", source, err)
+	}
+	return nil
+}
+
+// signBlob signs the file with cosign, writing the signature to signature
+// and, for keyless signing, the certificate next to it as .pem
+func (a *Attester) signBlob(path, signature string) error {
+	args := []string{"--output-signature=" + signature}
+	if a.Key == "" {
+		args = append(args, "--output-certificate="+strings.TrimSuffix(signature, ".sig")+".pem")
+	}
+	args = append(args, path)
+	return a.cosign("sign-blob", args...)
+}
+
+// cosign runs the cosign command, signing with the key if set
+func (a *Attester) cosign(command string, args ...string) error {
+	cmd := exec.Command("cosign", cosignArgs(command, a.Key, args...)...)
+	exec.InheritOutput(cmd)
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("cosign %s %s failed: %v", command, args[len(args)-1], err)
+	}
+	return nil
+}
+
+func cosignArgs(command, key string, args ...string) []string {
+	result := []string{command, "--yes"}
+	if key != "" {
+		result = append(result, "--key="+key)
+	}
+	return append(result, args...)
+}
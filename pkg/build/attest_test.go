@@ -0,0 +1,158 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package build
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestArtifactFileName(t *testing.T) {
+	testCases := []struct {
+		name     string
+		expected string
+	}{
+		{name: "kubernetes-server-linux-amd64.tar.gz", expected: "kubernetes-server-linux-amd64.tar.gz"},
+		{name: "gcr.io/p/kube-apiserver-amd64:v1.21.0@sha256:abc", expected: "gcr.io_p_kube-apiserver-amd64"},
+		{name: "localhost:5000/k8s/app@sha256:abc", expected: "localhost_5000_k8s_app"},
+	}
+	for _, tc := range testCases {
+		if actual := artifactFileName(tc.name); actual != tc.expected {
+			t.Errorf("expected file name %s for %s, but got %s", tc.expected, tc.name, actual)
+		}
+	}
+}
+
+func TestSplitDigest(t *testing.T) {
+	name, digest := splitDigest("gcr.io/p/app@sha256:abc")
+	if name != "gcr.io/p/app" || digest != "sha256:abc" {
+		t.Errorf("expected gcr.io/p/app and sha256:abc, but got %s and %s", name, digest)
+	}
+	if name, digest := splitDigest("gcr.io/p/app:v1"); name != "gcr.io/p/app:v1" || digest != "" {
+		t.Errorf("expected no digest, but got %s and %s", name, digest)
+	}
+}
+
+func TestFileDigest(t *testing.T) {
+	dir, err := ioutil.TempDir("", "attest")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "release.tar.gz")
+	if err := ioutil.WriteFile(path, []byte("hello"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	digest, err := fileDigest(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if expected := "sha256:2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824"; digest != expected {
+		t.Errorf("expected digest %s, but got %s", expected, digest)
+	}
+}
+
+func TestNewProvenance(t *testing.T) {
+	started := time.Date(2021, 5, 1, 10, 0, 0, 0, time.UTC)
+	s := newProvenance([]Attestation{
+		{Name: "kubernetes-server-linux-amd64.tar.gz", Digest: "sha256:aaa"},
+		{Name: "gcr.io/p/app@sha256:bbb", Digest: "sha256:bbb"},
+	}, "https://github.com/kubernetes/kubernetes", "0123abcd", "make", "v1.21.0", started, started.Add(time.Hour))
+
+	expectedSubjects := []provenanceSubject{
+		{Name: "kubernetes-server-linux-amd64.tar.gz", Digest: map[string]string{"sha256": "aaa"}},
+		{Name: "gcr.io/p/app", Digest: map[string]string{"sha256": "bbb"}},
+	}
+	if !reflect.DeepEqual(s.Subject, expectedSubjects) {
+		t.Errorf("expected subjects %v, but got %v", expectedSubjects, s.Subject)
+	}
+	if s.Type != inTotoStatementType || s.PredicateType != slsaProvenanceType {
+		t.Errorf("unexpected statement types %s, %s", s.Type, s.PredicateType)
+	}
+	p := s.Predicate
+	if p.Invocation.ConfigSource.Digest["sha1"] != "0123abcd" || len(p.Materials) != 1 {
+		t.Errorf("expected the commit as the config source and material, but got %+v", p)
+	}
+	if p.Invocation.Parameters["version"] != "v1.21.0" || p.Invocation.Parameters["strategy"] != "make" {
+		t.Errorf("unexpected parameters %v", p.Invocation.Parameters)
+	}
+	if p.Metadata.BuildStartedOn != "2021-05-01T10:00:00Z" || p.Metadata.BuildFinishedOn != "2021-05-01T11:00:00Z" {
+		t.Errorf("unexpected build times %+v", p.Metadata)
+	}
+
+	if s := newProvenance(nil, "", "", "ko", "v1", time.Time{}, time.Time{}); s.Predicate.Invocation.ConfigSource.Digest != nil || s.Predicate.Metadata.BuildStartedOn != "" {
+		t.Errorf("expected no commit and build times, but got %+v", s.Predicate)
+	}
+}
+
+func TestCosignArgs(t *testing.T) {
+	testCases := []struct {
+		key      string
+		expected []string
+	}{
+		{expected: []string{"sign", "--yes", "gcr.io/p/app@sha256:abc"}},
+		{key: "cosign.key", expected: []string{"sign", "--yes", "--key=cosign.key", "gcr.io/p/app@sha256:abc"}},
+	}
+	for _, tc := range testCases {
+		if actual := cosignArgs("sign", tc.key, "gcr.io/p/app@sha256:abc"); !reflect.DeepEqual(actual, tc.expected) {
+			t.Errorf("expected args %v, but got %v", tc.expected, actual)
+		}
+	}
+}
+
+func TestAttestedImages(t *testing.T) {
+	ko := &Ko{images: []string{"gcr.io/p/app@sha256:abc"}}
+	oci := &OCIStager{images: []string{"registry.example.com/k8s/kube-apiserver-amd64:v1.21.0"}}
+	cache := &Cache{Builder: ko, Stager: oci}
+	for _, b := range []Builder{ko, cache} {
+		if images := builtImages(b); !reflect.DeepEqual(images, ko.images) {
+			t.Errorf("expected built images %v, but got %v", ko.images, images)
+		}
+	}
+	for _, s := range []Stager{oci, cache} {
+		if images := stagedImages(s); !reflect.DeepEqual(images, oci.images) {
+			t.Errorf("expected staged images %v, but got %v", oci.images, images)
+		}
+	}
+	if images := stagedImages(ko); images != nil {
+		t.Errorf("expected no staged images for ko, but got %v", images)
+	}
+}
+
+func TestEnableAttest(t *testing.T) {
+	o := &Options{Strategy: string(MakeStrategy), BuildCache: true, Attest: true, AttestKey: "cosign.key"}
+	if err := o.Validate(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	attester, ok := o.Builder.(*Attester)
+	if !ok {
+		t.Fatalf("expected an attester, but got %T", o.Builder)
+	}
+	if _, ok := attester.Builder.(*Cache); !ok {
+		t.Errorf("expected the attester to wrap the cache, but got %T", attester.Builder)
+	}
+	if attester.Key != "cosign.key" {
+		t.Errorf("expected key cosign.key, but got %s", attester.Key)
+	}
+	if _, ok := o.StagedVersion(); ok {
+		t.Errorf("expected no staged version before the build")
+	}
+}
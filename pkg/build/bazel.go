@@ -18,6 +18,7 @@ package build
 
 import (
 	"fmt"
+	"strings"
 
 	"k8s.io/klog"
 
@@ -28,6 +29,10 @@ type Bazel struct {
 	RepoRoot      string
 	StageLocation string
 	ImageLocation string
+	// RemoteCache is the URL of the bazel remote cache, if any
+	RemoteCache string
+	// Flags are additional flags passed to every bazel command
+	Flags []string
 }
 
 var _ Builder = &Bazel{}
@@ -36,7 +41,11 @@ var _ Stager = &Bazel{}
 func (b *Bazel) Stage(version string) error {
 	location := b.StageLocation + "/v" + version
 	klog.V(0).Infof("Staging builds to %s ...", location)
-	cmd := exec.Command("bazel", "run", "//:push-build", "--", location)
+	args := b.args("run", "//:push-build", "--", location)
+	if b.ImageLocation != "" {
+		args = append(args, "--docker-registry="+b.ImageLocation)
+	}
+	cmd := exec.Command("bazel", args...)
 	cmd.SetDir(b.RepoRoot)
 	exec.InheritOutput(cmd)
 	return cmd.Run()
@@ -48,9 +57,24 @@ func (b *Bazel) Build() (string, error) {
 	if err != nil {
 		return "", fmt.Errorf("failed to get version: %v", err)
 	}
-	cmd := exec.Command("bazel", "build", "//build/release-tars")
+	cmd := exec.Command("bazel", b.args("build", "//build/release-tars")...)
 	cmd = cmd.SetDir(b.RepoRoot)
 	setSourceDateEpoch(b.RepoRoot, cmd)
 	exec.InheritOutput(cmd)
 	return version, cmd.Run()
 }
+
+// args returns the arguments for the bazel command, adding the cache and
+// extra flags before the target. Arguments after "--" are passed to the target.
+func (b *Bazel) args(command string, target ...string) []string {
+	args := []string{command}
+	if b.RemoteCache != "" {
+		args = append(args, "--remote_cache="+b.RemoteCache)
+		// GCS backed caches authenticate with the application default credentials
+		if strings.HasPrefix(b.RemoteCache, "https://storage.googleapis.com/") {
+			args = append(args, "--google_default_credentials")
+		}
+	}
+	args = append(args, b.Flags...)
+	return append(args, target...)
+}
@@ -0,0 +1,66 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package build
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestBazelArgs(t *testing.T) {
+	testCases := []struct {
+		name     string
+		bazel    Bazel
+		expected []string
+	}{
+		{
+			name:     "no cache",
+			bazel:    Bazel{},
+			expected: []string{"build", "//build/release-tars"},
+		},
+		{
+			name: "gcs cache and flags",
+			bazel: Bazel{
+				RemoteCache: "https://storage.googleapis.com/bucket",
+				Flags:       []string{"--config=ci"},
+			},
+			expected: []string{
+				"build",
+				"--remote_cache=https://storage.googleapis.com/bucket",
+				"--google_default_credentials",
+				"--config=ci",
+				"//build/release-tars",
+			},
+		},
+		{
+			name:     "grpc cache",
+			bazel:    Bazel{RemoteCache: "grpc://cache:9092"},
+			expected: []string{"build", "--remote_cache=grpc://cache:9092", "//build/release-tars"},
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			actual := tc.bazel.args("build", "//build/release-tars")
+			if !reflect.DeepEqual(actual, tc.expected) {
+				t.Errorf("expected args: %v, but got: %v", tc.expected, actual)
+			}
+		})
+	}
+}
@@ -103,16 +103,37 @@ func StoreCommonBinaries(kuberoot string, outroot string) {
 // setSourceDateEpoch sets the SOURCE_DATE_EPOCH env to the commit timestamp of the latest commit in the
 // kubernetes repository, specified under kubeRoot, for reproducible builds
 // https://github.com/kubernetes/kubernetes/blob/7eae33cb0e1ead51c80ad517bc670113d77fa28d/build/README.md#reproducibility
-func setSourceDateEpoch(kubeRoot string, cmd exec.Cmd) {
+// Any extraEnv is set on the command along with it.
+func setSourceDateEpoch(kubeRoot string, cmd exec.Cmd, extraEnv ...string) {
+	env := append(os.Environ(), extraEnv...)
+	defer func() { cmd.SetEnv(env...) }()
 	if os.Getenv("SOURCE_DATE_EPOCH") != "" {
 		return
 	}
 	gitCmd := exec.Command("git", "log", "-1", "--pretty=%ct")
 	gitCmd.SetDir(kubeRoot)
 	if output, err := exec.CombinedOutputLines(gitCmd); err == nil {
-		env := append(os.Environ(), fmt.Sprintf("SOURCE_DATE_EPOCH=%s", output[0]))
-		cmd.SetEnv(env...)
+		env = append(env, fmt.Sprintf("SOURCE_DATE_EPOCH=%s", output[0]))
 	} else {
 		klog.Warningf("failed to compute SOURCE_DATE_EPOCH from kubernetes repository: %v", err)
 	}
 }
+
+// Platforms returns the linux/<arch> platforms of the architectures
+func Platforms(archs []string) []string {
+	platforms := make([]string, len(archs))
+	for i, arch := range archs {
+		platforms[i] = "linux/" + arch
+	}
+	return platforms
+}
+
+// HasArch returns true if arch is one of archs
+func HasArch(archs []string, arch string) bool {
+	for _, a := range archs {
+		if a == arch {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,281 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package build
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"k8s.io/klog"
+
+	"sigs.k8s.io/kubetest2/pkg/exec"
+)
+
+// Cache wraps a Builder and a Stager to skip rebuilding sources that were
+// already built with the same options, keyed on the git tree hash of RepoRoot.
+//
+// A cache hit reuses either the artifacts previously staged to StageLocation,
+// or the release artifacts previously built locally under RepoRoot.
+// Builds of sources with uncommitted changes are never cached.
+type Cache struct {
+	Builder
+	Stager
+	RepoRoot string
+	// Dir is the local directory where the cache records are stored
+	Dir string
+	// StageLocation is where the artifacts are staged, records are also
+	// stored there so that they are shared across machines
+	StageLocation string
+	// Salt is mixed into the cache key, it should hold every option that
+	// affects the build output e.g. the strategy and its flags
+	Salt string
+
+	key     string
+	version string
+	hit     *cacheRecord
+}
+
+var _ Builder = &Cache{}
+var _ Stager = &Cache{}
+
+type cacheRecord struct {
+	// Version is the version returned by the build
+	Version string `json:"version"`
+	// StagedVersion is the version the build was staged as, if it was staged
+	StagedVersion string `json:"stagedVersion,omitempty"`
+}
+
+// remoteCacheDir is the directory under the stage location holding the cache records
+const remoteCacheDir = "build-cache"
+
+// Build returns the previously built version on a cache hit, otherwise it builds
+func (c *Cache) Build() (string, error) {
+	key, err := c.computeKey()
+	if err != nil {
+		klog.Warningf("Not using the build cache: %v", err)
+		return c.Builder.Build()
+	}
+	c.key = key
+
+	if r := c.lookup(); r != nil {
+		klog.V(0).Infof("Build cache hit for %s, skipping the build of version %s", key, r.Version)
+		c.hit = r
+		c.version = r.Version
+		return r.Version, nil
+	}
+
+	klog.V(1).Infof("Build cache miss for %s", key)
+	version, err := c.Builder.Build()
+	if err != nil {
+		return "", err
+	}
+	c.version = version
+	if err := c.writeStamp(); err != nil {
+		klog.Warningf("failed to stamp the build output for the cache: %v", err)
+	}
+	if err := c.save(cacheRecord{Version: version}); err != nil {
+		klog.Warningf("failed to record the build in the cache: %v", err)
+	}
+	return version, nil
+}
+
+// Stage is a no-op if the cached build was already staged, otherwise it stages
+func (c *Cache) Stage(version string) error {
+	if staged, ok := c.StagedVersion(); ok {
+		klog.V(0).Infof("Reusing the build previously staged as version %s", staged)
+		return nil
+	}
+	if err := c.Stager.Stage(version); err != nil {
+		return err
+	}
+	if c.key == "" {
+		return nil
+	}
+	if err := c.save(cacheRecord{Version: c.version, StagedVersion: version}); err != nil {
+		klog.Warningf("failed to record the staged build in the cache: %v", err)
+	}
+	return nil
+}
+
+// StagedVersion returns the version previously staged for the same sources and
+// options, if Build was a cache hit. The deployers should deploy this version
+// instead of the one they would stage.
+func (c *Cache) StagedVersion() (string, bool) {
+	if c.hit == nil || c.hit.StagedVersion == "" {
+		return "", false
+	}
+	return c.hit.StagedVersion, true
+}
+
+// computeKey returns the cache key for the sources at RepoRoot
+func (c *Cache) computeKey() (string, error) {
+	tree, err := SourceTreeHash(c.RepoRoot)
+	if err != nil {
+		return "", err
+	}
+	return cacheKey(tree, c.Salt), nil
+}
+
+// SourceTreeHash returns the git tree hash of the sources at root, to key
+// caches of their build outputs on. Sources with uncommitted changes have no
+// tree hash, as it would not match what is built.
+func SourceTreeHash(root string) (string, error) {
+	status := exec.Command("git", "status", "--porcelain")
+	status.SetDir(root)
+	changes, err := exec.Output(status)
+	if err != nil {
+		return "", fmt.Errorf("failed to get the git status of %s: %v", root, err)
+	}
+	if len(bytes.TrimSpace(changes)) > 0 {
+		return "", fmt.Errorf("%s has uncommitted changes", root)
+	}
+
+	revParse := exec.Command("git", "rev-parse", "HEAD^{tree}")
+	revParse.SetDir(root)
+	tree, err := exec.Output(revParse)
+	if err != nil {
+		return "", fmt.Errorf("failed to get the git tree hash of %s: %v", root, err)
+	}
+	return strings.TrimSpace(string(tree)), nil
+}
+
+func cacheKey(treeHash, salt string) string {
+	sum := sha256.Sum256([]byte(treeHash + "\n" + salt))
+	return hex.EncodeToString(sum[:])
+}
+
+// lookup returns the usable record for the key, if any
+func (c *Cache) lookup() *cacheRecord {
+	r, err := c.loadLocal()
+	if err != nil {
+		klog.Warningf("failed to load the build cache record: %v", err)
+	}
+	if r == nil && c.isRemote() {
+		if r, err = c.loadRemote(); err != nil {
+			klog.V(2).Infof("no remote build cache record: %v", err)
+		}
+	}
+	if r == nil {
+		return nil
+	}
+	if r.StagedVersion != "" && c.StageLocation != "" {
+		return r
+	}
+	// an unstaged build can only be reused if its output is still around,
+	// and was not since overwritten by a build of other sources or options
+	stamp, err := ioutil.ReadFile(c.stampPath())
+	if err != nil {
+		klog.V(1).Infof("the cached build output is gone, rebuilding: %v", err)
+		return nil
+	}
+	if strings.TrimSpace(string(stamp)) != c.key {
+		klog.V(1).Infof("the build output is not the cached build's, rebuilding")
+		return nil
+	}
+	return r
+}
+
+// stampPath is the file next to the release tars holding the cache key they
+// were built for
+func (c *Cache) stampPath() string {
+	return filepath.Join(c.RepoRoot, "_output", "release-tars", ".kubetest2-build-cache")
+}
+
+// writeStamp records the cache key the release tars were built for, if the
+// build produced release tars
+func (c *Cache) writeStamp() error {
+	if _, err := os.Stat(filepath.Dir(c.stampPath())); os.IsNotExist(err) {
+		return nil
+	}
+	return ioutil.WriteFile(c.stampPath(), []byte(c.key+"\n"), 0644)
+}
+
+func (c *Cache) localPath() string {
+	return filepath.Join(c.Dir, c.key+".json")
+}
+
+func (c *Cache) remotePath() string {
+	return strings.TrimSuffix(c.StageLocation, "/") + "/" + remoteCacheDir + "/" + c.key + ".json"
+}
+
+func (c *Cache) isRemote() bool {
+	return strings.HasPrefix(c.StageLocation, "gs://")
+}
+
+func (c *Cache) loadLocal() (*cacheRecord, error) {
+	data, err := ioutil.ReadFile(c.localPath())
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return parseRecord(data)
+}
+
+func (c *Cache) loadRemote() (*cacheRecord, error) {
+	cmd := exec.Command("gsutil", "cat", c.remotePath())
+	data, err := exec.Output(cmd)
+	if err != nil {
+		return nil, err
+	}
+	return parseRecord(data)
+}
+
+func parseRecord(data []byte) (*cacheRecord, error) {
+	r := &cacheRecord{}
+	if err := json.Unmarshal(data, r); err != nil {
+		return nil, fmt.Errorf("failed to parse build cache record: %v", err)
+	}
+	return r, nil
+}
+
+// save writes the record locally, and remotely if it has been staged
+func (c *Cache) save(r cacheRecord) error {
+	data, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(c.Dir, os.ModePerm); err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(c.localPath(), data, 0644); err != nil {
+		return err
+	}
+	if r.StagedVersion == "" || !c.isRemote() {
+		return nil
+	}
+	cmd := exec.Command("gsutil", "cp", "-", c.remotePath())
+	cmd.SetStdin(bytes.NewReader(data))
+	exec.InheritOutput(cmd)
+	return cmd.Run()
+}
+
+// defaultCacheDir returns the default local directory for the build cache records
+func defaultCacheDir() string {
+	if dir, err := os.UserCacheDir(); err == nil {
+		return filepath.Join(dir, "kubetest2", "build")
+	}
+	return filepath.Join(os.TempDir(), "kubetest2-build-cache")
+}
@@ -0,0 +1,113 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package build
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCacheKey(t *testing.T) {
+	base := cacheKey("tree", "make")
+	if base != cacheKey("tree", "make") {
+		t.Errorf("expected the cache key to be stable")
+	}
+	if base == cacheKey("other-tree", "make") {
+		t.Errorf("expected the cache key to depend on the tree hash")
+	}
+	if base == cacheKey("tree", "bazel") {
+		t.Errorf("expected the cache key to depend on the salt")
+	}
+}
+
+func TestCacheKeyAcrossRuns(t *testing.T) {
+	keys := map[string]bool{}
+	for _, runID := range []string{"run1", "run2"} {
+		o := &Options{
+			Builder:       &NoopBuilder{},
+			Stager:        &NoopStager{},
+			Strategy:      "make",
+			BuildCacheDir: "cache",
+			VersionSuffix: runID,
+		}
+		o.enableCache()
+		keys[cacheKey("tree", o.Builder.(*Cache).Salt)] = true
+	}
+	if len(keys) != 1 {
+		t.Errorf("expected the runs building the same tree to share the cache key, but got %d keys", len(keys))
+	}
+}
+
+func TestCacheLookup(t *testing.T) {
+	dir, err := ioutil.TempDir("", "build-cache")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	repoRoot := filepath.Join(dir, "repo")
+
+	c := &Cache{
+		RepoRoot:      repoRoot,
+		Dir:           filepath.Join(dir, "cache"),
+		StageLocation: "/local/stage",
+		key:           "key",
+	}
+	if r := c.lookup(); r != nil {
+		t.Errorf("expected a cache miss with no record, but got: %+v", r)
+	}
+
+	// an unstaged build is only reused while its output exists
+	if err := c.save(cacheRecord{Version: "v1.21.0"}); err != nil {
+		t.Fatalf("did not expect an error, but got: %v", err)
+	}
+	if r := c.lookup(); r != nil {
+		t.Errorf("expected a cache miss with no build output, but got: %+v", r)
+	}
+	if err := os.MkdirAll(filepath.Join(repoRoot, "_output", "release-tars"), os.ModePerm); err != nil {
+		t.Fatalf("failed to create build output: %v", err)
+	}
+	if r := c.lookup(); r != nil {
+		t.Errorf("expected a cache miss with an unstamped build output, but got: %+v", r)
+	}
+	other := &Cache{RepoRoot: repoRoot, key: "other-key"}
+	if err := other.writeStamp(); err != nil {
+		t.Fatalf("did not expect an error, but got: %v", err)
+	}
+	if r := c.lookup(); r != nil {
+		t.Errorf("expected a cache miss with the build output of another key, but got: %+v", r)
+	}
+	if err := c.writeStamp(); err != nil {
+		t.Fatalf("did not expect an error, but got: %v", err)
+	}
+	if r := c.lookup(); r == nil || r.Version != "v1.21.0" {
+		t.Errorf("expected a cache hit for v1.21.0, but got: %+v", r)
+	}
+
+	// a staged build is reused as the staged version
+	if err := os.RemoveAll(repoRoot); err != nil {
+		t.Fatalf("failed to remove build output: %v", err)
+	}
+	if err := c.save(cacheRecord{Version: "v1.21.0", StagedVersion: "1.21.0+run"}); err != nil {
+		t.Fatalf("did not expect an error, but got: %v", err)
+	}
+	c.hit = c.lookup()
+	if staged, ok := c.StagedVersion(); !ok || staged != "1.21.0+run" {
+		t.Errorf("expected staged version 1.21.0+run, but got: %q", staged)
+	}
+}
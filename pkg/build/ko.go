@@ -0,0 +1,123 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package build
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"k8s.io/klog"
+
+	"sigs.k8s.io/kubetest2/pkg/exec"
+	"sigs.k8s.io/kubetest2/pkg/metadata"
+)
+
+// Ko builds the Go binaries of RepoRoot into container images with ko
+// https://github.com/google/ko
+//
+// ko pushes the images as part of the build, so staging is a no-op.
+type Ko struct {
+	RepoRoot string
+	// ImportPaths are the Go import paths to build e.g. ./cmd/...
+	ImportPaths []string
+	// DockerRepo is the repository the images are pushed to (KO_DOCKER_REPO)
+	DockerRepo string
+	// BaseImage overrides the default base image (KO_DEFAULTBASEIMAGE)
+	BaseImage string
+	// Platforms is a comma separated list of platforms e.g. linux/amd64,linux/arm64
+	Platforms string
+	// RunDir is where the built image references are recorded, if set
+	RunDir string
+
+	// images are the references, with digests, of the images built
+	images []string
+}
+
+var _ Builder = &Ko{}
+var _ Stager = &Ko{}
+
+// Build builds and pushes the images, returning the git version of RepoRoot
+func (k *Ko) Build() (string, error) {
+	if k.DockerRepo == "" {
+		return "", fmt.Errorf("the ko build strategy requires an image location to push the images to")
+	}
+	klog.V(0).Infof("Building images with ko from %s ...", k.RepoRoot)
+
+	cmd := exec.Command("ko", k.buildArgs()...)
+	cmd.SetDir(k.RepoRoot)
+	cmd.SetEnv(k.env()...)
+	cmd.SetStderr(os.Stderr)
+	// ko prints the reference of each built image on stdout
+	images, err := exec.OutputLines(cmd)
+	if err != nil {
+		return "", fmt.Errorf("failed to build images with ko: %v", err)
+	}
+	k.images = images
+	klog.V(0).Infof("Built images: %v", images)
+
+	if k.RunDir != "" {
+		if err := metadata.NewStore(filepath.Join(k.RunDir, "metadata.json")).SetStrings(metadata.ImagesKey, images); err != nil {
+			klog.Warningf("failed to record the built images: %v", err)
+		}
+	}
+	return gitVersion(k.RepoRoot)
+}
+
+// Stage is a no-op, the images have already been pushed by Build
+func (k *Ko) Stage(string) error {
+	return nil
+}
+
+// Images returns the references of the images built
+func (k *Ko) Images() []string {
+	return k.images
+}
+
+func (k *Ko) buildArgs() []string {
+	args := []string{"build", "--base-import-paths"}
+	if k.Platforms != "" {
+		args = append(args, "--platform="+k.Platforms)
+	}
+	importPaths := k.ImportPaths
+	if len(importPaths) == 0 {
+		importPaths = []string{"./..."}
+	}
+	return append(args, importPaths...)
+}
+
+func (k *Ko) env() []string {
+	env := append(os.Environ(), "KO_DOCKER_REPO="+k.DockerRepo)
+	if k.BaseImage != "" {
+		env = append(env, "KO_DEFAULTBASEIMAGE="+k.BaseImage)
+	}
+	return env
+}
+
+// gitVersion returns the git describe version of the repository at root
+func gitVersion(root string) (string, error) {
+	cmd := exec.Command("git", "describe", "--tags", "--always", "--dirty")
+	cmd.SetDir(root)
+	output, err := exec.OutputLines(cmd)
+	if err != nil {
+		return "", fmt.Errorf("failed to get version: %v", err)
+	}
+	if len(output) == 0 {
+		return "", fmt.Errorf("failed to get version: git describe returned no output")
+	}
+	return output[0], nil
+}
@@ -18,12 +18,15 @@ package build
 
 import (
 	"fmt"
+	"strings"
 
 	"sigs.k8s.io/kubetest2/pkg/exec"
 )
 
 type MakeBuilder struct {
 	RepoRoot string
+	// Archs are the architectures to build for, defaults to the host architecture
+	Archs []string
 }
 
 var _ Builder = &MakeBuilder{}
@@ -40,7 +43,13 @@ func (m *MakeBuilder) Build() (string, error) {
 	}
 	cmd := exec.Command("make", target)
 	cmd.SetDir(m.RepoRoot)
-	setSourceDateEpoch(m.RepoRoot, cmd)
+	var env []string
+	if len(m.Archs) > 0 {
+		// KUBE_BUILD_PLATFORMS takes precedence over the host only quick-release platforms
+		// and determines both the binaries and the server images built
+		env = append(env, "KUBE_BUILD_PLATFORMS="+strings.Join(Platforms(m.Archs), " "))
+	}
+	setSourceDateEpoch(m.RepoRoot, cmd, env...)
 	exec.InheritOutput(cmd)
 	if err = cmd.Run(); err != nil {
 		return "", err
@@ -0,0 +1,127 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package build
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"k8s.io/klog"
+
+	"sigs.k8s.io/kubetest2/pkg/exec"
+)
+
+const (
+	// ociScheme is the scheme of stage locations that are OCI repositories
+	ociScheme = "oci://"
+	// releaseTarMediaType is the media type of the release tars pushed with oras
+	releaseTarMediaType = "application/vnd.kubernetes.release.tar+gzip"
+	// releaseArtifactName is the name of the artifact holding the release tars
+	releaseArtifactName = "release"
+)
+
+// OCIStager stages the built images and release tars to an OCI registry
+// instead of GCS, for environments that cannot consume GCS.
+// The container images are pushed with crane as <repository>/<image>-<arch>:<version>
+// and the release tars are pushed with oras as the <repository>/release:<version> artifact.
+type OCIStager struct {
+	RepoRoot string
+	// StageLocation is the repository to stage to e.g. oci://registry.example.com/kubernetes
+	StageLocation string
+
+	// images are the references of the images pushed
+	images []string
+}
+
+var _ Stager = &OCIStager{}
+
+// IsOCILocation returns true if the stage location is an OCI repository
+func IsOCILocation(location string) bool {
+	return strings.HasPrefix(location, ociScheme)
+}
+
+func (o *OCIStager) Stage(version string) error {
+	repository := strings.TrimSuffix(strings.TrimPrefix(o.StageLocation, ociScheme), "/")
+	if repository == "" {
+		return fmt.Errorf("invalid stage location: %v. Use oci://<registry>/<repository>", o.StageLocation)
+	}
+	tag := ociTag(version)
+	outputDir := filepath.Join(o.RepoRoot, "_output")
+
+	images, err := filepath.Glob(filepath.Join(outputDir, "release-images", "*", "*.tar"))
+	if err != nil {
+		return err
+	}
+	for _, image := range images {
+		ref := imageRef(repository, image, tag)
+		klog.V(0).Infof("Staging image %s to %s ...", image, ref)
+		cmd := exec.Command("crane", "push", image, ref)
+		exec.InheritOutput(cmd)
+		if err := cmd.Run(); err != nil {
+			return fmt.Errorf("failed to push image %s: %v", ref, err)
+		}
+		o.images = append(o.images, ref)
+	}
+
+	tarsDir := filepath.Join(outputDir, "release-tars")
+	tars, err := filepath.Glob(filepath.Join(tarsDir, "*.tar.gz"))
+	if err != nil {
+		return err
+	}
+	if len(tars) == 0 {
+		return fmt.Errorf("no release tars found in %s", tarsDir)
+	}
+	ref := fmt.Sprintf("%s/%s:%s", repository, releaseArtifactName, tag)
+	klog.V(0).Infof("Staging %d release tars to %s ...", len(tars), ref)
+	// oras records the file names relative to the working directory
+	cmd := exec.Command("oras", orasPushArgs(ref, tars)...)
+	cmd.SetDir(tarsDir)
+	exec.InheritOutput(cmd)
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("failed to push the release tars to %s: %v", ref, err)
+	}
+	return nil
+}
+
+// StagedImages returns the references of the images pushed by Stage
+func (o *OCIStager) StagedImages() []string {
+	return o.images
+}
+
+// ociTag returns a valid OCI tag for the version, as tags cannot contain a +
+func ociTag(version string) string {
+	if !strings.HasPrefix(version, "v") {
+		version = "v" + version
+	}
+	return strings.ReplaceAll(version, "+", "_")
+}
+
+// imageRef returns the reference for an image tar of _output/release-images/<arch>/<image>.tar
+func imageRef(repository, imageTar, tag string) string {
+	arch := filepath.Base(filepath.Dir(imageTar))
+	name := strings.TrimSuffix(filepath.Base(imageTar), ".tar")
+	return fmt.Sprintf("%s/%s-%s:%s", repository, name, arch, tag)
+}
+
+func orasPushArgs(ref string, files []string) []string {
+	args := []string{"push", ref}
+	for _, f := range files {
+		args = append(args, filepath.Base(f)+":"+releaseTarMediaType)
+	}
+	return args
+}
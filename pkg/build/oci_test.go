@@ -0,0 +1,57 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package build
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestOCITag(t *testing.T) {
+	testCases := []struct {
+		version  string
+		expected string
+	}{
+		{version: "v1.21.0", expected: "v1.21.0"},
+		{version: "1.21.0-alpha.1.123+abcdef", expected: "v1.21.0-alpha.1.123_abcdef"},
+	}
+	for _, tc := range testCases {
+		if actual := ociTag(tc.version); actual != tc.expected {
+			t.Errorf("expected tag %s for version %s, but got %s", tc.expected, tc.version, actual)
+		}
+	}
+}
+
+func TestOCIRefs(t *testing.T) {
+	ref := imageRef("registry.example.com/k8s", "/k/_output/release-images/arm64/kube-apiserver.tar", "v1.21.0")
+	if expected := "registry.example.com/k8s/kube-apiserver-arm64:v1.21.0"; ref != expected {
+		t.Errorf("expected image ref %s, but got %s", expected, ref)
+	}
+
+	args := orasPushArgs("registry.example.com/k8s/release:v1.21.0", []string{
+		"/k/_output/release-tars/kubernetes-server-linux-amd64.tar.gz",
+		"/k/_output/release-tars/kubernetes-test-linux-amd64.tar.gz",
+	})
+	expected := []string{
+		"push", "registry.example.com/k8s/release:v1.21.0",
+		"kubernetes-server-linux-amd64.tar.gz:application/vnd.kubernetes.release.tar+gzip",
+		"kubernetes-test-linux-amd64.tar.gz:application/vnd.kubernetes.release.tar+gzip",
+	}
+	if !reflect.DeepEqual(args, expected) {
+		t.Errorf("expected args: %v, but got: %v", expected, args)
+	}
+}
@@ -18,47 +18,139 @@ package build
 
 import (
 	"fmt"
+	"strings"
 )
 
 //  ignore package name stutter
 type BuildAndStageStrategy string //nolint:golint
 
 const (
-	// bazelStrategy builds and (optionally) stages using bazel
-	bazelStrategy BuildAndStageStrategy = "bazel"
+	// BazelStrategy builds and (optionally) stages using bazel
+	BazelStrategy BuildAndStageStrategy = "bazel"
 	// MakeStrategy builds using make and (optionally) stages using krel
 	MakeStrategy BuildAndStageStrategy = "make"
+	// KoStrategy builds and pushes container images of Go binaries using ko
+	KoStrategy BuildAndStageStrategy = "ko"
 )
 
 type Options struct {
-	Strategy           string `flag:"~strategy" desc:"Determines the build strategy to use either make or bazel."`
-	StageLocation      string `flag:"~stage" desc:"Upload binaries to gs://bucket/ci/job-suffix if set"`
-	RepoRoot           string `flag:"-"`
-	ImageLocation      string `flag:"~image-location" desc:"Image registry where built images are stored."`
-	StageExtraGCPFiles bool   `flag:"-"`
-	VersionSuffix      string `flag:"-"`
-	UpdateLatest       bool   `flag:"~update-latest" desc:"Whether should upload the build number to the GCS"`
+	Strategy           string   `flag:"~strategy" desc:"Determines the build strategy to use either make, bazel or ko."`
+	StageLocation      string   `flag:"~stage" desc:"Upload binaries to gs://bucket/ci/job-suffix if set. With --strategy=make, images and binaries can instead be pushed to an OCI registry with oci://registry/repo"`
+	RepoRoot           string   `flag:"-"`
+	ImageLocation      string   `flag:"~image-location" desc:"Image registry where built images are stored."`
+	StageExtraGCPFiles bool     `flag:"-"`
+	VersionSuffix      string   `flag:"-"`
+	UpdateLatest       bool     `flag:"~update-latest" desc:"Whether should upload the build number to the GCS"`
+	KoImportPaths      []string `flag:"~ko-import-paths" desc:"Only used with --strategy=ko. Comma separated list of Go import paths to build into images, defaults to ./..."`
+	KoBaseImage        string   `flag:"~ko-base-image" desc:"Only used with --strategy=ko. Base image for the built images, defaults to the ko default base image."`
+	KoPlatforms        string   `flag:"~ko-platforms" desc:"Only used with --strategy=ko. Comma separated list of platforms to build the images for e.g. linux/amd64,linux/arm64."`
+	BazelRemoteCache   string   `flag:"~bazel-remote-cache" desc:"Only used with --strategy=bazel. URL of the bazel remote cache e.g. https://storage.googleapis.com/bucket or grpc://host:port."`
+	BazelFlags         []string `flag:"~bazel-flags" desc:"Only used with --strategy=bazel. Comma separated list of additional flags for bazel build and bazel run e.g. --config=remote."`
+	BuildArchs         []string `flag:"~build-arch" desc:"Comma separated list of architectures to build the binaries and images for e.g. amd64,arm64. Defaults to the host architecture."`
+	BuildCache         bool     `flag:"~build-cache" desc:"Whether to skip the build when the same sources (by git tree hash) were already built with the same flags, reusing the previously staged or locally built artifacts."`
+	BuildCacheDir      string   `flag:"~build-cache-dir" desc:"Only used with --build-cache. Local directory of the build cache, defaults to the user cache directory."`
+	Attest             bool     `flag:"~attest" desc:"Whether to attest the built artifacts: generate SBOMs of the release tars and images with syft, sign them with cosign along with a SLSA provenance, and record their digests in the metadata."`
+	AttestKey          string   `flag:"~attest-key" desc:"Only used with --attest. cosign key reference to sign with e.g. cosign.key or gcpkms://..., defaults to keyless signing."`
+	RunDir             string   `flag:"-"`
 	Builder
 	Stager
 }
 
 func (o *Options) Validate() error {
-	return o.implementationFromStrategy()
+	if err := o.implementationFromStrategy(); err != nil {
+		return err
+	}
+	if o.BuildCache {
+		o.enableCache()
+	}
+	if o.Attest {
+		o.enableAttest()
+	}
+	return nil
+}
+
+// enableAttest wraps the builder and stager, cached or not, with an attester
+func (o *Options) enableAttest() {
+	if _, ok := o.Builder.(*Attester); ok {
+		return
+	}
+	attester := &Attester{
+		Builder:  o.Builder,
+		Stager:   o.Stager,
+		RepoRoot: o.RepoRoot,
+		RunDir:   o.RunDir,
+		Key:      o.AttestKey,
+		Strategy: o.Strategy,
+	}
+	o.Builder = attester
+	o.Stager = attester
+}
+
+// enableCache wraps the builder and stager of the strategy with a build cache
+func (o *Options) enableCache() {
+	if _, ok := o.Builder.(*Cache); ok {
+		return
+	}
+	dir := o.BuildCacheDir
+	if dir == "" {
+		dir = defaultCacheDir()
+	}
+	cache := &Cache{
+		Builder:       o.Builder,
+		Stager:        o.Stager,
+		RepoRoot:      o.RepoRoot,
+		Dir:           dir,
+		StageLocation: o.StageLocation,
+		// every option changing the build output or where it is staged, but
+		// the VersionSuffix e.g. of the run id, reused with StagedVersion
+		Salt: strings.Join([]string{
+			o.Strategy,
+			o.StageLocation,
+			o.ImageLocation,
+			fmt.Sprintf("%t", o.StageExtraGCPFiles),
+			strings.Join(o.KoImportPaths, ","),
+			o.KoBaseImage,
+			o.KoPlatforms,
+			strings.Join(o.BazelFlags, ","),
+			strings.Join(o.BuildArchs, ","),
+		}, "\n"),
+	}
+	o.Builder = cache
+	o.Stager = cache
+}
+
+// StagedVersion returns the version previously staged for the same sources
+// if the build cache is enabled and the build was a cache hit
+func (o *Options) StagedVersion() (string, bool) {
+	b := o.Builder
+	if attester, ok := b.(*Attester); ok {
+		b = attester.Builder
+	}
+	if cache, ok := b.(*Cache); ok {
+		return cache.StagedVersion()
+	}
+	return "", false
 }
 
 func (o *Options) implementationFromStrategy() error {
 	switch BuildAndStageStrategy(o.Strategy) {
-	case bazelStrategy:
+	case BazelStrategy:
+		if len(o.BuildArchs) > 0 {
+			return fmt.Errorf("--build-arch is not supported with --strategy=bazel")
+		}
 		bazel := &Bazel{
 			RepoRoot:      o.RepoRoot,
 			StageLocation: o.StageLocation,
 			ImageLocation: o.ImageLocation,
+			RemoteCache:   o.BazelRemoteCache,
+			Flags:         o.BazelFlags,
 		}
 		o.Builder = bazel
 		o.Stager = bazel
 	case MakeStrategy:
 		o.Builder = &MakeBuilder{
 			RepoRoot: o.RepoRoot,
+			Archs:    o.BuildArchs,
 		}
 		o.Stager = &Krel{
 			RepoRoot:        o.RepoRoot,
@@ -67,6 +159,27 @@ func (o *Options) implementationFromStrategy() error {
 			StageExtraFiles: o.StageExtraGCPFiles,
 			UpdateLatest:    o.UpdateLatest,
 		}
+		if IsOCILocation(o.StageLocation) {
+			o.Stager = &OCIStager{
+				RepoRoot:      o.RepoRoot,
+				StageLocation: o.StageLocation,
+			}
+		}
+	case KoStrategy:
+		platforms := o.KoPlatforms
+		if platforms == "" && len(o.BuildArchs) > 0 {
+			platforms = strings.Join(Platforms(o.BuildArchs), ",")
+		}
+		ko := &Ko{
+			RepoRoot:    o.RepoRoot,
+			ImportPaths: o.KoImportPaths,
+			DockerRepo:  o.ImageLocation,
+			BaseImage:   o.KoBaseImage,
+			Platforms:   platforms,
+			RunDir:      o.RunDir,
+		}
+		o.Builder = ko
+		o.Stager = ko
 	default:
 		return fmt.Errorf("unknown build strategy: %v", o.Strategy)
 	}
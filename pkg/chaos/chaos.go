@@ -0,0 +1,293 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package chaos implements injecting faults into the cluster under test while
+// the tests run, e.g. for resilience testing.
+package chaos
+
+import (
+	"fmt"
+	"math/rand"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"k8s.io/klog"
+
+	"sigs.k8s.io/kubetest2/pkg/exec"
+)
+
+// Action is a kind of fault injected into the cluster
+type Action string
+
+// The supported actions
+const (
+	// NodeDrain drains a random node, which is uncordoned on recovery
+	NodeDrain Action = "node-drain"
+	// NodeReboot reboots a random node, it requires a deployer implementing NodeRebooter
+	NodeReboot Action = "node-reboot"
+	// PodKill deletes a random kube-system pod
+	PodKill Action = "pod-kill"
+	// ZoneFailure takes down the nodes of a zone until recovery,
+	// it requires a deployer implementing ZoneFailer
+	ZoneFailure Action = "zone-failure"
+)
+
+var actions = map[Action]bool{
+	NodeDrain:   true,
+	NodeReboot:  true,
+	PodKill:     true,
+	ZoneFailure: true,
+}
+
+// NodeRebooter is implemented by deployers that can reboot the nodes of their clusters
+type NodeRebooter interface {
+	RebootNode(node string) error
+}
+
+// ZoneFailer is implemented by deployers that can simulate the failure of a
+// zone of their clusters, restore brings the zone back
+type ZoneFailer interface {
+	FailZone() (restore func() error, err error)
+}
+
+// Fault is an action injected every interval
+type Fault struct {
+	Action   Action
+	Interval time.Duration
+}
+
+// ParseFaults parses faults of the form action:interval e.g. node-reboot:10m
+func ParseFaults(specs []string) ([]Fault, error) {
+	faults := make([]Fault, 0, len(specs))
+	for _, spec := range specs {
+		parts := strings.SplitN(spec, ":", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("invalid chaos %q, expected action:interval e.g. %s:10m", spec, NodeReboot)
+		}
+		action := Action(parts[0])
+		if !actions[action] {
+			return nil, fmt.Errorf("unknown chaos action %q, expected one of %s", parts[0], strings.Join(actionNames(), ", "))
+		}
+		interval, err := time.ParseDuration(parts[1])
+		if err != nil {
+			return nil, fmt.Errorf("invalid interval for chaos %q: %v", spec, err)
+		}
+		if interval <= 0 {
+			return nil, fmt.Errorf("invalid interval for chaos %q: must be positive", spec)
+		}
+		faults = append(faults, Fault{Action: action, Interval: interval})
+	}
+	return faults, nil
+}
+
+func actionNames() []string {
+	names := make([]string, 0, len(actions))
+	for action := range actions {
+		names = append(names, string(action))
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Injector injects the faults periodically between Start and Stop. Faults
+// that have a recovery (draining a node, failing a zone) are recovered after
+// half of their interval, or on Stop.
+type Injector struct {
+	faults     []Fault
+	kubeconfig string
+	deployer   interface{}
+
+	randMu sync.Mutex
+	rand   *rand.Rand
+
+	stop chan struct{}
+	wg   sync.WaitGroup
+
+	errsMu sync.Mutex
+	errs   []string
+}
+
+// NewInjector returns an injector of the faults into the cluster of the kubeconfig,
+// deployer is checked for the optional capabilities required by the actions
+func NewInjector(faults []Fault, kubeconfig string, deployer interface{}) (*Injector, error) {
+	for _, f := range faults {
+		switch f.Action {
+		case NodeReboot:
+			if _, ok := deployer.(NodeRebooter); !ok {
+				return nil, fmt.Errorf("chaos action %s is not supported by the deployer", f.Action)
+			}
+		case ZoneFailure:
+			if _, ok := deployer.(ZoneFailer); !ok {
+				return nil, fmt.Errorf("chaos action %s is not supported by the deployer", f.Action)
+			}
+		}
+	}
+	return &Injector{
+		faults:     faults,
+		kubeconfig: kubeconfig,
+		deployer:   deployer,
+		rand:       rand.New(rand.NewSource(time.Now().UnixNano())),
+		stop:       make(chan struct{}),
+	}, nil
+}
+
+// Start starts injecting the faults
+func (i *Injector) Start() {
+	for _, f := range i.faults {
+		f := f
+		i.wg.Add(1)
+		go func() {
+			defer i.wg.Done()
+			i.loop(f)
+		}()
+	}
+}
+
+// Stop stops injecting the faults and recovers from the ongoing ones,
+// it returns an error if any recovery failed, as the cluster is then left degraded
+func (i *Injector) Stop() error {
+	close(i.stop)
+	i.wg.Wait()
+	i.errsMu.Lock()
+	defer i.errsMu.Unlock()
+	if len(i.errs) > 0 {
+		return fmt.Errorf("failed to recover from chaos: %s", strings.Join(i.errs, "; "))
+	}
+	return nil
+}
+
+func (i *Injector) loop(f Fault) {
+	ticker := time.NewTicker(f.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-i.stop:
+			return
+		case <-ticker.C:
+		}
+
+		klog.Infof("Chaos: injecting %s", f.Action)
+		restore, err := i.inject(f.Action)
+		if err != nil {
+			// the cluster may legitimately be in a state where the fault
+			// cannot be injected e.g. while recovering from another fault
+			klog.Warningf("Chaos: failed to inject %s: %v", f.Action, err)
+			continue
+		}
+		if restore == nil {
+			continue
+		}
+		select {
+		case <-i.stop:
+		case <-time.After(f.Interval / 2):
+		}
+		klog.Infof("Chaos: recovering from %s", f.Action)
+		if err := restore(); err != nil {
+			klog.Errorf("Chaos: failed to recover from %s: %v", f.Action, err)
+			i.errsMu.Lock()
+			i.errs = append(i.errs, fmt.Sprintf("%s: %v", f.Action, err))
+			i.errsMu.Unlock()
+		}
+	}
+}
+
+func (i *Injector) inject(action Action) (restore func() error, err error) {
+	switch action {
+	case NodeDrain:
+		node, err := i.randomNode()
+		if err != nil {
+			return nil, err
+		}
+		if err := i.kubectl("drain", node, "--ignore-daemonsets", "--delete-emptydir-data", "--force", "--timeout=5m"); err != nil {
+			// drain cordons the node before evicting, so uncordon it even on failure
+			if uncordonErr := i.kubectl("uncordon", node); uncordonErr != nil {
+				klog.Errorf("Chaos: failed to uncordon node %s: %v", node, uncordonErr)
+			}
+			return nil, err
+		}
+		return func() error { return i.kubectl("uncordon", node) }, nil
+	case NodeReboot:
+		node, err := i.randomNode()
+		if err != nil {
+			return nil, err
+		}
+		klog.Infof("Chaos: rebooting node %s", node)
+		return nil, i.deployer.(NodeRebooter).RebootNode(node)
+	case PodKill:
+		pods, err := i.kubectlLines("get", "pods", "--namespace=kube-system", "--output=jsonpath={range .items[*]}{.metadata.name}{\"\\n\"}{end}")
+		if err != nil {
+			return nil, err
+		}
+		pod, err := i.pick(pods, "kube-system pods")
+		if err != nil {
+			return nil, err
+		}
+		return nil, i.kubectl("delete", "pod", pod, "--namespace=kube-system", "--wait=false")
+	case ZoneFailure:
+		return i.deployer.(ZoneFailer).FailZone()
+	}
+	return nil, fmt.Errorf("unknown chaos action %q", action)
+}
+
+func (i *Injector) randomNode() (string, error) {
+	nodes, err := i.kubectlLines("get", "nodes", "--output=jsonpath={range .items[*]}{.metadata.name}{\"\\n\"}{end}")
+	if err != nil {
+		return "", err
+	}
+	return i.pick(nodes, "nodes")
+}
+
+func (i *Injector) pick(items []string, what string) (string, error) {
+	if len(items) == 0 {
+		return "", fmt.Errorf("no %s found", what)
+	}
+	i.randMu.Lock()
+	defer i.randMu.Unlock()
+	return items[i.rand.Intn(len(items))], nil
+}
+
+func (i *Injector) command(args ...string) exec.Cmd {
+	cmd := exec.Command("kubectl", args...)
+	if i.kubeconfig != "" {
+		cmd.SetEnv(append(os.Environ(), "KUBECONFIG="+i.kubeconfig)...)
+	}
+	return cmd
+}
+
+func (i *Injector) kubectl(args ...string) error {
+	cmd := i.command(args...)
+	exec.InheritOutput(cmd)
+	return cmd.Run()
+}
+
+func (i *Injector) kubectlLines(args ...string) ([]string, error) {
+	cmd := i.command(args...)
+	cmd.SetStderr(os.Stderr)
+	lines, err := exec.OutputLines(cmd)
+	if err != nil {
+		return nil, err
+	}
+	var items []string
+	for _, line := range lines {
+		if line = strings.TrimSpace(line); line != "" {
+			items = append(items, line)
+		}
+	}
+	return items, nil
+}
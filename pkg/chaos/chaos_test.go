@@ -0,0 +1,109 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package chaos
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseFaults(t *testing.T) {
+	testCases := []struct {
+		name        string
+		specs       []string
+		expected    []Fault
+		expectError bool
+	}{
+		{
+			name:  "multiple faults",
+			specs: []string{"node-reboot:10m", "pod-kill:30s"},
+			expected: []Fault{
+				{Action: NodeReboot, Interval: 10 * time.Minute},
+				{Action: PodKill, Interval: 30 * time.Second},
+			},
+		},
+		{
+			name:        "missing interval",
+			specs:       []string{"node-drain"},
+			expectError: true,
+		},
+		{
+			name:        "unknown action",
+			specs:       []string{"disk-fill:10m"},
+			expectError: true,
+		},
+		{
+			name:        "invalid interval",
+			specs:       []string{"pod-kill:often"},
+			expectError: true,
+		},
+		{
+			name:        "non positive interval",
+			specs:       []string{"pod-kill:0s"},
+			expectError: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			faults, err := ParseFaults(tc.specs)
+			if tc.expectError {
+				if err == nil {
+					t.Errorf("expected an error but got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Errorf("did not expect an error, but got: %v", err)
+			}
+			if !reflect.DeepEqual(faults, tc.expected) {
+				t.Errorf("expected faults %v, but got %v", tc.expected, faults)
+			}
+		})
+	}
+}
+
+type rebooter struct{}
+
+func (rebooter) RebootNode(string) error { return nil }
+
+func TestNewInjector(t *testing.T) {
+	testCases := []struct {
+		name        string
+		action      Action
+		deployer    interface{}
+		expectError bool
+	}{
+		{name: "generic action", action: NodeDrain, deployer: struct{}{}},
+		{name: "supported deployer action", action: NodeReboot, deployer: rebooter{}},
+		{name: "unsupported deployer action", action: ZoneFailure, deployer: rebooter{}, expectError: true},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			_, err := NewInjector([]Fault{{Action: tc.action, Interval: time.Minute}}, "", tc.deployer)
+			if (err != nil) != tc.expectError {
+				t.Errorf("expected error: %v, but got: %v", tc.expectError, err)
+			}
+		})
+	}
+}
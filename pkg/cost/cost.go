@@ -0,0 +1,169 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package cost estimates the spend of the resources provisioned by a
+// deployer during a run, for per-job cost attribution.
+//
+// Estimates are based on on-demand list prices, scaled by approximate
+// factors in the regions priced differently than us-central1, and do not
+// account for discounts, disks or network egress.
+package cost
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"sigs.k8s.io/kubetest2/pkg/metadata"
+)
+
+const (
+	// reportFile is the name of the file in the run dir holding the cost report,
+	// it is persisted so that the estimate can be completed by a later
+	// invocation for the same run (e.g. --up then --down)
+	reportFile = "cost-report.json"
+
+	// Currency is the currency of every estimate
+	Currency = "USD"
+
+	metadataEstimatedCost = "estimated-cost-usd"
+	metadataHourlyCost    = "hourly-cost-usd"
+	metadataInventory     = "resource-inventory"
+)
+
+// Resource is a billable resource provisioned for the run
+type Resource struct {
+	Kind        string `json:"kind"`
+	Name        string `json:"name,omitempty"`
+	Project     string `json:"project,omitempty"`
+	Region      string `json:"region,omitempty"`
+	MachineType string `json:"machineType,omitempty"`
+	Count       int    `json:"count"`
+	// HourlyCost is the cost of all Count instances of the resource
+	HourlyCost float64 `json:"hourlyCost"`
+}
+
+func (r Resource) String() string {
+	s := fmt.Sprintf("%dx %s", r.Count, r.Kind)
+	if r.MachineType != "" {
+		s += " " + r.MachineType
+	}
+	if r.Name != "" {
+		s += " " + r.Name
+	}
+	if r.Project != "" || r.Region != "" {
+		s += fmt.Sprintf(" (%s/%s)", r.Project, r.Region)
+	}
+	return s
+}
+
+// Report is the resource inventory of a run along with its estimated cost
+type Report struct {
+	Started       time.Time  `json:"started"`
+	Finished      *time.Time `json:"finished,omitempty"`
+	Hours         float64    `json:"hours"`
+	HourlyCost    float64    `json:"hourlyCost"`
+	EstimatedCost float64    `json:"estimatedCost"`
+	Currency      string     `json:"currency"`
+	Resources     []Resource `json:"resources"`
+}
+
+// NewReport returns a report for the resources started at the given time
+func NewReport(started time.Time, resources []Resource) *Report {
+	r := &Report{
+		Started:   started,
+		Currency:  Currency,
+		Resources: resources,
+	}
+	for _, res := range resources {
+		r.HourlyCost += res.HourlyCost
+	}
+	return r
+}
+
+// Finish sets the end of the run and computes the estimated cost
+func (r *Report) Finish(finished time.Time) {
+	r.Finished = &finished
+	r.Hours = finished.Sub(r.Started).Hours()
+	r.EstimatedCost = r.HourlyCost * r.Hours
+}
+
+// Start records the resource inventory for the run in runDir,
+// using the current time as the start of the billing period.
+func Start(runDir string, resources []Resource) error {
+	r := NewReport(time.Now(), resources)
+	return write(runDir, r)
+}
+
+// Finish completes the report previously recorded in runDir with Start,
+// using the current time as the end of the billing period.
+// It is a no-op returning nil if no report was recorded.
+func Finish(runDir string) (*Report, error) {
+	r, err := Load(runDir)
+	if err != nil || r == nil {
+		return nil, err
+	}
+	r.Finish(time.Now())
+	return r, write(runDir, r)
+}
+
+// Load returns the report recorded in runDir, or nil if there is none
+func Load(runDir string) (*Report, error) {
+	path := filepath.Join(runDir, reportFile)
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	r := &Report{}
+	if err := json.Unmarshal(data, r); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %v", path, err)
+	}
+	return r, nil
+}
+
+// write persists the report and records its summary in the run metadata
+func write(runDir string, r *Report) error {
+	if err := os.MkdirAll(runDir, os.ModePerm); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(filepath.Join(runDir, reportFile), data, 0644); err != nil {
+		return fmt.Errorf("failed to write cost report: %v", err)
+	}
+
+	inventory := make([]string, len(r.Resources))
+	for i, res := range r.Resources {
+		inventory[i] = res.String()
+	}
+	values := map[string]string{
+		metadataInventory:  strings.Join(inventory, ", "),
+		metadataHourlyCost: fmt.Sprintf("%.4f", r.HourlyCost),
+	}
+	if r.Finished != nil {
+		values[metadataEstimatedCost] = fmt.Sprintf("%.4f", r.EstimatedCost)
+	}
+	return metadata.Default().SetAll(values)
+}
@@ -0,0 +1,108 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cost
+
+import (
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"sigs.k8s.io/kubetest2/pkg/metadata"
+)
+
+func TestMachineHourlyPrice(t *testing.T) {
+	testCases := []struct {
+		machineType string
+		region      string
+		price       float64
+		expectError bool
+	}{
+		{machineType: "e2-medium", price: 0.033503},
+		{machineType: "e2-medium", region: "us-central1", price: 0.033503},
+		{machineType: "e2-medium", region: "europe-west2", price: 0.033503 * 1.288},
+		{machineType: "n1-standard-2", region: "asia-northeast1", price: (2*0.031611 + 7.5*0.004237) * 1.286},
+		{machineType: "n1-standard-2", price: 2*0.031611 + 7.5*0.004237},
+		{machineType: "e2-highcpu-8", price: 8*0.021811 + 8*0.002923},
+		{machineType: "n2-custom-4-8192", price: 4*0.031611 + 8*0.004237},
+		{machineType: "custom-2-4096", price: 2*0.031611 + 4*0.004237},
+		{machineType: "a2-highgpu-1g", expectError: true},
+		{machineType: "m1-megamem-96", expectError: true},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.machineType+" "+tc.region, func(t *testing.T) {
+			t.Parallel()
+			price, err := MachineHourlyPrice(tc.machineType, tc.region)
+			if tc.expectError {
+				if err == nil {
+					t.Errorf("expected an error for %q but got none", tc.machineType)
+				}
+				return
+			}
+			if err != nil {
+				t.Errorf("did not expect an error, but got: %v", err)
+			}
+			if math.Abs(price-tc.price) > 1e-9 {
+				t.Errorf("expected price %v, but got %v", tc.price, price)
+			}
+		})
+	}
+}
+
+func TestReport(t *testing.T) {
+	runDir, err := ioutil.TempDir("", "cost")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(runDir)
+	metadata.SetDefault(metadata.NewStore(filepath.Join(runDir, "metadata.json")))
+	defer metadata.SetDefault(nil)
+
+	nodes, err := Instances("node", "cluster-1", "project1", "us-central1", "e2-medium", 3)
+	if err != nil {
+		t.Fatalf("did not expect an error, but got: %v", err)
+	}
+	started := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	r := NewReport(started, []Resource{nodes, GKECluster("cluster-1", "project1", "us-central1")})
+	r.Finish(started.Add(90 * time.Minute))
+
+	expectedHourly := 3*0.033503 + GKEClusterHourlyFee
+	if math.Abs(r.HourlyCost-expectedHourly) > 1e-9 {
+		t.Errorf("expected hourly cost %v, but got %v", expectedHourly, r.HourlyCost)
+	}
+	if math.Abs(r.EstimatedCost-1.5*expectedHourly) > 1e-9 {
+		t.Errorf("expected cost %v, but got %v", 1.5*expectedHourly, r.EstimatedCost)
+	}
+
+	if err := write(runDir, r); err != nil {
+		t.Fatalf("did not expect an error, but got: %v", err)
+	}
+	loaded, err := Load(runDir)
+	if err != nil {
+		t.Fatalf("did not expect an error, but got: %v", err)
+	}
+	if loaded == nil || loaded.EstimatedCost != r.EstimatedCost || len(loaded.Resources) != 2 {
+		t.Errorf("expected loaded report %+v, but got %+v", r, loaded)
+	}
+	if _, err := os.Stat(filepath.Join(runDir, "metadata.json")); err != nil {
+		t.Errorf("expected the metadata to be written, but got: %v", err)
+	}
+}
@@ -0,0 +1,204 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cost
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"sigs.k8s.io/kubetest2/pkg/quota"
+)
+
+// GKEClusterHourlyFee is the hourly cluster management fee charged per GKE cluster
+const GKEClusterHourlyFee = 0.10
+
+type resourcePrice struct {
+	vCPU     float64
+	memoryGB float64
+}
+
+// on-demand hourly prices per vCPU and per GB of memory in us-central1
+// https://cloud.google.com/compute/vm-instance-pricing
+var familyPrices = map[string]resourcePrice{
+	"n1":  {vCPU: 0.031611, memoryGB: 0.004237},
+	"n2":  {vCPU: 0.031611, memoryGB: 0.004237},
+	"n2d": {vCPU: 0.027502, memoryGB: 0.003686},
+	"e2":  {vCPU: 0.021811, memoryGB: 0.002923},
+	"c2":  {vCPU: 0.03398, memoryGB: 0.00455},
+	"c2d": {vCPU: 0.029563, memoryGB: 0.003959},
+	"t2d": {vCPU: 0.027502, memoryGB: 0.003686},
+	"t2a": {vCPU: 0.0231, memoryGB: 0.0029},
+}
+
+// regionPriceFactors are the approximate on-demand prices of the instances in
+// the regions relative to those in us-central1, the regions missing are
+// estimated at the prices of us-central1
+var regionPriceFactors = map[string]float64{
+	"us-central1":             1,
+	"us-east1":                1,
+	"us-west1":                1,
+	"us-east4":                1.126,
+	"us-west2":                1.201,
+	"us-west3":                1.201,
+	"us-west4":                1.126,
+	"northamerica-northeast1": 1.101,
+	"southamerica-east1":      1.588,
+	"europe-west1":            1.1,
+	"europe-north1":           1.101,
+	"europe-west2":            1.288,
+	"europe-west3":            1.288,
+	"europe-west4":            1.101,
+	"europe-west6":            1.397,
+	"asia-east1":              1.158,
+	"asia-east2":              1.398,
+	"asia-northeast1":         1.286,
+	"asia-northeast2":         1.286,
+	"asia-northeast3":         1.286,
+	"asia-south1":             1.201,
+	"asia-southeast1":         1.233,
+	"australia-southeast1":    1.419,
+}
+
+// regionPriceFactor returns the price factor of the region relative to us-central1
+func regionPriceFactor(region string) float64 {
+	if factor, ok := regionPriceFactors[region]; ok {
+		return factor
+	}
+	return 1
+}
+
+// sharedCorePrices are the hourly prices of the shared core machine types
+var sharedCorePrices = map[string]float64{
+	"f1-micro":  0.0076,
+	"g1-small":  0.0257,
+	"e2-micro":  0.008376,
+	"e2-small":  0.016751,
+	"e2-medium": 0.033503,
+}
+
+// memoryPerVCPU returns the GB of memory per vCPU of the predefined machine types
+func memoryPerVCPU(family, class string) (float64, error) {
+	switch class {
+	case "standard":
+		if family == "n1" {
+			return 3.75, nil
+		}
+		return 4, nil
+	case "highmem":
+		if family == "n1" {
+			return 6.5, nil
+		}
+		return 8, nil
+	case "highcpu":
+		if family == "n1" {
+			return 0.9, nil
+		}
+		return 1, nil
+	}
+	return 0, fmt.Errorf("unknown machine class %q", class)
+}
+
+// MachineHourlyPrice returns the estimated on-demand hourly price of a
+// single instance of a GCE machine type in the region
+func MachineHourlyPrice(machineType, region string) (float64, error) {
+	price, err := machineHourlyPrice(machineType)
+	if err != nil {
+		return 0, err
+	}
+	return price * regionPriceFactor(region), nil
+}
+
+// machineHourlyPrice returns the hourly price of the machine type in us-central1
+func machineHourlyPrice(machineType string) (float64, error) {
+	if price, ok := sharedCorePrices[machineType]; ok {
+		return price, nil
+	}
+	cpus, err := quota.CPUsForMachineType(machineType)
+	if err != nil {
+		return 0, err
+	}
+
+	parts := strings.Split(machineType, "-")
+	family := parts[0]
+	if family == "custom" {
+		family = "n1"
+	}
+	price, ok := familyPrices[family]
+	if !ok {
+		return 0, fmt.Errorf("no pricing information for machine type %q", machineType)
+	}
+
+	var memoryGB float64
+	if i := indexOf(parts, "custom"); i >= 0 {
+		// custom machine types are of the form [family-]custom-CPUS-MEMORY[-ext]
+		if i+2 >= len(parts) {
+			return 0, fmt.Errorf("invalid custom machine type %q", machineType)
+		}
+		memoryMB, err := strconv.Atoi(parts[i+2])
+		if err != nil {
+			return 0, fmt.Errorf("invalid memory for custom machine type %q: %v", machineType, err)
+		}
+		memoryGB = float64(memoryMB) / 1024
+	} else {
+		perVCPU, err := memoryPerVCPU(family, parts[1])
+		if err != nil {
+			return 0, fmt.Errorf("no pricing information for machine type %q: %v", machineType, err)
+		}
+		memoryGB = perVCPU * float64(cpus)
+	}
+
+	return price.vCPU*float64(cpus) + price.memoryGB*memoryGB, nil
+}
+
+// Instances returns the resource for count instances of the machine type
+func Instances(kind, name, project, region, machineType string, count int) (Resource, error) {
+	price, err := MachineHourlyPrice(machineType, region)
+	if err != nil {
+		return Resource{}, err
+	}
+	return Resource{
+		Kind:        kind,
+		Name:        name,
+		Project:     project,
+		Region:      region,
+		MachineType: machineType,
+		Count:       count,
+		HourlyCost:  price * float64(count),
+	}, nil
+}
+
+// GKECluster returns the resource for the management fee of a GKE cluster
+func GKECluster(name, project, region string) Resource {
+	return Resource{
+		Kind:       "gke-cluster",
+		Name:       name,
+		Project:    project,
+		Region:     region,
+		Count:      1,
+		HourlyCost: GKEClusterHourlyFee,
+	}
+}
+
+func indexOf(parts []string, s string) int {
+	for i, p := range parts {
+		if p == s {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,175 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package diagnostics captures the workload-level state of a cluster with
+// kubectl, to triage the test failures after the fact.
+package diagnostics
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"k8s.io/klog"
+
+	"sigs.k8s.io/kubetest2/pkg/exec"
+)
+
+// DefaultMaxPods is the maximum number of unhealthy pods described and logged,
+// so that a cluster full of crashing pods does not delay the teardown for long
+const DefaultMaxPods = 50
+
+// requestTimeout bounds every kubectl request, as the cluster may be unhealthy
+const requestTimeout = "--request-timeout=60s"
+
+// Collector writes a diagnostic bundle of the cluster of the kubeconfig to a directory
+type Collector struct {
+	// Kubeconfig of the cluster, the default kubeconfig is used if empty
+	Kubeconfig string
+	// Dir is the directory the bundle is written to
+	Dir string
+	// MaxPods is the maximum number of unhealthy pods described and logged
+	MaxPods int
+}
+
+// Collect writes the bundle, the commands that fail are recorded in the
+// bundle instead of failing the collection
+func (c *Collector) Collect() error {
+	if c.MaxPods == 0 {
+		c.MaxPods = DefaultMaxPods
+	}
+	podsDir := filepath.Join(c.Dir, "pods")
+	if err := os.MkdirAll(podsDir, os.ModePerm); err != nil {
+		return err
+	}
+	for name, args := range map[string][]string{
+		"all.yaml":   {"get", "all", "--all-namespaces", "--output=yaml"},
+		"events.txt": {"get", "events", "--all-namespaces", "--sort-by=.lastTimestamp", "--output=wide"},
+		"nodes.txt":  {"describe", "nodes"},
+	} {
+		c.write(filepath.Join(c.Dir, name), args)
+	}
+
+	out, err := c.output("get", "pods", "--all-namespaces", "--output=json")
+	if err != nil {
+		return fmt.Errorf("failed to list the pods: %v", err)
+	}
+	pods, err := unhealthyPods(out)
+	if err != nil {
+		return err
+	}
+	if len(pods) > c.MaxPods {
+		klog.Warningf("Only collecting the diagnostics of %d of the %d unhealthy pods", c.MaxPods, len(pods))
+		pods = pods[:c.MaxPods]
+	}
+	for _, p := range pods {
+		prefix := filepath.Join(podsDir, p.namespace+"_"+p.name)
+		c.write(prefix+".describe.txt", []string{"describe", "pod", p.name, "--namespace=" + p.namespace})
+		c.write(prefix+".log", []string{"logs", p.name, "--namespace=" + p.namespace, "--all-containers", "--prefix"})
+		if p.restarted {
+			c.write(prefix+".previous.log", []string{"logs", p.name, "--namespace=" + p.namespace, "--all-containers", "--prefix", "--previous"})
+		}
+	}
+	klog.Infof("Collected the diagnostics of the cluster and of %d unhealthy pods in %s", len(pods), c.Dir)
+	return nil
+}
+
+func (c *Collector) command(args ...string) exec.Cmd {
+	cmd := exec.Command("kubectl", append([]string{requestTimeout}, args...)...)
+	if c.Kubeconfig != "" {
+		cmd.SetEnv(append(os.Environ(), "KUBECONFIG="+c.Kubeconfig)...)
+	}
+	return cmd
+}
+
+func (c *Collector) output(args ...string) ([]byte, error) {
+	cmd := c.command(args...)
+	var stderr bytes.Buffer
+	cmd.SetStderr(&stderr)
+	out, err := exec.Output(cmd)
+	if err != nil {
+		return nil, fmt.Errorf("%v: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return out, nil
+}
+
+// write writes the output of kubectl with args to path
+func (c *Collector) write(path string, args []string) {
+	var buf bytes.Buffer
+	cmd := c.command(args...)
+	exec.SetOutput(cmd, &buf, &buf)
+	if err := cmd.Run(); err != nil {
+		fmt.Fprintf(&buf, "\nerror: kubectl %s: %v\n", strings.Join(args, " "), err)
+	}
+	if err := ioutil.WriteFile(path, buf.Bytes(), 0644); err != nil {
+		klog.Warningf("Failed to write the diagnostics to %s: %v", path, err)
+	}
+}
+
+type pod struct {
+	namespace string
+	name      string
+	restarted bool
+}
+
+type podList struct {
+	Items []struct {
+		Metadata struct {
+			Name      string `json:"name"`
+			Namespace string `json:"namespace"`
+		} `json:"metadata"`
+		Status struct {
+			Phase             string `json:"phase"`
+			ContainerStatuses []struct {
+				Ready        bool `json:"ready"`
+				RestartCount int  `json:"restartCount"`
+			} `json:"containerStatuses"`
+		} `json:"status"`
+	} `json:"items"`
+}
+
+// unhealthyPods returns the pods of the kubectl get pods --output=json list that
+// are neither completed nor running with all their containers ready
+func unhealthyPods(list []byte) ([]pod, error) {
+	pods := &podList{}
+	if err := json.Unmarshal(list, pods); err != nil {
+		return nil, fmt.Errorf("failed to parse the pods: %v", err)
+	}
+	var unhealthy []pod
+	for _, item := range pods.Items {
+		ready, restarted := true, false
+		for _, cs := range item.Status.ContainerStatuses {
+			ready = ready && cs.Ready
+			restarted = restarted || cs.RestartCount > 0
+		}
+		switch {
+		case item.Status.Phase == "Succeeded":
+			continue
+		case item.Status.Phase == "Running" && ready:
+			continue
+		}
+		unhealthy = append(unhealthy, pod{
+			namespace: item.Metadata.Namespace,
+			name:      item.Metadata.Name,
+			restarted: restarted,
+		})
+	}
+	return unhealthy, nil
+}
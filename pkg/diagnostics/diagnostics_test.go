@@ -0,0 +1,48 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package diagnostics
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestUnhealthyPods(t *testing.T) {
+	list := []byte(`{"items": [
+  {"metadata": {"namespace": "default", "name": "running"}, "status": {"phase": "Running", "containerStatuses": [{"ready": true}]}},
+  {"metadata": {"namespace": "default", "name": "completed"}, "status": {"phase": "Succeeded", "containerStatuses": [{"ready": false}]}},
+  {"metadata": {"namespace": "default", "name": "crashing"}, "status": {"phase": "Running", "containerStatuses": [{"ready": true}, {"ready": false, "restartCount": 5}]}},
+  {"metadata": {"namespace": "kube-system", "name": "pending"}, "status": {"phase": "Pending"}},
+  {"metadata": {"namespace": "e2e-1234", "name": "failed"}, "status": {"phase": "Failed", "containerStatuses": [{"ready": false}]}}
+]}`)
+	expected := []pod{
+		{namespace: "default", name: "crashing", restarted: true},
+		{namespace: "kube-system", name: "pending"},
+		{namespace: "e2e-1234", name: "failed"},
+	}
+	actual, err := unhealthyPods(list)
+	if err != nil {
+		t.Fatalf("did not expect an error, but got: %v", err)
+	}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("expected %+v, but got %+v", expected, actual)
+	}
+
+	if _, err := unhealthyPods([]byte("not json")); err == nil {
+		t.Errorf("expected an error for invalid json")
+	}
+}
@@ -0,0 +1,80 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package doctor runs the preflight checks of `kubetest2 doctor`, so that a
+// missing tool or credential fails in seconds with a fix, instead of with a
+// cryptic error well into a run.
+package doctor
+
+import (
+	"fmt"
+	"io"
+	"os/exec"
+	"strings"
+
+	"sigs.k8s.io/kubetest2/pkg/types"
+)
+
+// Run runs the checks in order, printing the result of each and how to fix
+// the failed ones, and returns an error if any failed
+func Run(w io.Writer, checks []types.Check) error {
+	failed := 0
+	for i, check := range checks {
+		detail, err := check.Run()
+		if err == nil {
+			if detail != "" {
+				fmt.Fprintf(w, "[ok]   %s: %s\n", check.Name, detail)
+			} else {
+				fmt.Fprintf(w, "[ok]   %s\n", check.Name)
+			}
+			continue
+		}
+		failed++
+		fmt.Fprintf(w, "[fail] %s: %v\n", check.Name, err)
+		if check.Fix != "" {
+			fmt.Fprintf(w, "       fix: %s\n", indent(check.Fix))
+		}
+		if check.Fatal && i < len(checks)-1 {
+			fmt.Fprintf(w, "[skip] the remaining %d checks\n", len(checks)-i-1)
+			break
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d checks failed", failed, len(checks))
+	}
+	return nil
+}
+
+// indent aligns the lines of a multi line fix
+func indent(fix string) string {
+	return strings.ReplaceAll(fix, "\n", "\n            ")
+}
+
+// BinaryCheck returns a check that the binary is on PATH
+func BinaryCheck(name, fix string, fatal bool) types.Check {
+	return types.Check{
+		Name: name + " is on PATH",
+		Run: func() (string, error) {
+			path, err := exec.LookPath(name)
+			if err != nil {
+				return "", fmt.Errorf("%s not found", name)
+			}
+			return path, nil
+		},
+		Fix:   fix,
+		Fatal: fatal,
+	}
+}
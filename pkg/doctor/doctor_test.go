@@ -0,0 +1,79 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package doctor
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"sigs.k8s.io/kubetest2/pkg/types"
+)
+
+func passing(name, detail string) types.Check {
+	return types.Check{Name: name, Run: func() (string, error) { return detail, nil }}
+}
+
+func failing(name string, fatal bool) types.Check {
+	return types.Check{Name: name, Run: func() (string, error) { return "", errors.New("broken") }, Fix: "repair it\nthen retry", Fatal: fatal}
+}
+
+func TestRun(t *testing.T) {
+	testCases := []struct {
+		name        string
+		checks      []types.Check
+		expected    string
+		expectError bool
+	}{
+		{
+			name:     "all passing",
+			checks:   []types.Check{passing("tool", "v1.0"), passing("auth", "")},
+			expected: "[ok]   tool: v1.0\n[ok]   auth\n",
+		},
+		{
+			name:   "failing",
+			checks: []types.Check{failing("tool", false), passing("auth", "")},
+			expected: "[fail] tool: broken\n" +
+				"       fix: repair it\n" +
+				"            then retry\n" +
+				"[ok]   auth\n",
+			expectError: true,
+		},
+		{
+			name:   "fatal failure",
+			checks: []types.Check{failing("tool", true), passing("auth", ""), passing("api", "")},
+			expected: "[fail] tool: broken\n" +
+				"       fix: repair it\n" +
+				"            then retry\n" +
+				"[skip] the remaining 2 checks\n",
+			expectError: true,
+		},
+	}
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			var out bytes.Buffer
+			err := Run(&out, tc.checks)
+			if (err != nil) != tc.expectError {
+				t.Fatalf("expected error: %v, but got: %v", tc.expectError, err)
+			}
+			if out.String() != tc.expected {
+				t.Errorf("expected output:\n%s\ngot:\n%s", tc.expected, out.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,184 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package events emits the progress of a kubetest2 run as a stream of line
+// delimited JSON events, for dashboards and IDE integrations to render the
+// run live instead of parsing its interleaved logs.
+package events
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"k8s.io/klog"
+
+	"sigs.k8s.io/kubetest2/pkg/secrets"
+)
+
+// Type is the type of an event
+type Type string
+
+// The types of events
+const (
+	// PhaseStart is emitted when a phase or a step of the run starts e.g. Up
+	PhaseStart Type = "phase-start"
+	// PhaseEnd is emitted when a phase ends, with its result
+	PhaseEnd Type = "phase-end"
+	// CommandRun is emitted when an external command exits
+	CommandRun Type = "command"
+	// Progress is emitted as the run progresses within a phase e.g. per tester
+	Progress Type = "progress"
+	// Warning is emitted for the problems that do not fail the run
+	Warning Type = "warning"
+	// Result is the last event of the run, with its result
+	Result Type = "result"
+)
+
+// The results of the phase-end and result events
+const (
+	Passed = "passed"
+	Failed = "failed"
+)
+
+// Event is a line of the stream
+type Event struct {
+	Type  Type      `json:"type"`
+	Time  time.Time `json:"time"`
+	RunID string    `json:"runId,omitempty"`
+	// Phase is the name of the phase of the phase events
+	Phase string `json:"phase,omitempty"`
+	// Command is the redacted command line of the command events
+	Command         string  `json:"command,omitempty"`
+	ExitCode        int     `json:"exitCode,omitempty"`
+	DurationSeconds float64 `json:"durationSeconds,omitempty"`
+	Message         string  `json:"message,omitempty"`
+	Result          string  `json:"result,omitempty"`
+	Error           string  `json:"error,omitempty"`
+}
+
+// Emitter writes the events of a run, a nil emitter is disabled.
+// It is safe for concurrent use.
+type Emitter struct {
+	mu    sync.Mutex
+	ws    []io.Writer
+	runID string
+}
+
+// NewEmitter returns an emitter writing the events of the run to the writers
+func NewEmitter(runID string, ws ...io.Writer) *Emitter {
+	return &Emitter{ws: ws, runID: runID}
+}
+
+var (
+	defaultMu      sync.Mutex
+	defaultEmitter *Emitter
+)
+
+// SetDefault sets the emitter returned by Default, nil disables the events
+func SetDefault(e *Emitter) {
+	defaultMu.Lock()
+	defer defaultMu.Unlock()
+	defaultEmitter = e
+}
+
+// Default returns the emitter of the run, nil unless set with SetDefault
+func Default() *Emitter {
+	defaultMu.Lock()
+	defer defaultMu.Unlock()
+	return defaultEmitter
+}
+
+// Emit writes the event as a line of JSON to the writers of the emitter
+func (e *Emitter) Emit(event Event) {
+	if e == nil {
+		return
+	}
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+	event.RunID = e.runID
+	// e.g. the tokens in the command lines and their errors
+	event.Command = secrets.Redact(event.Command)
+	event.Error = secrets.Redact(event.Error)
+	event.Message = secrets.Redact(event.Message)
+	data, err := json.Marshal(event)
+	if err != nil {
+		klog.Warningf("Failed to encode the %s event: %v", event.Type, err)
+		return
+	}
+	data = append(data, '\n')
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for _, w := range e.ws {
+		if _, err := w.Write(data); err != nil {
+			klog.Warningf("Failed to write the %s event: %v", event.Type, err)
+		}
+	}
+}
+
+// PhaseStarted emits the phase-start event of the phase
+func (e *Emitter) PhaseStarted(phase string) {
+	e.Emit(Event{Type: PhaseStart, Phase: phase})
+}
+
+// PhaseEnded emits the phase-end event of the phase with its result
+func (e *Emitter) PhaseEnded(phase string, duration time.Duration, err error) {
+	event := Event{Type: PhaseEnd, Phase: phase, DurationSeconds: duration.Seconds()}
+	setResult(&event, err)
+	e.Emit(event)
+}
+
+// CommandExited emits the command event of the command line that exited
+// with the exit code
+func (e *Emitter) CommandExited(command string, exitCode int, duration time.Duration, err error) {
+	event := Event{Type: CommandRun, Command: command, ExitCode: exitCode, DurationSeconds: duration.Seconds()}
+	if err != nil {
+		event.Error = err.Error()
+	}
+	e.Emit(event)
+}
+
+// RunFinished emits the result event of the run
+func (e *Emitter) RunFinished(err error) {
+	event := Event{Type: Result}
+	setResult(&event, err)
+	e.Emit(event)
+}
+
+func setResult(event *Event, err error) {
+	event.Result = Passed
+	if err != nil {
+		event.Result = Failed
+		event.Error = err.Error()
+	}
+}
+
+// Progressf logs the progress message and emits it as a progress event
+func Progressf(format string, args ...interface{}) {
+	message := secrets.Redact(fmt.Sprintf(format, args...))
+	klog.InfoDepth(1, message)
+	Default().Emit(Event{Type: Progress, Message: message})
+}
+
+// Warningf logs the warning and emits it as a warning event
+func Warningf(format string, args ...interface{}) {
+	message := secrets.Redact(fmt.Sprintf(format, args...))
+	klog.WarningDepth(1, message)
+	Default().Emit(Event{Type: Warning, Message: message})
+}
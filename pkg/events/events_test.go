@@ -0,0 +1,83 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package events
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"sigs.k8s.io/kubetest2/pkg/secrets"
+)
+
+func TestDisabledEmitter(t *testing.T) {
+	var e *Emitter
+	// a nil emitter is a no-op
+	e.PhaseStarted("Up")
+	e.PhaseEnded("Up", time.Second, nil)
+	e.RunFinished(nil)
+	SetDefault(nil)
+	Progressf("Running tester %d of %d", 1, 2)
+}
+
+func TestEmitter(t *testing.T) {
+	var out, file bytes.Buffer
+	e := NewEmitter("run-1", &out, &file)
+	SetDefault(e)
+	defer SetDefault(nil)
+
+	e.PhaseStarted("Up")
+	e.CommandExited("gcloud container clusters create c1 --auth-token=hunter2", 1, 2*time.Second, errors.New("exit status 1"))
+	Warningf("Retrying the tester after a retryable failure: %v", "flake with password=hunter2")
+	e.PhaseEnded("Up", time.Minute, errors.New("error creating the clusters"))
+	e.RunFinished(errors.New("error creating the clusters"))
+
+	if out.String() != file.String() {
+		t.Errorf("expected the same events to be written to all the writers")
+	}
+	expected := []Event{
+		{Type: PhaseStart, Phase: "Up"},
+		{Type: CommandRun, Command: "gcloud container clusters create c1 --auth-token=" + secrets.Redacted, ExitCode: 1, DurationSeconds: 2, Error: "exit status 1"},
+		{Type: Warning, Message: "Retrying the tester after a retryable failure: flake with password=" + secrets.Redacted},
+		{Type: PhaseEnd, Phase: "Up", DurationSeconds: 60, Result: Failed, Error: "error creating the clusters"},
+		{Type: Result, Result: Failed, Error: "error creating the clusters"},
+	}
+	scanner := bufio.NewScanner(&out)
+	i := 0
+	for ; scanner.Scan(); i++ {
+		var event Event
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			t.Fatalf("failed to parse event %q: %v", scanner.Text(), err)
+		}
+		if event.RunID != "run-1" || event.Time.IsZero() {
+			t.Errorf("expected the run id and the time in event %q", scanner.Text())
+		}
+		if i >= len(expected) {
+			continue
+		}
+		event.RunID, event.Time = "", time.Time{}
+		if event != expected[i] {
+			t.Errorf("expected event %d to be %+v, but got %+v", i, expected[i], event)
+		}
+	}
+	if i != len(expected) {
+		t.Errorf("expected %d events, but got %d", len(expected), i)
+	}
+}
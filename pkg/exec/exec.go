@@ -25,6 +25,8 @@ import (
 	"os"
 
 	shell "github.com/kballard/go-shellquote"
+
+	"sigs.k8s.io/kubetest2/pkg/secrets"
 )
 
 // Cmd abstracts over running a command somewhere, this is useful for testing
@@ -122,12 +124,30 @@ func SetOutput(cmd Cmd, stdoutWriter, stderrWriter io.Writer) {
 	cmd.SetStderr(stderrWriter)
 }
 
-// InheritOutput sets cmd's output to write to the current process's stdout and stderr
+// InheritOutput sets cmd's output to write to the current process's stdout and stderr,
+// redacting the secrets e.g. from the verbose output of gcloud
 func InheritOutput(cmd Cmd) {
+	cmd.SetStderr(secrets.NewWriter(os.Stderr))
+	cmd.SetStdout(secrets.NewWriter(os.Stdout))
+}
+
+// InheritRawOutput sets cmd's output to the current process's stdout and
+// stderr as is, e.g. for the testers, whose output is not redacted line by
+// line and may be a terminal
+func InheritRawOutput(cmd Cmd) {
 	cmd.SetStderr(os.Stderr)
 	cmd.SetStdout(os.Stdout)
 }
 
+// flushOutput writes out the output buffered by the writers of InheritOutput
+func flushOutput(writers ...io.Writer) {
+	for _, w := range writers {
+		if sw, ok := w.(*secrets.Writer); ok {
+			_ = sw.Flush()
+		}
+	}
+}
+
 // NoOutput ignores all output from the command.
 func NoOutput(cmd Cmd) {
 	cmd.SetStdout(ioutil.Discard)
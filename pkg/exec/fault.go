@@ -0,0 +1,53 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package exec
+
+import (
+	"sync"
+)
+
+// FaultInjector simulates the failure of commands, for self-testing the error
+// handling of their callers, e.g. retries, without real failures
+type FaultInjector interface {
+	// Inject returns the output and the error simulating the failure of the
+	// command of the args, or a nil error to run the command
+	Inject(args []string) (output string, err error)
+}
+
+var (
+	faultMu              sync.Mutex
+	defaultFaultInjector FaultInjector
+)
+
+// SetFaultInjector sets the injector of the simulated failures of the
+// commands, nil disables the injection
+func SetFaultInjector(f FaultInjector) {
+	faultMu.Lock()
+	defer faultMu.Unlock()
+	defaultFaultInjector = f
+}
+
+// injectFault returns the simulated failure of the command, if any
+func injectFault(args []string) (string, error) {
+	faultMu.Lock()
+	f := defaultFaultInjector
+	faultMu.Unlock()
+	if f == nil {
+		return "", nil
+	}
+	return f.Inject(args)
+}
@@ -20,14 +20,32 @@ import (
 	"context"
 	"io"
 	osexec "os/exec"
+	"path/filepath"
 	"strings"
+	"time"
 
 	"k8s.io/klog"
+
+	"sigs.k8s.io/kubetest2/pkg/events"
+	"sigs.k8s.io/kubetest2/pkg/ratelimit"
+	"sigs.k8s.io/kubetest2/pkg/trace"
 )
 
+// rateLimitedCommands are the commands calling the GCP APIs, which are
+// subject to the rate limit of the run and retried when throttled
+var rateLimitedCommands = map[string]bool{
+	"gcloud": true,
+	"gsutil": true,
+}
+
+// throttledOutputLimit is the number of trailing bytes of the stderr of
+// a failed command checked for throttling errors
+const throttledOutputLimit = 4096
+
 // LocalCmd wraps os/exec.Cmd, implementing the exec.Cmd interface
 type LocalCmd struct {
 	*osexec.Cmd
+	ctx context.Context
 }
 
 var _ Cmd = &LocalCmd{}
@@ -39,7 +57,7 @@ var _ Cmder = &LocalCmder{}
 
 // Command returns a new exec.Cmd backed by Cmd
 func (c *LocalCmder) Command(name string, arg ...string) Cmd {
-	klog.V(2).Infof("⚙️ %s %s", name, strings.Join(arg, " "))
+	klog.V(2).Infof("⚙️ %s %s", name, Redact(strings.Join(arg, " ")))
 	return &LocalCmd{
 		Cmd: osexec.Command(name, arg...),
 	}
@@ -47,9 +65,10 @@ func (c *LocalCmder) Command(name string, arg ...string) Cmd {
 
 // CommandContext returns a new exec.Cmd with the context, backed by Cmd
 func (c *LocalCmder) CommandContext(ctx context.Context, name string, arg ...string) Cmd {
-	klog.V(2).Infof("⚙️ %s %s", name, strings.Join(arg, " "))
+	klog.V(2).Infof("⚙️ %s %s", name, Redact(strings.Join(arg, " ")))
 	return &LocalCmd{
 		Cmd: osexec.CommandContext(ctx, name, arg...),
+		ctx: ctx,
 	}
 }
 
@@ -82,7 +101,96 @@ func (cmd *LocalCmd) SetDir(dir string) Cmd {
 	return cmd
 }
 
-// Run runs
+// Run runs, recording the command as a span of the run's trace, in the transcript and as an event.
+// The commands calling the GCP APIs wait for the rate limit of the run, and are
+// retried with backoff when throttled.
 func (cmd *LocalCmd) Run() error {
-	return cmd.Cmd.Run()
+	defer flushOutput(cmd.Stdout, cmd.Stderr)
+	if !rateLimitedCommands[filepath.Base(cmd.Args[0])] {
+		return cmd.run()
+	}
+	backoff := ratelimit.DefaultBackoff
+	for attempt := 1; ; attempt++ {
+		ratelimit.Default().Wait()
+		stdout, stderr := cmd.Stdout, cmd.Stderr
+		output := &tailBuffer{limit: throttledOutputLimit}
+		switch {
+		case stderr == nil:
+			cmd.Stderr = output
+		case sameWriter(stdout, stderr):
+			// still the same writer, which os/exec writes both to from a single
+			// pipe, for the combined output to keep its order
+			w := io.MultiWriter(stderr, output)
+			cmd.Stdout, cmd.Stderr = w, w
+		default:
+			cmd.Stderr = io.MultiWriter(stderr, output)
+		}
+		err := cmd.run()
+		// the stdin of the command cannot be replayed
+		if err == nil || cmd.Stdin != nil || attempt >= backoff.Attempts || !ratelimit.IsThrottled(output.String()) {
+			return err
+		}
+		delay := backoff.Delay(attempt)
+		klog.Warningf("%s was throttled, retrying in %v (attempt %d/%d)", Redact(strings.Join(cmd.Args, " ")), delay.Round(time.Millisecond), attempt+1, backoff.Attempts)
+		time.Sleep(delay)
+		cmd.reset(stdout, stderr)
+	}
+}
+
+// sameWriter returns true if the writers are the same, like os/exec compares
+// stdout and stderr, recovering from comparing writers of uncomparable types
+func sameWriter(a, b io.Writer) (same bool) {
+	defer func() {
+		if recover() != nil {
+			same = false
+		}
+	}()
+	return a == b
+}
+
+// reset replaces the command that ran with an identical one, to run it again
+func (cmd *LocalCmd) reset(stdout, stderr io.Writer) {
+	var next *osexec.Cmd
+	if cmd.ctx != nil {
+		next = osexec.CommandContext(cmd.ctx, cmd.Path, cmd.Args[1:]...)
+	} else {
+		next = osexec.Command(cmd.Path, cmd.Args[1:]...)
+	}
+	next.Args = cmd.Args
+	next.Env = cmd.Env
+	next.Dir = cmd.Dir
+	next.Stdout = stdout
+	next.Stderr = stderr
+	next.ExtraFiles = cmd.ExtraFiles
+	next.SysProcAttr = cmd.SysProcAttr
+	cmd.Cmd = next
+}
+
+func (cmd *LocalCmd) run() error {
+	command := Redact(strings.Join(cmd.Args, " "))
+	span := trace.Default().StartSpan("exec "+filepath.Base(cmd.Args[0]), map[string]string{
+		"exec.command": command,
+		"exec.dir":     cmd.Dir,
+	})
+	start := time.Now()
+	recording := CurrentTranscript().Start(cmd.Cmd)
+	err := cmd.runOrInjectFault()
+	recording.Finish(err)
+	span.End(err)
+	events.Default().CommandExited(command, exitCode(err), time.Since(start), err)
+	return err
+}
+
+// runOrInjectFault runs the command, unless its failure is simulated in
+// which case the simulated output is written to its stderr
+func (cmd *LocalCmd) runOrInjectFault() error {
+	output, err := injectFault(cmd.Args)
+	if err == nil {
+		return cmd.Cmd.Run()
+	}
+	klog.Warningf("Simulating the failure of %s: %v", Redact(strings.Join(cmd.Args, " ")), err)
+	if cmd.Stderr != nil {
+		_, _ = io.WriteString(cmd.Stderr, output)
+	}
+	return err
 }
@@ -0,0 +1,118 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package exec
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"sigs.k8s.io/kubetest2/pkg/ratelimit"
+)
+
+func TestRunRetriesThrottledCommands(t *testing.T) {
+	dir, err := ioutil.TempDir("", "throttled")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	// a fake gcloud which is throttled on its first invocation
+	gcloud := filepath.Join(dir, "gcloud")
+	script := "#!/bin/sh\nif [ ! -f " + dir + "/called ]; then touch " + dir + "/called; echo 'ResponseError: code=429, message=Too Many Requests' >&2; exit 1; fi\necho ok\n"
+	if err := ioutil.WriteFile(gcloud, []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	backoff := ratelimit.DefaultBackoff
+	defer func() { ratelimit.DefaultBackoff = backoff }()
+	ratelimit.DefaultBackoff = ratelimit.Backoff{Initial: time.Millisecond, Max: time.Millisecond, Attempts: 2}
+
+	out, err := Output(Command(gcloud, "container", "clusters", "list"))
+	if err != nil {
+		t.Fatalf("expected the throttled command to be retried, but got: %v", err)
+	}
+	if strings.TrimSpace(string(out)) != "ok" {
+		t.Errorf("expected the output of the retried command, but got %q", out)
+	}
+
+	// commands not calling the GCP APIs are not retried
+	sh := filepath.Join(dir, "throttled.sh")
+	if err := ioutil.WriteFile(sh, []byte("#!/bin/sh\necho 'code=429' >&2\nexit 1\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := Command(sh).Run(); err == nil {
+		t.Errorf("expected the command to fail")
+	}
+}
+
+func TestRunKeepsCombinedOutput(t *testing.T) {
+	dir, err := ioutil.TempDir("", "combined")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	gcloud := filepath.Join(dir, "gcloud")
+	if err := ioutil.WriteFile(gcloud, []byte("#!/bin/sh\necho out\necho err >&2\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	var combined bytes.Buffer
+	cmd := Command(gcloud, "container", "clusters", "list").(*LocalCmd)
+	cmd.SetStdout(&combined)
+	cmd.SetStderr(&combined)
+	if err := cmd.Run(); err != nil {
+		t.Fatalf("did not expect an error, but got: %v", err)
+	}
+	if !sameWriter(cmd.Stdout, cmd.Stderr) {
+		t.Errorf("expected stdout and stderr to still be the same writer")
+	}
+	if combined.String() != "out\nerr\n" {
+		t.Errorf("expected the combined output in order, but got %q", combined.String())
+	}
+}
+
+type fakeFaultInjector struct{}
+
+func (fakeFaultInjector) Inject(args []string) (string, error) {
+	if len(args) > 1 && args[1] == "fail" {
+		return "simulated output\n", errors.New("simulated failure")
+	}
+	return "", nil
+}
+
+func TestRunInjectsFaults(t *testing.T) {
+	SetFaultInjector(fakeFaultInjector{})
+	defer SetFaultInjector(nil)
+
+	lines, err := CombinedOutputLines(Command("echo", "fail"))
+	if err == nil || err.Error() != "simulated failure" {
+		t.Errorf("expected the simulated failure, but got: %v", err)
+	}
+	if len(lines) != 1 || lines[0] != "simulated output" {
+		t.Errorf("expected the simulated output instead of the output of the command, but got %q", lines)
+	}
+
+	lines, err = CombinedOutputLines(Command("echo", "ok"))
+	if err != nil || len(lines) != 1 || lines[0] != "ok" {
+		t.Errorf("expected the command to run, but got %q, %v", lines, err)
+	}
+}
@@ -0,0 +1,194 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package exec
+
+import (
+	"fmt"
+	"io"
+	"os"
+	osexec "os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"sigs.k8s.io/kubetest2/pkg/secrets"
+)
+
+// DefaultTranscriptOutputLimit is the number of trailing bytes of the output
+// of a command that are kept in the transcript
+const DefaultTranscriptOutputLimit = 4096
+
+// Transcript records the commands executed by the run along with their
+// duration, exit code and the tail of their output, redacting secrets.
+// It is safe for concurrent use.
+type Transcript struct {
+	mu          sync.Mutex
+	w           io.Writer
+	outputLimit int
+}
+
+// NewTranscript returns a transcript writing the records to w
+func NewTranscript(w io.Writer) *Transcript {
+	return &Transcript{
+		w:           w,
+		outputLimit: DefaultTranscriptOutputLimit,
+	}
+}
+
+var (
+	transcriptMu      sync.Mutex
+	defaultTranscript *Transcript
+)
+
+// SetTranscript sets the transcript the commands are recorded to,
+// nil disables the recording
+func SetTranscript(t *Transcript) {
+	transcriptMu.Lock()
+	defer transcriptMu.Unlock()
+	defaultTranscript = t
+}
+
+// CurrentTranscript returns the transcript the commands are recorded to, if any
+func CurrentTranscript() *Transcript {
+	transcriptMu.Lock()
+	defer transcriptMu.Unlock()
+	return defaultTranscript
+}
+
+// Recording is a command being recorded, a nil recording is a no-op
+type Recording struct {
+	transcript *Transcript
+	args       []string
+	dir        string
+	start      time.Time
+	output     *tailBuffer
+}
+
+// Start starts recording the command, it tees the output of the command if it
+// is captured by the caller, the output of commands writing directly to a
+// file (e.g. the stdout of kubetest2) is not recorded, as wrapping it would
+// change what the command is attached to, nor the redacted output of
+// kubetest2 from InheritOutput
+func (t *Transcript) Start(cmd *osexec.Cmd) *Recording {
+	if t == nil {
+		return nil
+	}
+	r := &Recording{
+		transcript: t,
+		args:       cmd.Args,
+		dir:        cmd.Dir,
+		start:      time.Now(),
+		output:     &tailBuffer{limit: t.outputLimit},
+	}
+	stdout, stderr := cmd.Stdout, cmd.Stderr
+	cmd.Stdout = r.tee(stdout)
+	// the same writer may be used for both e.g. with CombinedOutputLines,
+	// keep it that way so that the output is not interleaved differently
+	if sameWriter(stderr, stdout) {
+		cmd.Stderr = cmd.Stdout
+	} else {
+		cmd.Stderr = r.tee(stderr)
+	}
+	return r
+}
+
+func (r *Recording) tee(w io.Writer) io.Writer {
+	if w == nil {
+		return nil
+	}
+	switch w.(type) {
+	case *os.File, *secrets.Writer:
+		return w
+	}
+	return io.MultiWriter(w, r.output)
+}
+
+// Finish records the command with the result of running it
+func (r *Recording) Finish(err error) {
+	if r == nil {
+		return
+	}
+	r.transcript.write(r, time.Since(r.start), err)
+}
+
+func exitCode(err error) int {
+	if err == nil {
+		return 0
+	}
+	if ee, ok := err.(*osexec.ExitError); ok {
+		return ee.ExitCode()
+	}
+	return -1
+}
+
+func (t *Transcript) write(r *Recording, duration time.Duration, err error) {
+	var b strings.Builder
+	fmt.Fprintf(&b, "=== %s (%s) exit code %d\n", r.start.UTC().Format(time.RFC3339), duration.Round(time.Millisecond), exitCode(err))
+	if r.dir != "" {
+		fmt.Fprintf(&b, "$ cd %s && %s\n", r.dir, Redact(strings.Join(r.args, " ")))
+	} else {
+		fmt.Fprintf(&b, "$ %s\n", Redact(strings.Join(r.args, " ")))
+	}
+	if err != nil {
+		fmt.Fprintf(&b, "error: %s\n", Redact(err.Error()))
+	}
+	if output := r.output.String(); output != "" {
+		if r.output.truncated > 0 {
+			fmt.Fprintf(&b, "... (%d bytes truncated)\n", r.output.truncated)
+		}
+		b.WriteString(Redact(output))
+		if !strings.HasSuffix(output, "\n") {
+			b.WriteString("\n")
+		}
+	}
+	b.WriteString("\n")
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	// failing to write the transcript must not fail the command
+	_, _ = io.WriteString(t.w, b.String())
+}
+
+// tailBuffer keeps the last limit bytes written to it
+type tailBuffer struct {
+	mu        sync.Mutex
+	limit     int
+	buf       []byte
+	truncated int
+}
+
+func (b *tailBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.buf = append(b.buf, p...)
+	if over := len(b.buf) - b.limit; over > 0 {
+		b.truncated += over
+		b.buf = append([]byte{}, b.buf[over:]...)
+	}
+	return len(p), nil
+}
+
+func (b *tailBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return string(b.buf)
+}
+
+// Redact replaces the secrets found in s with <redacted>
+func Redact(s string) string {
+	return secrets.Redact(s)
+}
@@ -0,0 +1,102 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package featuregates parses the --kube-feature-gates and --runtime-config
+// of kubetest2, which the deployers translate to the configuration of the
+// clusters they bring up, see types.DeployerWithFeatureGates.
+package featuregates
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ParseFeatureGates parses NAME=true|false feature gates, each value being a
+// comma separated list of them as for the --feature-gates of the components
+func ParseFeatureGates(values []string) (map[string]bool, error) {
+	gates := map[string]bool{}
+	for _, pair := range splitPairs(values) {
+		kv := strings.SplitN(pair, "=", 2)
+		if len(kv) != 2 || kv[0] == "" {
+			return nil, fmt.Errorf("invalid feature gate %q, expected NAME=true or NAME=false", pair)
+		}
+		enabled, err := strconv.ParseBool(kv[1])
+		if err != nil {
+			return nil, fmt.Errorf("invalid feature gate %q, expected NAME=true or NAME=false", pair)
+		}
+		if previous, ok := gates[kv[0]]; ok && previous != enabled {
+			return nil, fmt.Errorf("feature gate %s is both enabled and disabled", kv[0])
+		}
+		gates[kv[0]] = enabled
+	}
+	return gates, nil
+}
+
+// ParseRuntimeConfig parses KEY[=VALUE] runtime config e.g. api/all=true or
+// batch/v2alpha1, the value defaulting to true as for the --runtime-config
+// of the API server
+func ParseRuntimeConfig(values []string) (map[string]string, error) {
+	config := map[string]string{}
+	for _, pair := range splitPairs(values) {
+		kv := strings.SplitN(pair, "=", 2)
+		if kv[0] == "" {
+			return nil, fmt.Errorf("invalid runtime config %q, expected KEY=VALUE", pair)
+		}
+		value := "true"
+		if len(kv) == 2 {
+			value = kv[1]
+		}
+		config[kv[0]] = value
+	}
+	return config, nil
+}
+
+// splitPairs splits the comma separated values, ignoring the empty ones
+func splitPairs(values []string) []string {
+	var pairs []string
+	for _, value := range values {
+		for _, pair := range strings.Split(value, ",") {
+			if pair = strings.TrimSpace(pair); pair != "" {
+				pairs = append(pairs, pair)
+			}
+		}
+	}
+	return pairs
+}
+
+// FormatFeatureGates formats the feature gates as the sorted comma separated
+// NAME=true|false list of the --feature-gates of the components
+func FormatFeatureGates(gates map[string]bool) string {
+	pairs := make([]string, 0, len(gates))
+	for name, enabled := range gates {
+		pairs = append(pairs, fmt.Sprintf("%s=%t", name, enabled))
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
+
+// FormatRuntimeConfig formats the runtime config as the sorted comma
+// separated KEY=VALUE list of the --runtime-config of the API server
+func FormatRuntimeConfig(config map[string]string) string {
+	pairs := make([]string, 0, len(config))
+	for key, value := range config {
+		pairs = append(pairs, key+"="+value)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
@@ -0,0 +1,94 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package featuregates
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseFeatureGates(t *testing.T) {
+	testCases := []struct {
+		name        string
+		values      []string
+		expected    map[string]bool
+		expectError bool
+	}{
+		{
+			name:     "none",
+			expected: map[string]bool{},
+		},
+		{
+			name:     "comma separated and repeated",
+			values:   []string{"A=true,B=false", " C=True ", ""},
+			expected: map[string]bool{"A": true, "B": false, "C": true},
+		},
+		{
+			name:        "no value",
+			values:      []string{"A"},
+			expectError: true,
+		},
+		{
+			name:        "not a bool",
+			values:      []string{"A=yes"},
+			expectError: true,
+		},
+		{
+			name:        "conflicting",
+			values:      []string{"A=true", "A=false"},
+			expectError: true,
+		},
+	}
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			gates, err := ParseFeatureGates(tc.values)
+			if (err != nil) != tc.expectError {
+				t.Fatalf("expected error: %v, but got: %v", tc.expectError, err)
+			}
+			if !tc.expectError && !reflect.DeepEqual(gates, tc.expected) {
+				t.Errorf("expected %v, got %v", tc.expected, gates)
+			}
+		})
+	}
+}
+
+func TestParseRuntimeConfig(t *testing.T) {
+	config, err := ParseRuntimeConfig([]string{"api/all=false,batch/v2alpha1", "api/beta=true"})
+	if err != nil {
+		t.Fatalf("did not expect an error, but got: %v", err)
+	}
+	expected := map[string]string{"api/all": "false", "batch/v2alpha1": "true", "api/beta": "true"}
+	if !reflect.DeepEqual(config, expected) {
+		t.Errorf("expected %v, got %v", expected, config)
+	}
+	if _, err := ParseRuntimeConfig([]string{"=true"}); err == nil {
+		t.Errorf("expected an error for an empty key")
+	}
+}
+
+func TestFormat(t *testing.T) {
+	if s := FormatFeatureGates(map[string]bool{"B": false, "A": true}); s != "A=true,B=false" {
+		t.Errorf("unexpected feature gates %q", s)
+	}
+	if s := FormatRuntimeConfig(map[string]string{"batch/v2alpha1": "true", "api/all": "false"}); s != "api/all=false,batch/v2alpha1=true" {
+		t.Errorf("unexpected runtime config %q", s)
+	}
+	if s := FormatFeatureGates(nil); s != "" {
+		t.Errorf("expected no feature gates, got %q", s)
+	}
+}
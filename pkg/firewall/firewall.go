@@ -0,0 +1,241 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package firewall manages the GCP firewall rules created by kubetest2
+// deployers for e2e testing, keeping track of every rule created during
+// a run so that they can be verified and cleaned up on Down().
+package firewall
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"k8s.io/klog"
+
+	"sigs.k8s.io/kubetest2/pkg/exec"
+	"sigs.k8s.io/kubetest2/pkg/metadata"
+)
+
+const (
+	// stateFile is the name of the file in the run dir used to persist the
+	// created firewall rules across kubetest2 invocations (e.g. --up then --down)
+	stateFile = "firewall-rules.json"
+	// metadataKey is the key the created rules are recorded under in metadata.json
+	metadataKey = "firewall-rules"
+)
+
+// Rule describes a firewall rule to be created in a GCP project
+type Rule struct {
+	Name         string   `json:"name"`
+	Project      string   `json:"project"`
+	Network      string   `json:"network"`
+	Allow        string   `json:"allow,omitempty"`
+	Direction    string   `json:"direction,omitempty"`
+	Description  string   `json:"description,omitempty"`
+	TargetTags   []string `json:"targetTags,omitempty"`
+	SourceRanges []string `json:"sourceRanges,omitempty"`
+}
+
+func (r *Rule) createArgs() []string {
+	args := []string{
+		"compute", "firewall-rules", "create", r.Name,
+		"--project=" + r.Project,
+		"--network=" + r.Network,
+		"--allow=" + r.Allow,
+	}
+	if r.Direction != "" {
+		args = append(args, "--direction="+r.Direction)
+	}
+	if r.Description != "" {
+		args = append(args, "--description="+r.Description)
+	}
+	if len(r.TargetTags) > 0 {
+		args = append(args, "--target-tags="+strings.Join(r.TargetTags, ","))
+	}
+	if len(r.SourceRanges) > 0 {
+		args = append(args, "--source-ranges="+strings.Join(r.SourceRanges, ","))
+	}
+	return args
+}
+
+// Manager creates firewall rules and records them in the run dir
+type Manager struct {
+	runDir string
+	// skip is set when firewall rules must not be managed by kubetest2,
+	// e.g. for VPC-SC restricted projects
+	skip bool
+
+	mu      sync.Mutex
+	created []Rule
+}
+
+// NewManager returns a Manager recording rules under runDir.
+// Any rules recorded by a previous invocation for the same run are loaded.
+func NewManager(runDir string, skip bool) *Manager {
+	m := &Manager{
+		runDir: runDir,
+		skip:   skip,
+	}
+	rules, err := loadRules(filepath.Join(runDir, stateFile))
+	if err != nil {
+		klog.Warningf("failed to load previously created firewall rules: %v", err)
+	}
+	m.created = rules
+	return m
+}
+
+// Skipped returns true if firewall rules management is disabled
+func (m *Manager) Skipped() bool {
+	return m.skip
+}
+
+// Exists returns true if the named firewall rule exists in the project
+func Exists(project, name string) bool {
+	cmd := exec.Command("gcloud", "compute", "firewall-rules", "describe", name,
+		"--project="+project,
+		"--format=value(name)")
+	exec.NoOutput(cmd)
+	return cmd.Run() == nil
+}
+
+// Ensure creates the rule if it does not already exist, and records it
+func (m *Manager) Ensure(rule Rule) error {
+	if m.skip {
+		klog.V(1).Infof("Skipping creation of firewall rule %s in %s", rule.Name, rule.Project)
+		return nil
+	}
+	if Exists(rule.Project, rule.Name) {
+		// Assume that if this unique firewall exists, it's good to go.
+		klog.V(1).Infof("Firewall rule %s already exists in %s", rule.Name, rule.Project)
+		return nil
+	}
+	return m.Create(rule)
+}
+
+// Create unconditionally creates the rule and records it
+func (m *Manager) Create(rule Rule) error {
+	if m.skip {
+		klog.V(1).Infof("Skipping creation of firewall rule %s in %s", rule.Name, rule.Project)
+		return nil
+	}
+	klog.V(1).Infof("Creating firewall rule %s in %s", rule.Name, rule.Project)
+	cmd := exec.Command("gcloud", rule.createArgs()...)
+	exec.InheritOutput(cmd)
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("error creating firewall rule %s: %v", rule.Name, err)
+	}
+	return m.record(rule)
+}
+
+// Created returns the rules recorded for this run
+func (m *Manager) Created() []Rule {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]Rule{}, m.created...)
+}
+
+func (m *Manager) record(rule Rule) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, r := range m.created {
+		if r.Project == rule.Project && r.Name == rule.Name {
+			return nil
+		}
+	}
+	m.created = append(m.created, rule)
+	return m.persist()
+}
+
+// persist must be called with m.mu held
+func (m *Manager) persist() error {
+	if err := os.MkdirAll(m.runDir, os.ModePerm); err != nil {
+		return err
+	}
+	if err := saveRules(filepath.Join(m.runDir, stateFile), m.created); err != nil {
+		return fmt.Errorf("failed to record firewall rules: %v", err)
+	}
+	return metadata.Default().Set(metadataKey, ruleNames(m.created))
+}
+
+// Cleanup verifies that every rule recorded for the run has been deleted,
+// force deleting the ones that still exist. It returns an error listing
+// the rules that could not be deleted.
+func (m *Manager) Cleanup() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var leaked []Rule
+	for _, rule := range m.created {
+		if !Exists(rule.Project, rule.Name) {
+			continue
+		}
+		klog.Warningf("Firewall rule %s in %s still exists, force deleting it", rule.Name, rule.Project)
+		cmd := exec.Command("gcloud", "compute", "firewall-rules", "delete", "-q", rule.Name,
+			"--project="+rule.Project)
+		exec.InheritOutput(cmd)
+		if err := cmd.Run(); err != nil {
+			klog.Errorf("Error deleting firewall rule %s: %v", rule.Name, err)
+			leaked = append(leaked, rule)
+		}
+	}
+
+	m.created = leaked
+	if err := m.persist(); err != nil {
+		klog.Warningf("%v", err)
+	}
+	if len(leaked) > 0 {
+		return fmt.Errorf("failed to delete firewall rules: %s", ruleNames(leaked))
+	}
+	return nil
+}
+
+func ruleNames(rules []Rule) string {
+	names := make([]string, len(rules))
+	for i, r := range rules {
+		names[i] = r.Project + "/" + r.Name
+	}
+	sort.Strings(names)
+	return strings.Join(names, ",")
+}
+
+func loadRules(path string) ([]Rule, error) {
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var rules []Rule
+	if err := json.Unmarshal(data, &rules); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %v", path, err)
+	}
+	return rules, nil
+}
+
+func saveRules(path string, rules []Rule) error {
+	data, err := json.Marshal(rules)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, data, 0644)
+}
@@ -0,0 +1,161 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package firewall
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"sigs.k8s.io/kubetest2/pkg/metadata"
+)
+
+func TestCreateArgs(t *testing.T) {
+	testCases := []struct {
+		name     string
+		rule     Rule
+		expected []string
+	}{
+		{
+			name: "target tags",
+			rule: Rule{
+				Name:       "e2e-ports-1234abcd",
+				Project:    "project1",
+				Network:    "test-network",
+				Allow:      "tcp:22",
+				TargetTags: []string{"gke-node"},
+			},
+			expected: []string{
+				"compute", "firewall-rules", "create", "e2e-ports-1234abcd",
+				"--project=project1",
+				"--network=test-network",
+				"--allow=tcp:22",
+				"--target-tags=gke-node",
+			},
+		},
+		{
+			name: "source ranges and direction",
+			rule: Rule{
+				Name:         "rule-1-2",
+				Project:      "project1",
+				Network:      "test-network",
+				Allow:        "tcp:22",
+				Direction:    "INGRESS",
+				SourceRanges: []string{"10.0.4.0/22", "10.0.32.0/20"},
+			},
+			expected: []string{
+				"compute", "firewall-rules", "create", "rule-1-2",
+				"--project=project1",
+				"--network=test-network",
+				"--allow=tcp:22",
+				"--direction=INGRESS",
+				"--source-ranges=10.0.4.0/22,10.0.32.0/20",
+			},
+		},
+		{
+			name: "description",
+			rule: Rule{
+				Name:        "e2e-ports-1234abcd",
+				Project:     "project1",
+				Network:     "test-network",
+				Allow:       "tcp:22",
+				Description: "created by kubetest2, labels: kubetest2-run-id=1234",
+			},
+			expected: []string{
+				"compute", "firewall-rules", "create", "e2e-ports-1234abcd",
+				"--project=project1",
+				"--network=test-network",
+				"--allow=tcp:22",
+				"--description=created by kubetest2, labels: kubetest2-run-id=1234",
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			actual := tc.rule.createArgs()
+			if !reflect.DeepEqual(actual, tc.expected) {
+				t.Errorf("expected args: %v, but got: %v", tc.expected, actual)
+			}
+		})
+	}
+}
+
+func TestRecordRules(t *testing.T) {
+	runDir, err := ioutil.TempDir("", "firewall")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(runDir)
+	metadata.SetDefault(metadata.NewStore(filepath.Join(runDir, "metadata.json")))
+	defer metadata.SetDefault(nil)
+
+	m := NewManager(runDir, false)
+	rules := []Rule{
+		{Name: "b", Project: "project1"},
+		{Name: "a", Project: "project2"},
+		// duplicates should only be recorded once
+		{Name: "b", Project: "project1"},
+	}
+	for _, r := range rules {
+		if err := m.record(r); err != nil {
+			t.Errorf("did not expect an error, but got: %v", err)
+		}
+	}
+
+	// a new manager for the same run should see the recorded rules
+	loaded := NewManager(runDir, false).Created()
+	if !reflect.DeepEqual(loaded, rules[:2]) {
+		t.Errorf("expected loaded rules: %v, but got: %v", rules[:2], loaded)
+	}
+
+	data, err := ioutil.ReadFile(filepath.Join(runDir, "metadata.json"))
+	if err != nil {
+		t.Fatalf("failed to read metadata: %v", err)
+	}
+	expected := `{"firewall-rules":"project1/b,project2/a"}`
+	if string(data) != expected {
+		t.Errorf("expected metadata: %s, but got: %s", expected, string(data))
+	}
+}
+
+func TestSkipped(t *testing.T) {
+	runDir, err := ioutil.TempDir("", "firewall")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(runDir)
+
+	// e.g. with --skip-firewall-rules, gcloud is not run
+	m := NewManager(runDir, true)
+	if err := m.Ensure(Rule{Name: "a", Project: "project1"}); err != nil {
+		t.Errorf("did not expect an error, but got: %v", err)
+	}
+	if err := m.Create(Rule{Name: "b", Project: "project1"}); err != nil {
+		t.Errorf("did not expect an error, but got: %v", err)
+	}
+	if created := m.Created(); len(created) != 0 {
+		t.Errorf("expected no rules to be recorded, but got: %v", created)
+	}
+	if err := m.Cleanup(); err != nil {
+		t.Errorf("did not expect an error, but got: %v", err)
+	}
+}
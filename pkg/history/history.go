@@ -0,0 +1,267 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package history persists a summary of every kubetest2 invocation to an
+// index in the artifacts dir, listed by `kubetest2 history` and inspected by
+// `kubetest2 show`, e.g. to find which flag change broke a local workflow.
+package history
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"k8s.io/klog"
+)
+
+// IndexFile is the index of the runs in the artifacts dir, with a JSON
+// encoded Run per line
+const IndexFile = "runs.jsonl"
+
+// Run is the summary of an invocation of kubetest2. A run with e.g. a
+// separate --down invocation has a summary per invocation.
+type Run struct {
+	ID       string `json:"id"`
+	Deployer string `json:"deployer"`
+	// Flags are the kubetest2 and deployer flags set explicitly, with their values
+	Flags   map[string]string `json:"flags,omitempty"`
+	Testers []Tester          `json:"testers,omitempty"`
+	RunDir  string            `json:"runDir"`
+	Started time.Time         `json:"started"`
+	Seconds float64           `json:"seconds"`
+	Passed  bool              `json:"passed"`
+	Error   string            `json:"error,omitempty"`
+	Phases  []Phase           `json:"phases,omitempty"`
+}
+
+// Tester is a tester of the run with its args
+type Tester struct {
+	Name string   `json:"name"`
+	Args []string `json:"args,omitempty"`
+}
+
+// Phase is a phase of the run e.g. Up
+type Phase struct {
+	Name    string  `json:"name"`
+	Seconds float64 `json:"seconds"`
+	Passed  bool    `json:"passed"`
+}
+
+// Result returns passed or failed
+func (r *Run) Result() string {
+	if r.Passed {
+		return "passed"
+	}
+	return "failed"
+}
+
+// Record appends the run to the index in the dir
+func Record(dir string, r *Run) error {
+	data, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(filepath.Join(dir, IndexFile), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open the runs index: %v", err)
+	}
+	// a single write, so that concurrent runs sharing the dir do not interleave
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to record the run: %v", err)
+	}
+	return f.Close()
+}
+
+// Load returns the runs of the index in the dir, oldest first. The lines
+// that cannot be parsed, e.g. cut short by a killed run, are skipped.
+func Load(dir string) ([]Run, error) {
+	f, err := os.Open(filepath.Join(dir, IndexFile))
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to open the runs index: %v", err)
+	}
+	defer f.Close()
+	var runs []Run
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if len(strings.TrimSpace(scanner.Text())) == 0 {
+			continue
+		}
+		var r Run
+		if err := json.Unmarshal(scanner.Bytes(), &r); err != nil {
+			klog.Warningf("Skipping line %d of the runs index: %v", line, err)
+			continue
+		}
+		runs = append(runs, r)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read the runs index: %v", err)
+	}
+	return runs, nil
+}
+
+// Find returns the last invocation of the run with the ID, or with the ID
+// starting with it if it is the only such run
+func Find(runs []Run, id string) (*Run, error) {
+	var exact, prefixed *Run
+	matches := map[string]bool{}
+	for i := range runs {
+		r := &runs[i]
+		if r.ID == id {
+			exact = r
+		} else if strings.HasPrefix(r.ID, id) {
+			prefixed = r
+			matches[r.ID] = true
+		}
+	}
+	if exact != nil {
+		return exact, nil
+	}
+	if len(matches) > 1 {
+		ids := make([]string, 0, len(matches))
+		for match := range matches {
+			ids = append(ids, match)
+		}
+		sort.Strings(ids)
+		return nil, fmt.Errorf("run ID %q is ambiguous, it matches %s", id, strings.Join(ids, ", "))
+	}
+	if prefixed == nil {
+		return nil, fmt.Errorf("no run with ID %q", id)
+	}
+	return prefixed, nil
+}
+
+// FlagChange is a flag that differs between two runs
+type FlagChange struct {
+	Name string
+	// Old and New are nil if the flag is not set in the old, or the new run
+	Old *string
+	New *string
+}
+
+// DiffFlags returns the flags that differ from the old run to the new one, by name
+func DiffFlags(old, new map[string]string) []FlagChange {
+	var changes []FlagChange
+	for name, oldValue := range old {
+		oldValue := oldValue
+		if newValue, ok := new[name]; !ok {
+			changes = append(changes, FlagChange{Name: name, Old: &oldValue})
+		} else if newValue != oldValue {
+			changes = append(changes, FlagChange{Name: name, Old: &oldValue, New: &newValue})
+		}
+	}
+	for name, newValue := range new {
+		newValue := newValue
+		if _, ok := old[name]; !ok {
+			changes = append(changes, FlagChange{Name: name, New: &newValue})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Name < changes[j].Name })
+	return changes
+}
+
+// WriteList writes a table of the runs, newest first
+func WriteList(w io.Writer, runs []Run) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "RUN ID\tDEPLOYER\tSTARTED\tDURATION\tRESULT\tPHASES")
+	for i := len(runs) - 1; i >= 0; i-- {
+		r := runs[i]
+		var phases []string
+		for _, p := range r.Phases {
+			phases = append(phases, p.Name)
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\n", r.ID, r.Deployer, r.Started.Local().Format("2006-01-02 15:04:05"),
+			duration(r.Seconds), r.Result(), strings.Join(phases, ","))
+	}
+	return tw.Flush()
+}
+
+// WriteRun writes the details of the run
+func WriteRun(w io.Writer, r *Run) error {
+	fmt.Fprintf(w, "Run ID:    %s\n", r.ID)
+	fmt.Fprintf(w, "Deployer:  %s\n", r.Deployer)
+	fmt.Fprintf(w, "Run dir:   %s\n", r.RunDir)
+	fmt.Fprintf(w, "Started:   %s\n", r.Started.Local().Format(time.RFC3339))
+	fmt.Fprintf(w, "Duration:  %s\n", duration(r.Seconds))
+	fmt.Fprintf(w, "Result:    %s\n", r.Result())
+	if r.Error != "" {
+		fmt.Fprintf(w, "Error:     %s\n", r.Error)
+	}
+	if len(r.Phases) > 0 {
+		fmt.Fprintln(w, "\nPhases:")
+		tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+		for _, p := range r.Phases {
+			result := "passed"
+			if !p.Passed {
+				result = "failed"
+			}
+			fmt.Fprintf(tw, "  %s\t%s\t%s\n", p.Name, duration(p.Seconds), result)
+		}
+		if err := tw.Flush(); err != nil {
+			return err
+		}
+	}
+	if len(r.Flags) > 0 {
+		fmt.Fprintln(w, "\nFlags:")
+		names := make([]string, 0, len(r.Flags))
+		for name := range r.Flags {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			fmt.Fprintf(w, "  --%s=%s\n", name, r.Flags[name])
+		}
+	}
+	if len(r.Testers) > 0 {
+		fmt.Fprintln(w, "\nTesters:")
+		for _, t := range r.Testers {
+			fmt.Fprintf(w, "  %s %s\n", t.Name, strings.Join(t.Args, " "))
+		}
+	}
+	return nil
+}
+
+// WriteFlagChanges writes the flags that differ from the old run to the new
+// one as a diff, - for the old values and + for the new ones
+func WriteFlagChanges(w io.Writer, oldID, newID string, changes []FlagChange) {
+	fmt.Fprintf(w, "--- %s\n+++ %s\n", oldID, newID)
+	if len(changes) == 0 {
+		fmt.Fprintln(w, "  (the flags are the same)")
+	}
+	for _, c := range changes {
+		if c.Old != nil {
+			fmt.Fprintf(w, "- --%s=%s\n", c.Name, *c.Old)
+		}
+		if c.New != nil {
+			fmt.Fprintf(w, "+ --%s=%s\n", c.Name, *c.New)
+		}
+	}
+}
+
+// duration formats seconds as e.g. 1h2m3s
+func duration(seconds float64) string {
+	return (time.Duration(seconds * float64(time.Second))).Round(time.Second).String()
+}
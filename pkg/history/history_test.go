@@ -0,0 +1,107 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package history
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRecordAndLoad(t *testing.T) {
+	dir, err := ioutil.TempDir("", "history")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	if runs, err := Load(dir); err != nil || runs != nil {
+		t.Fatalf("expected no runs without an index, got %v, %v", runs, err)
+	}
+	first := &Run{ID: "a1", Deployer: "kind", Flags: map[string]string{"up": "true"}, Started: time.Unix(1600000000, 0).UTC(), Seconds: 90, Passed: true,
+		Phases: []Phase{{Name: "Up", Seconds: 60, Passed: true}}}
+	second := &Run{ID: "b2", Deployer: "gke", Started: time.Unix(1600001000, 0).UTC(), Error: "Up failed"}
+	if err := Record(dir, first); err != nil {
+		t.Fatal(err)
+	}
+	// e.g. cut short by a killed run
+	f, err := os.OpenFile(filepath.Join(dir, IndexFile), os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.WriteString(`{"id": "trunc` + "\n"); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+	if err := Record(dir, second); err != nil {
+		t.Fatal(err)
+	}
+	runs, err := Load(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := []Run{*first, *second}; !reflect.DeepEqual(runs, expected) {
+		t.Errorf("expected %+v, got %+v", expected, runs)
+	}
+}
+
+func TestFind(t *testing.T) {
+	runs := []Run{{ID: "abc-1", Seconds: 1}, {ID: "abd-2"}, {ID: "abc-1", Seconds: 2}, {ID: "abc"}}
+	testCases := []struct {
+		id          string
+		expected    *Run
+		expectError bool
+	}{
+		{id: "abc-1", expected: &runs[2]},
+		{id: "abd", expected: &runs[1]},
+		{id: "abc", expected: &runs[3]},
+		{id: "ab", expectError: true},
+		{id: "xyz", expectError: true},
+	}
+	for _, tc := range testCases {
+		actual, err := Find(runs, tc.id)
+		if (err != nil) != tc.expectError {
+			t.Errorf("%q: expected error: %v, but got: %v", tc.id, tc.expectError, err)
+		}
+		if actual != tc.expected {
+			t.Errorf("%q: expected %+v, got %+v", tc.id, tc.expected, actual)
+		}
+	}
+}
+
+func TestWriteFlagChanges(t *testing.T) {
+	old := map[string]string{"up": "true", "zone": "us-central1-a", "num-nodes": "3"}
+	new := map[string]string{"up": "true", "zone": "us-central1-b", "cluster-version": "1.21"}
+	var out bytes.Buffer
+	WriteFlagChanges(&out, "a1", "b2", DiffFlags(old, new))
+	expected := strings.Join([]string{
+		"--- a1",
+		"+++ b2",
+		"+ --cluster-version=1.21",
+		"- --num-nodes=3",
+		"- --zone=us-central1-a",
+		"+ --zone=us-central1-b",
+		"",
+	}, "\n")
+	if out.String() != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, out.String())
+	}
+}
@@ -0,0 +1,129 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package labels implements the labels applied by the kubetest2 deployers to
+// the cloud resources they create, identifying the run that created them for
+// the leak detection, cost attribution and org policies requiring labels.
+package labels
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The labels identifying the run that created a resource
+const (
+	RunID   = "kubetest2-run-id"
+	ProwJob = "kubetest2-prow-job"
+	Owner   = "kubetest2-owner"
+	// Expiry is the unix time after which the resource can be garbage collected
+	Expiry = "kubetest2-expiry"
+)
+
+var (
+	validKey          = regexp.MustCompile(`^[a-z][a-z0-9_-]{0,62}$`)
+	validValue        = regexp.MustCompile(`^[a-z0-9_-]{0,63}$`)
+	invalidValueChars = regexp.MustCompile(`[^a-z0-9_-]`)
+)
+
+// ForRun returns the labels identifying the run, with the prow job and the
+// owner taken from $JOB_NAME and $USER if set, and the expiry ttl after now
+func ForRun(runID string, ttl time.Duration, now time.Time) map[string]string {
+	labels := map[string]string{
+		RunID: SanitizeValue(runID),
+	}
+	if job := os.Getenv("JOB_NAME"); job != "" {
+		labels[ProwJob] = SanitizeValue(job)
+	}
+	if user := os.Getenv("USER"); user != "" {
+		labels[Owner] = SanitizeValue(user)
+	}
+	if ttl > 0 {
+		labels[Expiry] = strconv.FormatInt(now.Add(ttl).Unix(), 10)
+	}
+	return labels
+}
+
+// Parse parses KEY=VALUE labels, validating them against the GCP label requirements
+func Parse(pairs []string) (map[string]string, error) {
+	labels := map[string]string{}
+	for _, pair := range pairs {
+		kv := strings.SplitN(pair, "=", 2)
+		if len(kv) != 2 {
+			return nil, fmt.Errorf("invalid label %q, expected KEY=VALUE", pair)
+		}
+		if !validKey.MatchString(kv[0]) {
+			return nil, fmt.Errorf("invalid label key %q, keys must start with a lowercase letter and may only contain lowercase letters, digits, _ and -", kv[0])
+		}
+		if !validValue.MatchString(kv[1]) {
+			return nil, fmt.Errorf("invalid value %q for label %s, values may only contain lowercase letters, digits, _ and -", kv[1], kv[0])
+		}
+		labels[kv[0]] = kv[1]
+	}
+	return labels, nil
+}
+
+// Merge returns the labels with the overrides applied
+func Merge(labels, overrides map[string]string) map[string]string {
+	merged := make(map[string]string, len(labels)+len(overrides))
+	for key, value := range labels {
+		merged[key] = value
+	}
+	for key, value := range overrides {
+		merged[key] = value
+	}
+	return merged
+}
+
+// Format formats the labels as KEY=VALUE,... sorted by key, as expected by the gcloud --labels flags
+func Format(labels map[string]string) string {
+	pairs := make([]string, 0, len(labels))
+	for key, value := range labels {
+		pairs = append(pairs, key+"="+value)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
+
+// InDescription returns true if the description, e.g. of a firewall rule which
+// does not support labels, contains all of the labels formatted by Format
+func InDescription(description string, labels map[string]string) bool {
+	fields := map[string]bool{}
+	for _, field := range strings.FieldsFunc(description, func(r rune) bool { return r == ',' || r == ' ' }) {
+		fields[field] = true
+	}
+	for key, value := range labels {
+		if !fields[key+"="+value] {
+			return false
+		}
+	}
+	return len(labels) > 0
+}
+
+// SanitizeValue converts s to a valid GCP label value, which may only
+// contain lowercase letters, digits, underscores and dashes, up to 63 characters
+func SanitizeValue(s string) string {
+	s = invalidValueChars.ReplaceAllString(strings.ToLower(s), "-")
+	if len(s) > 63 {
+		s = s[:63]
+	}
+	return s
+}
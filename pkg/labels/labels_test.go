@@ -0,0 +1,121 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package labels
+
+import (
+	"os"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestForRun(t *testing.T) {
+	for key, value := range map[string]string{"JOB_NAME": "ci-kubetest2.GKE", "USER": "Prow"} {
+		old, set := os.LookupEnv(key)
+		os.Setenv(key, value)
+		if set {
+			defer os.Setenv(key, old)
+		} else {
+			defer os.Unsetenv(key)
+		}
+	}
+	expected := map[string]string{
+		RunID:   "1234",
+		ProwJob: "ci-kubetest2-gke",
+		Owner:   "prow",
+		Expiry:  "86400",
+	}
+	if actual := ForRun("1234", 24*time.Hour, time.Unix(0, 0)); !reflect.DeepEqual(actual, expected) {
+		t.Errorf("expected labels %v, but got %v", expected, actual)
+	}
+}
+
+func TestParse(t *testing.T) {
+	testCases := []struct {
+		name        string
+		pairs       []string
+		expected    map[string]string
+		expectError bool
+	}{
+		{
+			name:     "valid labels",
+			pairs:    []string{"team=sig-testing", "cost-center=", "env=ci_1"},
+			expected: map[string]string{"team": "sig-testing", "cost-center": "", "env": "ci_1"},
+		},
+		{
+			name:        "missing value",
+			pairs:       []string{"team"},
+			expectError: true,
+		},
+		{
+			name:        "uppercase key",
+			pairs:       []string{"Team=sig-testing"},
+			expectError: true,
+		},
+		{
+			name:        "invalid value",
+			pairs:       []string{"team=sig.testing"},
+			expectError: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			actual, err := Parse(tc.pairs)
+			if (err != nil) != tc.expectError {
+				t.Fatalf("expected error: %v, but got: %v", tc.expectError, err)
+			}
+			if !tc.expectError && !reflect.DeepEqual(actual, tc.expected) {
+				t.Errorf("expected labels %v, but got %v", tc.expected, actual)
+			}
+		})
+	}
+}
+
+func TestFormatAndInDescription(t *testing.T) {
+	labels := map[string]string{RunID: "1234", "team": "sig-testing"}
+	formatted := Format(labels)
+	if expected := "kubetest2-run-id=1234,team=sig-testing"; formatted != expected {
+		t.Errorf("expected %s, but got %s", expected, formatted)
+	}
+	description := "created by kubetest2, labels: " + formatted
+	if !InDescription(description, map[string]string{RunID: "1234"}) {
+		t.Errorf("expected the run label to be found in %q", description)
+	}
+	if InDescription(description, map[string]string{RunID: "123"}) {
+		t.Errorf("did not expect a different run label to be found in %q", description)
+	}
+	if InDescription(description, nil) {
+		t.Errorf("did not expect empty labels to match")
+	}
+}
+
+func TestSanitizeValue(t *testing.T) {
+	testCases := map[string]string{
+		"2d1b2c3e-0f9a-4b1c-8d7e-6f5a4b3c2d1e": "2d1b2c3e-0f9a-4b1c-8d7e-6f5a4b3c2d1e",
+		"Pull-Kubetest2.GKE":                   "pull-kubetest2-gke",
+		"a/b c":                                "a-b-c",
+		"0123456789012345678901234567890123456789012345678901234567890123456789": "012345678901234567890123456789012345678901234567890123456789012",
+	}
+	for value, expected := range testCases {
+		if actual := SanitizeValue(value); actual != expected {
+			t.Errorf("expected %q to be sanitized to %q, but got %q", value, expected, actual)
+		}
+	}
+}
@@ -0,0 +1,334 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package leak implements a post-run check for the GCP resources created
+// during a kubetest2 run that are still around after Down(), so that leaks
+// are attributed to the job that caused them instead of being silently
+// cleaned up by the boskos janitor later on.
+package leak
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"k8s.io/klog"
+
+	"sigs.k8s.io/kubetest2/pkg/exec"
+	"sigs.k8s.io/kubetest2/pkg/labels"
+	"sigs.k8s.io/kubetest2/pkg/metadata"
+)
+
+const (
+	// reportFile is the name of the file in the run dir the leaked resources are written to
+	reportFile = "leaked-resources.json"
+	// metadataKey is the key the leaked resources are recorded under in metadata.json
+	metadataKey = "leaked-resources"
+)
+
+// Filter selects the resources of a run in a project. A resource belongs to
+// the run if it matches any of the criteria.
+type Filter struct {
+	Project string
+	// Labels selects the resources having all of the labels, or for firewall
+	// rules which do not support labels, having them in their description
+	Labels map[string]string
+	// Names selects the resources with one of the names
+	Names []string
+	// NamePrefixes selects the resources with a name starting with one of the prefixes
+	NamePrefixes []string
+	// Networks selects the resources in one of the networks, which should only
+	// be set for the networks created by the run
+	Networks []string
+	// Kinds restricts the check to the kinds of resources, e.g. firewall-rule,
+	// every kind is checked if empty
+	Kinds []string
+}
+
+// checks returns true if the resources of the kind are checked by the filter
+func (f *Filter) checks(k kind) bool {
+	if len(f.Kinds) == 0 {
+		return true
+	}
+	for _, name := range f.Kinds {
+		if name == k.name {
+			return true
+		}
+	}
+	return false
+}
+
+// Resource is a leaked resource
+type Resource struct {
+	Kind     string `json:"kind"`
+	Project  string `json:"project"`
+	Name     string `json:"name"`
+	Location string `json:"location,omitempty"`
+}
+
+func (r Resource) String() string {
+	if r.Location != "" {
+		return fmt.Sprintf("%s %s/%s/%s", r.Kind, r.Project, r.Location, r.Name)
+	}
+	return fmt.Sprintf("%s %s/%s", r.Kind, r.Project, r.Name)
+}
+
+// listedResource has the fields of the gcloud list output used for matching
+type listedResource struct {
+	Name   string            `json:"name"`
+	Labels map[string]string `json:"labels"`
+	// clusters have resourceLabels instead of labels
+	ResourceLabels map[string]string `json:"resourceLabels"`
+	Description    string            `json:"description"`
+	Network        string            `json:"network"`
+	Location       string            `json:"location"`
+	Zone           string            `json:"zone"`
+	Region         string            `json:"region"`
+}
+
+// location returns the zone or region of the resource, or "" for global resources
+func (r *listedResource) location() string {
+	for _, location := range []string{r.Location, r.Zone, r.Region} {
+		if location != "" {
+			// compute resources reference their zone and region by URL
+			return path.Base(location)
+		}
+	}
+	return ""
+}
+
+// kind is a kind of GCP resource checked for leaks
+type kind struct {
+	name string
+	// the gcloud command listing the resources
+	list []string
+	// the gcloud command deleting a resource, the location flag is appended
+	delete []string
+	// locationFlags returns the flags to pass the location of a resource to gcloud
+	locationFlags func(location string) []string
+}
+
+// zonalOrRegional returns the --zone or --region flag for zones like us-central1-a
+// and regions like us-central1 respectively
+func zonalOrRegional(location string) []string {
+	if location == "" {
+		return nil
+	}
+	if strings.Count(location, "-") >= 2 {
+		return []string{"--zone=" + location}
+	}
+	return []string{"--region=" + location}
+}
+
+func regionalOrGlobal(location string) []string {
+	if location == "" {
+		return []string{"--global"}
+	}
+	return []string{"--region=" + location}
+}
+
+func global(string) []string {
+	return nil
+}
+
+// kinds are ordered so that the resources depending on others are deleted first
+var kinds = []kind{
+	{
+		name:          "cluster",
+		list:          []string{"container", "clusters", "list"},
+		delete:        []string{"container", "clusters", "delete", "-q"},
+		locationFlags: zonalOrRegional,
+	},
+	{
+		name:          "instance-group",
+		list:          []string{"compute", "instance-groups", "managed", "list"},
+		delete:        []string{"compute", "instance-groups", "managed", "delete", "-q"},
+		locationFlags: zonalOrRegional,
+	},
+	{
+		name:          "firewall-rule",
+		list:          []string{"compute", "firewall-rules", "list"},
+		delete:        []string{"compute", "firewall-rules", "delete", "-q"},
+		locationFlags: global,
+	},
+	{
+		name:          "route",
+		list:          []string{"compute", "routes", "list"},
+		delete:        []string{"compute", "routes", "delete", "-q"},
+		locationFlags: global,
+	},
+	{
+		name:          "address",
+		list:          []string{"compute", "addresses", "list"},
+		delete:        []string{"compute", "addresses", "delete", "-q"},
+		locationFlags: regionalOrGlobal,
+	},
+	{
+		name:          "disk",
+		list:          []string{"compute", "disks", "list"},
+		delete:        []string{"compute", "disks", "delete", "-q"},
+		locationFlags: zonalOrRegional,
+	},
+}
+
+func kindByName(name string) (kind, bool) {
+	for _, k := range kinds {
+		if k.name == name {
+			return k, true
+		}
+	}
+	return kind{}, false
+}
+
+// defaultRoute matches the routes GCP creates with every network, they are
+// deleted along with the network
+var defaultRoute = regexp.MustCompile(`^default-route-[0-9a-f]+$`)
+
+// matches returns true if the resource belongs to the run selected by the filter
+func (f *Filter) matches(r *listedResource) bool {
+	resourceLabels := r.Labels
+	if resourceLabels == nil {
+		resourceLabels = r.ResourceLabels
+	}
+	if len(f.Labels) > 0 && len(resourceLabels) > 0 {
+		matched := true
+		for key, value := range f.Labels {
+			matched = matched && resourceLabels[key] == value
+		}
+		if matched {
+			return true
+		}
+	}
+	if labels.InDescription(r.Description, f.Labels) {
+		return true
+	}
+	for _, name := range f.Names {
+		if r.Name == name {
+			return true
+		}
+	}
+	for _, prefix := range f.NamePrefixes {
+		if strings.HasPrefix(r.Name, prefix) {
+			return true
+		}
+	}
+	if r.Network != "" && !defaultRoute.MatchString(r.Name) {
+		for _, network := range f.Networks {
+			if path.Base(r.Network) == network {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// match returns the resources of kind k in the gcloud list output belonging to the run
+func (f *Filter) match(k kind, listed []listedResource) []Resource {
+	var matched []Resource
+	for i := range listed {
+		if f.matches(&listed[i]) {
+			matched = append(matched, Resource{
+				Kind:     k.name,
+				Project:  f.Project,
+				Name:     listed[i].Name,
+				Location: listed[i].location(),
+			})
+		}
+	}
+	return matched
+}
+
+// Detect lists the resources of every kind in the project of each filter,
+// returning the ones belonging to the run
+func Detect(filters []Filter) ([]Resource, error) {
+	var leaked []Resource
+	var errs []string
+	for _, f := range filters {
+		for _, k := range kinds {
+			if !f.checks(k) {
+				continue
+			}
+			args := append(append([]string{}, k.list...), "--project="+f.Project, "--format=json")
+			out, err := exec.Output(exec.Command("gcloud", args...))
+			if err != nil {
+				errs = append(errs, fmt.Sprintf("failed to list %ss in %s: %v", k.name, f.Project, err))
+				continue
+			}
+			var listed []listedResource
+			if err := json.Unmarshal(out, &listed); err != nil {
+				errs = append(errs, fmt.Sprintf("failed to parse %ss in %s: %v", k.name, f.Project, err))
+				continue
+			}
+			leaked = append(leaked, f.match(k, listed)...)
+		}
+	}
+	if len(errs) > 0 {
+		return leaked, fmt.Errorf("failed to check for leaked resources: %s", strings.Join(errs, "; "))
+	}
+	return leaked, nil
+}
+
+// deleteArgs returns the gcloud args to delete the resource
+func deleteArgs(r Resource) ([]string, error) {
+	k, ok := kindByName(r.Kind)
+	if !ok {
+		return nil, fmt.Errorf("unknown resource kind %s", r.Kind)
+	}
+	args := append(append([]string{}, k.delete...), r.Name, "--project="+r.Project)
+	return append(args, k.locationFlags(r.Location)...), nil
+}
+
+// Delete force deletes the resources, returning the ones that could not be deleted
+func Delete(resources []Resource) []Resource {
+	var remaining []Resource
+	for _, r := range resources {
+		args, err := deleteArgs(r)
+		if err == nil {
+			klog.Warningf("Force deleting leaked %s", r)
+			cmd := exec.Command("gcloud", args...)
+			exec.InheritOutput(cmd)
+			err = cmd.Run()
+		}
+		if err != nil {
+			klog.Errorf("Error deleting leaked %s: %v", r, err)
+			remaining = append(remaining, r)
+		}
+	}
+	return remaining
+}
+
+// Report writes the leaked resources to leaked-resources.json in the run dir
+// and records them in the metadata of the run
+func Report(runDir string, resources []Resource) error {
+	sort.Slice(resources, func(i, j int) bool { return resources[i].String() < resources[j].String() })
+	data, err := json.MarshalIndent(resources, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(filepath.Join(runDir, reportFile), data, 0644); err != nil {
+		return fmt.Errorf("failed to write the leaked resources: %v", err)
+	}
+	names := make([]string, 0, len(resources))
+	for _, r := range resources {
+		names = append(names, r.String())
+	}
+	return metadata.Default().Set(metadataKey, strings.Join(names, ","))
+}
@@ -0,0 +1,152 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package leak
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"sigs.k8s.io/kubetest2/pkg/labels"
+	"sigs.k8s.io/kubetest2/pkg/metadata"
+)
+
+func TestMatch(t *testing.T) {
+	f := &Filter{
+		Project:      "project1",
+		Labels:       map[string]string{labels.RunID: "run1"},
+		Names:        []string{"cluster1"},
+		NamePrefixes: []string{"gke-cluster1-"},
+		Networks:     []string{"test-network"},
+	}
+	listed := `[
+  {"name": "cluster1", "location": "us-central1"},
+  {"name": "cluster2", "location": "us-central1-c", "resourceLabels": {"kubetest2-run-id": "run1"}},
+  {"name": "cluster3", "location": "us-central1-c", "resourceLabels": {"kubetest2-run-id": "run2"}},
+  {"name": "gke-cluster1-default-pool-1234-grp", "zone": "https://www.googleapis.com/compute/v1/projects/project1/zones/us-central1-a"},
+  {"name": "gke-cluster10-default-pool-1234-grp", "zone": "https://www.googleapis.com/compute/v1/projects/project1/zones/us-central1-a"},
+  {"name": "e2e-ports-1234", "network": "https://www.googleapis.com/compute/v1/projects/project1/global/networks/test-network"},
+  {"name": "default-route-0123abcd", "network": "https://www.googleapis.com/compute/v1/projects/project1/global/networks/test-network"},
+  {"name": "k8s-fw-1234", "description": "kubetest2 labels: kubetest2-run-id=run1,kubetest2-owner=prow", "network": "https://www.googleapis.com/compute/v1/projects/project1/global/networks/default"},
+  {"name": "default-allow-ssh", "network": "https://www.googleapis.com/compute/v1/projects/project1/global/networks/default"},
+  {"name": "pvc-1234", "labels": {"kubetest2-run-id": "run1", "goog-gke-volume": ""}, "zone": "us-central1-b"},
+  {"name": "address", "region": "https://www.googleapis.com/compute/v1/projects/project1/regions/us-central1"}
+]`
+	var resources []listedResource
+	if err := json.Unmarshal([]byte(listed), &resources); err != nil {
+		t.Fatalf("failed to parse listed resources: %v", err)
+	}
+	expected := []Resource{
+		{Kind: "cluster", Project: "project1", Name: "cluster1", Location: "us-central1"},
+		{Kind: "cluster", Project: "project1", Name: "cluster2", Location: "us-central1-c"},
+		{Kind: "cluster", Project: "project1", Name: "gke-cluster1-default-pool-1234-grp", Location: "us-central1-a"},
+		{Kind: "cluster", Project: "project1", Name: "e2e-ports-1234"},
+		{Kind: "cluster", Project: "project1", Name: "k8s-fw-1234"},
+		{Kind: "cluster", Project: "project1", Name: "pvc-1234", Location: "us-central1-b"},
+	}
+	k, _ := kindByName("cluster")
+	if actual := f.match(k, resources); !reflect.DeepEqual(actual, expected) {
+		t.Errorf("expected matched resources %v, but got %v", expected, actual)
+	}
+}
+
+func TestChecks(t *testing.T) {
+	cluster, _ := kindByName("cluster")
+	firewallRule, _ := kindByName("firewall-rule")
+	all := &Filter{}
+	if !all.checks(cluster) || !all.checks(firewallRule) {
+		t.Errorf("expected a filter without kinds to check every kind")
+	}
+	firewallRules := &Filter{Kinds: []string{"firewall-rule"}}
+	if firewallRules.checks(cluster) || !firewallRules.checks(firewallRule) {
+		t.Errorf("expected a filter with kinds to only check those kinds")
+	}
+}
+
+func TestDeleteArgs(t *testing.T) {
+	testCases := []struct {
+		resource Resource
+		expected []string
+	}{
+		{
+			resource: Resource{Kind: "cluster", Project: "project1", Name: "cluster1", Location: "us-central1"},
+			expected: []string{"container", "clusters", "delete", "-q", "cluster1", "--project=project1", "--region=us-central1"},
+		},
+		{
+			resource: Resource{Kind: "disk", Project: "project1", Name: "pvc-1234", Location: "us-central1-b"},
+			expected: []string{"compute", "disks", "delete", "-q", "pvc-1234", "--project=project1", "--zone=us-central1-b"},
+		},
+		{
+			resource: Resource{Kind: "address", Project: "project1", Name: "ingress"},
+			expected: []string{"compute", "addresses", "delete", "-q", "ingress", "--project=project1", "--global"},
+		},
+		{
+			resource: Resource{Kind: "firewall-rule", Project: "project1", Name: "e2e-ports-1234"},
+			expected: []string{"compute", "firewall-rules", "delete", "-q", "e2e-ports-1234", "--project=project1"},
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.resource.String(), func(t *testing.T) {
+			t.Parallel()
+			actual, err := deleteArgs(tc.resource)
+			if err != nil {
+				t.Fatalf("did not expect an error, but got: %v", err)
+			}
+			if !reflect.DeepEqual(actual, tc.expected) {
+				t.Errorf("expected args %v, but got %v", tc.expected, actual)
+			}
+		})
+	}
+
+	if _, err := deleteArgs(Resource{Kind: "bucket", Name: "foo"}); err == nil {
+		t.Errorf("expected an error for an unknown kind")
+	}
+}
+
+func TestReport(t *testing.T) {
+	runDir, err := ioutil.TempDir("", "leak")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(runDir)
+	metadata.SetDefault(metadata.NewStore(filepath.Join(runDir, "metadata.json")))
+	defer metadata.SetDefault(nil)
+
+	resources := []Resource{
+		{Kind: "route", Project: "project1", Name: "gke-cluster1-1234"},
+		{Kind: "disk", Project: "project1", Name: "pvc-1234", Location: "us-central1-b"},
+	}
+	if err := Report(runDir, resources); err != nil {
+		t.Fatalf("did not expect an error, but got: %v", err)
+	}
+	data, err := ioutil.ReadFile(filepath.Join(runDir, "metadata.json"))
+	if err != nil {
+		t.Fatalf("failed to read metadata: %v", err)
+	}
+	expected := `{"leaked-resources":"disk project1/us-central1-b/pvc-1234,route project1/gke-cluster1-1234"}`
+	if string(data) != expected {
+		t.Errorf("expected metadata: %s, but got: %s", expected, string(data))
+	}
+	if _, err := os.Stat(filepath.Join(runDir, reportFile)); err != nil {
+		t.Errorf("expected %s to be written, but got: %v", reportFile, err)
+	}
+}
@@ -0,0 +1,229 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package metadata
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Well-known metadata keys, written by the deployers and testers and consumed by testgrid
+const (
+	KubetestVersionKey = "kubetest-version"
+	DeployerVersionKey = "deployer-version"
+	ClusterNamesKey    = "cluster-names"
+	ClusterVersionKey  = "cluster-version"
+	ImagesKey          = "images"
+	BoskosProjectsKey  = "boskos-projects"
+	// the RuntimeClass the tests should run their pods with, e.g. of a sandboxed runtime
+	RuntimeClassKey = "runtime-class"
+	// the --kube-feature-gates and --runtime-config the cluster was brought up with
+	FeatureGatesKey  = "feature-gates"
+	RuntimeConfigKey = "runtime-config"
+	// the --project-source of the GCP projects of the run, and the projects
+	ProjectSourceKey = "project-source"
+	ProjectsKey      = "projects"
+	// the name@digest of the artifacts attested with --attest, and the file listing their attestations
+	AttestedArtifactsKey = "attested-artifacts"
+	AttestationsKey      = "attestations"
+	// the result reported by the tester, see testers.Result
+	TestsPassedKey   = "tests-passed"
+	TestsFailedKey   = "tests-failed"
+	TestsSkippedKey  = "tests-skipped"
+	TestFailuresKey  = "test-failures"
+	TestArtifactsKey = "test-artifacts"
+)
+
+// fileMu serializes the updates of the metadata files of this process
+var fileMu sync.Mutex
+
+// Store writes key/values to a metadata.json file. Every update is flushed to
+// the file right away, and the file is replaced atomically, so that a run
+// that crashes or is killed still has the metadata recorded so far.
+//
+// The file is re-read before every update, so stores in separate processes
+// (e.g. kubetest2 and a tester) can write to the same file.
+type Store struct {
+	path string
+}
+
+// NewStore returns a store for the metadata file at path.
+// A store with an empty path silently drops the updates.
+func NewStore(path string) *Store {
+	return &Store{path: path}
+}
+
+// Path returns the path of the metadata file
+func (s *Store) Path() string {
+	return s.path
+}
+
+// Reset replaces the contents of the metadata file with an empty object
+func (s *Store) Reset() error {
+	if s.path == "" {
+		return nil
+	}
+	fileMu.Lock()
+	defer fileMu.Unlock()
+	return s.write(&CustomJSON{})
+}
+
+// Set sets the key to value, overwriting any existing value
+func (s *Store) Set(key, value string) error {
+	return s.SetAll(map[string]string{key: value})
+}
+
+// SetAll sets all the key/values in a single update
+func (s *Store) SetAll(values map[string]string) error {
+	if s.path == "" {
+		return nil
+	}
+	fileMu.Lock()
+	defer fileMu.Unlock()
+	meta, err := s.load()
+	if err != nil {
+		return err
+	}
+	for key, value := range values {
+		meta.Set(key, value)
+	}
+	return s.write(meta)
+}
+
+// SetStrings sets the key to the comma separated values
+func (s *Store) SetStrings(key string, values []string) error {
+	return s.Set(key, strings.Join(values, ","))
+}
+
+// SetInt sets the key to the integer value
+func (s *Store) SetInt(key string, value int) error {
+	return s.Set(key, strconv.Itoa(value))
+}
+
+// SetBool sets the key to the boolean value
+func (s *Store) SetBool(key string, value bool) error {
+	return s.Set(key, strconv.FormatBool(value))
+}
+
+// Get returns the value of key in the metadata file, if set
+func (s *Store) Get(key string) (string, bool, error) {
+	if s.path == "" {
+		return "", false, nil
+	}
+	fileMu.Lock()
+	defer fileMu.Unlock()
+	meta, err := s.load()
+	if err != nil {
+		return "", false, err
+	}
+	value, ok := meta.data[key]
+	return value, ok, nil
+}
+
+// load must be called with fileMu held
+func (s *Store) load() (*CustomJSON, error) {
+	existing, err := os.Open(s.path)
+	if os.IsNotExist(err) {
+		return &CustomJSON{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer existing.Close()
+	meta, err := NewCustomJSON(existing)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %v", s.path, err)
+	}
+	return meta, nil
+}
+
+// write must be called with fileMu held
+func (s *Store) write(meta *CustomJSON) error {
+	tmp, err := ioutil.TempFile(filepath.Dir(s.path), ".metadata-*.json")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if err := meta.Write(tmp); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), s.path)
+}
+
+var (
+	defaultMu    sync.Mutex
+	defaultStore *Store
+)
+
+// SetDefault sets the store returned by Default, kubetest2 sets it to the
+// metadata file of the run before calling the deployer
+func SetDefault(s *Store) {
+	defaultMu.Lock()
+	defer defaultMu.Unlock()
+	defaultStore = s
+}
+
+// Default returns the metadata store of the current run.
+// Outside of kubetest2 itself, e.g. in testers, this is the metadata.json
+// of the $KUBETEST2_RUN_DIR the tester is run with.
+func Default() *Store {
+	defaultMu.Lock()
+	defer defaultMu.Unlock()
+	if defaultStore == nil {
+		return FromEnv()
+	}
+	return defaultStore
+}
+
+// FromEnv returns the store of the metadata.json in $KUBETEST2_RUN_DIR,
+// which drops the updates if it is not set
+func FromEnv() *Store {
+	runDir := os.Getenv("KUBETEST2_RUN_DIR")
+	if runDir == "" {
+		return NewStore("")
+	}
+	return NewStore(filepath.Join(runDir, "metadata.json"))
+}
+
+// ParseKeyValues parses KEY=VALUE pairs e.g. from the --metadata flag
+func ParseKeyValues(pairs []string) (map[string]string, error) {
+	values := map[string]string{}
+	for _, pair := range pairs {
+		kv := strings.SplitN(pair, "=", 2)
+		if len(kv) != 2 || kv[0] == "" {
+			return nil, fmt.Errorf("invalid metadata %q, expected KEY=VALUE", pair)
+		}
+		values[kv[0]] = kv[1]
+	}
+	return values, nil
+}
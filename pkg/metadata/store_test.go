@@ -0,0 +1,95 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package metadata
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "metadata")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "metadata.json")
+
+	s := NewStore(path)
+	if err := s.Set("foo", "bar"); err != nil {
+		t.Errorf("did not expect an error, but got: %v", err)
+	}
+	if err := s.SetStrings(ClusterNamesKey, []string{"a", "b"}); err != nil {
+		t.Errorf("did not expect an error, but got: %v", err)
+	}
+	// updates from another store for the same file are merged
+	if err := NewStore(path).SetInt("count", 3); err != nil {
+		t.Errorf("did not expect an error, but got: %v", err)
+	}
+	if err := s.SetBool("foo", true); err != nil {
+		t.Errorf("did not expect an error, but got: %v", err)
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read metadata: %v", err)
+	}
+	expected := `{"cluster-names":"a,b","count":"3","foo":"true"}`
+	if string(data) != expected {
+		t.Errorf("expected metadata: %s, but got: %s", expected, string(data))
+	}
+
+	if value, ok, err := s.Get("count"); err != nil || !ok || value != "3" {
+		t.Errorf("expected count to be 3, but got: %q %v %v", value, ok, err)
+	}
+
+	if err := s.Reset(); err != nil {
+		t.Errorf("did not expect an error, but got: %v", err)
+	}
+	if _, ok, _ := s.Get("foo"); ok {
+		t.Errorf("expected the metadata to be reset")
+	}
+}
+
+func TestStoreWithoutPath(t *testing.T) {
+	s := NewStore("")
+	if err := s.Set("foo", "bar"); err != nil {
+		t.Errorf("did not expect an error, but got: %v", err)
+	}
+	if _, ok, err := s.Get("foo"); ok || err != nil {
+		t.Errorf("expected updates to be dropped, but got: %v %v", ok, err)
+	}
+}
+
+func TestParseKeyValues(t *testing.T) {
+	values, err := ParseKeyValues([]string{"job=ci-e2e", "url=https://example.com/?a=b"})
+	if err != nil {
+		t.Fatalf("did not expect an error, but got: %v", err)
+	}
+	expected := map[string]string{"job": "ci-e2e", "url": "https://example.com/?a=b"}
+	if !reflect.DeepEqual(values, expected) {
+		t.Errorf("expected %v, but got %v", expected, values)
+	}
+	for _, invalid := range []string{"novalue", "=value"} {
+		if _, err := ParseKeyValues([]string{invalid}); err == nil {
+			t.Errorf("expected an error for %q", invalid)
+		}
+	}
+}
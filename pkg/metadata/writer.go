@@ -19,6 +19,8 @@ package metadata
 import (
 	"io"
 	"time"
+
+	"sigs.k8s.io/kubetest2/pkg/secrets"
 )
 
 // Writer manages writing out kubetest2 metadata, namely JUnit
@@ -56,10 +58,10 @@ func (w *Writer) WrapStep(name string, doStep func() error) error {
 		Time:      finish.Sub(start).Seconds(),
 	}
 	if err != nil {
-		tc.Failure = err.Error()
+		tc.Failure = secrets.Redact(err.Error())
 	}
 	if v, ok := err.(JUnitError); ok {
-		tc.SystemOut = v.SystemOut()
+		tc.SystemOut = secrets.Redact(v.SystemOut())
 	}
 	w.suite.AddTestCase(tc)
 	return err
@@ -0,0 +1,255 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package metrics collects metrics about the phases of a kubetest2 run, so
+// that they can be written out in the OpenMetrics text format or pushed to a
+// Prometheus pushgateway, e.g. to alert on creeping cluster bring-up times.
+package metrics
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Names of the metrics, all of the metrics are gauges
+// as every run pushes a complete set of samples
+const (
+	PhaseDurationMetric = "kubetest2_phase_duration_seconds"
+	PhaseSuccessMetric  = "kubetest2_phase_success"
+	PhaseRetriesMetric  = "kubetest2_phase_retries"
+)
+
+var help = map[string]string{
+	PhaseDurationMetric: "Duration of the phase of the run in seconds.",
+	PhaseSuccessMetric:  "Whether the phase of the run succeeded (1) or failed (0).",
+	PhaseRetriesMetric:  "Number of times the phase of the run was retried.",
+}
+
+type phase struct {
+	name     string
+	duration time.Duration
+	success  bool
+}
+
+// Registry accumulates the metrics of a run, it is safe for concurrent use
+type Registry struct {
+	mu      sync.Mutex
+	labels  map[string]string
+	phases  []phase
+	retries map[string]int
+}
+
+// NewRegistry returns a registry whose samples all have the given labels
+func NewRegistry(labels map[string]string) *Registry {
+	return &Registry{
+		labels:  labels,
+		retries: map[string]int{},
+	}
+}
+
+var (
+	defaultMu       sync.Mutex
+	defaultRegistry = NewRegistry(nil)
+)
+
+// SetDefault sets the registry returned by Default
+func SetDefault(r *Registry) {
+	defaultMu.Lock()
+	defer defaultMu.Unlock()
+	defaultRegistry = r
+}
+
+// Default returns the registry of the run, which deployers can use to record
+// e.g. retries without having it threaded through
+func Default() *Registry {
+	defaultMu.Lock()
+	defer defaultMu.Unlock()
+	return defaultRegistry
+}
+
+// Phase is the observation of a phase of the run
+type Phase struct {
+	Name     string
+	Duration time.Duration
+	Success  bool
+}
+
+// Phases returns the phases observed so far, in the order they were first observed
+func (r *Registry) Phases() []Phase {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	phases := make([]Phase, 0, len(r.phases))
+	for _, p := range r.phases {
+		phases = append(phases, Phase{Name: p.name, Duration: p.duration, Success: p.success})
+	}
+	return phases
+}
+
+// ObservePhase records the duration and the result of a phase of the run.
+// Observing a phase again (e.g. Down on interrupt) replaces the earlier observation.
+func (r *Registry) ObservePhase(name string, duration time.Duration, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	p := phase{name: name, duration: duration, success: err == nil}
+	for i := range r.phases {
+		if r.phases[i].name == name {
+			r.phases[i] = p
+			return
+		}
+	}
+	r.phases = append(r.phases, p)
+}
+
+// AddRetries adds count to the retries of the phase
+func (r *Registry) AddRetries(phase string, count int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.retries[phase] += count
+}
+
+// Write writes the metrics in the Prometheus text exposition format,
+// which is also valid OpenMetrics save for the terminating "# EOF"
+func (r *Registry) Write(w io.Writer) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var buf bytes.Buffer
+	writeFamily := func(name string, samples func()) {
+		fmt.Fprintf(&buf, "# HELP %s %s\n", name, help[name])
+		fmt.Fprintf(&buf, "# TYPE %s gauge\n", name)
+		samples()
+	}
+	writeFamily(PhaseDurationMetric, func() {
+		for _, p := range r.phases {
+			r.writeSample(&buf, PhaseDurationMetric, p.name, p.duration.Seconds())
+		}
+	})
+	writeFamily(PhaseSuccessMetric, func() {
+		for _, p := range r.phases {
+			value := 0.0
+			if p.success {
+				value = 1
+			}
+			r.writeSample(&buf, PhaseSuccessMetric, p.name, value)
+		}
+	})
+	writeFamily(PhaseRetriesMetric, func() {
+		phases := make([]string, 0, len(r.retries))
+		for name := range r.retries {
+			phases = append(phases, name)
+		}
+		sort.Strings(phases)
+		for _, name := range phases {
+			r.writeSample(&buf, PhaseRetriesMetric, name, float64(r.retries[name]))
+		}
+	})
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+func (r *Registry) writeSample(buf *bytes.Buffer, metric, phase string, value float64) {
+	labels := map[string]string{"phase": phase}
+	for k, v := range r.labels {
+		labels[k] = v
+	}
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	pairs := make([]string, 0, len(keys))
+	for _, k := range keys {
+		pairs = append(pairs, fmt.Sprintf("%s=\"%s\"", k, escapeLabelValue(labels[k])))
+	}
+	fmt.Fprintf(buf, "%s{%s} %v\n", metric, strings.Join(pairs, ","), value)
+}
+
+var labelValueEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escapeLabelValue(v string) string {
+	return labelValueEscaper.Replace(v)
+}
+
+// WriteFile writes the metrics to path as an OpenMetrics file
+func (r *Registry) WriteFile(path string) error {
+	var buf bytes.Buffer
+	if err := r.Write(&buf); err != nil {
+		return err
+	}
+	buf.WriteString("# EOF\n")
+	return ioutil.WriteFile(path, buf.Bytes(), 0644)
+}
+
+// pushURL returns the URL of the pushgateway group for the job and grouping labels
+func pushURL(gateway, job string, grouping map[string]string) (string, error) {
+	if job == "" {
+		return "", fmt.Errorf("a job name is required to push metrics")
+	}
+	u, err := url.Parse(gateway)
+	if err != nil {
+		return "", fmt.Errorf("invalid pushgateway url %q: %v", gateway, err)
+	}
+	if u.Scheme == "" || u.Host == "" {
+		return "", fmt.Errorf("invalid pushgateway url %q: expected e.g. http://pushgateway:9091", gateway)
+	}
+	path := strings.TrimSuffix(u.Path, "/") + "/metrics/job/" + url.PathEscape(job)
+	keys := make([]string, 0, len(grouping))
+	for k := range grouping {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		path += "/" + url.PathEscape(k) + "/" + url.PathEscape(grouping[k])
+	}
+	u.Path = ""
+	return u.String() + path, nil
+}
+
+// Push replaces the metrics of the job and grouping labels in the pushgateway
+func (r *Registry) Push(gateway, job string, grouping map[string]string) error {
+	target, err := pushURL(gateway, job, grouping)
+	if err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	if err := r.Write(&buf); err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPut, target, &buf)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "text/plain; version=0.0.4")
+	client := &http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to push metrics to %s: %v", target, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		body, _ := ioutil.ReadAll(resp.Body)
+		return fmt.Errorf("failed to push metrics to %s: %s: %s", target, resp.Status, strings.TrimSpace(string(body)))
+	}
+	return nil
+}
@@ -0,0 +1,147 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package metrics
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestWrite(t *testing.T) {
+	r := NewRegistry(map[string]string{"deployer": "gke"})
+	r.ObservePhase("Up", 90*time.Second, nil)
+	r.ObservePhase("Test", 2*time.Second, errors.New("failed"))
+	// observing a phase again replaces the earlier observation
+	r.ObservePhase("Up", 95*time.Second, nil)
+	r.AddRetries("Up", 1)
+	r.AddRetries("Up", 1)
+
+	var buf bytes.Buffer
+	if err := r.Write(&buf); err != nil {
+		t.Fatalf("did not expect an error, but got: %v", err)
+	}
+	expected := `# HELP kubetest2_phase_duration_seconds Duration of the phase of the run in seconds.
+# TYPE kubetest2_phase_duration_seconds gauge
+kubetest2_phase_duration_seconds{deployer="gke",phase="Up"} 95
+kubetest2_phase_duration_seconds{deployer="gke",phase="Test"} 2
+# HELP kubetest2_phase_success Whether the phase of the run succeeded (1) or failed (0).
+# TYPE kubetest2_phase_success gauge
+kubetest2_phase_success{deployer="gke",phase="Up"} 1
+kubetest2_phase_success{deployer="gke",phase="Test"} 0
+# HELP kubetest2_phase_retries Number of times the phase of the run was retried.
+# TYPE kubetest2_phase_retries gauge
+kubetest2_phase_retries{deployer="gke",phase="Up"} 2
+`
+	if buf.String() != expected {
+		t.Errorf("expected metrics:\n%s\nbut got:\n%s", expected, buf.String())
+	}
+}
+
+func TestEscapeLabelValue(t *testing.T) {
+	actual := escapeLabelValue("a\\b\"c\nd")
+	expected := `a\\b\"c\nd`
+	if actual != expected {
+		t.Errorf("expected %s, but got %s", expected, actual)
+	}
+}
+
+func TestPushURL(t *testing.T) {
+	testCases := []struct {
+		name        string
+		gateway     string
+		job         string
+		grouping    map[string]string
+		expected    string
+		expectError bool
+	}{
+		{
+			name:     "job only",
+			gateway:  "http://pushgateway:9091",
+			job:      "ci-kubernetes-e2e",
+			expected: "http://pushgateway:9091/metrics/job/ci-kubernetes-e2e",
+		},
+		{
+			name:     "grouping labels are sorted and escaped",
+			gateway:  "https://example.com/prefix/",
+			job:      "job",
+			grouping: map[string]string{"instance": "a/b", "deployer": "gke"},
+			expected: "https://example.com/prefix/metrics/job/job/deployer/gke/instance/a%2Fb",
+		},
+		{
+			name:        "missing scheme",
+			gateway:     "pushgateway:9091",
+			job:         "job",
+			expectError: true,
+		},
+		{
+			name:        "missing job",
+			gateway:     "http://pushgateway:9091",
+			expectError: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			actual, err := pushURL(tc.gateway, tc.job, tc.grouping)
+			if tc.expectError {
+				if err == nil {
+					t.Errorf("expected an error but got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Errorf("did not expect an error, but got: %v", err)
+			}
+			if actual != tc.expected {
+				t.Errorf("expected url %s, but got %s", tc.expected, actual)
+			}
+		})
+	}
+}
+
+func TestPush(t *testing.T) {
+	var method, path string
+	var body []byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		method, path = req.Method, req.URL.EscapedPath()
+		body, _ = ioutil.ReadAll(req.Body)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	r := NewRegistry(nil)
+	r.ObservePhase("Down", time.Second, nil)
+	if err := r.Push(server.URL, "job", map[string]string{"deployer": "kind"}); err != nil {
+		t.Fatalf("did not expect an error, but got: %v", err)
+	}
+	if method != http.MethodPut {
+		t.Errorf("expected method PUT, but got %s", method)
+	}
+	if path != "/metrics/job/job/deployer/kind" {
+		t.Errorf("unexpected push path %s", path)
+	}
+	if !bytes.Contains(body, []byte(`kubetest2_phase_duration_seconds{phase="Down"} 1`)) {
+		t.Errorf("expected the pushed metrics to contain the Down phase, but got:\n%s", body)
+	}
+}
@@ -0,0 +1,173 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package mirror implements copying the container images needed by a run to a
+// mirror registry ahead of time, for environments where the clusters and the
+// tests cannot pull from the public registries.
+package mirror
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"k8s.io/klog"
+
+	"sigs.k8s.io/kubetest2/pkg/exec"
+)
+
+// RepoListFile is the name of the KUBE_TEST_REPO_LIST file written to the run dir,
+// pointing the e2e tests at the mirrored images
+const RepoListFile = "test-repo-list.yaml"
+
+// the registry of the images without an explicit one
+const defaultHost = "docker.io"
+
+// e2eRegistries are the registries of the images used by the e2e tests,
+// keyed by their KUBE_TEST_REPO_LIST name
+// https://github.com/kubernetes/kubernetes/blob/master/test/utils/image/manifest.go
+var e2eRegistries = map[string]string{
+	"gcRegistry":               "registry.k8s.io",
+	"gcEtcdRegistry":           "registry.k8s.io",
+	"e2eRegistry":              "registry.k8s.io/e2e-test-images",
+	"promoterE2eRegistry":      "registry.k8s.io/e2e-test-images",
+	"buildImageRegistry":       "registry.k8s.io/build-image",
+	"sigStorageRegistry":       "registry.k8s.io/sig-storage",
+	"cloudProviderGcpRegistry": "registry.k8s.io/cloud-provider-gcp",
+	"dockerLibraryRegistry":    "docker.io/library",
+}
+
+// Mirror is a registry the images are copied to with their repository path,
+// e.g. registry.k8s.io/pause:3.9 is mirrored as <Registry>/pause:3.9
+type Mirror struct {
+	// Registry is the registry and optional repository of the mirror
+	// e.g. localhost:5001 or us-docker.pkg.dev/project/mirror
+	Registry string
+	// Insecure allows copying to a plain http registry
+	Insecure bool
+}
+
+// ReadImages reads the images of a file listing one image per line,
+// ignoring the empty lines and the # comments
+func ReadImages(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the images to mirror: %v", err)
+	}
+	defer f.Close()
+	var images []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if i := strings.Index(line, "#"); i >= 0 {
+			line = line[:i]
+		}
+		if line = strings.TrimSpace(line); line != "" {
+			images = append(images, line)
+		}
+	}
+	return images, scanner.Err()
+}
+
+// splitHost returns the registry host and the repository path of an image,
+// following the docker conventions for the images without a registry
+func splitHost(image string) (string, string) {
+	parts := strings.SplitN(image, "/", 2)
+	if len(parts) == 2 && (strings.ContainsAny(parts[0], ".:") || parts[0] == "localhost") {
+		return parts[0], parts[1]
+	}
+	if len(parts) == 1 {
+		return defaultHost, "library/" + image
+	}
+	return defaultHost, image
+}
+
+// Ref returns the reference of the mirrored image
+func (m *Mirror) Ref(image string) string {
+	_, path := splitHost(image)
+	return strings.TrimSuffix(m.Registry, "/") + "/" + path
+}
+
+// Hosts returns the sorted registry hosts of the images
+func Hosts(images []string) []string {
+	seen := map[string]bool{}
+	var hosts []string
+	for _, image := range images {
+		host, _ := splitHost(image)
+		if !seen[host] {
+			seen[host] = true
+			hosts = append(hosts, host)
+		}
+	}
+	sort.Strings(hosts)
+	return hosts
+}
+
+// Copy copies the images to the mirror with crane, with all their platforms
+func (m *Mirror) Copy(images []string) error {
+	for _, image := range images {
+		ref := m.Ref(image)
+		klog.V(0).Infof("Mirroring image %s to %s ...", image, ref)
+		args := []string{"copy", image, ref}
+		if m.Insecure {
+			args = append(args, "--insecure")
+		}
+		cmd := exec.Command("crane", args...)
+		exec.InheritOutput(cmd)
+		if err := cmd.Run(); err != nil {
+			return fmt.Errorf("failed to mirror image %s: %v", image, err)
+		}
+	}
+	return nil
+}
+
+// RepoList returns the KUBE_TEST_REPO_LIST content pointing the e2e tests at the mirror
+func (m *Mirror) RepoList() string {
+	names := make([]string, 0, len(e2eRegistries))
+	for name := range e2eRegistries {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var b strings.Builder
+	for _, name := range names {
+		fmt.Fprintf(&b, "%s: %s\n", name, m.Ref(e2eRegistries[name]+"/"))
+	}
+	return b.String()
+}
+
+// WriteRepoList writes the RepoListFile to dir, it is picked up by the ginkgo tester
+func (m *Mirror) WriteRepoList(dir string) error {
+	path := filepath.Join(dir, RepoListFile)
+	if err := ioutil.WriteFile(path, []byte(m.RepoList()), 0644); err != nil {
+		return fmt.Errorf("failed to write the test repo list: %v", err)
+	}
+	klog.V(1).Infof("Wrote the KUBE_TEST_REPO_LIST of the mirror %s to %s", m.Registry, path)
+	return nil
+}
+
+// ContainerdConfigPatch returns the containerd configuration pulling the images
+// of the hosts from the mirror endpoint, e.g. http://kind-registry:5000
+func ContainerdConfigPatch(hosts []string, endpoint string) string {
+	var b strings.Builder
+	for _, host := range hosts {
+		fmt.Fprintf(&b, "[plugins.\"io.containerd.grpc.v1.cri\".registry.mirrors.%q]\n  endpoint = [%q]\n", host, endpoint)
+	}
+	return b.String()
+}
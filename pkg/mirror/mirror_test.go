@@ -0,0 +1,90 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mirror
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestRef(t *testing.T) {
+	m := &Mirror{Registry: "localhost:5001/"}
+	testCases := []struct {
+		image    string
+		expected string
+	}{
+		{image: "registry.k8s.io/pause:3.9", expected: "localhost:5001/pause:3.9"},
+		{image: "registry.k8s.io/e2e-test-images/agnhost:2.39", expected: "localhost:5001/e2e-test-images/agnhost:2.39"},
+		{image: "nginx:1.21", expected: "localhost:5001/library/nginx:1.21"},
+		{image: "busybox/busybox:1.29", expected: "localhost:5001/busybox/busybox:1.29"},
+		{image: "localhost/foo@sha256:abcd", expected: "localhost:5001/foo@sha256:abcd"},
+		{image: "gcr.io:443/project/image", expected: "localhost:5001/project/image"},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.image, func(t *testing.T) {
+			t.Parallel()
+			if actual := m.Ref(tc.image); actual != tc.expected {
+				t.Errorf("expected %s, but got %s", tc.expected, actual)
+			}
+		})
+	}
+}
+
+func TestHosts(t *testing.T) {
+	images := []string{"registry.k8s.io/pause:3.9", "nginx", "quay.io/cilium/cilium:v1.10", "registry.k8s.io/etcd:3.5"}
+	expected := []string{"docker.io", "quay.io", "registry.k8s.io"}
+	if actual := Hosts(images); !reflect.DeepEqual(actual, expected) {
+		t.Errorf("expected %v, but got %v", expected, actual)
+	}
+}
+
+func TestReadImages(t *testing.T) {
+	dir, err := ioutil.TempDir("", "mirror")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "images.txt")
+	content := "# e2e images\nregistry.k8s.io/pause:3.9\n\n  nginx:1.21  # for the webserver tests\n"
+	if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	images, err := ReadImages(path)
+	if err != nil {
+		t.Fatalf("did not expect an error, but got: %v", err)
+	}
+	expected := []string{"registry.k8s.io/pause:3.9", "nginx:1.21"}
+	if !reflect.DeepEqual(images, expected) {
+		t.Errorf("expected %v, but got %v", expected, images)
+	}
+}
+
+func TestContainerdConfigPatch(t *testing.T) {
+	expected := `[plugins."io.containerd.grpc.v1.cri".registry.mirrors."docker.io"]
+  endpoint = ["http://kind-registry:5000"]
+[plugins."io.containerd.grpc.v1.cri".registry.mirrors."registry.k8s.io"]
+  endpoint = ["http://kind-registry:5000"]
+`
+	if actual := ContainerdConfigPatch([]string{"docker.io", "registry.k8s.io"}, "http://kind-registry:5000"); actual != expected {
+		t.Errorf("expected:\n%s\nbut got:\n%s", expected, actual)
+	}
+}
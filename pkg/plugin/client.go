@@ -0,0 +1,177 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package plugin
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"sync"
+	"time"
+
+	"k8s.io/klog"
+
+	"sigs.k8s.io/kubetest2/pkg/metadata"
+)
+
+// shutdownTimeout bounds how long a plugin may take to exit after shutdown
+const shutdownTimeout = 30 * time.Second
+
+// Client speaks the plugin protocol with a plugin process
+type Client struct {
+	cmd     *exec.Cmd
+	stdin   io.WriteCloser
+	decoder *json.Decoder
+
+	// calls are serialized as the protocol is strictly request / response
+	mu     sync.Mutex
+	nextID int
+
+	handshake *HandshakeResult
+}
+
+// Start starts the plugin at path and performs the handshake
+func Start(path string) (*Client, error) {
+	cmd := exec.Command(path)
+	cmd.Env = append(os.Environ(), fmt.Sprintf("%s=%d", ProtocolVersionEnv, ProtocolVersion))
+	cmd.Stderr = os.Stderr
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("failed to start plugin %s: %v", path, err)
+	}
+	c := newClient(stdout, stdin)
+	c.cmd = cmd
+	if _, err := c.Handshake(); err != nil {
+		_ = cmd.Process.Kill()
+		_ = cmd.Wait()
+		return nil, fmt.Errorf("plugin %s: %v", path, err)
+	}
+	return c, nil
+}
+
+func newClient(r io.Reader, w io.WriteCloser) *Client {
+	return &Client{
+		stdin:   w,
+		decoder: json.NewDecoder(r),
+		nextID:  1,
+	}
+}
+
+// Handshake negotiates the protocol version and returns the description and
+// capabilities of the plugin, it is only sent to the plugin once
+func (c *Client) Handshake() (*HandshakeResult, error) {
+	if c.handshake != nil {
+		return c.handshake, nil
+	}
+	result := &HandshakeResult{}
+	if err := c.Call(MethodHandshake, &HandshakeParams{ProtocolVersion: ProtocolVersion}, result); err != nil {
+		return nil, err
+	}
+	if err := checkProtocolVersion(result.ProtocolVersion); err != nil {
+		return nil, err
+	}
+	c.handshake = result
+	return result, nil
+}
+
+// Supports returns true if the plugin implements the optional method
+func (c *Client) Supports(method string) bool {
+	if c.handshake == nil {
+		return false
+	}
+	for _, capability := range c.handshake.Capabilities {
+		if capability == method {
+			return true
+		}
+	}
+	return false
+}
+
+// Call calls method with params, decoding the result into result if it is not nil
+func (c *Client) Call(method string, params, result interface{}) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	request := &Request{ID: c.nextID, Method: method}
+	c.nextID++
+	if params != nil {
+		raw, err := json.Marshal(params)
+		if err != nil {
+			return err
+		}
+		request.Params = raw
+	}
+	line, err := json.Marshal(request)
+	if err != nil {
+		return err
+	}
+	if _, err := c.stdin.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("failed to send %s to the plugin: %v", method, err)
+	}
+
+	response := &Response{}
+	if err := c.decoder.Decode(response); err != nil {
+		return fmt.Errorf("failed to read the %s response of the plugin: %v", method, err)
+	}
+	if response.ID != request.ID {
+		return fmt.Errorf("plugin responded to request %d, expected %d", response.ID, request.ID)
+	}
+	if len(response.Metadata) > 0 {
+		if err := metadata.Default().SetAll(response.Metadata); err != nil {
+			klog.Warningf("failed to record the metadata of the plugin: %v", err)
+		}
+	}
+	if response.Error != "" {
+		return fmt.Errorf("%s: %s", method, response.Error)
+	}
+	if result != nil && len(response.Result) > 0 {
+		if err := json.Unmarshal(response.Result, result); err != nil {
+			return fmt.Errorf("failed to decode the %s result of the plugin: %v", method, err)
+		}
+	}
+	return nil
+}
+
+// Close asks the plugin to shut down and waits for it to exit
+func (c *Client) Close() error {
+	if err := c.Call(MethodShutdown, nil, nil); err != nil {
+		klog.Warningf("plugin shutdown failed: %v", err)
+	}
+	_ = c.stdin.Close()
+	if c.cmd == nil {
+		return nil
+	}
+	done := make(chan error, 1)
+	go func() { done <- c.cmd.Wait() }()
+	select {
+	case err := <-done:
+		return err
+	case <-time.After(shutdownTimeout):
+		klog.Warningf("plugin did not exit %v after shutdown, killing it", shutdownTimeout)
+		_ = c.cmd.Process.Kill()
+		return <-done
+	}
+}
@@ -0,0 +1,162 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package plugin
+
+import (
+	"fmt"
+
+	"github.com/spf13/pflag"
+
+	"sigs.k8s.io/kubetest2/pkg/types"
+)
+
+// Deployer implements types.Deployer by calling a plugin
+type Deployer struct {
+	client      *Client
+	description types.Description
+	opts        types.Options
+	flags       *pflag.FlagSet
+	configured  bool
+}
+
+// assert that Deployer implements the optional deployer interfaces
+var (
+	_ types.DeployerWithKubeconfig  = &Deployer{}
+	_ types.DeployerWithProvider    = &Deployer{}
+	_ types.DeployerWithVersion     = &Deployer{}
+	_ types.DeployerWithPostTester  = &Deployer{}
+	_ types.DeployerWithDescription = &Deployer{}
+)
+
+// NewDeployer implements types.NewDeployer for the plugin, the flags of the
+// returned flag set are the flags described by the plugin in the handshake
+func (c *Client) NewDeployer(opts types.Options) (types.Deployer, *pflag.FlagSet) {
+	d := &Deployer{
+		client: c,
+		opts:   opts,
+	}
+	if c.handshake != nil {
+		d.description = c.handshake.Description
+	}
+	d.flags = pflag.NewFlagSet(d.description.Name, pflag.ContinueOnError)
+	for _, f := range d.description.Flags {
+		d.flags.String(f.Name, f.Default, f.Usage)
+		if f.Type == "bool" {
+			d.flags.Lookup(f.Name).NoOptDefVal = "true"
+		}
+	}
+	return d, d.flags
+}
+
+// configure sends the flags and options of the run to the plugin before the first call
+func (d *Deployer) configure() error {
+	if d.configured {
+		return nil
+	}
+	params := &ConfigureParams{
+		Flags:   map[string]string{},
+		Options: NewOptions(d.opts),
+	}
+	d.flags.Visit(func(f *pflag.Flag) {
+		params.Flags[f.Name] = f.Value.String()
+	})
+	if err := d.client.Call(MethodConfigure, params, nil); err != nil {
+		return fmt.Errorf("failed to configure plugin %s: %v", d.description.Name, err)
+	}
+	d.configured = true
+	return nil
+}
+
+func (d *Deployer) call(method string, params, result interface{}) error {
+	if err := d.configure(); err != nil {
+		return err
+	}
+	if err := d.client.Call(method, params, result); err != nil {
+		return fmt.Errorf("plugin %s: %v", d.description.Name, err)
+	}
+	return nil
+}
+
+func (d *Deployer) Build() error {
+	return d.call(MethodBuild, nil, nil)
+}
+
+func (d *Deployer) Up() error {
+	return d.call(MethodUp, nil, nil)
+}
+
+func (d *Deployer) IsUp() (bool, error) {
+	result := &IsUpResult{}
+	err := d.call(MethodIsUp, nil, result)
+	return result.Up, err
+}
+
+func (d *Deployer) Down() error {
+	return d.call(MethodDown, nil, nil)
+}
+
+func (d *Deployer) DumpClusterLogs() error {
+	return d.call(MethodDumpClusterLogs, nil, nil)
+}
+
+// value calls an optional method returning a string, or returns "" if it is not implemented
+func (d *Deployer) value(method string) (string, error) {
+	if !d.client.Supports(method) {
+		return "", nil
+	}
+	result := &ValueResult{}
+	err := d.call(method, nil, result)
+	return result.Value, err
+}
+
+// Kubeconfig returns the kubeconfig of the plugin, or "" for the default kubeconfig
+func (d *Deployer) Kubeconfig() (string, error) {
+	return d.value(MethodKubeconfig)
+}
+
+func (d *Deployer) Provider() string {
+	provider, err := d.value(MethodProvider)
+	if err != nil {
+		return ""
+	}
+	return provider
+}
+
+// Version returns the version reported by the plugin, falling back to the
+// version in the description of the handshake
+func (d *Deployer) Version() string {
+	version, err := d.value(MethodVersion)
+	if err != nil || version == "" {
+		return d.description.Version
+	}
+	return version
+}
+
+func (d *Deployer) Description() string {
+	return d.description.Description
+}
+
+func (d *Deployer) PostTest(testErr error) error {
+	if !d.client.Supports(MethodPostTest) {
+		return nil
+	}
+	params := &PostTestParams{}
+	if testErr != nil {
+		params.TestError = testErr.Error()
+	}
+	return d.call(MethodPostTest, params, nil)
+}
@@ -0,0 +1,52 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package plugin implements a JSON over stdio protocol for out of process
+// deployers, so that deployers can be written in any language and released
+// independently of kubetest2.
+//
+// A deployer plugin is an executable named kubetest2-plugin-<name> in PATH,
+// `kubetest2 <name>` runs it when there is no kubetest2-<name> deployer, with
+// KUBETEST2_PLUGIN_PROTOCOL_VERSION set to the protocol version of the driver.
+//
+// The driver writes one request per line to the stdin of the plugin:
+//
+//	{"id": 1, "method": "up", "params": {}}
+//
+// and the plugin writes exactly one response per line to stdout, in order:
+//
+//	{"id": 1, "result": {}, "error": "", "metadata": {"cluster-version": "1.22.1"}}
+//
+// A non empty error fails the method, and the metadata of any response is added
+// to the metadata.json of the run. The stderr of the plugin is passed through,
+// so plugins must log to stderr.
+//
+// The methods, in the order the driver calls them, are:
+//
+//   - handshake: params {"protocolVersion": 1}, the result is
+//     {"protocolVersion": 1, "description": {...}, "capabilities": [...]} where
+//     the description is the same as the --describe-json output of the in tree
+//     deployers, its flags are registered as the deployer flags, and the
+//     capabilities are the optional methods the plugin implements.
+//   - configure: params {"flags": {"name": "value"}, "options": {...}} with the
+//     flags set by the user and the kubetest2 options of the run.
+//   - build, up, isUp, down, dumpClusterLogs: no params, isUp results in
+//     {"up": true}.
+//   - kubeconfig, provider, version: optional, no params, result in
+//     {"value": "..."}.
+//   - postTest: optional, params {"testError": "..."}.
+//   - shutdown: the plugin should respond and exit.
+package plugin
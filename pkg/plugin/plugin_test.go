@@ -0,0 +1,153 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package plugin
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/spf13/pflag"
+
+	"sigs.k8s.io/kubetest2/pkg/types"
+)
+
+type fakeOptions struct{}
+
+func (o *fakeOptions) HelpRequested() bool       { return false }
+func (o *fakeOptions) ShouldBuild() bool         { return false }
+func (o *fakeOptions) ShouldUp() bool            { return true }
+func (o *fakeOptions) ShouldDown() bool          { return true }
+func (o *fakeOptions) ShouldTest() bool          { return false }
+func (o *fakeOptions) SkipTestJUnitReport() bool { return false }
+func (o *fakeOptions) RunID() string             { return "run" }
+func (o *fakeOptions) RunDir() string            { return "/tmp/run" }
+
+type fakeDeployer struct {
+	opts  types.Options
+	calls []string
+}
+
+func (d *fakeDeployer) Up() error {
+	d.calls = append(d.calls, "up "+d.opts.RunID()+" "+d.opts.RunDir())
+	return nil
+}
+
+func (d *fakeDeployer) Down() error {
+	return fmt.Errorf("cluster is gone")
+}
+
+func (d *fakeDeployer) IsUp() (bool, error) {
+	return true, nil
+}
+
+func (d *fakeDeployer) DumpClusterLogs() error { return nil }
+func (d *fakeDeployer) Build() error           { return nil }
+func (d *fakeDeployer) Version() string        { return "v1.2.3" }
+
+func (d *fakeDeployer) Kubeconfig() (string, error) {
+	return "/tmp/kubeconfig", nil
+}
+
+// startFake connects a client to a server for a fake deployer over pipes
+func startFake(t *testing.T) (*Client, *fakeDeployer, chan error) {
+	fake := &fakeDeployer{}
+	newDeployer := func(opts types.Options) (types.Deployer, *pflag.FlagSet) {
+		fake.opts = opts
+		return fake, pflag.NewFlagSet("fake", pflag.ContinueOnError)
+	}
+	// use OS pipes, that buffer like the stdin and stdout of a plugin process
+	requestsReader, requestsWriter, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("failed to create pipe: %v", err)
+	}
+	responsesReader, responsesWriter, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("failed to create pipe: %v", err)
+	}
+	served := make(chan error, 1)
+	go func() {
+		served <- serve(requestsReader, responsesWriter, "fake", newDeployer)
+		responsesWriter.Close()
+	}()
+	c := newClient(responsesReader, requestsWriter)
+	if _, err := c.Handshake(); err != nil {
+		t.Fatalf("handshake failed: %v", err)
+	}
+	return c, fake, served
+}
+
+func TestProtocol(t *testing.T) {
+	c, fake, served := startFake(t)
+
+	expectedHandshake := &HandshakeResult{
+		ProtocolVersion: ProtocolVersion,
+		Description: types.Description{
+			Name:    "fake",
+			Version: "v1.2.3",
+		},
+		Capabilities: []string{MethodKubeconfig, MethodVersion},
+	}
+	if !reflect.DeepEqual(c.handshake, expectedHandshake) {
+		t.Errorf("expected handshake %+v, but got %+v", expectedHandshake, c.handshake)
+	}
+
+	d, _ := c.NewDeployer(&fakeOptions{})
+	if err := d.Up(); err != nil {
+		t.Errorf("did not expect an error, but got: %v", err)
+	}
+	if up, err := d.IsUp(); !up || err != nil {
+		t.Errorf("expected the cluster to be up, but got: %v, %v", up, err)
+	}
+	if err := d.Down(); err == nil {
+		t.Errorf("expected the error of down to be returned")
+	}
+	kubeconfig, err := d.(types.DeployerWithKubeconfig).Kubeconfig()
+	if err != nil || kubeconfig != "/tmp/kubeconfig" {
+		t.Errorf("expected kubeconfig /tmp/kubeconfig, but got: %v, %v", kubeconfig, err)
+	}
+	// optional methods not implemented by the plugin are not called
+	if provider := d.(types.DeployerWithProvider).Provider(); provider != "" {
+		t.Errorf("expected no provider, but got %s", provider)
+	}
+	if err := d.(types.DeployerWithPostTester).PostTest(nil); err != nil {
+		t.Errorf("did not expect an error, but got: %v", err)
+	}
+
+	// the options are sent to the plugin before the first call
+	expectedCalls := []string{"up run /tmp/run"}
+	if !reflect.DeepEqual(fake.calls, expectedCalls) {
+		t.Errorf("expected calls %v, but got %v", expectedCalls, fake.calls)
+	}
+
+	if err := c.Close(); err != nil {
+		t.Errorf("did not expect an error, but got: %v", err)
+	}
+	if err := <-served; err != nil {
+		t.Errorf("did not expect the server to fail, but got: %v", err)
+	}
+}
+
+func TestCheckProtocolVersion(t *testing.T) {
+	if err := checkProtocolVersion(ProtocolVersion); err != nil {
+		t.Errorf("did not expect an error, but got: %v", err)
+	}
+	if err := checkProtocolVersion(ProtocolVersion + 1); err == nil {
+		t.Errorf("expected an error for a different protocol version")
+	}
+}
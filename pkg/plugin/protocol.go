@@ -0,0 +1,130 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package plugin
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"sigs.k8s.io/kubetest2/pkg/types"
+)
+
+// ProtocolVersion is the version of the plugin protocol, it is bumped on
+// incompatible changes
+const ProtocolVersion = 1
+
+// ProtocolVersionEnv is the environment variable that tells a plugin which
+// protocol version the driver speaks
+const ProtocolVersionEnv = "KUBETEST2_PLUGIN_PROTOCOL_VERSION"
+
+// The methods of the protocol
+const (
+	MethodHandshake       = "handshake"
+	MethodConfigure       = "configure"
+	MethodBuild           = "build"
+	MethodUp              = "up"
+	MethodIsUp            = "isUp"
+	MethodDown            = "down"
+	MethodDumpClusterLogs = "dumpClusterLogs"
+	MethodKubeconfig      = "kubeconfig"
+	MethodProvider        = "provider"
+	MethodVersion         = "version"
+	MethodPostTest        = "postTest"
+	MethodShutdown        = "shutdown"
+)
+
+// Request is a call from the driver to the plugin
+type Request struct {
+	ID     int             `json:"id"`
+	Method string          `json:"method"`
+	Params json.RawMessage `json:"params,omitempty"`
+}
+
+// Response is the reply of the plugin to a Request
+type Response struct {
+	ID       int               `json:"id"`
+	Result   json.RawMessage   `json:"result,omitempty"`
+	Error    string            `json:"error,omitempty"`
+	Metadata map[string]string `json:"metadata,omitempty"`
+}
+
+// HandshakeParams are the params of the handshake method
+type HandshakeParams struct {
+	ProtocolVersion int `json:"protocolVersion"`
+}
+
+// HandshakeResult is the result of the handshake method
+type HandshakeResult struct {
+	ProtocolVersion int               `json:"protocolVersion"`
+	Description     types.Description `json:"description"`
+	// Capabilities lists the optional methods implemented by the plugin
+	Capabilities []string `json:"capabilities,omitempty"`
+}
+
+// Options are the kubetest2 options of the run
+type Options struct {
+	RunID               string `json:"runID"`
+	RunDir              string `json:"runDir"`
+	ShouldBuild         bool   `json:"shouldBuild"`
+	ShouldUp            bool   `json:"shouldUp"`
+	ShouldDown          bool   `json:"shouldDown"`
+	ShouldTest          bool   `json:"shouldTest"`
+	SkipTestJUnitReport bool   `json:"skipTestJUnitReport"`
+}
+
+// NewOptions captures the kubetest2 options of the run
+func NewOptions(opts types.Options) Options {
+	return Options{
+		RunID:               opts.RunID(),
+		RunDir:              opts.RunDir(),
+		ShouldBuild:         opts.ShouldBuild(),
+		ShouldUp:            opts.ShouldUp(),
+		ShouldDown:          opts.ShouldDown(),
+		ShouldTest:          opts.ShouldTest(),
+		SkipTestJUnitReport: opts.SkipTestJUnitReport(),
+	}
+}
+
+// ConfigureParams are the params of the configure method
+type ConfigureParams struct {
+	// Flags are the deployer flags set by the user
+	Flags   map[string]string `json:"flags,omitempty"`
+	Options Options           `json:"options"`
+}
+
+// IsUpResult is the result of the isUp method
+type IsUpResult struct {
+	Up bool `json:"up"`
+}
+
+// ValueResult is the result of the kubeconfig, provider and version methods
+type ValueResult struct {
+	Value string `json:"value"`
+}
+
+// PostTestParams are the params of the postTest method
+type PostTestParams struct {
+	TestError string `json:"testError,omitempty"`
+}
+
+// checkProtocolVersion returns an error if the plugin speaks a different protocol version
+func checkProtocolVersion(version int) error {
+	if version != ProtocolVersion {
+		return fmt.Errorf("plugin speaks protocol version %d but kubetest2 speaks version %d", version, ProtocolVersion)
+	}
+	return nil
+}
@@ -0,0 +1,220 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package plugin
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/spf13/pflag"
+	"golang.org/x/sys/unix"
+
+	"sigs.k8s.io/kubetest2/pkg/types"
+)
+
+// Serve implements the plugin side of the protocol on stdin / stdout for a
+// deployer written in go, so that it can be released independently of kubetest2
+func Serve(name string, newDeployer types.NewDeployer) error {
+	protocol, err := redirectStdout()
+	if err != nil {
+		return err
+	}
+	defer protocol.Close()
+	return serve(os.Stdin, protocol, name, newDeployer)
+}
+
+// redirectStdout returns a duplicate of stdout to write the responses to, and
+// points stdout at stderr, so that the output of the deployer and of the
+// commands it runs is not mixed into the responses
+func redirectStdout() (*os.File, error) {
+	fd, err := unix.Dup(int(os.Stdout.Fd()))
+	if err != nil {
+		return nil, fmt.Errorf("failed to duplicate stdout: %v", err)
+	}
+	if err := unix.Dup2(int(os.Stderr.Fd()), int(os.Stdout.Fd())); err != nil {
+		unix.Close(fd)
+		return nil, fmt.Errorf("failed to redirect stdout to stderr: %v", err)
+	}
+	return os.NewFile(uintptr(fd), "protocol"), nil
+}
+
+// serverOptions implements types.Options with the options sent by the driver in configure
+type serverOptions struct {
+	Options
+}
+
+var _ types.Options = &serverOptions{}
+
+func (o *serverOptions) HelpRequested() bool       { return false }
+func (o *serverOptions) ShouldBuild() bool         { return o.Options.ShouldBuild }
+func (o *serverOptions) ShouldUp() bool            { return o.Options.ShouldUp }
+func (o *serverOptions) ShouldDown() bool          { return o.Options.ShouldDown }
+func (o *serverOptions) ShouldTest() bool          { return o.Options.ShouldTest }
+func (o *serverOptions) SkipTestJUnitReport() bool { return o.Options.SkipTestJUnitReport }
+func (o *serverOptions) RunID() string             { return o.Options.RunID }
+func (o *serverOptions) RunDir() string            { return o.Options.RunDir }
+
+type server struct {
+	name     string
+	opts     *serverOptions
+	deployer types.Deployer
+	flags    *pflag.FlagSet
+}
+
+func serve(r io.Reader, w io.Writer, name string, newDeployer types.NewDeployer) error {
+	s := &server{name: name, opts: &serverOptions{}}
+	// the options are filled in by configure, before any other deployer method is called
+	s.deployer, s.flags = newDeployer(s.opts)
+	if s.flags == nil {
+		s.flags = pflag.NewFlagSet(name, pflag.ContinueOnError)
+	}
+
+	decoder := json.NewDecoder(r)
+	for {
+		request := &Request{}
+		if err := decoder.Decode(request); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return fmt.Errorf("failed to read request: %v", err)
+		}
+		response := &Response{ID: request.ID}
+		result, err := s.handle(request)
+		if err != nil {
+			response.Error = err.Error()
+		} else if result != nil {
+			raw, err := json.Marshal(result)
+			if err != nil {
+				return err
+			}
+			response.Result = raw
+		}
+		line, err := json.Marshal(response)
+		if err != nil {
+			return err
+		}
+		if _, err := w.Write(append(line, '\n')); err != nil {
+			return fmt.Errorf("failed to write response: %v", err)
+		}
+		if request.Method == MethodShutdown {
+			return nil
+		}
+	}
+}
+
+func (s *server) handle(request *Request) (interface{}, error) {
+	switch request.Method {
+	case MethodHandshake:
+		params := &HandshakeParams{}
+		if err := decodeParams(request, params); err != nil {
+			return nil, err
+		}
+		if err := checkProtocolVersion(params.ProtocolVersion); err != nil {
+			return nil, err
+		}
+		return s.handshake(), nil
+	case MethodConfigure:
+		params := &ConfigureParams{}
+		if err := decodeParams(request, params); err != nil {
+			return nil, err
+		}
+		s.opts.Options = params.Options
+		for name, value := range params.Flags {
+			if err := s.flags.Set(name, value); err != nil {
+				return nil, fmt.Errorf("invalid value %q for flag --%s: %v", value, name, err)
+			}
+		}
+		return nil, nil
+	case MethodBuild:
+		return nil, s.deployer.Build()
+	case MethodUp:
+		return nil, s.deployer.Up()
+	case MethodIsUp:
+		up, err := s.deployer.IsUp()
+		return &IsUpResult{Up: up}, err
+	case MethodDown:
+		return nil, s.deployer.Down()
+	case MethodDumpClusterLogs:
+		return nil, s.deployer.DumpClusterLogs()
+	case MethodKubeconfig:
+		if d, ok := s.deployer.(types.DeployerWithKubeconfig); ok {
+			kubeconfig, err := d.Kubeconfig()
+			return &ValueResult{Value: kubeconfig}, err
+		}
+	case MethodProvider:
+		if d, ok := s.deployer.(types.DeployerWithProvider); ok {
+			return &ValueResult{Value: d.Provider()}, nil
+		}
+	case MethodVersion:
+		if d, ok := s.deployer.(types.DeployerWithVersion); ok {
+			return &ValueResult{Value: d.Version()}, nil
+		}
+	case MethodPostTest:
+		if d, ok := s.deployer.(types.DeployerWithPostTester); ok {
+			params := &PostTestParams{}
+			if err := decodeParams(request, params); err != nil {
+				return nil, err
+			}
+			var testErr error
+			if params.TestError != "" {
+				testErr = fmt.Errorf("%s", params.TestError)
+			}
+			return nil, d.PostTest(testErr)
+		}
+	case MethodShutdown:
+		return nil, nil
+	}
+	return nil, fmt.Errorf("method %s is not implemented", request.Method)
+}
+
+func (s *server) handshake() *HandshakeResult {
+	var version, description string
+	var capabilities []string
+	if _, ok := s.deployer.(types.DeployerWithKubeconfig); ok {
+		capabilities = append(capabilities, MethodKubeconfig)
+	}
+	if _, ok := s.deployer.(types.DeployerWithProvider); ok {
+		capabilities = append(capabilities, MethodProvider)
+	}
+	if d, ok := s.deployer.(types.DeployerWithVersion); ok {
+		version = d.Version()
+		capabilities = append(capabilities, MethodVersion)
+	}
+	if _, ok := s.deployer.(types.DeployerWithPostTester); ok {
+		capabilities = append(capabilities, MethodPostTest)
+	}
+	if d, ok := s.deployer.(types.DeployerWithDescription); ok {
+		description = d.Description()
+	}
+	return &HandshakeResult{
+		ProtocolVersion: ProtocolVersion,
+		Description:     *types.NewDescription(s.name, version, description, s.flags),
+		Capabilities:    capabilities,
+	}
+}
+
+func decodeParams(request *Request, params interface{}) error {
+	if len(request.Params) == 0 {
+		return nil
+	}
+	if err := json.Unmarshal(request.Params, params); err != nil {
+		return fmt.Errorf("invalid %s params: %v", request.Method, err)
+	}
+	return nil
+}
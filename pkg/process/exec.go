@@ -20,6 +20,8 @@ import (
 	"os"
 	"os/exec"
 	"os/signal"
+
+	kexec "sigs.k8s.io/kubetest2/pkg/exec"
 )
 
 // Exec generally mimics syscall.Exec behavior, but using a child process
@@ -34,7 +36,10 @@ func Exec(argv0 string, args []string, env []string) error {
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
 
-	return execCmdWithSignals(cmd)
+	recording := kexec.CurrentTranscript().Start(cmd)
+	err := execCmdWithSignals(cmd)
+	recording.Finish(err)
+	return err
 }
 
 func execCmdWithSignals(cmd *exec.Cmd) error {
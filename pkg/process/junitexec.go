@@ -23,7 +23,9 @@ import (
 	"os"
 	"os/exec"
 
+	kexec "sigs.k8s.io/kubetest2/pkg/exec"
 	"sigs.k8s.io/kubetest2/pkg/metadata"
+	"sigs.k8s.io/kubetest2/pkg/secrets"
 )
 
 type execJunitError struct {
@@ -57,14 +59,20 @@ func execJUnit(cmd *exec.Cmd, env []string) error {
 	cmd.Stdin = os.Stdin
 	// ensure we also capture output
 	var systemout bytes.Buffer
-	cmd.Stdout = io.MultiWriter(&systemout, os.Stdout)
-	cmd.Stderr = io.MultiWriter(&systemout, os.Stderr)
+	stdout, stderr := secrets.NewWriter(os.Stdout), secrets.NewWriter(os.Stderr)
+	cmd.Stdout = io.MultiWriter(&systemout, stdout)
+	cmd.Stderr = io.MultiWriter(&systemout, stderr)
 
 	// actually execute, return a JUnit error if the command errors
-	if err := execCmdWithSignals(cmd); err != nil {
+	recording := kexec.CurrentTranscript().Start(cmd)
+	err := execCmdWithSignals(cmd)
+	recording.Finish(err)
+	_ = stdout.Flush()
+	_ = stderr.Flush()
+	if err != nil {
 		return &execJunitError{
 			error:     err,
-			systemout: systemout.String(),
+			systemout: secrets.Redact(systemout.String()),
 		}
 	}
 	return nil
@@ -0,0 +1,438 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package projects acquires the GCP projects of a run from its
+// --project-source, so that the same job config works locally with explicit
+// projects, in CI with projects leased from boskos, or with the projects of
+// an environment variable, and releases them.
+package projects
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"time"
+
+	"k8s.io/klog"
+	"sigs.k8s.io/boskos/client"
+
+	"sigs.k8s.io/kubetest2/pkg/boskos"
+	"sigs.k8s.io/kubetest2/pkg/metadata"
+)
+
+// Source is where the projects of a run come from
+type Source string
+
+// The supported sources
+const (
+	// Static is the projects passed explicitly with --project
+	Static Source = "static"
+	// Boskos is the projects leased from boskos, cleaned up by its janitor once released
+	Boskos Source = "boskos"
+	// Env is the comma separated projects of $KUBETEST2_PROJECTS
+	Env Source = "env"
+)
+
+// EnvVar holds the projects of the env source
+const EnvVar = "KUBETEST2_PROJECTS"
+
+// BoskosRequest is a number of projects to lease of a boskos resource type
+type BoskosRequest struct {
+	ResourceType string
+	Count        int
+}
+
+// Config configures the projects of a run
+type Config struct {
+	// Source is the --project-source, empty to infer it from Projects
+	Source string
+	// Projects are the explicit projects, only used by the static source
+	Projects []string
+
+	BoskosLocation          string
+	BoskosRequests          []BoskosRequest
+	BoskosAcquireTimeout    time.Duration
+	BoskosHeartbeatInterval time.Duration
+
+	// LeaseFile persists the projects leased from boskos for the sequential
+	// invocations of a job in the same pod to reuse them, instead of leasing
+	// new ones per invocation. The projects are only released by the final one.
+	LeaseFile string
+	// LeaseFinal is set for the final invocation sharing the LeaseFile, which
+	// releases the projects and removes the file
+	LeaseFinal bool
+	// SkipMetadata does not record the projects in the metadata of the run,
+	// which are those of the deployer, e.g. for the projects of a tester
+	SkipMetadata bool
+}
+
+// Resolve returns the source of the projects and the projects that are known
+// without acquiring them, i.e. all of them but for the boskos source. The
+// source defaults to static if projects are passed explicitly and boskos
+// otherwise, as before --project-source existed.
+func (c *Config) Resolve() (Source, []string, error) {
+	source := Source(c.Source)
+	if source == "" {
+		source = Boskos
+		if len(c.Projects) > 0 {
+			source = Static
+		}
+	}
+	switch source {
+	case Static:
+		if len(c.Projects) == 0 {
+			return "", nil, fmt.Errorf("--project-source=static requires --project")
+		}
+		return source, c.Projects, nil
+	case Env:
+		if len(c.Projects) > 0 {
+			return "", nil, fmt.Errorf("--project cannot be used with --project-source=env")
+		}
+		projects := parseProjects(os.Getenv(EnvVar))
+		if len(projects) == 0 {
+			return "", nil, fmt.Errorf("--project-source=env requires $%s to list the projects", EnvVar)
+		}
+		return source, projects, nil
+	case Boskos:
+		if len(c.Projects) > 0 {
+			return "", nil, fmt.Errorf("--project cannot be used with --project-source=boskos")
+		}
+		return source, nil, nil
+	default:
+		return "", nil, fmt.Errorf("unknown --project-source %q, must be one of static, boskos or env", c.Source)
+	}
+}
+
+// parseProjects parses a comma separated list of projects
+func parseProjects(value string) []string {
+	var projects []string
+	for _, project := range strings.Split(value, ",") {
+		if project = strings.TrimSpace(project); project != "" {
+			projects = append(projects, project)
+		}
+	}
+	return projects
+}
+
+// Lease is the projects of a run, released with Release once the run is done
+// with them
+type Lease struct {
+	source         Source
+	projects       []string
+	boskos         *client.Client
+	heartbeatClose chan struct{}
+	// leaseFile and final are the LeaseFile and LeaseFinal of the config
+	leaseFile    string
+	final        bool
+	skipMetadata bool
+}
+
+// leaseFileContents is the lease persisted to the Config.LeaseFile
+type leaseFileContents struct {
+	Projects []string `json:"projects"`
+	// Updated is when an invocation last held the lease, i.e. the last
+	// heartbeat for boskos, which reaps the leases without recent heartbeats
+	Updated time.Time `json:"updated"`
+}
+
+// readLeaseFile returns the lease persisted to the file, nil if there is none
+func readLeaseFile(path string) (*leaseFileContents, error) {
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to read the boskos lease file: %v", err)
+	}
+	contents := &leaseFileContents{}
+	if err := json.Unmarshal(data, contents); err != nil {
+		return nil, fmt.Errorf("failed to parse the boskos lease file %s: %v", path, err)
+	}
+	if len(contents.Projects) == 0 {
+		return nil, fmt.Errorf("the boskos lease file %s has no projects", path)
+	}
+	return contents, nil
+}
+
+// writeLeaseFile persists the projects of the lease for the next invocation
+func (l *Lease) writeLeaseFile() error {
+	data, err := json.MarshalIndent(&leaseFileContents{Projects: l.projects, Updated: time.Now()}, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(l.leaseFile, data, 0644); err != nil {
+		return fmt.Errorf("failed to write the boskos lease file: %v", err)
+	}
+	return nil
+}
+
+// requestedCount returns the number of projects requested from boskos
+func (c *Config) requestedCount() int {
+	count := 0
+	for _, request := range c.BoskosRequests {
+		count += request.Count
+	}
+	return count
+}
+
+// reuseLeaseFile takes over the projects of the lease file of a previous
+// invocation, returning false if there is none or it cannot be reacquired
+func (l *Lease) reuseLeaseFile(c *Config) (bool, error) {
+	contents, err := readLeaseFile(c.LeaseFile)
+	if err != nil || contents == nil {
+		return false, err
+	}
+	if len(contents.Projects) != c.requestedCount() {
+		return false, fmt.Errorf("the boskos lease file %s has %d projects, but %d are requested", c.LeaseFile, len(contents.Projects), c.requestedCount())
+	}
+	boskosClient, err := boskos.NewClient(c.BoskosLocation)
+	if err != nil {
+		return false, fmt.Errorf("failed to make boskos client: %w", err)
+	}
+	if err := l.resumeLeaseFile(boskosClient, c, contents); err != nil {
+		klog.Warningf("Leasing new projects, as %v", err)
+		return false, nil
+	}
+	return true, nil
+}
+
+// resumeLeaseFile reacquires the projects of the lease file contents
+func (l *Lease) resumeLeaseFile(boskosClient *client.Client, c *Config, contents *leaseFileContents) error {
+	heartbeatClose := make(chan struct{})
+	if err := boskos.Resume(boskosClient, contents.Projects, c.BoskosHeartbeatInterval, heartbeatClose); err != nil {
+		// e.g. reaped by boskos since the last invocation held it
+		return fmt.Errorf("the projects of the boskos lease file last held %s ago could not be reacquired: %v",
+			time.Since(contents.Updated).Round(time.Second), err)
+	}
+	l.boskos = boskosClient
+	l.heartbeatClose = heartbeatClose
+	l.projects = contents.Projects
+	klog.V(1).Infof("Reusing projects %v of the boskos lease file %s", l.projects, c.LeaseFile)
+	return nil
+}
+
+// ResumeLeaseFile takes over the projects of the LeaseFile of a previous
+// invocation, failing rather than leasing new projects if they cannot be
+// reacquired, e.g. for a final invocation tearing down the clusters in them
+func ResumeLeaseFile(c *Config) (*Lease, error) {
+	contents, err := readLeaseFile(c.LeaseFile)
+	if err != nil {
+		return nil, err
+	}
+	if contents == nil {
+		return nil, fmt.Errorf("there is no boskos lease file %s", c.LeaseFile)
+	}
+	boskosClient, err := boskos.NewClient(c.BoskosLocation)
+	if err != nil {
+		return nil, fmt.Errorf("failed to make boskos client: %w", err)
+	}
+	l := &Lease{source: Boskos, skipMetadata: c.SkipMetadata}
+	if err := l.resumeLeaseFile(boskosClient, c, contents); err != nil {
+		return nil, err
+	}
+	l.leaseFile, l.final = c.LeaseFile, c.LeaseFinal
+	if err := l.writeLeaseFile(); err != nil {
+		return nil, err
+	}
+	l.recordMetadata()
+	return l, nil
+}
+
+// Acquire acquires the projects from their source, leasing them from boskos
+// for the boskos source, and records the source and the projects in the
+// metadata of the run
+func Acquire(c *Config) (*Lease, error) {
+	source, projects, err := c.Resolve()
+	if err != nil {
+		return nil, err
+	}
+	l := &Lease{source: source, projects: projects, skipMetadata: c.SkipMetadata}
+	if source == Boskos {
+		reused := false
+		if c.LeaseFile != "" {
+			if reused, err = l.reuseLeaseFile(c); err != nil {
+				return nil, err
+			}
+		}
+		if !reused {
+			if err := l.acquireFromBoskos(c); err != nil {
+				// do not hold on to the projects leased before the failure
+				if releaseErr := l.Release(); releaseErr != nil {
+					klog.Warningf("Failed to release the boskos projects: %v", releaseErr)
+				}
+				return nil, err
+			}
+		}
+		if c.LeaseFile != "" {
+			l.leaseFile, l.final = c.LeaseFile, c.LeaseFinal
+			// right away, so that the next invocation reuses the projects even
+			// if this one does not release them
+			if err := l.writeLeaseFile(); err != nil {
+				return nil, err
+			}
+		}
+	}
+	klog.V(1).Infof("Using the %s projects %v", source, l.projects)
+	l.recordMetadata()
+	return l, nil
+}
+
+// AcquireProject acquires the single project of a run from the source, or
+// uses the project if set
+func AcquireProject(c *Config, project string) (*Lease, error) {
+	if project != "" {
+		c.Projects = []string{project}
+	} else {
+		klog.V(1).Info("No GCP project provided, acquiring it from the project source")
+	}
+	lease, err := Acquire(c)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := lease.Project(); err != nil {
+		return nil, err
+	}
+	return lease, nil
+}
+
+func (l *Lease) acquireFromBoskos(c *Config) error {
+	boskosClient, err := boskos.NewClient(c.BoskosLocation)
+	if err != nil {
+		return fmt.Errorf("failed to make boskos client: %w", err)
+	}
+	l.boskos = boskosClient
+	l.heartbeatClose = make(chan struct{})
+	for _, request := range c.BoskosRequests {
+		for i := 0; i < request.Count; i++ {
+			resource, err := boskos.Acquire(l.boskos, request.ResourceType, c.BoskosAcquireTimeout, c.BoskosHeartbeatInterval, l.heartbeatClose)
+			if err != nil {
+				return fmt.Errorf("failed to get project from boskos: %w", err)
+			}
+			l.projects = append(l.projects, resource.Name)
+			klog.V(1).Infof("Got project %s from boskos", resource.Name)
+		}
+	}
+	if len(l.projects) == 0 {
+		return fmt.Errorf("no projects requested from boskos")
+	}
+	return nil
+}
+
+// Resume takes over the projects acquired by a previous invocation of a
+// resumed run, reacquiring the leases of the boskos source
+func Resume(c *Config, source Source, projects []string) (*Lease, error) {
+	l := &Lease{source: source, projects: projects, skipMetadata: c.SkipMetadata}
+	if source == Boskos {
+		boskosClient, err := boskos.NewClient(c.BoskosLocation)
+		if err != nil {
+			return nil, fmt.Errorf("failed to make boskos client: %w", err)
+		}
+		l.boskos = boskosClient
+		l.heartbeatClose = make(chan struct{})
+		if err := boskos.Resume(l.boskos, projects, c.BoskosHeartbeatInterval, l.heartbeatClose); err != nil {
+			return nil, err
+		}
+		klog.V(1).Infof("Reacquired projects %v from boskos", projects)
+		l.leaseFile, l.final = c.LeaseFile, c.LeaseFinal
+	}
+	l.recordMetadata()
+	return l, nil
+}
+
+func (l *Lease) recordMetadata() {
+	if l.skipMetadata {
+		return
+	}
+	values := map[string]string{
+		metadata.ProjectSourceKey: string(l.source),
+		metadata.ProjectsKey:      strings.Join(l.projects, ","),
+	}
+	if l.source == Boskos {
+		values[metadata.BoskosProjectsKey] = strings.Join(l.projects, ",")
+	}
+	if err := metadata.Default().SetAll(values); err != nil {
+		klog.Warningf("Failed to record the projects in the metadata: %v", err)
+	}
+}
+
+// Source returns the source of the projects, empty for a nil lease
+func (l *Lease) Source() Source {
+	if l == nil {
+		return ""
+	}
+	return l.source
+}
+
+// Projects returns the projects of the lease
+func (l *Lease) Projects() []string {
+	if l == nil {
+		return nil
+	}
+	return l.projects
+}
+
+// Project returns the project of a lease of a single project, for a lease of
+// several projects it releases them and fails
+func (l *Lease) Project() (string, error) {
+	if len(l.Projects()) != 1 {
+		if err := l.Release(); err != nil {
+			klog.Warningf("Failed to release the projects: %s", err)
+		}
+		return "", fmt.Errorf("a single project is required, got %v", l.Projects())
+	}
+	return l.projects[0], nil
+}
+
+// Leased returns true if the projects are leased from boskos, whose janitor
+// cleans them up once they are released
+func (l *Lease) Leased() bool {
+	return l.Source() == Boskos
+}
+
+// Kept returns true if the projects are kept leased from boskos for a later
+// invocation sharing the lease file, in which case the run should clean them
+// up itself rather than rely on the janitor
+func (l *Lease) Kept() bool {
+	return l.Leased() && l.leaseFile != "" && !l.final
+}
+
+// Release releases the projects leased from boskos, it is a no-op for the
+// other sources and once released. Kept projects are not released but handed
+// over to the next invocation through the lease file.
+func (l *Lease) Release() error {
+	if l == nil || l.boskos == nil {
+		return nil
+	}
+	if l.Kept() {
+		close(l.heartbeatClose)
+		l.boskos = nil
+		klog.V(1).Infof("Keeping projects %v leased for the next invocation sharing %s", l.projects, l.leaseFile)
+		return l.writeLeaseFile()
+	}
+	if len(l.projects) == 0 {
+		close(l.heartbeatClose)
+	} else if err := boskos.Release(l.boskos, l.projects, l.heartbeatClose); err != nil {
+		return err
+	}
+	l.boskos = nil
+	if l.leaseFile != "" {
+		if err := os.Remove(l.leaseFile); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove the boskos lease file: %v", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,224 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package projects
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"sigs.k8s.io/boskos/client"
+
+	"sigs.k8s.io/kubetest2/pkg/metadata"
+)
+
+func TestResolve(t *testing.T) {
+	os.Setenv(EnvVar, "p1, p2,")
+	defer os.Unsetenv(EnvVar)
+
+	testCases := []struct {
+		name             string
+		config           Config
+		expectedSource   Source
+		expectedProjects []string
+		expectError      bool
+	}{
+		{
+			name:             "inferred static",
+			config:           Config{Projects: []string{"p"}},
+			expectedSource:   Static,
+			expectedProjects: []string{"p"},
+		},
+		{
+			name:           "inferred boskos",
+			expectedSource: Boskos,
+		},
+		{
+			name:             "env",
+			config:           Config{Source: "env"},
+			expectedSource:   Env,
+			expectedProjects: []string{"p1", "p2"},
+		},
+		{
+			name:        "static without projects",
+			config:      Config{Source: "static"},
+			expectError: true,
+		},
+		{
+			name:        "boskos with projects",
+			config:      Config{Source: "boskos", Projects: []string{"p"}},
+			expectError: true,
+		},
+		{
+			name:        "env with projects",
+			config:      Config{Source: "env", Projects: []string{"p"}},
+			expectError: true,
+		},
+		{
+			name:        "unknown",
+			config:      Config{Source: "gcloud"},
+			expectError: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			source, projects, err := tc.config.Resolve()
+			if tc.expectError {
+				if err == nil {
+					t.Errorf("expected an error but got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Errorf("did not expect an error, but got: %v", err)
+			}
+			if source != tc.expectedSource || !reflect.DeepEqual(projects, tc.expectedProjects) {
+				t.Errorf("expected %s %v, but got %s %v", tc.expectedSource, tc.expectedProjects, source, projects)
+			}
+		})
+	}
+}
+
+func TestStaticLease(t *testing.T) {
+	l, err := Acquire(&Config{Projects: []string{"p"}})
+	if err != nil {
+		t.Fatalf("did not expect an error, but got: %v", err)
+	}
+	if l.Leased() {
+		t.Errorf("did not expect static projects to be leased")
+	}
+	if !reflect.DeepEqual(l.Projects(), []string{"p"}) {
+		t.Errorf("unexpected projects %v", l.Projects())
+	}
+	if err := l.Release(); err != nil {
+		t.Errorf("did not expect an error releasing static projects, but got: %v", err)
+	}
+	// e.g. a separate --down invocation which acquired nothing
+	var none *Lease
+	if none.Leased() || none.Release() != nil {
+		t.Errorf("expected a nil lease to hold nothing")
+	}
+}
+
+func TestAcquireProject(t *testing.T) {
+	dir, err := ioutil.TempDir("", "projects")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	store := metadata.NewStore(filepath.Join(dir, "metadata.json"))
+	metadata.SetDefault(store)
+	defer metadata.SetDefault(nil)
+
+	l, err := AcquireProject(&Config{SkipMetadata: true}, "p")
+	if err != nil {
+		t.Fatalf("did not expect an error, but got: %v", err)
+	}
+	if project, err := l.Project(); err != nil || project != "p" {
+		t.Errorf("expected the project p, but got %q, %v", project, err)
+	}
+	if _, ok, err := store.Get(metadata.ProjectsKey); err != nil || ok {
+		t.Errorf("did not expect the projects to be recorded in the metadata, got %v, %v", ok, err)
+	}
+
+	os.Setenv(EnvVar, "p1,p2")
+	defer os.Unsetenv(EnvVar)
+	if _, err := AcquireProject(&Config{Source: "env"}, ""); err == nil {
+		t.Errorf("expected an error acquiring a single project of several")
+	}
+}
+
+func TestKeptLease(t *testing.T) {
+	dir, err := ioutil.TempDir("", "projects")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	leaseFile := filepath.Join(dir, "lease.json")
+
+	l := &Lease{
+		source:         Boskos,
+		projects:       []string{"p1", "p2"},
+		boskos:         &client.Client{},
+		heartbeatClose: make(chan struct{}),
+		leaseFile:      leaseFile,
+	}
+	if !l.Kept() {
+		t.Fatalf("expected a lease with a lease file to be kept until the final invocation")
+	}
+	if err := l.Release(); err != nil {
+		t.Fatalf("did not expect an error releasing a kept lease, but got: %v", err)
+	}
+	select {
+	case <-l.heartbeatClose:
+	default:
+		t.Errorf("expected the heartbeat to be stopped")
+	}
+	contents, err := readLeaseFile(leaseFile)
+	if err != nil {
+		t.Fatalf("did not expect an error reading the lease file, but got: %v", err)
+	}
+	if contents == nil || !reflect.DeepEqual(contents.Projects, []string{"p1", "p2"}) {
+		t.Errorf("expected the lease file to hold the projects, got %+v", contents)
+	}
+
+	final := &Lease{source: Boskos, leaseFile: leaseFile, final: true}
+	if final.Kept() {
+		t.Errorf("did not expect the lease of the final invocation to be kept")
+	}
+}
+
+func TestReadLeaseFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "projects")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	if contents, err := readLeaseFile(filepath.Join(dir, "missing.json")); contents != nil || err != nil {
+		t.Errorf("expected no lease for a missing file, got %+v, %v", contents, err)
+	}
+	for name, data := range map[string]string{
+		"invalid.json": "{",
+		"empty.json":   `{"projects": []}`,
+	} {
+		path := filepath.Join(dir, name)
+		if err := ioutil.WriteFile(path, []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := readLeaseFile(path); err == nil {
+			t.Errorf("expected an error reading %s", name)
+		}
+	}
+}
+
+func TestResumeMissingLeaseFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "projects")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// rather than falling back to leasing new projects
+	if _, err := ResumeLeaseFile(&Config{LeaseFile: filepath.Join(dir, "missing.json")}); err == nil {
+		t.Errorf("expected an error resuming a missing lease file")
+	}
+}
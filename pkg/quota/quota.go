@@ -0,0 +1,162 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package quota implements a preflight check of the GCP compute quotas
+// for deployers that provision resources in a GCP project.
+package quota
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"k8s.io/klog"
+
+	"sigs.k8s.io/kubetest2/pkg/exec"
+)
+
+// Well-known regional compute quota metrics
+const (
+	CPUs           = "CPUS"
+	InUseAddresses = "IN_USE_ADDRESSES"
+	Instances      = "INSTANCES"
+)
+
+// Requirements maps a quota metric to the amount required by the run
+type Requirements map[string]float64
+
+// Add adds amount to the requirement for metric
+func (r Requirements) Add(metric string, amount float64) {
+	r[metric] += amount
+}
+
+// AddNodes adds the requirements of count nodes of the given machine type
+func (r Requirements) AddNodes(machineType string, count int, externalIP bool) error {
+	cpus, err := CPUsForMachineType(machineType)
+	if err != nil {
+		return err
+	}
+	r.Add(CPUMetricForMachineType(machineType), float64(cpus*count))
+	r.Add(Instances, float64(count))
+	if externalIP {
+		r.Add(InUseAddresses, float64(count))
+	}
+	return nil
+}
+
+type regionQuota struct {
+	Metric string  `json:"metric"`
+	Limit  float64 `json:"limit"`
+	Usage  float64 `json:"usage"`
+}
+
+type region struct {
+	Quotas []regionQuota `json:"quotas"`
+}
+
+// Check queries the regional quotas of the project, returning an error
+// listing every metric for which the requirements exceed the available quota
+func Check(project, regionName string, required Requirements) error {
+	cmd := exec.Command("gcloud", "compute", "regions", "describe", regionName,
+		"--project="+project,
+		"--format=json")
+	out, err := exec.Output(cmd)
+	if err != nil {
+		return fmt.Errorf("failed to describe region %s in project %s: %v", regionName, project, err)
+	}
+	r := &region{}
+	if err := json.Unmarshal(out, r); err != nil {
+		return fmt.Errorf("failed to parse quotas for region %s: %v", regionName, err)
+	}
+	return compare(project, regionName, r.Quotas, required)
+}
+
+func compare(project, regionName string, quotas []regionQuota, required Requirements) error {
+	available := map[string]regionQuota{}
+	for _, q := range quotas {
+		available[q.Metric] = q
+	}
+
+	metrics := make([]string, 0, len(required))
+	for metric := range required {
+		metrics = append(metrics, metric)
+	}
+	sort.Strings(metrics)
+
+	var exceeded []string
+	for _, metric := range metrics {
+		amount := required[metric]
+		q, ok := available[metric]
+		if !ok {
+			klog.V(2).Infof("no %s quota reported for region %s, skipping", metric, regionName)
+			continue
+		}
+		if free := q.Limit - q.Usage; amount > free {
+			exceeded = append(exceeded, fmt.Sprintf("%s requires %v but only %v of %v is available", metric, amount, free, q.Limit))
+		}
+	}
+	if len(exceeded) > 0 {
+		return fmt.Errorf("insufficient quota in project %s region %s: %s", project, regionName, strings.Join(exceeded, "; "))
+	}
+	return nil
+}
+
+// sharedCoreCPUs are the vCPUs of the shared core machine types
+// https://cloud.google.com/compute/docs/general-purpose-machines#sharedcore
+var sharedCoreCPUs = map[string]int{
+	"f1-micro":  1,
+	"g1-small":  1,
+	"e2-micro":  2,
+	"e2-small":  2,
+	"e2-medium": 2,
+}
+
+// CPUsForMachineType returns the number of vCPUs of a GCE machine type, e.g.
+// 4 for n1-standard-4 or e2-custom-4-8192
+func CPUsForMachineType(machineType string) (int, error) {
+	if cpus, ok := sharedCoreCPUs[machineType]; ok {
+		return cpus, nil
+	}
+	parts := strings.Split(machineType, "-")
+	// custom machine types are of the form [family-]custom-CPUS-MEMORY[-ext]
+	for i, part := range parts {
+		if part == "custom" && i+1 < len(parts) {
+			return strconv.Atoi(parts[i+1])
+		}
+	}
+	if len(parts) < 3 {
+		return 0, fmt.Errorf("unable to determine the number of CPUs for machine type %q", machineType)
+	}
+	cpus, err := strconv.Atoi(parts[len(parts)-1])
+	if err != nil {
+		return 0, fmt.Errorf("unable to determine the number of CPUs for machine type %q: %v", machineType, err)
+	}
+	return cpus, nil
+}
+
+// CPUMetricForMachineType returns the CPU quota metric that applies to the machine type,
+// as the newer machine families have their own quotas e.g. N2_CPUS
+func CPUMetricForMachineType(machineType string) string {
+	family := strings.Split(machineType, "-")[0]
+	switch family {
+	case "n1", "e2", "f1", "g1", "custom":
+		return CPUs
+	default:
+		return strings.ToUpper(family) + "_" + CPUs
+	}
+}
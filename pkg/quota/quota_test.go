@@ -0,0 +1,100 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package quota
+
+import (
+	"testing"
+)
+
+func TestCPUsForMachineType(t *testing.T) {
+	testCases := []struct {
+		machineType string
+		cpus        int
+		metric      string
+		expectError bool
+	}{
+		{machineType: "e2-medium", cpus: 2, metric: CPUs},
+		{machineType: "n1-standard-4", cpus: 4, metric: CPUs},
+		{machineType: "n2-highmem-16", cpus: 16, metric: "N2_CPUS"},
+		{machineType: "t2a-standard-4", cpus: 4, metric: "T2A_CPUS"},
+		{machineType: "e2-custom-6-8192", cpus: 6, metric: CPUs},
+		{machineType: "custom-8-16384", cpus: 8, metric: CPUs},
+		{machineType: "n2-custom-2-4096-ext", cpus: 2, metric: "N2_CPUS"},
+		{machineType: "bogus", expectError: true},
+		{machineType: "n1-standard-four", expectError: true},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.machineType, func(t *testing.T) {
+			t.Parallel()
+			cpus, err := CPUsForMachineType(tc.machineType)
+			if tc.expectError {
+				if err == nil {
+					t.Errorf("expected an error for %q but got none", tc.machineType)
+				}
+				return
+			}
+			if err != nil {
+				t.Errorf("did not expect an error, but got: %v", err)
+			}
+			if cpus != tc.cpus {
+				t.Errorf("expected %d CPUs, but got %d", tc.cpus, cpus)
+			}
+			if metric := CPUMetricForMachineType(tc.machineType); metric != tc.metric {
+				t.Errorf("expected metric %s, but got %s", tc.metric, metric)
+			}
+		})
+	}
+}
+
+func TestCompare(t *testing.T) {
+	quotas := []regionQuota{
+		{Metric: CPUs, Limit: 24, Usage: 8},
+		{Metric: InUseAddresses, Limit: 8, Usage: 0},
+	}
+	testCases := []struct {
+		name        string
+		required    Requirements
+		expectError bool
+	}{
+		{
+			name:     "within quota",
+			required: Requirements{CPUs: 16, InUseAddresses: 8},
+		},
+		{
+			name:        "exceeds cpu quota",
+			required:    Requirements{CPUs: 17},
+			expectError: true,
+		},
+		{
+			name:     "unreported metric is ignored",
+			required: Requirements{"N2_CPUS": 100},
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			err := compare("project", "us-central1", quotas, tc.required)
+			if (err != nil) != tc.expectError {
+				t.Errorf("expected error: %v, but got: %v", tc.expectError, err)
+			}
+		})
+	}
+}
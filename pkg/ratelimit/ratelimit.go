@@ -0,0 +1,149 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package ratelimit implements a client-side rate limit shared by the GCP API
+// calls of the run, e.g. the gcloud invocations, along with the jittered
+// exponential backoff retrying the calls that are throttled anyway.
+package ratelimit
+
+import (
+	"math/rand"
+	"regexp"
+	"sync"
+	"time"
+)
+
+// Limiter is a token bucket allowing qps calls per second on average,
+// with bursts of up to burst calls. It is safe for concurrent use.
+// A nil limiter or one with a qps of 0 does not limit the calls.
+type Limiter struct {
+	mu     sync.Mutex
+	qps    float64
+	burst  float64
+	tokens float64
+	last   time.Time
+
+	now   func() time.Time
+	sleep func(time.Duration)
+}
+
+// NewLimiter returns a limiter allowing qps calls per second with bursts of burst calls
+func NewLimiter(qps float64, burst int) *Limiter {
+	if burst < 1 {
+		burst = 1
+	}
+	return &Limiter{
+		qps:    qps,
+		burst:  float64(burst),
+		tokens: float64(burst),
+		now:    time.Now,
+		sleep:  time.Sleep,
+	}
+}
+
+// Wait blocks until the next call is allowed
+func (l *Limiter) Wait() {
+	if delay := l.reserve(); delay > 0 {
+		l.sleep(delay)
+	}
+}
+
+// reserve takes a token from the bucket, returning how long to wait for it
+func (l *Limiter) reserve() time.Duration {
+	if l == nil || l.qps <= 0 {
+		return 0
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := l.now()
+	if !l.last.IsZero() {
+		l.tokens += now.Sub(l.last).Seconds() * l.qps
+		if l.tokens > l.burst {
+			l.tokens = l.burst
+		}
+	}
+	l.last = now
+	l.tokens--
+	if l.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-l.tokens / l.qps * float64(time.Second))
+}
+
+// Backoff configures the retries of the throttled calls
+type Backoff struct {
+	// Initial is the delay before the first retry, doubled for each retry
+	Initial time.Duration
+	// Max caps the delay between retries
+	Max time.Duration
+	// Attempts is the maximum number of attempts, including the first one
+	Attempts int
+}
+
+// DefaultBackoff retries for up to about 3 minutes
+var DefaultBackoff = Backoff{
+	Initial:  2 * time.Second,
+	Max:      time.Minute,
+	Attempts: 6,
+}
+
+// Delay returns the delay before the retry following the given attempt,
+// starting at 1, half of which is randomized so that concurrent callers
+// throttled at the same time do not retry in lockstep
+func (b Backoff) Delay(attempt int) time.Duration {
+	return b.delay(attempt, rand.Float64())
+}
+
+func (b Backoff) delay(attempt int, jitter float64) time.Duration {
+	d := b.Initial
+	for i := 1; i < attempt && d < b.Max; i++ {
+		d *= 2
+	}
+	if d > b.Max {
+		d = b.Max
+	}
+	return d/2 + time.Duration(jitter*float64(d/2))
+}
+
+// throttled matches the errors returned by the GCP APIs and gcloud when the
+// calls are rate limited, as opposed to e.g. exceeding a resource quota, which
+// retrying would not fix
+var throttled = regexp.MustCompile(`(?i)\b429\b|rate ?limit ?exceeded|RESOURCE_EXHAUSTED|too many requests|quota exceeded for quota (metric|group)`)
+
+// IsThrottled returns true if the output of a failed call reports that it was rate limited
+func IsThrottled(output string) bool {
+	return throttled.MatchString(output)
+}
+
+var (
+	defaultMu      sync.Mutex
+	defaultLimiter *Limiter
+)
+
+// SetDefault sets the limiter returned by Default
+func SetDefault(l *Limiter) {
+	defaultMu.Lock()
+	defer defaultMu.Unlock()
+	defaultLimiter = l
+}
+
+// Default returns the limiter shared by the calls of the run, which does not
+// limit the calls unless set with SetDefault
+func Default() *Limiter {
+	defaultMu.Lock()
+	defer defaultMu.Unlock()
+	return defaultLimiter
+}
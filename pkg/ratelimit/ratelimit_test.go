@@ -0,0 +1,104 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ratelimit
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLimiter(t *testing.T) {
+	now := time.Unix(0, 0)
+	l := NewLimiter(2, 3)
+	l.now = func() time.Time { return now }
+
+	// the burst is allowed right away
+	for i := 0; i < 3; i++ {
+		if delay := l.reserve(); delay != 0 {
+			t.Errorf("expected call %d of the burst to be allowed, but got a delay of %v", i, delay)
+		}
+	}
+	// then the calls are spaced by 1/qps
+	if delay := l.reserve(); delay != 500*time.Millisecond {
+		t.Errorf("expected a delay of 500ms, but got %v", delay)
+	}
+	if delay := l.reserve(); delay != time.Second {
+		t.Errorf("expected a delay of 1s, but got %v", delay)
+	}
+	// the bucket refills over time, up to the burst
+	now = now.Add(time.Minute)
+	for i := 0; i < 3; i++ {
+		if delay := l.reserve(); delay != 0 {
+			t.Errorf("expected call %d after the refill to be allowed, but got a delay of %v", i, delay)
+		}
+	}
+	if delay := l.reserve(); delay == 0 {
+		t.Errorf("expected the call exceeding the burst to be delayed")
+	}
+}
+
+func TestUnlimited(t *testing.T) {
+	var nilLimiter *Limiter
+	for _, l := range []*Limiter{nilLimiter, NewLimiter(0, 0)} {
+		for i := 0; i < 100; i++ {
+			if delay := l.reserve(); delay != 0 {
+				t.Fatalf("expected no delay, but got %v", delay)
+			}
+		}
+	}
+}
+
+func TestBackoffDelay(t *testing.T) {
+	b := Backoff{Initial: 2 * time.Second, Max: 10 * time.Second, Attempts: 5}
+	testCases := []struct {
+		attempt  int
+		jitter   float64
+		expected time.Duration
+	}{
+		{attempt: 1, jitter: 0, expected: time.Second},
+		{attempt: 1, jitter: 1, expected: 2 * time.Second},
+		{attempt: 2, jitter: 0.5, expected: 3 * time.Second},
+		{attempt: 3, jitter: 1, expected: 8 * time.Second},
+		{attempt: 4, jitter: 1, expected: 10 * time.Second},
+		{attempt: 10, jitter: 0, expected: 5 * time.Second},
+	}
+	for _, tc := range testCases {
+		if actual := b.delay(tc.attempt, tc.jitter); actual != tc.expected {
+			t.Errorf("attempt %d with jitter %v: expected %v, but got %v", tc.attempt, tc.jitter, tc.expected, actual)
+		}
+	}
+}
+
+func TestIsThrottled(t *testing.T) {
+	testCases := []struct {
+		output    string
+		throttled bool
+	}{
+		{output: "ERROR: (gcloud.compute.instances.list) There was a problem refreshing your current auth tokens: 429 Too Many Requests", throttled: true},
+		{output: "ERROR: (gcloud.container.clusters.describe) ResponseError: code=429, message=Quota exceeded for quota metric 'Read requests' and limit 'Read requests per minute'", throttled: true},
+		{output: "ERROR: (gcloud.compute.firewall-rules.create) Could not fetch resource:\n - Rate Limit Exceeded", throttled: true},
+		{output: "status: RESOURCE_EXHAUSTED", throttled: true},
+		{output: "ERROR: (gcloud.container.clusters.create) ResponseError: code=403, message=Insufficient regional quota to satisfy request: resource \"CPUS\": request requires '24.0' and is short '8.0'"},
+		{output: "ERROR: Quota 'CPUS' exceeded. Limit: 24.0 in region us-central1."},
+		{output: "ERROR: (gcloud.container.clusters.describe) ResponseError: code=404, message=Not found: cluster kt2-1429."},
+	}
+	for _, tc := range testCases {
+		if actual := IsThrottled(tc.output); actual != tc.throttled {
+			t.Errorf("expected IsThrottled(%q) to be %v", tc.output, tc.throttled)
+		}
+	}
+}
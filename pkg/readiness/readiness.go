@@ -0,0 +1,337 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package readiness implements the --wait-for readiness gate of the cluster,
+// polling the API server until e.g. the addons the tests depend on are ready
+// so that the tests do not start against a cluster that is still coming up.
+package readiness
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/clientcmd"
+	"k8s.io/klog"
+)
+
+// DefaultTimeout is the time to wait for all the probes to be ready
+const DefaultTimeout = 5 * time.Minute
+
+// pollInterval is the time between two evaluations of a probe
+const pollInterval = 5 * time.Second
+
+// Kind is a kind of object a probe waits for
+type Kind string
+
+// The supported kinds
+const (
+	// Deployment is ready once all its replicas are updated and available
+	Deployment Kind = "deployment"
+	// DaemonSet is ready once its pods are updated and available on all the nodes it is scheduled on
+	DaemonSet Kind = "daemonset"
+	// StatefulSet is ready once all its replicas are updated and ready
+	StatefulSet Kind = "statefulset"
+	// Endpoints is ready once the service has a ready endpoint
+	Endpoints Kind = "endpoints"
+	// CRD is ready once the custom resource definition is established, it is not namespaced
+	CRD Kind = "crd"
+)
+
+// kindAliases maps the kinds and their kubectl short names to the kinds
+var kindAliases = map[string]Kind{
+	"deployment":               Deployment,
+	"deploy":                   Deployment,
+	"daemonset":                DaemonSet,
+	"ds":                       DaemonSet,
+	"statefulset":              StatefulSet,
+	"sts":                      StatefulSet,
+	"endpoints":                Endpoints,
+	"ep":                       Endpoints,
+	"crd":                      CRD,
+	"customresourcedefinition": CRD,
+}
+
+// Probe is an object to wait for
+type Probe struct {
+	Kind      Kind
+	Name      string
+	Namespace string
+}
+
+func (p Probe) String() string {
+	if p.Namespace == "" {
+		return fmt.Sprintf("%s/%s", p.Kind, p.Name)
+	}
+	return fmt.Sprintf("%s/%s in %s", p.Kind, p.Name, p.Namespace)
+}
+
+// ParseProbe parses a --wait-for value, KIND/NAME followed by the comma
+// separated options of the probe, of which only the namespace as -n NAMESPACE
+// or --namespace=NAMESPACE is supported e.g. deployment/coredns,-n kube-system.
+// The namespace defaults to default for the namespaced kinds.
+func ParseProbe(value string) (Probe, error) {
+	parts := strings.Split(value, ",")
+	object := strings.SplitN(strings.TrimSpace(parts[0]), "/", 2)
+	if len(object) != 2 || object[0] == "" || object[1] == "" {
+		return Probe{}, fmt.Errorf("invalid --wait-for %q, must be KIND/NAME", value)
+	}
+	kind, ok := kindAliases[strings.ToLower(object[0])]
+	if !ok {
+		return Probe{}, fmt.Errorf("invalid --wait-for %q, unknown kind %q, must be one of deployment, daemonset, statefulset, endpoints or crd", value, object[0])
+	}
+	p := Probe{Kind: kind, Name: object[1]}
+	for _, option := range parts[1:] {
+		option = strings.TrimSpace(option)
+		switch {
+		case strings.HasPrefix(option, "-n "), strings.HasPrefix(option, "-n="):
+			p.Namespace = strings.TrimSpace(option[len("-n "):])
+		case strings.HasPrefix(option, "--namespace="):
+			p.Namespace = strings.TrimSpace(strings.TrimPrefix(option, "--namespace="))
+		default:
+			return Probe{}, fmt.Errorf("invalid --wait-for %q, unknown option %q", value, option)
+		}
+		if p.Namespace == "" {
+			return Probe{}, fmt.Errorf("invalid --wait-for %q, empty namespace", value)
+		}
+	}
+	if kind == CRD {
+		if p.Namespace != "" {
+			return Probe{}, fmt.Errorf("invalid --wait-for %q, custom resource definitions are not namespaced", value)
+		}
+	} else if p.Namespace == "" {
+		p.Namespace = "default"
+	}
+	return p, nil
+}
+
+// Gate waits for the probes to be ready in the clusters of the kubeconfig
+type Gate struct {
+	// Kubeconfig of the clusters, the default kubeconfig is used if empty.
+	// The kubeconfig of a multi-cluster run lists a file per cluster, in
+	// which the probes are waited for in turn.
+	Kubeconfig string
+	// Timeout of the wait for all the probes
+	Timeout time.Duration
+	Probes  []Probe
+
+	client  kubernetes.Interface
+	dynamic dynamic.Interface
+}
+
+// Wait polls the probes in order until they are all ready in every cluster,
+// failing with the reason the first probe that is not ready is not once the
+// timeout elapses
+func (g *Gate) Wait() error {
+	if len(g.Probes) == 0 {
+		return nil
+	}
+	if g.Timeout == 0 {
+		g.Timeout = DefaultTimeout
+	}
+	deadline := time.Now().Add(g.Timeout)
+	kubeconfigs := filepath.SplitList(g.Kubeconfig)
+	if len(kubeconfigs) == 0 {
+		kubeconfigs = []string{""}
+	}
+	for _, kubeconfig := range kubeconfigs {
+		if err := g.waitInCluster(kubeconfig, deadline); err != nil {
+			if len(kubeconfigs) > 1 {
+				return fmt.Errorf("in the cluster of %s: %v", kubeconfig, err)
+			}
+			return err
+		}
+	}
+	klog.Infof("All the %d --wait-for probes are ready", len(g.Probes))
+	return nil
+}
+
+// waitInCluster polls the probes in the cluster of the kubeconfig file, the
+// default kubeconfig if empty
+func (g *Gate) waitInCluster(kubeconfig string, deadline time.Time) error {
+	rules := clientcmd.NewDefaultClientConfigLoadingRules()
+	if kubeconfig != "" {
+		rules = &clientcmd.ClientConfigLoadingRules{ExplicitPath: kubeconfig}
+	}
+	config, err := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(rules, &clientcmd.ConfigOverrides{}).ClientConfig()
+	if err != nil {
+		return fmt.Errorf("failed to load the kubeconfig: %v", err)
+	}
+	if g.client, err = kubernetes.NewForConfig(config); err != nil {
+		return fmt.Errorf("failed to make the kubernetes client: %v", err)
+	}
+	if g.dynamic, err = dynamic.NewForConfig(config); err != nil {
+		return fmt.Errorf("failed to make the dynamic client: %v", err)
+	}
+
+	for _, probe := range g.Probes {
+		klog.Infof("Waiting for %s to be ready", probe)
+		var reason string
+		err := wait.PollImmediate(pollInterval, time.Until(deadline), func() (bool, error) {
+			ready, why, err := g.ready(probe)
+			if err != nil {
+				// the API server may still be coming up, keep polling
+				klog.V(1).Infof("Failed to get %s: %v", probe, err)
+				reason = err.Error()
+				return false, nil
+			}
+			if !ready {
+				klog.V(1).Infof("%s is not ready: %s", probe, why)
+			}
+			reason = why
+			return ready, nil
+		})
+		if err == wait.ErrWaitTimeout {
+			return fmt.Errorf("%s not ready after %s: %s", probe, g.Timeout, reason)
+		} else if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// crdResource is the resource of the custom resource definitions, read with
+// the dynamic client to not depend on the apiextensions clients
+var crdResource = schema.GroupVersionResource{Group: "apiextensions.k8s.io", Version: "v1", Resource: "customresourcedefinitions"}
+
+// ready returns whether the object of the probe is ready, and why not
+func (g *Gate) ready(p Probe) (bool, string, error) {
+	ctx := context.Background()
+	var (
+		ready  bool
+		reason string
+		err    error
+	)
+	switch p.Kind {
+	case Deployment:
+		var deployment *appsv1.Deployment
+		if deployment, err = g.client.AppsV1().Deployments(p.Namespace).Get(ctx, p.Name, metav1.GetOptions{}); err == nil {
+			ready, reason = deploymentReady(deployment)
+		}
+	case DaemonSet:
+		var daemonSet *appsv1.DaemonSet
+		if daemonSet, err = g.client.AppsV1().DaemonSets(p.Namespace).Get(ctx, p.Name, metav1.GetOptions{}); err == nil {
+			ready, reason = daemonSetReady(daemonSet)
+		}
+	case StatefulSet:
+		var statefulSet *appsv1.StatefulSet
+		if statefulSet, err = g.client.AppsV1().StatefulSets(p.Namespace).Get(ctx, p.Name, metav1.GetOptions{}); err == nil {
+			ready, reason = statefulSetReady(statefulSet)
+		}
+	case Endpoints:
+		var endpoints *corev1.Endpoints
+		if endpoints, err = g.client.CoreV1().Endpoints(p.Namespace).Get(ctx, p.Name, metav1.GetOptions{}); err == nil {
+			ready, reason = endpointsReady(endpoints)
+		}
+	case CRD:
+		var crd *unstructured.Unstructured
+		if crd, err = g.dynamic.Resource(crdResource).Get(ctx, p.Name, metav1.GetOptions{}); err == nil {
+			ready, reason = crdEstablished(crd.Object)
+		}
+	default:
+		return false, "", fmt.Errorf("unknown kind %q", p.Kind)
+	}
+	if apierrors.IsNotFound(err) {
+		// e.g. not created yet by the addon manager
+		return false, "not found", nil
+	}
+	return ready, reason, err
+}
+
+func deploymentReady(d *appsv1.Deployment) (bool, string) {
+	replicas := int32(1)
+	if d.Spec.Replicas != nil {
+		replicas = *d.Spec.Replicas
+	}
+	switch {
+	case d.Status.ObservedGeneration < d.Generation:
+		return false, "the latest spec is not observed yet"
+	case d.Status.UpdatedReplicas < replicas:
+		return false, fmt.Sprintf("%d of %d replicas are updated", d.Status.UpdatedReplicas, replicas)
+	case d.Status.Replicas > d.Status.UpdatedReplicas:
+		return false, fmt.Sprintf("%d old replicas are pending termination", d.Status.Replicas-d.Status.UpdatedReplicas)
+	case d.Status.AvailableReplicas < replicas:
+		return false, fmt.Sprintf("%d of %d replicas are available", d.Status.AvailableReplicas, replicas)
+	}
+	return true, ""
+}
+
+func daemonSetReady(ds *appsv1.DaemonSet) (bool, string) {
+	desired := ds.Status.DesiredNumberScheduled
+	switch {
+	case ds.Status.ObservedGeneration < ds.Generation:
+		return false, "the latest spec is not observed yet"
+	case ds.Status.UpdatedNumberScheduled < desired:
+		return false, fmt.Sprintf("%d of %d pods are updated", ds.Status.UpdatedNumberScheduled, desired)
+	case ds.Status.NumberAvailable < desired:
+		return false, fmt.Sprintf("%d of %d pods are available", ds.Status.NumberAvailable, desired)
+	}
+	return true, ""
+}
+
+func statefulSetReady(sts *appsv1.StatefulSet) (bool, string) {
+	replicas := int32(1)
+	if sts.Spec.Replicas != nil {
+		replicas = *sts.Spec.Replicas
+	}
+	switch {
+	case sts.Status.ObservedGeneration < sts.Generation:
+		return false, "the latest spec is not observed yet"
+	case sts.Status.UpdatedReplicas < replicas:
+		return false, fmt.Sprintf("%d of %d replicas are updated", sts.Status.UpdatedReplicas, replicas)
+	case sts.Status.ReadyReplicas < replicas:
+		return false, fmt.Sprintf("%d of %d replicas are ready", sts.Status.ReadyReplicas, replicas)
+	}
+	return true, ""
+}
+
+func endpointsReady(e *corev1.Endpoints) (bool, string) {
+	for _, subset := range e.Subsets {
+		if len(subset.Addresses) > 0 {
+			return true, ""
+		}
+	}
+	return false, "no ready endpoints"
+}
+
+// crdEstablished returns whether the Established condition of the custom
+// resource definition object is true
+func crdEstablished(obj map[string]interface{}) (bool, string) {
+	status, _ := obj["status"].(map[string]interface{})
+	conditions, _ := status["conditions"].([]interface{})
+	for _, c := range conditions {
+		condition, ok := c.(map[string]interface{})
+		if !ok || condition["type"] != "Established" {
+			continue
+		}
+		if condition["status"] == "True" {
+			return true, ""
+		}
+		return false, fmt.Sprintf("not established: %v", condition["message"])
+	}
+	return false, "not established yet"
+}
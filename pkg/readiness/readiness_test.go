@@ -0,0 +1,236 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package readiness
+
+import (
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestParseProbe(t *testing.T) {
+	testCases := []struct {
+		value       string
+		expected    Probe
+		expectError bool
+	}{
+		{
+			value:    "deployment/coredns,-n kube-system",
+			expected: Probe{Kind: Deployment, Name: "coredns", Namespace: "kube-system"},
+		},
+		{
+			value:    "ds/kube-proxy,--namespace=kube-system",
+			expected: Probe{Kind: DaemonSet, Name: "kube-proxy", Namespace: "kube-system"},
+		},
+		{
+			value:    "endpoints/kube-dns, -n=kube-system",
+			expected: Probe{Kind: Endpoints, Name: "kube-dns", Namespace: "kube-system"},
+		},
+		{
+			value:    "StatefulSet/web",
+			expected: Probe{Kind: StatefulSet, Name: "web", Namespace: "default"},
+		},
+		{
+			value:    "crd/foo.example.com",
+			expected: Probe{Kind: CRD, Name: "foo.example.com"},
+		},
+		{
+			value:       "crd/foo.example.com,-n kube-system",
+			expectError: true,
+		},
+		{
+			value:       "coredns",
+			expectError: true,
+		},
+		{
+			value:       "deployment/",
+			expectError: true,
+		},
+		{
+			value:       "pod/coredns",
+			expectError: true,
+		},
+		{
+			value:       "deployment/coredns,--timeout=1m",
+			expectError: true,
+		},
+		{
+			value:       "deployment/coredns,-n ",
+			expectError: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.value, func(t *testing.T) {
+			probe, err := ParseProbe(tc.value)
+			if tc.expectError {
+				if err == nil {
+					t.Errorf("expected an error, got %+v", probe)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if probe != tc.expected {
+				t.Errorf("expected %+v, got %+v", tc.expected, probe)
+			}
+		})
+	}
+}
+
+func int32Ptr(i int32) *int32 {
+	return &i
+}
+
+func TestDeploymentReady(t *testing.T) {
+	testCases := []struct {
+		name     string
+		spec     appsv1.DeploymentSpec
+		status   appsv1.DeploymentStatus
+		expected bool
+	}{
+		{
+			name:     "available",
+			spec:     appsv1.DeploymentSpec{Replicas: int32Ptr(2)},
+			status:   appsv1.DeploymentStatus{ObservedGeneration: 1, Replicas: 2, UpdatedReplicas: 2, AvailableReplicas: 2},
+			expected: true,
+		},
+		{
+			name:   "spec not observed",
+			spec:   appsv1.DeploymentSpec{Replicas: int32Ptr(2)},
+			status: appsv1.DeploymentStatus{Replicas: 2, UpdatedReplicas: 2, AvailableReplicas: 2},
+		},
+		{
+			name:   "rolling out",
+			spec:   appsv1.DeploymentSpec{Replicas: int32Ptr(2)},
+			status: appsv1.DeploymentStatus{ObservedGeneration: 1, Replicas: 3, UpdatedReplicas: 2, AvailableReplicas: 2},
+		},
+		{
+			name:   "not available",
+			spec:   appsv1.DeploymentSpec{Replicas: int32Ptr(2)},
+			status: appsv1.DeploymentStatus{ObservedGeneration: 1, Replicas: 2, UpdatedReplicas: 2, AvailableReplicas: 1},
+		},
+		{
+			name:     "default replicas",
+			status:   appsv1.DeploymentStatus{ObservedGeneration: 1, Replicas: 1, UpdatedReplicas: 1, AvailableReplicas: 1},
+			expected: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			d := &appsv1.Deployment{Spec: tc.spec, Status: tc.status}
+			d.Generation = 1
+			if ready, reason := deploymentReady(d); ready != tc.expected {
+				t.Errorf("expected ready to be %v, got %v (%s)", tc.expected, ready, reason)
+			}
+		})
+	}
+}
+
+func TestDaemonSetReady(t *testing.T) {
+	testCases := []struct {
+		name     string
+		status   appsv1.DaemonSetStatus
+		expected bool
+	}{
+		{
+			name:     "available",
+			status:   appsv1.DaemonSetStatus{DesiredNumberScheduled: 3, UpdatedNumberScheduled: 3, NumberAvailable: 3},
+			expected: true,
+		},
+		{
+			name:   "not updated",
+			status: appsv1.DaemonSetStatus{DesiredNumberScheduled: 3, UpdatedNumberScheduled: 2, NumberAvailable: 3},
+		},
+		{
+			name:   "not available",
+			status: appsv1.DaemonSetStatus{DesiredNumberScheduled: 3, UpdatedNumberScheduled: 3, NumberAvailable: 2},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if ready, reason := daemonSetReady(&appsv1.DaemonSet{Status: tc.status}); ready != tc.expected {
+				t.Errorf("expected ready to be %v, got %v (%s)", tc.expected, ready, reason)
+			}
+		})
+	}
+}
+
+func TestStatefulSetReady(t *testing.T) {
+	ready := &appsv1.StatefulSet{
+		Spec:   appsv1.StatefulSetSpec{Replicas: int32Ptr(3)},
+		Status: appsv1.StatefulSetStatus{UpdatedReplicas: 3, ReadyReplicas: 3},
+	}
+	if ok, reason := statefulSetReady(ready); !ok {
+		t.Errorf("expected the statefulset to be ready, got %s", reason)
+	}
+	ready.Status.ReadyReplicas = 2
+	if ok, _ := statefulSetReady(ready); ok {
+		t.Errorf("expected the statefulset with 2 of 3 ready replicas not to be ready")
+	}
+}
+
+func TestEndpointsReady(t *testing.T) {
+	notReady := &corev1.Endpoints{Subsets: []corev1.EndpointSubset{{}}}
+	if ok, _ := endpointsReady(notReady); ok {
+		t.Errorf("expected endpoints without addresses not to be ready")
+	}
+	ready := &corev1.Endpoints{Subsets: []corev1.EndpointSubset{{}, {Addresses: []corev1.EndpointAddress{{IP: "10.0.0.1"}}}}}
+	if ok, reason := endpointsReady(ready); !ok {
+		t.Errorf("expected endpoints with an address to be ready, got %s", reason)
+	}
+}
+
+func TestCRDEstablished(t *testing.T) {
+	crd := func(conditions ...interface{}) map[string]interface{} {
+		return map[string]interface{}{"status": map[string]interface{}{"conditions": conditions}}
+	}
+	testCases := []struct {
+		name     string
+		obj      map[string]interface{}
+		expected bool
+	}{
+		{
+			name: "no status",
+			obj:  map[string]interface{}{},
+		},
+		{
+			name: "names accepted",
+			obj:  crd(map[string]interface{}{"type": "NamesAccepted", "status": "True"}),
+		},
+		{
+			name: "not established",
+			obj:  crd(map[string]interface{}{"type": "Established", "status": "False", "message": "pending"}),
+		},
+		{
+			name: "established",
+			obj: crd(
+				map[string]interface{}{"type": "NamesAccepted", "status": "True"},
+				map[string]interface{}{"type": "Established", "status": "True"},
+			),
+			expected: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if ready, reason := crdEstablished(tc.obj); ready != tc.expected {
+				t.Errorf("expected established to be %v, got %v (%s)", tc.expected, ready, reason)
+			}
+		})
+	}
+}
@@ -0,0 +1,144 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package secrets
+
+import (
+	"bytes"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Redacted replaces the redacted secrets
+const Redacted = "<redacted>"
+
+// minSecretLength is the length below which registered values are not
+// redacted, as replacing them everywhere would mangle unrelated output
+const minSecretLength = 4
+
+var (
+	registeredMu sync.RWMutex
+	registered   []string
+)
+
+// Register adds values to the secrets redacted by Redact, e.g. the secrets
+// passed to a command by other means than a flag
+func Register(values ...string) {
+	registeredMu.Lock()
+	defer registeredMu.Unlock()
+	for _, v := range values {
+		if len(v) < minSecretLength {
+			continue
+		}
+		registered = append(registered, v)
+	}
+	// the longest first, in case a secret contains another one
+	sort.Slice(registered, func(i, j int) bool { return len(registered[i]) > len(registered[j]) })
+}
+
+// redactions match secrets in command lines and output, the first group is kept
+var redactions = []*regexp.Regexp{
+	// flags e.g. --password=foo
+	regexp.MustCompile(`(?i)((?:^|[\s"'])--?[\w-]*(?:password|passwd|token|secret|api-?key)[\w-]*=)[^\s,]+`),
+	// the known secret flags with a separate value e.g. --auth-token foo, as
+	// the other ones are followed by words like in "--enable-secret-manager true"
+	regexp.MustCompile(`(?i)((?:^|[\s"'])--?(?:password|passwd|token|auth-token|access-token|github-token|api-key|apikey|client-secret)\s+)[^\s,-][^\s,]*`),
+	// environment variables e.g. GITHUB_TOKEN=foo
+	regexp.MustCompile(`(?i)(\b\w*(?:password|passwd|token|secret|api_?key)\w*=)[^\s,]+`),
+	// http authorization headers
+	regexp.MustCompile(`(?i)(authorization:\s*(?:bearer|basic)\s+)\S+`),
+	// GCP OAuth access tokens
+	regexp.MustCompile(`()\bya29\.[\w.-]+`),
+	// private keys
+	regexp.MustCompile(`(?s)(-----BEGIN [A-Z ]*PRIVATE KEY-----).*?-----END [A-Z ]*PRIVATE KEY-----`),
+}
+
+// Redact replaces the registered secrets and the well-known forms of secrets
+// found in s with <redacted>
+func Redact(s string) string {
+	registeredMu.RLock()
+	for _, secret := range registered {
+		s = strings.ReplaceAll(s, secret, Redacted)
+	}
+	registeredMu.RUnlock()
+	for _, re := range redactions {
+		s = re.ReplaceAllString(s, "${1}"+Redacted)
+	}
+	return s
+}
+
+// maxBufferedOutput is the size above which a Writer writes out a partial line
+const maxBufferedOutput = 64 * 1024
+
+// privateKeyBegin and privateKeyEnd delimit a private key spanning multiple lines
+var (
+	privateKeyBegin = []byte("-----BEGIN ")
+	privateKeyEnd   = []byte("-----END ")
+)
+
+// Writer redacts the secrets from the output written to the underlying writer.
+// The output is written out by whole lines, and private keys as a whole, so
+// that the secrets split across writes are redacted too. It is safe for
+// concurrent use.
+type Writer struct {
+	mu  sync.Mutex
+	w   io.Writer
+	buf []byte
+}
+
+// NewWriter returns a Writer redacting the output written to w
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{w: w}
+}
+
+func (w *Writer) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.buf = append(w.buf, p...)
+
+	end := bytes.LastIndexByte(w.buf, '\n') + 1
+	// hold back a private key until it ends
+	if begin := bytes.LastIndex(w.buf[:end], privateKeyBegin); begin >= 0 && !bytes.Contains(w.buf[begin:end], privateKeyEnd) {
+		end = begin
+	}
+	if len(w.buf) > maxBufferedOutput {
+		end = len(w.buf)
+	}
+	if err := w.writeOut(end); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// Flush writes out the buffered partial output
+func (w *Writer) Flush() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.writeOut(len(w.buf))
+}
+
+func (w *Writer) writeOut(end int) error {
+	if end == 0 {
+		return nil
+	}
+	out := Redact(string(w.buf[:end]))
+	w.buf = append(w.buf[:0], w.buf[end:]...)
+	_, err := io.WriteString(w.w, out)
+	return err
+}
@@ -0,0 +1,178 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package secrets resolves the flags referencing secrets held outside of the
+// command line, e.g. in a file, an environment variable or GCP Secret Manager,
+// and redacts the secrets from the logs and the artifacts of the run.
+package secrets
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	osexec "os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// The prefixes of the flag values referencing a secret
+const (
+	// EnvPrefix references the environment variable holding the secret, e.g. env:BOSKOS_URL
+	EnvPrefix = "env:"
+	// FilePrefix references the file holding the secret, e.g. file:/etc/boskos/url
+	FilePrefix = "file:"
+	// GCPSecretPrefix references a secret in GCP Secret Manager by resource name, using
+	// the latest version by default, e.g. gcp-secret:projects/my-project/secrets/boskos-url/versions/3
+	GCPSecretPrefix = "gcp-secret:"
+)
+
+// IsReference returns true if value references a secret
+func IsReference(value string) bool {
+	return strings.HasPrefix(value, EnvPrefix) ||
+		strings.HasPrefix(value, FilePrefix) ||
+		strings.HasPrefix(value, GCPSecretPrefix)
+}
+
+// Resolve returns the secret referenced by value, registering it for redaction.
+// Values that do not reference a secret are returned as is.
+func Resolve(value string) (string, error) {
+	if !IsReference(value) {
+		return value, nil
+	}
+	secret, err := resolve(value)
+	if err != nil {
+		return "", err
+	}
+	Register(secret)
+	return secret, nil
+}
+
+func resolve(value string) (string, error) {
+	switch {
+	case strings.HasPrefix(value, EnvPrefix):
+		name := strings.TrimPrefix(value, EnvPrefix)
+		secret, ok := os.LookupEnv(name)
+		if !ok {
+			return "", fmt.Errorf("the environment variable %s holding the secret is not set", name)
+		}
+		return secret, nil
+	case strings.HasPrefix(value, FilePrefix):
+		data, err := ioutil.ReadFile(strings.TrimPrefix(value, FilePrefix))
+		if err != nil {
+			return "", fmt.Errorf("failed to read the secret: %v", err)
+		}
+		return strings.TrimRight(string(data), "\r\n"), nil
+	default:
+		project, secret, version, err := parseSecretName(strings.TrimPrefix(value, GCPSecretPrefix))
+		if err != nil {
+			return "", err
+		}
+		return accessSecretVersion(project, secret, version)
+	}
+}
+
+// parseSecretName parses a Secret Manager secret or secret version resource name,
+// projects/PROJECT/secrets/SECRET[/versions/VERSION]
+func parseSecretName(name string) (project, secret, version string, err error) {
+	parts := strings.Split(name, "/")
+	valid := (len(parts) == 4 || len(parts) == 6 && parts[4] == "versions") &&
+		parts[0] == "projects" && parts[2] == "secrets"
+	for _, part := range parts {
+		valid = valid && part != ""
+	}
+	if !valid {
+		return "", "", "", fmt.Errorf("%q is not of the form projects/PROJECT/secrets/SECRET[/versions/VERSION]", name)
+	}
+	version = "latest"
+	if len(parts) == 6 {
+		version = parts[5]
+	}
+	return parts[1], parts[3], version, nil
+}
+
+// accessSecretVersion returns the payload of the secret version.
+// This deliberately does not go through pkg/exec, which would record the
+// secret in the transcript of the run before it is registered for redaction.
+func accessSecretVersion(project, secret, version string) (string, error) {
+	cmd := osexec.Command("gcloud", "secrets", "versions", "access", version,
+		"--secret="+secret,
+		"--project="+project,
+	)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("failed to access version %s of secret %s in project %s: %v: %s",
+			version, secret, project, err, strings.TrimSpace(stderr.String()))
+	}
+	return strings.TrimRight(string(out), "\r\n"), nil
+}
+
+var (
+	filesMu  sync.Mutex
+	filesDir string
+	files    = map[string]string{}
+)
+
+// ResolveFile returns the path to a file holding the secret referenced by
+// value, for the flags taking e.g. a service account key file. Plain paths and
+// file: references are returned as is, the other secrets are written to a
+// private temporary file removed by Cleanup.
+func ResolveFile(value string) (string, error) {
+	if strings.HasPrefix(value, FilePrefix) {
+		return strings.TrimPrefix(value, FilePrefix), nil
+	}
+	if !IsReference(value) {
+		return value, nil
+	}
+
+	filesMu.Lock()
+	defer filesMu.Unlock()
+	if path, ok := files[value]; ok {
+		return path, nil
+	}
+	secret, err := Resolve(value)
+	if err != nil {
+		return "", err
+	}
+	if filesDir == "" {
+		// not in the run dir, which is uploaded with the artifacts
+		dir, err := ioutil.TempDir("", "kubetest2-secrets")
+		if err != nil {
+			return "", err
+		}
+		filesDir = dir
+	}
+	path := filepath.Join(filesDir, fmt.Sprintf("secret-%d", len(files)))
+	if err := ioutil.WriteFile(path, []byte(secret), 0600); err != nil {
+		return "", fmt.Errorf("failed to write the secret to a file: %v", err)
+	}
+	files[value] = path
+	return path, nil
+}
+
+// Cleanup removes the files written by ResolveFile
+func Cleanup() {
+	filesMu.Lock()
+	defer filesMu.Unlock()
+	if filesDir != "" {
+		_ = os.RemoveAll(filesDir)
+	}
+	filesDir = ""
+	files = map[string]string{}
+}
@@ -0,0 +1,260 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package smoke implements a smoke check of a freshly brought up cluster, to
+// fail fast with diagnostics instead of running the tests against a broken cluster.
+package smoke
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"k8s.io/klog"
+
+	"sigs.k8s.io/kubetest2/pkg/exec"
+)
+
+// Images used by the checks
+const (
+	ServerImage = "k8s.gcr.io/e2e-test-images/agnhost:2.32"
+	ClientImage = "k8s.gcr.io/e2e-test-images/busybox:1.29-1"
+)
+
+// DefaultTimeout is the time each check waits for the cluster to become healthy
+const DefaultTimeout = 5 * time.Minute
+
+// Checker runs the smoke checks against the cluster of the kubeconfig
+type Checker struct {
+	// Kubeconfig of the cluster, the default kubeconfig is used if empty
+	Kubeconfig string
+	// Timeout of each check
+	Timeout time.Duration
+	// ArtifactsDir is where the diagnostics are written on failure
+	ArtifactsDir string
+}
+
+type check struct {
+	name string
+	run  func() error
+}
+
+// Run runs the checks in order until one fails, as the later checks depend
+// on the earlier ones, and collects diagnostics of the cluster on failure
+func (c *Checker) Run() error {
+	if c.Timeout == 0 {
+		c.Timeout = DefaultTimeout
+	}
+	namespace := "kubetest2-smoke-" + uuid.New().String()[:8]
+	checks := []check{
+		{name: "nodes are ready", run: c.nodesReady},
+		{name: "kube-system pods are healthy", run: c.systemPodsHealthy},
+		{name: "a deployment behind a service is reachable by its DNS name", run: func() error {
+			return c.serviceReachable(namespace)
+		}},
+	}
+	defer c.cleanup(namespace)
+
+	for _, check := range checks {
+		klog.Infof("Smoke check: %s", check.name)
+		if err := check.run(); err != nil {
+			c.diagnose(namespace)
+			return fmt.Errorf("cluster smoke check %q failed: %v", check.name, err)
+		}
+	}
+	klog.Info("Smoke check: the cluster is healthy")
+	return nil
+}
+
+func (c *Checker) command(args ...string) exec.Cmd {
+	cmd := exec.Command("kubectl", args...)
+	if c.Kubeconfig != "" {
+		cmd.SetEnv(append(os.Environ(), "KUBECONFIG="+c.Kubeconfig)...)
+	}
+	return cmd
+}
+
+func (c *Checker) kubectl(args ...string) error {
+	cmd := c.command(args...)
+	exec.InheritOutput(cmd)
+	return cmd.Run()
+}
+
+func (c *Checker) timeoutFlag() string {
+	return "--timeout=" + c.Timeout.String()
+}
+
+func (c *Checker) nodesReady() error {
+	return c.kubectl("wait", "--for=condition=Ready", "nodes", "--all", c.timeoutFlag())
+}
+
+// podsJSONPath prints each pod as: name phase ready,ready,...
+const podsJSONPath = `{range .items[*]}{.metadata.name}{" "}{.status.phase}{" "}{range .status.containerStatuses[*]}{.ready}{","}{end}{"\n"}{end}`
+
+// unhealthyPods returns the pods of the podsJSONPath output that are neither
+// running with all their containers ready nor completed
+func unhealthyPods(lines []string) []string {
+	var unhealthy []string
+	for _, line := range lines {
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		name, phase, ready := fields[0], "", ""
+		if len(fields) > 1 {
+			phase = fields[1]
+		}
+		if len(fields) > 2 {
+			ready = fields[2]
+		}
+		switch {
+		case phase == "Succeeded":
+		case phase == "Running" && ready != "" && !strings.Contains(ready, "false"):
+		default:
+			unhealthy = append(unhealthy, name)
+		}
+	}
+	return unhealthy
+}
+
+func (c *Checker) systemPodsHealthy() error {
+	deadline := time.Now().Add(c.Timeout)
+	for {
+		cmd := c.command("get", "pods", "--namespace=kube-system", "--output=jsonpath="+podsJSONPath)
+		cmd.SetStderr(os.Stderr)
+		lines, err := exec.OutputLines(cmd)
+		if err != nil {
+			return err
+		}
+		unhealthy := unhealthyPods(lines)
+		if len(unhealthy) == 0 {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("kube-system pods not healthy after %s: %s", c.Timeout, strings.Join(unhealthy, ", "))
+		}
+		klog.V(1).Infof("Waiting for kube-system pods to be healthy: %s", strings.Join(unhealthy, ", "))
+		time.Sleep(10 * time.Second)
+	}
+}
+
+// serverManifest is a deployment serving its hostname behind a service
+const serverManifest = `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: smoke-server
+spec:
+  replicas: 2
+  selector:
+    matchLabels:
+      app: smoke-server
+  template:
+    metadata:
+      labels:
+        app: smoke-server
+    spec:
+      nodeSelector:
+        kubernetes.io/os: linux
+      containers:
+      - name: server
+        image: %s
+        args: ["netexec", "--http-port=8080"]
+        ports:
+        - containerPort: 8080
+        readinessProbe:
+          httpGet:
+            path: /healthz
+            port: 8080
+---
+apiVersion: v1
+kind: Service
+metadata:
+  name: smoke-server
+spec:
+  selector:
+    app: smoke-server
+  ports:
+  - port: 80
+    targetPort: 8080
+`
+
+func (c *Checker) serviceReachable(namespace string) error {
+	if err := c.kubectl("create", "namespace", namespace); err != nil {
+		return err
+	}
+	apply := c.command("apply", "--namespace="+namespace, "--filename=-")
+	apply.SetStdin(strings.NewReader(fmt.Sprintf(serverManifest, ServerImage)))
+	exec.InheritOutput(apply)
+	if err := apply.Run(); err != nil {
+		return err
+	}
+	if err := c.kubectl("rollout", "status", "deployment/smoke-server", "--namespace="+namespace, c.timeoutFlag()); err != nil {
+		return err
+	}
+	// resolve the service by its fully qualified name and fetch from it, from a pod
+	script := fmt.Sprintf("nslookup smoke-server.%[1]s.svc.cluster.local && wget -q -T 10 -O - http://smoke-server.%[1]s.svc.cluster.local/hostname", namespace)
+	return c.kubectl("run", "smoke-client", "--namespace="+namespace,
+		"--image="+ClientImage,
+		"--restart=Never",
+		"--rm", "--attach",
+		`--overrides={"spec":{"nodeSelector":{"kubernetes.io/os":"linux"}}}`,
+		"--pod-running-timeout="+c.Timeout.String(),
+		"--", "sh", "-c", script)
+}
+
+func (c *Checker) cleanup(namespace string) {
+	cmd := c.command("delete", "namespace", namespace, "--ignore-not-found", "--wait=false")
+	exec.NoOutput(cmd)
+	if err := cmd.Run(); err != nil {
+		klog.Warningf("Failed to delete the smoke check namespace %s: %v", namespace, err)
+	}
+}
+
+// diagnose writes the state of the cluster to smoke-check.log in the artifacts
+func (c *Checker) diagnose(namespace string) {
+	var buf bytes.Buffer
+	for _, args := range [][]string{
+		{"get", "nodes", "--output=wide"},
+		{"describe", "nodes"},
+		{"get", "pods", "--all-namespaces", "--output=wide"},
+		{"describe", "pods", "--namespace=kube-system"},
+		{"get", "events", "--all-namespaces", "--sort-by=.lastTimestamp"},
+		{"describe", "all", "--namespace=" + namespace},
+	} {
+		fmt.Fprintf(&buf, "$ kubectl %s\n", strings.Join(args, " "))
+		cmd := c.command(args...)
+		exec.SetOutput(cmd, &buf, &buf)
+		if err := cmd.Run(); err != nil {
+			fmt.Fprintf(&buf, "error: %v\n", err)
+		}
+		buf.WriteString("\n")
+	}
+	if c.ArtifactsDir == "" {
+		klog.Infof("Smoke check diagnostics:\n%s", buf.String())
+		return
+	}
+	path := filepath.Join(c.ArtifactsDir, "smoke-check.log")
+	if err := ioutil.WriteFile(path, buf.Bytes(), 0644); err != nil {
+		klog.Warningf("Failed to write the smoke check diagnostics: %v", err)
+		return
+	}
+	klog.Infof("Smoke check diagnostics written to %s", path)
+}
@@ -0,0 +1,39 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package smoke
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestUnhealthyPods(t *testing.T) {
+	lines := []string{
+		"kube-dns-1 Running true,true,",
+		"kube-proxy-2 Running true,",
+		"metrics-server-3 Running true,false,",
+		"completed-job-4 Succeeded false,",
+		"pending-5 Pending ",
+		"crashing-6 Failed false,",
+		"starting-7 Running",
+		"",
+	}
+	expected := []string{"metrics-server-3", "pending-5", "crashing-6", "starting-7"}
+	if actual := unhealthyPods(lines); !reflect.DeepEqual(actual, expected) {
+		t.Errorf("expected unhealthy pods %v, but got %v", expected, actual)
+	}
+}
@@ -0,0 +1,150 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package snapshot implements saving and restoring the etcd state of kubeadm
+// style control planes, where etcd and the control plane components are
+// static pods, so that tests can run repeatedly against a pristine cluster.
+package snapshot
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	"k8s.io/klog"
+
+	"sigs.k8s.io/kubetest2/pkg/exec"
+)
+
+// DefaultReadyTimeout is how long to wait for the API server to be ready after a restore
+const DefaultReadyTimeout = 3 * time.Minute
+
+var nameRegexp = regexp.MustCompile(`^[a-z0-9-]+$`)
+
+// Runner runs a shell script as root on the single control plane node of the cluster
+type Runner func(script string) error
+
+// Snapshotter saves and restores the etcd state of the cluster
+type Snapshotter struct {
+	// Run runs the scripts on the control plane node e.g. with docker exec
+	Run Runner
+	// Kubeconfig of the cluster, to wait for the API server after a restore
+	Kubeconfig string
+	// ReadyTimeout is how long to wait for the API server after a restore
+	ReadyTimeout time.Duration
+}
+
+// the scripts read the etcd data dir and certificates from the etcd static pod
+// manifest, the same paths are mounted in the etcd container by kubeadm
+const scriptPrelude = `set -eu
+manifests=/etc/kubernetes/manifests
+flag() { sed -n "s/^ *- --$1=//p" "$manifests/etcd.yaml" | head -n 1; }
+data_dir=$(flag data-dir)
+etcd=$(crictl ps --quiet --name='^etcd$')
+`
+
+const saveScript = scriptPrelude + `crictl exec "$etcd" etcdctl --endpoints=https://127.0.0.1:2379 \
+  --cacert="$(flag trusted-ca-file)" --cert="$(flag cert-file)" --key="$(flag key-file)" \
+  snapshot save "$data_dir/kubetest2-snapshot-%[1]s.db"
+`
+
+// restoreScript restores the snapshot to a new data dir, stops the control plane by moving
+// its static pod manifests away, swaps the data dirs and starts the control plane again
+const restoreScript = scriptPrelude + `rm -rf "$data_dir/kubetest2-restore"
+crictl exec "$etcd" etcdctl snapshot restore "$data_dir/kubetest2-snapshot-%[1]s.db" \
+  --data-dir="$data_dir/kubetest2-restore" --name="$(flag name)" \
+  --initial-cluster="$(flag initial-cluster)" --initial-advertise-peer-urls="$(flag initial-advertise-peer-urls)"
+stopped=/etc/kubernetes/kubetest2-stopped-manifests
+mkdir -p "$stopped"
+for component in etcd kube-apiserver kube-controller-manager kube-scheduler; do
+  mv "$manifests/$component.yaml" "$stopped/"
+done
+stopped_all=false
+for i in $(seq 120); do
+  if ! crictl ps --quiet --name='^(etcd|kube-apiserver|kube-controller-manager|kube-scheduler)$' | grep -q .; then
+    stopped_all=true
+    break
+  fi
+  sleep 1
+done
+# the data dir of a running etcd cannot be swapped, start the control plane again as it was
+if [ "$stopped_all" != true ]; then
+  mv "$stopped"/*.yaml "$manifests/"
+  echo "the control plane did not stop within 120s" >&2
+  exit 1
+fi
+rm -rf "$data_dir/member"
+mv "$data_dir/kubetest2-restore/member" "$data_dir/member"
+rm -rf "$data_dir/kubetest2-restore"
+mv "$stopped"/*.yaml "$manifests/"
+`
+
+func validateName(name string) error {
+	if !nameRegexp.MatchString(name) {
+		return fmt.Errorf("invalid snapshot name %q, must only contain lowercase letters, digits and dashes", name)
+	}
+	return nil
+}
+
+// Save saves the etcd state of the cluster as the named snapshot
+func (s *Snapshotter) Save(name string) error {
+	if err := validateName(name); err != nil {
+		return err
+	}
+	klog.V(0).Infof("Saving the etcd snapshot %s ...", name)
+	if err := s.Run(fmt.Sprintf(saveScript, name)); err != nil {
+		return fmt.Errorf("failed to save the etcd snapshot %s: %v", name, err)
+	}
+	return nil
+}
+
+// Restore restores the etcd state of the cluster from the named snapshot,
+// restarting the control plane, and waits for the API server to be ready
+func (s *Snapshotter) Restore(name string) error {
+	if err := validateName(name); err != nil {
+		return err
+	}
+	klog.V(0).Infof("Restoring the etcd snapshot %s ...", name)
+	if err := s.Run(fmt.Sprintf(restoreScript, name)); err != nil {
+		return fmt.Errorf("failed to restore the etcd snapshot %s: %v", name, err)
+	}
+	return s.waitForAPIServer()
+}
+
+func (s *Snapshotter) waitForAPIServer() error {
+	timeout := s.ReadyTimeout
+	if timeout == 0 {
+		timeout = DefaultReadyTimeout
+	}
+	deadline := time.Now().Add(timeout)
+	for {
+		cmd := exec.Command("kubectl", "get", "--raw=/readyz", "--request-timeout=10s")
+		if s.Kubeconfig != "" {
+			cmd.SetEnv(append(os.Environ(), "KUBECONFIG="+s.Kubeconfig)...)
+		}
+		out, err := exec.CombinedOutputLines(cmd)
+		if err == nil {
+			klog.V(0).Info("The API server is ready after the restore")
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("the API server is not ready %s after the restore: %v: %s", timeout, err, strings.Join(out, "\n"))
+		}
+		time.Sleep(5 * time.Second)
+	}
+}
@@ -0,0 +1,45 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package snapshot
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSave(t *testing.T) {
+	var scripts []string
+	s := &Snapshotter{Run: func(script string) error {
+		scripts = append(scripts, script)
+		return nil
+	}}
+	if err := s.Save("pristine"); err != nil {
+		t.Fatalf("did not expect an error, but got: %v", err)
+	}
+	if len(scripts) != 1 || !strings.Contains(scripts[0], `snapshot save "$data_dir/kubetest2-snapshot-pristine.db"`) {
+		t.Errorf("unexpected snapshot scripts: %v", scripts)
+	}
+
+	for _, name := range []string{"", "../etc", "a b", "Pristine"} {
+		if err := s.Save(name); err == nil {
+			t.Errorf("expected an error for snapshot name %q", name)
+		}
+	}
+	if len(scripts) != 1 {
+		t.Errorf("expected no script to run for invalid names, but got %d", len(scripts)-1)
+	}
+}
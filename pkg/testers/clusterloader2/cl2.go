@@ -98,7 +98,7 @@ func (t *Tester) Test() error {
 
 	// TODO(amwat): get prebuilt binaries
 	cmd := exec.Command("go", append(cmdArgs, args...)...)
-	exec.InheritOutput(cmd)
+	exec.InheritRawOutput(cmd)
 	cmd.SetDir(filepath.Join(t.RepoRoot, "clusterloader2"))
 	klog.V(2).Infof("running clusterloader2 %s", args)
 	return cmd.Run()
@@ -110,6 +110,10 @@ func (t *Tester) Execute() error {
 		return fmt.Errorf("failed to initialize tester: %v", err)
 	}
 
+	if testers.DescribeRequested(os.Args[1:]) {
+		return testers.Describe("clusterloader2", GitTag, "runs the clusterloader2 scalability tests", fs)
+	}
+
 	klog.InitFlags(nil)
 	fs.AddGoFlagSet(flag.CommandLine)
 
@@ -0,0 +1,36 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package testers
+
+import (
+	"os"
+
+	"github.com/spf13/pflag"
+
+	"sigs.k8s.io/kubetest2/pkg/types"
+)
+
+// DescribeRequested returns true if the tester was run with only --describe-json,
+// the contract used by `kubetest2 list-testers` to discover the installed testers
+func DescribeRequested(args []string) bool {
+	return len(args) == 1 && args[0] == "--"+types.DescribeFlag
+}
+
+// Describe prints the types.Description of the tester as JSON to stdout
+func Describe(name, version, description string, fs *pflag.FlagSet) error {
+	return types.NewDescription(name, version, description, fs).Write(os.Stdout)
+}
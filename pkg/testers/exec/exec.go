@@ -45,6 +45,10 @@ func (t *Tester) Execute() error {
 		return fmt.Errorf("failed to initialize tester: %v", err)
 	}
 
+	if testers.DescribeRequested(os.Args[1:]) {
+		return testers.Describe("exec", GitTag, "runs the arguments after -- as the test command", fs)
+	}
+
 	fs.Usage = func() {
 		fmt.Print(usage)
 	}
@@ -29,6 +29,7 @@ import (
 	"sigs.k8s.io/kubetest2/pkg/artifacts"
 	"sigs.k8s.io/kubetest2/pkg/build"
 	"sigs.k8s.io/kubetest2/pkg/exec"
+	"sigs.k8s.io/kubetest2/pkg/mirror"
 	"sigs.k8s.io/kubetest2/pkg/testers"
 )
 
@@ -40,7 +41,9 @@ type Tester struct {
 	Parallel           int    `desc:"Run this many tests in parallel at once."`
 	SkipRegex          string `desc:"Regular expression of jobs to skip."`
 	FocusRegex         string `desc:"Regular expression of jobs to focus on."`
-	TestPackageVersion string `desc:"The ginkgo tester uses a test package made during the kubernetes build. The tester downloads this test package from one of the release tars published to GCS. Defaults to latest. Use \"gsutil ls gs://kubernetes-release/release/\" to find release names. Example: v1.20.0-alpha.0"`
+	Profile            string `desc:"Comma separated list of named test profiles to run e.g. conformance, gke-default or slow, setting the focus and skip regexes when not set explicitly and the feature gates the tests require."`
+	ProfilesFile       string `desc:"Path to a JSON file of profiles by name, each with focus, skip and featureGates fields, overriding or adding to the built-in profiles."`
+	TestPackageVersion string `desc:"The ginkgo tester uses a test package made during the kubernetes build. The tester downloads this test package from one of the release tars published to GCS, verifying its checksum and caching it locally. Defaults to the binaries built by the run if any, else to the release of the cluster under test, or to latest if it cannot be determined. Use \"gsutil ls gs://kubernetes-release/release/\" to find release names. Example: v1.20.0-alpha.0"`
 	TestPackageBucket  string `desc:"The bucket which release tars will be downloaded from to acquire the test package. Defaults to the main kubernetes project bucket."`
 	TestPackageDir     string `desc:"The directory in the bucket which represents the type of release. Default to the release directory."`
 	TestPackageMarker  string `desc:"The version marker in the directory containing the package version to download when unspecified. Defaults to latest.txt."`
@@ -61,6 +64,10 @@ func (t *Tester) Test() error {
 	if err := t.pretestSetup(); err != nil {
 		return err
 	}
+	profileArgs, err := t.applyProfile()
+	if err != nil {
+		return err
+	}
 
 	e2eTestArgs := []string{
 		"--kubeconfig=" + t.kubeconfigPath,
@@ -70,6 +77,7 @@ func (t *Tester) Test() error {
 		"--ginkgo.focus=" + t.FocusRegex,
 		"--report-dir=" + artifacts.BaseDir(),
 	}
+	e2eTestArgs = append(e2eTestArgs, profileArgs...)
 	extraE2EArgs, err := shellquote.Split(t.TestArgs)
 	if err != nil {
 		return fmt.Errorf("error parsing --test-args: %v", err)
@@ -89,8 +97,43 @@ func (t *Tester) Test() error {
 
 	klog.V(0).Infof("Running ginkgo test as %s %+v", t.ginkgoPath, ginkgoArgs)
 	cmd := exec.Command(t.ginkgoPath, ginkgoArgs...)
-	exec.InheritOutput(cmd)
-	return cmd.Run()
+	exec.InheritRawOutput(cmd)
+	// point the tests at the images mirrored by the deployer
+	repoList := filepath.Join(t.runDir, mirror.RepoListFile)
+	if _, err := os.Stat(repoList); err == nil && os.Getenv("KUBE_TEST_REPO_LIST") == "" {
+		klog.V(0).Infof("Using the mirrored test images of %s", repoList)
+		cmd.SetEnv(append(os.Environ(), "KUBE_TEST_REPO_LIST="+repoList)...)
+	}
+	err = cmd.Run()
+	if resultErr := t.writeResult(err); resultErr != nil {
+		klog.Warningf("Failed to report the test result: %v", resultErr)
+	}
+	return err
+}
+
+// writeResult reports the result of the e2e junit files to kubetest2
+func (t *Tester) writeResult(testErr error) error {
+	paths, err := filepath.Glob(filepath.Join(artifacts.BaseDir(), "junit_*.xml"))
+	if err != nil {
+		return err
+	}
+	var junitPaths []string
+	for _, path := range paths {
+		// written by kubetest2 itself
+		if filepath.Base(path) != "junit_runner.xml" {
+			junitPaths = append(junitPaths, path)
+		}
+	}
+	result, err := testers.ResultFromJUnit(junitPaths)
+	if err != nil {
+		return err
+	}
+	for _, path := range junitPaths {
+		result.Artifacts = append(result.Artifacts, filepath.Base(path))
+	}
+	// without any failed test, e.g. the test setup or the cluster failed
+	result.Retryable = testErr != nil && result.Failed == 0
+	return testers.WriteResult(result)
 }
 
 func (t *Tester) pretestSetup() error {
@@ -125,6 +168,11 @@ func (t *Tester) pretestSetup() error {
 		}
 		return nil
 	}
+	// the binaries built by the run match the cluster it deployed from the same build
+	if t.TestPackageVersion == "" && t.validateLocalBinaries() == nil {
+		klog.V(0).Infof("Using the test binaries built by the run in %s", t.runDir)
+		return nil
+	}
 
 	if err := t.AcquireTestPackage(); err != nil {
 		return fmt.Errorf("failed to get ginkgo test package from published releases: %s", err)
@@ -160,6 +208,10 @@ func (t *Tester) Execute() error {
 		return fmt.Errorf("failed to initialize tester: %v", err)
 	}
 
+	if testers.DescribeRequested(os.Args[1:]) {
+		return testers.Describe("ginkgo", GitTag, "runs the kubernetes e2e tests with ginkgo", fs)
+	}
+
 	help := fs.BoolP("help", "h", false, "")
 	if err := fs.Parse(os.Args); err != nil {
 		return fmt.Errorf("failed to parse flags: %v", err)
@@ -21,10 +21,12 @@ import (
 	"compress/gzip"
 	"crypto/sha256"
 	"encoding/hex"
+	"encoding/json"
 	"fmt"
 	"io"
 	"os"
 	"path/filepath"
+	"regexp"
 	"runtime"
 	"strings"
 
@@ -38,7 +40,17 @@ import (
 // The second is "e2e.test", which contains kubernetes e2e test cases.
 // The third is "kubectl".
 func (t *Tester) AcquireTestPackage() error {
-	// first, get the name of the latest release (e.g. v1.20.0-alpha.0)
+	// first, default to the release of the cluster under test, so that the
+	// tests match it, or else get the name of the latest release (e.g. v1.20.0-alpha.0)
+	if t.TestPackageVersion == "" {
+		version, err := t.clusterReleaseVersion()
+		if err != nil {
+			klog.Warningf("Failed to determine the version of the cluster, the test package may not match it: %v", err)
+		} else {
+			t.TestPackageVersion = version
+			klog.V(1).Infof("Test package version was not specified. Defaulting to the version of the cluster: %s", t.TestPackageVersion)
+		}
+	}
 	if t.TestPackageVersion == "" {
 		cmd := exec.Command(
 			"gsutil",
@@ -59,10 +71,16 @@ func (t *Tester) AcquireTestPackage() error {
 
 	releaseTar := fmt.Sprintf("kubernetes-test-%s-%s.tar.gz", runtime.GOOS, runtime.GOARCH)
 
-	downloadDir, err := os.UserCacheDir()
+	cacheDir, err := os.UserCacheDir()
 	if err != nil {
 		return fmt.Errorf("failed to get user cache directory: %v", err)
 	}
+	// the tars of each version are cached separately, so that runs against
+	// clusters of different versions do not keep replacing each other's
+	downloadDir := filepath.Join(cacheDir, "kubetest2", t.TestPackageVersion)
+	if err := os.MkdirAll(downloadDir, os.ModePerm); err != nil {
+		return fmt.Errorf("failed to create the cache directory: %v", err)
+	}
 
 	downloadPath := filepath.Join(downloadDir, releaseTar)
 
@@ -168,10 +186,13 @@ func (t *Tester) ensureKubectl(downloadPath string) error {
 	}
 
 	cmd := exec.Command("gsutil", "cp", kubectlPathInGCS, downloadPath)
-	exec.InheritOutput(cmd)
+	exec.InheritRawOutput(cmd)
 	if err := cmd.Run(); err != nil {
 		return fmt.Errorf("failed to download kubectl for release %s: %s", t.TestPackageVersion, err)
 	}
+	if err := t.compareSHA(downloadPath, kubectlPathInGCS); err != nil {
+		return fmt.Errorf("failed to verify the downloaded kubectl: %v", err)
+	}
 	if err := os.Chmod(downloadPath, 0700); err != nil {
 		return fmt.Errorf("failed to make %s executable: %s", downloadPath, err)
 	}
@@ -205,10 +226,13 @@ func (t *Tester) ensureReleaseTar(downloadPath, releaseTar string) error {
 		releaseTarPathInGCS,
 		downloadPath,
 	)
-	exec.InheritOutput(cmd)
+	exec.InheritRawOutput(cmd)
 	if err := cmd.Run(); err != nil {
 		return fmt.Errorf("failed to download release tar %s for release %s: %s", releaseTar, t.TestPackageVersion, err)
 	}
+	if err := t.compareSHA(downloadPath, releaseTarPathInGCS); err != nil {
+		return fmt.Errorf("failed to verify the downloaded release tar %s: %v", releaseTar, err)
+	}
 	return nil
 }
 
@@ -244,3 +268,36 @@ func sha256sum(path string) (string, error) {
 	}
 	return hex.EncodeToString(h.Sum(nil)), nil
 }
+
+// releaseVersionRe matches the release part of a kubernetes version e.g. v1.29.3
+// of v1.29.3-gke.1093000 or v1.30.0-alpha.1 of v1.30.0-alpha.1.30+0123456789abcd
+var releaseVersionRe = regexp.MustCompile(`^v\d+\.\d+\.\d+(-(alpha|beta|rc)\.\d+)?`)
+
+// releaseVersion returns the kubernetes release of the version of a cluster
+func releaseVersion(gitVersion string) (string, error) {
+	version := releaseVersionRe.FindString(gitVersion)
+	if version == "" {
+		return "", fmt.Errorf("unexpected kubernetes version %q", gitVersion)
+	}
+	return version, nil
+}
+
+// clusterReleaseVersion returns the kubernetes release of the cluster under test
+func (t *Tester) clusterReleaseVersion() (string, error) {
+	out, err := exec.Output(exec.Command("kubectl", "--kubeconfig="+t.kubeconfigPath, "version", "-o", "json"))
+	if err != nil {
+		return "", fmt.Errorf("failed to get the version of the cluster: %v", err)
+	}
+	versions := &struct {
+		ServerVersion *struct {
+			GitVersion string `json:"gitVersion"`
+		} `json:"serverVersion"`
+	}{}
+	if err := json.Unmarshal(out, versions); err != nil {
+		return "", fmt.Errorf("failed to parse the version of the cluster: %v", err)
+	}
+	if versions.ServerVersion == nil {
+		return "", fmt.Errorf("the cluster did not report its version")
+	}
+	return releaseVersion(versions.ServerVersion.GitVersion)
+}
@@ -0,0 +1,51 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ginkgo
+
+import (
+	"testing"
+)
+
+func TestReleaseVersion(t *testing.T) {
+	testCases := []struct {
+		gitVersion  string
+		expected    string
+		expectError bool
+	}{
+		{gitVersion: "v1.29.3", expected: "v1.29.3"},
+		{gitVersion: "v1.29.3-gke.1093000", expected: "v1.29.3"},
+		{gitVersion: "v1.30.0-alpha.1", expected: "v1.30.0-alpha.1"},
+		{gitVersion: "v1.30.0-rc.0.12+0123456789abcd", expected: "v1.30.0-rc.0"},
+		{gitVersion: "v1.28.2+k3s1", expected: "v1.28.2"},
+		{gitVersion: "1.29", expectError: true},
+	}
+	for _, tc := range testCases {
+		actual, err := releaseVersion(tc.gitVersion)
+		if tc.expectError {
+			if err == nil {
+				t.Errorf("expected an error for %q but got none", tc.gitVersion)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("did not expect an error for %q, but got: %v", tc.gitVersion, err)
+		}
+		if actual != tc.expected {
+			t.Errorf("expected %q for %q, but got %q", tc.expected, tc.gitVersion, actual)
+		}
+	}
+}
@@ -0,0 +1,146 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ginkgo
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strings"
+)
+
+// profile is a named set of e2e tests, selected with --profile
+type profile struct {
+	// Focus and Skip are the --ginkgo.focus and --ginkgo.skip regexes
+	Focus string `json:"focus,omitempty"`
+	Skip  string `json:"skip,omitempty"`
+	// FeatureGates are the KEY=VALUE feature gates the tests require,
+	// passed to the e2e tests with --feature-gates
+	FeatureGates []string `json:"featureGates,omitempty"`
+}
+
+// skipAlwaysRegex skips the tests that are not meant to run in shared CI jobs
+const skipAlwaysRegex = `\[Flaky\]|\[Feature:.+\]|\[Alpha\]`
+
+// profiles are the built-in profiles, which --profiles-file can override or extend
+var profiles = map[string]profile{
+	"conformance": {
+		Focus: `\[Conformance\]`,
+		Skip:  `\[Disruptive\]|NoExecuteTaintManager`,
+	},
+	"gke-default": {
+		Skip: strings.Join([]string{
+			`\[Slow\]`, `\[Serial\]`, `\[Disruptive\]`, skipAlwaysRegex,
+			// not applicable to GKE managed control planes and nodes
+			`\[Driver:.gcepd\]`, `NodeProblemDetector`, `Dashboard`, `Nvidia.GPUs`,
+			`kube-dns-autoscaler`, `In-tree.Volumes`, `Firewall.rule`,
+			`should.have.ipv4.and.ipv6.internal.node.ip`,
+		}, "|"),
+	},
+	"slow": {
+		Focus: `\[Slow\]`,
+		Skip:  strings.Join([]string{`\[Serial\]`, `\[Disruptive\]`, skipAlwaysRegex}, "|"),
+	},
+}
+
+// loadProfiles returns the built-in profiles overridden by the ones in the
+// profilesFile, a JSON object of the profiles by name, if any
+func loadProfiles(profilesFile string) (map[string]profile, error) {
+	all := make(map[string]profile, len(profiles))
+	for name, p := range profiles {
+		all[name] = p
+	}
+	if profilesFile == "" {
+		return all, nil
+	}
+	data, err := ioutil.ReadFile(profilesFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read --profiles-file: %v", err)
+	}
+	overrides := map[string]profile{}
+	if err := json.Unmarshal(data, &overrides); err != nil {
+		return nil, fmt.Errorf("failed to parse --profiles-file %s: %v", profilesFile, err)
+	}
+	for name, p := range overrides {
+		all[name] = p
+	}
+	return all, nil
+}
+
+// resolveProfiles combines the comma separated list of profiles, the tests
+// matching any focus (or all the tests if one of them has none) minus the
+// ones matching any skip regex are selected
+func resolveProfiles(names string, available map[string]profile) (profile, error) {
+	var focus, skip, gates []string
+	focusAll := false
+	for _, name := range strings.Split(names, ",") {
+		name = strings.TrimSpace(name)
+		p, ok := available[name]
+		if !ok {
+			known := make([]string, 0, len(available))
+			for name := range available {
+				known = append(known, name)
+			}
+			sort.Strings(known)
+			return profile{}, fmt.Errorf("unknown --profile %q, must be one of %s", name, strings.Join(known, ", "))
+		}
+		if p.Focus == "" {
+			focusAll = true
+		} else {
+			focus = append(focus, p.Focus)
+		}
+		if p.Skip != "" {
+			skip = append(skip, p.Skip)
+		}
+		gates = append(gates, p.FeatureGates...)
+	}
+	resolved := profile{
+		Skip:         strings.Join(skip, "|"),
+		FeatureGates: gates,
+	}
+	if !focusAll {
+		resolved.Focus = strings.Join(focus, "|")
+	}
+	return resolved, nil
+}
+
+// applyProfile sets the focus and skip regexes from --profile, unless set explicitly,
+// returning the test args enabling the feature gates the profile requires
+func (t *Tester) applyProfile() ([]string, error) {
+	if t.Profile == "" {
+		return nil, nil
+	}
+	available, err := loadProfiles(t.ProfilesFile)
+	if err != nil {
+		return nil, err
+	}
+	p, err := resolveProfiles(t.Profile, available)
+	if err != nil {
+		return nil, err
+	}
+	if t.FocusRegex == "" {
+		t.FocusRegex = p.Focus
+	}
+	if t.SkipRegex == "" {
+		t.SkipRegex = p.Skip
+	}
+	if len(p.FeatureGates) == 0 {
+		return nil, nil
+	}
+	return []string{"--feature-gates=" + strings.Join(p.FeatureGates, ",")}, nil
+}
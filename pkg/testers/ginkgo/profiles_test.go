@@ -0,0 +1,116 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ginkgo
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestResolveProfiles(t *testing.T) {
+	available := map[string]profile{
+		"a":     {Focus: `\[A\]`, Skip: `\[Slow\]`},
+		"b":     {Focus: `\[B\]`, FeatureGates: []string{"Foo=true"}},
+		"all":   {Skip: `\[Serial\]`},
+		"gates": {FeatureGates: []string{"Bar=true"}},
+	}
+	testCases := []struct {
+		name        string
+		profiles    string
+		expected    profile
+		expectError bool
+	}{
+		{
+			name:     "single",
+			profiles: "a",
+			expected: profile{Focus: `\[A\]`, Skip: `\[Slow\]`},
+		},
+		{
+			name:     "combined",
+			profiles: "a, b",
+			expected: profile{Focus: `\[A\]|\[B\]`, Skip: `\[Slow\]`, FeatureGates: []string{"Foo=true"}},
+		},
+		{
+			name:     "combined with a profile focusing on all the tests",
+			profiles: "a,all,gates",
+			expected: profile{Skip: `\[Slow\]|\[Serial\]`, FeatureGates: []string{"Bar=true"}},
+		},
+		{
+			name:        "unknown",
+			profiles:    "a,c",
+			expectError: true,
+		},
+	}
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			actual, err := resolveProfiles(tc.profiles, available)
+			if tc.expectError {
+				if err == nil {
+					t.Errorf("expected an error but got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Errorf("did not expect an error, but got: %v", err)
+			}
+			if !reflect.DeepEqual(actual, tc.expected) {
+				t.Errorf("expected %+v, but got %+v", tc.expected, actual)
+			}
+		})
+	}
+}
+
+func TestApplyProfile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "profiles")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	profilesFile := filepath.Join(dir, "profiles.json")
+	if err := ioutil.WriteFile(profilesFile, []byte(`{
+  "conformance": {"focus": "\\[Conformance\\]", "skip": "\\[Serial\\]"},
+  "team": {"focus": "\\[sig-team\\]", "featureGates": ["TeamFeature=true"]}
+}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tester := &Tester{Profile: "conformance", ProfilesFile: profilesFile}
+	args, err := tester.applyProfile()
+	if err != nil {
+		t.Fatalf("did not expect an error, but got: %v", err)
+	}
+	if tester.FocusRegex != `\[Conformance\]` || tester.SkipRegex != `\[Serial\]` || len(args) != 0 {
+		t.Errorf("expected the overridden conformance profile, but got focus %q skip %q args %v", tester.FocusRegex, tester.SkipRegex, args)
+	}
+
+	// the explicit regexes take precedence over the profile
+	tester = &Tester{Profile: "team,slow", ProfilesFile: profilesFile, SkipRegex: "explicit"}
+	args, err = tester.applyProfile()
+	if err != nil {
+		t.Fatalf("did not expect an error, but got: %v", err)
+	}
+	if tester.FocusRegex != `\[sig-team\]|\[Slow\]` || tester.SkipRegex != "explicit" {
+		t.Errorf("expected the combined focus and the explicit skip, but got focus %q skip %q", tester.FocusRegex, tester.SkipRegex)
+	}
+	if expected := []string{"--feature-gates=TeamFeature=true"}; !reflect.DeepEqual(args, expected) {
+		t.Errorf("expected args %v, but got %v", expected, args)
+	}
+}
@@ -0,0 +1,278 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package netperf implements a tester measuring the pod to pod network
+// throughput and latency between the nodes of the clusters, as a cheap
+// gating check of CNI and dataplane changes.
+package netperf
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/octago/sflags/gen/gpflag"
+	"k8s.io/klog"
+
+	"sigs.k8s.io/kubetest2/pkg/exec"
+	"sigs.k8s.io/kubetest2/pkg/testers"
+)
+
+var GitTag string
+
+type Tester struct {
+	Kubeconfig             string  `desc:"Path to the kubeconfig of the cluster, or a list of the kubeconfigs of the clusters of a multi-cluster run. Defaults to the KUBECONFIG exposed by the kubetest2 deployer."`
+	Image                  string  `desc:"Image of the measuring pods, providing iperf3, netperf and netserver."`
+	Nodes                  int     `desc:"Number of schedulable linux nodes per cluster to measure between, 0 for all of them."`
+	DurationSeconds        int     `desc:"Duration of each throughput and latency measurement."`
+	ParallelStreams        int     `desc:"Number of parallel iperf3 streams of the throughput measurements."`
+	CrossCluster           bool    `desc:"Whether to also measure between the clusters of a multi-cluster run, which requires the pod IPs to be routable between them."`
+	MinThroughputMbps      float64 `desc:"Fail the throughput measurements below this many Mbit/s, 0 to not check the throughput."`
+	MaxLatencyP99Micros    float64 `desc:"Fail the latency measurements with a 99th percentile TCP request/response latency above this many microseconds, 0 to not check the latency."`
+	PodReadyTimeoutSeconds int     `desc:"How long to wait for the measuring pods to be ready."`
+	ReportDir              string  `desc:"Directory to write netperf.json and the junit to, defaults to $ARTIFACTS."`
+}
+
+func NewDefaultTester() *Tester {
+	return &Tester{
+		Kubeconfig:             os.Getenv("KUBECONFIG"),
+		Image:                  "quay.io/cilium/netperf",
+		Nodes:                  3,
+		DurationSeconds:        10,
+		ParallelStreams:        1,
+		CrossCluster:           true,
+		PodReadyTimeoutSeconds: 300,
+		ReportDir:              os.Getenv("ARTIFACTS"),
+	}
+}
+
+// clusterRun is the measuring pods deployed to a cluster
+type clusterRun struct {
+	index      int
+	kubeconfig string
+	namespace  string
+	endpoints  []endpoint
+}
+
+func (c *clusterRun) command(args ...string) exec.Cmd {
+	cmd := exec.Command("kubectl", args...)
+	if c.kubeconfig != "" {
+		cmd.SetEnv(append(os.Environ(), "KUBECONFIG="+c.kubeconfig)...)
+	}
+	return cmd
+}
+
+func (c *clusterRun) kubectl(args ...string) error {
+	cmd := c.command(args...)
+	exec.InheritRawOutput(cmd)
+	return cmd.Run()
+}
+
+// podManifest is a measuring pod pinned to a node, serving both iperf3 and netperf
+const podManifest = `apiVersion: v1
+kind: Pod
+metadata:
+  name: %s
+  labels:
+    app: kubetest2-netperf
+spec:
+  nodeName: %s
+  containers:
+  - name: netperf
+    image: %s
+    command: ["sh", "-c", "netserver -D & exec iperf3 -s"]
+    ports:
+    - containerPort: 5201
+    - containerPort: 12865
+    readinessProbe:
+      tcpSocket:
+        port: 5201
+`
+
+// nodesJSONPath prints each node as: name<tab>unschedulable<tab>taint effects
+const nodesJSONPath = `{range .items[*]}{.metadata.name}{"\t"}{.spec.unschedulable}{"\t"}{.spec.taints[*].effect}{"\n"}{end}`
+
+// podsJSONPath prints each pod as: name node ip
+const podsJSONPath = `{range .items[*]}{.metadata.name}{" "}{.spec.nodeName}{" "}{.status.podIP}{"\n"}{end}`
+
+// deploy runs a measuring pod on each of the measured nodes of the cluster
+func (t *Tester) deploy(c *clusterRun) error {
+	cmd := c.command("get", "nodes", "--selector=kubernetes.io/os=linux", "--output=jsonpath="+nodesJSONPath)
+	cmd.SetStderr(os.Stderr)
+	lines, err := exec.OutputLines(cmd)
+	if err != nil {
+		return fmt.Errorf("failed to list the nodes: %v", err)
+	}
+	nodes := schedulableNodes(lines)
+	if t.Nodes > 0 && len(nodes) > t.Nodes {
+		nodes = nodes[:t.Nodes]
+	}
+	if len(nodes) == 0 {
+		return fmt.Errorf("no schedulable linux nodes to measure")
+	}
+
+	if err := c.kubectl("create", "namespace", c.namespace); err != nil {
+		return err
+	}
+	var manifests []string
+	for i, node := range nodes {
+		manifests = append(manifests, fmt.Sprintf(podManifest, "netperf-"+strconv.Itoa(i), node, t.Image))
+	}
+	apply := c.command("apply", "--namespace="+c.namespace, "--filename=-")
+	apply.SetStdin(strings.NewReader(strings.Join(manifests, "---\n")))
+	exec.InheritRawOutput(apply)
+	if err := apply.Run(); err != nil {
+		return err
+	}
+	if err := c.kubectl("wait", "--for=condition=Ready", "pods", "--all", "--namespace="+c.namespace,
+		fmt.Sprintf("--timeout=%ds", t.PodReadyTimeoutSeconds)); err != nil {
+		return fmt.Errorf("the measuring pods are not ready: %v", err)
+	}
+
+	cmd = c.command("get", "pods", "--namespace="+c.namespace, "--output=jsonpath="+podsJSONPath)
+	cmd.SetStderr(os.Stderr)
+	lines, err = exec.OutputLines(cmd)
+	if err != nil {
+		return err
+	}
+	c.endpoints, err = parseEndpoints(c.index, lines)
+	return err
+}
+
+func (c *clusterRun) cleanup() {
+	cmd := c.command("delete", "namespace", c.namespace, "--ignore-not-found", "--wait=false")
+	exec.NoOutput(cmd)
+	if err := cmd.Run(); err != nil {
+		klog.Warningf("Failed to delete the netperf namespace %s: %v", c.namespace, err)
+	}
+}
+
+// exec runs a measuring command in the pod of the client endpoint
+func (c *clusterRun) exec(client endpoint, args ...string) (string, error) {
+	cmd := c.command(append([]string{"exec", client.pod, "--namespace=" + c.namespace, "--"}, args...)...)
+	var stderr strings.Builder
+	cmd.SetStderr(&stderr)
+	out, err := exec.Output(cmd)
+	if err != nil {
+		return "", fmt.Errorf("%v: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return string(out), nil
+}
+
+// measure measures the throughput and the latency from the client to the server
+func (t *Tester) measure(clusters []*clusterRun, p pair) *Measurement {
+	client := clusters[p.client.cluster]
+	m := &Measurement{
+		Client:       p.client.String(),
+		Server:       p.server.String(),
+		CrossCluster: p.client.cluster != p.server.cluster,
+	}
+	duration := strconv.Itoa(t.DurationSeconds)
+
+	out, err := client.exec(p.client, "iperf3", "--client="+p.server.ip, "--json",
+		"--time="+duration, "--parallel="+strconv.Itoa(t.ParallelStreams))
+	if err == nil {
+		m.ThroughputMbps, err = parseIperf3(out)
+	}
+	if err != nil {
+		m.ThroughputError = err.Error()
+	}
+
+	out, err = client.exec(p.client, "netperf", "-H", p.server.ip, "-t", "TCP_RR", "-l", duration, "-P", "0",
+		"--", "-o", "P50_LATENCY,P99_LATENCY,TRANSACTION_RATE")
+	if err == nil {
+		m.LatencyP50Micros, m.LatencyP99Micros, m.TransactionRate, err = parseNetperfRR(out)
+	}
+	if err != nil {
+		m.LatencyError = err.Error()
+	}
+	return m
+}
+
+// Test deploys the measuring pods, runs the measurements between every pair
+// of nodes and reports the results
+func (t *Tester) Test() error {
+	namespace := "kubetest2-netperf-" + uuid.New().String()[:8]
+	kubeconfigs := filepath.SplitList(t.Kubeconfig)
+	if len(kubeconfigs) == 0 {
+		kubeconfigs = []string{""}
+	}
+	var clusters []*clusterRun
+	for i, kubeconfig := range kubeconfigs {
+		c := &clusterRun{index: i, kubeconfig: kubeconfig, namespace: namespace}
+		clusters = append(clusters, c)
+		defer c.cleanup()
+		if err := t.deploy(c); err != nil {
+			// the tests did not run, so running the tester again may succeed
+			_ = testers.WriteResult(&testers.Result{Retryable: true})
+			return fmt.Errorf("failed to deploy the netperf pods to cluster %d: %v", i, err)
+		}
+	}
+
+	var endpoints [][]endpoint
+	for _, c := range clusters {
+		endpoints = append(endpoints, c.endpoints)
+	}
+	var measurements []*Measurement
+	for _, p := range measurementPairs(endpoints, t.CrossCluster) {
+		klog.Infof("Measuring from %s to %s", p.client, p.server)
+		measurements = append(measurements, t.measure(clusters, p))
+	}
+	return t.report(measurements)
+}
+
+func (t *Tester) Execute() error {
+	fs, err := gpflag.Parse(t)
+	if err != nil {
+		return fmt.Errorf("failed to initialize tester: %v", err)
+	}
+
+	if testers.DescribeRequested(os.Args[1:]) {
+		return testers.Describe("netperf", GitTag, "measures the pod to pod network throughput and latency between the nodes", fs)
+	}
+
+	klog.InitFlags(nil)
+	fs.AddGoFlagSet(flag.CommandLine)
+
+	help := fs.BoolP("help", "h", false, "")
+	if err := fs.Parse(os.Args); err != nil {
+		return fmt.Errorf("failed to parse flags: %v", err)
+	}
+
+	if *help {
+		fs.SetOutput(os.Stdout)
+		fs.PrintDefaults()
+		return nil
+	}
+	if t.DurationSeconds <= 0 || t.ParallelStreams <= 0 {
+		return fmt.Errorf("--duration-seconds and --parallel-streams must be positive")
+	}
+	if err := testers.WriteVersionToMetadata(GitTag); err != nil {
+		return err
+	}
+	return t.Test()
+}
+
+func Main() {
+	t := NewDefaultTester()
+	if err := t.Execute(); err != nil {
+		klog.Fatalf("failed to run netperf tester: %v", err)
+	}
+}
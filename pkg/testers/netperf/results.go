@@ -0,0 +1,263 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package netperf
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"k8s.io/klog"
+
+	"sigs.k8s.io/kubetest2/pkg/metadata"
+	"sigs.k8s.io/kubetest2/pkg/testers"
+)
+
+// the outputs of the tester, in the report dir
+const (
+	resultsFile = "netperf.json"
+	junitFile   = "junit_netperf.xml"
+)
+
+// endpoint is a measuring pod
+type endpoint struct {
+	cluster int
+	node    string
+	pod     string
+	ip      string
+}
+
+func (e endpoint) String() string {
+	return fmt.Sprintf("cluster-%d/%s", e.cluster, e.node)
+}
+
+// pair is a measurement from the client to the server
+type pair struct {
+	client endpoint
+	server endpoint
+}
+
+// schedulableNodes returns the nodes of the nodesJSONPath output that are
+// neither cordoned nor tainted to repel the pods
+func schedulableNodes(lines []string) []string {
+	var nodes []string
+	for _, line := range lines {
+		fields := strings.Split(line, "\t")
+		if fields[0] == "" {
+			continue
+		}
+		if len(fields) > 1 && fields[1] == "true" {
+			continue
+		}
+		if len(fields) > 2 && repelsPods(strings.Fields(fields[2])) {
+			continue
+		}
+		nodes = append(nodes, fields[0])
+	}
+	return nodes
+}
+
+// repelsPods returns whether any of the taint effects keeps the measuring
+// pods, which tolerate no taints, off the node
+func repelsPods(effects []string) bool {
+	for _, effect := range effects {
+		if effect == "NoSchedule" || effect == "NoExecute" {
+			return true
+		}
+	}
+	return false
+}
+
+// parseEndpoints parses the podsJSONPath output of the measuring pods of a cluster
+func parseEndpoints(cluster int, lines []string) ([]endpoint, error) {
+	var endpoints []endpoint
+	for _, line := range lines {
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) != 3 {
+			return nil, fmt.Errorf("measuring pod %s has no IP", fields[0])
+		}
+		endpoints = append(endpoints, endpoint{cluster: cluster, pod: fields[0], node: fields[1], ip: fields[2]})
+	}
+	return endpoints, nil
+}
+
+// measurementPairs returns the pairs of endpoints to measure: every ordered
+// pair of nodes of each cluster, and with crossCluster the first node of every
+// ordered pair of clusters
+func measurementPairs(clusters [][]endpoint, crossCluster bool) []pair {
+	var pairs []pair
+	for _, endpoints := range clusters {
+		for _, client := range endpoints {
+			for _, server := range endpoints {
+				if client.node != server.node {
+					pairs = append(pairs, pair{client: client, server: server})
+				}
+			}
+		}
+	}
+	if !crossCluster {
+		return pairs
+	}
+	for i, clients := range clusters {
+		for j, servers := range clusters {
+			if i != j && len(clients) > 0 && len(servers) > 0 {
+				pairs = append(pairs, pair{client: clients[0], server: servers[0]})
+			}
+		}
+	}
+	return pairs
+}
+
+// Measurement is the throughput and latency measured from the client to the server
+type Measurement struct {
+	Client           string  `json:"client"`
+	Server           string  `json:"server"`
+	CrossCluster     bool    `json:"crossCluster,omitempty"`
+	ThroughputMbps   float64 `json:"throughputMbps"`
+	ThroughputError  string  `json:"throughputError,omitempty"`
+	LatencyP50Micros float64 `json:"latencyP50Micros"`
+	LatencyP99Micros float64 `json:"latencyP99Micros"`
+	TransactionRate  float64 `json:"transactionRate"`
+	LatencyError     string  `json:"latencyError,omitempty"`
+}
+
+type iperf3Output struct {
+	Error string `json:"error"`
+	End   struct {
+		SumReceived struct {
+			BitsPerSecond float64 `json:"bits_per_second"`
+		} `json:"sum_received"`
+	} `json:"end"`
+}
+
+// parseIperf3 returns the throughput in Mbit/s received by the server of an iperf3 --json run
+func parseIperf3(out string) (float64, error) {
+	o := &iperf3Output{}
+	if err := json.Unmarshal([]byte(out), o); err != nil {
+		return 0, fmt.Errorf("failed to parse the iperf3 output: %v", err)
+	}
+	if o.Error != "" {
+		return 0, fmt.Errorf("iperf3 failed: %s", o.Error)
+	}
+	return o.End.SumReceived.BitsPerSecond / 1e6, nil
+}
+
+// parseNetperfRR returns the P50_LATENCY,P99_LATENCY,TRANSACTION_RATE output
+// selectors of a netperf TCP_RR run, the last line of its output
+func parseNetperfRR(out string) (p50, p99, rate float64, err error) {
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	fields := strings.Split(strings.TrimSpace(lines[len(lines)-1]), ",")
+	if len(fields) != 3 {
+		return 0, 0, 0, fmt.Errorf("unexpected netperf output %q", out)
+	}
+	var values [3]float64
+	for i, field := range fields {
+		v, err := strconv.ParseFloat(strings.TrimSpace(field), 64)
+		if err != nil {
+			return 0, 0, 0, fmt.Errorf("unexpected netperf output %q: %v", out, err)
+		}
+		values[i] = v
+	}
+	return values[0], values[1], values[2], nil
+}
+
+// checkThroughput fails the measurement if it errored or is below the minimum, 0 for no minimum
+func checkThroughput(m *Measurement, minMbps float64) error {
+	if m.ThroughputError != "" {
+		return errors.New(m.ThroughputError)
+	}
+	if minMbps > 0 && m.ThroughputMbps < minMbps {
+		return fmt.Errorf("throughput of %.1f Mbit/s is below the minimum of %.1f Mbit/s", m.ThroughputMbps, minMbps)
+	}
+	return nil
+}
+
+// checkLatency fails the measurement if it errored or is above the maximum, 0 for no maximum
+func checkLatency(m *Measurement, maxP99Micros float64) error {
+	if m.LatencyError != "" {
+		return errors.New(m.LatencyError)
+	}
+	if maxP99Micros > 0 && m.LatencyP99Micros > maxP99Micros {
+		return fmt.Errorf("99th percentile latency of %.0fus is above the maximum of %.0fus", m.LatencyP99Micros, maxP99Micros)
+	}
+	return nil
+}
+
+// report writes the measurements to netperf.json and a junit test case per
+// throughput and latency measurement, failing if any of them failed
+func (t *Tester) report(measurements []*Measurement) error {
+	if t.ReportDir == "" {
+		t.ReportDir = "."
+	}
+	if err := os.MkdirAll(t.ReportDir, os.ModePerm); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(measurements, "", "  ")
+	if err != nil {
+		return err
+	}
+	resultsPath := filepath.Join(t.ReportDir, resultsFile)
+	if err := ioutil.WriteFile(resultsPath, data, 0644); err != nil {
+		return fmt.Errorf("failed to write the netperf results: %v", err)
+	}
+
+	junitPath := filepath.Join(t.ReportDir, junitFile)
+	junit, err := os.Create(junitPath)
+	if err != nil {
+		return fmt.Errorf("failed to create the junit: %v", err)
+	}
+	defer junit.Close()
+	writer := metadata.NewWriter("netperf", junit)
+	result := &testers.Result{Artifacts: []string{resultsFile, junitFile}}
+	for _, m := range measurements {
+		for _, check := range []struct {
+			name string
+			err  error
+		}{
+			{name: fmt.Sprintf("[netperf] throughput from %s to %s", m.Client, m.Server), err: checkThroughput(m, t.MinThroughputMbps)},
+			{name: fmt.Sprintf("[netperf] latency from %s to %s", m.Client, m.Server), err: checkLatency(m, t.MaxLatencyP99Micros)},
+		} {
+			err := check.err
+			_ = writer.WrapStep(check.name, func() error { return err })
+			if err != nil {
+				result.Failed++
+				result.Failures = append(result.Failures, testers.Failure{Name: check.name, Message: err.Error()})
+			} else {
+				result.Passed++
+			}
+		}
+		klog.Infof("%s -> %s: %.1f Mbit/s, p50 %.0fus, p99 %.0fus", m.Client, m.Server, m.ThroughputMbps, m.LatencyP50Micros, m.LatencyP99Micros)
+	}
+	if err := writer.Finish(); err != nil {
+		return fmt.Errorf("failed to write the junit: %v", err)
+	}
+	if err := testers.WriteResult(result); err != nil {
+		return err
+	}
+	if result.Failed > 0 {
+		return fmt.Errorf("%d of %d netperf measurements failed", result.Failed, result.Failed+result.Passed)
+	}
+	return nil
+}
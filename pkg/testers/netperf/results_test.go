@@ -0,0 +1,133 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package netperf
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSchedulableNodes(t *testing.T) {
+	lines := []string{
+		"node-a\t\t",
+		"node-b\ttrue\t",
+		"control-plane\t\tNoSchedule",
+		"node-c\t\tPreferNoSchedule",
+		"node-d\t\tNoExecute PreferNoSchedule",
+		"",
+	}
+	expected := []string{"node-a", "node-c"}
+	if actual := schedulableNodes(lines); !reflect.DeepEqual(actual, expected) {
+		t.Errorf("expected %v, but got %v", expected, actual)
+	}
+}
+
+func TestMeasurementPairs(t *testing.T) {
+	a := endpoint{cluster: 0, node: "a"}
+	b := endpoint{cluster: 0, node: "b"}
+	c := endpoint{cluster: 1, node: "c"}
+	clusters := [][]endpoint{{a, b}, {c}}
+
+	expected := []pair{{client: a, server: b}, {client: b, server: a}}
+	if actual := measurementPairs(clusters, false); !reflect.DeepEqual(actual, expected) {
+		t.Errorf("expected %v, but got %v", expected, actual)
+	}
+	expected = append(expected, pair{client: a, server: c}, pair{client: c, server: a})
+	if actual := measurementPairs(clusters, true); !reflect.DeepEqual(actual, expected) {
+		t.Errorf("expected %v, but got %v", expected, actual)
+	}
+}
+
+func TestParseIperf3(t *testing.T) {
+	mbps, err := parseIperf3(`{"start": {}, "end": {"sum_sent": {"bits_per_second": 9.5e9}, "sum_received": {"bits_per_second": 9.4e9}}}`)
+	if err != nil {
+		t.Fatalf("did not expect an error, but got: %v", err)
+	}
+	if mbps != 9400 {
+		t.Errorf("expected 9400 Mbit/s, but got %v", mbps)
+	}
+	if _, err := parseIperf3(`{"error": "unable to connect to server: Connection refused"}`); err == nil {
+		t.Errorf("expected an error for a failed run but got none")
+	}
+	if _, err := parseIperf3("iperf3: error"); err == nil {
+		t.Errorf("expected an error for invalid output but got none")
+	}
+}
+
+func TestParseNetperfRR(t *testing.T) {
+	testCases := []struct {
+		name        string
+		out         string
+		p50, p99    float64
+		rate        float64
+		expectError bool
+	}{
+		{
+			name: "values only",
+			out:  "61,104,15873.51\n",
+			p50:  61, p99: 104, rate: 15873.51,
+		},
+		{
+			name: "with header",
+			out:  "50th Percentile Latency Microseconds,99th Percentile Latency Microseconds,Transaction Rate Tran/s\n61,104,15873.51\n",
+			p50:  61, p99: 104, rate: 15873.51,
+		},
+		{
+			name:        "error",
+			out:         "establish control: are you sure there is a netserver listening on 10.0.0.1 at port 12865?\n",
+			expectError: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			p50, p99, rate, err := parseNetperfRR(tc.out)
+			if tc.expectError {
+				if err == nil {
+					t.Errorf("expected an error but got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Errorf("did not expect an error, but got: %v", err)
+			}
+			if p50 != tc.p50 || p99 != tc.p99 || rate != tc.rate {
+				t.Errorf("expected %v,%v,%v, but got %v,%v,%v", tc.p50, tc.p99, tc.rate, p50, p99, rate)
+			}
+		})
+	}
+}
+
+func TestChecks(t *testing.T) {
+	m := &Measurement{ThroughputMbps: 900, LatencyP99Micros: 250}
+	if err := checkThroughput(m, 0); err != nil {
+		t.Errorf("did not expect an error without a minimum, but got: %v", err)
+	}
+	if err := checkThroughput(m, 1000); err == nil {
+		t.Errorf("expected an error below the minimum but got none")
+	}
+	if err := checkLatency(m, 500); err != nil {
+		t.Errorf("did not expect an error below the maximum, but got: %v", err)
+	}
+	if err := checkLatency(m, 200); err == nil {
+		t.Errorf("expected an error above the maximum but got none")
+	}
+	if err := checkThroughput(&Measurement{ThroughputError: "connection refused"}, 0); err == nil {
+		t.Errorf("expected an error for a failed measurement but got none")
+	}
+}
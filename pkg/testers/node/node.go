@@ -31,10 +31,9 @@ import (
 	"github.com/octago/sflags/gen/gpflag"
 	"k8s.io/klog"
 
-	"sigs.k8s.io/boskos/client"
-	"sigs.k8s.io/kubetest2/pkg/boskos"
 	"sigs.k8s.io/kubetest2/pkg/exec"
 	"sigs.k8s.io/kubetest2/pkg/fs"
+	"sigs.k8s.io/kubetest2/pkg/projects"
 	"sigs.k8s.io/kubetest2/pkg/testers"
 )
 
@@ -49,6 +48,7 @@ const (
 type Tester struct {
 	RepoRoot                       string `desc:"Absolute path to kubernetes repository root."`
 	GCPProject                     string `desc:"GCP Project to create VMs in. If unset, the deployer will attempt to get a project from boskos."`
+	ProjectSource                  string `desc:"Where to get the GCP project from: static for --gcp-project, boskos to lease it from boskos, or env for the project of $KUBETEST2_PROJECTS. Defaults to static if --gcp-project is set and boskos otherwise."`
 	GCPZone                        string `desc:"GCP Zone to create VMs in."`
 	SkipRegex                      string `desc:"Regular expression of jobs to skip."`
 	FocusRegex                     string `desc:"Regular expression of jobs to focus on."`
@@ -63,13 +63,8 @@ type Tester struct {
 	GCPProjectType                 string `desc:"Explicitly indicate which project type to select from boskos."`
 	RuntimeConfig                  string `desc:"The runtime configuration for the API server. Format: a list of key=value pairs."`
 
-	// boskos struct field will be non-nil when the deployer is
-	// using boskos to acquire a GCP project
-	boskos *client.Client
-
-	// this channel serves as a signal channel for the hearbeat goroutine
-	// so that it can be explicitly closed
-	boskosHeartbeatClose chan struct{}
+	// projectLease holds the project acquired from the --project-source
+	projectLease *projects.Lease
 
 	// this contains ssh key path
 	privateKey string
@@ -84,17 +79,39 @@ func NewDefaultTester() *Tester {
 		BoskosAcquireTimeoutSeconds:    5 * 60,
 		BoskosHeartbeatIntervalSeconds: 5 * 60,
 		Parallelism:                    8,
-		boskosHeartbeatClose:           make(chan struct{}),
 		GCPProjectType:                 "gce-project",
 	}
 }
 
+// acquireProject acquires the project to create the VMs in from the --project-source
+func (t *Tester) acquireProject() error {
+	lease, err := projects.AcquireProject(&projects.Config{
+		Source:                  t.ProjectSource,
+		BoskosLocation:          t.BoskosLocation,
+		BoskosRequests:          []projects.BoskosRequest{{ResourceType: t.GCPProjectType, Count: 1}},
+		BoskosAcquireTimeout:    time.Duration(t.BoskosAcquireTimeoutSeconds) * time.Second,
+		BoskosHeartbeatInterval: time.Duration(t.BoskosHeartbeatIntervalSeconds) * time.Second,
+		// the projects of the run are those of the deployer
+		SkipMetadata: true,
+	}, t.GCPProject)
+	if err != nil {
+		return err
+	}
+	t.projectLease = lease
+	t.GCPProject = lease.Projects()[0]
+	return nil
+}
+
 func (t *Tester) Execute() error {
 	fs, err := gpflag.Parse(t)
 	if err != nil {
 		return fmt.Errorf("failed to initialize tester: %v", err)
 	}
 
+	if testers.DescribeRequested(os.Args[1:]) {
+		return testers.Describe("node", GitTag, "runs the kubernetes node e2e tests", fs)
+	}
+
 	klog.InitFlags(nil)
 	fs.AddGoFlagSet(flag.CommandLine)
 
@@ -125,40 +142,13 @@ func (t *Tester) Execute() error {
 
 	t.maybeSetupSSHKeys()
 
-	// try to acquire project from boskos
-	if t.GCPProject == "" {
-		klog.V(1).Info("no GCP project provided, acquiring from Boskos ...")
-
-		boskosClient, err := boskos.NewClient(t.BoskosLocation)
-		if err != nil {
-			return fmt.Errorf("failed to make boskos client: %s", err)
-		}
-		t.boskos = boskosClient
-
-		resource, err := boskos.Acquire(
-			t.boskos,
-			t.GCPProjectType,
-			time.Duration(t.BoskosAcquireTimeoutSeconds)*time.Second,
-			time.Duration(t.BoskosHeartbeatIntervalSeconds)*time.Second,
-			t.boskosHeartbeatClose,
-		)
-
-		if err != nil {
-			return fmt.Errorf("init failed to get project from boskos: %s", err)
-		}
-		t.GCPProject = resource.Name
-		klog.V(1).Infof("got project %s from boskos", t.GCPProject)
+	if err := t.acquireProject(); err != nil {
+		return fmt.Errorf("init failed to acquire the project: %s", err)
 	}
-
 	defer func() {
-		if t.boskos != nil {
+		if t.projectLease.Leased() {
 			klog.V(1).Info("releasing boskos project")
-			err := boskos.Release(
-				t.boskos,
-				[]string{t.GCPProject},
-				t.boskosHeartbeatClose,
-			)
-			if err != nil {
+			if err := t.projectLease.Release(); err != nil {
 				klog.Errorf("failed to release boskos project: %v", err)
 			}
 		}
@@ -257,7 +247,7 @@ func (t *Tester) Test() error {
 	args = append(args, t.constructArgs()...)
 	cmd := exec.Command("make", args...)
 	cmd.SetDir(t.RepoRoot)
-	exec.InheritOutput(cmd)
+	exec.InheritRawOutput(cmd)
 	return cmd.Run()
 }
 
@@ -0,0 +1,168 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package testers
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+)
+
+// ResultFileEnv is the environment variable kubetest2 sets to the path of
+// the file the tester writes its Result to. The file is optional, without it
+// kubetest2 only knows the exit code of the tester.
+const ResultFileEnv = "KUBETEST2_TESTER_RESULT_FILE"
+
+// maxSummaryFailures is the number of failures listed in a result summary
+const maxSummaryFailures = 10
+
+// Result is the outcome of a tester run reported back to kubetest2, which
+// records it in the metadata of the run and uses it to decide on retries
+type Result struct {
+	Passed   int       `json:"passed"`
+	Failed   int       `json:"failed"`
+	Skipped  int       `json:"skipped"`
+	Failures []Failure `json:"failures,omitempty"`
+	// Artifacts are the paths under $ARTIFACTS or the URLs of the outputs of the tests e.g. the junit files
+	Artifacts []string `json:"artifacts,omitempty"`
+	// Retryable is set if the failure of the run does not come from the tests
+	// themselves e.g. if the test setup failed, so that running the tester
+	// again may succeed
+	Retryable bool `json:"retryable,omitempty"`
+}
+
+// Failure is a failed test
+type Failure struct {
+	Name    string `json:"name"`
+	Message string `json:"message,omitempty"`
+}
+
+// WriteResult writes the result to the file kubetest2 reads it from, if any
+func WriteResult(r *Result) error {
+	path := os.Getenv(ResultFileEnv)
+	if path == "" {
+		return nil
+	}
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, data, 0644)
+}
+
+// ReadResult reads the result written by the tester, returning nil if it did not write one
+func ReadResult(path string) (*Result, error) {
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	r := &Result{}
+	if err := json.Unmarshal(data, r); err != nil {
+		return nil, fmt.Errorf("failed to parse the tester result %s: %v", path, err)
+	}
+	return r, nil
+}
+
+// Merge returns the sum of the results of the runs of several testers, or nil if none
+// of them reported a result. The merged result is retryable if all the results are.
+func Merge(results ...*Result) *Result {
+	var merged *Result
+	for _, r := range results {
+		if r == nil {
+			continue
+		}
+		if merged == nil {
+			merged = &Result{Retryable: true}
+		}
+		merged.Passed += r.Passed
+		merged.Failed += r.Failed
+		merged.Skipped += r.Skipped
+		merged.Failures = append(merged.Failures, r.Failures...)
+		merged.Artifacts = append(merged.Artifacts, r.Artifacts...)
+		merged.Retryable = merged.Retryable && r.Retryable
+	}
+	return merged
+}
+
+// Summary returns the counts and the first failures of the result
+func (r *Result) Summary() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%d passed, %d failed, %d skipped", r.Passed, r.Failed, r.Skipped)
+	for i, f := range r.Failures {
+		if i == maxSummaryFailures {
+			fmt.Fprintf(&b, "\n... and %d more failures", len(r.Failures)-maxSummaryFailures)
+			break
+		}
+		fmt.Fprintf(&b, "\nFAIL: %s", f.Name)
+		if f.Message != "" {
+			fmt.Fprintf(&b, "\n    %s", strings.Split(strings.TrimSpace(f.Message), "\n")[0])
+		}
+	}
+	return b.String()
+}
+
+type junitTestCase struct {
+	Name    string `xml:"name,attr"`
+	Failure *struct {
+		Message string `xml:"message,attr"`
+	} `xml:"failure"`
+	Skipped *struct{} `xml:"skipped"`
+}
+
+type junitTestSuite struct {
+	TestCases []junitTestCase `xml:"testcase"`
+}
+
+// junitFile is either a testsuite or a testsuites document
+type junitFile struct {
+	junitTestSuite
+	TestSuites []junitTestSuite `xml:"testsuite"`
+}
+
+// ResultFromJUnit returns the result of the test cases of the junit files
+func ResultFromJUnit(paths []string) (*Result, error) {
+	r := &Result{}
+	for _, path := range paths {
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		f := &junitFile{}
+		if err := xml.Unmarshal(data, f); err != nil {
+			return nil, fmt.Errorf("failed to parse junit file %s: %v", path, err)
+		}
+		for _, suite := range append([]junitTestSuite{f.junitTestSuite}, f.TestSuites...) {
+			for _, tc := range suite.TestCases {
+				switch {
+				case tc.Failure != nil:
+					r.Failed++
+					r.Failures = append(r.Failures, Failure{Name: tc.Name, Message: tc.Failure.Message})
+				case tc.Skipped != nil:
+					r.Skipped++
+				default:
+					r.Passed++
+				}
+			}
+		}
+	}
+	return r, nil
+}
@@ -0,0 +1,130 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package testers
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestResultFromJUnit(t *testing.T) {
+	dir, err := ioutil.TempDir("", "junit")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	suite := filepath.Join(dir, "junit_01.xml")
+	if err := ioutil.WriteFile(suite, []byte(`<?xml version="1.0" encoding="UTF-8"?>
+<testsuite name="Kubernetes e2e suite" tests="3" failures="1">
+  <testcase name="[sig-node] Pods should run"></testcase>
+  <testcase name="[sig-network] DNS should resolve"><failure message="timed out waiting for the condition">stack</failure></testcase>
+  <testcase name="[sig-storage] Slow test"><skipped></skipped></testcase>
+</testsuite>`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	suites := filepath.Join(dir, "junit_02.xml")
+	if err := ioutil.WriteFile(suites, []byte(`<testsuites>
+  <testsuite name="a"><testcase name="passes"></testcase></testsuite>
+  <testsuite name="b"><testcase name="also passes"></testcase></testsuite>
+</testsuites>`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	actual, err := ResultFromJUnit([]string{suite, suites})
+	if err != nil {
+		t.Fatalf("did not expect an error, but got: %v", err)
+	}
+	expected := &Result{
+		Passed:   3,
+		Failed:   1,
+		Skipped:  1,
+		Failures: []Failure{{Name: "[sig-network] DNS should resolve", Message: "timed out waiting for the condition"}},
+	}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("expected %+v, but got %+v", expected, actual)
+	}
+}
+
+func TestResultRoundTrip(t *testing.T) {
+	dir, err := ioutil.TempDir("", "result")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "tester-result.json")
+
+	if r, err := ReadResult(path); r != nil || err != nil {
+		t.Errorf("expected no result and no error for a missing file, but got %v, %v", r, err)
+	}
+
+	os.Setenv(ResultFileEnv, path)
+	defer os.Unsetenv(ResultFileEnv)
+	expected := &Result{Passed: 1, Failed: 1, Failures: []Failure{{Name: "test"}}, Artifacts: []string{"junit_01.xml"}, Retryable: true}
+	if err := WriteResult(expected); err != nil {
+		t.Fatalf("failed to write the result: %v", err)
+	}
+	actual, err := ReadResult(path)
+	if err != nil {
+		t.Fatalf("failed to read the result: %v", err)
+	}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("expected %+v, but got %+v", expected, actual)
+	}
+}
+
+func TestResultSummary(t *testing.T) {
+	r := &Result{Passed: 5, Failed: 12}
+	for i := 0; i < 12; i++ {
+		r.Failures = append(r.Failures, Failure{Name: "test", Message: "first line\nsecond line"})
+	}
+	summary := r.Summary()
+	if !strings.HasPrefix(summary, "5 passed, 12 failed, 0 skipped\nFAIL: test\n    first line\n") {
+		t.Errorf("unexpected summary:\n%s", summary)
+	}
+	if strings.Contains(summary, "second line") {
+		t.Errorf("expected only the first line of the failure messages:\n%s", summary)
+	}
+	if strings.Count(summary, "FAIL:") != maxSummaryFailures || !strings.HasSuffix(summary, "... and 2 more failures") {
+		t.Errorf("expected the failures to be truncated:\n%s", summary)
+	}
+}
+
+func TestMerge(t *testing.T) {
+	if merged := Merge(nil, nil); merged != nil {
+		t.Errorf("expected no result when no tester reported one, but got %+v", merged)
+	}
+	merged := Merge(
+		&Result{Passed: 10, Skipped: 2, Artifacts: []string{"testers/ginkgo/junit_01.xml"}, Retryable: true},
+		nil,
+		&Result{Passed: 1, Failed: 1, Failures: []Failure{{Name: "smoke"}}},
+	)
+	expected := &Result{
+		Passed:    11,
+		Failed:    1,
+		Skipped:   2,
+		Failures:  []Failure{{Name: "smoke"}},
+		Artifacts: []string{"testers/ginkgo/junit_01.xml"},
+	}
+	if !reflect.DeepEqual(merged, expected) {
+		t.Errorf("expected %+v, but got %+v", expected, merged)
+	}
+}
@@ -0,0 +1,347 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package trace records the phases of a kubetest2 run and the external
+// commands it executes as OpenTelemetry spans, which are exported in the
+// OTLP JSON encoding, either to an OTLP/HTTP endpoint or to a file.
+//
+// The spans of a run form a single trace: the root span covers the run,
+// spans started with Wrap nest, and spans started with StartSpan (e.g. for
+// commands, which may run concurrently) are leaves of the innermost Wrap.
+package trace
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Span is a timed operation of the run, a nil span is a no-op
+type Span struct {
+	tracer     *Tracer
+	id         string
+	parent     *Span
+	name       string
+	start      time.Time
+	end        time.Time
+	attributes map[string]string
+	err        error
+}
+
+// End ends the span, recording err as its status
+func (s *Span) End(err error) {
+	if s == nil {
+		return
+	}
+	s.tracer.mu.Lock()
+	defer s.tracer.mu.Unlock()
+	if !s.end.IsZero() {
+		return
+	}
+	s.end = time.Now()
+	s.err = err
+}
+
+// Tracer collects the spans of a run, it is safe for concurrent use.
+// The zero value is a disabled tracer which does not record any spans.
+type Tracer struct {
+	mu       sync.Mutex
+	enabled  bool
+	traceID  string
+	resource map[string]string
+	root     *Span
+	current  *Span
+	spans    []*Span
+}
+
+// NewTracer returns a tracer with a started root span for the run,
+// resource holds the attributes of the run e.g. the service name
+func NewTracer(rootName string, resource map[string]string) *Tracer {
+	t := &Tracer{
+		enabled:  true,
+		traceID:  randomID(16),
+		resource: resource,
+	}
+	t.root = t.newSpan(rootName, nil, nil)
+	t.current = t.root
+	return t
+}
+
+var (
+	defaultMu     sync.Mutex
+	defaultTracer = &Tracer{}
+)
+
+// SetDefault sets the tracer returned by Default
+func SetDefault(t *Tracer) {
+	defaultMu.Lock()
+	defer defaultMu.Unlock()
+	defaultTracer = t
+}
+
+// Default returns the tracer of the run, which is disabled unless set with SetDefault
+func Default() *Tracer {
+	defaultMu.Lock()
+	defer defaultMu.Unlock()
+	return defaultTracer
+}
+
+func randomID(bytes int) string {
+	b := make([]byte, bytes)
+	if _, err := rand.Read(b); err != nil {
+		// fall back to the time, the ids only need to be unique within the run
+		return fmt.Sprintf("%0*x", bytes*2, time.Now().UnixNano())[:bytes*2]
+	}
+	return hex.EncodeToString(b)
+}
+
+// newSpan must be called with the lock held, or before the tracer is shared
+func (t *Tracer) newSpan(name string, parent *Span, attributes map[string]string) *Span {
+	s := &Span{
+		tracer:     t,
+		id:         randomID(8),
+		parent:     parent,
+		name:       name,
+		start:      time.Now(),
+		attributes: attributes,
+	}
+	t.spans = append(t.spans, s)
+	return s
+}
+
+// StartSpan starts a span that is a child of the innermost Wrap,
+// it returns nil if the tracer is disabled
+func (t *Tracer) StartSpan(name string, attributes map[string]string) *Span {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if !t.enabled {
+		return nil
+	}
+	return t.newSpan(name, t.current, attributes)
+}
+
+// Wrap runs f in a span, the spans started while f runs are its children
+func (t *Tracer) Wrap(name string, f func() error) error {
+	t.mu.Lock()
+	if !t.enabled {
+		t.mu.Unlock()
+		return f()
+	}
+	s := t.newSpan(name, t.current, nil)
+	previous := t.current
+	t.current = s
+	t.mu.Unlock()
+
+	err := f()
+	s.End(err)
+
+	t.mu.Lock()
+	t.current = previous
+	t.mu.Unlock()
+	return err
+}
+
+// Traceparent returns the W3C traceparent of the root span to propagate the
+// trace to e.g. the tester, it returns an empty string if the tracer is disabled
+func (t *Tracer) Traceparent() string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if !t.enabled {
+		return ""
+	}
+	return fmt.Sprintf("00-%s-%s-01", t.traceID, t.root.id)
+}
+
+// Finish ends the root span and any span that was not ended
+func (t *Tracer) Finish(err error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if !t.enabled {
+		return
+	}
+	now := time.Now()
+	for _, s := range t.spans {
+		if s.end.IsZero() {
+			s.end = now
+			if s == t.root {
+				s.err = err
+			}
+		}
+	}
+}
+
+// OTLP JSON encoding, see
+// https://github.com/open-telemetry/opentelemetry-proto/blob/main/opentelemetry/proto/trace/v1/trace.proto
+type keyValue struct {
+	Key   string   `json:"key"`
+	Value anyValue `json:"value"`
+}
+
+type anyValue struct {
+	StringValue string `json:"stringValue"`
+}
+
+type status struct {
+	Code    int    `json:"code"`
+	Message string `json:"message,omitempty"`
+}
+
+// status codes of the OTLP Status message
+const (
+	statusOK    = 1
+	statusError = 2
+)
+
+type otlpSpan struct {
+	TraceID           string     `json:"traceId"`
+	SpanID            string     `json:"spanId"`
+	ParentSpanID      string     `json:"parentSpanId,omitempty"`
+	Name              string     `json:"name"`
+	Kind              int        `json:"kind"`
+	StartTimeUnixNano string     `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string     `json:"endTimeUnixNano"`
+	Attributes        []keyValue `json:"attributes,omitempty"`
+	Status            status     `json:"status"`
+}
+
+type scope struct {
+	Name string `json:"name"`
+}
+
+type scopeSpans struct {
+	Scope scope      `json:"scope"`
+	Spans []otlpSpan `json:"spans"`
+}
+
+type resource struct {
+	Attributes []keyValue `json:"attributes"`
+}
+
+type resourceSpans struct {
+	Resource   resource     `json:"resource"`
+	ScopeSpans []scopeSpans `json:"scopeSpans"`
+}
+
+type traces struct {
+	ResourceSpans []resourceSpans `json:"resourceSpans"`
+}
+
+// spanKindInternal is the OTLP SpanKind of all the spans
+const spanKindInternal = 1
+
+func keyValues(attributes map[string]string) []keyValue {
+	keys := make([]string, 0, len(attributes))
+	for k := range attributes {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	kvs := make([]keyValue, 0, len(keys))
+	for _, k := range keys {
+		kvs = append(kvs, keyValue{Key: k, Value: anyValue{StringValue: attributes[k]}})
+	}
+	return kvs
+}
+
+func unixNano(t time.Time) string {
+	return strconv.FormatInt(t.UnixNano(), 10)
+}
+
+// export returns the ended spans in the OTLP JSON encoding
+func (t *Tracer) export() traces {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	spans := []otlpSpan{}
+	for _, s := range t.spans {
+		if s.end.IsZero() {
+			continue
+		}
+		span := otlpSpan{
+			TraceID:           t.traceID,
+			SpanID:            s.id,
+			Name:              s.name,
+			Kind:              spanKindInternal,
+			StartTimeUnixNano: unixNano(s.start),
+			EndTimeUnixNano:   unixNano(s.end),
+			Attributes:        keyValues(s.attributes),
+			Status:            status{Code: statusOK},
+		}
+		if s.parent != nil {
+			span.ParentSpanID = s.parent.id
+		}
+		if s.err != nil {
+			span.Status = status{Code: statusError, Message: s.err.Error()}
+		}
+		spans = append(spans, span)
+	}
+	return traces{
+		ResourceSpans: []resourceSpans{{
+			Resource: resource{Attributes: keyValues(t.resource)},
+			ScopeSpans: []scopeSpans{{
+				Scope: scope{Name: "sigs.k8s.io/kubetest2"},
+				Spans: spans,
+			}},
+		}},
+	}
+}
+
+// Write writes the ended spans in the OTLP JSON encoding
+func (t *Tracer) Write(w io.Writer) error {
+	return json.NewEncoder(w).Encode(t.export())
+}
+
+// WriteFile writes the ended spans to path in the OTLP JSON encoding
+func (t *Tracer) WriteFile(path string) error {
+	var buf bytes.Buffer
+	if err := t.Write(&buf); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, buf.Bytes(), 0644)
+}
+
+// Export sends the ended spans to the OTLP/HTTP endpoint,
+// e.g. http://otel-collector:4318
+func (t *Tracer) Export(endpoint string) error {
+	if !strings.HasPrefix(endpoint, "http://") && !strings.HasPrefix(endpoint, "https://") {
+		return fmt.Errorf("invalid OTLP endpoint %q: expected e.g. http://otel-collector:4318", endpoint)
+	}
+	target := strings.TrimSuffix(endpoint, "/") + "/v1/traces"
+	var buf bytes.Buffer
+	if err := t.Write(&buf); err != nil {
+		return err
+	}
+	client := &http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Post(target, "application/json", &buf)
+	if err != nil {
+		return fmt.Errorf("failed to export the trace to %s: %v", target, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		body, _ := ioutil.ReadAll(resp.Body)
+		return fmt.Errorf("failed to export the trace to %s: %s: %s", target, resp.Status, strings.TrimSpace(string(body)))
+	}
+	return nil
+}
@@ -0,0 +1,135 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package trace
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+)
+
+func TestDisabledTracer(t *testing.T) {
+	tracer := &Tracer{}
+	if span := tracer.StartSpan("exec gcloud", nil); span != nil {
+		t.Errorf("expected a nil span from a disabled tracer, but got %v", span)
+	}
+	// ending a nil span is a no-op
+	tracer.StartSpan("exec gcloud", nil).End(nil)
+
+	called := false
+	err := tracer.Wrap("Up", func() error {
+		called = true
+		return errors.New("failed")
+	})
+	if !called || err == nil {
+		t.Errorf("expected Wrap to call the function and return its error")
+	}
+	if tp := tracer.Traceparent(); tp != "" {
+		t.Errorf("expected no traceparent from a disabled tracer, but got %s", tp)
+	}
+}
+
+func TestSpans(t *testing.T) {
+	tracer := NewTracer("kubetest2", map[string]string{"service.name": "kubetest2"})
+	err := tracer.Wrap("Up", func() error {
+		tracer.StartSpan("exec gcloud", map[string]string{"exec.command": "gcloud version"}).End(nil)
+		return tracer.Wrap("CreateClusters", func() error {
+			span := tracer.StartSpan("exec kubectl", nil)
+			span.End(errors.New("exit status 1"))
+			return errors.New("failed")
+		})
+	})
+	if err == nil {
+		t.Errorf("expected Wrap to return the error")
+	}
+	// started after the Wrap, so a child of the root
+	tracer.StartSpan("exec ginkgo", nil).End(nil)
+	tracer.Finish(nil)
+
+	exported := tracer.export()
+	spans := exported.ResourceSpans[0].ScopeSpans[0].Spans
+	byName := map[string]otlpSpan{}
+	for _, s := range spans {
+		byName[s.Name] = s
+	}
+	if len(byName) != 6 {
+		t.Fatalf("expected 6 spans, but got %d: %v", len(byName), spans)
+	}
+	parents := map[string]string{
+		"kubetest2":      "",
+		"Up":             "kubetest2",
+		"exec gcloud":    "Up",
+		"CreateClusters": "Up",
+		"exec kubectl":   "CreateClusters",
+		"exec ginkgo":    "kubetest2",
+	}
+	for name, parent := range parents {
+		expected := ""
+		if parent != "" {
+			expected = byName[parent].SpanID
+		}
+		if actual := byName[name].ParentSpanID; actual != expected {
+			t.Errorf("expected span %s to have parent %q, but got %q", name, parent, actual)
+		}
+	}
+	if s := byName["exec kubectl"]; s.Status.Code != statusError || s.Status.Message != "exit status 1" {
+		t.Errorf("expected an error status for exec kubectl, but got %v", s.Status)
+	}
+	if s := byName["exec gcloud"]; s.Status.Code != statusOK || len(s.Attributes) != 1 {
+		t.Errorf("expected an ok status and an attribute for exec gcloud, but got %v", s)
+	}
+
+	traceparent := regexp.MustCompile(`^00-[0-9a-f]{32}-[0-9a-f]{16}-01$`)
+	if tp := tracer.Traceparent(); !traceparent.MatchString(tp) {
+		t.Errorf("invalid traceparent %s", tp)
+	}
+}
+
+func TestExport(t *testing.T) {
+	var path, contentType string
+	var body []byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		path, contentType = req.URL.Path, req.Header.Get("Content-Type")
+		body, _ = ioutil.ReadAll(req.Body)
+	}))
+	defer server.Close()
+
+	tracer := NewTracer("kubetest2", nil)
+	tracer.Finish(nil)
+	if err := tracer.Export(server.URL + "/"); err != nil {
+		t.Fatalf("did not expect an error, but got: %v", err)
+	}
+	if path != "/v1/traces" || contentType != "application/json" {
+		t.Errorf("unexpected export request to %s with content type %s", path, contentType)
+	}
+	exported := traces{}
+	if err := json.NewDecoder(bytes.NewReader(body)).Decode(&exported); err != nil {
+		t.Fatalf("failed to decode the exported trace: %v", err)
+	}
+	if spans := exported.ResourceSpans[0].ScopeSpans[0].Spans; len(spans) != 1 || spans[0].Name != "kubetest2" {
+		t.Errorf("expected the root span to be exported, but got %v", spans)
+	}
+
+	if err := tracer.Export("otel-collector:4318"); err == nil {
+		t.Errorf("expected an error for an endpoint without a scheme")
+	}
+}
@@ -0,0 +1,76 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package types
+
+import (
+	"encoding/json"
+	"io"
+
+	"github.com/spf13/pflag"
+)
+
+// DescribeFlag is the flag deployers and testers accept to print their
+// Description as JSON to stdout, which is how `kubetest2 list-deployers`
+// and `kubetest2 list-testers` discover what is installed
+const DescribeFlag = "describe-json"
+
+// Description is the output of a deployer or tester run with --describe-json
+type Description struct {
+	Name        string            `json:"name"`
+	Version     string            `json:"version,omitempty"`
+	Description string            `json:"description,omitempty"`
+	Flags       []FlagDescription `json:"flags,omitempty"`
+}
+
+// FlagDescription describes a flag supported by a deployer or tester
+type FlagDescription struct {
+	Name    string `json:"name"`
+	Type    string `json:"type,omitempty"`
+	Usage   string `json:"usage,omitempty"`
+	Default string `json:"default,omitempty"`
+}
+
+// NewDescription returns the Description of a deployer or tester with the flags of fs,
+// fs may be nil
+func NewDescription(name, version, description string, fs *pflag.FlagSet) *Description {
+	d := &Description{
+		Name:        name,
+		Version:     version,
+		Description: description,
+	}
+	if fs != nil {
+		fs.VisitAll(func(f *pflag.Flag) {
+			if f.Hidden || f.Name == DescribeFlag || f.Name == "help" {
+				return
+			}
+			d.Flags = append(d.Flags, FlagDescription{
+				Name:    f.Name,
+				Type:    f.Value.Type(),
+				Usage:   f.Usage,
+				Default: f.DefValue,
+			})
+		})
+	}
+	return d
+}
+
+// Write writes the description as JSON to w
+func (d *Description) Write(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(d)
+}
@@ -0,0 +1,36 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package types
+
+// DoctorFlag is the flag deployers accept to run their preflight checks
+// instead of a run, which is how `kubetest2 doctor` checks them
+const DoctorFlag = "doctor"
+
+// Check is a preflight check of the environment of a deployer e.g. that its
+// tools are installed and the credentials it needs are set up
+type Check struct {
+	// Name says what is checked e.g. "gcloud is authenticated"
+	Name string
+	// Run returns an optional detail on success e.g. the version of a tool,
+	// or what is wrong
+	Run func() (string, error)
+	// Fix is what to do when the check fails e.g. the command to run
+	Fix string
+	// Fatal checks skip the remaining checks when they fail, as they would
+	// only fail the same way e.g. without the tool they run
+	Fatal bool
+}
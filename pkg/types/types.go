@@ -121,9 +121,87 @@ type DeployerWithVersion interface {
 	Version() string
 }
 
+// DeployerWithDescription allows the deployer to describe itself in the
+// `kubetest2 list-deployers` output
+type DeployerWithDescription interface {
+	Deployer
+
+	// Description returns a one line description of the deployer
+	Description() string
+}
+
+// DeployerWithDoctor should be implemented by deployers with preflight checks
+// of their environment, run by `kubetest2 doctor`
+type DeployerWithDoctor interface {
+	Deployer
+
+	// DoctorChecks returns the preflight checks in order, for the flags of the deployer
+	DoctorChecks() []Check
+}
+
+// DeployerWithState allows resuming a failed run with --resume by restoring
+// the state the deployer persisted in the run dir of the previous invocation
+type DeployerWithState interface {
+	Deployer
+
+	// RestoreState is called before the remaining phases of the resumed run
+	RestoreState() error
+}
+
+// DeployerWithDebugHints adds the ability to tell users how to debug the
+// cluster of a failed run, e.g. with --on-failure=pause
+type DeployerWithDebugHints interface {
+	Deployer
+
+	// DebugHints returns the commands or instructions to connect to the
+	// cluster and its nodes, beyond the kubeconfig
+	DebugHints() []string
+}
+
+// DeployerWithSnapshot adds the ability to save the etcd state of the cluster
+// and to restore it, e.g. to run destructive tests repeatedly against a
+// pristine control plane with --etcd-snapshot
+type DeployerWithSnapshot interface {
+	Deployer
+
+	// Snapshot saves the etcd state of the cluster as the named snapshot
+	Snapshot(name string) error
+	// Restore restores the etcd state of the cluster from the named snapshot,
+	// returning once the API server is ready again
+	Restore(name string) error
+}
+
+// DeployerWithUpgrade adds the ability to change the Kubernetes version of the
+// cluster between test runs, e.g. for the version skew tests of --skew-sequence.
+// Downgrades are upgrades to an older version, as far as the deployer supports them.
+type DeployerWithUpgrade interface {
+	Deployer
+
+	// UpgradeControlPlane upgrades the control plane of the cluster to the
+	// version, returning once the API server is ready again
+	UpgradeControlPlane(version string) error
+	// UpgradeNodes upgrades all the nodes of the cluster to the version
+	UpgradeNodes(version string) error
+}
+
+// DeployerWithFeatureGates adds the ability to bring up clusters with the
+// --kube-feature-gates and --runtime-config of kubetest2, translated to the
+// configuration of the deployer e.g. a kind config.
+type DeployerWithFeatureGates interface {
+	Deployer
+
+	// SetFeatureGates is called before Build and Up with the feature gates of
+	// the components and the runtime config of the API server, either may be
+	// empty. It returns an error for what the deployer cannot honor, failing
+	// the run before the cluster is brought up.
+	SetFeatureGates(featureGates map[string]bool, runtimeConfig map[string]string) error
+}
+
 // Tester defines the "interface" between kubetest2 and a tester
 // The tester is executed as a separate binary during the Test() phase
 type Tester struct {
+	// Name is the name the tester was selected with e.g. ginkgo
+	Name       string
 	TesterPath string
 	TesterArgs []string
 }